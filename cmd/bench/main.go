@@ -0,0 +1,203 @@
+// Command bench checks `go test -bench` results against the performance
+// budgets in bench/budgets.json.
+//
+// It reads benchmark output (the file arguments, or stdin) and exits
+// non-zero when any operation allocates more than its budget plus the
+// allowed tolerance, so it can gate CI via `make bench`. Only allocations
+// are budgeted: they depend on the code rather than the machine, while
+// ns/op recorded on one machine says little about another. Timings are
+// printed for reference. Benchmarks are keyed by operation and fixture:
+// BenchmarkMerge/text-heavy-8 is "merge/text-heavy".
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Budget is the allowed cost of a single operation on a single fixture
+type Budget struct {
+	MaxAllocsPerOp int64 `json:"maxAllocsPerOp"`
+	MaxBytesPerOp  int64 `json:"maxBytesPerOp"`
+}
+
+type measurement struct {
+	name        string
+	nsPerOp     int64
+	bytesPerOp  int64
+	allocsPerOp int64
+}
+
+func main() {
+	budgetsPath := flag.String("budgets", "bench/budgets.json", "path to the performance budgets file")
+	tolerance := flag.Float64("tolerance", 0.1, "allowed growth over budget (0.1 = 10%)")
+	update := flag.Bool("update", false, "write measured results as the new budgets instead of checking")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		var readers []io.Reader
+		for _, path := range flag.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				log.Fatalf("Failed to open benchmark output: %v", err)
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+		input = io.MultiReader(readers...)
+	}
+
+	results, err := parseResults(input)
+	if err != nil {
+		log.Fatalf("Failed to read benchmark output: %v", err)
+	}
+	if len(results) == 0 {
+		log.Fatal("No benchmark results found (run go test with -bench and -benchmem)")
+	}
+	for _, m := range results {
+		fmt.Printf("%-28s %10d ns/op %10d B/op %8d allocs/op\n", m.name, m.nsPerOp, m.bytesPerOp, m.allocsPerOp)
+	}
+
+	if *update {
+		writeBudgets(*budgetsPath, results)
+		return
+	}
+
+	budgets := readBudgets(*budgetsPath)
+	if failures := checkBudgets(results, budgets, *tolerance); len(failures) > 0 {
+		fmt.Println("\nPerformance budget exceeded:")
+		for _, f := range failures {
+			fmt.Println("  " + f)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("\nAll benchmarks within budget")
+}
+
+// benchLine matches a result line of `go test -bench -benchmem`:
+// name[-GOMAXPROCS], iterations, then value/unit pairs
+var benchLine = regexp.MustCompile(`^Benchmark(\S+?)(?:-\d+)?\s+\d+\s+(.*)$`)
+
+// parseResults reads the benchmark results in r. A benchmark run more than
+// once (-count) keeps its last result.
+func parseResults(r io.Reader) ([]measurement, error) {
+	byName := map[string]int{}
+	var results []measurement
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := benchLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		m := measurement{name: budgetKey(match[1])}
+		fields := strings.Fields(match[2])
+		for i := 0; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				m.nsPerOp = int64(value)
+			case "B/op":
+				m.bytesPerOp = int64(value)
+			case "allocs/op":
+				m.allocsPerOp = int64(value)
+			}
+		}
+
+		if i, ok := byName[m.name]; ok {
+			results[i] = m
+			continue
+		}
+		byName[m.name] = len(results)
+		results = append(results, m)
+	}
+	return results, scanner.Err()
+}
+
+// budgetKey turns a benchmark name (Merge/text-heavy) into its budget key
+// (merge/text-heavy)
+func budgetKey(name string) string {
+	op, rest, found := strings.Cut(name, "/")
+	if !found {
+		return strings.ToLower(op)
+	}
+	return strings.ToLower(op) + "/" + rest
+}
+
+func checkBudgets(results []measurement, budgets map[string]Budget, tolerance float64) []string {
+	var failures []string
+	allowed := func(limit int64) int64 { return int64(float64(limit) * (1 + tolerance)) }
+
+	for _, m := range results {
+		b, ok := budgets[m.name]
+		if !ok {
+			fmt.Printf("warning: no budget for %s (run make bench-update to record one)\n", m.name)
+			continue
+		}
+		if b.MaxAllocsPerOp > 0 && m.allocsPerOp > allowed(b.MaxAllocsPerOp) {
+			failures = append(failures, fmt.Sprintf("%s: %d allocs/op > budget %d allocs/op", m.name, m.allocsPerOp, b.MaxAllocsPerOp))
+		}
+		if b.MaxBytesPerOp > 0 && m.bytesPerOp > allowed(b.MaxBytesPerOp) {
+			failures = append(failures, fmt.Sprintf("%s: %d B/op > budget %d B/op", m.name, m.bytesPerOp, b.MaxBytesPerOp))
+		}
+	}
+	return failures
+}
+
+func readBudgets(path string) map[string]Budget {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read budgets: %v", err)
+	}
+	budgets := map[string]Budget{}
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		log.Fatalf("Failed to parse budgets: %v", err)
+	}
+	return budgets
+}
+
+func writeBudgets(path string, results []measurement) {
+	budgets := map[string]Budget{}
+	for _, m := range results {
+		budgets[m.name] = Budget{
+			MaxAllocsPerOp: m.allocsPerOp,
+			MaxBytesPerOp:  m.bytesPerOp,
+		}
+	}
+
+	// Keep the file stable for review diffs
+	keys := make([]string, 0, len(budgets))
+	for k := range budgets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i, k := range keys {
+		entry, _ := json.Marshal(budgets[k])
+		fmt.Fprintf(&sb, "  %q: %s", k, entry)
+		if i < len(keys)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("}\n")
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		log.Fatalf("Failed to write budgets: %v", err)
+	}
+	fmt.Printf("\nWrote %d budgets to %s\n", len(budgets), path)
+}
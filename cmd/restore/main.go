@@ -0,0 +1,116 @@
+// Command restore checks a backup written by the admin backup job and loads
+// its database dump back into MongoDB.
+//
+// It reads the backup's manifest from BACKUP_BUCKET, checks every backup
+// file against its SHA-256 and, unless -skip-objects is given, that every
+// stored object the backup lists is still there and unchanged. It exits
+// non-zero without restoring anything when a check fails.
+//
+//	go run ./cmd/restore -backup <id> -verify-only
+//	go run ./cmd/restore -backup <id> -replace
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/services"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+)
+
+// listed is how many problems of each kind are printed
+const listed = 20
+
+func main() {
+	backupID := flag.String("backup", "", "ID of the backup to restore")
+	verifyOnly := flag.Bool("verify-only", false, "check the backup without restoring it")
+	skipObjects := flag.Bool("skip-objects", false, "don't check the stored objects the backup lists")
+	replace := flag.Bool("replace", false, "delete the documents of collections being restored first")
+	force := flag.Bool("force", false, "restore even when stored objects are missing or changed")
+	flag.Parse()
+
+	if *backupID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	ctx := context.Background()
+
+	mongoClient, err := mongodb.NewClient(cfg.MongoDBURI, cfg.MongoDBDatabase)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Close(ctx)
+
+	minioClient, err := minioPkg.NewClient(
+		cfg.MinIOEndpoint,
+		cfg.MinIOAccessKey,
+		cfg.MinIOSecretKey,
+		cfg.MinIOUseSSL,
+		cfg.MinIOBucketTemp,
+		cfg.MinIOBucketUserFiles,
+	)
+	if err != nil {
+		log.Fatalf("Failed to connect to MinIO: %v", err)
+	}
+
+	backupService, err := services.NewBackupService(mongoClient, minioClient, nil, cfg.BackupBucket, nil)
+	if err != nil {
+		log.Fatalf("Failed to open backup bucket: %v", err)
+	}
+
+	manifest, err := backupService.Manifest(ctx, *backupID)
+	if errors.Is(err, services.ErrBackupNotFound) {
+		log.Fatalf("No backup %s in bucket %s", *backupID, cfg.BackupBucket)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+	log.Printf("Backup %s of %s taken %s (snapshot: %t, maintenance: %t)",
+		manifest.ID, manifest.Database, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), manifest.Snapshot, manifest.Maintenance)
+
+	v, err := backupService.Verify(ctx, manifest, !*skipObjects)
+	if err != nil {
+		log.Fatalf("Failed to verify backup: %v", err)
+	}
+	log.Printf("Checked %d collection dumps and %d stored objects", v.Collections, v.Objects)
+	report("Corrupt backup file", v.Corrupt)
+	report("Missing object", v.Missing)
+	report("Changed object", v.Changed)
+
+	if len(v.Corrupt) > 0 {
+		log.Fatalf("Backup is corrupt; not restoring")
+	}
+	if !v.OK() && !*force {
+		log.Fatalf("Stored objects don't match the backup; rerun with -force to restore anyway")
+	}
+	if *verifyOnly {
+		log.Printf("Backup verified")
+		return
+	}
+
+	if err := backupService.Restore(ctx, manifest, *replace); err != nil {
+		if errors.Is(err, services.ErrBackupNotEmpty) {
+			log.Fatalf("%v; rerun with -replace to overwrite them", err)
+		}
+		log.Fatalf("Restore failed: %v", err)
+	}
+	log.Printf("Restored %d collections from backup %s", len(manifest.Collections), manifest.ID)
+}
+
+// report logs up to listed problems of one kind
+func report(kind string, keys []string) {
+	for i, key := range keys {
+		if i == listed {
+			log.Printf("... and %d more", len(keys)-listed)
+			return
+		}
+		log.Printf("%s: %s", kind, key)
+	}
+}
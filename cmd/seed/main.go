@@ -0,0 +1,372 @@
+// Command seed fills a development MongoDB and MinIO with demo data: users
+// on the free, pro and business plans, library files, a temporary upload,
+// share links, notifications and operation history, so every endpoint has
+// something to work with.
+//
+// Demo users have Firebase UIDs starting with "seed-" and own everything
+// the command creates. Each run removes their data first, so it can be
+// rerun at any time; -clean only removes it. With -accounts the users are
+// also created in Firebase Auth (a dev project, or the emulator when
+// FIREBASE_AUTH_EMULATOR_HOST is set) with the password given by
+// -password, so you can sign in as them.
+//
+//	go run ./cmd/seed
+//	go run ./cmd/seed -accounts -password demo-password
+//	go run ./cmd/seed -clean
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/pdfgen"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/pkg/firebase"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+
+	"firebase.google.com/go/v4/auth"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// uidPrefix marks the demo users; everything they own is seed data
+const uidPrefix = "seed-"
+
+// demoUser is one seeded account and the library it starts with
+type demoUser struct {
+	UID   string
+	Email string
+	Name  string
+	Plan  string
+	Admin bool
+	Files []demoFile
+}
+
+// demoFile is a generated PDF; each string is one page of lines
+type demoFile struct {
+	Name  string
+	Tags  []string
+	Pages [][]string
+}
+
+var demoUsers = []demoUser{
+	{
+		UID: uidPrefix + "admin", Email: "admin@demo.example.com", Name: "Ada Admin", Plan: "business", Admin: true,
+		Files: []demoFile{
+			{Name: "Quarterly Report Q3.pdf", Tags: []string{"finance", "reports"}, Pages: reportPages(6)},
+			{Name: "Employee Handbook.pdf", Tags: []string{"hr"}, Pages: handbookPages()},
+		},
+	},
+	{
+		UID: uidPrefix + "pro", Email: "pro@demo.example.com", Name: "Priya Pro", Plan: "pro",
+		Files: []demoFile{
+			{Name: "Service Agreement.pdf", Tags: []string{"legal", "contracts"}, Pages: agreementPages()},
+			{Name: "Invoice 2041.pdf", Tags: []string{"finance"}, Pages: invoicePages(2041)},
+			{Name: "Invoice 2042.pdf", Tags: []string{"finance"}, Pages: invoicePages(2042)},
+		},
+	},
+	{
+		UID: uidPrefix + "free", Email: "free@demo.example.com", Name: "Felix Free", Plan: "free",
+		Files: []demoFile{
+			{Name: "Lecture Notes.pdf", Tags: []string{"study"}, Pages: reportPages(3)},
+		},
+	},
+}
+
+func main() {
+	clean := flag.Bool("clean", false, "only remove the seeded data")
+	accounts := flag.Bool("accounts", false, "also create the demo users in Firebase Auth")
+	password := flag.String("password", "demo-password", "password of the Firebase accounts created with -accounts")
+	force := flag.Bool("force", false, "run even when GIN_MODE is release")
+	flag.Parse()
+
+	cfg := config.Load()
+	if cfg.GinMode == "release" && !*force {
+		log.Fatalf("GIN_MODE is release; seed only targets development databases (rerun with -force to seed anyway)")
+	}
+	ctx := context.Background()
+
+	mongoClient, err := mongodb.NewClient(cfg.MongoDBURI, cfg.MongoDBDatabase)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Close(ctx)
+
+	minioClient, err := minioPkg.NewClient(
+		cfg.MinIOEndpoint,
+		cfg.MinIOAccessKey,
+		cfg.MinIOSecretKey,
+		cfg.MinIOUseSSL,
+		cfg.MinIOBucketTemp,
+		cfg.MinIOBucketUserFiles,
+	)
+	if err != nil {
+		log.Fatalf("Failed to connect to MinIO: %v", err)
+	}
+
+	pdfService, err := services.NewPDFService()
+	if err != nil {
+		log.Fatalf("Failed to create PDF service: %v", err)
+	}
+	userService := services.NewUserService(mongoClient)
+	storageRouter := services.NewStorageRouter(minioClient, services.NewOrgService(mongoClient), cfg.OrgStorageAllowPrivate)
+	storageService := services.NewStorageService(minioClient, storageRouter, mongoClient, pdfService, userService, cfg.TempFileTTLHours)
+
+	s := &seeder{mongo: mongoClient, users: userService, storage: storageService}
+	for _, u := range demoUsers {
+		if err := s.remove(ctx, u.UID); err != nil {
+			log.Fatalf("Failed to remove seed data of %s: %v", u.UID, err)
+		}
+	}
+	if *clean {
+		log.Printf("Removed the seed data of %d users", len(demoUsers))
+		return
+	}
+
+	for _, u := range demoUsers {
+		if err := s.seed(ctx, u); err != nil {
+			log.Fatalf("Failed to seed %s: %v", u.UID, err)
+		}
+	}
+
+	if *accounts {
+		fb, err := firebase.NewClient(cfg.FirebaseCredentialsFile)
+		if err != nil {
+			log.Fatalf("Failed to connect to Firebase: %v", err)
+		}
+		for _, u := range demoUsers {
+			if err := upsertAccount(ctx, fb.Auth(), u, *password); err != nil {
+				log.Fatalf("Failed to create the Firebase account of %s: %v", u.Email, err)
+			}
+		}
+		log.Printf("Sign in as any demo user with password %q", *password)
+	}
+
+	for _, u := range demoUsers {
+		log.Printf("%-24s %-8s uid %s", u.Email, u.Plan, u.UID)
+	}
+}
+
+type seeder struct {
+	mongo   *mongodb.Client
+	users   *services.UserService
+	storage *services.StorageService
+}
+
+// remove deletes a demo user and everything they own
+func (s *seeder) remove(ctx context.Context, uid string) error {
+	if !strings.HasPrefix(uid, uidPrefix) {
+		return fmt.Errorf("%s is not a seed user", uid)
+	}
+
+	cursor, err := s.mongo.Documents().Find(ctx, bson.M{"ownerUid": uid})
+	if err != nil {
+		return err
+	}
+	var docs []models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := s.storage.DeleteFile(ctx, doc.ID.Hex(), uid); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", doc.OriginalName, err)
+		}
+	}
+
+	if _, err := s.mongo.Collection("shares").DeleteMany(ctx, bson.M{"creatorId": uid}); err != nil {
+		return err
+	}
+	if _, err := s.mongo.Collection("operation_logs").DeleteMany(ctx, bson.M{"userId": uid}); err != nil {
+		return err
+	}
+	if user, err := s.users.GetUserByFirebaseUID(ctx, uid); err == nil {
+		if _, err := s.mongo.Collection("notifications").DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+			return err
+		}
+	}
+	_, err = s.mongo.Users().DeleteOne(ctx, bson.M{"firebaseUid": uid})
+	return err
+}
+
+// seed creates a demo user with their library, a temporary upload, share
+// links, notifications and operation history
+func (s *seeder) seed(ctx context.Context, u demoUser) error {
+	user, err := s.users.CreateOrUpdateUser(ctx, u.UID, u.Email, u.Name, "")
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePlan(ctx, user.ID.Hex(), u.Plan); err != nil {
+		return err
+	}
+	if u.Admin {
+		if _, err := s.mongo.Users().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"role": "admin"}}); err != nil {
+			return err
+		}
+	}
+
+	var library []*models.Document
+	for _, f := range u.Files {
+		b := &pdfgen.Builder{}
+		for _, lines := range f.Pages {
+			b.AddTextPage(lines...)
+		}
+		data := b.Bytes()
+		doc, err := s.storage.AddToLibrary(ctx, u.UID, f.Name, bytes.NewReader(data), len(f.Pages), services.ContentHash(data))
+		if err != nil {
+			return err
+		}
+		if err := s.storage.SetLibraryTags(ctx, doc, f.Tags, ""); err != nil {
+			return err
+		}
+		library = append(library, doc)
+	}
+
+	scan := pdfgen.Sample(2)
+	upload, err := s.storage.UploadFile(ctx, u.UID, "Scan from phone.pdf", "application/pdf", bytes.NewReader(scan), int64(len(scan)), true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.seedShares(ctx, u, library); err != nil {
+		return err
+	}
+	if err := s.seedNotifications(ctx, user, u); err != nil {
+		return err
+	}
+	if err := s.seedOperations(ctx, u, library, upload); err != nil {
+		return err
+	}
+	log.Printf("Seeded %s: %d library files, 1 temporary upload", u.Email, len(library))
+	return nil
+}
+
+// seedShares shares the first library file and adds an expired link to the
+// last one
+func (s *seeder) seedShares(ctx context.Context, u demoUser, library []*models.Document) error {
+	if len(library) == 0 {
+		return nil
+	}
+	now := time.Now()
+	name := strings.TrimPrefix(u.UID, uidPrefix)
+	first, last := library[0], library[len(library)-1]
+	shares := []interface{}{
+		models.Share{
+			Code: "demo-" + name, FileID: first.ID.Hex(), CreatorID: u.UID, FileType: "library", Filename: first.OriginalName,
+			Stats:     models.ShareStats{Views: 12, Downloads: 3, LastAccess: now.Add(-2 * time.Hour)},
+			ExpiresAt: now.Add(7 * 24 * time.Hour), CreatedAt: now.Add(-24 * time.Hour),
+		},
+		models.Share{
+			Code: "demo-" + name + "-expired", FileID: last.ID.Hex(), CreatorID: u.UID, FileType: "library", Filename: last.OriginalName,
+			Stats:     models.ShareStats{Views: 1, LastAccess: now.Add(-9 * 24 * time.Hour)},
+			ExpiresAt: now.Add(-24 * time.Hour), CreatedAt: now.Add(-10 * 24 * time.Hour),
+		},
+	}
+	_, err := s.mongo.Collection("shares").InsertMany(ctx, shares)
+	return err
+}
+
+// seedNotifications adds a read welcome and unread notices
+func (s *seeder) seedNotifications(ctx context.Context, user *models.User, u demoUser) error {
+	now := time.Now()
+	notifications := []interface{}{
+		models.Notification{UserID: user.ID, Title: "Welcome to BinaryPDF", Message: "Your demo library is ready.", Type: models.NotificationTypeInfo, Read: true, CreatedAt: now.Add(-72 * time.Hour)},
+		models.Notification{UserID: user.ID, Title: "Link viewed", Message: "Someone opened your shared link.", Type: models.NotificationTypeSuccess, Link: "/share/demo-" + strings.TrimPrefix(u.UID, uidPrefix), CreatedAt: now.Add(-2 * time.Hour)},
+		models.Notification{UserID: user.ID, Title: "Temporary file expiring", Message: "Scan from phone.pdf will be deleted soon unless you save it to your library.", Type: models.NotificationTypeWarning, CreatedAt: now.Add(-10 * time.Minute)},
+	}
+	_, err := s.mongo.Collection("notifications").InsertMany(ctx, notifications)
+	return err
+}
+
+// seedOperations records a few days of history, including a failure
+func (s *seeder) seedOperations(ctx context.Context, u demoUser, library []*models.Document, upload *services.UploadResult) error {
+	if len(library) == 0 {
+		return nil
+	}
+	now := time.Now()
+	output := func(doc *models.Document) models.OperationOutput {
+		return models.OperationOutput{FileID: doc.ID.Hex(), Filename: doc.OriginalName, PageCount: doc.Metadata.PageCount, Size: doc.Size}
+	}
+	first := library[0]
+	logs := []interface{}{
+		models.OperationLog{
+			UserID: u.UID, Operation: "merge", InputFiles: []string{"Part 1.pdf", "Part 2.pdf"}, OutputFileID: first.ID.Hex(),
+			Outputs: []models.OperationOutput{output(first)}, PageCount: first.Metadata.PageCount, Status: "success",
+			ProcessingMs: 420, RequestID: "seed", CreatedAt: now.Add(-50 * time.Hour),
+		},
+		models.OperationLog{
+			UserID: u.UID, Operation: "compress", InputFiles: []string{first.OriginalName}, OutputFileID: first.ID.Hex(),
+			Outputs: []models.OperationOutput{output(first)}, PageCount: first.Metadata.PageCount, Status: "success",
+			ProcessingMs: 1310, RequestID: "seed", CreatedAt: now.Add(-26 * time.Hour),
+		},
+		models.OperationLog{
+			UserID: u.UID, Operation: "watermark", InputFiles: []string{"Scan from phone.pdf"}, Status: "error",
+			ErrorMessage: "Invalid PDF file: xref table not found", ProcessingMs: 35, RequestID: "seed", CreatedAt: now.Add(-3 * time.Hour),
+		},
+		models.OperationLog{
+			UserID: u.UID, Operation: "rotate", InputFiles: []string{"Scan from phone.pdf"}, OutputFileID: upload.FileID,
+			Outputs: []models.OperationOutput{{FileID: upload.FileID, Filename: "Scan from phone.pdf", PageCount: 2, Size: upload.Size}}, PageCount: 2,
+			Status: "success", ProcessingMs: 180, RequestID: "seed", CreatedAt: now.Add(-15 * time.Minute),
+		},
+	}
+	_, err := s.mongo.Collection("operation_logs").InsertMany(ctx, logs)
+	return err
+}
+
+// upsertAccount creates or resets u's Firebase account
+func upsertAccount(ctx context.Context, client *auth.Client, u demoUser, password string) error {
+	if _, err := client.GetUser(ctx, u.UID); err == nil {
+		_, err = client.UpdateUser(ctx, u.UID, (&auth.UserToUpdate{}).Email(u.Email).DisplayName(u.Name).Password(password).EmailVerified(true))
+		return err
+	} else if !auth.IsUserNotFound(err) {
+		return err
+	}
+	_, err := client.CreateUser(ctx, (&auth.UserToCreate{}).UID(u.UID).Email(u.Email).DisplayName(u.Name).Password(password).EmailVerified(true))
+	return err
+}
+
+func reportPages(n int) [][]string {
+	pages := make([][]string, n)
+	for i := range pages {
+		pages[i] = []string{
+			fmt.Sprintf("Section %d", i+1),
+			"Revenue grew 12% quarter over quarter, led by subscriptions.",
+			"Operating costs held flat as infrastructure spend was reduced.",
+			"Next steps: expand the team and launch the mobile app.",
+		}
+	}
+	return pages
+}
+
+func handbookPages() [][]string {
+	return [][]string{
+		{"Employee Handbook", "Welcome to the team."},
+		{"1. Working hours", "Core hours are 10:00 to 16:00 in your time zone."},
+		{"2. Leave", "Request leave at least two weeks ahead."},
+		{"3. Contacts", "HR: hr@demo.example.com, +1 (555) 010-2030"},
+	}
+}
+
+func agreementPages() [][]string {
+	return [][]string{
+		{"SERVICE AGREEMENT", "Between Demo Corp and Jane Doe (jane.doe@example.com).", "Effective date: January 1, 2025. Term: 12 months."},
+		{"1. Payment", "Fees are due within 30 days of invoice.", "Card on file: 4111 1111 1111 1111."},
+		{"2. Termination", "Either party may terminate with 30 days written notice.", "This agreement renews automatically unless cancelled."},
+		{"3. Liability", "Liability is limited to the fees paid in the prior 12 months.", "SSN for tax purposes: 123-45-6789"},
+	}
+}
+
+func invoicePages(number int) [][]string {
+	return [][]string{{
+		fmt.Sprintf("INVOICE #%d", number),
+		"Bill to: Demo Corp, 1 Example Street",
+		"Consulting, 10 hours ........ $1,500.00",
+		"Total due ................... $1,500.00",
+	}}
+}
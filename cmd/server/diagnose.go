@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/pkg/firebase"
+	minioPkg "brainy-pdf/pkg/minio"
+	"firebase.google.com/go/v4/auth"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Exit codes of the diagnose mode
+const (
+	diagnoseOK     = 0 // every check passed or was skipped
+	diagnoseFailed = 1 // a check failed, or was skipped with -strict
+	diagnoseUsage  = 2
+)
+
+// errSkipped marks a check for a service that is not configured
+var errSkipped = errors.New("skipped")
+
+// diagnosis is one dependency check
+type diagnosis struct {
+	name  string
+	check func(ctx context.Context) (string, error)
+}
+
+// diagnose checks that every dependency the server talks to is reachable
+// with the configured credentials, printing secrets masked, and returns
+// the process exit code. Nothing is created: buckets are probed with a
+// temporary object, Razorpay and OpenRouter keys with read-only calls.
+//
+//	server diagnose [-only mongo,minio] [-strict] [-timeout 15s]
+func diagnose(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	only := fs.String("only", "", "comma-separated checks to run: mongo, minio, redis, firebase, razorpay, openrouter, libreoffice")
+	strict := fs.Bool("strict", false, "fail checks of services that are not configured instead of skipping them")
+	timeout := fs.Duration("timeout", 15*time.Second, "time allowed for each check")
+	if err := fs.Parse(args); err != nil {
+		return diagnoseUsage
+	}
+
+	checks := []diagnosis{
+		{"mongo", func(ctx context.Context) (string, error) { return checkMongo(ctx, cfg) }},
+		{"minio", func(ctx context.Context) (string, error) { return checkMinIO(ctx, cfg) }},
+		{"redis", func(ctx context.Context) (string, error) { return checkRedis(ctx, cfg) }},
+		{"firebase", func(ctx context.Context) (string, error) { return checkFirebase(ctx, cfg) }},
+		{"razorpay", func(ctx context.Context) (string, error) { return checkRazorpay(cfg) }},
+		{"openrouter", func(ctx context.Context) (string, error) { return checkOpenRouter(ctx, cfg) }},
+		{"libreoffice", func(ctx context.Context) (string, error) { return checkLibreOffice(ctx, cfg) }},
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+	for name := range selected {
+		known := false
+		for _, c := range checks {
+			known = known || c.name == name
+		}
+		if !known {
+			fmt.Fprintf(os.Stderr, "diagnose: unknown check %q\n", name)
+			return diagnoseUsage
+		}
+	}
+
+	// Clients log their own connection messages; the report says it all
+	log.SetOutput(io.Discard)
+
+	fmt.Println("Configuration")
+	for _, setting := range [][2]string{
+		{"MONGODB_URI", maskURI(cfg.MongoDBURI)},
+		{"MONGODB_DATABASE", cfg.MongoDBDatabase},
+		{"MINIO_ENDPOINT", cfg.MinIOEndpoint},
+		{"MINIO_ACCESS_KEY", mask(cfg.MinIOAccessKey)},
+		{"MINIO_SECRET_KEY", mask(cfg.MinIOSecretKey)},
+		{"QUEUE_BACKEND", cfg.QueueBackend},
+		{"REDIS_URL", maskURI(cfg.RedisURL)},
+		{"FIREBASE_CREDENTIALS_FILE", cfg.FirebaseCredentialsFile},
+		{"RAZORPAY_KEY_ID", mask(cfg.RazorpayKeyID)},
+		{"RAZORPAY_KEY_SECRET", mask(cfg.RazorpayKeySecret)},
+		{"OPENROUTER_API_KEY", mask(cfg.OpenRouterAPIKey)},
+	} {
+		fmt.Printf("  %-26s %s\n", setting[0], setting[1])
+	}
+
+	fmt.Println("Checks")
+	code := diagnoseOK
+	for _, c := range checks {
+		if len(selected) > 0 && !selected[c.name] {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		start := time.Now()
+		detail, err := c.check(ctx)
+		cancel()
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		switch {
+		case errors.Is(err, errSkipped) && !*strict:
+			fmt.Printf("  ⏭️  %-12s %s\n", c.name, detail)
+		case err != nil:
+			if errors.Is(err, errSkipped) {
+				err = errors.New(detail)
+			}
+			fmt.Printf("  ❌ %-12s %v (%s)\n", c.name, err, elapsed)
+			code = diagnoseFailed
+		default:
+			fmt.Printf("  ✅ %-12s %s (%s)\n", c.name, detail, elapsed)
+		}
+	}
+	return code
+}
+
+func checkMongo(ctx context.Context, cfg *config.Config) (string, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoDBURI))
+	if err != nil {
+		return "", err
+	}
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return "", err
+	}
+	return "primary reachable, database " + cfg.MongoDBDatabase, nil
+}
+
+// checkMinIO writes, reads and deletes a probe object in both buckets.
+// Unlike the server it does not create missing buckets.
+func checkMinIO(ctx context.Context, cfg *config.Config) (string, error) {
+	for _, bucket := range []string{cfg.MinIOBucketTemp, cfg.MinIOBucketUserFiles} {
+		client, err := minioPkg.NewBucketClient(cfg.MinIOEndpoint, "", cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOUseSSL, bucket, nil)
+		if err != nil {
+			return "", err
+		}
+		if err := client.CheckAccess(ctx); err != nil {
+			return "", fmt.Errorf("%s: %w", bucket, err)
+		}
+	}
+	return fmt.Sprintf("buckets %s and %s are writable", cfg.MinIOBucketTemp, cfg.MinIOBucketUserFiles), nil
+}
+
+// checkRedis pings the Redis the job queue is kept in
+func checkRedis(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.QueueBackend != "redis" {
+		return "not used by QUEUE_BACKEND=" + cfg.QueueBackend, errSkipped
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	info, err := client.Info(ctx, "server").Result()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			return "Redis " + v + " reachable", nil
+		}
+	}
+	return "reachable", nil
+}
+
+// checkFirebase looks up a user that doesn't exist: "not found" proves the
+// credentials were accepted
+func checkFirebase(ctx context.Context, cfg *config.Config) (string, error) {
+	if _, err := os.Stat(cfg.FirebaseCredentialsFile); err != nil {
+		return cfg.FirebaseCredentialsFile + " not found; sign-in is disabled", errSkipped
+	}
+	client, err := firebase.NewClient(cfg.FirebaseCredentialsFile)
+	if err != nil {
+		return "", err
+	}
+	_, err = client.Auth().GetUser(ctx, "brainy-pdf-diagnose")
+	if err != nil && !auth.IsUserNotFound(err) {
+		return "", err
+	}
+	return "credentials accepted", nil
+}
+
+func checkRazorpay(cfg *config.Config) (string, error) {
+	if cfg.RazorpayKeyID == "" || cfg.RazorpayKeySecret == "" {
+		return "RAZORPAY_KEY_ID or RAZORPAY_KEY_SECRET not set; payments are disabled", errSkipped
+	}
+	if err := services.NewRazorpayGateway(cfg.RazorpayKeyID, cfg.RazorpayKeySecret).CheckCredentials(); err != nil {
+		return "", err
+	}
+	mode := "live"
+	if strings.HasPrefix(cfg.RazorpayKeyID, "rzp_test_") {
+		mode = "test"
+	}
+	return "credentials accepted (" + mode + " mode)", nil
+}
+
+func checkOpenRouter(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.OpenRouterAPIKey == "" {
+		return "OPENROUTER_API_KEY not set; AI features are disabled", errSkipped
+	}
+	aiService, err := services.NewAIService(ctx, cfg.OpenRouterAPIKey)
+	if err != nil {
+		return "", err
+	}
+	if err := aiService.CheckKey(ctx); err != nil {
+		return "", err
+	}
+	return "API key accepted", nil
+}
+
+// checkLibreOffice runs soffice --version. It is only required where
+// conversion workers run.
+func checkLibreOffice(ctx context.Context, cfg *config.Config) (string, error) {
+	path := services.SofficePath()
+	if path == "" {
+		if cfg.ConversionWorkers == 0 || !cfg.APIRunWorkers {
+			return "not installed; conversions run elsewhere", errSkipped
+		}
+		return "", errors.New("LibreOffice (soffice) is not installed")
+	}
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// mask shows the first and last two characters of a secret
+func mask(s string) string {
+	if s == "" {
+		return "(not set)"
+	}
+	if len(s) < 8 {
+		return "****"
+	}
+	return s[:2] + "****" + s[len(s)-2:]
+}
+
+// maskURI hides the password in a connection string
+func maskURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "(unparseable)"
+	}
+	return u.Redacted()
+}
@@ -3,15 +3,16 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/handlers"
+	"brainy-pdf/internal/lifecycle"
 	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/renderer"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/pkg/firebase"
 	minioPkg "brainy-pdf/pkg/minio"
@@ -22,10 +23,21 @@ import (
 func main() {
 	// Load configuration
 	cfg := config.Load()
+
+	// "server diagnose" checks connectivity to every dependency and exits
+	if len(os.Args) > 1 && os.Args[1] == "diagnose" {
+		os.Exit(diagnose(cfg, os.Args[2:]))
+	}
 	
 	log.Printf("🚀 Starting Server...")
 	log.Printf("DEBUG: Loaded CORS Allowed Origins: %v", cfg.CORSAllowedOrigins)
 
+	removePIDFile, err := lifecycle.WritePIDFile(cfg.PIDFile)
+	if err != nil {
+		log.Fatalf("Failed to write PID file: %v", err)
+	}
+	defer removePIDFile()
+
 
 	// Set Gin mode
 	gin.SetMode(cfg.GinMode)
@@ -50,6 +62,32 @@ func main() {
 		log.Fatalf("Failed to connect to MinIO: %v", err)
 	}
 
+	// Encrypt user files at rest when a master key is configured
+	var encryptionService *services.EncryptionService
+	if cfg.EncryptionMasterKey != "" {
+		encryptionService, err = services.NewEncryptionService(mongoClient, minioClient, cfg.EncryptionMasterKey, cfg.EncryptionPreviousMasterKeys, cfg.EncryptionAllowPlaintext)
+		if err != nil {
+			log.Fatalf("Failed to set up encryption: %v", err)
+		}
+		minioClient.SetEncrypter(encryptionService)
+	}
+
+	// Regional read replicas of user files for presigned downloads
+	replicaRegions := make(map[string]bool)
+	if len(cfg.StorageReplicas) > 0 {
+		replicaClients := make(map[string]*minioPkg.Client)
+		for _, r := range cfg.StorageReplicas {
+			replica, err := minioPkg.NewBucketClient(r.Endpoint, r.AWSRegion, r.AccessKey, r.SecretKey, r.UseSSL, r.Bucket, nil)
+			if err != nil {
+				log.Printf("Warning: Storage replica %s not available: %v", r.Region, err)
+				continue
+			}
+			replicaClients[r.Region] = replica
+			replicaRegions[r.Region] = true
+		}
+		minioClient.SetReplicas(replicaClients)
+	}
+
 	// Initialize Firebase
 	firebaseClient, err := firebase.NewClient(cfg.FirebaseCredentialsFile)
 	if err != nil {
@@ -66,50 +104,248 @@ func main() {
 	if err != nil {
 		log.Printf("Warning: Failed to initialize AI service: %v", err)
 	}
+	transcriptionService := services.NewTranscriptionService(cfg.TranscriptionAPIURL, cfg.TranscriptionAPIKey, cfg.TranscriptionModel)
+	speechService := services.NewSpeechService(cfg.TTSAPIURL, cfg.TTSAPIKey, cfg.TTSModel, cfg.TTSVoice)
+	thumbnailService := services.NewThumbnailService(pdfService, renderer.New(cfg.PdftoppmPath))
+	logForwarder, err := services.NewLogForwarder(services.LogForwarderConfig{
+		Transport:      cfg.SIEMTransport,
+		Endpoint:       cfg.SIEMEndpoint,
+		SyslogProtocol: cfg.SIEMSyslogProtocol,
+		AuthHeader:     cfg.SIEMAuthHeader,
+		BatchSize:      cfg.SIEMBatchSize,
+		FlushInterval:  time.Duration(cfg.SIEMFlushIntervalSeconds) * time.Second,
+		BufferSize:     cfg.SIEMBufferSize,
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure SIEM log forwarding: %v", err)
+	}
+	auditService := services.NewAuditService(mongoClient, logForwarder)
+	orgService := services.NewOrgService(mongoClient)
+	legalHoldService := services.NewLegalHoldService(mongoClient)
+	activityService := services.NewActivityService(mongoClient)
+	maintenanceService := services.NewMaintenanceService(context.Background(), mongoClient)
+	storageMigrationService := services.NewStorageMigrationService(mongoClient, minioClient, maintenanceService)
 	notificationService := services.NewNotificationService(mongoClient) // Correct signature
 	userService := services.NewUserService(mongoClient)
-	conversionService, err := services.NewConversionService(4) // Correct signature
+	jobQueue, err := services.NewJobQueue(cfg.QueueBackend, mongoClient, cfg.RedisURL, time.Duration(cfg.QueueVisibilityTimeoutSeconds)*time.Second, time.Duration(cfg.QueueMaxWaitSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to create job queue: %v", err)
+	}
+	defer jobQueue.Close()
+	conversionWorkers := cfg.ConversionWorkers
+	if !cfg.APIRunWorkers {
+		conversionWorkers = 0 // jobs are processed by cmd/worker
+	}
+	conversionService, err := services.NewConversionService(conversionWorkers, mongoClient, minioClient, jobQueue)
 	if err != nil {
 		log.Printf("Warning: Conversion service not available: %v", err)
 	}
+	summaryWorkers := cfg.SummaryWorkers
+	if !cfg.APIRunWorkers {
+		summaryWorkers = 0
+	}
+	summaryService, err := services.NewSummaryService(summaryWorkers, aiService, mongoClient, minioClient, jobQueue)
+	if err != nil {
+		log.Printf("Warning: Summary service not available: %v", err)
+	}
+
+	// Optional services report their state here so handlers can refuse
+	// requests with SERVICE_DISABLED instead of failing on a nil dependency
+	capabilities := services.NewCapabilityRegistry()
+	if firebaseClient != nil {
+		capabilities.Set(services.CapabilityAuth, true, "")
+	} else {
+		capabilities.Set(services.CapabilityAuth, false, "Firebase credentials are not configured")
+	}
+	capabilities.Register(services.CapabilityConversion, conversionService.Capability)
+	capabilities.Register(services.CapabilityAI, aiService.Capability)
+	capabilities.Register(services.CapabilityOCR, aiService.OCRCapability)
+	capabilities.Register(services.CapabilityTranscription, transcriptionService.Capability)
+	capabilities.Register(services.CapabilitySpeech, speechService.Capability)
+	capabilities.Register(services.CapabilityThumbnails, thumbnailService.Capability)
+	capabilities.SetDisabled(cfg.DisabledFeatures)
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(userService, firebaseClient) // Assuming firebaseClient is authClient
-	storageService := services.NewStorageService(minioClient, mongoClient, pdfService, userService, cfg.TempFileTTLHours)
-	corePDFHandler := handlers.NewCorePDFHandler(pdfService, storageService, userService, mongoClient) // Original corePDFHandler
-	aiHandler := handlers.NewAIHandler(aiService, pdfService, storageService) // Original aiHandler
-	shareHandler := handlers.NewShareHandler(minioClient, mongoClient.MongoClient(), cfg.MongoDBDatabase, cfg.ServerHost, notificationService, conversionService)
-	conversionHandler := handlers.NewConversionHandler(conversionService) // Original conversionHandler
-	paymentHandler := handlers.NewPaymentHandler(cfg, userService, notificationService)
+	authHandler := handlers.NewAuthHandler(userService, firebaseClient, capabilities, activityService) // Assuming firebaseClient is authClient
+	storageRouter := services.NewStorageRouter(minioClient, orgService, cfg.OrgStorageAllowPrivate)
+	if encryptionService != nil {
+		encryptionService.SetStorageRouter(storageRouter)
+	}
+	storageService := services.NewStorageService(minioClient, storageRouter, mongoClient, pdfService, userService, cfg.TempFileTTLHours)
+	signatureService := services.NewSignatureService(mongoClient, minioClient)
+
+	// Scan stored files for malware before they can be shared publicly
+	virusScanTimeout := time.Duration(cfg.VirusScanTimeoutSeconds) * time.Second
+	virusScanner, err := services.NewVirusScanner(cfg.VirusScanner, cfg.ClamAVAddress, virusScanTimeout)
+	if err != nil {
+		log.Fatalf("Failed to configure virus scanning: %v", err)
+	}
+	virusScanService := services.NewVirusScanService(mongoClient, storageRouter, virusScanner, virusScanTimeout)
+	if virusScanService.Enabled() {
+		storageService.AddUploadHook(virusScanService)
+	}
+
+	// Index the people, companies, invoice numbers and dates in library PDFs
+	var entityIndexService *services.EntityIndexService
+	if cfg.EntityIndexEnabled {
+		var entityAI *services.AIService
+		if cfg.EntityIndexAI && aiService.Capability().Available {
+			entityAI = aiService
+		}
+		entityIndexService = services.NewEntityIndexService(mongoClient, storageService, pdfService, entityAI, orgService)
+		storageService.AddUploadHook(entityIndexService)
+		storageService.AddDeleteHook(entityIndexService)
+	}
+
+	// File uploads into folders by the owner's rules
+	folderRuleService := services.NewFolderRuleService(mongoClient, storageService, pdfService)
+	storageService.AddUploadHook(folderRuleService)
+
+	// Notify users of new documents matching their saved searches
+	savedSearchService := services.NewSavedSearchService(mongoClient, storageService, pdfService, notificationService)
+	storageService.AddUploadHook(savedSearchService)
+
+	// Copy user files to the regional read replicas
+	var replicationService *services.ReplicationService
+	if len(minioClient.Replicas()) > 0 {
+		replicationService = services.NewReplicationService(minioClient)
+		storageService.AddUploadHook(replicationService)
+		storageService.AddDeleteHook(replicationService)
+	}
+
+	// Move library files left unopened for months to cold storage
+	var archiveService *services.ArchiveService
+	if cfg.ArchiveAfterMonths > 0 {
+		archiveService, err = services.NewArchiveService(mongoClient, minioClient, notificationService, cfg.ArchiveAfterMonths, cfg.ArchiveBucket, cfg.ArchiveStorageClass)
+		if err != nil {
+			log.Printf("Warning: Archiving not available: %v", err)
+		}
+	}
+
+	// Disaster-recovery backups of the database and stored objects, started by admins
+	backupBuckets := []string{cfg.MinIOBucketUserFiles}
+	if cfg.ArchiveBucket != "" {
+		backupBuckets = append(backupBuckets, cfg.ArchiveBucket)
+	}
+	backupService, err := services.NewBackupService(mongoClient, minioClient, maintenanceService, cfg.BackupBucket, backupBuckets)
+	if err != nil {
+		log.Printf("Warning: Backups not available: %v", err)
+	}
+	progressService := services.NewProgressService(mongoClient)
+	corePDFHandler := handlers.NewCorePDFHandler(pdfService, storageService, userService, mongoClient, signatureService, aiService, capabilities, logForwarder, progressService, thumbnailService) // Original corePDFHandler
+	// Async /api/pdf jobs are run through the handler's routes, so they need
+	// the API's services and always run here rather than in cmd/worker
+	jobService, err := services.NewJobService(cfg.PDFJobWorkers, mongoClient, minioClient, jobQueue, progressService)
+	if err != nil {
+		log.Printf("Warning: PDF job service not available: %v", err)
+	} else {
+		corePDFHandler.SetJobService(jobService)
+		jobService.Start(corePDFHandler.RunJob)
+	}
+	jobHandler := handlers.NewJobHandler(jobService)
+	// Users' default compression, watermark, page number and share settings
+	preferencesService := services.NewPreferencesService(mongoClient)
+	corePDFHandler.SetPreferencesService(preferencesService)
+	preferencesHandler := handlers.NewPreferencesHandler(preferencesService)
+	aiHandler := handlers.NewAIHandler(aiService, pdfService, storageService, summaryService, speechService, userService, orgService, capabilities, services.NewRedactionService(pdfService, aiService)) // Original aiHandler
+	shareHandler := handlers.NewShareHandler(minioClient, mongoClient.MongoClient(), cfg.MongoDBDatabase, cfg.ServerHost, notificationService, conversionService, pdfService, cfg.ShareBlockUnsafePDFs, cfg.SharePIIPolicy, auditService, orgService, storageRouter, storageService, cfg.ShareCodeAlphabet, cfg.ShareCodeLength, virusScanService)
+	shareHandler.SetPreferencesService(preferencesService)
+	brandingService := services.NewBrandingService(orgService, userService, minioClient, cfg.ShareDomainTarget, cfg.ShareDomainHTTPS)
+	shareHandler.SetBrandingService(brandingService)
+	shareHandler.SetThumbnailService(thumbnailService)
+	shareHandler.SetShareCounter(services.NewShareCounter(mongoClient, time.Duration(cfg.ShareCountWindowMinutes)*time.Minute))
+	// Public abuse reports on share links, reviewed by admins
+	abuseService := services.NewAbuseService(mongoClient, notificationService)
+	shareHandler.SetAbuseService(abuseService)
+	abuseHandler := handlers.NewAbuseHandler(abuseService)
+	conversionHandler := handlers.NewConversionHandler(conversionService, userService, capabilities) // Original conversionHandler
+	razorpayGateway := services.NewRazorpayGateway(cfg.RazorpayKeyID, cfg.RazorpayKeySecret)
+	paymentHandler := handlers.NewPaymentHandler(cfg, razorpayGateway, userService, notificationService)
+	subscriptionService := services.NewSubscriptionService(mongoClient, razorpayGateway, userService, notificationService)
+	paymentHandler.SetSubscriptionService(subscriptionService)
 	
 	// Original handlers that were not explicitly in the provided snippet but are needed
 	pdfHandler := handlers.NewPDFHandler(pdfService, storageService, userService)
-	storageHandler := handlers.NewStorageHandler(storageService)
-	libraryHandler := handlers.NewLibraryHandler(minioClient, mongoClient, pdfService, userService)
+	storageHandler := handlers.NewStorageHandler(storageService, userService, services.NewURLImporter(), thumbnailService, capabilities)
+	libraryHandler := handlers.NewLibraryHandler(minioClient, mongoClient, pdfService, userService, transcriptionService, capabilities, storageService, entityIndexService, folderRuleService, savedSearchService, archiveService)
 	notificationHandler := handlers.NewNotificationHandler(notificationService, userService)
-	adminHandler := handlers.NewAdminHandler(mongoClient, userService)
+	adminHandler := handlers.NewAdminHandler(mongoClient, userService, auditService, encryptionService, legalHoldService, maintenanceService, storageMigrationService, backupService, abuseService)
+	limitsHandler := handlers.NewLimitsHandler(userService)
+	estimateService := services.NewEstimateService(mongoClient)
+	if conversionService != nil {
+		estimateService.AddConversionQueue(conversionService, cfg.ConversionWorkers)
+	}
+	if summaryService != nil {
+		estimateService.AddSummaryQueue(summaryService, cfg.SummaryWorkers)
+	}
+	estimateHandler := handlers.NewEstimateHandler(estimateService)
+	toolsHandler := handlers.NewToolsHandler(userService, capabilities)
+	workspaceService := services.NewWorkspaceService(mongoClient, minioClient, pdfService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService, pdfService, storageService, userService)
+	signatureHandler := handlers.NewSignatureHandler(signatureService)
+	orgHandler := handlers.NewOrgHandler(orgService, userService, auditService, storageRouter, legalHoldService)
+	orgHandler.SetBrandingService(brandingService)
 
 
+	// Resource watchdog: refuses heavy jobs and clears artifacts under pressure.
+	// Queued jobs also wait while maintenance mode is on.
+	resourceMonitor := services.NewResourceMonitor(cfg.MaxTempDiskMB, cfg.MaxRSSMB, services.DefaultTempDirs()...)
+	pauseJobs := func() bool {
+		overloaded, _ := resourceMonitor.Overloaded()
+		return overloaded || maintenanceService.Active()
+	}
+	tempJobQuota := int64(cfg.TempJobQuotaMB) * 1024 * 1024
+	pdfService.ScratchSpace().SetQuota(tempJobQuota)
+	resourceMonitor.AddScratch(pdfService.ScratchSpace())
+	if conversionService != nil {
+		conversionService.SetTempQuota(tempJobQuota)
+		resourceMonitor.AddScratch(conversionService.ScratchSpace())
+		resourceMonitor.RegisterCleaner(conversionService.CleanupFinished)
+		conversionService.SetPauseCheck(pauseJobs)
+		conversionService.SetReservedWorkers(cfg.QueueReservedWorkers)
+	}
+	if summaryService != nil {
+		resourceMonitor.RegisterCleaner(summaryService.CleanupFinished)
+		summaryService.SetPauseCheck(pauseJobs)
+		summaryService.SetReservedWorkers(cfg.QueueReservedWorkers)
+	}
+	if jobService != nil {
+		resourceMonitor.RegisterCleaner(jobService.CleanupFinished)
+		jobService.SetPauseCheck(pauseJobs)
+		jobService.SetReservedWorkers(cfg.QueueReservedWorkers)
+	}
+
 	// Create Gin router
-	router := gin.Default()
+	router := gin.New()
 
 	// Add middleware
-	router.Use(middleware.CORSMiddleware(cfg.CORSAllowedOrigins))
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(gin.LoggerWithFormatter(middleware.AccessLogFormatter), gin.Recovery())
+	cors := middleware.NewReloadableCORS(cfg.CORSAllowedOrigins)
+	router.Use(cors.Handler())
+	if len(replicaRegions) > 0 {
+		router.Use(middleware.StorageRegionMiddleware(cfg.StorageRegionHeader, replicaRegions, cfg.StorageReplicaCountries))
+	}
+	router.Use(middleware.BackpressureMiddleware(resourceMonitor, "/api/pdf", "/api/v1/pdf", "/api/v1/ai", "/api/v1/convert"))
+	// Admins can still sign in and turn maintenance mode off
+	router.Use(middleware.MaintenanceMiddleware(maintenanceService, "/api/v1/admin", "/api/v1/auth/google"))
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"status":    "healthy",
-			"timestamp": time.Now().UTC(),
-			"version":   "2.0.0",
-			"features":  []string{"merge", "split", "organize", "ai-features", "ocr", "library", "convert", "admin"},
+			"status":       "healthy",
+			"timestamp":    time.Now().UTC(),
+			"version":      "2.0.0",
+			"features":     []string{"merge", "split", "organize", "ai-features", "ocr", "library", "convert", "admin"},
+			"resources":    resourceMonitor.Snapshot(),
+			"maintenance":  maintenanceService.Active(),
+			"capabilities": capabilities.All(),
+			"operationLogFailures": handlers.OperationLogFailures(),
 		})
 	})
 
 	// Auth middleware (defined at outer scope for use in multiple route groups)
-	var authMiddleware gin.HandlerFunc = func(c *gin.Context) {
-		c.Next() // No-op if Firebase not configured
-	}
+	var authMiddleware gin.HandlerFunc = middleware.RequireCapability(capabilities, services.CapabilityAuth)
 	var optionalAuthMiddleware gin.HandlerFunc = func(c *gin.Context) {
 		c.Next()
 	}
@@ -118,8 +354,8 @@ func main() {
 	}
 
 	if firebaseClient != nil {
-		authMiddleware = middleware.AuthMiddleware(firebaseClient)
-		optionalAuthMiddleware = middleware.OptionalAuthMiddleware(firebaseClient)
+		authMiddleware = middleware.AuthMiddleware(firebaseClient, activityService)
+		optionalAuthMiddleware = middleware.OptionalAuthMiddleware(firebaseClient, activityService)
 		adminMiddleware = middleware.AdminMiddleware(userService)
 	}
 
@@ -134,10 +370,19 @@ func main() {
 		libraryHandler.RegisterRoutes(v1, authMiddleware)
 		log.Println("📤 Registering Share routes...")
 		shareHandler.RegisterRoutes(v1, authMiddleware)
+		abuseHandler.RegisterRoutes(v1)
 		conversionHandler.RegisterRoutes(v1, optionalAuthMiddleware)
+		jobHandler.RegisterRoutes(v1, optionalAuthMiddleware)
 		notificationHandler.RegisterRoutes(v1, authMiddleware) // Register notification routes with auth
 		paymentHandler.RegisterRoutes(v1, authMiddleware)
 		adminHandler.RegisterRoutes(v1, authMiddleware, adminMiddleware)
+		limitsHandler.RegisterRoutes(v1, optionalAuthMiddleware)
+		estimateHandler.RegisterRoutes(v1, optionalAuthMiddleware)
+		toolsHandler.RegisterRoutes(v1, optionalAuthMiddleware)
+		workspaceHandler.RegisterRoutes(v1, optionalAuthMiddleware)
+		signatureHandler.RegisterRoutes(v1, authMiddleware)
+		preferencesHandler.RegisterRoutes(v1, authMiddleware)
+		orgHandler.RegisterRoutes(v1, authMiddleware)
 	}
 
 	// API routes (Phase 3 - /api/pdf/*)
@@ -147,8 +392,35 @@ func main() {
 		corePDFHandler.RegisterRoutes(apiGroup)
 	}
 
+	// Periodic jobs run on a single instance, coordinated through Mongo leases
+	leaseService := services.NewLeaseService(mongoClient)
+	schedulerCtx, stopSchedulers := context.WithCancel(context.Background())
+	defer stopSchedulers()
+
 	// Start cleanup goroutine for expired files
-	go startCleanupJob(storageService)
+	go startCleanupJob(schedulerCtx, leaseService, storageService, workspaceService, maintenanceService)
+	go storageMigrationService.Run(schedulerCtx, leaseService)
+	go shareHandler.RunActivationNotices(schedulerCtx, leaseService)
+	go subscriptionService.Run(schedulerCtx, leaseService)
+	if entityIndexService != nil {
+		go entityIndexService.Run(schedulerCtx, leaseService)
+	}
+	if archiveService != nil {
+		go archiveService.Run(schedulerCtx, leaseService)
+	}
+	if backupService != nil {
+		go backupService.Run(schedulerCtx, leaseService)
+	}
+	if replicationService != nil {
+		go replicationService.Run(schedulerCtx, leaseService)
+	}
+	go backfillDocumentLocations(schedulerCtx, storageService)
+	go migrateLibrary(schedulerCtx, storageService)
+
+	// Resource sampling and the maintenance switch are per instance, so they
+	// run outside the leases
+	go resourceMonitor.Run(schedulerCtx)
+	go maintenanceService.Run(schedulerCtx)
 
 	// Create server
 	server := &http.Server{
@@ -159,41 +431,112 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	// Graceful shutdown
+	// Start server
+	log.Printf("🚀 BinaryPDF API server starting on port %s", cfg.Port)
+	log.Printf("📄 API documentation available at http://localhost:%s/health", cfg.Port)
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
 	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	lifecycle.LogStartup("server", map[string]interface{}{
+		"port":             cfg.Port,
+		"ginMode":          cfg.GinMode,
+		"envFile":          config.EnvFile(),
+		"pidFile":          cfg.PIDFile,
+		"corsOrigins":      cfg.CORSAllowedOrigins,
+		"planLimitsFile":   cfg.PlanLimitsFile,
+		"disabledFeatures": cfg.DisabledFeatures,
+		"queueBackend":     cfg.QueueBackend,
+		"runWorkers":       cfg.APIRunWorkers,
+		"capabilities":     capabilities.All(),
+	})
+	lifecycle.Ready()
 
+	// Reload on SIGHUP and shut down gracefully on SIGINT/SIGTERM (or the
+	// Windows service equivalents)
+	lifecycle.Run("brainy-pdf", func() {
+		reloaded, err := config.Reload()
+		if err != nil {
+			log.Printf("[Reload] Failed, keeping the current configuration: %v", err)
+			return
+		}
+		if err := cors.SetOrigins(reloaded.CORSAllowedOrigins); err != nil {
+			log.Printf("[Reload] Keeping CORS origins %v: %v", cfg.CORSAllowedOrigins, err)
+		} else {
+			cfg.CORSAllowedOrigins = reloaded.CORSAllowedOrigins
+		}
+		capabilities.SetDisabled(reloaded.DisabledFeatures)
+		log.Printf("[Reload] Applied CORS origins %v, disabled features %v and plan limits from %q",
+			cfg.CORSAllowedOrigins, reloaded.DisabledFeatures, reloaded.PlanLimitsFile)
+	}, func() {
 		log.Println("Shutting down server...")
+		stopSchedulers()
+		if conversionService != nil {
+			conversionService.Close()
+		}
+		if summaryService != nil {
+			summaryService.Close()
+		}
+		if jobService != nil {
+			jobService.Close()
+		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			log.Fatalf("Server forced to shutdown: %v", err)
+			log.Printf("Server forced to shutdown: %v", err)
 		}
+		logForwarder.Close()
 
 		log.Println("Server exited properly")
-	}()
+	})
+}
 
-	// Start server
-	log.Printf("🚀 BinaryPDF API server starting on port %s", cfg.Port)
-	log.Printf("📄 API documentation available at http://localhost:%s/health", cfg.Port)
+// startCleanupJob runs periodic cleanup of expired temporary files on
+// whichever instance holds the "cleanup" lease
+// backfillDocumentLocations gives documents stored before bucket and
+// objectKey existed those fields
+func backfillDocumentLocations(ctx context.Context, storageService *services.StorageService) {
+	n, err := storageService.BackfillLocations(ctx)
+	if err != nil {
+		log.Printf("Document location backfill failed after %d documents: %v", n, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Document location backfill: updated %d documents", n)
+	}
+}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Failed to start server: %v", err)
+// migrateLibrary moves library documents out of the legacy library
+// collection into documents
+func migrateLibrary(ctx context.Context, storageService *services.StorageService) {
+	n, err := storageService.MigrateLibrary(ctx)
+	if err != nil {
+		log.Printf("Library migration failed after %d documents: %v", n, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Library migration: moved %d documents", n)
 	}
 }
 
-// startCleanupJob runs periodic cleanup of expired temporary files
-func startCleanupJob(storageService *services.StorageService) {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
+func startCleanupJob(ctx context.Context, leaseService *services.LeaseService, storageService *services.StorageService, workspaceService *services.WorkspaceService, maintenance *services.MaintenanceService) {
+	leaseService.RunPeriodic(ctx, "cleanup", 30*time.Minute, func(ctx context.Context) {
+		if maintenance.Active() {
+			log.Printf("Cleanup job: skipped during maintenance")
+			return
+		}
 
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		deleted, err := storageService.CleanupExpiredFiles(ctx)
+		filesCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		deleted, err := storageService.CleanupExpiredFiles(filesCtx)
 		cancel()
 
 		if err != nil {
@@ -201,5 +544,15 @@ func startCleanupJob(storageService *services.StorageService) {
 		} else if deleted > 0 {
 			log.Printf("Cleanup job: removed %d expired files", deleted)
 		}
-	}
+
+		workspacesCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		expired, err := workspaceService.CleanupExpired(workspacesCtx)
+		cancel()
+
+		if err != nil {
+			log.Printf("Workspace cleanup error: %v", err)
+		} else if expired > 0 {
+			log.Printf("Cleanup job: removed %d expired workspaces", expired)
+		}
+	})
 }
@@ -0,0 +1,182 @@
+// Command worker runs conversion and AI summary jobs from the shared queue.
+// It lets the API stay responsive while LibreOffice-heavy nodes are scaled
+// and sized independently; set API_RUN_WORKERS=false on API instances to route all
+// processing here.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/lifecycle"
+	"brainy-pdf/internal/services"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+)
+
+func main() {
+	cfg := config.Load()
+
+	log.Printf("🚀 Starting Worker...")
+
+	removePIDFile, err := lifecycle.WritePIDFile(cfg.PIDFile)
+	if err != nil {
+		log.Fatalf("Failed to write PID file: %v", err)
+	}
+	defer removePIDFile()
+
+	if cfg.QueueBackend == "memory" || cfg.QueueBackend == "" {
+		log.Fatalf("QUEUE_BACKEND=%q cannot be shared with the API; use a shared backend such as mongo or redis", cfg.QueueBackend)
+	}
+	if cfg.ConversionWorkers <= 0 {
+		log.Fatalf("CONVERSION_WORKERS must be at least 1")
+	}
+
+	mongoClient, err := mongodb.NewClient(cfg.MongoDBURI, cfg.MongoDBDatabase)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Close(context.Background())
+
+	minioClient, err := minioPkg.NewClient(
+		cfg.MinIOEndpoint,
+		cfg.MinIOAccessKey,
+		cfg.MinIOSecretKey,
+		cfg.MinIOUseSSL,
+		cfg.MinIOBucketTemp,
+		cfg.MinIOBucketUserFiles,
+	)
+	if err != nil {
+		log.Fatalf("Failed to connect to MinIO: %v", err)
+	}
+
+	// Encrypt user files at rest when a master key is configured
+	if cfg.EncryptionMasterKey != "" {
+		encryptionService, err := services.NewEncryptionService(mongoClient, minioClient, cfg.EncryptionMasterKey, cfg.EncryptionPreviousMasterKeys, cfg.EncryptionAllowPlaintext)
+		if err != nil {
+			log.Fatalf("Failed to set up encryption: %v", err)
+		}
+		minioClient.SetEncrypter(encryptionService)
+	}
+
+	jobQueue, err := services.NewJobQueue(cfg.QueueBackend, mongoClient, cfg.RedisURL, time.Duration(cfg.QueueVisibilityTimeoutSeconds)*time.Second, time.Duration(cfg.QueueMaxWaitSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to create job queue: %v", err)
+	}
+	defer jobQueue.Close()
+
+	conversionService, err := services.NewConversionService(cfg.ConversionWorkers, mongoClient, minioClient, jobQueue)
+	if err != nil {
+		log.Fatalf("Failed to create conversion service: %v", err)
+	}
+
+	// Summaries need OpenRouter; without a key they are left to the API nodes
+	var summaryService *services.SummaryService
+	if cfg.SummaryWorkers > 0 && cfg.OpenRouterAPIKey != "" {
+		aiService, err := services.NewAIService(context.Background(), cfg.OpenRouterAPIKey)
+		if err != nil {
+			log.Fatalf("Failed to create AI service: %v", err)
+		}
+		summaryService, err = services.NewSummaryService(cfg.SummaryWorkers, aiService, mongoClient, minioClient, jobQueue)
+		if err != nil {
+			log.Fatalf("Failed to create summary service: %v", err)
+		}
+	}
+
+	// Stop taking jobs while temp disk or memory is over its limit, or while
+	// maintenance mode is on
+	resourceMonitor := services.NewResourceMonitor(cfg.MaxTempDiskMB, cfg.MaxRSSMB, services.DefaultTempDirs()...)
+	maintenanceService := services.NewMaintenanceService(context.Background(), mongoClient)
+	overloaded := func() bool {
+		overloaded, _ := resourceMonitor.Overloaded()
+		return overloaded || maintenanceService.Active()
+	}
+	conversionService.SetTempQuota(int64(cfg.TempJobQuotaMB) * 1024 * 1024)
+	resourceMonitor.AddScratch(conversionService.ScratchSpace())
+	resourceMonitor.RegisterCleaner(conversionService.CleanupFinished)
+	conversionService.SetPauseCheck(overloaded)
+	conversionService.SetReservedWorkers(cfg.QueueReservedWorkers)
+	if summaryService != nil {
+		resourceMonitor.RegisterCleaner(summaryService.CleanupFinished)
+		summaryService.SetPauseCheck(overloaded)
+		summaryService.SetReservedWorkers(cfg.QueueReservedWorkers)
+	}
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go resourceMonitor.Run(monitorCtx)
+	go maintenanceService.Run(monitorCtx)
+
+	// Health endpoint for orchestrator probes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		depth, err := conversionService.QueueDepth(r.Context())
+		status := http.StatusOK
+		body := map[string]interface{}{
+			"status":      "ok",
+			"service":     "brainy-pdf-worker",
+			"workers":     cfg.ConversionWorkers,
+			"queued":      depth,
+			"resources":   resourceMonitor.Snapshot(),
+			"maintenance": maintenanceService.Active(),
+		}
+		if summaryService != nil && err == nil {
+			var summaryDepth int64
+			summaryDepth, err = summaryService.QueueDepth(r.Context())
+			body["summaryWorkers"] = cfg.SummaryWorkers
+			body["summariesQueued"] = summaryDepth
+		}
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			body["status"] = "degraded"
+			body["error"] = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	})
+	server := &http.Server{
+		Addr:    ":" + cfg.WorkerHealthPort,
+		Handler: mux,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Worker health endpoint failed: %v", err)
+		}
+	}()
+
+	log.Printf("Worker running %d conversion workers on %s queue (health on :%s)", cfg.ConversionWorkers, cfg.QueueBackend, cfg.WorkerHealthPort)
+	lifecycle.LogStartup("worker", map[string]interface{}{
+		"envFile":           config.EnvFile(),
+		"pidFile":           cfg.PIDFile,
+		"planLimitsFile":    cfg.PlanLimitsFile,
+		"queueBackend":      cfg.QueueBackend,
+		"conversionWorkers": cfg.ConversionWorkers,
+		"summaryWorkers":    cfg.SummaryWorkers,
+		"reservedWorkers":   cfg.QueueReservedWorkers,
+		"healthPort":        cfg.WorkerHealthPort,
+	})
+	lifecycle.Ready()
+
+	// None of the worker's settings can change while it runs
+	lifecycle.Run("brainy-pdf-worker", func() {
+		log.Println("[Reload] Nothing to reload in the worker; restart it to apply configuration changes")
+	}, func() {
+		log.Println("Shutting down worker...")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+
+		// Jobs interrupted here are redelivered after the visibility timeout
+		conversionService.Close()
+		if summaryService != nil {
+			summaryService.Close()
+		}
+
+		log.Println("Worker exited")
+	})
+}
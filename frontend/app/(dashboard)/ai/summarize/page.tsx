@@ -13,6 +13,7 @@ export default function SummarizePage() {
     const [length, setLength] = useState<'short' | 'medium' | 'long'>('medium');
     const [isProcessing, setIsProcessing] = useState(false);
     const [result, setResult] = useState<any>(null);
+    const [progress, setProgress] = useState<{ completed: number; total: number; stage?: string } | null>(null);
     const [copied, setCopied] = useState(false);
 
     const lengthOptions = [
@@ -34,15 +35,34 @@ export default function SummarizePage() {
 
         setIsProcessing(true);
         setResult(null);
+        setProgress(null);
 
         try {
             const response = await aiApi.summarize(files[0], length);
-            setResult(response.data.data);
-            toast.success('AI Analysis Complete!');
+            const { jobId, totalChunks } = response.data.data;
+            setProgress({ completed: 0, total: totalChunks });
+
+            // Large documents are summarized chunk by chunk in the background
+            while (true) {
+                await new Promise((resolve) => setTimeout(resolve, 2000));
+                const job = (await aiApi.summarizeStatus(jobId)).data.data;
+                setProgress({ completed: job.completedChunks, total: job.totalChunks, stage: job.stage });
+
+                if (job.status === 'completed') {
+                    setResult(job.result);
+                    toast.success('AI Analysis Complete!');
+                    break;
+                }
+                if (job.status === 'failed') {
+                    toast.error(job.error || 'Failed to analyze PDF');
+                    break;
+                }
+            }
         } catch (error: any) {
             toast.error(error.response?.data?.error?.message || 'Failed to analyze PDF');
         } finally {
             setIsProcessing(false);
+            setProgress(null);
         }
     };
 
@@ -164,7 +184,13 @@ export default function SummarizePage() {
                                             {isProcessing ? (
                                                 <div className="flex items-center gap-3">
                                                     <Loader2 className="w-6 h-6 animate-spin" />
-                                                    <span className="text-lg">AI is reading your document...</span>
+                                                    <span className="text-lg">
+                                                        {progress?.stage === 'combining'
+                                                            ? 'Combining section summaries...'
+                                                            : progress && progress.total > 1
+                                                                ? `Summarized ${progress.completed} of ${progress.total} sections...`
+                                                                : 'AI is reading your document...'}
+                                                    </span>
                                                 </div>
                                             ) : (
                                                 <div className="flex items-center gap-3">
@@ -3,7 +3,7 @@
 import { useState, useEffect } from 'react';
 import { useParams } from 'next/navigation';
 import { api, shareApi } from '@/lib/api';
-import { Download, FileText, AlertTriangle, Clock, Shield, CheckCircle, Zap, ArrowRight, Loader2 } from 'lucide-react';
+import { Download, FileText, AlertTriangle, Clock, Shield, CheckCircle, Zap, ArrowRight, Loader2, Eye, Flag } from 'lucide-react';
 import { motion } from 'framer-motion';
 import clsx from 'clsx';
 import toast from 'react-hot-toast';
@@ -16,6 +16,11 @@ export default function SharedFilePage() {
     const [fileData, setFileData] = useState<any>(null);
     const [error, setError] = useState<string | null>(null);
     const [isDownloading, setIsDownloading] = useState(false);
+    const [reportOpen, setReportOpen] = useState(false);
+    const [reportReason, setReportReason] = useState('copyright');
+    const [reportDetails, setReportDetails] = useState('');
+    const [reportEmail, setReportEmail] = useState('');
+    const [reported, setReported] = useState(false);
 
     useEffect(() => {
         const fetchShare = async () => {
@@ -23,7 +28,12 @@ export default function SharedFilePage() {
                 const response = await shareApi.get(code);
                 setFileData(response.data.data);
             } catch (err: any) {
-                setError(err.response?.data?.error || 'Link invalid or expired');
+                const data = err.response?.data;
+                if (data?.code === 'SHARE_NOT_ACTIVE' && data.activatesAt) {
+                    setError(`This link opens on ${new Date(data.activatesAt).toLocaleString()}`);
+                } else {
+                    setError(data?.error || 'Link invalid or expired');
+                }
             } finally {
                 setLoading(false);
             }
@@ -33,7 +43,7 @@ export default function SharedFilePage() {
     }, [code]);
 
     const handleDownload = async () => {
-        if (!fileData?.url) return;
+        if (!fileData?.url || fileData.downloadable === false) return;
         setIsDownloading(true);
 
         try {
@@ -51,6 +61,17 @@ export default function SharedFilePage() {
         }
     };
 
+    const handleReport = async () => {
+        try {
+            await shareApi.report(code, reportReason, reportDetails, reportEmail || undefined);
+            setReported(true);
+            setReportOpen(false);
+            toast.success('Thanks, your report will be reviewed');
+        } catch (err: any) {
+            toast.error(err.response?.data?.error?.message || 'Report failed. Please try again.');
+        }
+    };
+
     const formatBytes = (bytes: number): string => {
         if (bytes === 0) return '0 B';
         const k = 1024;
@@ -105,7 +126,7 @@ export default function SharedFilePage() {
             <motion.div
                 initial={{ opacity: 0, y: 20 }}
                 animate={{ opacity: 1, y: 0 }}
-                className="relative z-10 max-w-xl w-full"
+                className={clsx("relative z-10 w-full", fileData.downloadable === false ? "max-w-4xl" : "max-w-xl")}
             >
                 <div className="flex justify-center mb-12">
                     <div className="flex items-center gap-3 bg-slate-900/50 backdrop-blur-xl border border-white/5 px-6 py-3 rounded-2xl shadow-2xl">
@@ -122,7 +143,11 @@ export default function SharedFilePage() {
                             <div className="relative inline-block group">
                                 <div className="w-32 h-44 bg-slate-950 border-2 border-white/5 rounded-[32px] flex items-center justify-center shadow-2xl relative overflow-hidden">
                                     <div className="absolute inset-0 bg-gradient-to-br from-blue-500/5 via-transparent to-transparent"></div>
-                                    <FileText className="w-16 h-16 text-slate-800" />
+                                    {fileData.coverUrl ? (
+                                        <img src={fileData.coverUrl} alt="" className="absolute inset-0 w-full h-full object-cover" />
+                                    ) : (
+                                        <FileText className="w-16 h-16 text-slate-800" />
+                                    )}
                                     <div className="absolute -bottom-1 -right-1 w-12 h-12 bg-slate-900 rounded-tl-2xl border-t border-l border-white/5 flex items-center justify-center">
                                         <Zap className="w-4 h-4 text-blue-400" />
                                     </div>
@@ -135,8 +160,11 @@ export default function SharedFilePage() {
 
                             <div className="space-y-2">
                                 <h1 className="text-2xl font-black text-white px-2 tracking-tight line-clamp-2">
-                                    {fileData.filename}
+                                    {fileData.title || fileData.filename}
                                 </h1>
+                                {fileData.description && (
+                                    <p className="text-sm text-slate-400 px-4 leading-relaxed">{fileData.description}</p>
+                                )}
                                 <div className="flex items-center justify-center gap-3">
                                     <div className="flex items-center gap-2 text-slate-500 group">
                                         <Clock className="w-4 h-4" />
@@ -146,6 +174,20 @@ export default function SharedFilePage() {
                             </div>
                         </div>
 
+                        {fileData.downloadable === false ? (
+                            <div className="space-y-4">
+                                {/* View-only: the server serves a watermarked copy inline */}
+                                <iframe
+                                    src={`${fileData.url}#toolbar=0`}
+                                    title={fileData.filename}
+                                    className="w-full h-[70vh] rounded-2xl border border-white/5 bg-slate-950"
+                                />
+                                <div className="flex items-center justify-center gap-2 text-slate-500">
+                                    <Eye className="w-4 h-4" />
+                                    <span className="text-xs font-bold uppercase tracking-widest">View only - downloads are disabled</span>
+                                </div>
+                            </div>
+                        ) : (
                         <div className="space-y-6">
                             <button
                                 onClick={handleDownload}
@@ -169,12 +211,62 @@ export default function SharedFilePage() {
                                 )}
                             </button>
                         </div>
+                        )}
                     </div>
 
                     <div className="bg-slate-950/50 border-t border-white/5 p-6 text-center">
                         <p className="text-[10px] font-black text-slate-700 uppercase tracking-[0.3em]">
                             SECURELY ROUTED VIA <span className="text-slate-500">BinaryPDF</span>
                         </p>
+                        {!reported && !reportOpen && (
+                            <button
+                                onClick={() => setReportOpen(true)}
+                                className="mt-3 inline-flex items-center gap-1.5 text-[10px] font-bold text-slate-600 hover:text-rose-400 uppercase tracking-widest transition-colors"
+                            >
+                                <Flag className="w-3 h-3" />
+                                Report this link
+                            </button>
+                        )}
+                        {reportOpen && (
+                            <div className="mt-4 space-y-3 text-left">
+                                <select
+                                    value={reportReason}
+                                    onChange={(e) => setReportReason(e.target.value)}
+                                    className="w-full rounded-xl bg-slate-900 border border-white/10 px-3 py-2 text-sm text-slate-300"
+                                >
+                                    <option value="copyright">Copyright infringement (DMCA)</option>
+                                    <option value="malware">Malware</option>
+                                    <option value="phishing">Phishing</option>
+                                    <option value="harassment">Harassment or private information</option>
+                                    <option value="illegal">Illegal content</option>
+                                    <option value="spam">Spam</option>
+                                    <option value="other">Other</option>
+                                </select>
+                                <textarea
+                                    value={reportDetails}
+                                    onChange={(e) => setReportDetails(e.target.value)}
+                                    maxLength={2000}
+                                    rows={3}
+                                    placeholder="Details (required for Other)"
+                                    className="w-full rounded-xl bg-slate-900 border border-white/10 px-3 py-2 text-sm text-slate-300"
+                                />
+                                <input
+                                    type="email"
+                                    value={reportEmail}
+                                    onChange={(e) => setReportEmail(e.target.value)}
+                                    placeholder="Your email (optional)"
+                                    className="w-full rounded-xl bg-slate-900 border border-white/10 px-3 py-2 text-sm text-slate-300"
+                                />
+                                <div className="flex gap-3">
+                                    <button onClick={() => setReportOpen(false)} className="flex-1 py-2 rounded-xl border border-white/10 text-xs font-bold text-slate-400 uppercase">
+                                        Cancel
+                                    </button>
+                                    <button onClick={handleReport} className="flex-1 py-2 rounded-xl bg-rose-500/20 border border-rose-500/30 text-xs font-bold text-rose-300 uppercase">
+                                        Send report
+                                    </button>
+                                </div>
+                            </div>
+                        )}
                     </div>
                 </div>
             </motion.div>
@@ -218,8 +218,11 @@ export default function ConvertPage() {
                     const contentDisposition = response.headers['content-disposition'];
                     let filename = 'converted_output';
                     if (contentDisposition) {
-                        const match = contentDisposition.match(/filename="(.+)"/);
-                        if (match) filename = match[1];
+                        // Prefer the UTF-8 name; filename= is an ASCII fallback
+                        const encoded = contentDisposition.match(/filename\*=UTF-8''([^;]+)/i);
+                        const match = contentDisposition.match(/filename="([^"]+)"/);
+                        if (encoded) filename = decodeURIComponent(encoded[1]);
+                        else if (match) filename = match[1];
                     }
                     const blobUrl = window.URL.createObjectURL(new Blob([response.data]));
                     const link = document.createElement('a');
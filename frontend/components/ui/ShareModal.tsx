@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
-import { X, Copy, Check, Globe, Clock, Link as LinkIcon, Share2, Zap, Lock } from 'lucide-react';
+import { X, Copy, Check, Globe, Clock, Link as LinkIcon, Share2, Zap, Lock, Eye, Download } from 'lucide-react';
 import { notify } from '@/lib/notifications';
 import { api } from '@/lib/api';
 import { useAuthStore } from '@/lib/store';
@@ -17,6 +17,7 @@ export default function ShareModal({ isOpen, onClose, fileId, fileType }: ShareM
     const { user } = useAuthStore();
     const router = useRouter();
     const [expiresIn, setExpiresIn] = useState(1440); // default 24h (1440 min)
+    const [permission, setPermission] = useState<'download' | 'view'>('download');
     const [generatedUrl, setGeneratedUrl] = useState<string | null>(null);
     const [loading, setLoading] = useState(false);
     const [copied, setCopied] = useState(false);
@@ -28,6 +29,7 @@ export default function ShareModal({ isOpen, onClose, fileId, fileType }: ShareM
         if (isOpen) {
             setGeneratedUrl(null);
             setExpiresIn(1440);
+            setPermission('download');
         }
     }, [isOpen]);
 
@@ -38,7 +40,8 @@ export default function ShareModal({ isOpen, onClose, fileId, fileType }: ShareM
             const response = await api.post('/share', {
                 fileId,
                 fileType,
-                expiresInMinutes: expiresIn
+                expiresInMinutes: expiresIn,
+                permission
             });
             setGeneratedUrl(response.data.data.url);
             notify.customSuccess('Link Generated', 'Public share link created successfully.');
@@ -139,6 +142,36 @@ export default function ShareModal({ isOpen, onClose, fileId, fileType }: ShareM
                                     </div>
                                 </div>
 
+                                <div>
+                                    <label className="block text-sm font-bold text-slate-700 dark:text-slate-300 mb-3 ml-1">
+                                        Recipients Can
+                                    </label>
+                                    <div className="grid grid-cols-2 gap-3">
+                                        {[
+                                            { label: 'Download', value: 'download' as const, icon: Download },
+                                            { label: 'View Only', value: 'view' as const, icon: Eye },
+                                        ].map((option) => (
+                                            <button
+                                                key={option.value}
+                                                onClick={() => setPermission(option.value)}
+                                                className={`py-3 px-4 rounded-2xl border text-xs font-bold transition-all flex items-center justify-center gap-2
+                                                ${permission === option.value
+                                                        ? 'border-blue-500 bg-blue-500/5 text-blue-600 dark:text-blue-400 ring-2 ring-blue-500/20'
+                                                        : 'border-slate-100 dark:border-slate-800 hover:border-blue-300 text-slate-500'
+                                                    }`}
+                                            >
+                                                <option.icon className="w-4 h-4" />
+                                                {option.label}
+                                            </button>
+                                        ))}
+                                    </div>
+                                    {permission === 'view' && (
+                                        <p className="text-[11px] text-slate-500 mt-2 ml-1">
+                                            PDFs open in the browser with a watermark and can't be downloaded.
+                                        </p>
+                                    )}
+                                </div>
+
                                 <button
                                     onClick={handleGenerate}
                                     disabled={loading}
@@ -20,12 +20,24 @@ export const api = axios.create({
 });
 
 // Add auth token to requests
+// Per-tab session ID, sent with every request so server logs for one
+// session can be followed across page loads
+function sessionId(): string {
+    let id = sessionStorage.getItem('sessionId');
+    if (!id) {
+        id = crypto.randomUUID();
+        sessionStorage.setItem('sessionId', id);
+    }
+    return id;
+}
+
 api.interceptors.request.use((config) => {
     if (typeof window !== 'undefined') {
         const token = localStorage.getItem('authToken');
         if (token) {
             config.headers.Authorization = `Bearer ${token}`;
         }
+        config.headers['X-Session-ID'] = sessionId();
     }
     return config;
 });
@@ -57,28 +69,53 @@ export const authApi = {
 
     getUserStats: () => api.get<ApiResponse<any>>('/auth/stats'),
 
-    updateProfile: (displayName: string) => api.put<ApiResponse<any>>('/auth/profile', { displayName }),
+    getLargestFiles: (limit: number = 10) => api.get<ApiResponse<any>>(`/auth/largest-files?limit=${limit}`),
+
+    getStorageBreakdown: () => api.get<ApiResponse<any>>('/auth/storage-breakdown'),
+
+    getActivity: (limit: number = 50) => api.get<ApiResponse<any>>(`/auth/activity?limit=${limit}`),
+
+    updateProfile: (displayName: string, filenameTemplate?: string) =>
+        api.put<ApiResponse<any>>('/auth/profile', { displayName, filenameTemplate }),
+
+    getEstimate: (operation: string, size?: number, pages?: number) =>
+        api.get<ApiResponse<any>>('/estimate', { params: { operation, size, pages } }),
 };
 
 // PDF API
 export const pdfApi = {
-    merge: (files: File[]) => {
+    merge: (files: File[], progressId?: string) => {
         const formData = new FormData();
         files.forEach((file) => formData.append('files', file));
+        if (progressId) formData.append('progressId', progressId);
         return api.post<ApiResponse<any>>('/pdf/merge', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
         });
     },
 
-    split: (file: File, pages: string) => {
+    split: (file: File, pages: string, progressId?: string) => {
         const formData = new FormData();
         formData.append('file', file);
         formData.append('pages', pages);
+        if (progressId) formData.append('progressId', progressId);
         return api.post<ApiResponse<any>>('/pdf/split', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
         });
     },
 
+    // Progress of a merge or split sent with the same progressId; poll
+    // while the request is running
+    progress: (progressId: string) =>
+        api.get<ApiResponse<{
+            operation: string;
+            status: 'processing' | 'completed' | 'failed';
+            stage: 'reading' | 'processing' | 'uploading';
+            pagesProcessed: number;
+            totalPages: number;
+            progress: number;
+            error?: string;
+        }>>(`/pdf/progress/${progressId}`),
+
     rotate: (file: File, pages: string, angle: number) => {
         const formData = new FormData();
         formData.append('file', file);
@@ -89,10 +126,29 @@ export const pdfApi = {
         });
     },
 
-    compress: (file: File, quality: string = 'medium') => {
+    // rotations maps page numbers to angles, e.g. { 1: 90, 3: 270 }
+    rotatePages: (file: File, rotations: Record<number, number>) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('rotations', JSON.stringify(rotations));
+        return api.post<ApiResponse<any>>('/pdf/rotate', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
+    // mode 'photo' recompresses JPEGs for scans and photo-heavy files (see
+    // imageProfile.suggestedMode); dryRun estimates without storing
+    compress: (
+        file: File,
+        quality: string = 'medium',
+        options?: { mode?: 'standard' | 'photo'; dpi?: number; dryRun?: boolean }
+    ) => {
         const formData = new FormData();
         formData.append('file', file);
         formData.append('quality', quality);
+        if (options?.mode) formData.append('mode', options.mode);
+        if (options?.dpi) formData.append('dpi', options.dpi.toString());
+        if (options?.dryRun) formData.append('dryRun', 'true');
         return api.post<ApiResponse<any>>('/pdf/compress', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
         });
@@ -136,6 +192,20 @@ export const pdfApi = {
         });
     },
 
+    imageWatermark: (file: File, image: File, position?: string, scale?: number, opacity?: number, rotation?: number) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('type', 'image');
+        formData.append('image', image);
+        if (position) formData.append('position', position);
+        if (scale) formData.append('scale', scale.toString());
+        if (opacity) formData.append('opacity', opacity.toString());
+        if (rotation) formData.append('rotation', rotation.toString());
+        return api.post<ApiResponse<any>>('/pdf/watermark', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
     pageNumbers: (file: File, position?: string, format?: string, startFrom?: number) => {
         const formData = new FormData();
         formData.append('file', file);
@@ -158,6 +228,128 @@ export const pdfApi = {
             headers: { 'Content-Type': 'multipart/form-data' },
         });
     },
+
+    // Photos of paper pages, in page order, to one scanned PDF. corners
+    // holds each photo's page outline as adjusted by the user, or null to
+    // detect it.
+    // Splits a stack of documents at detected boundaries; dryRun only
+    // reports the documents found
+    autoSplit: (file: File, options?: { ai?: boolean; dryRun?: boolean }) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        if (options?.ai !== undefined) formData.append('ai', options.ai.toString());
+        if (options?.dryRun) formData.append('dryRun', 'true');
+        return api.post<ApiResponse<any>>('/pdf/auto-split', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    scanDocument: (
+        images: File[],
+        options?: {
+            mode?: 'color' | 'grayscale' | 'bw';
+            pageSize?: 'A4' | 'Letter';
+            corners?: ({ x: number; y: number }[] | null)[];
+            progressId?: string;
+        }
+    ) => {
+        const formData = new FormData();
+        images.forEach((image) => formData.append('images', image));
+        if (options?.mode) formData.append('mode', options.mode);
+        if (options?.pageSize) formData.append('pageSize', options.pageSize);
+        if (options?.corners) formData.append('corners', JSON.stringify(options.corners));
+        if (options?.progressId) formData.append('progressId', options.progressId);
+        return api.post<ApiResponse<any>>('/pdf/scan-document', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
+    // Images as they are, one page each, in order
+    fromImages: (
+        images: File[],
+        options?: {
+            pageSize?: 'A4' | 'Letter' | 'Legal' | 'Fit';
+            orientation?: 'portrait' | 'landscape' | 'auto';
+            margin?: number;
+        }
+    ) => {
+        const formData = new FormData();
+        images.forEach((image) => formData.append('images', image));
+        if (options?.pageSize) formData.append('pageSize', options.pageSize);
+        if (options?.orientation) formData.append('orientation', options.orientation);
+        if (options?.margin !== undefined) formData.append('margin', options.margin.toString());
+        return api.post<ApiResponse<any>>('/pdf/from-images', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
+    // Digital signature with a .p12/.pfx certificate; visible when a saved
+    // signatureId or an image is given
+    sign: (
+        file: File,
+        certificate: File,
+        password: string,
+        options?: {
+            signatureId?: string;
+            image?: File;
+            page?: number;
+            x?: number;
+            y?: number;
+            width?: number;
+            reason?: string;
+            location?: string;
+            contactInfo?: string;
+        }
+    ) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('certificate', certificate);
+        formData.append('password', password);
+        if (options?.signatureId) formData.append('signatureId', options.signatureId);
+        if (options?.image) formData.append('image', options.image);
+        if (options?.page) formData.append('page', options.page.toString());
+        if (options?.x !== undefined) formData.append('x', options.x.toString());
+        if (options?.y !== undefined) formData.append('y', options.y.toString());
+        if (options?.width) formData.append('width', options.width.toString());
+        if (options?.reason) formData.append('reason', options.reason);
+        if (options?.location) formData.append('location', options.location);
+        if (options?.contactInfo) formData.append('contactInfo', options.contactInfo);
+        return api.post<ApiResponse<any>>('/pdf/sign', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    verifySignature: (file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.post<ApiResponse<any>>('/pdf/verify-signature', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
+    // AcroForm fields: list them, fill them by name (values as returned by
+    // /ai/auto-fill work as is), and flatten them into the page content
+    formFields: (file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.post<ApiResponse<any>>('/pdf/form/fields', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    fillForm: (file: File, values: Record<string, string | boolean | string[]>, flatten?: boolean) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('values', JSON.stringify(values));
+        if (flatten) formData.append('flatten', 'true');
+        return api.post<ApiResponse<any>>('/pdf/form/fill', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    flattenForm: (file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.post<ApiResponse<any>>('/pdf/form/flatten', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
 };
 
 // AI API - longer timeout for AI processing
@@ -171,13 +363,59 @@ export const aiApi = {
         });
     },
 
+    // Queues a summary job; poll summarizeStatus with the returned jobId
     summarize: (file: File, length: string = 'medium') => {
         const formData = new FormData();
         formData.append('file', file);
         formData.append('length', length);
         return api.post<ApiResponse<any>>('/ai/summarize', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
-            timeout: 90000, // 90 seconds for AI summarization
+            timeout: 90000, // 90 seconds for upload and text extraction
+        });
+    },
+
+    summarizeStatus: (jobId: string) =>
+        api.get<ApiResponse<any>>(`/ai/summarize/status/${jobId}`),
+
+    contractReview: (file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.post<ApiResponse<any>>('/ai/contract-review', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            timeout: 120000, // 2 minutes for long contracts
+        });
+    },
+
+    diffSummary: (original: File, revised: File) => {
+        const formData = new FormData();
+        formData.append('original', original);
+        formData.append('revised', revised);
+        return api.post<ApiResponse<any>>('/ai/diff-summary', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            timeout: 120000, // 2 minutes for large diffs
+        });
+    },
+
+    // requirements: names, {name, description} objects, or one per line
+    templateCheck: (file: File, requirements: string | Array<string | { name: string; description?: string }>) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('requirements', typeof requirements === 'string' ? requirements : JSON.stringify(requirements));
+        return api.post<ApiResponse<any>>('/ai/template-check', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            timeout: 120000, // 2 minutes for long documents
+        });
+    },
+
+    readAloud: (file: File, mode: 'document' | 'pages' = 'document', pages?: string, voice?: string) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('mode', mode);
+        if (pages) formData.append('pages', pages);
+        if (voice) formData.append('voice', voice);
+        return api.post<ApiResponse<any>>('/ai/read-aloud', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            timeout: 300000, // 5 minutes for long documents
         });
     },
 
@@ -200,6 +438,19 @@ export const aiApi = {
         });
     },
 
+    redact: (file: File, options: { types?: string[]; terms?: string[]; ai?: boolean; color?: string } = {}) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        if (options.types?.length) formData.append('types', options.types.join(','));
+        if (options.terms?.length) formData.append('terms', options.terms.join(','));
+        if (options.ai) formData.append('ai', 'true');
+        if (options.color) formData.append('color', options.color);
+        return api.post<ApiResponse<any>>('/ai/redact', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            timeout: 120000, // 2 minutes when the AI reviews the text
+        });
+    },
+
     search: (query: string, documents?: string[], fileIds?: string[]) =>
         api.post<ApiResponse<any>>('/ai/search', { query, documents, fileIds }, { timeout: 60000 }),
 
@@ -218,11 +469,19 @@ export const filesApi = {
         });
     },
 
+    // Store a PDF or Office file hosted elsewhere, without downloading it first
+    importUrl: (url: string, temporary: boolean = false) =>
+        api.post<ApiResponse<any>>('/files/import-url', { url, temporary }),
+
     getFile: (id: string) => api.get<ApiResponse<any>>(`/files/${id}`),
 
     download: (id: string) =>
         api.get(`/files/${id}/download`, { responseType: 'blob' }),
 
+    // Page preview of a stored PDF (page is 1-based)
+    thumbnail: (id: string, page: number, options: { dpi?: number; format?: 'png' | 'jpeg'; quality?: number } = {}) =>
+        api.get(`/files/${id}/thumbnail/${page}`, { params: options, responseType: 'blob' }),
+
     delete: (id: string) => api.delete<ApiResponse<any>>(`/files/${id}`),
 
     listLibrary: (page: number = 1, limit: number = 20, folderId?: string) =>
@@ -233,6 +492,7 @@ export const filesApi = {
 
 // Library API - for user's permanent file library
 export const libraryApi = {
+    // A ZIP imports every PDF in it; the response lists each entry
     upload: (file: File) => {
         const formData = new FormData();
         formData.append('file', file);
@@ -241,11 +501,14 @@ export const libraryApi = {
         });
     },
 
-    list: (page: number = 1, limit: number = 20) =>
+    list: (page: number = 1, limit: number = 20, entity?: { value: string; type?: string }) =>
         api.get<ApiResponse<any>>('/library/list', {
-            params: { page, limit },
+            params: { page, limit, entity: entity?.value, entityType: entity?.type },
         }),
 
+    entities: (params?: { type?: string; search?: string; limit?: number }) =>
+        api.get<ApiResponse<any>>('/library/entities', { params }),
+
     download: (id: string) =>
         api.get(`/library/download/${id}`, { responseType: 'blob' }),
 
@@ -254,19 +517,118 @@ export const libraryApi = {
 
     delete: (id: string) =>
         api.delete<ApiResponse<any>>(`/library/${id}`),
+
+    restore: (id: string) =>
+        api.post<ApiResponse<any>>(`/library/${id}/restore`),
+
+    addAudioNote: (id: string, audio: File | Blob, page?: number) => {
+        const formData = new FormData();
+        formData.append('audio', audio, audio instanceof File ? audio.name : 'note.webm');
+        if (page) formData.append('page', String(page));
+        return api.post<ApiResponse<any>>(`/library/${id}/notes/audio`, formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
+    listNotes: (id: string) =>
+        api.get<ApiResponse<any>>(`/library/${id}/notes`),
+
+    deleteNote: (id: string, noteId: string) =>
+        api.delete<ApiResponse<any>>(`/library/${id}/notes/${noteId}`),
+
+    setTags: (id: string, tags: string[]) =>
+        api.put<ApiResponse<any>>(`/library/${id}/tags`, { tags }),
+
+    listRules: () =>
+        api.get<ApiResponse<any>>('/library/rules'),
+
+    createRule: (rule: any) =>
+        api.post<ApiResponse<any>>('/library/rules', rule),
+
+    updateRule: (ruleId: string, rule: any) =>
+        api.put<ApiResponse<any>>(`/library/rules/${ruleId}`, rule),
+
+    deleteRule: (ruleId: string) =>
+        api.delete<ApiResponse<any>>(`/library/rules/${ruleId}`),
+
+    testRules: (params: { documentId?: string; filename?: string; documentType?: string; mimeType?: string; rule?: any }) =>
+        api.post<ApiResponse<any>>('/library/rules/test', params),
+
+    listSearches: () =>
+        api.get<ApiResponse<any>>('/library/searches'),
+
+    createSearch: (search: { name: string; query: { text?: string; tags?: string[]; entity?: string; entityType?: string }; alert?: boolean }) =>
+        api.post<ApiResponse<any>>('/library/searches', search),
+
+    updateSearch: (searchId: string, search: { name: string; query: { text?: string; tags?: string[]; entity?: string; entityType?: string }; alert?: boolean }) =>
+        api.put<ApiResponse<any>>(`/library/searches/${searchId}`, search),
+
+    deleteSearch: (searchId: string) =>
+        api.delete<ApiResponse<any>>(`/library/searches/${searchId}`),
+
+    searchResults: (searchId: string) =>
+        api.get<ApiResponse<any>>(`/library/searches/${searchId}/results`),
+};
+
+export const orgApi = {
+    create: (name: string) => api.post<ApiResponse<any>>('/orgs', { name }),
+    get: () => api.get<ApiResponse<any>>('/orgs/me'),
+    updatePolicy: (policy: any) => api.put<ApiResponse<any>>('/orgs/me/policy', policy),
+    addMember: (email: string, role: string = 'member') =>
+        api.post<ApiResponse<any>>('/orgs/me/members', { email, role }),
+    removeMember: (uid: string) => api.delete<ApiResponse<any>>(`/orgs/me/members/${uid}`),
+    testStorage: (storage: any) => api.post<ApiResponse<any>>('/orgs/me/storage/test', storage),
+    setStorage: (storage: any) => api.put<ApiResponse<any>>('/orgs/me/storage', storage),
+    removeStorage: () => api.delete<ApiResponse<any>>('/orgs/me/storage'),
+    updateBranding: (branding: { accentColor?: string; shareDomain?: string; watermarkDownloads?: boolean }) =>
+        api.put<ApiResponse<any>>('/orgs/me/branding', branding),
+    setBrandingLogo: (file: File) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        return api.put<ApiResponse<any>>('/orgs/me/branding/logo', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+    verifyShareDomain: () => api.post<ApiResponse<any>>('/orgs/me/branding/domain/verify'),
+    removeBranding: () => api.delete<ApiResponse<any>>('/orgs/me/branding'),
+    listLegalHolds: () => api.get<ApiResponse<any>>('/orgs/me/legal-holds'),
+    setLegalHold: (id: string, reason: string) => api.put<ApiResponse<any>>(`/orgs/me/legal-holds/${id}`, { reason }),
+    releaseLegalHold: (id: string) => api.delete<ApiResponse<any>>(`/orgs/me/legal-holds/${id}`),
 };
 
 // Document Conversion API
 export const conversionApi = {
-    convert: (files: File[], outputFormat: string) => {
+    // ZIPs are extracted; the response's entries report each file in them.
+    // spreadsheet lays out xls/xlsx files converted to PDF.
+    convert: (files: File[], outputFormat: string, spreadsheet?: {
+        orientation?: 'portrait' | 'landscape';
+        fitToWidth?: boolean;
+        gridlines?: boolean;
+        sheets?: string[];
+    }) => {
         const formData = new FormData();
         files.forEach(file => formData.append('files', file));
         formData.append('outputFormat', outputFormat);
+        if (spreadsheet?.orientation) formData.append('orientation', spreadsheet.orientation);
+        if (spreadsheet?.fitToWidth) formData.append('fitToWidth', 'true');
+        if (spreadsheet?.gridlines) formData.append('gridlines', 'true');
+        spreadsheet?.sheets?.forEach(sheet => formData.append('sheets', sheet));
         return api.post<ApiResponse<any>>('/convert', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
         });
     },
 
+    // Presentation to a PDF handout with note lines; follow it with status
+    handout: (file: File, slidesPerPage: 2 | 4 | 6 = 4, pageSize: 'A4' | 'Letter' = 'A4') => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('slidesPerPage', String(slidesPerPage));
+        formData.append('pageSize', pageSize);
+        return api.post<ApiResponse<any>>('/convert/handout', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+        });
+    },
+
     status: (jobId: string) =>
         api.get<ApiResponse<any>>(`/convert/status/${jobId}`),
 
@@ -277,15 +639,42 @@ export const conversionApi = {
         api.get<ApiResponse<any>>('/convert/formats'),
 };
 
+export const jobsApi = {
+    // Status of an /api/pdf operation sent with async=true
+    status: (jobId: string) =>
+        api.get<ApiResponse<any>>(`/jobs/${jobId}`),
+};
+
 export const shareApi = {
-    create: (fileId: string, fileType: string, expiresIn: number) =>
-        api.post<ApiResponse<any>>('/share', { fileId, fileType, expiresIn }),
+    // permission: 'download' (default) or 'view' for view-only PDF links
+    create: (fileId: string, fileType: string, expiresIn: number, acknowledgeSensitiveData: boolean = false, permission: 'download' | 'view' = 'download') =>
+        api.post<ApiResponse<any>>('/share', { fileId, fileType, expiresIn, acknowledgeSensitiveData, permission }),
     get: (code: string) => api.get<ApiResponse<any>>(`/share/${code}`),
+    // Page with Open Graph tags, for links pasted into chat and social apps
+    metaUrl: (code: string) => `${API_URL}/share/${code}/meta`,
+    // Reschedule a link, move its expiry (RFC 3339 times) or change its landing page
+    update: (code: string, changes: { activatesAt?: string; expiresAt?: string; title?: string; description?: string; coverPage?: number }) =>
+        api.patch<ApiResponse<any>>(`/share/${code}`, changes),
+    // Report a link for abuse; no sign-in needed
+    report: (code: string, reason: string, details?: string, email?: string) =>
+        api.post<ApiResponse<any>>('/report', { code, reason, details, email }),
+};
+
+export const preferencesApi = {
+    // Defaults for compress, watermark, page-numbers and share links
+    get: () => api.get<ApiResponse<any>>('/preferences'),
+    update: (preferences: any) => api.put<ApiResponse<any>>('/preferences', preferences),
+    reset: () => api.delete<ApiResponse<any>>('/preferences'),
 };
 
 export const paymentApi = {
     createOrder: (plan: string) => api.post<ApiResponse<any>>('/payment/order', { plan }),
     verifyPayment: (data: any) => api.post<ApiResponse<any>>('/payment/verify', data),
+    // Monthly billing: subscribe, confirm the first payment from checkout, cancel renewals
+    getSubscription: () => api.get<ApiResponse<any>>('/payment/subscription'),
+    createSubscription: (plan: string) => api.post<ApiResponse<any>>('/payment/subscription', { plan }),
+    verifySubscription: (data: any) => api.post<ApiResponse<any>>('/payment/subscription/verify', data),
+    cancelSubscription: () => api.delete<ApiResponse<any>>('/payment/subscription'),
 };
 
 export const adminApi = {
@@ -295,6 +684,33 @@ export const adminApi = {
     listDocuments: () => api.get<ApiResponse<any>>('/admin/documents'),
     updateUserRole: (uid: string, role: string) => api.post<ApiResponse<any>>(`/admin/users/${uid}/role`, { role }),
     updateUserPlan: (uid: string, plan: string) => api.post<ApiResponse<any>>(`/admin/users/${uid}/plan`, { plan }),
+    rotateUserKey: (uid: string) => api.post<ApiResponse<any>>(`/admin/encryption/users/${uid}/rotate`),
+    rewrapKeys: () => api.post<ApiResponse<any>>('/admin/encryption/rewrap'),
+    listLegalHolds: () => api.get<ApiResponse<any>>('/admin/legal-holds'),
+    getMaintenance: () => api.get<ApiResponse<any>>('/admin/maintenance'),
+    setMaintenance: (data: { enabled: boolean; message?: string; retryAfterSeconds?: number }) =>
+        api.put<ApiResponse<any>>('/admin/maintenance', data),
+    listStorageMigrations: () => api.get<ApiResponse<any>>('/admin/storage-migrations'),
+    getStorageMigration: (id: string) => api.get<ApiResponse<any>>(`/admin/storage-migrations/${id}`),
+    startStorageMigration: (data: {
+        sourceBucket: string;
+        target: { endpoint: string; region?: string; bucket: string; accessKey: string; secretKey: string; useSSL: boolean };
+    }) => api.post<ApiResponse<any>>('/admin/storage-migrations', data),
+    pauseStorageMigration: (id: string) => api.post<ApiResponse<any>>(`/admin/storage-migrations/${id}/pause`),
+    resumeStorageMigration: (id: string) => api.post<ApiResponse<any>>(`/admin/storage-migrations/${id}/resume`),
+    cutoverStorageMigration: (id: string) => api.post<ApiResponse<any>>(`/admin/storage-migrations/${id}/cutover`),
+    cancelStorageMigration: (id: string) => api.delete<ApiResponse<any>>(`/admin/storage-migrations/${id}`),
+    listBackups: () => api.get<ApiResponse<any>>('/admin/backups'),
+    getBackup: (id: string) => api.get<ApiResponse<any>>(`/admin/backups/${id}`),
+    startBackup: () => api.post<ApiResponse<any>>('/admin/backups'),
+    // Abuse review queue; status defaults to open, 'all' lists every report
+    listAbuseReports: (status?: string) => api.get<ApiResponse<any>>('/admin/abuse-reports', { params: { status } }),
+    resolveAbuseReport: (id: string, action: 'dismiss' | 'disable_share' | 'disable_user', note?: string) =>
+        api.post<ApiResponse<any>>(`/admin/abuse-reports/${id}/resolve`, { action, note }),
+    disableShare: (code: string, reason: string) => api.post<ApiResponse<any>>(`/admin/shares/${code}/disable`, { reason }),
+    enableShare: (code: string) => api.post<ApiResponse<any>>(`/admin/shares/${code}/enable`),
+    setUserSharing: (uid: string, enabled: boolean, reason?: string) =>
+        api.post<ApiResponse<any>>(`/admin/users/${uid}/sharing`, { enabled, reason }),
 };
 
 export default api;
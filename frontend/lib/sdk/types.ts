@@ -0,0 +1,694 @@
+// Code generated by cmd/sdkgen; DO NOT EDIT.
+
+export interface ApiError {
+    code: string;
+    message: string;
+    details?: string;
+}
+
+export interface ApiEnvelope<T> {
+    success: boolean;
+    data?: T;
+    error?: ApiError;
+    meta: { requestId: string; timestamp: string };
+}
+
+export interface Retention {
+    temporary: boolean;
+    expiresAt?: string;
+    plan?: string;
+    retentionDays?: number;
+    saveUrl?: string;
+    requiresSignIn?: boolean;
+}
+
+export interface OperationOutput {
+    fileId: string;
+    url: string;
+    filename: string;
+    pageCount: number;
+    size: number;
+    range?: string;
+    retention?: Retention;
+}
+
+export interface OperationResult {
+    operationId?: string;
+    operation: string;
+    outputs: OperationOutput[];
+    processingMs: number;
+}
+
+export interface SingleFileResult extends OperationResult, OperationOutput {
+}
+
+export interface MergeResult extends SingleFileResult {
+    inputFiles: number;
+}
+
+export interface FailedPart {
+    range: string;
+    filename: string;
+    attempts: number;
+    error: string;
+}
+
+export interface SplitResult extends OperationResult {
+    files: OperationOutput[];
+    totalFiles: number;
+    failedParts?: FailedPart[];
+    inputFile: string;
+    inputPages: number;
+    largeFileMode?: boolean;
+}
+
+export interface RotateResult extends SingleFileResult {
+    angle: number;
+    rotations?: Record<string, number>;
+    largeFileMode?: boolean;
+}
+
+export interface ImageProfile {
+    totalBytes: number;
+    imageBytes: number;
+    jpegBytes: number;
+    images: number;
+    jpegImages: number;
+    imageShare: number;
+    imageHeavy: boolean;
+    suggestedMode: string;
+}
+
+export interface PhotoCompression {
+    dpi: number;
+    jpegQuality: number;
+    imagesRecompressed: number;
+    imagesDownsampled: number;
+    imagesSkipped: number;
+    imageBytesBefore: number;
+    imageBytesAfter: number;
+    estimatedSavings: number;
+    estimatedReduction: string;
+}
+
+export interface CompressResult extends SingleFileResult {
+    originalSize: number;
+    compressedSize: number;
+    reduction: string;
+    quality: string;
+    mode: string;
+    largeFileMode?: boolean;
+    imageProfile?: ImageProfile;
+    photo?: PhotoCompression;
+}
+
+export interface CompressEstimate {
+    filename: string;
+    mode: string;
+    quality: string;
+    originalSize: number;
+    estimatedSize: number;
+    estimatedReduction: string;
+    imageProfile?: ImageProfile;
+    photo?: PhotoCompression;
+    dryRun: boolean;
+}
+
+export interface CropMargins {
+    top: number;
+    right: number;
+    bottom: number;
+    left: number;
+}
+
+export interface CropResult extends SingleFileResult {
+    margins: CropMargins;
+}
+
+export interface WatermarkSettings {
+    type: string;
+    text: string;
+    position: string;
+    opacity: number;
+    fontSize: number;
+    color: string;
+    font: string;
+    mode: string;
+    rotation: number;
+    tile: boolean;
+    scale?: number;
+}
+
+export interface WatermarkResult extends SingleFileResult {
+    watermark: WatermarkSettings;
+}
+
+export interface PageNumberSettings {
+    position: string;
+    format: string;
+    startFrom: number;
+    style: string;
+    frontMatter: number;
+    skip?: string;
+    mirror: boolean;
+}
+
+export interface PageNumbersResult extends SingleFileResult {
+    settings: PageNumberSettings;
+}
+
+export interface ReorderResult extends SingleFileResult {
+    originalPages: number;
+    newOrder: number[];
+}
+
+export interface RemovePagesResult extends SingleFileResult {
+    originalPages: number;
+    pagesRemoved: number;
+    removedPages: string;
+    largeFileMode?: boolean;
+}
+
+export interface ExtractResult extends SingleFileResult {
+    originalPages: number;
+    extractedPages: string;
+    largeFileMode?: boolean;
+}
+
+export interface PipelineStepResult {
+    operation: string;
+    pageCount: number;
+    size: number;
+    processingMs: number;
+}
+
+export interface PipelineResult extends SingleFileResult {
+    inputSize: number;
+    steps: PipelineStepResult[];
+}
+
+export interface TextPlacement {
+    text: string;
+    x: number;
+    y: number;
+    fontSize: number;
+    color: string;
+    font: string;
+    pages?: string;
+    width?: number;
+    align: string;
+    rotation: number;
+    unit: string;
+}
+
+export interface DrawTextResult extends SingleFileResult {
+    placements: TextPlacement[];
+}
+
+export interface PageGeometry {
+    page: number;
+    width: number;
+    height: number;
+    rotation: number;
+}
+
+export interface PageLayoutResult {
+    fileId: string;
+    filename: string;
+    pageCount: number;
+    unit: string;
+    pages: PageGeometry[];
+}
+
+export interface TextRect {
+    x: number;
+    y: number;
+    width: number;
+    height: number;
+}
+
+export interface SearchMatch {
+    page: number;
+    text: string;
+    context: string;
+    rects: TextRect[];
+}
+
+export interface SearchResult {
+    fileId?: string;
+    query: string;
+    pageCount: number;
+    totalMatches: number;
+    truncated: boolean;
+    matches: SearchMatch[];
+}
+
+export interface Heading {
+    title: string;
+    level: number;
+    page: number;
+    y: number;
+    fontSize: number;
+    source: string;
+}
+
+export interface StructureResult extends OperationResult {
+    pageCount: number;
+    bodyFontSize: number;
+    existingBookmarks: number;
+    aiAssisted: boolean;
+    bookmarksWritten: boolean;
+    headings: Heading[];
+}
+
+export interface SanitizeReport {
+    documentInfo: string[];
+    xmpMetadata: number;
+    javaScript: number;
+    actions: number;
+    xfa: boolean;
+    attachments: string[];
+    hiddenLayers: string[];
+    privateData: number;
+    thumbnails: number;
+    revisions: number;
+}
+
+export interface SanitizeResult extends SingleFileResult {
+    originalSize: number;
+    removed: SanitizeReport;
+}
+
+export interface InvertSettings {
+    background: string;
+    foreground: string;
+    images: string;
+}
+
+export interface InvertResult extends SingleFileResult {
+    settings: InvertSettings;
+}
+
+export interface ProtectResult extends SingleFileResult {
+    ownerPassword: boolean;
+    restrictions: string[];
+}
+
+export interface UnlockResult extends SingleFileResult {
+}
+
+export interface PageThumbnail {
+    page: number;
+    width: number;
+    height: number;
+    dpi: number;
+}
+
+export interface ThumbnailsResult extends OperationResult {
+    format: string;
+    pages: PageThumbnail[];
+}
+
+export interface PreflightIssue {
+    check: string;
+    severity: string;
+    message: string;
+    pages?: number[];
+}
+
+export interface PreflightImage {
+    page: number;
+    name: string;
+    width: number;
+    height: number;
+    dpi: number;
+    colorSpace: string;
+}
+
+export interface PreflightFont {
+    name: string;
+    type: string;
+    embedded: boolean;
+    pages: number[];
+}
+
+export interface PreflightReport {
+    passed: boolean;
+    minDpi: number;
+    bleedMm: number;
+    colorSpaces: string[];
+    transparency: boolean;
+    images: PreflightImage[];
+    fonts: PreflightFont[];
+    issues: PreflightIssue[];
+}
+
+export interface PreflightResult extends PreflightReport {
+    fileId?: string;
+    pageCount: number;
+}
+
+export interface ScanFinding {
+    type: string;
+    severity: string;
+    trigger?: string;
+    detail?: string;
+}
+
+export interface ScanReport {
+    risk: string;
+    safe: boolean;
+    javaScript: number;
+    launchActions: number;
+    uris: string[];
+    embeddedExecutables: string[];
+    findings: ScanFinding[];
+}
+
+export interface ScanResult extends ScanReport {
+    fileId?: string;
+    pageCount: number;
+}
+
+export interface ScanPoint {
+    x: number;
+    y: number;
+}
+
+export interface ScannedPage {
+    page: number;
+    correction: string;
+    corners: ScanPoint[];
+    width: number;
+    height: number;
+}
+
+export interface DocumentScanResult extends SingleFileResult {
+    mode: string;
+    pageSize: string;
+    pages: ScannedPage[];
+}
+
+export interface DetectedDocument {
+    firstPage: number;
+    lastPage: number;
+    range: string;
+    documentType?: string;
+    vendor?: string;
+    date?: string;
+    confidence: number;
+    source: string;
+    signals?: string[];
+    filename?: string;
+    fileId?: string;
+}
+
+export interface AutoSplitResult extends OperationResult {
+    files: OperationOutput[];
+    totalFiles: number;
+    failedParts?: FailedPart[];
+    inputFile: string;
+    inputPages: number;
+    aiAssisted: boolean;
+    dryRun?: boolean;
+    documents: DetectedDocument[];
+}
+
+export interface ContractParty {
+    name: string;
+    role?: string;
+    pages: number[];
+}
+
+export interface ContractDate {
+    label: string;
+    date: string;
+    pages: number[];
+}
+
+export interface ContractClause {
+    title: string;
+    summary: string;
+    risk: string;
+    quote?: string;
+    pages: number[];
+}
+
+export interface ContractReview {
+    pageCount: number;
+    riskLevel: string;
+    summary: string;
+    risks: string[];
+    parties: ContractParty[];
+    dates: ContractDate[];
+    renewal: ContractClause[];
+    termination: ContractClause[];
+    liabilities: ContractClause[];
+    unusualTerms: ContractClause[];
+}
+
+export interface TextChange {
+    type: string;
+    before?: string;
+    after?: string;
+    pageBefore: number;
+    pageAfter: number;
+}
+
+export interface MaterialChange {
+    title: string;
+    description: string;
+    impact: string;
+    pages: number[];
+}
+
+export interface DiffSummary {
+    pageCountBefore: number;
+    pageCountAfter: number;
+    identical: boolean;
+    added: number;
+    removed: number;
+    changed: number;
+    summary: string;
+    materialChanges: MaterialChange[];
+    changes: TextChange[];
+    truncated: boolean;
+}
+
+export interface ReadAloudResult extends OperationResult {
+    mode: string;
+    voice: string;
+    characters: number;
+    pages: number[];
+    skippedPages: number[];
+}
+
+export interface RedactionFinding {
+    page: number;
+    type: string;
+    value: string;
+    rects: TextRect[];
+}
+
+export interface RedactionReport {
+    findings: RedactionFinding[];
+    total: number;
+    types: Record<string, number>;
+    pages: number[];
+    removedGlyphs: number;
+    removedAnnotations: number;
+    aiAssisted: boolean;
+    aiReviewedPages?: number;
+}
+
+export interface RedactResult extends SingleFileResult {
+    redactions: RedactionReport;
+}
+
+export interface LibraryNote {
+    id: string;
+    documentId: string;
+    source: string;
+    text: string;
+    page?: number;
+    language?: string;
+    audioName?: string;
+    audioSize?: number;
+    audioUrl?: string;
+    duration?: number;
+    createdAt: string;
+}
+
+export interface WorkspaceEdit {
+    type: string;
+    pages?: number[];
+    angle?: number;
+    order?: number[];
+}
+
+export interface WorkspacePage {
+    source: number;
+    rotation: number;
+}
+
+export interface WorkspaceCommitResult extends SingleFileResult {
+    workspaceId: string;
+    editsApplied: number;
+}
+
+export interface Signature {
+    id: string;
+    name: string;
+    kind: string;
+    width: number;
+    height: number;
+    size: number;
+    hasTransparency: boolean;
+    url?: string;
+    createdAt: string;
+    updatedAt: string;
+}
+
+export interface StampResult extends SingleFileResult {
+    signatureId: string;
+    stampedPages: string;
+}
+
+export interface OperationLog {
+    id: string;
+    operation: string;
+    inputFiles: string[];
+    outputFileId?: string;
+    outputFiles?: string[];
+    outputs?: OperationOutput[];
+    result?: unknown;
+    pageCount?: number;
+    inputBytes?: number;
+    status: string;
+    errorMessage?: string;
+    processingMs: number;
+    requestId?: string;
+    createdAt: string;
+}
+
+export interface OrgPolicy {
+    maxShareExpiryHours: number;
+    externalSharingDisabled: boolean;
+    aiDisabledForConfidential: boolean;
+    sharePiiPolicy?: string;
+}
+
+export interface OrgStorage {
+    endpoint: string;
+    region?: string;
+    bucket: string;
+    accessKey: string;
+    useSSL: boolean;
+    updatedAt: string;
+}
+
+export interface OrgMember {
+    userId: string;
+    email: string;
+    role: string;
+    joinedAt: string;
+}
+
+export interface Organization {
+    id: string;
+    name: string;
+    members: OrgMember[];
+    policy: OrgPolicy;
+    storage?: OrgStorage;
+    branding?: OrgBranding;
+    createdAt: string;
+    updatedAt: string;
+}
+
+export interface LegalHold {
+    orgId: string;
+    setBy: string;
+    reason?: string;
+    setAt: string;
+}
+
+export interface HeldItem {
+    id: string;
+    kind: string;
+    ownerUid: string;
+    fileName: string;
+    size: number;
+    legalHold?: LegalHold;
+}
+
+export interface StoredFile {
+    id: string;
+    kind: string;
+    fileName: string;
+    size: number;
+    documentId?: string;
+    createdAt: string;
+}
+
+export interface StorageCategory {
+    bytes: number;
+    files: number;
+}
+
+export interface StorageBreakdown {
+    used: number;
+    limit: number;
+    grace: number;
+    library: StorageCategory;
+    outputs: StorageCategory;
+    voiceNotes: StorageCategory;
+    versions: StorageCategory;
+    trash: StorageCategory;
+    largest: StoredFile[];
+}
+
+export interface ActivityEntry {
+    id: string;
+    action: string;
+    ip?: string;
+    userAgent?: string;
+    details?: Record<string, unknown>;
+    createdAt: string;
+}
+
+export interface MaintenanceState {
+    enabled: boolean;
+    message?: string;
+    retryAfterSeconds: number;
+    startedBy?: string;
+    startedAt?: string;
+    updatedAt: string;
+}
+
+export interface StorageMigrationTarget {
+    endpoint: string;
+    region?: string;
+    bucket: string;
+    accessKey: string;
+    useSSL: boolean;
+}
+
+export interface StorageMigration {
+    id: string;
+    sourceBucket: string;
+    target: StorageMigrationTarget;
+    status: string;
+    paused: boolean;
+    error?: string;
+    lastKey?: string;
+    syncKey?: string;
+    copied: number;
+    copiedBytes: number;
+    resynced: number;
+    rewritten: number;
+    startedBy: string;
+    createdAt: string;
+    updatedAt: string;
+    completedAt?: string;
+}
+
@@ -0,0 +1,58 @@
+// Package assets embeds the files the binaries need at runtime, so
+// cmd/server and cmd/worker deploy as a single file: TrueType fonts for
+// drawing text beyond the PDF standard fonts, and the badge images.
+package assets
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed fonts/*.ttf fonts/LICENSE badges/*.png
+var files embed.FS
+
+// ErrNotFound is returned for names no embedded asset has
+var ErrNotFound = errors.New("asset not found")
+
+// Fonts returns the embedded TrueType fonts by file name, e.g. Go-Bold.ttf
+func Fonts() map[string][]byte {
+	return readDir("fonts", ".ttf")
+}
+
+// Badge returns the PNG image of the named badge, e.g. gold
+func Badge(name string) ([]byte, error) {
+	data, err := files.ReadFile(path.Join("badges", name+".png"))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// Badges returns the names of the embedded badges, sorted
+func Badges() []string {
+	var names []string
+	for file := range readDir("badges", ".png") {
+		names = append(names, strings.TrimSuffix(file, ".png"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readDir returns the files in dir with the extension ext by name
+func readDir(dir, ext string) map[string][]byte {
+	entries, _ := fs.ReadDir(files, dir)
+	out := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		if path.Ext(e.Name()) != ext {
+			continue
+		}
+		if data, err := files.ReadFile(path.Join(dir, e.Name())); err == nil {
+			out[e.Name()] = data
+		}
+	}
+	return out
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -34,23 +37,144 @@ type Config struct {
 	// OpenRouter AI
 	OpenRouterAPIKey string
 
+	// Speech-to-text for voice notes (any Whisper-compatible API)
+	TranscriptionAPIURL string
+	TranscriptionAPIKey string
+	TranscriptionModel  string
+
+	// Text-to-speech for read-aloud (any OpenAI-compatible speech API)
+	TTSAPIURL string
+	TTSAPIKey string
+	TTSModel  string
+	TTSVoice  string
+
+	// Page rendering for thumbnails (poppler's pdftoppm; found on PATH when empty)
+	PdftoppmPath string
+
 	// Temporary files
 	TempFileTTLHours int
 
+	// Uploads larger than this are spooled to disk and processed file-to-file
+	LargeFileThresholdMB int
+
+	// Job queue: "memory" (single instance), or "mongo" or "redis" (shared
+	// across instances)
+	QueueBackend                  string
+	RedisURL                      string // for the redis queue backend
+	QueueVisibilityTimeoutSeconds int
+	QueueMaxWaitSeconds           int // jobs waiting longer are served ahead of every lane
+	QueueReservedWorkers          int // workers per service kept for paid lanes
+	ConversionWorkers             int
+	SummaryWorkers                int
+	PDFJobWorkers                 int // async /api/pdf jobs; always run on API instances
+	APIRunWorkers                 bool   // false when dedicated cmd/worker processes handle jobs
+	WorkerHealthPort              string
+
+	// Resource watchdog: heavy jobs are refused above these limits (0 disables)
+	MaxTempDiskMB int
+	MaxRSSMB      int
+	// Temp files one PDF operation or conversion job may write (0 disables)
+	TempJobQuotaMB int
+
 	// CORS
 	CORSAllowedOrigins []string
 
 	// Share links
 	ServerHost string
+	// Refuse to share PDFs whose security scan finds high-risk content
+	ShareBlockUnsafePDFs bool
+	// What to do when a shared PDF contains SSNs, card or Aadhaar numbers:
+	// off, warn (share after the user acknowledges) or block
+	SharePIIPolicy string
+	// Characters and length of generated share codes
+	ShareCodeAlphabet string
+	ShareCodeLength   int
+	// Host organizations' share domains must be a CNAME for (empty disables
+	// custom domains), and whether those domains are served over HTTPS
+	ShareDomainTarget string
+	ShareDomainHTTPS  bool
+	// Repeat views and downloads of a link by the same viewer within this
+	// many minutes count once
+	ShareCountWindowMinutes int
+
+	// Encryption at rest: base64 32-byte master key wrapping per-user data
+	// keys (empty disables), former master keys kept for rotation, and
+	// whether files stored unencrypted can still be read while migrating
+	EncryptionMasterKey          string
+	EncryptionPreviousMasterKeys []string
+	EncryptionAllowPlaintext     bool
+
+	// Let organizations use storage endpoints on private networks
+	OrgStorageAllowPrivate bool
+
+	// SIEM log forwarding: transport "http" or "syslog" (empty disables),
+	// endpoint URL or host:port, and batching
+	SIEMTransport            string
+	SIEMEndpoint             string
+	SIEMSyslogProtocol       string
+	SIEMAuthHeader           string
+	SIEMBatchSize            int
+	SIEMFlushIntervalSeconds int
+	SIEMBufferSize           int
+
+	// Virus scanning of stored files: scanner "clamav" (empty disables),
+	// the clamd address and how long one scan may take
+	VirusScanner            string
+	ClamAVAddress           string
+	VirusScanTimeoutSeconds int
+
+	// Entity index of library documents; EntityIndexAI also has the AI model
+	// name people and companies, at a model call per document
+	EntityIndexEnabled bool
+	EntityIndexAI      bool
+
+	// Cold storage: library files unopened for ArchiveAfterMonths (0
+	// disables) move to ArchiveBucket and/or ArchiveStorageClass
+	ArchiveAfterMonths  int
+	ArchiveBucket       string
+	ArchiveStorageClass string
+
+	// Bucket disaster-recovery backups are written to
+	BackupBucket string
+
+	// Read replicas of the user files bucket by region. Requests are
+	// mapped to a region by StorageRegionHeader, holding a region name or
+	// a country code looked up in StorageReplicaCountries.
+	StorageReplicas         []StorageReplica
+	StorageReplicaCountries map[string]string
+	StorageRegionHeader     string
 
 	// Razorpay
 	RazorpayKeyID     string
 	RazorpayKeySecret string
+
+	// Runtime: PIDFile is written at startup and removed on exit.
+	// DisabledFeatures names capabilities switched off by the operator and
+	// PlanLimitsFile overrides the built-in plan limits; both, with the
+	// CORS origins, are re-applied on SIGHUP.
+	PIDFile          string
+	DisabledFeatures []string
+	PlanLimitsFile   string
+}
+
+// StorageReplica is an S3-compatible bucket kept as a copy of the user
+// files bucket for downloads from Region
+type StorageReplica struct {
+	Region    string
+	Endpoint  string
+	AWSRegion string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Bucket    string
 }
 
 // Global config instance
 var AppConfig *Config
 
+// envFile is the .env file Load read, if any; Reload reads it again
+var envFile string
+
 // Load initializes configuration from environment variables
 func Load() *Config {
 	// Load .env file if it exists
@@ -62,10 +186,46 @@ func Load() *Config {
 		if err := godotenv.Load("/etc/secrets/.env"); err != nil {
 			log.Printf("No .env file found in /etc/secrets/ or error loading it: %v", err)
 		} else {
+			envFile = "/etc/secrets/.env"
 			log.Println("Successfully loaded .env from /etc/secrets/")
 		}
+	} else {
+		envFile = filepath.Join(cwd, ".env")
 	}
 
+	config := fromEnv()
+	if err := LoadPlanLimits(config.PlanLimitsFile); err != nil {
+		log.Printf("Warning: %v; using the built-in plan limits", err)
+	}
+	AppConfig = config
+	return config
+}
+
+// Reload re-reads the .env file Load found, letting its values replace
+// the environment's, reloads the plan limits file and returns the new
+// configuration. Most settings are only read at startup; the caller
+// re-applies those that can change while running. On error the plan
+// limits in use are kept.
+func Reload() (*Config, error) {
+	if envFile != "" {
+		if err := godotenv.Overload(envFile); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", envFile, err)
+		}
+	}
+	config := fromEnv()
+	if err := LoadPlanLimits(config.PlanLimitsFile); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// EnvFile returns the .env file the configuration was read from, or ""
+func EnvFile() string {
+	return envFile
+}
+
+// fromEnv builds the configuration from environment variables
+func fromEnv() *Config {
 	config := &Config{
 		// Server
 		Port:    getEnv("PORT", "8080"),
@@ -90,9 +250,43 @@ func Load() *Config {
 		// OpenRouter AI
 		OpenRouterAPIKey: getEnv("OPENROUTER_API_KEY", ""),
 
+		// Speech-to-text
+		TranscriptionAPIURL: getEnv("TRANSCRIPTION_API_URL", "https://api.openai.com/v1/audio/transcriptions"),
+		TranscriptionAPIKey: getEnv("TRANSCRIPTION_API_KEY", ""),
+		TranscriptionModel:  getEnv("TRANSCRIPTION_MODEL", "whisper-1"),
+
+		// Text-to-speech
+		TTSAPIURL: getEnv("TTS_API_URL", "https://api.openai.com/v1/audio/speech"),
+		TTSAPIKey: getEnv("TTS_API_KEY", ""),
+		TTSModel:  getEnv("TTS_MODEL", "tts-1"),
+		TTSVoice:  getEnv("TTS_VOICE", "alloy"),
+
+		// Page rendering
+		PdftoppmPath: getEnv("PDFTOPPM_PATH", ""),
+
 		// Temporary files
 		TempFileTTLHours: getEnvInt("TEMP_FILE_TTL_HOURS", 2),
 
+		// Large-file mode
+		LargeFileThresholdMB: getEnvInt("LARGE_FILE_THRESHOLD_MB", 64),
+
+		// Job queue
+		QueueBackend:                  getEnv("QUEUE_BACKEND", "memory"),
+		RedisURL:                      getEnv("REDIS_URL", ""),
+		QueueVisibilityTimeoutSeconds: getEnvInt("QUEUE_VISIBILITY_TIMEOUT_SECONDS", 600),
+		QueueMaxWaitSeconds:           getEnvInt("QUEUE_MAX_WAIT_SECONDS", 300),
+		QueueReservedWorkers:          getEnvInt("QUEUE_RESERVED_WORKERS", 1),
+		ConversionWorkers:             getEnvInt("CONVERSION_WORKERS", 4),
+		SummaryWorkers:                getEnvInt("SUMMARY_WORKERS", 2),
+		PDFJobWorkers:                 getEnvInt("PDF_JOB_WORKERS", 2),
+		APIRunWorkers:                 getEnvBool("API_RUN_WORKERS", true),
+		WorkerHealthPort:              getEnv("WORKER_HEALTH_PORT", "8081"),
+
+		// Resource watchdog
+		MaxTempDiskMB:  getEnvInt("MAX_TEMP_DISK_MB", 4096),
+		MaxRSSMB:       getEnvInt("MAX_RSS_MB", 2048),
+		TempJobQuotaMB: getEnvInt("TEMP_JOB_QUOTA_MB", 2048),
+
 		// CORS
 	}
 
@@ -106,6 +300,73 @@ func Load() *Config {
 
 	// Share links - should point to frontend for /s/[code] route
 	config.ServerHost = getEnv("SERVER_HOST", "http://localhost:3000")
+	config.ShareBlockUnsafePDFs = getEnvBool("SHARE_BLOCK_UNSAFE_PDFS", false)
+	config.SharePIIPolicy = strings.ToLower(getEnv("SHARE_PII_POLICY", "off"))
+	switch config.SharePIIPolicy {
+	case "off", "warn", "block":
+	default:
+		log.Printf("Warning: unknown SHARE_PII_POLICY %q, sharing without sensitive data checks", config.SharePIIPolicy)
+		config.SharePIIPolicy = "off"
+	}
+	config.ShareCodeAlphabet = getEnv("SHARE_CODE_ALPHABET", DefaultShareCodeAlphabet)
+	if !validShareCodeAlphabet(config.ShareCodeAlphabet) {
+		log.Printf("Warning: SHARE_CODE_ALPHABET needs at least 2 distinct ASCII letters or digits, using the default")
+		config.ShareCodeAlphabet = DefaultShareCodeAlphabet
+	}
+	config.ShareCodeLength = getEnvInt("SHARE_CODE_LENGTH", 8)
+	if config.ShareCodeLength < 4 || config.ShareCodeLength > 32 {
+		log.Printf("Warning: SHARE_CODE_LENGTH %d is outside 4-32, using 8", config.ShareCodeLength)
+		config.ShareCodeLength = 8
+	}
+	config.ShareDomainTarget = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(getEnv("SHARE_DOMAIN_TARGET", ""))), ".")
+	config.ShareDomainHTTPS = getEnvBool("SHARE_DOMAIN_HTTPS", true)
+	config.ShareCountWindowMinutes = getEnvInt("SHARE_COUNT_WINDOW_MINUTES", 30)
+	if config.ShareCountWindowMinutes < 1 {
+		log.Printf("Warning: SHARE_COUNT_WINDOW_MINUTES must be at least 1, using 30")
+		config.ShareCountWindowMinutes = 30
+	}
+
+	// Encryption at rest
+	config.EncryptionMasterKey = getEnv("ENCRYPTION_MASTER_KEY", "")
+	for _, k := range strings.Split(getEnv("ENCRYPTION_PREVIOUS_MASTER_KEYS", ""), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			config.EncryptionPreviousMasterKeys = append(config.EncryptionPreviousMasterKeys, k)
+		}
+	}
+	config.EncryptionAllowPlaintext = getEnvBool("ENCRYPTION_ALLOW_PLAINTEXT", false)
+
+	config.OrgStorageAllowPrivate = getEnvBool("ORG_STORAGE_ALLOW_PRIVATE", false)
+
+	// SIEM log forwarding
+	config.SIEMTransport = strings.ToLower(getEnv("SIEM_TRANSPORT", ""))
+	config.SIEMEndpoint = getEnv("SIEM_ENDPOINT", "")
+	config.SIEMSyslogProtocol = strings.ToLower(getEnv("SIEM_SYSLOG_PROTOCOL", "tcp"))
+	config.SIEMAuthHeader = getEnv("SIEM_AUTH_HEADER", "")
+	config.SIEMBatchSize = getEnvInt("SIEM_BATCH_SIZE", 100)
+	config.SIEMFlushIntervalSeconds = getEnvInt("SIEM_FLUSH_INTERVAL_SECONDS", 5)
+	config.SIEMBufferSize = getEnvInt("SIEM_BUFFER_SIZE", 10000)
+
+	// Virus scanning
+	config.VirusScanner = strings.ToLower(getEnv("VIRUS_SCANNER", ""))
+	config.ClamAVAddress = getEnv("CLAMAV_ADDRESS", "localhost:3310")
+	config.VirusScanTimeoutSeconds = getEnvInt("VIRUS_SCAN_TIMEOUT_SECONDS", 120)
+
+	// Entity index
+	config.EntityIndexEnabled = getEnvBool("ENTITY_INDEX_ENABLED", true)
+	config.EntityIndexAI = getEnvBool("ENTITY_INDEX_AI", false)
+
+	// Cold storage
+	config.ArchiveAfterMonths = getEnvInt("ARCHIVE_AFTER_MONTHS", 0)
+	config.ArchiveBucket = getEnv("ARCHIVE_BUCKET", "")
+	config.ArchiveStorageClass = strings.ToUpper(getEnv("ARCHIVE_STORAGE_CLASS", ""))
+
+	// Backups
+	config.BackupBucket = getEnv("BACKUP_BUCKET", "backups")
+
+	// Regional read replicas
+	config.StorageReplicas = parseStorageReplicas(getEnv("STORAGE_REPLICAS", ""), config)
+	config.StorageReplicaCountries = parseReplicaCountries(getEnv("STORAGE_REPLICA_COUNTRIES", ""))
+	config.StorageRegionHeader = getEnv("STORAGE_REGION_HEADER", "CF-IPCountry")
 
 	// Fix common misconfiguration where SERVER_HOST is set to backend port
 	if strings.Contains(config.ServerHost, ":8080") && config.Port == "8080" {
@@ -113,7 +374,12 @@ func Load() *Config {
 		config.ServerHost = strings.Replace(config.ServerHost, ":8080", ":3000", 1)
 	}
 
-	AppConfig = config
+	// Runtime
+	config.PIDFile = getEnv("PID_FILE", "")
+	config.PlanLimitsFile = getEnv("PLAN_LIMITS_FILE", "")
+	for _, name := range parseCORSOrigins(getEnv("DISABLED_FEATURES", "")) {
+		config.DisabledFeatures = append(config.DisabledFeatures, strings.ToLower(name))
+	}
 	return config
 }
 
@@ -130,6 +396,55 @@ func parseCORSOrigins(raw string) []string {
 	return cleaned
 }
 
+// parseStorageReplicas parses comma-separated replicas of the form
+// region=https://[key:secret@]endpoint[/bucket][?region=aws-region].
+// Credentials default to MinIO's and the bucket to the user files bucket;
+// invalid entries are skipped with a warning.
+func parseStorageReplicas(raw string, cfg *Config) []StorageReplica {
+	var replicas []StorageReplica
+	for _, entry := range parseCORSOrigins(raw) {
+		region, endpoint, ok := strings.Cut(entry, "=")
+		region = strings.ToLower(strings.TrimSpace(region))
+		u, err := url.Parse(strings.TrimSpace(endpoint))
+		if !ok || region == "" || err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Printf("Warning: ignoring invalid STORAGE_REPLICAS entry %q", entry)
+			continue
+		}
+		r := StorageReplica{
+			Region:    region,
+			Endpoint:  u.Host,
+			AWSRegion: u.Query().Get("region"),
+			AccessKey: cfg.MinIOAccessKey,
+			SecretKey: cfg.MinIOSecretKey,
+			UseSSL:    u.Scheme == "https",
+			Bucket:    strings.Trim(u.Path, "/"),
+		}
+		if u.User != nil {
+			r.AccessKey = u.User.Username()
+			r.SecretKey, _ = u.User.Password()
+		}
+		if r.Bucket == "" {
+			r.Bucket = cfg.MinIOBucketUserFiles
+		}
+		replicas = append(replicas, r)
+	}
+	return replicas
+}
+
+// parseReplicaCountries parses comma-separated COUNTRY=region pairs
+func parseReplicaCountries(raw string) map[string]string {
+	countries := make(map[string]string)
+	for _, entry := range parseCORSOrigins(raw) {
+		country, region, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Printf("Warning: ignoring invalid STORAGE_REPLICA_COUNTRIES entry %q", entry)
+			continue
+		}
+		countries[strings.ToUpper(strings.TrimSpace(country))] = strings.ToLower(strings.TrimSpace(region))
+	}
+	return countries
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -158,3 +473,30 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// LargeFileThreshold returns the upload size in bytes above which PDF
+// operations switch to disk-backed processing
+func LargeFileThreshold() int64 {
+	mb := 64
+	if AppConfig != nil && AppConfig.LargeFileThresholdMB > 0 {
+		mb = AppConfig.LargeFileThresholdMB
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+// DefaultShareCodeAlphabet leaves out characters that are easily confused
+// when read aloud or retyped (0/o, 1/l/i)
+const DefaultShareCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// validShareCodeAlphabet reports whether alphabet can build share codes
+// that are safe in URLs
+func validShareCodeAlphabet(alphabet string) bool {
+	seen := map[rune]bool{}
+	for _, r := range alphabet {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') || seen[r] {
+			return false
+		}
+		seen[r] = true
+	}
+	return len(seen) >= 2
+}
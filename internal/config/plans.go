@@ -1,5 +1,12 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
 type PlanLimits struct {
 	MaxFileSize     int64 // Max single file size in bytes
 	StorageLimit    int64 // Total storage limit in bytes
@@ -7,9 +14,20 @@ type PlanLimits struct {
 	ToolkitOpsLimit int
 	MaxActiveLinks  int
 	RetentionDays   int
+	// Overage allowed past StorageLimit, in percent, so a single upload
+	// doesn't fail right at the boundary
+	StorageGracePercent int
+	// Share links may use a custom slug instead of a generated code
+	VanityShareLinks bool
+	// Organizations may brand share pages and downloads with their logo,
+	// accent color and domain
+	WhiteLabelBranding bool
+	// Job queue lane: standard, priority or express (served first)
+	QueueLane string
 }
 
-// Plans defines storage and feature limits for each subscription tier
+// Plans defines the built-in storage and feature limits for each
+// subscription tier, which PLAN_LIMITS_FILE may override
 // Based on pricing: Free, Student (₹99), Pro (₹299), Plus (₹699)
 var Plans = map[string]PlanLimits{
 	"free": {
@@ -19,6 +37,7 @@ var Plans = map[string]PlanLimits{
 		ToolkitOpsLimit: 5,
 		MaxActiveLinks:  0,                 // No sharing for free
 		RetentionDays:   1,
+		QueueLane:       "standard",
 	},
 	"student": {
 		MaxFileSize:     25 * 1024 * 1024,  // 25 MB max file
@@ -27,6 +46,8 @@ var Plans = map[string]PlanLimits{
 		ToolkitOpsLimit: 30,
 		MaxActiveLinks:  5,
 		RetentionDays:   7,
+		StorageGracePercent: 10,
+		QueueLane:       "priority",
 	},
 	"pro": {
 		MaxFileSize:     100 * 1024 * 1024,  // 100 MB max file
@@ -35,6 +56,9 @@ var Plans = map[string]PlanLimits{
 		ToolkitOpsLimit: 1000000, // Unlimited
 		MaxActiveLinks:  50,
 		RetentionDays:   30,
+		StorageGracePercent: 10,
+		VanityShareLinks: true,
+		QueueLane:       "priority",
 	},
 	"plus": {
 		MaxFileSize:     300 * 1024 * 1024,  // 300 MB max file
@@ -43,6 +67,9 @@ var Plans = map[string]PlanLimits{
 		ToolkitOpsLimit: 1000000,
 		MaxActiveLinks:  1000000,
 		RetentionDays:   180, // 6 months
+		StorageGracePercent: 10,
+		VanityShareLinks: true,
+		QueueLane:       "express",
 	},
 	"business": {
 		MaxFileSize:     1024 * 1024 * 1024, // 1 GB max file
@@ -51,21 +78,161 @@ var Plans = map[string]PlanLimits{
 		ToolkitOpsLimit: 1000000,
 		MaxActiveLinks:  1000000,
 		RetentionDays:   365,
+		StorageGracePercent: 10,
+		VanityShareLinks: true,
+		WhiteLabelBranding: true,
+		QueueLane:       "express",
 	},
 }
 
+// PlanPrices are the monthly prices of the paid plans in paise (INR),
+// charged once by orders and every month by subscriptions
+var PlanPrices = map[string]int64{
+	"student":  9900,   // ₹99
+	"pro":      29900,  // ₹299
+	"plus":     69900,  // ₹699
+	"business": 199900, // ₹1999
+}
+
+// UnlimitedQuota is the sentinel used in Plans for features without a cap
+const UnlimitedQuota = 1000000
+
+// plans holds the limits in effect: Plans with the overrides of the plan
+// limits file applied
+var (
+	plansMu sync.RWMutex
+	plans   = Plans
+)
+
+// PlanOverride changes some of a plan's limits; unset fields keep the
+// built-in value. Sizes are in MB.
+type PlanOverride struct {
+	MaxFileSizeMB       *int64  `json:"maxFileSizeMB"`
+	StorageLimitMB      *int64  `json:"storageLimitMB"`
+	AIChatsLimit        *int    `json:"aiChatsLimit"`
+	ToolkitOpsLimit     *int    `json:"toolkitOpsLimit"`
+	MaxActiveLinks      *int    `json:"maxActiveLinks"`
+	RetentionDays       *int    `json:"retentionDays"`
+	StorageGracePercent *int    `json:"storageGracePercent"`
+	VanityShareLinks    *bool   `json:"vanityShareLinks"`
+	WhiteLabelBranding  *bool   `json:"whiteLabelBranding"`
+	QueueLane           *string `json:"queueLane"`
+}
+
+// LoadPlanLimits applies the overrides in path, a JSON object of
+// PlanOverride by plan name, to the built-in plans. An empty path restores
+// the built-in limits. On error the limits in effect are kept.
+func LoadPlanLimits(path string) error {
+	next := make(map[string]PlanLimits, len(Plans))
+	for name, limits := range Plans {
+		next[name] = limits
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read plan limits: %w", err)
+		}
+		var overrides map[string]PlanOverride
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return fmt.Errorf("invalid plan limits in %s: %w", path, err)
+		}
+		for name, o := range overrides {
+			limits, ok := next[name]
+			if !ok {
+				return fmt.Errorf("invalid plan limits in %s: unknown plan %q", path, name)
+			}
+			if err := o.apply(&limits); err != nil {
+				return fmt.Errorf("invalid plan limits in %s: %s: %w", path, name, err)
+			}
+			next[name] = limits
+		}
+	}
+
+	plansMu.Lock()
+	plans = next
+	plansMu.Unlock()
+	return nil
+}
+
+func (o PlanOverride) apply(limits *PlanLimits) error {
+	if o.MaxFileSizeMB != nil {
+		if *o.MaxFileSizeMB <= 0 {
+			return fmt.Errorf("maxFileSizeMB must be positive")
+		}
+		limits.MaxFileSize = *o.MaxFileSizeMB * 1024 * 1024
+	}
+	if o.StorageLimitMB != nil {
+		if *o.StorageLimitMB <= 0 {
+			return fmt.Errorf("storageLimitMB must be positive")
+		}
+		limits.StorageLimit = *o.StorageLimitMB * 1024 * 1024
+	}
+	for _, f := range []struct {
+		name string
+		from *int
+		to   *int
+	}{
+		{"aiChatsLimit", o.AIChatsLimit, &limits.AIChatsLimit},
+		{"toolkitOpsLimit", o.ToolkitOpsLimit, &limits.ToolkitOpsLimit},
+		{"maxActiveLinks", o.MaxActiveLinks, &limits.MaxActiveLinks},
+		{"retentionDays", o.RetentionDays, &limits.RetentionDays},
+		{"storageGracePercent", o.StorageGracePercent, &limits.StorageGracePercent},
+	} {
+		if f.from == nil {
+			continue
+		}
+		if *f.from < 0 {
+			return fmt.Errorf("%s cannot be negative", f.name)
+		}
+		*f.to = *f.from
+	}
+	if o.VanityShareLinks != nil {
+		limits.VanityShareLinks = *o.VanityShareLinks
+	}
+	if o.WhiteLabelBranding != nil {
+		limits.WhiteLabelBranding = *o.WhiteLabelBranding
+	}
+	if o.QueueLane != nil {
+		switch *o.QueueLane {
+		case "standard", "priority", "express":
+			limits.QueueLane = *o.QueueLane
+		default:
+			return fmt.Errorf("queueLane must be standard, priority or express")
+		}
+	}
+	return nil
+}
+
+// IsPlan reports whether plan is a known subscription tier
+func IsPlan(plan string) bool {
+	plansMu.RLock()
+	defer plansMu.RUnlock()
+	_, ok := plans[plan]
+	return ok
+}
+
+// GetPlanLimits returns the limits for a plan, defaulting to free
+func GetPlanLimits(plan string) PlanLimits {
+	plansMu.RLock()
+	defer plansMu.RUnlock()
+	if limits, ok := plans[plan]; ok {
+		return limits
+	}
+	return plans["free"]
+}
+
 // GetStorageLimitForPlan returns the storage limit in bytes for a given plan
 func GetStorageLimitForPlan(plan string) int64 {
-	if limits, ok := Plans[plan]; ok {
-		return limits.StorageLimit
-	}
-	return Plans["free"].StorageLimit // Default to free
+	return GetPlanLimits(plan).StorageLimit
+}
+
+// GetStorageGraceForPlan returns how many bytes past limit a plan may use
+func GetStorageGraceForPlan(plan string, limit int64) int64 {
+	return limit * int64(GetPlanLimits(plan).StorageGracePercent) / 100
 }
 
 // GetMaxFileSizeForPlan returns the max file size in bytes for a given plan
 func GetMaxFileSizeForPlan(plan string) int64 {
-	if limits, ok := Plans[plan]; ok {
-		return limits.MaxFileSize
-	}
-	return Plans["free"].MaxFileSize // Default to free
+	return GetPlanLimits(plan).MaxFileSize
 }
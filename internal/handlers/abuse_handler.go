@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AbuseHandler takes public reports of abusive share links
+type AbuseHandler struct {
+	abuseService *services.AbuseService
+}
+
+// NewAbuseHandler creates a new abuse handler
+func NewAbuseHandler(abuseService *services.AbuseService) *AbuseHandler {
+	return &AbuseHandler{abuseService: abuseService}
+}
+
+// Report handles POST /api/v1/report
+// Body: {"code": "aB3dE5fG", "reason": "copyright", "details": "...",
+// "email": "legal@example.com"}. Anyone can report a link, signed in or
+// not; reports wait for an admin to review.
+func (h *AbuseHandler) Report(c *gin.Context) {
+	var req struct {
+		Code    string `json:"code" binding:"required"`
+		Reason  string `json:"reason" binding:"required"`
+		Details string `json:"details"`
+		Email   string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "code and reason are required")
+		return
+	}
+
+	report, err := h.abuseService.Report(c.Request.Context(), services.AbuseReportInput{
+		Code:      req.Code,
+		Reason:    req.Reason,
+		Details:   req.Details,
+		Email:     req.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.SuccessWithStatus(c, http.StatusAccepted, gin.H{
+		"id":      report.ID,
+		"status":  report.Status,
+		"message": "Thanks, your report will be reviewed.",
+	})
+}
+
+func (h *AbuseHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAbuseReport):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrReportedShareGone):
+		utils.NotFound(c, "Share link not found")
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// RegisterRoutes registers abuse report routes
+func (h *AbuseHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/report", h.Report)
+}
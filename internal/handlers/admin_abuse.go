@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAbuseReports handles GET /admin/abuse-reports?status=open&limit=
+// Returns the review queue, oldest first; status defaults to open and
+// "all" lists every report
+func (h *AdminHandler) ListAbuseReports(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	status := c.DefaultQuery("status", models.AbuseReportOpen)
+	if status == "all" {
+		status = ""
+	}
+
+	reports, err := h.abuse.List(c.Request.Context(), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch abuse reports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reports})
+}
+
+// ResolveAbuseReport handles POST /admin/abuse-reports/:id/resolve
+// Body: {"action": "dismiss" | "disable_share" | "disable_user", "note": "..."}.
+// The note is sent to the owner as the reason when their link or sharing is
+// disabled.
+func (h *AdminHandler) ResolveAbuseReport(c *gin.Context) {
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	report, err := h.abuse.Resolve(c.Request.Context(), c.Param("id"), req.Action, req.Note, adminID)
+	if err != nil {
+		respondAbuseError(c, err)
+		return
+	}
+
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditAbuseReportResolved,
+		ResourceType: "abuse_report",
+		ResourceID:   report.ID.Hex(),
+		Details:      gin.H{"action": report.Action, "shareCode": report.ShareCode, "ownerId": report.OwnerID},
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// DisableShare handles POST /admin/shares/:code/disable
+// Body: {"reason": "..."}, sent to the link's owner
+func (h *AdminHandler) DisableShare(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	code := c.Param("code")
+	reason := strings.TrimSpace(req.Reason)
+	if err := h.abuse.DisableShare(c.Request.Context(), code, reason, adminID); err != nil {
+		respondAbuseError(c, err)
+		return
+	}
+
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditShareDisabled,
+		ResourceType: "share",
+		ResourceID:   code,
+		Details:      gin.H{"reason": reason},
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Share link disabled"})
+}
+
+// EnableShare handles POST /admin/shares/:code/enable
+// Brings back a link disabled with DisableShare
+func (h *AdminHandler) EnableShare(c *gin.Context) {
+	code := c.Param("code")
+	if err := h.abuse.EnableShare(c.Request.Context(), code); err != nil {
+		respondAbuseError(c, err)
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditShareEnabled,
+		ResourceType: "share",
+		ResourceID:   code,
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Share link enabled"})
+}
+
+// UpdateUserSharing handles POST /admin/users/:uid/sharing
+// Body: {"enabled": false, "reason": "..."}. Disabling stops the user
+// creating links and takes all of theirs offline.
+func (h *AdminHandler) UpdateUserSharing(c *gin.Context) {
+	var req struct {
+		Enabled *bool  `json:"enabled" binding:"required"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if !*req.Enabled && reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required to disable sharing"})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	uid := c.Param("uid")
+	var err error
+	action := models.AuditUserSharingEnabled
+	if *req.Enabled {
+		err = h.abuse.EnableSharing(c.Request.Context(), uid)
+	} else {
+		err = h.abuse.DisableSharing(c.Request.Context(), uid, reason, adminID)
+		action = models.AuditUserSharingDisabled
+	}
+	if err != nil {
+		respondAbuseError(c, err)
+		return
+	}
+
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       action,
+		ResourceType: "user",
+		ResourceID:   uid,
+		Details:      gin.H{"reason": reason},
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Sharing updated"})
+}
+
+func respondAbuseError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAbuseReport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAbuseReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Abuse report not found"})
+	case errors.Is(err, services.ErrReportedShareGone):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+	case errors.Is(err, services.ErrTakedownUserMissing):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrAbuseReportResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Abuse action failed: " + err.Error()})
+	}
+}
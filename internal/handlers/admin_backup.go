@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListBackups handles GET /admin/backups
+func (h *AdminHandler) ListBackups(c *gin.Context) {
+	if !h.backupsAvailable(c) {
+		return
+	}
+	backups, err := h.backups.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch backups"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": backups})
+}
+
+// GetBackup handles GET /admin/backups/:id
+func (h *AdminHandler) GetBackup(c *gin.Context) {
+	if !h.backupsAvailable(c) {
+		return
+	}
+	b, err := h.backups.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondBackupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": b})
+}
+
+// StartBackup handles POST /admin/backups
+// Queues a backup of the database and a hashed listing of stored objects
+func (h *AdminHandler) StartBackup(c *gin.Context) {
+	if !h.backupsAvailable(c) {
+		return
+	}
+	adminID, _ := middleware.GetUserID(c)
+	b, err := h.backups.Start(c.Request.Context(), adminID)
+	if err != nil {
+		respondBackupError(c, err)
+		return
+	}
+
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditBackupStarted,
+		ResourceType: "backup",
+		ResourceID:   b.ID.Hex(),
+		Details:      gin.H{"bucket": b.Bucket},
+	})
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": b})
+}
+
+func (h *AdminHandler) backupsAvailable(c *gin.Context) bool {
+	if h.backups == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Backups are not available; check BACKUP_BUCKET"})
+		return false
+	}
+	return true
+}
+
+func respondBackupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBackupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+	case errors.Is(err, services.ErrBackupInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Backup failed: " + err.Error()})
+	}
+}
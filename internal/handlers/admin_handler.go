@@ -3,8 +3,10 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
+	"brainy-pdf/internal/middleware"
 	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/pkg/mongodb"
@@ -13,14 +15,28 @@ import (
 )
 
 type AdminHandler struct {
-	db          *mongodb.Client
-	userService *services.UserService
+	db                *mongodb.Client
+	userService       *services.UserService
+	auditService      *services.AuditService
+	encryptionService *services.EncryptionService // nil unless encryption at rest is enabled
+	legalHoldService  *services.LegalHoldService
+	maintenance       *services.MaintenanceService
+	storageMigrations *services.StorageMigrationService
+	backups           *services.BackupService // nil when the backup bucket is unavailable
+	abuse             *services.AbuseService
 }
 
-func NewAdminHandler(db *mongodb.Client, userService *services.UserService) *AdminHandler {
+func NewAdminHandler(db *mongodb.Client, userService *services.UserService, auditService *services.AuditService, encryptionService *services.EncryptionService, legalHoldService *services.LegalHoldService, maintenance *services.MaintenanceService, storageMigrations *services.StorageMigrationService, backups *services.BackupService, abuse *services.AbuseService) *AdminHandler {
 	return &AdminHandler{
-		db:          db,
-		userService: userService,
+		db:                db,
+		userService:       userService,
+		auditService:      auditService,
+		encryptionService: encryptionService,
+		legalHoldService:  legalHoldService,
+		maintenance:       maintenance,
+		storageMigrations: storageMigrations,
+		backups:           backups,
+		abuse:             abuse,
 	}
 }
 
@@ -34,8 +50,29 @@ func (h *AdminHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.Han
 		admin.GET("/health", h.GetSystemHealth)
 		admin.GET("/users", h.ListUsers)
 		admin.GET("/documents", h.ListDocuments)
+		admin.GET("/audit-logs", h.ListAuditLogs)
+		admin.GET("/legal-holds", h.ListLegalHolds)
+		admin.GET("/maintenance", h.GetMaintenance)
+		admin.PUT("/maintenance", h.SetMaintenance)
+		admin.GET("/storage-migrations", h.ListStorageMigrations)
+		admin.POST("/storage-migrations", h.StartStorageMigration)
+		admin.GET("/storage-migrations/:id", h.GetStorageMigration)
+		admin.POST("/storage-migrations/:id/pause", h.PauseStorageMigration)
+		admin.POST("/storage-migrations/:id/resume", h.ResumeStorageMigration)
+		admin.POST("/storage-migrations/:id/cutover", h.CutoverStorageMigration)
+		admin.DELETE("/storage-migrations/:id", h.CancelStorageMigration)
+		admin.GET("/backups", h.ListBackups)
+		admin.POST("/backups", h.StartBackup)
+		admin.GET("/backups/:id", h.GetBackup)
 		admin.POST("/users/:uid/role", h.UpdateUserRole)
 		admin.POST("/users/:uid/plan", h.UpdateUserPlan)
+		admin.POST("/users/:uid/sharing", h.UpdateUserSharing)
+		admin.GET("/abuse-reports", h.ListAbuseReports)
+		admin.POST("/abuse-reports/:id/resolve", h.ResolveAbuseReport)
+		admin.POST("/shares/:code/disable", h.DisableShare)
+		admin.POST("/shares/:code/enable", h.EnableShare)
+		admin.POST("/encryption/users/:uid/rotate", h.RotateUserKey)
+		admin.POST("/encryption/rewrap", h.RewrapKeys)
 	}
 }
 
@@ -109,8 +146,14 @@ func (h *AdminHandler) ListUsers(c *gin.Context) {
 func (h *AdminHandler) ListDocuments(c *gin.Context) {
 	ctx := context.Background()
 	var docs []models.Document
-	
-	cursor, err := h.db.Collection("documents").Find(ctx, bson.M{})
+
+	// ?virusScan=infected (or pending, error, clean) lists files by scan result
+	filter := bson.M{}
+	if status := c.Query("virusScan"); status != "" {
+		filter["virusScan.status"] = status
+	}
+
+	cursor, err := h.db.Collection("documents").Find(ctx, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch documents"})
 		return
@@ -124,6 +167,78 @@ func (h *AdminHandler) ListDocuments(c *gin.Context) {
 	})
 }
 
+// ListAuditLogs handles GET /admin/audit-logs?actorId=&action=&limit=
+// Returns the newest audit entries first
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	entries, err := h.auditService.List(c.Request.Context(), c.Query("actorId"), c.Query("action"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// ListLegalHolds lists every file under legal hold, across organizations
+func (h *AdminHandler) ListLegalHolds(c *gin.Context) {
+	items, err := h.legalHoldService.List(c.Request.Context(), c.Query("orgId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch legal holds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    items,
+	})
+}
+
+// GetMaintenance reports whether maintenance mode is on
+func (h *AdminHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h.maintenance.State()})
+}
+
+// SetMaintenance turns maintenance mode on or off. While on, writes and
+// processing get 503 with Retry-After and queued jobs wait.
+func (h *AdminHandler) SetMaintenance(c *gin.Context) {
+	var req struct {
+		Enabled           bool   `json:"enabled"`
+		Message           string `json:"message"`
+		RetryAfterSeconds int    `json:"retryAfterSeconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.RetryAfterSeconds < 0 || req.RetryAfterSeconds > 86400 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retryAfterSeconds must be between 0 and 86400"})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	state, err := h.maintenance.Set(c.Request.Context(), req.Enabled, req.Message, req.RetryAfterSeconds, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update maintenance mode"})
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditMaintenanceChanged,
+		ResourceType: "maintenance",
+		Details:      gin.H{"enabled": state.Enabled, "message": state.Message},
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": state})
+}
+
 func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 	uid := c.Param("uid")
 	var req struct {
@@ -244,3 +359,57 @@ func (h *AdminHandler) GetAnalytics(c *gin.Context) {
 	})
 }
 
+
+// RotateUserKey handles POST /admin/encryption/users/:uid/rotate
+// Gives the user a new data key and re-encrypts their files with it
+func (h *AdminHandler) RotateUserKey(c *gin.Context) {
+	if h.encryptionService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Encryption at rest is not enabled"})
+		return
+	}
+
+	uid := c.Param("uid")
+	if _, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), uid); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	result, err := h.encryptionService.RotateUserKey(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Key rotation failed: " + err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditDataKeyRotated,
+		ResourceType: "user",
+		ResourceID:   uid,
+		Details:      gin.H{"version": result.Version, "reencrypted": result.Reencrypted, "failed": result.Failed},
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// RewrapKeys handles POST /admin/encryption/rewrap
+// Rewraps all data keys with the current master key after it was rotated
+func (h *AdminHandler) RewrapKeys(c *gin.Context) {
+	if h.encryptionService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Encryption at rest is not enabled"})
+		return
+	}
+
+	n, err := h.encryptionService.RewrapKeys(c.Request.Context())
+	adminID, _ := middleware.GetUserID(c)
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditDataKeysRewrapped,
+		ResourceType: "encryption",
+		Details:      gin.H{"rewrapped": n},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rewrap failed: " + err.Error(), "rewrapped": n})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "rewrapped": n})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListStorageMigrations handles GET /admin/storage-migrations
+func (h *AdminHandler) ListStorageMigrations(c *gin.Context) {
+	migrations, err := h.storageMigrations.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage migrations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": migrations})
+}
+
+// GetStorageMigration handles GET /admin/storage-migrations/:id
+func (h *AdminHandler) GetStorageMigration(c *gin.Context) {
+	m, err := h.storageMigrations.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": m})
+}
+
+// StartStorageMigration handles POST /admin/storage-migrations
+// Starts copying one of the platform's buckets to another bucket or endpoint
+func (h *AdminHandler) StartStorageMigration(c *gin.Context) {
+	var req struct {
+		SourceBucket string `json:"sourceBucket" binding:"required"`
+		Target       struct {
+			Endpoint  string `json:"endpoint"`
+			Region    string `json:"region"`
+			Bucket    string `json:"bucket"`
+			AccessKey string `json:"accessKey"`
+			SecretKey string `json:"secretKey"`
+			UseSSL    bool   `json:"useSSL"`
+		} `json:"target"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	m, err := h.storageMigrations.Start(c.Request.Context(), req.SourceBucket, models.StorageMigrationTarget{
+		Endpoint:  strings.TrimSpace(req.Target.Endpoint),
+		Region:    strings.TrimSpace(req.Target.Region),
+		Bucket:    strings.TrimSpace(req.Target.Bucket),
+		AccessKey: req.Target.AccessKey,
+		SecretKey: req.Target.SecretKey,
+		UseSSL:    req.Target.UseSSL,
+	}, adminID)
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditStorageMigrationStarted,
+		ResourceType: "storage_migration",
+		ResourceID:   m.ID.Hex(),
+		Details:      gin.H{"sourceBucket": m.SourceBucket, "endpoint": m.Target.Endpoint, "bucket": m.Target.Bucket},
+	})
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": m})
+}
+
+// PauseStorageMigration handles POST /admin/storage-migrations/:id/pause
+func (h *AdminHandler) PauseStorageMigration(c *gin.Context) {
+	m, err := h.storageMigrations.Pause(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": m})
+}
+
+// ResumeStorageMigration handles POST /admin/storage-migrations/:id/resume
+func (h *AdminHandler) ResumeStorageMigration(c *gin.Context) {
+	m, err := h.storageMigrations.Resume(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": m})
+}
+
+// CutoverStorageMigration handles POST /admin/storage-migrations/:id/cutover
+// Switches documents to the target bucket; maintenance mode must be on
+func (h *AdminHandler) CutoverStorageMigration(c *gin.Context) {
+	m, err := h.storageMigrations.Cutover(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditStorageMigrationCutover,
+		ResourceType: "storage_migration",
+		ResourceID:   m.ID.Hex(),
+	})
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": m})
+}
+
+// CancelStorageMigration handles DELETE /admin/storage-migrations/:id
+func (h *AdminHandler) CancelStorageMigration(c *gin.Context) {
+	m, err := h.storageMigrations.Cancel(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondStorageMigrationError(c, err)
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      adminID,
+		Action:       models.AuditStorageMigrationCancelled,
+		ResourceType: "storage_migration",
+		ResourceID:   m.ID.Hex(),
+	})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": m})
+}
+
+func respondStorageMigrationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMigrationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Storage migration not found"})
+	case errors.Is(err, services.ErrMigrationInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMigrationInProgress), errors.Is(err, services.ErrMigrationState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMaintenanceRequired):
+		c.JSON(http.StatusConflict, gin.H{"error": "Turn on maintenance mode before cutting over"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage migration failed: " + err.Error()})
+	}
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
-	"io"
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
 	"github.com/gin-gonic/gin"
@@ -16,37 +23,46 @@ type AIHandler struct {
 	aiService      *services.AIService
 	pdfService     *services.PDFService
 	storageService *services.StorageService
+	summaryService *services.SummaryService
+	speechService  *services.SpeechService
+	userService    *services.UserService
+	orgService     *services.OrgService
+	capabilities   *services.CapabilityRegistry
+
+	redactionService *services.RedactionService
 }
 
 // NewAIHandler creates a new AI handler
-func NewAIHandler(aiService *services.AIService, pdfService *services.PDFService, storageService *services.StorageService) *AIHandler {
+func NewAIHandler(aiService *services.AIService, pdfService *services.PDFService, storageService *services.StorageService, summaryService *services.SummaryService, speechService *services.SpeechService, userService *services.UserService, orgService *services.OrgService, capabilities *services.CapabilityRegistry, redactionService *services.RedactionService) *AIHandler {
 	return &AIHandler{
-		aiService:      aiService,
-		pdfService:     pdfService,
-		storageService: storageService,
+		aiService:        aiService,
+		pdfService:       pdfService,
+		storageService:   storageService,
+		summaryService:   summaryService,
+		speechService:    speechService,
+		userService:      userService,
+		orgService:       orgService,
+		capabilities:     capabilities,
+		redactionService: redactionService,
 	}
 }
 
 // OCR handles POST /api/v1/ai/ocr
 func (h *AIHandler) OCR(c *gin.Context) {
-	file, _, err := c.Request.FormFile("file")
+	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		utils.BadRequest(c, "No file provided")
 		return
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// First try to extract text directly (for non-scanned PDFs)
-	text, err := h.pdfService.ExtractText(c.Request.Context(), data)
+	text, err := h.pdfService.ExtractText(c.Request.Context(), in)
 	if err == nil && len(strings.TrimSpace(text)) > 100 {
 		// PDF has extractable text, return it
-		pageCount, _ := h.pdfService.GetPageCount(data)
+		pageCount, _ := h.pdfService.GetPageCount(in)
 		utils.Success(c, gin.H{
 			"text":       text,
 			"pages":      []gin.H{{"pageNumber": 1, "text": text}},
@@ -57,7 +73,11 @@ func (h *AIHandler) OCR(c *gin.Context) {
 	}
 
 	// Fall back to OCR for scanned PDFs
-	result, err := h.aiService.ExtractTextOCR(c.Request.Context(), data)
+	if ocr := h.capabilities.Get(services.CapabilityOCR); !ocr.Available {
+		utils.ServiceDisabled(c, services.CapabilityOCR, ocr.Reason)
+		return
+	}
+	result, err := h.aiService.ExtractTextOCR(c.Request.Context(), in)
 	if err != nil {
 		utils.InternalServerError(c, "OCR failed: "+err.Error())
 		return
@@ -72,10 +92,12 @@ func (h *AIHandler) OCR(c *gin.Context) {
 }
 
 // Summarize handles POST /api/v1/ai/summarize
+// Extracts the text and queues a summary job in the lane of the caller's
+// plan, returning its jobId; poll GET /api/v1/ai/summarize/status/:jobId
+// for progress and the result
 func (h *AIHandler) Summarize(c *gin.Context) {
-	// Check if AI service is available
-	if h.aiService == nil {
-		utils.ServiceUnavailable(c, "AI service is not configured. Please set OPENROUTER_API_KEY in environment.")
+	if h.summaryService == nil {
+		utils.ServiceDisabled(c, services.CapabilityAI, "Summary jobs are not available")
 		return
 	}
 
@@ -99,20 +121,18 @@ func (h *AIHandler) Summarize(c *gin.Context) {
 		length = "medium"
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF format
-	if err := h.pdfService.ValidatePDF(data); err != nil {
+	if err := h.pdfService.ValidatePDF(in); err != nil {
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
-	// Extract text from PDF
-	text, err := h.pdfService.ExtractText(c.Request.Context(), data)
+	// Extract text from PDF, keeping page breaks so the summary is built
+	// from groups of whole pages
+	pages, err := h.pdfService.ExtractPageTexts(c.Request.Context(), in)
+	text := strings.Join(pages, services.PageBreak)
 	
 	// Check if text extraction failed or returned low-quality text
 	needsOCR := false
@@ -129,7 +149,7 @@ func (h *AIHandler) Summarize(c *gin.Context) {
 	
 	// Try OCR if needed
 	if needsOCR {
-		ocrText, ocrErr := h.pdfService.ExtractTextWithOCR(c.Request.Context(), data)
+		ocrText, ocrErr := h.pdfService.ExtractTextWithOCR(c.Request.Context(), in)
 		if ocrErr != nil {
 			log.Printf("[AI] OCR also failed: %v", ocrErr)
 			// If we have some text from normal extraction, use it anyway
@@ -144,64 +164,446 @@ func (h *AIHandler) Summarize(c *gin.Context) {
 		}
 	}
 	
-	// Clean the extracted text
-	text = services.CleanExtractedText(text)
-	
+	// Clean the extracted text page by page so page numbers are kept
+	pages = services.SplitPages(text)
+	for i := range pages {
+		pages[i] = services.CleanExtractedText(pages[i])
+	}
+	text = strings.Join(pages, services.PageBreak)
+
 	// Final validation
 	if len(strings.TrimSpace(text)) < 30 {
 		utils.BadRequest(c, "Not enough text content to summarize. The PDF may be empty or contain only images.")
 		return
 	}
 
-	result, err := h.aiService.SummarizePDF(c.Request.Context(), text, length)
+	userID, _ := middleware.GetUserID(c)
+	lane := h.userService.QueueLane(c.Request.Context(), userID)
+	job, err := h.summaryService.SubmitJob(userID, lane, text, header.Filename, length)
 	if err != nil {
-		// Check for specific error types
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "quota") || strings.Contains(errMsg, "rate") {
-			utils.TooManyRequests(c, "AI API rate limit exceeded. Please try again in a few moments.")
-			return
+		utils.InternalServerError(c, "Failed to queue summary: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"jobId":       job.ID,
+		"status":      job.Status,
+		"lane":        job.Lane,
+		"totalChunks": job.TotalChunks,
+	})
+}
+
+// SummarizeStatus handles GET /api/v1/ai/summarize/status/:jobId
+// Reports per-chunk progress, and the summary once the job completes.
+// Queued jobs also report their queuePosition.
+func (h *AIHandler) SummarizeStatus(c *gin.Context) {
+	jobID := c.Param("jobId")
+	if jobID == "" {
+		utils.BadRequest(c, "Job ID required")
+		return
+	}
+
+	if h.summaryService == nil {
+		utils.ServiceDisabled(c, services.CapabilityAI, "Summary jobs are not available")
+		return
+	}
+
+	job, err := h.summaryService.GetJob(jobID)
+	if err != nil {
+		utils.NotFound(c, "Job not found")
+		return
+	}
+	if userID, _ := middleware.GetUserID(c); job.UserID != "" && job.UserID != userID {
+		utils.NotFound(c, "Job not found")
+		return
+	}
+
+	response := gin.H{
+		"jobId":           job.ID,
+		"status":          job.Status,
+		"lane":            job.Lane,
+		"stage":           job.Stage,
+		"progress":        job.Progress,
+		"completedChunks": job.CompletedChunks,
+		"totalChunks":     job.TotalChunks,
+		"chunks":          job.Chunks,
+		"error":           job.Error,
+		"createdAt":       job.CreatedAt,
+		"completedAt":     job.CompletedAt,
+	}
+	if job.Status == services.JobStatusQueued {
+		if position, err := h.summaryService.QueuePosition(c.Request.Context(), job.ID); err == nil && position > 0 {
+			response["queuePosition"] = position
 		}
-		if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline") {
-			utils.GatewayTimeout(c, "AI processing timed out. Please try with a smaller document.")
-			return
+	}
+	if result := job.Result; result != nil {
+		response["result"] = gin.H{
+			"summary":         result.Summary,
+			"documentType":    result.DocumentType,
+			"confidenceLevel": result.ConfidenceLevel,
+			"keyEntities":     result.KeyEntities,
+			"importantPoints": result.ImportantPoints,
+			"wordCount":       result.WordCount,
 		}
-		if strings.Contains(errMsg, "unintelligible") || strings.Contains(errMsg, "encrypted") {
-			utils.BadRequest(c, "The document content could not be understood. Please ensure the PDF contains readable text, not just images.")
-			return
+	}
+	utils.Success(c, response)
+}
+
+// ContractReview handles POST /api/v1/ai/contract-review
+// Extracts parties, dates and key clauses with page references and rates
+// the contract's risk
+func (h *AIHandler) ContractReview(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > 10*1024*1024 {
+		utils.BadRequest(c, "File too large. Maximum size for AI processing is 10MB.")
+		return
+	}
+
+	in := inputReader(file, header)
+
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	pages, err := h.pdfService.ExtractPageTexts(c.Request.Context(), in)
+	if err != nil {
+		utils.BadRequest(c, "Could not extract text from this PDF: "+err.Error())
+		return
+	}
+	for i := range pages {
+		pages[i] = services.CleanExtractedText(pages[i])
+	}
+	if len(strings.TrimSpace(strings.Join(pages, ""))) < 30 {
+		utils.BadRequest(c, "Not enough text content to review. The PDF may be scanned or contain only images.")
+		return
+	}
+
+	review, err := h.aiService.ReviewContract(c.Request.Context(), pages)
+	if err != nil {
+		aiFailed(c, "Contract review", err)
+		return
+	}
+
+	utils.Success(c, review)
+}
+
+// diffSummaryMaxChanges bounds the text changes returned by DiffSummary
+const diffSummaryMaxChanges = 500
+
+// DiffSummary handles POST /api/v1/ai/diff-summary
+// Diffs the text of two versions of a PDF ("original" and "revised") and
+// has the AI summarize the material changes
+func (h *AIHandler) DiffSummary(c *gin.Context) {
+	before, ok := h.readPageTexts(c, "original")
+	if !ok {
+		return
+	}
+	after, ok := h.readPageTexts(c, "revised")
+	if !ok {
+		return
+	}
+
+	changes := services.DiffPageTexts(before, after)
+	result := models.DiffSummary{
+		PageCountBefore: len(before),
+		PageCountAfter:  len(after),
+		Identical:       len(changes) == 0,
+		MaterialChanges: []models.MaterialChange{},
+		Changes:         changes,
+	}
+	for _, change := range changes {
+		switch change.Type {
+		case models.TextAdded:
+			result.Added++
+		case models.TextRemoved:
+			result.Removed++
+		default:
+			result.Changed++
 		}
-		utils.InternalServerError(c, "Summarization failed: "+err.Error())
+	}
+
+	if result.Identical {
+		result.Changes = []models.TextChange{}
+		result.Summary = "The two versions have the same text."
+		utils.Success(c, result)
 		return
 	}
 
-	utils.Success(c, gin.H{
-		"summary":          result.Summary,
-		"documentType":     result.DocumentType,
-		"confidenceLevel":  result.ConfidenceLevel,
-		"keyEntities":      result.KeyEntities,
-		"importantPoints":  result.ImportantPoints,
-		"wordCount":        result.WordCount,
-	})
+	summary, material, err := h.aiService.SummarizeDiff(c.Request.Context(), changes)
+	if err != nil {
+		aiFailed(c, "Diff summary", err)
+		return
+	}
+	result.Summary = summary
+	result.MaterialChanges = material
+	if len(result.Changes) > diffSummaryMaxChanges {
+		result.Changes = result.Changes[:diffSummaryMaxChanges]
+		result.Truncated = true
+	}
+
+	utils.Success(c, result)
 }
 
-// DetectSensitive handles POST /api/v1/ai/detect-sensitive
-func (h *AIHandler) DetectSensitive(c *gin.Context) {
-	file, _, err := c.Request.FormFile("file")
+// readPageTexts reads the PDF uploaded as field and returns the cleaned
+// text of each page, responding with 400 when that fails
+func (h *AIHandler) readPageTexts(c *gin.Context, field string) ([]string, bool) {
+	file, header, err := c.Request.FormFile(field)
+	if err != nil {
+		utils.BadRequest(c, "No "+field+" file provided")
+		return nil, false
+	}
+	defer file.Close()
+
+	if header.Size > 10*1024*1024 {
+		utils.BadRequest(c, "File too large. Maximum size for AI processing is 10MB.")
+		return nil, false
+	}
+
+	in := inputReader(file, header)
+
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file ("+field+"): "+err.Error())
+		return nil, false
+	}
+
+	pages, err := h.pdfService.ExtractPageTexts(c.Request.Context(), in)
+	if err != nil {
+		utils.BadRequest(c, "Could not extract text from the "+field+" PDF: "+err.Error())
+		return nil, false
+	}
+	for i := range pages {
+		pages[i] = services.CleanExtractedText(pages[i])
+	}
+	return pages, true
+}
+
+// readAloudMaxChars bounds the text read aloud in one request, about an
+// hour of speech
+const readAloudMaxChars = 60000
+
+// ReadAloud handles POST /api/v1/ai/read-aloud
+// Reads the text of a PDF aloud and stores the MP3 (mode "document") or one
+// MP3 per page (mode "pages"), returning presigned links
+func (h *AIHandler) ReadAloud(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		utils.BadRequest(c, "No file provided")
 		return
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	if header.Size > 10*1024*1024 {
+		utils.BadRequest(c, "File too large. Maximum size for AI processing is 10MB.")
+		return
+	}
+
+	mode := c.DefaultPostForm("mode", models.ReadAloudDocument)
+	if mode != models.ReadAloudDocument && mode != models.ReadAloudPages {
+		utils.BadRequest(c, "mode must be 'document' or 'pages'")
+		return
+	}
+	voice := strings.TrimSpace(c.PostForm("voice"))
+	if voice == "" {
+		voice = h.speechService.DefaultVoice()
+	}
+
+	in := inputReader(file, header)
+
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	texts, err := h.pdfService.ExtractPageTexts(c.Request.Context(), in)
+	if err != nil {
+		utils.BadRequest(c, "Could not extract text from this PDF: "+err.Error())
+		return
+	}
+
+	selected, err := selectPages(c.PostForm("pages"), len(texts))
 	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	result := &models.ReadAloudResult{Mode: mode, Voice: voice, Pages: []int{}, SkippedPages: []int{}}
+	var readable []string
+	for _, page := range selected {
+		text := services.CleanExtractedText(texts[page-1])
+		if text == "" {
+			result.SkippedPages = append(result.SkippedPages, page)
+			continue
+		}
+		result.Pages = append(result.Pages, page)
+		readable = append(readable, text)
+		result.Characters += len(text)
+	}
+	if len(readable) == 0 {
+		utils.BadRequest(c, "No text to read on the selected pages. Scanned pages need OCR first.")
+		return
+	}
+	if result.Characters > readAloudMaxChars {
+		utils.BadRequest(c, fmt.Sprintf("Too much text to read aloud at once (%d characters, maximum %d). Select fewer pages.", result.Characters, readAloudMaxChars))
+		return
+	}
+
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+	baseName := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+
+	// Each track is read and stored in page order
+	type track struct {
+		name, text, pages string
+		pageCount         int
+	}
+	var tracks []track
+	if mode == models.ReadAloudPages {
+		for i, page := range result.Pages {
+			tracks = append(tracks, track{fmt.Sprintf("%s_page_%d.mp3", baseName, page), readable[i], fmt.Sprint(page), 1})
+		}
+	} else {
+		tracks = append(tracks, track{baseName + "_audio.mp3", strings.Join(readable, "\n\n"), formatPageRanges(result.Pages), len(result.Pages)})
+	}
+
+	var outputs []models.OperationOutput
+	for _, t := range tracks {
+		audio, err := h.speechService.Synthesize(c.Request.Context(), t.text, voice)
+		if err != nil {
+			speechFailed(c, err)
+			return
+		}
+
+		upload, err := h.storageService.UploadProcessedMedia(c.Request.Context(), userID, t.name, "audio/mpeg", audio)
+		if err != nil {
+			if errors.Is(err, services.ErrStorageLimitExceeded) {
+				utils.Forbidden(c, "Storage limit exceeded. Please upgrade your plan")
+				return
+			}
+			utils.InternalServerError(c, "Failed to save audio")
+			return
+		}
+		output := outputFromUpload(upload, t.pageCount)
+		output.Range = t.pages
+		outputs = append(outputs, output)
+	}
+
+	result.Operation = "read-aloud"
+	result.SetOutputs(outputs...)
+	result.ProcessingMs = time.Since(startTime).Milliseconds()
+	utils.Success(c, result)
+}
+
+// selectPages expands a page selection such as "1-3,5" into page numbers
+// in document order; an empty selection is every page
+func selectPages(ranges string, pageCount int) ([]int, error) {
+	if strings.TrimSpace(ranges) == "" {
+		pages := make([]int, pageCount)
+		for i := range pages {
+			pages[i] = i + 1
+		}
+		return pages, nil
+	}
+	if !isValidPageRanges(ranges) {
+		return nil, fmt.Errorf("invalid page selection %q (e.g. 1-3,5)", ranges)
+	}
+	if err := validatePageRangesAgainstCount(ranges, pageCount); err != nil {
+		return nil, err
+	}
+
+	chosen := make([]bool, pageCount+1)
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		start, end := part, part
+		if bounds := strings.SplitN(part, "-", 2); len(bounds) == 2 {
+			start, end = bounds[0], bounds[1]
+		}
+		first, _ := parseInt(strings.TrimSpace(start))
+		last, _ := parseInt(strings.TrimSpace(end))
+		for p := first; p <= last; p++ {
+			chosen[p] = true
+		}
+	}
+
+	var pages []int
+	for p := 1; p <= pageCount; p++ {
+		if chosen[p] {
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
+
+// formatPageRanges writes sorted page numbers as ranges, e.g. "1-3,5"
+func formatPageRanges(pages []int) string {
+	var parts []string
+	for i := 0; i < len(pages); {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", pages[i], pages[j]))
+		} else {
+			parts = append(parts, fmt.Sprint(pages[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// speechFailed responds to a failed text-to-speech call: 429 when rate
+// limited, 504 on timeouts, 500 otherwise
+func speechFailed(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "rate limit"):
+		utils.TooManyRequests(c, "Text-to-speech rate limit exceeded. Please try again in a few moments.")
+	case strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline"):
+		utils.GatewayTimeout(c, "Text-to-speech timed out. Please select fewer pages.")
+	default:
+		utils.InternalServerError(c, "Read aloud failed: "+errMsg)
+	}
+}
+
+// aiFailed responds to a failed OpenRouter call: 429 when rate limited,
+// 504 on timeouts, 500 otherwise
+func aiFailed(c *gin.Context, action string, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "quota") || strings.Contains(errMsg, "rate"):
+		utils.TooManyRequests(c, "AI API rate limit exceeded. Please try again in a few moments.")
+	case strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline"):
+		utils.GatewayTimeout(c, "AI processing timed out. Please try with a smaller document.")
+	default:
+		utils.InternalServerError(c, action+" failed: "+errMsg)
+	}
+}
+
+// DetectSensitive handles POST /api/v1/ai/detect-sensitive
+func (h *AIHandler) DetectSensitive(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+
 	// Extract text
-	text, err := h.pdfService.ExtractText(c.Request.Context(), data)
+	text, err := h.pdfService.ExtractText(c.Request.Context(), in)
 	if err != nil || len(strings.TrimSpace(text)) < 10 {
-		ocrResult, ocrErr := h.aiService.ExtractTextOCR(c.Request.Context(), data)
+		ocrResult, ocrErr := h.aiService.ExtractTextOCR(c.Request.Context(), in)
 		if ocrErr != nil {
 			utils.InternalServerError(c, "Failed to extract text from PDF")
 			return
@@ -224,7 +626,7 @@ func (h *AIHandler) DetectSensitive(c *gin.Context) {
 
 // MaskSensitive handles POST /api/v1/ai/mask-sensitive
 func (h *AIHandler) MaskSensitive(c *gin.Context) {
-	file, _, err := c.Request.FormFile("file")
+	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		utils.BadRequest(c, "No file provided")
 		return
@@ -234,14 +636,10 @@ func (h *AIHandler) MaskSensitive(c *gin.Context) {
 	typesStr := c.DefaultPostForm("types", "email,phone,ssn,credit_card")
 	types := strings.Split(typesStr, ",")
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Extract text
-	text, err := h.pdfService.ExtractText(c.Request.Context(), data)
+	text, err := h.pdfService.ExtractText(c.Request.Context(), in)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to extract text from PDF")
 		return
@@ -254,16 +652,93 @@ func (h *AIHandler) MaskSensitive(c *gin.Context) {
 		return
 	}
 
-	// Note: Full PDF masking would require more complex PDF manipulation
-	// For now, we return the masked text and count
+	// Only the extracted text is masked; /redact removes the data from the PDF
 	utils.Success(c, gin.H{
 		"maskedText":  maskedText,
 		"maskedCount": maskedCount,
 		"types":       types,
-		"note":        "Full PDF masking requires additional processing",
+		"note":        "Only the extracted text is masked. Use /api/v1/ai/redact to remove the data from the PDF itself.",
 	})
 }
 
+// Redact handles POST /api/v1/ai/redact
+// Accepts file and optional types (pattern types, all by default), terms
+// (comma-separated text to redact too), ai=true to have the AI find names,
+// addresses and other personal information, and color (#RRGGBB) for the
+// boxes. Stores the redacted copy and reports what was removed, masked.
+func (h *AIHandler) Redact(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > 10*1024*1024 {
+		utils.BadRequest(c, "File too large. Maximum size for AI processing is 10MB.")
+		return
+	}
+
+	useAI := false
+	if v := c.PostForm("ai"); v != "" {
+		if useAI, err = strconv.ParseBool(v); err != nil {
+			utils.BadRequest(c, "ai must be true or false")
+			return
+		}
+		if ai := h.capabilities.Get(services.CapabilityAI); useAI && !ai.Available {
+			utils.ServiceDisabled(c, services.CapabilityAI, ai.Reason)
+			return
+		}
+	}
+	opts, err := services.NormalizeRedactOptions(services.RedactOptions{
+		Types: formList(c, "types"),
+		Terms: formList(c, "terms"),
+		AI:    useAI,
+		Color: c.PostForm("color"),
+	})
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	out, report, err := h.redactionService.Redact(c.Request.Context(), in, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRedact) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		aiFailed(c, "Redaction", err)
+		return
+	}
+
+	name := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename)) + "_redacted.pdf"
+	upload, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, name, out, "application/pdf")
+	if err != nil {
+		if errors.Is(err, services.ErrStorageLimitExceeded) {
+			utils.Forbidden(c, "Storage limit exceeded. Please upgrade your plan")
+			return
+		}
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(out))
+	result := &models.RedactResult{SingleFileResult: singleFileResult(upload, pageCount), Redactions: *report}
+	result.Operation = "redact"
+	result.ProcessingMs = time.Since(startTime).Milliseconds()
+	utils.Success(c, result)
+}
+
 // AutoFill handles POST /api/v1/ai/auto-fill
 func (h *AIHandler) AutoFill(c *gin.Context) {
 	var request struct {
@@ -322,7 +797,7 @@ func (h *AIHandler) Search(c *gin.Context) {
 			if err != nil {
 				continue
 			}
-			text, err := h.pdfService.ExtractText(c.Request.Context(), data)
+			text, err := h.pdfService.ExtractText(c.Request.Context(), bytes.NewReader(data))
 			if err != nil {
 				continue
 			}
@@ -369,15 +844,26 @@ func (h *AIHandler) Search(c *gin.Context) {
 // RegisterRoutes registers all AI routes
 func (h *AIHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	ai := r.Group("/ai")
-	ai.Use(authMiddleware)
+	ai.Use(authMiddleware, middleware.ConfidentialAIGuard(h.orgService))
 	{
 		ai.POST("/ocr", h.OCR)
-		ai.POST("/summarize", h.Summarize)
 		ai.POST("/detect-sensitive", h.DetectSensitive)
 		ai.POST("/mask-sensitive", h.MaskSensitive)
-		ai.POST("/auto-fill", h.AutoFill)
+		ai.POST("/redact", h.Redact)
 		ai.POST("/search", h.Search)
-		ai.POST("/chat", h.Chat)
+		ai.POST("/read-aloud", middleware.RequireCapability(h.capabilities, services.CapabilitySpeech), h.ReadAloud)
+	}
+
+	// LLM-backed endpoints are refused up front when OpenRouter is not configured
+	llm := ai.Group("", middleware.RequireCapability(h.capabilities, services.CapabilityAI))
+	{
+		llm.POST("/summarize", h.Summarize)
+		llm.GET("/summarize/status/:jobId", h.SummarizeStatus)
+		llm.POST("/contract-review", h.ContractReview)
+		llm.POST("/diff-summary", h.DiffSummary)
+		llm.POST("/template-check", h.TemplateCheck)
+		llm.POST("/auto-fill", h.AutoFill)
+		llm.POST("/chat", h.Chat)
 	}
 }
 
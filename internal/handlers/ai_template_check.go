@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateCheck handles POST /api/v1/ai/template-check
+// Checks that a PDF (file) contains what a template requires, for intake
+// of HR or procurement paperwork. requirements is the template spec: a
+// JSON array of names or {name, description} objects, or one requirement
+// per line. Returns pass or fail for each with evidence or a reason.
+func (h *AIHandler) TemplateCheck(c *gin.Context) {
+	reqs, err := services.ParseTemplateRequirements(c.PostForm("requirements"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > 10*1024*1024 {
+		utils.BadRequest(c, "File too large. Maximum size for AI processing is 10MB.")
+		return
+	}
+
+	in := inputReader(file, header)
+
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	pages, err := h.pdfService.ExtractPageTexts(c.Request.Context(), in)
+	if err != nil {
+		utils.BadRequest(c, "Could not extract text from this PDF: "+err.Error())
+		return
+	}
+	for i := range pages {
+		pages[i] = services.CleanExtractedText(pages[i])
+	}
+
+	// Filled-in form values aren't part of the page text
+	if fields, err := h.pdfService.FormFields(c.Request.Context(), in); err == nil && len(pages) > 0 {
+		for _, f := range fields {
+			page := 0
+			if len(f.Pages) > 0 && f.Pages[0] >= 1 && f.Pages[0] <= len(pages) {
+				page = f.Pages[0] - 1
+			}
+			pages[page] += "\n" + formFieldLine(f)
+		}
+	}
+
+	if len(strings.TrimSpace(strings.Join(pages, ""))) < 30 {
+		utils.BadRequest(c, "Not enough text content to check. The PDF may be scanned or contain only images.")
+		return
+	}
+
+	check, err := h.aiService.CheckTemplate(c.Request.Context(), pages, reqs)
+	if err != nil {
+		aiFailed(c, "Template check", err)
+		return
+	}
+
+	utils.Success(c, check)
+}
+
+// formFieldLine describes a form field and its value for the AI
+func formFieldLine(f models.FormField) string {
+	name := f.Name
+	if name == "" {
+		name = f.ID
+	}
+	value := ""
+	switch v := f.Value.(type) {
+	case bool:
+		value = "unchecked"
+		if v {
+			value = "checked"
+		}
+	case []string:
+		value = strings.Join(v, ", ")
+	case nil:
+	default:
+		value = fmt.Sprint(v)
+	}
+	if strings.TrimSpace(value) == "" {
+		value = "(blank)"
+	}
+	return fmt.Sprintf("[Form field %s: %s]", name, value)
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"strconv"
+	"strings"
+
 	"brainy-pdf/internal/middleware"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
@@ -12,13 +15,17 @@ import (
 type AuthHandler struct {
 	userService    *services.UserService
 	firebaseClient *firebase.Client
+	capabilities   *services.CapabilityRegistry
+	activity       *services.ActivityService
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(userService *services.UserService, firebaseClient *firebase.Client) *AuthHandler {
+func NewAuthHandler(userService *services.UserService, firebaseClient *firebase.Client, capabilities *services.CapabilityRegistry, activity *services.ActivityService) *AuthHandler {
 	return &AuthHandler{
 		userService:    userService,
 		firebaseClient: firebaseClient,
+		capabilities:   capabilities,
+		activity:       activity,
 	}
 }
 
@@ -52,6 +59,7 @@ func (h *AuthHandler) GoogleAuth(c *gin.Context) {
 		utils.InternalServerError(c, "Failed to create user")
 		return
 	}
+	h.activity.RecordLogin(c.Request.Context(), token.UID, token.AuthTime, c.ClientIP(), c.Request.UserAgent())
 
 	utils.Success(c, gin.H{
 		"user": gin.H{
@@ -90,6 +98,8 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		"storageUsed":  user.StorageUsed,
 		"storageLimit": user.StorageLimit,
 		"createdAt":    user.CreatedAt,
+		// Default output filename template, empty for the built-in names
+		"filenameTemplate": user.FilenameTemplate,
 	})
 }
 
@@ -112,12 +122,23 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 
 	var request struct {
 		DisplayName string `json:"displayName"`
+		// Default output filename template; "" clears it, omitted keeps it
+		FilenameTemplate *string `json:"filenameTemplate"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		utils.BadRequest(c, "Invalid request body")
 		return
 	}
+	if request.FilenameTemplate != nil {
+		*request.FilenameTemplate = strings.TrimSpace(*request.FilenameTemplate)
+		if *request.FilenameTemplate != "" {
+			if err := services.ValidateFilenameTemplate(*request.FilenameTemplate); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+		}
+	}
 
 	// Get existing user
 	user, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), firebaseUID)
@@ -144,11 +165,21 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	filenameTemplate := user.FilenameTemplate
+	if request.FilenameTemplate != nil {
+		filenameTemplate = *request.FilenameTemplate
+		if err := h.userService.SetFilenameTemplate(c.Request.Context(), firebaseUID, filenameTemplate); err != nil {
+			utils.InternalServerError(c, "Failed to update filename template")
+			return
+		}
+	}
+
 	utils.Success(c, gin.H{
-		"id":          updatedUser.ID.Hex(),
-		"email":       updatedUser.Email,
-		"displayName": updatedUser.DisplayName,
-		"photoURL":    updatedUser.PhotoURL,
+		"id":               updatedUser.ID.Hex(),
+		"email":            updatedUser.Email,
+		"displayName":      updatedUser.DisplayName,
+		"photoURL":         updatedUser.PhotoURL,
+		"filenameTemplate": filenameTemplate,
 	})
 }
 
@@ -201,12 +232,80 @@ func (h *AuthHandler) GetStats(c *gin.Context) {
 	utils.Success(c, stats)
 }
 
+// LargestFiles handles GET /api/v1/auth/largest-files?limit=10
+// Lists the caller's largest stored files, to pick what to delete when
+// running out of storage
+func (h *AuthHandler) LargestFiles(c *gin.Context) {
+	firebaseUID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "Not authenticated")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		utils.BadRequest(c, "limit must be between 1 and 50")
+		return
+	}
+
+	files, err := h.userService.LargestFiles(c.Request.Context(), firebaseUID, limit)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list files")
+		return
+	}
+
+	utils.Success(c, gin.H{"files": files})
+}
+
+// StorageBreakdown handles GET /api/v1/auth/storage-breakdown
+// Splits the caller's storage usage by library, saved outputs and voice
+// notes, with their ten largest files
+func (h *AuthHandler) StorageBreakdown(c *gin.Context) {
+	firebaseUID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "Not authenticated")
+		return
+	}
+
+	breakdown, err := h.userService.StorageBreakdown(c.Request.Context(), firebaseUID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to compute storage usage")
+		return
+	}
+
+	utils.Success(c, breakdown)
+}
+
+// Activity handles GET /api/v1/auth/activity?limit=50&action=login
+// Lists recent sign-ins, plan changes and deletions on the caller's account
+func (h *AuthHandler) Activity(c *gin.Context) {
+	firebaseUID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "Not authenticated")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		utils.BadRequest(c, "limit must be between 1 and 200")
+		return
+	}
+
+	entries, err := h.activity.List(c.Request.Context(), firebaseUID, c.Query("action"), limit)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch activity")
+		return
+	}
+
+	utils.Success(c, gin.H{"activity": entries})
+}
+
 // RegisterRoutes registers all auth routes
 func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	auth := r.Group("/auth")
 	{
 		// Public routes
-		auth.POST("/google", h.GoogleAuth)
+		auth.POST("/google", middleware.RequireCapability(h.capabilities, services.CapabilityAuth), h.GoogleAuth)
 
 		// Protected routes
 		auth.GET("/me", authMiddleware, h.GetMe)
@@ -214,5 +313,8 @@ func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.Hand
 		auth.PUT("/profile", authMiddleware, h.UpdateProfile)
 		auth.POST("/sync-storage", authMiddleware, h.SyncStorage)
 		auth.GET("/stats", authMiddleware, h.GetStats)
+		auth.GET("/largest-files", authMiddleware, h.LargestFiles)
+		auth.GET("/storage-breakdown", authMiddleware, h.StorageBreakdown)
+		auth.GET("/activity", authMiddleware, h.Activity)
 	}
 }
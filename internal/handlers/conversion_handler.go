@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
 
@@ -18,17 +20,21 @@ import (
 // ConversionHandler handles document conversion endpoints
 type ConversionHandler struct {
 	conversionService *services.ConversionService
+	userService       *services.UserService
+	capabilities      *services.CapabilityRegistry
 	maxFileSize       int64  // in bytes
 	tempDir           string
 }
 
 // NewConversionHandler creates a new conversion handler
-func NewConversionHandler(conversionService *services.ConversionService) *ConversionHandler {
+func NewConversionHandler(conversionService *services.ConversionService, userService *services.UserService, capabilities *services.CapabilityRegistry) *ConversionHandler {
 	tempDir := filepath.Join(os.TempDir(), "brainy-pdf-convert", "uploads")
 	os.MkdirAll(tempDir, 0755)
 
 	return &ConversionHandler{
 		conversionService: conversionService,
+		userService:       userService,
+		capabilities:      capabilities,
 		maxFileSize:       50 * 1024 * 1024, // 50MB per file
 		tempDir:           tempDir,
 	}
@@ -47,8 +53,15 @@ var allowedInputTypes = map[string]string{
 }
 
 // Convert handles POST /api/v1/convert
-// Accepts multiple files and output format, returns jobId
+// Accepts multiple files and output format, returns jobId. The job is
+// queued in the lane of the caller's plan. A ZIP upload is extracted and
+// every convertible file in it joins the job; entries lists what happened
+// to each file of the archive. Spreadsheets converted to PDF take
+// orientation, fitToWidth, gridlines and sheets.
 func (h *ConversionHandler) Convert(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	lane := h.userService.QueueLane(c.Request.Context(), userID)
+
 	outputFormat := c.DefaultPostForm("outputFormat", "pdf")
 	outputFormat = strings.ToLower(strings.TrimSpace(outputFormat))
 
@@ -66,6 +79,12 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 		return
 	}
 
+	spreadsheet, err := spreadsheetOptions(c, outputFormat)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
 	// Parse multipart form
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -74,6 +93,10 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 	}
 
 	files := form.File["files"]
+	if len(files) == 0 && len(form.File["file"]) > 0 && services.IsZipFilename(form.File["file"][0].Filename) {
+		// An archive goes through the multi-file path, like the files in it
+		files = form.File["file"][:1]
+	}
 	if len(files) == 0 {
 		// Try single file field
 		file, header, err := c.Request.FormFile("file")
@@ -90,7 +113,7 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 			return
 		}
 
-		jobID, err := h.conversionService.SubmitJob([]string{tempPath}, []string{originalName}, outputFormat)
+		jobID, err := h.conversionService.SubmitJob(lane, []string{tempPath}, []string{originalName}, outputFormat, services.ConversionOptions{Spreadsheet: spreadsheet})
 		if err != nil {
 			os.Remove(tempPath)
 			utils.InternalServerError(c, "Failed to queue job: "+err.Error())
@@ -101,6 +124,7 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 			"jobId":     jobID,
 			"fileCount": 1,
 			"status":    "queued",
+			"lane":      lane,
 		})
 		return
 	}
@@ -108,8 +132,30 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 	// Validate all files first
 	var tempPaths []string
 	var originalNames []string
+	var entries []models.ZipEntryResult // manifest of uploaded archives
+	taken := make(map[string]bool)
 
 	for _, fileHeader := range files {
+		if services.IsZipFilename(fileHeader.Filename) {
+			file, err := fileHeader.Open()
+			if err != nil {
+				h.cleanupFiles(tempPaths)
+				utils.BadRequest(c, "Failed to read file: "+fileHeader.Filename)
+				return
+			}
+			zipPaths, zipNames, zipEntries, err := h.saveZipEntries(file, fileHeader, outputFormat, taken)
+			file.Close()
+			if err != nil {
+				h.cleanupFiles(tempPaths)
+				utils.BadRequest(c, fileHeader.Filename+": "+err.Error())
+				return
+			}
+			tempPaths = append(tempPaths, zipPaths...)
+			originalNames = append(originalNames, zipNames...)
+			entries = append(entries, zipEntries...)
+			continue
+		}
+
 		if fileHeader.Size > h.maxFileSize {
 			h.cleanupFiles(tempPaths)
 			utils.BadRequest(c, fmt.Sprintf("File %s exceeds max size of 50MB", fileHeader.Filename))
@@ -132,23 +178,33 @@ func (h *ConversionHandler) Convert(c *gin.Context) {
 			return
 		}
 
+		originalName, _ = uniqueOutputName(originalName, outputFormat, taken)
 		tempPaths = append(tempPaths, tempPath)
 		originalNames = append(originalNames, originalName)
 	}
+	if len(tempPaths) == 0 {
+		utils.BadRequest(c, "No files in the archive can be converted to "+outputFormat)
+		return
+	}
 
 	// Submit job
-	jobID, err := h.conversionService.SubmitJob(tempPaths, originalNames, outputFormat)
+	jobID, err := h.conversionService.SubmitJob(lane, tempPaths, originalNames, outputFormat, services.ConversionOptions{Spreadsheet: spreadsheet})
 	if err != nil {
 		h.cleanupFiles(tempPaths)
 		utils.InternalServerError(c, "Failed to queue job: "+err.Error())
 		return
 	}
 
-	utils.Success(c, gin.H{
+	response := gin.H{
 		"jobId":     jobID,
 		"fileCount": len(tempPaths),
 		"status":    "queued",
-	})
+		"lane":      lane,
+	}
+	if entries != nil {
+		response["entries"] = entries
+	}
+	utils.Success(c, response)
 }
 
 // saveUploadedFile validates and saves an uploaded file
@@ -193,7 +249,7 @@ func (h *ConversionHandler) saveUploadedFile(file io.Reader, filename string, si
 		return "", "", fmt.Errorf("file %s is empty", filename)
 	}
 
-	return tempPath, filename, nil
+	return tempPath, services.NormalizeFilename(filename), nil
 }
 
 // cleanupFiles removes temporary files
@@ -204,6 +260,7 @@ func (h *ConversionHandler) cleanupFiles(paths []string) {
 }
 
 // Status handles GET /api/v1/convert/status/:jobId
+// Queued jobs also report their queuePosition, from 1 for the next served
 func (h *ConversionHandler) Status(c *gin.Context) {
 	jobID := c.Param("jobId")
 	if jobID == "" {
@@ -211,22 +268,34 @@ func (h *ConversionHandler) Status(c *gin.Context) {
 		return
 	}
 
+	if h.conversionService == nil {
+		utils.ServiceDisabled(c, services.CapabilityConversion, h.capabilities.Get(services.CapabilityConversion).Reason)
+		return
+	}
+
 	job, err := h.conversionService.GetJob(jobID)
 	if err != nil {
 		utils.NotFound(c, "Job not found")
 		return
 	}
 
-	utils.Success(c, gin.H{
+	response := gin.H{
 		"jobId":          job.ID,
 		"status":         job.Status,
+		"lane":           job.Lane,
 		"progress":       job.Progress,
 		"processedFiles": job.ProcessedFiles,
 		"totalFiles":     job.TotalFiles,
 		"error":          job.Error,
 		"createdAt":      job.CreatedAt,
 		"completedAt":    job.CompletedAt,
-	})
+	}
+	if job.Status == services.JobStatusQueued {
+		if position, err := h.conversionService.QueuePosition(c.Request.Context(), job.ID); err == nil && position > 0 {
+			response["queuePosition"] = position
+		}
+	}
+	utils.Success(c, response)
 }
 
 // Download handles GET /api/v1/convert/download/:jobId
@@ -238,26 +307,17 @@ func (h *ConversionHandler) Download(c *gin.Context) {
 		return
 	}
 
-	resultPath, filename, err := h.conversionService.GetResultPath(jobID)
-	if err != nil {
-		utils.BadRequest(c, err.Error())
+	if h.conversionService == nil {
+		utils.ServiceDisabled(c, services.CapabilityConversion, h.capabilities.Get(services.CapabilityConversion).Reason)
 		return
 	}
 
-	// Check file exists
-	fileInfo, err := os.Stat(resultPath)
+	result, filename, size, err := h.conversionService.OpenResult(c.Request.Context(), jobID)
 	if err != nil {
-		utils.NotFound(c, "Result file not found")
-		return
-	}
-
-	// Open file
-	file, err := os.Open(resultPath)
-	if err != nil {
-		utils.InternalServerError(c, "Failed to read result file")
+		utils.BadRequest(c, err.Error())
 		return
 	}
-	defer file.Close()
+	defer result.Close()
 
 	// Determine content type
 	contentType := "application/octet-stream"
@@ -274,14 +334,14 @@ func (h *ConversionHandler) Download(c *gin.Context) {
 	}
 
 	// Set headers for forced download
-	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", filename))
 	c.Header("Content-Type", contentType)
-	c.Header("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
 	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 
 	// Stream file
 	c.Status(200)
-	io.Copy(c.Writer, file)
+	io.Copy(c.Writer, result)
 }
 
 // Formats handles GET /api/v1/convert/formats
@@ -299,7 +359,8 @@ func (h *ConversionHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gi
 	convert := r.Group("/convert")
 	convert.Use(authMiddleware)
 	{
-		convert.POST("", h.Convert)
+		convert.POST("", middleware.RequireCapability(h.capabilities, services.CapabilityConversion), h.Convert)
+		convert.POST("/handout", middleware.RequireCapability(h.capabilities, services.CapabilityConversion), h.Handout)
 		convert.GET("/status/:jobId", h.Status)
 		convert.GET("/download/:jobId", h.Download)
 		convert.GET("/formats", h.Formats)
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handout handles POST /api/v1/convert/handout
+// Accepts a presentation (ppt or pptx) as file, slidesPerPage (2, 4 or 6;
+// default 4) and pageSize (A4 or Letter), and queues a job converting it
+// into a PDF handout: the slides down the left of each page with lines for
+// notes beside them. The job is followed with /convert/status and
+// /convert/download like any other conversion.
+func (h *ConversionHandler) Handout(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	lane := h.userService.QueueLane(c.Request.Context(), userID)
+
+	slidesPerPage := 0
+	if v := c.PostForm("slidesPerPage"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			utils.BadRequest(c, "slidesPerPage must be 2, 4 or 6")
+			return
+		}
+		slidesPerPage = n
+	}
+	handout, err := services.NewHandoutOptions(slidesPerPage, c.PostForm("pageSize"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No file provided")
+		return
+	}
+	defer file.Close()
+	if !services.IsPresentationFile(header.Filename) {
+		utils.BadRequest(c, "Handouts are made from presentations (ppt or pptx)")
+		return
+	}
+	if header.Size > h.maxFileSize {
+		utils.BadRequest(c, "File size must be less than 50MB")
+		return
+	}
+
+	tempPath, originalName, err := h.saveUploadedFile(file, header.Filename, header.Size, "pdf")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	jobID, err := h.conversionService.SubmitJob(lane, []string{tempPath}, []string{originalName}, "pdf", services.ConversionOptions{Handout: handout})
+	if err != nil {
+		os.Remove(tempPath)
+		utils.InternalServerError(c, "Failed to queue job: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"jobId":     jobID,
+		"fileCount": 1,
+		"status":    "queued",
+		"lane":      lane,
+		"handout":   handout,
+	})
+}
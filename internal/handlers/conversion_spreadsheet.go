@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"brainy-pdf/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spreadsheetOptions reads the layout of spreadsheets converted to PDF:
+// orientation, fitToWidth, gridlines and sheets (names, repeated or
+// comma-separated). Returns nil when none are given.
+func spreadsheetOptions(c *gin.Context, outputFormat string) (*services.SpreadsheetOptions, error) {
+	var fitToWidth, gridlines bool
+	for _, f := range []struct {
+		name  string
+		value *bool
+	}{{"fitToWidth", &fitToWidth}, {"gridlines", &gridlines}} {
+		if v := c.PostForm(f.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be true or false", services.ErrInvalidSpreadsheetOptions, f.name)
+			}
+			*f.value = b
+		}
+	}
+
+	opts, err := services.NewSpreadsheetOptions(c.PostForm("orientation"), fitToWidth, gridlines, formList(c, "sheets"))
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && outputFormat != "pdf" {
+		return nil, fmt.Errorf("%w: they apply to PDF output only", services.ErrInvalidSpreadsheetOptions)
+	}
+	return opts, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+)
+
+// saveZipEntries extracts the convertible files of an uploaded archive to
+// temp files for the job and reports every entry in the manifest. Entries
+// that can't be converted are skipped rather than failing the upload.
+// taken holds the output names already in the job, so files with the same
+// name in different folders don't overwrite each other in the result.
+func (h *ConversionHandler) saveZipEntries(file multipart.File, header *multipart.FileHeader, outputFormat string, taken map[string]bool) ([]string, []string, []models.ZipEntryResult, error) {
+	if header.Size > h.maxFileSize {
+		return nil, nil, nil, fmt.Errorf("archive %s exceeds max size of 50MB", header.Filename)
+	}
+	archive, err := services.OpenZip(file, header.Size, h.maxFileSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var tempPaths, originalNames []string
+	var entries []models.ZipEntryResult
+	for _, e := range archive.Entries {
+		res := models.ZipEntryResult{Name: e.Name, Size: e.Size, Status: models.ZipEntrySkipped}
+		ext := strings.ToLower(path.Ext(e.Name))
+		if _, ok := allowedInputTypes[ext]; !ok || !services.IsValidConversion(ext, outputFormat) {
+			res.Reason = fmt.Sprintf("cannot convert %s to %s", strings.TrimPrefix(ext, "."), outputFormat)
+			entries = append(entries, res)
+			continue
+		}
+
+		data, err := archive.ReadAll(e)
+		if errors.Is(err, services.ErrZipTooLarge) {
+			h.cleanupFiles(tempPaths)
+			return nil, nil, nil, err
+		}
+		if err != nil {
+			res.Status, res.Reason = models.ZipEntryFailed, err.Error()
+			entries = append(entries, res)
+			continue
+		}
+
+		tempPath, originalName, err := h.saveUploadedFile(bytes.NewReader(data), e.Name, int64(len(data)), outputFormat)
+		if err != nil {
+			res.Status, res.Reason = models.ZipEntryFailed, err.Error()
+			entries = append(entries, res)
+			continue
+		}
+		originalName, res.OutputName = uniqueOutputName(originalName, outputFormat, taken)
+
+		tempPaths = append(tempPaths, tempPath)
+		originalNames = append(originalNames, originalName)
+		res.Status, res.Size = models.ZipEntryQueued, int64(len(data))
+		entries = append(entries, res)
+	}
+	for _, s := range archive.Skipped {
+		entries = append(entries, models.ZipEntryResult{Name: s.Name, Size: s.Size, Status: models.ZipEntrySkipped, Reason: s.Reason})
+	}
+	return tempPaths, originalNames, entries, nil
+}
+
+// uniqueOutputName numbers name ("report (2).docx") until its converted
+// name isn't taken, and returns both
+func uniqueOutputName(name, outputFormat string, taken map[string]bool) (string, string) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := stem
+	for n := 2; taken[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)", stem, n)
+	}
+	taken[strings.ToLower(candidate)] = true
+	return candidate + ext, candidate + "." + outputFormat
+}
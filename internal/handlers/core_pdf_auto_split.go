@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AutoSplitPDF handles POST /api/pdf/auto-split
+// Accepts file (or fileId) holding several documents, such as a stack of
+// invoices scanned together, finds where each starts from page text and
+// layout, has the AI confirm the boundaries and name each document, and
+// stores one PDF per document named by vendor and date. ai=false keeps to
+// the layout; dryRun=true only reports the documents found.
+func (h *CorePDFHandler) AutoSplitPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "auto-split", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		utils.BadRequest(c, "File must be a PDF")
+		return
+	}
+	maxSize := h.getMaxFileSize(c, userID)
+	if header.Size > maxSize {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", "File too large", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
+		return
+	}
+
+	// AI is used when available unless ai=false; ai=true requires it
+	ai := h.capabilities.Get(services.CapabilityAI)
+	useAI, dryRun := ai.Available, false
+	if v := c.PostForm("ai"); v != "" {
+		if useAI, err = strconv.ParseBool(v); err != nil {
+			utils.BadRequest(c, "ai must be true or false")
+			return
+		}
+		if useAI && !ai.Available {
+			utils.ServiceDisabled(c, services.CapabilityAI, ai.Reason)
+			return
+		}
+	}
+	if v := c.PostForm("dryRun"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			utils.BadRequest(c, "dryRun must be true or false")
+			return
+		}
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	tracker := h.startProgress(c, userID, "auto-split")
+	defer finishProgress(c, tracker)
+
+	pages, err := h.pdfService.ReadDocumentPages(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidAutoSplit) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to read PDF: "+err.Error())
+		return
+	}
+	hasText := false
+	for _, page := range pages {
+		hasText = hasText || page.HasText()
+	}
+	if !hasText {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", "No text layer", 0, startTime)
+		utils.BadRequest(c, "No text found to detect documents by. The PDF may be scanned without a text layer; run OCR on it first.")
+		return
+	}
+
+	res := &models.AutoSplitResult{
+		OperationResult: models.OperationResult{Outputs: []models.OperationOutput{}},
+		Files:           []models.OperationOutput{},
+		InputFile:       header.Filename,
+		InputPages:      len(pages),
+		DryRun:          dryRun,
+		Documents:       services.DetectDocuments(pages),
+	}
+	if useAI {
+		// The layout boundaries still stand when the model fails
+		if docs, err := h.aiService.ClassifyDocuments(c.Request.Context(), pages, res.Documents); err == nil {
+			res.Documents, res.AIAssisted = docs, true
+		} else {
+			log.Printf("[AutoSplit] AI classification failed, keeping layout boundaries: %v", err)
+		}
+	}
+
+	if dryRun {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "success", "", len(pages), startTime)
+		utils.Success(c, res)
+		return
+	}
+
+	ranges := services.AutoSplitRanges(res.Documents)
+	pagesPerRange := rangePages(ranges)
+	tracker.SetTotalPages(c.Request.Context(), len(pages))
+	result, err := h.pdfService.Split(c.Request.Context(), in, ranges, rangeProgress(c.Request.Context(), tracker, pagesPerRange, nil))
+	if err != nil {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to split PDF: "+err.Error())
+		return
+	}
+
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	used := map[string]int{}
+	for i, splitData := range result.Files {
+		doc := &res.Documents[i]
+		outputFilename := h.outputName(c, userID, "auto-split", header.Filename, doc.Range, autoSplitFilename(*doc, baseName, i, used))
+
+		uploadResult, failed := uploadPart(c.Request.Context(), doc.Range, outputFilename, func() (*services.UploadResult, error) {
+			return h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, splitData, "application/pdf")
+		})
+		tracker.Pages(c.Request.Context(), models.ProgressStageUploading, sumPages(pagesPerRange, i+1))
+		if failed != nil {
+			res.FailedParts = append(res.FailedParts, *failed)
+			continue
+		}
+
+		output := outputFromUpload(uploadResult, doc.LastPage-doc.FirstPage+1)
+		output.Range = doc.Range
+		res.Files = append(res.Files, output)
+		doc.Filename, doc.FileID = output.Filename, output.FileID
+	}
+
+	if len(res.Files) == 0 {
+		h.logOperation(c, userID, "auto-split", []string{header.Filename}, "", "error", "No files created", 0, startTime)
+		utils.InternalServerError(c, "Failed to create any split files: "+res.FailedParts[0].Error)
+		return
+	}
+
+	res.TotalFiles = len(res.Files)
+	res.SetOutputs(res.Files...)
+	h.recordResult(c, userID, "auto-split", []string{header.Filename}, res, len(pages), startTime)
+
+	utils.Success(c, res)
+}
+
+// autoSplitFilename names a detected document by its vendor and date, such
+// as "Acme Ltd_2024-03-01.pdf", falling back to its type or the input name
+// and its position. used counts names already given so repeats get "_2".
+func autoSplitFilename(doc models.DetectedDocument, baseName string, index int, used map[string]int) string {
+	var parts []string
+	for _, part := range []string{doc.Vendor, doc.Date} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 1 && doc.DocumentType != "" {
+		parts = append([]string{doc.DocumentType}, parts...)
+	}
+	if len(parts) == 0 {
+		parts = []string{baseName, fmt.Sprintf("document%d", index+1)}
+	}
+
+	name := strings.Join(parts, "_")
+	used[strings.ToLower(name)]++
+	if n := used[strings.ToLower(name)]; n > 1 {
+		name = fmt.Sprintf("%s_%d", name, n)
+	}
+	return services.NormalizeFilename(name + ".pdf")
+}
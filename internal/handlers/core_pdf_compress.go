@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// compressPhotos is CompressPDF in photo mode. Like large-file mode it
+// works from a spooled copy of the input and writes its output to disk.
+func (h *CorePDFHandler) compressPhotos(c *gin.Context, file multipart.File, header *multipart.FileHeader, userID, quality string, opts services.PhotoCompressOptions, dryRun bool, startTime time.Time) {
+	progress := progressLogger("compress", header.Filename)
+	scratch, ok := h.newScratch(c, userID, "compress", header.Filename, startTime)
+	if !ok {
+		return
+	}
+	defer scratch.Close()
+
+	inPath, err := h.pdfService.SpoolToDisk(c.Request.Context(), scratch, file, header.Size, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	if err := h.pdfService.ValidatePDFFile(inPath); err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+	pageCount, _ := h.pdfService.GetPageCountFile(inPath)
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to read file")
+		return
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		utils.InternalServerError(c, "Failed to read file")
+		return
+	}
+	originalSize := info.Size()
+
+	// A dry run only measures; nothing is written
+	var out io.Writer
+	outPath := scratch.Path("compress_photo.pdf")
+	if !dryRun {
+		f, err := os.Create(outPath)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to create output file")
+			return
+		}
+		defer f.Close()
+		out = scratch.Writer(f)
+	}
+
+	profile, photo, err := h.pdfService.CompressPhotos(c.Request.Context(), in, originalSize, out, opts, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "Failed to compress PDF: "+err.Error())
+		return
+	}
+
+	if dryRun {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "success", "", pageCount, startTime)
+		utils.Success(c, compressEstimate(header.Filename, services.CompressModePhoto, quality, originalSize, originalSize-photo.EstimatedSavings, profile, photo))
+		return
+	}
+
+	compressed, err := os.Stat(outPath)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save compressed PDF")
+		return
+	}
+
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	outputFilename := h.outputName(c, userID, "compress", header.Filename, "", fmt.Sprintf("%s_compressed.pdf", baseName))
+
+	uploadResult, err := h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save compressed PDF: "+err.Error())
+		return
+	}
+
+	res := &models.CompressResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		OriginalSize:     originalSize,
+		CompressedSize:   compressed.Size(),
+		Reduction:        reductionPercent(originalSize, compressed.Size()),
+		Quality:          quality,
+		Mode:             services.CompressModePhoto,
+		LargeFileMode:    isLargeFile(originalSize),
+		ImageProfile:     profile,
+		Photo:            photo,
+	}
+	h.recordResult(c, userID, "compress", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// imageProfileFile profiles the images of the PDF at path, or returns nil
+func (h *CorePDFHandler) imageProfileFile(path string) *models.ImageProfile {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	profile, _ := h.pdfService.ImageProfile(f, info.Size())
+	return profile
+}
+
+// compressEstimate reports the outcome of a compress dry run
+func compressEstimate(filename, mode, quality string, originalSize, estimatedSize int64, profile *models.ImageProfile, photo *models.PhotoCompression) *models.CompressEstimate {
+	return &models.CompressEstimate{
+		Filename:           filename,
+		Mode:               mode,
+		Quality:            quality,
+		OriginalSize:       originalSize,
+		EstimatedSize:      estimatedSize,
+		EstimatedReduction: reductionPercent(originalSize, estimatedSize),
+		ImageProfile:       profile,
+		Photo:              photo,
+		DryRun:             true,
+	}
+}
+
+// reductionPercent formats how much smaller after is than before, never
+// below 0%
+func reductionPercent(before, after int64) string {
+	if before <= 0 || after >= before {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(before-after)/float64(before)*100)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ScanDocument handles POST /api/pdf/scan-document
+// Accepts phone photos of paper pages ("images", JPEG or PNG, in page
+// order) and returns one scanned PDF with each page straightened and
+// enhanced. Optional mode (color, grayscale or bw), pageSize (A4 or
+// Letter) and corners, a JSON array holding each image's four page corners
+// (or null to find the page automatically).
+func (h *CorePDFHandler) ScanDocument(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		h.logOperation(c, userID, "scan-document", nil, "", "error", "Invalid form data", 0, startTime)
+		utils.BadRequest(c, "Invalid form data: "+err.Error())
+		return
+	}
+	photos := form.File["images"]
+	if len(photos) == 0 {
+		h.logOperation(c, userID, "scan-document", nil, "", "error", "No images provided", 0, startTime)
+		utils.BadRequest(c, "At least one image is required")
+		return
+	}
+	if len(photos) > services.MaxScanPhotos {
+		h.logOperation(c, userID, "scan-document", nil, "", "error", "Too many images", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("At most %d images can be scanned at once", services.MaxScanPhotos))
+		return
+	}
+
+	opts := services.DocumentScanOptions{Mode: c.PostForm("mode"), PageSize: c.PostForm("pageSize")}
+	if raw := strings.TrimSpace(c.PostForm("corners")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Corners); err != nil {
+			h.logOperation(c, userID, "scan-document", nil, "", "error", "Invalid corners", 0, startTime)
+			utils.BadRequest(c, "corners must be a JSON array holding four {x, y} points, or null, per image")
+			return
+		}
+	}
+	opts, err = services.NormalizeDocumentScanOptions(opts, len(photos))
+	if err != nil {
+		h.logOperation(c, userID, "scan-document", nil, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	tracker := h.startProgress(c, userID, "scan-document")
+	defer finishProgress(c, tracker)
+	tracker.SetTotalPages(c.Request.Context(), len(photos))
+
+	maxSize := h.getMaxFileSize(c, userID)
+	var data [][]byte
+	var inputFileNames []string
+	for _, header := range photos {
+		if header.Size > maxSize {
+			h.logOperation(c, userID, "scan-document", inputFileNames, "", "error", "File too large", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Image '%s' exceeds your plan limit of %d MB", header.Filename, maxSize/(1024*1024)))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.logOperation(c, userID, "scan-document", inputFileNames, "", "error", "Failed to open file", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Failed to read image '%s'", header.Filename))
+			return
+		}
+		photo, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			h.logOperation(c, userID, "scan-document", inputFileNames, "", "error", "Failed to read file", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Failed to read image '%s'", header.Filename))
+			return
+		}
+		data = append(data, photo)
+		inputFileNames = append(inputFileNames, header.Filename)
+	}
+
+	tracker.Pages(c.Request.Context(), models.ProgressStageProcessing, 0)
+	result, pages, err := h.pdfService.ScanDocument(c.Request.Context(), data, opts, func(stage string, done, total int64) {
+		tracker.Pages(c.Request.Context(), models.ProgressStageProcessing, int(done))
+	})
+	if err != nil {
+		h.logOperation(c, userID, "scan-document", inputFileNames, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidDocumentScan) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to scan document: "+err.Error())
+		return
+	}
+	tracker.Pages(c.Request.Context(), models.ProgressStageUploading, 0)
+
+	outputFilename := h.outputName(c, userID, "scan-document", inputFileNames[0], "", "scan_"+time.Now().Format("20060102_150405")+".pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		h.logOperation(c, userID, "scan-document", inputFileNames, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save scanned PDF: "+err.Error())
+		return
+	}
+
+	res := &models.DocumentScanResult{
+		SingleFileResult: singleFileResult(uploadResult, len(pages)),
+		Mode:             opts.Mode,
+		PageSize:         opts.PageSize,
+		Pages:            pages,
+	}
+	h.recordResult(c, userID, "scan-document", inputFileNames, res, len(pages), startTime)
+
+	utils.Success(c, res)
+}
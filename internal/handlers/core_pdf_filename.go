@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Output filenames follow a template with tokens like {original} and
+// {date}, taken from the request's "filenameTemplate" field or else the
+// user's default. Without either, each operation keeps its built-in name.
+
+// filenameTemplateKey holds the request's validated filename template
+const filenameTemplateKey = "filenameTemplate"
+
+// checkFilenameTemplate rejects a request whose "filenameTemplate" is
+// invalid before any work is done, and keeps a valid one for outputName
+func checkFilenameTemplate(c *gin.Context) {
+	if c.Request.Method != http.MethodPost {
+		c.Next()
+		return
+	}
+	if err := bindJSONForm(c); err != nil {
+		utils.BadRequest(c, err.Error())
+		c.Abort()
+		return
+	}
+
+	if tmpl := strings.TrimSpace(c.PostForm("filenameTemplate")); tmpl != "" {
+		if err := services.ValidateFilenameTemplate(tmpl); err != nil {
+			utils.BadRequest(c, err.Error())
+			c.Abort()
+			return
+		}
+		c.Set(filenameTemplateKey, tmpl)
+	}
+	c.Next()
+}
+
+// outputName names an operation's output from the filename template, or
+// returns fallback, the operation's built-in name, when there is none.
+// original is the input filename; rangeName names a split part, and is
+// appended when the template has no {range} so parts don't collide.
+func (h *CorePDFHandler) outputName(c *gin.Context, userID, operation, original, rangeName, fallback string) string {
+	if _, ok := c.Get(filenameTemplateKey); !ok {
+		// Look the user's default up once per request
+		var tmpl string
+		if userID != "" {
+			if user, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), userID); err == nil {
+				tmpl = user.FilenameTemplate
+			}
+		}
+		c.Set(filenameTemplateKey, tmpl)
+	}
+	tmpl := c.GetString(filenameTemplateKey)
+	if tmpl == "" || services.ValidateFilenameTemplate(tmpl) != nil {
+		return fallback
+	}
+	if rangeName != "" && !strings.Contains(tmpl, "{range}") {
+		tmpl += "_{range}"
+	}
+
+	return services.RenderFilename(tmpl, services.FilenameVars{
+		Original:  strings.TrimSuffix(original, filepath.Ext(original)),
+		Operation: operation,
+		Range:     rangeName,
+		Time:      time.Now(),
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// FormFields handles POST /api/pdf/form/fields
+// Accepts file (or fileId) and lists its AcroForm fields: name, type,
+// pages, current value and, for choice fields, the options. PDFs without
+// a form have no fields.
+func (h *CorePDFHandler) FormFields(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "form-fields", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "form-fields", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	fields, err := h.pdfService.FormFields(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "form-fields", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to read form fields: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(in)
+	res := &models.FormFieldsResult{PageCount: pageCount, Fields: fields}
+	if stored {
+		res.FileID = strings.TrimSpace(c.PostForm("fileId"))
+	}
+	h.logOperation(c, userID, "form-fields", []string{header.Filename}, "", "success", "", pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// FillForm handles POST /api/pdf/form/fill
+// Accepts file (or fileId) and values, a JSON object of field name (or ID)
+// to value: text for text, date, radio and combo fields, true/false (or
+// "yes"/"no") for checkboxes, a list for multi-select list boxes. The AI
+// auto-fill suggestions can be passed as {fieldName: suggestedValue}. With
+// flatten=true the values are burned into the pages as well.
+func (h *CorePDFHandler) FillForm(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "form-fill", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(c.PostForm("values")), &values); err != nil {
+		utils.BadRequest(c, "values must be a JSON object of field names to values")
+		return
+	}
+	flatten := c.PostForm("flatten") == "true"
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "form-fill", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, filled, err := h.pdfService.FillForm(c.Request.Context(), in, values)
+	if err == nil && flatten {
+		result, _, err = h.pdfService.FlattenForm(c.Request.Context(), bytes.NewReader(result))
+	}
+	if err != nil {
+		h.logOperation(c, userID, "form-fill", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		respondFormError(c, "Failed to fill form: ", err)
+		return
+	}
+
+	suffix := "_filled.pdf"
+	if flatten {
+		suffix = "_filled_flat.pdf"
+	}
+	outputFilename := h.outputName(c, userID, "form-fill", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+suffix)
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.FormFillResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Filled:           filled,
+		Flattened:        flatten,
+	}
+	h.recordResult(c, userID, "form-fill", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// FlattenForm handles POST /api/pdf/form/flatten
+// Accepts file (or fileId) and burns its form field values into the page
+// content, removing the fields so they can no longer be edited.
+func (h *CorePDFHandler) FlattenForm(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "form-flatten", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "form-flatten", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, widgets, err := h.pdfService.FlattenForm(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "form-flatten", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		respondFormError(c, "Failed to flatten form: ", err)
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "form-flatten", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_flat.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.FormFlattenResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Widgets:          widgets,
+	}
+	h.recordResult(c, userID, "form-flatten", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+func respondFormError(c *gin.Context, prefix string, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidForm), errors.Is(err, services.ErrNoForm):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, prefix+err.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// FromImages handles POST /api/pdf/from-images
+// Accepts JPEG or PNG images ("images", in page order) and assembles them
+// into one PDF, a page per image. Optional pageSize (A4, Letter, Legal or
+// Fit for pages the size of each image), orientation (portrait, landscape
+// or auto to follow each image) and margin in points.
+func (h *CorePDFHandler) FromImages(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		h.logOperation(c, userID, "from-images", nil, "", "error", "Invalid form data", 0, startTime)
+		utils.BadRequest(c, "Invalid form data: "+err.Error())
+		return
+	}
+	images := form.File["images"]
+	if len(images) == 0 {
+		h.logOperation(c, userID, "from-images", nil, "", "error", "No images provided", 0, startTime)
+		utils.BadRequest(c, "At least one image is required")
+		return
+	}
+	if len(images) > services.MaxImagesToPDF {
+		h.logOperation(c, userID, "from-images", nil, "", "error", "Too many images", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("At most %d images can be assembled at once", services.MaxImagesToPDF))
+		return
+	}
+
+	opts := services.ImagesToPDFOptions{PageSize: c.PostForm("pageSize"), Orientation: c.PostForm("orientation")}
+	if v := strings.TrimSpace(c.PostForm("margin")); v != "" {
+		if opts.Margin, err = strconv.ParseFloat(v, 64); err != nil {
+			h.logOperation(c, userID, "from-images", nil, "", "error", "Invalid margin", 0, startTime)
+			utils.BadRequest(c, "margin must be a number of points")
+			return
+		}
+	}
+	opts, err = services.NormalizeImagesToPDFOptions(opts)
+	if err != nil {
+		h.logOperation(c, userID, "from-images", nil, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	maxSize := h.getMaxFileSize(c, userID)
+	var data [][]byte
+	var inputFileNames []string
+	for _, header := range images {
+		if header.Size > maxSize {
+			h.logOperation(c, userID, "from-images", inputFileNames, "", "error", "File too large", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Image '%s' exceeds your plan limit of %d MB", header.Filename, maxSize/(1024*1024)))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.logOperation(c, userID, "from-images", inputFileNames, "", "error", "Failed to open file", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Failed to read image '%s'", header.Filename))
+			return
+		}
+		image, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			h.logOperation(c, userID, "from-images", inputFileNames, "", "error", "Failed to read file", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("Failed to read image '%s'", header.Filename))
+			return
+		}
+		data = append(data, image)
+		inputFileNames = append(inputFileNames, header.Filename)
+	}
+
+	result, err := h.pdfService.ImagesToPDF(c.Request.Context(), data, opts)
+	if err != nil {
+		h.logOperation(c, userID, "from-images", inputFileNames, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidImagesToPDF) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to create PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "from-images", inputFileNames[0], "", "images_"+time.Now().Format("20060102_150405")+".pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		h.logOperation(c, userID, "from-images", inputFileNames, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save PDF: "+err.Error())
+		return
+	}
+
+	res := &models.FromImagesResult{
+		SingleFileResult: singleFileResult(uploadResult, len(data)),
+		PageSize:         opts.PageSize,
+		Orientation:      opts.Orientation,
+		Margin:           opts.Margin,
+	}
+	h.recordResult(c, userID, "from-images", inputFileNames, res, len(data), startTime)
+
+	utils.Success(c, res)
+}
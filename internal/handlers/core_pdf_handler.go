@@ -1,19 +1,30 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
 	"brainy-pdf/pkg/mongodb"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // CorePDFHandler handles core PDF operations (Phase 3)
@@ -22,30 +33,33 @@ type CorePDFHandler struct {
 	storageService *services.StorageService
 	userService    *services.UserService
 	mongoClient    *mongodb.Client
-}
-
-// OperationLog represents a logged PDF operation
-type OperationLog struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty"`
-	UserID        string             `bson:"userId,omitempty"`
-	Operation     string             `bson:"operation"`
-	InputFiles    []string           `bson:"inputFiles"`
-	OutputFileID  string             `bson:"outputFileId,omitempty"`
-	OutputFiles   []string           `bson:"outputFiles,omitempty"`
-	PageCount     int                `bson:"pageCount,omitempty"`
-	Status        string             `bson:"status"` // success, error
-	ErrorMessage  string             `bson:"errorMessage,omitempty"`
-	ProcessingMs  int64              `bson:"processingMs"`
-	CreatedAt     time.Time          `bson:"createdAt"`
+	signatures     *services.SignatureService
+	aiService      *services.AIService
+	capabilities   *services.CapabilityRegistry
+	logForwarder   *services.LogForwarder // nil unless logs are shipped to a SIEM
+	progress       *services.ProgressService
+	thumbnails     *services.ThumbnailService
+	jobs           *services.JobService // nil unless async=true is supported
+	preferences    *services.PreferencesService // nil unless users' defaults are applied
+
+	// jobEngine serves the routes to background jobs
+	jobRouterOnce sync.Once
+	jobEngine     *gin.Engine
 }
 
 // NewCorePDFHandler creates a new core PDF handler
-func NewCorePDFHandler(pdfService *services.PDFService, storageService *services.StorageService, userService *services.UserService, mongoClient *mongodb.Client) *CorePDFHandler {
+func NewCorePDFHandler(pdfService *services.PDFService, storageService *services.StorageService, userService *services.UserService, mongoClient *mongodb.Client, signatures *services.SignatureService, aiService *services.AIService, capabilities *services.CapabilityRegistry, logForwarder *services.LogForwarder, progress *services.ProgressService, thumbnails *services.ThumbnailService) *CorePDFHandler {
 	return &CorePDFHandler{
 		pdfService:     pdfService,
 		storageService: storageService,
 		userService:    userService,
 		mongoClient:    mongoClient,
+		signatures:     signatures,
+		aiService:      aiService,
+		capabilities:   capabilities,
+		logForwarder:   logForwarder,
+		progress:       progress,
+		thumbnails:     thumbnails,
 	}
 }
 
@@ -67,104 +81,170 @@ func (h *CorePDFHandler) MergePDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Parse multipart form
-	form, err := c.MultipartForm()
-	if err != nil {
-		h.logOperation(userID, "merge", nil, "", "error", "Invalid form data", 0, startTime)
-		utils.BadRequest(c, "Invalid form data: "+err.Error())
+	// Inputs are either uploaded "files" or the "fileIds" of files uploaded
+	// earlier, so clients can upload in parallel and merge with a small JSON
+	// call
+	if err := bindJSONForm(c); err != nil {
+		h.logOperation(c, userID, "merge", nil, "", "error", "Invalid JSON body", 0, startTime)
+		utils.BadRequest(c, err.Error())
 		return
 	}
+	fileIDs := formList(c, "fileIds")
 
-	files := form.File["files"]
-	if len(files) < 2 {
-		h.logOperation(userID, "merge", nil, "", "error", "Minimum 2 files required", 0, startTime)
+	var files []*multipart.FileHeader
+	if c.ContentType() != "application/json" {
+		form, err := c.MultipartForm()
+		if err != nil && len(fileIDs) == 0 {
+			h.logOperation(c, userID, "merge", nil, "", "error", "Invalid form data", 0, startTime)
+			utils.BadRequest(c, "Invalid form data: "+err.Error())
+			return
+		}
+		if form != nil {
+			files = form.File["files"]
+		}
+	}
+	if len(files) > 0 && len(fileIDs) > 0 {
+		h.logOperation(c, userID, "merge", nil, "", "error", "Both files and fileIds provided", 0, startTime)
+		utils.BadRequest(c, "Provide either \"files\" or \"fileIds\", not both")
+		return
+	}
+	if len(files)+len(fileIDs) < 2 {
+		h.logOperation(c, userID, "merge", nil, "", "error", "Minimum 2 files required", 0, startTime)
 		utils.BadRequest(c, "At least 2 PDF files required for merge")
 		return
 	}
 
-	// Validate and read all files
-	var pdfData [][]byte
+	tracker := h.startProgress(c, userID, "merge")
+	defer finishProgress(c, tracker)
+
+	// Inputs are spooled to disk and merged file-to-file, so the request
+	// never holds the PDFs in memory
+	scratch, err := h.pdfService.NewScratch("merge")
+	if err != nil {
+		h.logOperation(c, userID, "merge", nil, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to create temp dir")
+		return
+	}
+	defer scratch.Close()
+
+	var inPaths []string
 	var inputFileNames []string
+	var inputPages int
+
+	// add spools and validates one input, reporting false once a response
+	// has been sent
+	add := func(name string, file io.Reader, size int64) bool {
+		// Validate file size (max 50MB per file)
+		if size > 50*1024*1024 {
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "File too large", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("File '%s' exceeds 50MB limit", name))
+			return false
+		}
+
+		path, err := h.pdfService.SpoolToDisk(c.Request.Context(), scratch, file, size, nil)
+		if err != nil {
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Failed to read file", 0, startTime)
+			if !respondTempQuota(c, err) {
+				utils.BadRequest(c, fmt.Sprintf("Failed to read file '%s'", name))
+			}
+			return false
+		}
+
+		// Validate PDF structure
+		if err := h.pdfService.ValidatePDFFile(path); err != nil {
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Invalid PDF file", 0, startTime)
+			utils.BadRequest(c, fmt.Sprintf("File '%s' is not a valid PDF: %s", name, err.Error()))
+			return false
+		}
+
+		inPaths = append(inPaths, path)
+		inputFileNames = append(inputFileNames, name)
+		if tracker != nil {
+			pages, _ := h.pdfService.GetPageCountFile(path)
+			inputPages += pages
+			tracker.Pages(c.Request.Context(), models.ProgressStageReading, inputPages)
+		}
+		return true
+	}
 
 	for _, fileHeader := range files {
 		// Validate file type
 		if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".pdf") {
-			h.logOperation(userID, "merge", inputFileNames, "", "error", "Invalid file type", 0, startTime)
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Invalid file type", 0, startTime)
 			utils.BadRequest(c, fmt.Sprintf("File '%s' is not a PDF", fileHeader.Filename))
 			return
 		}
 
-		// Validate file size (max 50MB per file)
-		if fileHeader.Size > 50*1024*1024 {
-			h.logOperation(userID, "merge", inputFileNames, "", "error", "File too large", 0, startTime)
-			utils.BadRequest(c, fmt.Sprintf("File '%s' exceeds 50MB limit", fileHeader.Filename))
-			return
-		}
-
 		file, err := fileHeader.Open()
 		if err != nil {
-			h.logOperation(userID, "merge", inputFileNames, "", "error", "Failed to open file", 0, startTime)
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Failed to open file", 0, startTime)
 			utils.BadRequest(c, fmt.Sprintf("Failed to read file '%s'", fileHeader.Filename))
 			return
 		}
-
-		data, err := io.ReadAll(file)
+		ok := add(fileHeader.Filename, file, fileHeader.Size)
 		file.Close()
-		if err != nil {
-			h.logOperation(userID, "merge", inputFileNames, "", "error", "Failed to read file", 0, startTime)
-			utils.BadRequest(c, fmt.Sprintf("Failed to read file '%s'", fileHeader.Filename))
+		if !ok {
 			return
 		}
+	}
 
-		// Validate PDF structure
-		if err := h.pdfService.ValidatePDF(data); err != nil {
-			h.logOperation(userID, "merge", inputFileNames, "", "error", "Invalid PDF file", 0, startTime)
-			utils.BadRequest(c, fmt.Sprintf("File '%s' is not a valid PDF: %s", fileHeader.Filename, err.Error()))
+	for _, fileID := range fileIDs {
+		doc, file, size, err := h.storageService.OpenFileForUser(c.Request.Context(), fileID, userID)
+		if err != nil {
+			if errors.Is(err, services.ErrFileNotFound) || errors.Is(err, services.ErrFileAccessDenied) {
+				h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Stored file not found", 0, startTime)
+				utils.NotFound(c, fmt.Sprintf("File '%s' not found", fileID))
+				return
+			}
+			if errors.Is(err, services.ErrFileArchived) {
+				h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Stored file archived", 0, startTime)
+				respondArchived(c)
+				return
+			}
+			h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Failed to load stored file", 0, startTime)
+			utils.InternalServerError(c, "Failed to load file: "+err.Error())
+			return
+		}
+		ok := add(storedName(doc), file, size)
+		file.Close()
+		if !ok {
 			return
 		}
-
-		pdfData = append(pdfData, data)
-		inputFileNames = append(inputFileNames, fileHeader.Filename)
 	}
 
 	// Merge PDFs using pdfcpu
-	result, err := h.pdfService.Merge(c.Request.Context(), pdfData)
+	tracker.SetTotalPages(c.Request.Context(), inputPages)
+	tracker.Pages(c.Request.Context(), models.ProgressStageProcessing, 0)
+	outPath, err := h.pdfService.MergeFiles(c.Request.Context(), scratch, inPaths, nil)
 	if err != nil {
-		h.logOperation(userID, "merge", inputFileNames, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "merge", inputFileNames, "", "error", err.Error(), 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
 		utils.InternalServerError(c, "Failed to merge PDFs: "+err.Error())
 		return
 	}
+	tracker.Pages(c.Request.Context(), models.ProgressStageUploading, 0)
 
 	// Generate output filename
-	outputFilename := "merged_" + time.Now().Format("20060102_150405") + ".pdf"
+	outputFilename := h.outputName(c, userID, "merge", inputFileNames[0], "", "merged_"+time.Now().Format("20060102_150405")+".pdf")
 
-	// Upload merged file to MinIO
-	uploadResult, err := h.storageService.UploadProcessedFile(
-		c.Request.Context(),
-		userID,
-		outputFilename,
-		result.Data,
-		"application/pdf",
-	)
+	// Stream the merged file to MinIO
+	uploadResult, err := h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, nil)
 	if err != nil {
-		h.logOperation(userID, "merge", inputFileNames, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "merge", inputFileNames, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save merged PDF: "+err.Error())
 		return
 	}
 
-	// Log successful operation
-	h.logOperation(userID, "merge", inputFileNames, uploadResult.FileID, "success", "", result.PageCount, startTime)
+	pageCount := uploadResult.Metadata.PageCount
+	res := &models.MergeResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		InputFiles:       len(inPaths),
+	}
+	h.recordResult(c, userID, "merge", inputFileNames, res, pageCount, startTime)
 
-	// Return response
-	utils.Success(c, gin.H{
-		"fileId":       uploadResult.FileID,
-		"url":          uploadResult.URL,
-		"filename":     uploadResult.Filename,
-		"pageCount":    result.PageCount,
-		"size":         uploadResult.Size,
-		"inputFiles":   len(files),
-		"processingMs": time.Since(startTime).Milliseconds(),
-	})
+	utils.Success(c, res)
 }
 
 // SplitPDF handles POST /api/pdf/split
@@ -173,18 +253,20 @@ func (h *CorePDFHandler) SplitPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded or stored file
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "split", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "split", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
+	tracker := h.startProgress(c, userID, "split")
+	defer finishProgress(c, tracker)
+
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
@@ -192,7 +274,7 @@ func (h *CorePDFHandler) SplitPDF(c *gin.Context) {
 	// Validate file size based on plan
 	maxSize := h.getMaxFileSize(c, userID)
 	if header.Size > maxSize {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "File too large", 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "File too large", 0, startTime)
 		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
 		return
 	}
@@ -200,52 +282,54 @@ func (h *CorePDFHandler) SplitPDF(c *gin.Context) {
 	// Get page ranges
 	pageRanges := c.PostForm("pages")
 	if pageRanges == "" {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "No page ranges", 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "No page ranges", 0, startTime)
 		utils.BadRequest(c, "Page ranges required (e.g., '1-3, 4-7, 8-10')")
 		return
 	}
 
 	// Validate page range format
 	if !isValidPageRanges(pageRanges) {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "Invalid page ranges format", 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Invalid page ranges format", 0, startTime)
 		utils.BadRequest(c, "Invalid page ranges format. Use format like '1-3, 4-7' or '1, 2, 3-5'")
 		return
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+	// Large uploads are processed file-to-file to avoid buffering them in memory
+	if isLargeFile(header.Size) {
+		h.splitLarge(c, file, header, userID, pageRanges, tracker, startTime)
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get page count for validation
-	pageCount, err := h.pdfService.GetPageCount(data)
+	pageCount, err := h.pdfService.GetPageCount(in)
 	if err != nil {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "Failed to read PDF", 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Failed to read PDF", 0, startTime)
 		utils.InternalServerError(c, "Failed to read PDF")
 		return
 	}
 
 	// Validate page ranges against actual page count
 	if err := validatePageRangesAgainstCount(pageRanges, pageCount); err != nil {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Split PDF using pdfcpu
-	result, err := h.pdfService.Split(c.Request.Context(), data, pageRanges)
+	pagesPerRange := rangePages(pageRanges)
+	tracker.SetTotalPages(c.Request.Context(), sumPages(pagesPerRange, len(pagesPerRange)))
+	result, err := h.pdfService.Split(c.Request.Context(), in, pageRanges, rangeProgress(c.Request.Context(), tracker, pagesPerRange, nil))
 	if err != nil {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to split PDF: "+err.Error())
 		return
 	}
@@ -254,8 +338,8 @@ func (h *CorePDFHandler) SplitPDF(c *gin.Context) {
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
 	ranges := parseRangesForNaming(pageRanges)
 
-	var outputFiles []gin.H
-	var outputFileIDs []string
+	var outputFiles []models.OperationOutput
+	var failedParts []models.FailedPart
 
 	for i, splitData := range result.Files {
 		// Generate filename
@@ -263,139 +347,158 @@ func (h *CorePDFHandler) SplitPDF(c *gin.Context) {
 		if i < len(ranges) {
 			rangeName = ranges[i]
 		}
-		outputFilename := fmt.Sprintf("%s_%s.pdf", baseName, rangeName)
+		outputFilename := h.outputName(c, userID, "split", header.Filename, rangeName, fmt.Sprintf("%s_%s.pdf", baseName, rangeName))
 
 		// Get page count of split file
-		splitPageCount, _ := h.pdfService.GetPageCount(splitData)
-
-		// Upload to MinIO
-		uploadResult, err := h.storageService.UploadProcessedFile(
-			c.Request.Context(),
-			userID,
-			outputFilename,
-			splitData,
-			"application/pdf",
-		)
-		if err != nil {
-			continue // Skip failed uploads, return partial results
+		splitPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(splitData))
+
+		// Upload to MinIO, reporting parts that still fail after retrying
+		uploadResult, failed := uploadPart(c.Request.Context(), rangeName, outputFilename, func() (*services.UploadResult, error) {
+			return h.storageService.UploadProcessedFile(
+				c.Request.Context(),
+				userID,
+				outputFilename,
+				splitData,
+				"application/pdf",
+			)
+		})
+		tracker.Pages(c.Request.Context(), models.ProgressStageUploading, sumPages(pagesPerRange, i+1))
+		if failed != nil {
+			failedParts = append(failedParts, *failed)
+			continue
 		}
 
-		outputFiles = append(outputFiles, gin.H{
-			"fileId":    uploadResult.FileID,
-			"url":       uploadResult.URL,
-			"filename":  uploadResult.Filename,
-			"pageCount": splitPageCount,
-			"size":      uploadResult.Size,
-			"range":     ranges[i],
-		})
-		outputFileIDs = append(outputFileIDs, uploadResult.FileID)
+		output := outputFromUpload(uploadResult, splitPageCount)
+		output.Range = rangeName
+		outputFiles = append(outputFiles, output)
 	}
 
 	if len(outputFiles) == 0 {
-		h.logOperation(userID, "split", []string{header.Filename}, "", "error", "No files created", 0, startTime)
-		utils.InternalServerError(c, "Failed to create any split files")
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "No files created", 0, startTime)
+		utils.InternalServerError(c, "Failed to create any split files: "+failedParts[0].Error)
 		return
 	}
 
-	// Log successful operation
-	h.logOperationMultiple(userID, "split", []string{header.Filename}, outputFileIDs, "success", "", pageCount, startTime)
+	res := &models.SplitResult{
+		Files:       outputFiles,
+		TotalFiles:  len(outputFiles),
+		FailedParts: failedParts,
+		InputFile:   header.Filename,
+		InputPages:  pageCount,
+	}
+	res.SetOutputs(outputFiles...)
+	h.recordResult(c, userID, "split", []string{header.Filename}, res, pageCount, startTime)
 
-	// Return response
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"files":        outputFiles,
-			"totalFiles":   len(outputFiles),
-			"inputFile":    header.Filename,
-			"inputPages":   pageCount,
-			"processingMs": time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
 }
 
 // RotatePDF handles POST /api/pdf/rotate
-// Accepts file + angle (90|180|270), rotates all pages, stores in MinIO
+// Accepts file + angle (90|180|270), rotates all pages, stores in MinIO.
+// rotations ({"1":90,"3":270}) rotates pages by different angles instead.
 func (h *CorePDFHandler) RotatePDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "rotate", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "rotate", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
 
-	// Validate file size (max 100MB)
-	if header.Size > 100*1024*1024 {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "File too large", 0, startTime)
-		utils.BadRequest(c, "File exceeds 100MB limit")
+	// Validate file size based on plan
+	maxSize := h.getMaxFileSize(c, userID)
+	if header.Size > maxSize {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "File too large", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
 		return
 	}
 
-	// Get angle parameter
+	// Get angle parameter, or per-page angles
 	angleStr := c.PostForm("angle")
-	if angleStr == "" {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "No angle provided", 0, startTime)
+	var rotations map[int]int
+	if raw := c.PostForm("rotations"); raw != "" {
+		if angleStr != "" {
+			h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Both angle and rotations provided", 0, startTime)
+			utils.BadRequest(c, "Send either angle or rotations, not both")
+			return
+		}
+		if rotations, err = parseRotations(raw); err != nil {
+			h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid rotations", 0, startTime)
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if angleStr == "" && rotations == nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "No angle provided", 0, startTime)
 		utils.BadRequest(c, "Rotation angle required (90, 180, or 270)")
 		return
 	}
 
 	// Validate angle
 	var angle int
-	if _, err := fmt.Sscanf(angleStr, "%d", &angle); err != nil {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Invalid angle format", 0, startTime)
-		utils.BadRequest(c, "Invalid angle format")
-		return
-	}
+	if rotations == nil {
+		if _, err := fmt.Sscanf(angleStr, "%d", &angle); err != nil {
+			h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid angle format", 0, startTime)
+			utils.BadRequest(c, "Invalid angle format")
+			return
+		}
 
-	if angle != 90 && angle != 180 && angle != 270 {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Invalid angle value", 0, startTime)
-		utils.BadRequest(c, "Angle must be 90, 180, or 270 degrees")
-		return
+		if angle != 90 && angle != 180 && angle != 270 {
+			h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid angle value", 0, startTime)
+			utils.BadRequest(c, "Angle must be 90, 180, or 270 degrees")
+			return
+		}
 	}
 
 	// Optional: specific pages to rotate (default: all pages)
 	pages := c.DefaultPostForm("pages", "1-")
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+	// Large uploads are processed file-to-file to avoid buffering them in memory
+	if isLargeFile(header.Size) {
+		h.rotateLarge(c, file, header, userID, pages, angle, rotations, startTime)
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get original page count
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Rotate PDF using pdfcpu
-	result, err := h.pdfService.Rotate(c.Request.Context(), data, pages, angle)
+	var result *services.RotateResult
+	if rotations != nil {
+		result, err = h.pdfService.RotatePages(c.Request.Context(), in, rotations)
+	} else {
+		result, err = h.pdfService.Rotate(c.Request.Context(), in, pages, angle)
+	}
 	if err != nil {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidRotation) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalServerError(c, "Failed to rotate PDF: "+err.Error())
 		return
 	}
 
 	// Generate output filename
-	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_rotated_%d.pdf", baseName, angle)
+	outputFilename := h.outputName(c, userID, "rotate", header.Filename, "", rotatedName(header.Filename, angle))
 
 	// Upload rotated file to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -406,95 +509,158 @@ func (h *CorePDFHandler) RotatePDF(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "rotate", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save rotated PDF: "+err.Error())
 		return
 	}
 
-	// Log successful operation
-	h.logOperation(userID, "rotate", []string{header.Filename}, uploadResult.FileID, "success", "", pageCount, startTime)
+	res := &models.RotateResult{
+		SingleFileResult: singleFileResult(uploadResult, result.PageCount),
+		Angle:            angle,
+		Rotations:        rotationsByPage(rotations),
+	}
+	h.recordResult(c, userID, "rotate", []string{header.Filename}, res, pageCount, startTime)
 
-	// Return response
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":       uploadResult.FileID,
-			"url":          uploadResult.URL,
-			"filename":     uploadResult.Filename,
-			"pageCount":    result.PageCount,
-			"angle":        angle,
-			"size":         uploadResult.Size,
-			"processingMs": time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
+}
+
+// parseRotations reads a JSON object of page numbers to angles, such as
+// {"1":90,"3":270}. Angles and pages are checked against the document by
+// RotatePages.
+func parseRotations(raw string) (map[int]int, error) {
+	var byPage map[string]int
+	if err := json.Unmarshal([]byte(raw), &byPage); err != nil {
+		return nil, errors.New(`rotations must map page numbers to angles, e.g. {"1":90,"3":270}`)
+	}
+	rotations := make(map[int]int, len(byPage))
+	for key, angle := range byPage {
+		page, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("rotations: %q is not a page number", key)
+		}
+		rotations[page] = angle
+	}
+	return rotations, nil
+}
+
+// rotationsByPage keys per-page angles by page number as sent
+func rotationsByPage(rotations map[int]int) map[string]int {
+	if rotations == nil {
+		return nil
+	}
+	byPage := make(map[string]int, len(rotations))
+	for page, angle := range rotations {
+		byPage[strconv.Itoa(page)] = angle
+	}
+	return byPage
+}
+
+// rotatedName is the default output name of a rotation; angle is 0 for
+// per-page rotations
+func rotatedName(filename string, angle int) string {
+	baseName := strings.TrimSuffix(filename, ".pdf")
+	if angle == 0 {
+		return baseName + "_rotated.pdf"
+	}
+	return fmt.Sprintf("%s_rotated_%d.pdf", baseName, angle)
 }
 
 // CompressPDF handles POST /api/pdf/compress
-// Accepts file + quality level, compresses using pdfcpu optimize, stores in MinIO
+// Accepts file (or fileId) + quality level, compresses using pdfcpu optimize, stores in MinIO
 func (h *CorePDFHandler) CompressPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "compress", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "compress", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
 
-	// Validate file size (max 100MB)
-	if header.Size > 100*1024*1024 {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", "File too large", 0, startTime)
-		utils.BadRequest(c, "File exceeds 100MB limit")
+	// Validate file size based on plan
+	maxSize := h.getMaxFileSize(c, userID)
+	if header.Size > maxSize {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "File too large", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
 		return
 	}
 
+	// Fill in the user's default quality and mode
+	h.applyPreferences(c, userID, "compress")
+
 	// Get quality parameter (low, medium, high)
 	quality := c.DefaultPostForm("quality", "medium")
 	if quality != "low" && quality != "medium" && quality != "high" {
 		quality = "medium"
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+	// Photo mode recompresses JPEGs; dryRun=true estimates without storing
+	mode := strings.ToLower(strings.TrimSpace(c.DefaultPostForm("mode", services.CompressModeStandard)))
+	if mode != services.CompressModeStandard && mode != services.CompressModePhoto {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Invalid mode", 0, startTime)
+		utils.BadRequest(c, `mode must be "standard" or "photo"`)
+		return
+	}
+	dryRun := c.PostForm("dryRun") == "true"
+
+	if mode == services.CompressModePhoto {
+		opts, err := services.NormalizePhotoCompressOptions(quality, c.PostForm("dpi"))
+		if err != nil {
+			h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		h.compressPhotos(c, file, header, userID, quality, opts, dryRun, startTime)
+		return
+	}
+
+	// Large uploads are processed file-to-file to avoid buffering them in memory
+	if isLargeFile(header.Size) {
+		h.compressLarge(c, file, header, userID, quality, dryRun, startTime)
 		return
 	}
 
-	originalSize := int64(len(data))
+	in := inputReader(file, header)
+
+	originalSize := in.Size()
 
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get page count
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Compress PDF using pdfcpu OptimizeFile
-	result, err := h.pdfService.Compress(c.Request.Context(), data, quality)
+	result, err := h.pdfService.Compress(c.Request.Context(), in, quality)
 	if err != nil {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to compress PDF: "+err.Error())
 		return
 	}
+	profile, _ := h.pdfService.ImageProfile(in, originalSize)
+
+	if dryRun {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "success", "", pageCount, startTime)
+		utils.Success(c, compressEstimate(header.Filename, mode, quality, originalSize, result.SizeAfter, profile, nil))
+		return
+	}
 
 	// Generate output filename
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_compressed.pdf", baseName)
+	outputFilename := h.outputName(c, userID, "compress", header.Filename, "", fmt.Sprintf("%s_compressed.pdf", baseName))
 
 	// Upload compressed file to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -505,56 +671,48 @@ func (h *CorePDFHandler) CompressPDF(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "compress", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save compressed PDF: "+err.Error())
 		return
 	}
 
-	// Log successful operation
-	h.logOperation(userID, "compress", []string{header.Filename}, uploadResult.FileID, "success", "", pageCount, startTime)
-
 	// Calculate compression stats
-	compressedSize := result.SizeAfter
 	reduction := result.Compression
 	if reduction < 0 {
 		reduction = 0
 	}
 
-	// Return response
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":         uploadResult.FileID,
-			"url":            uploadResult.URL,
-			"filename":       uploadResult.Filename,
-			"pageCount":      pageCount,
-			"originalSize":   originalSize,
-			"compressedSize": compressedSize,
-			"reduction":      fmt.Sprintf("%.1f%%", reduction),
-			"quality":        quality,
-			"processingMs":   time.Since(startTime).Milliseconds(),
-		},
-	})
+	res := &models.CompressResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		OriginalSize:     originalSize,
+		CompressedSize:   result.SizeAfter,
+		Reduction:        fmt.Sprintf("%.1f%%", reduction),
+		Quality:          quality,
+		Mode:             mode,
+		ImageProfile:     profile,
+	}
+	h.recordResult(c, userID, "compress", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
 }
 
 // CropPDF handles POST /api/pdf/crop
-// Accepts file + crop margins (top, right, bottom, left), crops all pages
+// Accepts file (or fileId) + crop margins (top, right, bottom, left), crops all pages
 func (h *CorePDFHandler) CropPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "crop", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "crop", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "crop", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
@@ -568,44 +726,38 @@ func (h *CorePDFHandler) CropPDF(c *gin.Context) {
 
 	// Validate crop values
 	if top < 0 || right < 0 || bottom < 0 || left < 0 {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", "Invalid crop values", 0, startTime)
+		h.logOperation(c, userID, "crop", []string{header.Filename}, "", "error", "Invalid crop values", 0, startTime)
 		utils.BadRequest(c, "Crop values must be non-negative")
 		return
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "crop", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Crop PDF using pdfcpu
-	result, err := h.pdfService.Crop(c.Request.Context(), data, services.CropOptions{
+	result, err := h.pdfService.Crop(c.Request.Context(), in, services.CropOptions{
 		Top:    top,
 		Right:  right,
 		Bottom: bottom,
 		Left:   left,
 	})
 	if err != nil {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "crop", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to crop PDF: "+err.Error())
 		return
 	}
 
 	// Generate output filename
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_cropped.pdf", baseName)
+	outputFilename := h.outputName(c, userID, "crop", header.Filename, "", fmt.Sprintf("%s_cropped.pdf", baseName))
 
 	// Upload cropped file to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -616,102 +768,80 @@ func (h *CorePDFHandler) CropPDF(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "crop", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "crop", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save cropped PDF: "+err.Error())
 		return
 	}
 
-	h.logOperation(userID, "crop", []string{header.Filename}, uploadResult.FileID, "success", "", pageCount, startTime)
+	res := &models.CropResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Margins:          models.CropMargins{Top: top, Right: right, Bottom: bottom, Left: left},
+	}
+	h.recordResult(c, userID, "crop", []string{header.Filename}, res, pageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":       uploadResult.FileID,
-			"url":          uploadResult.URL,
-			"filename":     uploadResult.Filename,
-			"pageCount":    pageCount,
-			"margins":      gin.H{"top": top, "right": right, "bottom": bottom, "left": left},
-			"size":         uploadResult.Size,
-			"processingMs": time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
 }
 
 // WatermarkPDF handles POST /api/pdf/watermark
-// Accepts file + text + opacity + position, adds text watermark to all pages
+// Accepts file (or fileId) + text + opacity + position, adds text watermark to all
+// pages. With type=image it stamps the PNG or JPEG uploaded as image
+// instead, sized by scale.
 func (h *CorePDFHandler) WatermarkPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "watermark", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "watermark", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
 
-	// Get watermark parameters
-	text := c.PostForm("text")
-	if text == "" {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", "No text provided", 0, startTime)
+	// Get watermark parameters, defaulting to the user's branding
+	h.applyPreferences(c, userID, "watermark")
+	if c.PostForm("text") == "" && c.PostForm("type") != services.WatermarkTypeImage {
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", "No text provided", 0, startTime)
 		utils.BadRequest(c, "Watermark text is required")
 		return
 	}
 
-	position := c.DefaultPostForm("position", "center")
-	var opacity float64 = 0.3
-	fmt.Sscanf(c.DefaultPostForm("opacity", "0.3"), "%f", &opacity)
-
-	// Validate opacity
-	if opacity < 0.1 || opacity > 1.0 {
-		opacity = 0.3
-	}
-
-	var fontSize int = 48
-	fmt.Sscanf(c.DefaultPostForm("fontSize", "48"), "%d", &fontSize)
-
-	// Read file data
-	data, err := io.ReadAll(file)
+	opts, err := watermarkOptionsFromForm(c)
 	if err != nil {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Add watermark using pdfcpu
-	result, err := h.pdfService.AddWatermark(c.Request.Context(), data, services.WatermarkOptions{
-		Text:     text,
-		Position: position,
-		Opacity:  opacity,
-		FontSize: float64(fontSize),
-	})
+	result, err := h.pdfService.AddWatermark(c.Request.Context(), in, opts)
 	if err != nil {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to add watermark: "+err.Error())
 		return
 	}
 
 	// Generate output filename
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_watermarked.pdf", baseName)
+	outputFilename := h.outputName(c, userID, "watermark", header.Filename, "", fmt.Sprintf("%s_watermarked.pdf", baseName))
 
 	// Upload watermarked file to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -722,96 +852,149 @@ func (h *CorePDFHandler) WatermarkPDF(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "watermark", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "watermark", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save watermarked PDF: "+err.Error())
 		return
 	}
 
-	h.logOperation(userID, "watermark", []string{header.Filename}, uploadResult.FileID, "success", "", pageCount, startTime)
+	res := &models.WatermarkResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Watermark:        watermarkSettings(opts),
+	}
+	h.recordResult(c, userID, "watermark", []string{header.Filename}, res, pageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":       uploadResult.FileID,
-			"url":          uploadResult.URL,
-			"filename":     uploadResult.Filename,
-			"pageCount":    pageCount,
-			"watermark":    gin.H{"text": text, "position": position, "opacity": opacity},
-			"size":         uploadResult.Size,
-			"processingMs": time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
+}
+
+// watermarkOptionsFromForm reads and validates the watermark fields shared
+// by both watermark endpoints. Out-of-range opacity falls back to 0.3.
+func watermarkOptionsFromForm(c *gin.Context) (services.WatermarkOptions, error) {
+	opts := services.WatermarkOptions{
+		Type:     c.PostForm("type"),
+		Text:     c.PostForm("text"),
+		Position: c.DefaultPostForm("position", "center"),
+		Color:    c.PostForm("color"),
+		Font:     c.PostForm("font"),
+		Mode:     c.PostForm("mode"),
+	}
+
+	fmt.Sscanf(c.DefaultPostForm("opacity", "0.3"), "%f", &opts.Opacity)
+	if opts.Opacity < 0.1 || opts.Opacity > 1.0 {
+		opts.Opacity = 0.3
+	}
+
+	fontSize := 48
+	fmt.Sscanf(c.DefaultPostForm("fontSize", "48"), "%d", &fontSize)
+	opts.FontSize = float64(fontSize)
+
+	if v := c.PostForm("rotation"); v != "" {
+		rotation, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("%w: rotation must be a number", services.ErrInvalidWatermark)
+		}
+		opts.Rotation = rotation
+	}
+	if v := c.PostForm("tile"); v != "" {
+		tile, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: tile must be true or false", services.ErrInvalidWatermark)
+		}
+		opts.Tile = tile
+	}
+
+	if opts.Type == services.WatermarkTypeImage {
+		if v := c.PostForm("scale"); v != "" {
+			scale, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%w: scale must be a number", services.ErrInvalidWatermark)
+			}
+			opts.Scale = scale
+		}
+		if file, _, err := c.Request.FormFile("image"); err == nil {
+			defer file.Close()
+			data, err := io.ReadAll(io.LimitReader(file, services.MaxWatermarkImageSize+1))
+			if err != nil {
+				return opts, fmt.Errorf("%w: failed to read image", services.ErrInvalidWatermark)
+			}
+			opts.Image = data
+		}
+	}
+
+	return services.NormalizeWatermarkOptions(opts)
+}
+
+func watermarkSettings(opts services.WatermarkOptions) models.WatermarkSettings {
+	return models.WatermarkSettings{
+		Type:     opts.Type,
+		Text:     opts.Text,
+		Position: opts.Position,
+		Opacity:  opts.Opacity,
+		FontSize: int(opts.FontSize),
+		Color:    opts.Color,
+		Font:     opts.Font,
+		Mode:     opts.Mode,
+		Rotation: opts.Rotation,
+		Tile:     opts.Tile,
+		Scale:    opts.Scale,
+	}
 }
 
 // PageNumbersPDF handles POST /api/pdf/page-numbers
-// Accepts file + position + format + startFrom, adds page numbers to all pages
+// Accepts file (or fileId) + position + format + startFrom, adds page numbers to all pages
 func (h *CorePDFHandler) PageNumbersPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "page-numbers", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "page-numbers", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "page-numbers", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "page-numbers", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
 
-	// Get page number parameters
-	position := c.DefaultPostForm("position", "bottom-center")
-	format := c.DefaultPostForm("format", "{n}")
-	var startFrom int = 1
-	fmt.Sscanf(c.DefaultPostForm("startFrom", "1"), "%d", &startFrom)
-
-	// Validate position
-	validPositions := map[string]bool{
-		"bottom-center": true, "bottom-right": true, "bottom-left": true,
-		"top-center": true, "top-right": true, "top-left": true,
-	}
-	if !validPositions[position] {
-		position = "bottom-center"
-	}
-
-	// Read file data
-	data, err := io.ReadAll(file)
+	// Get page number parameters, defaulting to the user's style
+	h.applyPreferences(c, userID, "page-numbers")
+	opts, err := pageNumberOptionsFromForm(c)
 	if err != nil {
-		h.logOperation(userID, "page-numbers", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+		h.logOperation(c, userID, "page-numbers", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "page-numbers", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "page-numbers", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Add page numbers using pdfcpu
-	result, err := h.pdfService.AddPageNumbers(c.Request.Context(), data, services.PageNumberOptions{
-		Position:  position,
-		Format:    format,
-		StartFrom: startFrom,
-	})
+	result, err := h.pdfService.AddPageNumbers(c.Request.Context(), in, opts)
 	if err != nil {
-		h.logOperation(userID, "page-numbers", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "page-numbers", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidPageNumbers) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalServerError(c, "Failed to add page numbers: "+err.Error())
 		return
 	}
 
 	// Generate output filename
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_numbered.pdf", baseName)
+	outputFilename := h.outputName(c, userID, "page-numbers", header.Filename, "", fmt.Sprintf("%s_numbered.pdf", baseName))
 
 	// Upload numbered file to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -822,34 +1005,76 @@ func (h *CorePDFHandler) PageNumbersPDF(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "page-numbers", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "page-numbers", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save numbered PDF: "+err.Error())
 		return
 	}
 
-	h.logOperation(userID, "page-numbers", []string{header.Filename}, uploadResult.FileID, "success", "", pageCount, startTime)
+	res := &models.PageNumbersResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Settings:         pageNumberSettings(opts),
+	}
+	h.recordResult(c, userID, "page-numbers", []string{header.Filename}, res, pageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":       uploadResult.FileID,
-			"url":          uploadResult.URL,
-			"filename":     uploadResult.Filename,
-			"pageCount":    pageCount,
-			"settings":     gin.H{"position": position, "format": format, "startFrom": startFrom},
-			"size":         uploadResult.Size,
-			"processingMs": time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
+}
+
+// pageNumberOptionsFromForm reads and validates the page number fields
+// shared by both page number endpoints
+func pageNumberOptionsFromForm(c *gin.Context) (services.PageNumberOptions, error) {
+	opts := services.PageNumberOptions{
+		Position: c.DefaultPostForm("position", "bottom-center"),
+		Format:   c.DefaultPostForm("format", "{n}"),
+		Style:    c.PostForm("style"),
+		Skip:     strings.TrimSpace(c.PostForm("skip")),
+	}
+
+	ints := []struct {
+		field string
+		dst   *int
+	}{
+		{"startFrom", &opts.StartFrom},
+		{"frontMatter", &opts.FrontMatter},
+	}
+	for _, f := range ints {
+		if v := c.PostForm(f.field); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s must be an integer", services.ErrInvalidPageNumbers, f.field)
+			}
+			*f.dst = n
+		}
+	}
+	if v := c.PostForm("mirror"); v != "" {
+		mirror, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: mirror must be true or false", services.ErrInvalidPageNumbers)
+		}
+		opts.Mirror = mirror
+	}
+
+	return services.NormalizePageNumberOptions(opts)
+}
+
+func pageNumberSettings(opts services.PageNumberOptions) models.PageNumberSettings {
+	return models.PageNumberSettings{
+		Position:    opts.Position,
+		Format:      opts.Format,
+		StartFrom:   opts.StartFrom,
+		Style:       opts.Style,
+		FrontMatter: opts.FrontMatter,
+		Skip:        opts.Skip,
+		Mirror:      opts.Mirror,
+	}
 }
 
 // logOperation logs a PDF operation to MongoDB
-func (h *CorePDFHandler) logOperation(userID, operation string, inputFiles []string, outputFileID, status, errorMsg string, pageCount int, startTime time.Time) {
+func (h *CorePDFHandler) logOperation(c *gin.Context, userID, operation string, inputFiles []string, outputFileID, status, errorMsg string, pageCount int, startTime time.Time) {
 	if h.mongoClient == nil {
 		return
 	}
 
-	log := OperationLog{
+	log := models.OperationLog{
 		UserID:       userID,
 		Operation:    operation,
 		InputFiles:   inputFiles,
@@ -860,29 +1085,172 @@ func (h *CorePDFHandler) logOperation(userID, operation string, inputFiles []str
 		ProcessingMs: time.Since(startTime).Milliseconds(),
 		CreatedAt:    time.Now(),
 	}
+	h.saveOperationLog(c, log)
+}
+
+// operationLogFailures counts operation logs that couldn't be stored
+var operationLogFailures atomic.Int64
+
+// OperationLogFailures returns how many operation logs failed to store
+// since startup
+func OperationLogFailures() int64 {
+	return operationLogFailures.Load()
+}
+
+// saveOperationLog stores an operation log, tagged with the request it came
+// from, and forwards it to the SIEM. It outlives the request, which may
+// already be cancelled, but not by long.
+func (h *CorePDFHandler) saveOperationLog(c *gin.Context, entry models.OperationLog) {
+	entry.RequestID = middleware.GetRequestID(c)
+	entry.SessionID = middleware.GetSessionID(c)
+	entry.IP = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	if c.Request.ContentLength > 0 {
+		entry.InputBytes = c.Request.ContentLength
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), 5*time.Second)
+	defer cancel()
+	if _, err := h.mongoClient.Collection("operation_logs").InsertOne(ctx, entry); err != nil {
+		operationLogFailures.Add(1)
+		log.Printf("[Operations] Failed to store %s log for request %s: %v", entry.Operation, entry.RequestID, err)
+	}
+	h.logForwarder.ForwardOperation(entry)
+}
+
+// operationResult is implemented by every typed result in models
+type operationResult interface {
+	Base() *models.OperationResult
+}
+
+const (
+	// splitUploadAttempts bounds how often each split part's upload is tried
+	splitUploadAttempts = 3
+	// splitUploadBackoff is the wait before the first retry, doubled after
+	splitUploadBackoff = 250 * time.Millisecond
+)
+
+// uploadPart stores one split part, retrying failed uploads with backoff.
+// A part that still fails is returned as a FailedPart for the response.
+func uploadPart(ctx context.Context, rangeName, filename string, upload func() (*services.UploadResult, error)) (*services.UploadResult, *models.FailedPart) {
+	for attempt := 1; ; attempt++ {
+		result, err := upload()
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("[Split] Upload of %s failed (attempt %d/%d): %v", filename, attempt, splitUploadAttempts, err)
+		if attempt == splitUploadAttempts || ctx.Err() != nil {
+			return nil, &models.FailedPart{Range: rangeName, Filename: filename, Attempts: attempt, Error: err.Error()}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(splitUploadBackoff << (attempt - 1)):
+		}
+	}
+}
+
+// outputFromUpload describes a stored upload as an operation output
+func outputFromUpload(upload *services.UploadResult, pageCount int) models.OperationOutput {
+	return models.OperationOutput{
+		FileID:    upload.FileID,
+		URL:       upload.URL,
+		Filename:  upload.Filename,
+		PageCount: pageCount,
+		Size:      upload.Size,
+		Retention: upload.Retention,
+	}
+}
+
+// singleFileResult builds the result of an operation producing one file
+func singleFileResult(upload *services.UploadResult, pageCount int) models.SingleFileResult {
+	output := outputFromUpload(upload, pageCount)
+	res := models.SingleFileResult{OperationOutput: output}
+	res.SetOutputs(output)
+	return res
+}
+
+// recordResult fills in the common result fields and stores the successful
+// operation, with its outputs and typed result, in operation_logs
+func (h *CorePDFHandler) recordResult(c *gin.Context, userID, operation string, inputFiles []string, result operationResult, pageCount int, startTime time.Time) {
+	base := result.Base()
+	base.Operation = operation
+	base.ProcessingMs = time.Since(startTime).Milliseconds()
+
+	id := primitive.NewObjectID()
+	base.OperationID = id.Hex()
+
+	if h.mongoClient == nil {
+		return
+	}
+
+	log := models.OperationLog{
+		ID:           id,
+		UserID:       userID,
+		Operation:    operation,
+		InputFiles:   inputFiles,
+		Outputs:      base.Outputs,
+		Result:       result,
+		PageCount:    pageCount,
+		Status:       models.OperationStatusSuccess,
+		ProcessingMs: base.ProcessingMs,
+		CreatedAt:    time.Now(),
+	}
+	if len(base.Outputs) == 1 {
+		log.OutputFileID = base.Outputs[0].FileID
+	} else {
+		for _, o := range base.Outputs {
+			log.OutputFiles = append(log.OutputFiles, o.FileID)
+		}
+	}
 
-	h.mongoClient.Collection("operation_logs").InsertOne(nil, log)
+	h.saveOperationLog(c, log)
 }
 
-// logOperationMultiple logs operation with multiple outputs
-func (h *CorePDFHandler) logOperationMultiple(userID, operation string, inputFiles, outputFileIDs []string, status, errorMsg string, pageCount int, startTime time.Time) {
+// History handles GET /api/pdf/history
+// Returns the caller's recent operations with their outputs and typed results
+func (h *CorePDFHandler) History(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	if userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
 	if h.mongoClient == nil {
+		utils.ServiceUnavailable(c, "History is not available")
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	filter := bson.M{"userId": userID}
+	if op := c.Query("operation"); op != "" {
+		filter["operation"] = op
+	}
+
+	// Decode results as plain maps so they serialize as JSON objects
+	coll := h.mongoClient.Database().Collection("operation_logs",
+		options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := coll.Find(c.Request.Context(), filter, opts)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load history")
 		return
 	}
+	defer cursor.Close(c.Request.Context())
 
-	log := bson.M{
-		"userId":       userID,
-		"operation":    operation,
-		"inputFiles":   inputFiles,
-		"outputFiles":  outputFileIDs,
-		"pageCount":    pageCount,
-		"status":       status,
-		"errorMessage": errorMsg,
-		"processingMs": time.Since(startTime).Milliseconds(),
-		"createdAt":    time.Now(),
+	operations := []models.OperationLog{}
+	if err := cursor.All(c.Request.Context(), &operations); err != nil {
+		utils.InternalServerError(c, "Failed to load history")
+		return
 	}
 
-	h.mongoClient.Collection("operation_logs").InsertOne(nil, log)
+	utils.Success(c, gin.H{
+		"operations": operations,
+		"count":      len(operations),
+	})
 }
 
 // ReorderPages handles POST /api/pdf/reorder
@@ -891,18 +1259,17 @@ func (h *CorePDFHandler) ReorderPages(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "reorder", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "reorder", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
@@ -910,7 +1277,7 @@ func (h *CorePDFHandler) ReorderPages(c *gin.Context) {
 	// Get page order (comma-separated like "3,1,2,4")
 	orderStr := c.PostForm("order")
 	if orderStr == "" {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "No order provided", 0, startTime)
+		h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "No order provided", 0, startTime)
 		utils.BadRequest(c, "Page order is required (e.g., '3,1,2,4')")
 		return
 	}
@@ -922,54 +1289,48 @@ func (h *CorePDFHandler) ReorderPages(c *gin.Context) {
 		part = strings.TrimSpace(part)
 		var pageNum int
 		if _, err := fmt.Sscanf(part, "%d", &pageNum); err != nil {
-			h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Invalid page number", 0, startTime)
+			h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Invalid page number", 0, startTime)
 			utils.BadRequest(c, fmt.Sprintf("Invalid page number: %s", part))
 			return
 		}
 		if pageNum < 1 {
-			h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Page number must be positive", 0, startTime)
+			h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Page number must be positive", 0, startTime)
 			utils.BadRequest(c, "Page numbers must be positive")
 			return
 		}
 		newOrder = append(newOrder, pageNum)
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Validate page numbers against actual page count
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 	for _, p := range newOrder {
 		if p > pageCount {
-			h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Page out of range", 0, startTime)
+			h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Page out of range", 0, startTime)
 			utils.BadRequest(c, fmt.Sprintf("Page %d is out of range (document has %d pages)", p, pageCount))
 			return
 		}
 	}
 
 	// Reorder pages using pdfcpu OrganizePages
-	result, err := h.pdfService.OrganizePages(c.Request.Context(), data, newOrder)
+	result, err := h.pdfService.OrganizePages(c.Request.Context(), in, newOrder)
 	if err != nil {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to reorder pages: "+err.Error())
 		return
 	}
 
 	// Generate output filename
 	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_reordered.pdf", baseName)
+	outputFilename := h.outputName(c, userID, "reorder", header.Filename, "", fmt.Sprintf("%s_reordered.pdf", baseName))
 
 	// Upload to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
@@ -980,27 +1341,20 @@ func (h *CorePDFHandler) ReorderPages(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "reorder", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "reorder", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save reordered PDF: "+err.Error())
 		return
 	}
 
-	newPageCount, _ := h.pdfService.GetPageCount(result)
-	h.logOperation(userID, "reorder", []string{header.Filename}, uploadResult.FileID, "success", "", newPageCount, startTime)
+	newPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.ReorderResult{
+		SingleFileResult: singleFileResult(uploadResult, newPageCount),
+		OriginalPages:    pageCount,
+		NewOrder:         newOrder,
+	}
+	h.recordResult(c, userID, "reorder", []string{header.Filename}, res, newPageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":        uploadResult.FileID,
-			"url":           uploadResult.URL,
-			"filename":      uploadResult.Filename,
-			"pageCount":     newPageCount,
-			"originalPages": pageCount,
-			"newOrder":      newOrder,
-			"size":          uploadResult.Size,
-			"processingMs":  time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
 }
 
 // RemovePages handles POST /api/pdf/remove
@@ -1009,18 +1363,17 @@ func (h *CorePDFHandler) RemovePages(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "remove", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "remove", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
@@ -1028,48 +1381,56 @@ func (h *CorePDFHandler) RemovePages(c *gin.Context) {
 	// Get pages to remove
 	pagesStr := c.PostForm("pages")
 	if pagesStr == "" {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", "No pages specified", 0, startTime)
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", "No pages specified", 0, startTime)
 		utils.BadRequest(c, "Pages to remove are required (e.g., '2,5,7' or '2-5')")
 		return
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	outputFilename := h.outputName(c, userID, "remove", header.Filename, "", fmt.Sprintf("%s_pages_removed.pdf", baseName))
+
+	// Large inputs are processed file-to-file to avoid buffering them in memory
+	if isLargeFile(header.Size) {
+		h.pageSelectionLarge(c, file, header, userID, "remove", pagesStr, outputFilename, h.pdfService.RemovePagesFile,
+			func(upload *services.UploadResult, originalPages, pageCount int) operationResult {
+				return &models.RemovePagesResult{
+					SingleFileResult: singleFileResult(upload, pageCount),
+					OriginalPages:    originalPages,
+					PagesRemoved:     originalPages - pageCount,
+					RemovedPages:     pagesStr,
+					LargeFileMode:    true,
+				}
+			}, startTime)
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get original page count
-	originalPageCount, _ := h.pdfService.GetPageCount(data)
+	originalPageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Validate page ranges
 	if err := validatePageRangesAgainstCount(pagesStr, originalPageCount); err != nil {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Remove pages using pdfcpu
-	result, err := h.pdfService.RemovePages(c.Request.Context(), data, pagesStr)
+	result, err := h.pdfService.RemovePages(c.Request.Context(), in, pagesStr)
 	if err != nil {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to remove pages: "+err.Error())
 		return
 	}
 
-	// Generate output filename
-	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	outputFilename := fmt.Sprintf("%s_pages_removed.pdf", baseName)
-
 	// Upload to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
 		c.Request.Context(),
@@ -1079,64 +1440,54 @@ func (h *CorePDFHandler) RemovePages(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "remove", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "remove", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save PDF: "+err.Error())
 		return
 	}
 
-	newPageCount, _ := h.pdfService.GetPageCount(result)
+	newPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
 	pagesRemoved := originalPageCount - newPageCount
 
-	h.logOperation(userID, "remove", []string{header.Filename}, uploadResult.FileID, "success", "", newPageCount, startTime)
+	res := &models.RemovePagesResult{
+		SingleFileResult: singleFileResult(uploadResult, newPageCount),
+		OriginalPages:    originalPageCount,
+		PagesRemoved:     pagesRemoved,
+		RemovedPages:     pagesStr,
+	}
+	h.recordResult(c, userID, "remove", []string{header.Filename}, res, newPageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":        uploadResult.FileID,
-			"url":           uploadResult.URL,
-			"filename":      uploadResult.Filename,
-			"pageCount":     newPageCount,
-			"originalPages": originalPageCount,
-			"pagesRemoved":  pagesRemoved,
-			"removedPages":  pagesStr,
-			"size":          uploadResult.Size,
-			"processingMs":  time.Since(startTime).Milliseconds(),
-		},
-	})
+	utils.Success(c, res)
 }
 
 // GetPDFInfo handles POST /api/pdf/info
-// Returns page count and basic info about a PDF
+// Returns page count and basic info about a PDF, uploaded or stored
 func (h *CorePDFHandler) GetPDFInfo(c *gin.Context) {
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	userID, _ := middleware.GetUserID(c)
+
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "info", stored, err, time.Now())
 		return
 	}
 	defer file.Close()
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF
-	if err := h.pdfService.ValidatePDF(data); err != nil {
+	if err := h.pdfService.ValidatePDF(in); err != nil {
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get info
-	pageCount, _ := h.pdfService.GetPageCount(data)
-	info, _ := h.pdfService.GetInfo(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
+	info, _ := h.pdfService.GetInfo(in)
 
 	utils.Success(c, gin.H{
 		"filename":  header.Filename,
 		"pageCount": pageCount,
-		"size":      len(data),
+		"size":      in.Size(),
 		"version":   info["version"],
 	})
 }
@@ -1147,18 +1498,17 @@ func (h *CorePDFHandler) ExtractPages(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
+	// Get uploaded file, or the stored file named by fileId
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "extract", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "extract", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
 		utils.BadRequest(c, "File must be a PDF")
 		return
 	}
@@ -1166,51 +1516,58 @@ func (h *CorePDFHandler) ExtractPages(c *gin.Context) {
 	// Get pages to extract
 	pagesStr := c.PostForm("pages")
 	if pagesStr == "" {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", "No pages specified", 0, startTime)
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", "No pages specified", 0, startTime)
 		utils.BadRequest(c, "Pages to extract are required (e.g., '1,3,5-7')")
 		return
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
-		utils.BadRequest(c, "Failed to read file")
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	// Clean up pages string for filename
+	pagesForFilename := strings.ReplaceAll(pagesStr, ",", "_")
+	pagesForFilename = strings.ReplaceAll(pagesForFilename, "-", "to")
+	outputFilename := h.outputName(c, userID, "extract", header.Filename, "", fmt.Sprintf("%s_pages_%s.pdf", baseName, pagesForFilename))
+
+	// Large inputs are processed file-to-file to avoid buffering them in memory
+	if isLargeFile(header.Size) {
+		h.pageSelectionLarge(c, file, header, userID, "extract", pagesStr, outputFilename, h.pdfService.ExtractPagesFile,
+			func(upload *services.UploadResult, originalPages, pageCount int) operationResult {
+				return &models.ExtractResult{
+					SingleFileResult: singleFileResult(upload, pageCount),
+					OriginalPages:    originalPages,
+					ExtractedPages:   pagesStr,
+					LargeFileMode:    true,
+				}
+			}, startTime)
 		return
 	}
 
+	in := inputReader(file, header)
+
 	// Validate PDF structure
-	if err := h.pdfService.ValidatePDF(data); err != nil {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get original page count
-	originalPageCount, _ := h.pdfService.GetPageCount(data)
+	originalPageCount, _ := h.pdfService.GetPageCount(in)
 
 	// Validate page ranges
 	if err := validatePageRangesAgainstCount(pagesStr, originalPageCount); err != nil {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Extract pages using pdfcpu
-	result, err := h.pdfService.ExtractPages(c.Request.Context(), data, pagesStr)
+	result, err := h.pdfService.ExtractPages(c.Request.Context(), in, pagesStr)
 	if err != nil {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
 		utils.InternalServerError(c, "Failed to extract pages: "+err.Error())
 		return
 	}
 
-	// Generate output filename
-	baseName := strings.TrimSuffix(header.Filename, ".pdf")
-	// Clean up pages string for filename
-	pagesForFilename := strings.ReplaceAll(pagesStr, ",", "_")
-	pagesForFilename = strings.ReplaceAll(pagesForFilename, "-", "to")
-	outputFilename := fmt.Sprintf("%s_pages_%s.pdf", baseName, pagesForFilename)
-
 	// Upload to MinIO
 	uploadResult, err := h.storageService.UploadProcessedFile(
 		c.Request.Context(),
@@ -1220,88 +1577,549 @@ func (h *CorePDFHandler) ExtractPages(c *gin.Context) {
 		"application/pdf",
 	)
 	if err != nil {
-		h.logOperation(userID, "extract", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		h.logOperation(c, userID, "extract", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
 		utils.InternalServerError(c, "Failed to save extracted PDF: "+err.Error())
 		return
 	}
 
-	newPageCount, _ := h.pdfService.GetPageCount(result)
-	h.logOperation(userID, "extract", []string{header.Filename}, uploadResult.FileID, "success", "", newPageCount, startTime)
+	newPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.ExtractResult{
+		SingleFileResult: singleFileResult(uploadResult, newPageCount),
+		OriginalPages:    originalPageCount,
+		ExtractedPages:   pagesStr,
+	}
+	h.recordResult(c, userID, "extract", []string{header.Filename}, res, newPageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"success": true,
-		"data": gin.H{
-			"fileId":          uploadResult.FileID,
-			"url":             uploadResult.URL,
-			"filename":        uploadResult.Filename,
-			"pageCount":       newPageCount,
-			"originalPages":   originalPageCount,
-			"extractedPages":  pagesStr,
-			"size":            uploadResult.Size,
-			"processingMs":    time.Since(startTime).Milliseconds(),
-		},
+	utils.Success(c, res)
+}
+
+// PageLayout handles GET /api/pdf/:fileId/pages
+// Returns each page's displayed width, height and rotation in points so
+// clients can place text, badges and signatures without guessing
+func (h *CorePDFHandler) PageLayout(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	doc, data, err := h.storageService.GetFileForUser(c.Request.Context(), c.Param("fileId"), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrFileNotFound) || errors.Is(err, services.ErrFileAccessDenied) {
+			utils.NotFound(c, "File not found")
+			return
+		}
+		if errors.Is(err, services.ErrFileArchived) {
+			respondArchived(c)
+			return
+		}
+		utils.InternalServerError(c, "Failed to load file: "+err.Error())
+		return
+	}
+
+	pages, err := h.pdfService.PageGeometry(bytes.NewReader(data))
+	if err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	utils.Success(c, &models.PageLayoutResult{
+		FileID:    c.Param("fileId"),
+		Filename:  storedName(doc),
+		PageCount: len(pages),
+		Unit:      services.UnitPoints,
+		Pages:     pages,
 	})
 }
 
-// DrawTextPDF handles POST /api/pdf/draw-text
-// Adds custom text at specific coordinates
-func (h *CorePDFHandler) DrawTextPDF(c *gin.Context) {
+// SearchPDF handles POST /api/pdf/search
+// Accepts fileId (or file) + query, with optional caseSensitive and limit,
+// and returns each match's page and bounding rectangle for highlighting
+func (h *CorePDFHandler) SearchPDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	file, header, err := c.Request.FormFile("file")
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "draw-text", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "search", stored, err, startTime)
 		return
 	}
 	defer file.Close()
 
-	// Get parameters
-	text := c.PostForm("text")
-	if text == "" {
-		utils.BadRequest(c, "Text is required")
+	opts := services.SearchOptions{Query: c.PostForm("query")}
+	if v := c.PostForm("caseSensitive"); v != "" {
+		if opts.CaseSensitive, err = strconv.ParseBool(v); err != nil {
+			utils.BadRequest(c, "caseSensitive must be true or false")
+			return
+		}
+	}
+	if v := c.PostForm("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "limit must be an integer")
+			return
+		}
+	}
+
+	in := inputReader(file, header)
+
+	res, err := h.pdfService.SearchText(c.Request.Context(), in, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearch) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to search PDF: "+err.Error())
 		return
 	}
+	if stored {
+		res.FileID = strings.TrimSpace(c.PostForm("fileId"))
+	}
 
-	var x, y, fontSize float64
-	fmt.Sscanf(c.DefaultPostForm("x", "0"), "%f", &x)
-	fmt.Sscanf(c.DefaultPostForm("y", "0"), "%f", &y)
-	fmt.Sscanf(c.DefaultPostForm("fontSize", "24"), "%f", &fontSize)
-	color := c.DefaultPostForm("color", "#000000")
+	utils.Success(c, res)
+}
+
+// DetectStructure handles POST /api/pdf/detect-structure
+// Accepts file (or fileId) and infers the document's headings. ai=true has
+// the model confirm and level them; writeBookmarks=true replaces the
+// document's bookmarks with the outline and stores the result.
+func (h *CorePDFHandler) DetectStructure(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "detect-structure", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	var useAI, writeBookmarks bool
+	var opts services.OutlineOptions
+	for field, dst := range map[string]*bool{"ai": &useAI, "writeBookmarks": &writeBookmarks} {
+		if v := c.PostForm(field); v != "" {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				utils.BadRequest(c, field+" must be true or false")
+				return
+			}
+		}
+	}
+	if v := c.PostForm("maxHeadings"); v != "" {
+		if opts.MaxHeadings, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "maxHeadings must be an integer")
+			return
+		}
+	}
+	if useAI {
+		if ai := h.capabilities.Get(services.CapabilityAI); !ai.Available {
+			utils.ServiceDisabled(c, services.CapabilityAI, ai.Reason)
+			return
+		}
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "detect-structure", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	outline, err := h.pdfService.DetectHeadings(c.Request.Context(), in, opts)
+	if err != nil {
+		h.logOperation(c, userID, "detect-structure", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidOutline) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to detect structure: "+err.Error())
+		return
+	}
+
+	res := &models.StructureResult{
+		OperationResult:   models.OperationResult{Outputs: []models.OperationOutput{}},
+		PageCount:         outline.PageCount,
+		BodyFontSize:      outline.BodyFontSize,
+		ExistingBookmarks: outline.ExistingBookmarks,
+		Headings:          outline.Headings,
+	}
+	if useAI && len(res.Headings) > 0 {
+		// The heuristic outline is still useful when the model fails
+		if refined, err := h.aiService.RefineHeadings(c.Request.Context(), res.Headings, res.BodyFontSize); err == nil {
+			res.Headings, res.AIAssisted = refined, true
+		}
+	}
+
+	if writeBookmarks {
+		if len(res.Headings) == 0 {
+			utils.BadRequest(c, "No headings detected to write as bookmarks")
+			return
+		}
+		result, err := h.pdfService.AddOutline(c.Request.Context(), in, res.Headings)
+		if err != nil {
+			h.logOperation(c, userID, "detect-structure", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+			utils.InternalServerError(c, "Failed to write bookmarks: "+err.Error())
+			return
+		}
+
+		outputFilename := h.outputName(c, userID, "detect-structure", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_bookmarked.pdf")
+		uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+		if err != nil {
+			utils.InternalServerError(c, "Failed to save file")
+			return
+		}
+		res.SetOutputs(outputFromUpload(uploadResult, res.PageCount))
+		res.BookmarksWritten = true
+	}
+	h.recordResult(c, userID, "detect-structure", []string{header.Filename}, res, res.PageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// SanitizePDF handles POST /api/pdf/sanitize
+// Removes metadata, scripts, attachments, hidden layers and earlier
+// revisions before external sharing, reporting what was removed
+func (h *CorePDFHandler) SanitizePDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "sanitize", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "sanitize", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, report, err := h.pdfService.Sanitize(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "sanitize", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to sanitize PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "sanitize", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_sanitized.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.SanitizeResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		OriginalSize:     in.Size(),
+		Removed:          *report,
+	}
+	h.recordResult(c, userID, "sanitize", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// ScanPDF handles POST /api/pdf/scan
+// Accepts file (or fileId) and reports JavaScript, launch actions, external
+// URIs and embedded executables without changing the document.
+func (h *CorePDFHandler) ScanPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "scan", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "scan", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	report, err := h.pdfService.ScanSecurity(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "scan", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to scan PDF: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(in)
+	res := &models.ScanResult{PageCount: pageCount, ScanReport: *report}
+	if stored {
+		res.FileID = strings.TrimSpace(c.PostForm("fileId"))
+	}
+	h.logOperation(c, userID, "scan", []string{header.Filename}, "", "success", "", pageCount, startTime)
+
+	utils.Success(c, res)
+}
 
-	data, err := io.ReadAll(file)
+// PreflightPDF handles POST /api/pdf/preflight
+// Accepts file (or fileId) and checks it for print: image DPI, RGB color,
+// trim and bleed boxes, transparency and font embedding. Optional minDpi
+// and bleed (mm).
+func (h *CorePDFHandler) PreflightPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "preflight", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	var opts services.PreflightOptions
+	if v := c.PostForm("minDpi"); v != "" {
+		if opts.MinDPI, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "minDpi must be an integer")
+			return
+		}
+	}
+	if v := c.PostForm("bleed"); v != "" {
+		if opts.BleedMM, err = strconv.ParseFloat(v, 64); err != nil {
+			utils.BadRequest(c, "bleed must be a number")
+			return
+		}
+	}
+	if opts, err = services.NormalizePreflightOptions(opts); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "preflight", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	report, err := h.pdfService.Preflight(c.Request.Context(), in, opts)
+	if err != nil {
+		h.logOperation(c, userID, "preflight", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidPreflight) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to preflight PDF: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(in)
+	res := &models.PreflightResult{PageCount: pageCount, PreflightReport: *report}
+	if stored {
+		res.FileID = strings.TrimSpace(c.PostForm("fileId"))
+	}
+	h.logOperation(c, userID, "preflight", []string{header.Filename}, "", "success", "", pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// InvertPDF handles POST /api/pdf/invert
+// Accepts file (or fileId) and produces a dark-mode version. Optional
+// background and foreground hex colors; images=dim veils images.
+func (h *CorePDFHandler) InvertPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
+		h.respondInputError(c, userID, "invert", stored, err, startTime)
 		return
 	}
+	defer file.Close()
 
-	result, err := h.pdfService.DrawTextOnPDF(c.Request.Context(), data, services.DrawTextOptions{
-		Text:     text,
-		X:        x,
-		Y:        y,
-		FontSize: fontSize,
-		Color:    color,
+	opts, err := services.NormalizeInvertOptions(services.InvertOptions{
+		Background: strings.TrimSpace(c.PostForm("background")),
+		Foreground: strings.TrimSpace(c.PostForm("foreground")),
+		Images:     c.PostForm("images"),
 	})
 	if err != nil {
-		h.logOperation(userID, "draw-text", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "invert", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "invert", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, err := h.pdfService.InvertColors(c.Request.Context(), in, opts)
+	if err != nil {
+		h.logOperation(c, userID, "invert", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidInvert) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to invert PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "invert", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_dark.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.InvertResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Settings: models.InvertSettings{
+			Background: opts.Background,
+			Foreground: opts.Foreground,
+			Images:     opts.Images,
+		},
+	}
+	h.recordResult(c, userID, "invert", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// DrawTextPDF handles POST /api/pdf/draw-text
+// Adds custom text at specific coordinates. Accepts file (or fileId) plus
+// either the fields of a single placement or "placements", a JSON array of
+// them drawn in one pass.
+func (h *CorePDFHandler) DrawTextPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "draw-text", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	placements, err := textPlacementsFromForm(c)
+	if err != nil {
+		h.logOperation(c, userID, "draw-text", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+
+	result, err := h.pdfService.DrawTextBatch(c.Request.Context(), in, placements)
+	if err != nil {
+		h.logOperation(c, userID, "draw-text", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidDrawText) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalServerError(c, "Failed to draw text: "+err.Error())
 		return
 	}
 
-	outputFilename := "custom_" + header.Filename
+	outputFilename := h.outputName(c, userID, "draw-text", header.Filename, "", "custom_"+header.Filename)
 	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
 	if err != nil {
 		utils.InternalServerError(c, "Failed to save file")
 		return
 	}
 
-	h.logOperation(userID, "draw-text", []string{header.Filename}, uploadResult.FileID, "success", "", 0, startTime)
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.DrawTextResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Placements:       make([]models.TextPlacement, len(placements)),
+	}
+	for i, p := range placements {
+		res.Placements[i] = textPlacement(p)
+	}
+	h.recordResult(c, userID, "draw-text", []string{header.Filename}, res, pageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"fileId": uploadResult.FileID,
-		"url":    uploadResult.URL,
-	})
+	utils.Success(c, res)
+}
+
+// textPlacementsFromForm reads the "placements" JSON array, or a single
+// placement from the text, x, y, fontSize, color, font, pages, width, align,
+// rotation and unit fields. A top-level unit applies to batch entries that
+// don't set their own. Every placement is normalized.
+func textPlacementsFromForm(c *gin.Context) ([]services.DrawTextOptions, error) {
+	var batch []models.TextPlacement
+	if raw := strings.TrimSpace(c.PostForm("placements")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &batch); err != nil {
+			return nil, fmt.Errorf("%w: placements must be a JSON array: %v", services.ErrInvalidDrawText, err)
+		}
+		if len(batch) == 0 {
+			return nil, fmt.Errorf("%w: placements is empty", services.ErrInvalidDrawText)
+		}
+	} else {
+		p := models.TextPlacement{
+			Text:  c.PostForm("text"),
+			Color: c.PostForm("color"),
+			Font:  c.PostForm("font"),
+			Pages: c.PostForm("pages"),
+			Align: c.PostForm("align"),
+			Unit:  c.PostForm("unit"),
+		}
+		floats := []struct {
+			field string
+			dst   *float64
+		}{
+			{"x", &p.X}, {"y", &p.Y}, {"fontSize", &p.FontSize}, {"width", &p.Width}, {"rotation", &p.Rotation},
+		}
+		for _, f := range floats {
+			if v := c.PostForm(f.field); v != "" {
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s must be a number", services.ErrInvalidDrawText, f.field)
+				}
+				*f.dst = n
+			}
+		}
+		batch = []models.TextPlacement{p}
+	}
+	if len(batch) > services.MaxTextPlacements {
+		return nil, fmt.Errorf("%w: at most %d placements per request", services.ErrInvalidDrawText, services.MaxTextPlacements)
+	}
+
+	placements := make([]services.DrawTextOptions, len(batch))
+	for i, p := range batch {
+		if p.Unit == "" {
+			p.Unit = c.PostForm("unit")
+		}
+		opts, err := services.NormalizeDrawTextOptions(services.DrawTextOptions{
+			Text:       p.Text,
+			X:          p.X,
+			Y:          p.Y,
+			FontSize:   p.FontSize,
+			Color:      p.Color,
+			FontFamily: p.Font,
+			Pages:      p.Pages,
+			Width:      p.Width,
+			Align:      p.Align,
+			Rotation:   p.Rotation,
+			Unit:       p.Unit,
+		})
+		if err != nil {
+			if len(batch) > 1 {
+				return nil, fmt.Errorf("placement %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+		placements[i] = opts
+	}
+	return placements, nil
+}
+
+func textPlacement(opts services.DrawTextOptions) models.TextPlacement {
+	return models.TextPlacement{
+		Text:     opts.Text,
+		X:        opts.X,
+		Y:        opts.Y,
+		FontSize: opts.FontSize,
+		Color:    opts.Color,
+		Font:     opts.FontFamily,
+		Pages:    opts.Pages,
+		Width:    opts.Width,
+		Align:    opts.Align,
+		Rotation: opts.Rotation,
+		Unit:     opts.Unit,
+	}
 }
 
 // AddBadgePDF handles POST /api/pdf/add-badge
@@ -1309,10 +2127,9 @@ func (h *CorePDFHandler) AddBadgePDF(c *gin.Context) {
 	startTime := time.Now()
 	userID, _ := middleware.GetUserID(c)
 
-	file, header, err := c.Request.FormFile("file")
+	file, header, stored, err := h.openInput(c, userID)
 	if err != nil {
-		h.logOperation(userID, "add-badge", nil, "", "error", "No file provided", 0, startTime)
-		utils.BadRequest(c, "No PDF file provided")
+		h.respondInputError(c, userID, "add-badge", stored, err, startTime)
 		return
 	}
 	defer file.Close()
@@ -1322,47 +2139,142 @@ func (h *CorePDFHandler) AddBadgePDF(c *gin.Context) {
 	fmt.Sscanf(c.DefaultPostForm("x", "0"), "%f", &x)
 	fmt.Sscanf(c.DefaultPostForm("y", "0"), "%f", &y)
 	fmt.Sscanf(c.DefaultPostForm("scale", "1.0"), "%f", &scale)
+	unit := c.PostForm("unit")
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.AddBadgeOnPDF(c.Request.Context(), data, services.BadgeOptions{
+	result, err := h.pdfService.AddBadgeOnPDF(c.Request.Context(), in, services.BadgeOptions{
 		Type:  badgeType,
 		X:     x,
 		Y:     y,
 		Scale: scale,
+		Unit:  unit,
 	})
 	if err != nil {
-		h.logOperation(userID, "add-badge", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		h.logOperation(c, userID, "add-badge", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidPlacement) || errors.Is(err, services.ErrUnknownBadge) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalServerError(c, "Failed to add badge: "+err.Error())
 		return
 	}
 
-	outputFilename := "badged_" + header.Filename
+	outputFilename := h.outputName(c, userID, "add-badge", header.Filename, "", "badged_"+header.Filename)
 	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
 	if err != nil {
 		utils.InternalServerError(c, "Failed to save file")
 		return
 	}
 
-	h.logOperation(userID, "add-badge", []string{header.Filename}, uploadResult.FileID, "success", "", 0, startTime)
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := singleFileResult(uploadResult, pageCount)
+	h.recordResult(c, userID, "add-badge", []string{header.Filename}, &res, pageCount, startTime)
 
-	utils.Success(c, gin.H{
-		"fileId": uploadResult.FileID,
-		"url":    uploadResult.URL,
+	utils.Success(c, &res)
+}
+
+// StampSignature handles POST /api/pdf/stamp-signature
+// Accepts file (or fileId) + signatureId from the user's signature library,
+// x/y position and width in points, and optional pages (default: last page)
+func (h *CorePDFHandler) StampSignature(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+	if userID == "" {
+		utils.Unauthorized(c, "Sign in to use saved signatures")
+		return
+	}
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "stamp-signature", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	signatureID := c.PostForm("signatureId")
+	if signatureID == "" {
+		utils.BadRequest(c, "signatureId is required")
+		return
+	}
+
+	var x, y, width, opacity float64
+	fmt.Sscanf(c.DefaultPostForm("x", "0"), "%f", &x)
+	fmt.Sscanf(c.DefaultPostForm("y", "0"), "%f", &y)
+	fmt.Sscanf(c.DefaultPostForm("width", "150"), "%f", &width)
+	fmt.Sscanf(c.DefaultPostForm("opacity", "1"), "%f", &opacity)
+	if x < 0 || y < 0 || width <= 0 {
+		utils.BadRequest(c, "x and y must be non-negative and width positive")
+		return
+	}
+
+	_, img, err := h.signatures.GetImage(c.Request.Context(), userID, signatureID)
+	if err != nil {
+		if errors.Is(err, services.ErrSignatureNotFound) {
+			utils.NotFound(c, "Signature not found")
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "stamp-signature", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	// Signatures usually go on the last page
+	pages := c.DefaultPostForm("pages", "l")
+	if pages != "l" {
+		pageCount, _ := h.pdfService.GetPageCount(in)
+		if err := validatePageRangesAgainstCount(pages, pageCount); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	result, err := h.pdfService.StampImage(c.Request.Context(), in, img, services.ImageStampOptions{
+		X:       x,
+		Y:       y,
+		Width:   width,
+		Pages:   pages,
+		Opacity: opacity,
 	})
+	if err != nil {
+		h.logOperation(c, userID, "stamp-signature", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to stamp signature: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "stamp-signature", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_signed.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.StampResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		SignatureID:      signatureID,
+		StampedPages:     pages,
+	}
+	h.recordResult(c, userID, "stamp-signature", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
 }
 
 // RegisterRoutes registers core PDF routes
 func (h *CorePDFHandler) RegisterRoutes(r *gin.RouterGroup) {
 	pdf := r.Group("/pdf")
+	pdf.Use(checkFilenameTemplate, h.runAsync)
 	{
 		// Phase 3: Core tools
 		pdf.POST("/merge", h.MergePDF)
 		pdf.POST("/split", h.SplitPDF)
+		pdf.POST("/auto-split", h.AutoSplitPDF)
 		// Phase 4: Rotate & Compress
 		pdf.POST("/rotate", h.RotatePDF)
 		pdf.POST("/compress", h.CompressPDF)
@@ -1374,12 +2286,33 @@ func (h *CorePDFHandler) RegisterRoutes(r *gin.RouterGroup) {
 		pdf.POST("/reorder", h.ReorderPages)
 		pdf.POST("/remove", h.RemovePages)
 		pdf.POST("/info", h.GetPDFInfo)
+		pdf.GET("/:fileId/pages", h.PageLayout)
+		pdf.POST("/search", h.SearchPDF)
+		pdf.POST("/detect-structure", h.DetectStructure)
+		pdf.POST("/sanitize", h.SanitizePDF)
+		pdf.POST("/scan", h.ScanPDF)
+		pdf.POST("/scan-document", h.ScanDocument)
+		pdf.POST("/from-images", h.FromImages)
+		pdf.POST("/invert", h.InvertPDF)
+		pdf.POST("/protect", h.ProtectPDF)
+		pdf.POST("/unlock", h.UnlockPDF)
+		pdf.POST("/preflight", h.PreflightPDF)
+		pdf.POST("/form/fields", h.FormFields)
+		pdf.POST("/form/fill", h.FillForm)
+		pdf.POST("/form/flatten", h.FlattenForm)
+		pdf.POST("/thumbnails", middleware.RequireCapability(h.capabilities, services.CapabilityThumbnails), h.Thumbnails)
+		pdf.GET("/history", h.History)
+		pdf.GET("/progress/:id", h.Progress)
 		// Phase 7: Extract pages
 		pdf.POST("/extract", h.ExtractPages)
+		pdf.POST("/pipeline", h.PipelinePDF)
 		
 		// Phase 8: Manual Tools (Premium)
 		pdf.POST("/draw-text", h.DrawTextPDF)
 		pdf.POST("/add-badge", h.AddBadgePDF)
+		pdf.POST("/stamp-signature", h.StampSignature)
+		pdf.POST("/sign", h.SignPDF)
+		pdf.POST("/verify-signature", h.VerifySignature)
 	}
 }
 
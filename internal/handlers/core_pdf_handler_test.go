@@ -0,0 +1,137 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"brainy-pdf/internal/pdfgen"
+	"brainy-pdf/internal/testutil"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// TestUploadMergeShareDownload drives upload -> merge -> share -> public
+// download as a paid user and verifies the downloaded PDF page count. Run
+// with: go test -tags integration ./internal/handlers/
+func TestUploadMergeShareDownload(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	uid := "it-merge-user"
+	env.SeedUser(t, uid, "pro")
+
+	// Upload
+	req, err := testutil.NewMultipartRequest(http.MethodPost, "/api/v1/files/upload",
+		[]testutil.FormFile{{Field: "file", Name: "upload.pdf", Data: pdfgen.Sample(2)}}, nil)
+	if err != nil {
+		t.Fatalf("build upload request: %v", err)
+	}
+	rec := env.Do(req, uid)
+	var uploaded struct {
+		FileID string `json:"fileId"`
+	}
+	if err := testutil.DecodeData(rec, &uploaded); err != nil || uploaded.FileID == "" {
+		t.Fatalf("upload: %v (body: %s)", err, rec.Body.String())
+	}
+
+	// Merge
+	req, err = testutil.NewMultipartRequest(http.MethodPost, "/api/pdf/merge", []testutil.FormFile{
+		{Field: "files", Name: "a.pdf", Data: pdfgen.Sample(2)},
+		{Field: "files", Name: "b.pdf", Data: pdfgen.Sample(3)},
+	}, nil)
+	if err != nil {
+		t.Fatalf("build merge request: %v", err)
+	}
+	rec = env.Do(req, uid)
+	var merged struct {
+		FileID    string `json:"fileId"`
+		PageCount int    `json:"pageCount"`
+	}
+	if err := testutil.DecodeData(rec, &merged); err != nil {
+		t.Fatalf("merge: %v (body: %s)", err, rec.Body.String())
+	}
+	if merged.PageCount != 5 {
+		t.Fatalf("merge: expected 5 pages, got %d", merged.PageCount)
+	}
+
+	// Share
+	req, err = testutil.NewJSONRequest(http.MethodPost, "/api/v1/share", map[string]interface{}{
+		"fileId":   merged.FileID,
+		"fileType": "temp",
+	})
+	if err != nil {
+		t.Fatalf("build share request: %v", err)
+	}
+	rec = env.Do(req, uid)
+	var shared struct {
+		Code string `json:"code"`
+	}
+	if err := testutil.DecodeData(rec, &shared); err != nil || shared.Code == "" {
+		t.Fatalf("share: %v (body: %s)", err, rec.Body.String())
+	}
+
+	// Public download, no auth
+	rec = env.Do(httptest.NewRequest(http.MethodGet, "/api/v1/share/download/"+shared.Code, nil), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("download: status %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	if n := pageCount(t, testutil.ReadAll(rec)); n != 5 {
+		t.Fatalf("download: expected 5 pages, got %d", n)
+	}
+}
+
+// TestSplitRanges checks that split returns exactly one file per requested
+// range, with the page counts and range labels of that range
+func TestSplitRanges(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	req, err := testutil.NewMultipartRequest(http.MethodPost, "/api/pdf/split",
+		[]testutil.FormFile{{Field: "file", Name: "ten.pdf", Data: pdfgen.Sample(10)}},
+		map[string]string{"pages": "1-3, 4-10"})
+	if err != nil {
+		t.Fatalf("build split request: %v", err)
+	}
+	rec := env.Do(req, "")
+
+	var result struct {
+		Files []struct {
+			PageCount int    `json:"pageCount"`
+			Range     string `json:"range"`
+		} `json:"files"`
+	}
+	if err := testutil.DecodeData(rec, &result); err != nil {
+		t.Fatalf("split: %v (body: %s)", err, rec.Body.String())
+	}
+
+	want := []struct {
+		pages int
+		label string
+	}{{3, "pages_1to3"}, {7, "pages_4to10"}}
+	if len(result.Files) != len(want) {
+		t.Fatalf("split: expected %d files, got %d", len(want), len(result.Files))
+	}
+	for i, w := range want {
+		got := result.Files[i]
+		if got.PageCount != w.pages || got.Range != w.label {
+			t.Errorf("split part %d: got %d pages %q, want %d pages %q", i+1, got.PageCount, got.Range, w.pages, w.label)
+		}
+	}
+}
+
+func pageCount(t *testing.T, data []byte) int {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		n := len(data)
+		if n > 64 {
+			n = 64
+		}
+		t.Fatalf("expected a PDF body, got %q", data[:n])
+	}
+	n, err := api.PageCount(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("read page count: %v", err)
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// storedFile is a file already in storage, spooled to scratch and served
+// through the multipart.File interface the handlers read uploads with.
+// Closing it removes the copy.
+type storedFile struct {
+	*os.File
+	scratch *services.Scratch
+}
+
+func (f storedFile) Close() error {
+	err := f.File.Close()
+	f.scratch.Close()
+	return err
+}
+
+// errInvalidJSON is returned by bindJSONForm for malformed bodies
+var errInvalidJSON = errors.New("invalid JSON body")
+
+// openInput returns the PDF to operate on: the "file" upload, or the stored
+// file named by "fileId" so library workflows don't re-upload bytes already
+// in MinIO. JSON bodies ({"fileId": ..., "pages": ..., "angle": ...}) are
+// accepted as well and exposed through c.PostForm. stored reports a fileId
+// input, which is streamed from storage to a scratch file so it is read in
+// place like an upload the multipart parser spooled to disk. Files
+// encrypted at rest are decrypted in memory first.
+func (h *CorePDFHandler) openInput(c *gin.Context, userID string) (file multipart.File, header *multipart.FileHeader, stored bool, err error) {
+	if err := bindJSONForm(c); err != nil {
+		return nil, nil, false, err
+	}
+
+	fileID := strings.TrimSpace(c.PostForm("fileId"))
+	if fileID == "" {
+		file, header, err = c.Request.FormFile("file")
+		return file, header, false, err
+	}
+
+	doc, reader, size, err := h.storageService.OpenFileForUser(c.Request.Context(), fileID, userID)
+	if err != nil {
+		return nil, nil, true, err
+	}
+
+	defer reader.Close()
+
+	scratch, err := h.pdfService.NewScratch("input")
+	if err != nil {
+		return nil, nil, true, err
+	}
+	path, err := h.pdfService.SpoolToDisk(c.Request.Context(), scratch, reader, size, nil)
+	if err != nil {
+		scratch.Close()
+		return nil, nil, true, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		scratch.Close()
+		return nil, nil, true, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	header = &multipart.FileHeader{Filename: storedName(doc), Size: size}
+	return storedFile{File: f, scratch: scratch}, header, true, nil
+}
+
+// inputReader reads an input opened by openInput in place, whether it is
+// held in memory or on disk
+func inputReader(file multipart.File, header *multipart.FileHeader) *io.SectionReader {
+	return io.NewSectionReader(file, 0, header.Size)
+}
+
+// storedName is the name a stored document was uploaded as
+func storedName(doc *models.Document) string {
+	if doc.OriginalName != "" {
+		return doc.OriginalName
+	}
+	return doc.Filename
+}
+
+// formList reads a list field sent as repeated form fields, a
+// comma-separated value or a JSON array, dropping empty entries
+func formList(c *gin.Context, key string) []string {
+	var list []string
+	for _, value := range c.PostFormArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// bindJSONForm copies the fields of a JSON body into the request's form so
+// handlers read them with c.PostForm like multipart fields. Arrays of
+// scalars become comma-separated lists ([3, 1, 2] -> "3,1,2"); arrays of
+// objects stay JSON.
+func bindJSONForm(c *gin.Context) error {
+	if !strings.HasPrefix(c.ContentType(), "application/json") || c.Request.PostForm != nil {
+		return nil
+	}
+
+	var body map[string]interface{}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidJSON, err)
+	}
+
+	form := url.Values{}
+	for key, value := range body {
+		form.Set(key, formValue(value))
+	}
+	c.Request.PostForm = form
+	c.Request.Form = form
+	return nil
+}
+
+// respondInputError logs and reports an openInput failure
+func (h *CorePDFHandler) respondInputError(c *gin.Context, userID, operation string, stored bool, err error, startTime time.Time) {
+	switch {
+	case errors.Is(err, errInvalidJSON):
+		h.logOperation(c, userID, operation, nil, "", "error", "Invalid JSON body", 0, startTime)
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrFileAccessDenied):
+		// Other users' files are reported as missing rather than forbidden
+		h.logOperation(c, userID, operation, nil, "", "error", "Stored file not found", 0, startTime)
+		utils.NotFound(c, "File not found")
+	case errors.Is(err, services.ErrFileArchived):
+		h.logOperation(c, userID, operation, nil, "", "error", "Stored file archived", 0, startTime)
+		respondArchived(c)
+	case stored:
+		h.logOperation(c, userID, operation, nil, "", "error", "Failed to load stored file", 0, startTime)
+		utils.InternalServerError(c, "Failed to load file: "+err.Error())
+	default:
+		h.logOperation(c, userID, operation, nil, "", "error", "No file provided", 0, startTime)
+		utils.BadRequest(c, "No PDF file provided (upload \"file\" or pass \"fileId\")")
+	}
+}
+
+func formValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				b, _ := json.Marshal(v)
+				return string(b)
+			}
+			parts[i] = formValue(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
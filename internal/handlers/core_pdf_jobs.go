@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SetJobService lets /api/pdf operations run as background jobs when sent
+// with async=true. Start the service's workers with RunJob.
+func (h *CorePDFHandler) SetJobService(jobs *services.JobService) {
+	h.jobs = jobs
+}
+
+// syncOnlyOperations take inputs that must not be kept in the job queue,
+// like a signing certificate and its password
+var syncOnlyOperations = map[string]bool{"sign": true}
+
+// runAsync queues a POST sent with async=true (as a query or form value)
+// instead of running it, and answers 202 with the job ID. The job runs
+// the same operation later; GET /api/v1/jobs/:id reports its progress and
+// result.
+func (h *CorePDFHandler) runAsync(c *gin.Context) {
+	if c.Request.Method != http.MethodPost {
+		c.Next()
+		return
+	}
+	value := c.Query("async")
+	if value == "" {
+		value = c.PostForm("async")
+	}
+	if value == "" {
+		c.Next()
+		return
+	}
+	async, err := strconv.ParseBool(value)
+	if err != nil {
+		utils.BadRequest(c, "async must be true or false")
+		c.Abort()
+		return
+	}
+	if !async {
+		c.Next()
+		return
+	}
+	if syncOnlyOperations[path.Base(c.FullPath())] {
+		utils.BadRequest(c, path.Base(c.FullPath())+" can't run in the background")
+		c.Abort()
+		return
+	}
+	if h.jobs == nil {
+		utils.ServiceUnavailable(c, "Background jobs are not available")
+		c.Abort()
+		return
+	}
+
+	// The form is already parsed: multipart by PostForm above, JSON by
+	// checkFilenameTemplate
+	fields := url.Values{}
+	for key, values := range c.Request.PostForm {
+		if key != "async" && key != "progressId" {
+			fields[key] = values
+		}
+	}
+	query := c.Request.URL.Query()
+	query.Del("async")
+	var files map[string][]*multipart.FileHeader
+	if c.Request.MultipartForm != nil {
+		files = c.Request.MultipartForm.File
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	lane := h.userService.QueueLane(c.Request.Context(), userID)
+	operation := path.Base(c.FullPath())
+	job, err := h.jobs.SubmitJob(userID, lane, operation, fields, query.Encode(), files)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to queue job: "+err.Error())
+		c.Abort()
+		return
+	}
+
+	utils.SuccessWithStatus(c, http.StatusAccepted, gin.H{
+		"jobId":     job.ID,
+		"status":    job.Status,
+		"operation": job.Operation,
+		"lane":      job.Lane,
+		"statusUrl": "/api/v1/jobs/" + job.ID,
+	})
+	c.Abort()
+}
+
+// jobUserKey carries a job's user to the routes it is run through
+type jobUserKey struct{}
+
+// RunJob is the services.PDFJobRunner for /api/pdf jobs. It sends the
+// job's fields and uploads through the /api/pdf routes as the job's user,
+// so the operation is validated, logged and stored exactly as when run
+// synchronously, and returns the data of the response. Progress is
+// tracked under the job ID.
+func (h *CorePDFHandler) RunJob(ctx context.Context, job *services.PDFJob, inputs []string) (json.RawMessage, error) {
+	fields := url.Values{}
+	for key, values := range job.Fields {
+		fields[key] = values
+	}
+	fields.Set("progressId", job.ID)
+
+	// Uploads are streamed from disk; the routes spool large parts back to
+	// disk as usual
+	body, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeJobForm(form, fields, job.Inputs, inputs))
+	}()
+	defer body.Close()
+
+	target := "/api/pdf/" + job.Operation
+	if job.Query != "" {
+		target += "?" + job.Query
+	}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, jobUserKey{}, job.UserID), http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	rec := httptest.NewRecorder()
+	h.jobRouter().ServeHTTP(rec, req)
+
+	var resp struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   *utils.APIError `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+		if resp.Error != nil {
+			return nil, errors.New(resp.Error.Message)
+		}
+		return nil, fmt.Errorf("%s failed: %s", job.Operation, http.StatusText(rec.Code))
+	}
+	return resp.Data, nil
+}
+
+// jobRouter serves the /api/pdf routes to RunJob, authenticated as the
+// job's user
+func (h *CorePDFHandler) jobRouter() *gin.Engine {
+	h.jobRouterOnce.Do(func() {
+		router := gin.New()
+		router.Use(gin.Recovery())
+		api := router.Group("/api")
+		api.Use(func(c *gin.Context) {
+			if userID, _ := c.Request.Context().Value(jobUserKey{}).(string); userID != "" {
+				c.Set(string(middleware.UserIDKey), userID)
+			}
+		})
+		h.RegisterRoutes(api)
+		h.jobEngine = router
+	})
+	return h.jobEngine
+}
+
+// writeJobForm writes a job's fields and uploads as a multipart form
+func writeJobForm(form *multipart.Writer, fields url.Values, uploads []services.PDFJobInput, paths []string) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range fields[key] {
+			if err := form.WriteField(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, upload := range uploads {
+		part, err := form.CreateFormFile(upload.Field, upload.Filename)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(paths[i])
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
@@ -0,0 +1,83 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brainy-pdf/internal/pdfgen"
+	"brainy-pdf/internal/testutil"
+)
+
+// TestAsyncMerge submits a merge with async=true, polls /api/v1/jobs/:id
+// until it completes and checks the merged file it reports, and that
+// another user can't see the job
+func TestAsyncMerge(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	uid := "it-async-user"
+	env.SeedUser(t, uid, "pro")
+
+	req, err := testutil.NewMultipartRequest(http.MethodPost, "/api/pdf/merge", []testutil.FormFile{
+		{Field: "files", Name: "a.pdf", Data: pdfgen.Sample(2)},
+		{Field: "files", Name: "b.pdf", Data: pdfgen.Sample(3)},
+	}, map[string]string{"async": "true"})
+	if err != nil {
+		t.Fatalf("build merge request: %v", err)
+	}
+	rec := env.Do(req, uid)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("async merge: status %d, want 202 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	if err := testutil.DecodeData(rec, &queued); err != nil || queued.JobID == "" {
+		t.Fatalf("async merge: %v (body: %s)", err, rec.Body.String())
+	}
+
+	if rec := env.Do(httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+queued.JobID, nil), "it-someone-else"); rec.Code != http.StatusNotFound {
+		t.Errorf("job of another user: status %d, want 404", rec.Code)
+	}
+
+	var job struct {
+		Status  string   `json:"status"`
+		Error   string   `json:"error"`
+		FileIDs []string `json:"fileIds"`
+		Result  struct {
+			PageCount int `json:"pageCount"`
+		} `json:"result"`
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		rec := env.Do(httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+queued.JobID, nil), uid)
+		if err := testutil.DecodeData(rec, &job); err != nil {
+			t.Fatalf("job status: %v (body: %s)", err, rec.Body.String())
+		}
+		if job.Status == "completed" || job.Status == "failed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s after 30s", job.Status)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if job.Status != "completed" {
+		t.Fatalf("job failed: %s", job.Error)
+	}
+	if len(job.FileIDs) != 1 || job.Result.PageCount != 5 {
+		t.Fatalf("job result: %d files and %d pages, want 1 file of 5 pages", len(job.FileIDs), job.Result.PageCount)
+	}
+	_, data, err := env.Storage.GetFileForUser(context.Background(), job.FileIDs[0], uid)
+	if err != nil {
+		t.Fatalf("merged file: %v", err)
+	}
+	if n := pageCount(t, data); n != 5 {
+		t.Fatalf("merged file: expected 5 pages, got %d", n)
+	}
+}
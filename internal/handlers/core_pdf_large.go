@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Large-file mode: inputs above config.LargeFileThreshold(), uploaded or
+// stored, are spooled to disk and processed file-to-file so the request
+// never holds the whole PDF (or its output) in memory.
+
+// isLargeFile reports whether an upload should use disk-backed processing
+func isLargeFile(size int64) bool {
+	return size > config.LargeFileThreshold()
+}
+
+// progressLogger logs progress for each stage in 10% steps
+func progressLogger(operation, filename string) services.ProgressFunc {
+	last := map[string]int64{}
+	return func(stage string, done, total int64) {
+		if total <= 0 {
+			return
+		}
+		step := done * 10 / total
+		if prev, ok := last[stage]; ok && prev >= step {
+			return
+		}
+		last[stage] = step
+		log.Printf("[LargeFile] %s %s: %s %d%%", operation, filename, stage, step*10)
+	}
+}
+
+// newScratch creates the request's temp directory, responding with an
+// error if it can't. The caller must Close it.
+func (h *CorePDFHandler) newScratch(c *gin.Context, userID, operation, filename string, startTime time.Time) (*services.Scratch, bool) {
+	scratch, err := h.pdfService.NewScratch(operation)
+	if err != nil {
+		h.logOperation(c, userID, operation, []string{filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to create temp dir")
+		return nil, false
+	}
+	return scratch, true
+}
+
+// respondTempQuota responds 413 if err is the job outgrowing its temp
+// quota, reporting whether it did
+func respondTempQuota(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrScratchQuota) {
+		return false
+	}
+	utils.Error(c, http.StatusRequestEntityTooLarge, "TEMP_QUOTA_EXCEEDED", err.Error())
+	return true
+}
+
+// spoolUpload copies an input opened by openInput into scratch, returning
+// its path
+func (h *CorePDFHandler) spoolUpload(c *gin.Context, scratch *services.Scratch, file io.Reader, header *multipart.FileHeader, progress services.ProgressFunc) (string, error) {
+	return h.pdfService.SpoolToDisk(c.Request.Context(), scratch, file, header.Size, progress)
+}
+
+// compressLarge is the disk-backed variant of CompressPDF
+func (h *CorePDFHandler) compressLarge(c *gin.Context, file io.Reader, header *multipart.FileHeader, userID, quality string, dryRun bool, startTime time.Time) {
+	progress := progressLogger("compress", header.Filename)
+	scratch, ok := h.newScratch(c, userID, "compress", header.Filename, startTime)
+	if !ok {
+		return
+	}
+	defer scratch.Close()
+
+	inPath, err := h.spoolUpload(c, scratch, file, header, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	if err := h.pdfService.ValidatePDFFile(inPath); err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCountFile(inPath)
+
+	outPath, result, err := h.pdfService.CompressFile(c.Request.Context(), scratch, inPath, quality, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "Failed to compress PDF: "+err.Error())
+		return
+	}
+	profile := h.imageProfileFile(inPath)
+
+	if dryRun {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "success", "", pageCount, startTime)
+		utils.Success(c, compressEstimate(header.Filename, services.CompressModeStandard, quality, result.SizeBefore, result.SizeAfter, profile, nil))
+		return
+	}
+
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	outputFilename := h.outputName(c, userID, "compress", header.Filename, "", fmt.Sprintf("%s_compressed.pdf", baseName))
+
+	uploadResult, err := h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, progress)
+	if err != nil {
+		h.logOperation(c, userID, "compress", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save compressed PDF: "+err.Error())
+		return
+	}
+
+	reduction := result.Compression
+	if reduction < 0 {
+		reduction = 0
+	}
+
+	res := &models.CompressResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		OriginalSize:     result.SizeBefore,
+		CompressedSize:   result.SizeAfter,
+		Reduction:        fmt.Sprintf("%.1f%%", reduction),
+		Quality:          quality,
+		Mode:             services.CompressModeStandard,
+		LargeFileMode:    true,
+		ImageProfile:     profile,
+	}
+	h.recordResult(c, userID, "compress", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// rotateLarge is the disk-backed variant of RotatePDF
+func (h *CorePDFHandler) rotateLarge(c *gin.Context, file io.Reader, header *multipart.FileHeader, userID, pages string, angle int, rotations map[int]int, startTime time.Time) {
+	progress := progressLogger("rotate", header.Filename)
+	scratch, ok := h.newScratch(c, userID, "rotate", header.Filename, startTime)
+	if !ok {
+		return
+	}
+	defer scratch.Close()
+
+	inPath, err := h.spoolUpload(c, scratch, file, header, progress)
+	if err != nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	if err := h.pdfService.ValidatePDFFile(inPath); err != nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCountFile(inPath)
+
+	var outPath string
+	if rotations != nil {
+		outPath, err = h.pdfService.RotatePagesFile(c.Request.Context(), scratch, inPath, rotations, progress)
+	} else {
+		outPath, err = h.pdfService.RotateFile(c.Request.Context(), scratch, inPath, pages, angle, progress)
+	}
+	if err != nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidRotation) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "Failed to rotate PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "rotate", header.Filename, "", rotatedName(header.Filename, angle))
+
+	uploadResult, err := h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, progress)
+	if err != nil {
+		h.logOperation(c, userID, "rotate", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save rotated PDF: "+err.Error())
+		return
+	}
+
+	res := &models.RotateResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Angle:            angle,
+		Rotations:        rotationsByPage(rotations),
+		LargeFileMode:    true,
+	}
+	h.recordResult(c, userID, "rotate", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// splitLarge is the disk-backed variant of SplitPDF
+func (h *CorePDFHandler) splitLarge(c *gin.Context, file io.Reader, header *multipart.FileHeader, userID, pageRanges string, tracker *services.ProgressTracker, startTime time.Time) {
+	progress := progressLogger("split", header.Filename)
+	scratch, ok := h.newScratch(c, userID, "split", header.Filename, startTime)
+	if !ok {
+		return
+	}
+	defer scratch.Close()
+
+	inPath, err := h.spoolUpload(c, scratch, file, header, progress)
+	if err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	if err := h.pdfService.ValidatePDFFile(inPath); err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	pageCount, err := h.pdfService.GetPageCountFile(inPath)
+	if err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "Failed to read PDF", 0, startTime)
+		utils.InternalServerError(c, "Failed to read PDF")
+		return
+	}
+
+	if err := validatePageRangesAgainstCount(pageRanges, pageCount); err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	pagesPerRange := rangePages(pageRanges)
+	tracker.SetTotalPages(c.Request.Context(), sumPages(pagesPerRange, len(pagesPerRange)))
+	outPaths, err := h.pdfService.SplitFile(c.Request.Context(), scratch, inPath, pageRanges, rangeProgress(c.Request.Context(), tracker, pagesPerRange, progress))
+	if err != nil {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "Failed to split PDF: "+err.Error())
+		return
+	}
+
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	ranges := parseRangesForNaming(pageRanges)
+
+	var outputFiles []models.OperationOutput
+	var failedParts []models.FailedPart
+
+	for i, outPath := range outPaths {
+		rangeName := fmt.Sprintf("part%d", i+1)
+		if i < len(ranges) {
+			rangeName = ranges[i]
+		}
+		outputFilename := h.outputName(c, userID, "split", header.Filename, rangeName, fmt.Sprintf("%s_%s.pdf", baseName, rangeName))
+
+		uploadResult, failed := uploadPart(c.Request.Context(), rangeName, outputFilename, func() (*services.UploadResult, error) {
+			return h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, progress)
+		})
+		tracker.Pages(c.Request.Context(), models.ProgressStageUploading, sumPages(pagesPerRange, i+1))
+		if failed != nil {
+			failedParts = append(failedParts, *failed)
+			continue
+		}
+
+		output := outputFromUpload(uploadResult, uploadResult.Metadata.PageCount)
+		output.Range = rangeName
+		outputFiles = append(outputFiles, output)
+	}
+
+	if len(outputFiles) == 0 {
+		h.logOperation(c, userID, "split", []string{header.Filename}, "", "error", "No files created", 0, startTime)
+		utils.InternalServerError(c, "Failed to create any split files: "+failedParts[0].Error)
+		return
+	}
+
+	res := &models.SplitResult{
+		Files:         outputFiles,
+		TotalFiles:    len(outputFiles),
+		FailedParts:   failedParts,
+		InputFile:     header.Filename,
+		InputPages:    pageCount,
+		LargeFileMode: true,
+	}
+	res.SetOutputs(outputFiles...)
+	h.recordResult(c, userID, "split", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// pageSelectionLarge is the disk-backed variant of ExtractPages and
+// RemovePages: run writes the output for the selected pages, and result
+// describes it given the input's and output's page counts
+func (h *CorePDFHandler) pageSelectionLarge(c *gin.Context, file io.Reader, header *multipart.FileHeader, userID, operation, pages, outputFilename string,
+	run func(ctx context.Context, scratch *services.Scratch, inPath, pages string, progress services.ProgressFunc) (string, error),
+	result func(upload *services.UploadResult, originalPages, pageCount int) operationResult, startTime time.Time) {
+	progress := progressLogger(operation, header.Filename)
+	scratch, ok := h.newScratch(c, userID, operation, header.Filename, startTime)
+	if !ok {
+		return
+	}
+	defer scratch.Close()
+
+	inPath, err := h.spoolUpload(c, scratch, file, header, progress)
+	if err != nil {
+		h.logOperation(c, userID, operation, []string{header.Filename}, "", "error", "Failed to read file", 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	if err := h.pdfService.ValidatePDFFile(inPath); err != nil {
+		h.logOperation(c, userID, operation, []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	originalPages, _ := h.pdfService.GetPageCountFile(inPath)
+	if err := validatePageRangesAgainstCount(pages, originalPages); err != nil {
+		h.logOperation(c, userID, operation, []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	outPath, err := run(c.Request.Context(), scratch, inPath, pages, progress)
+	if err != nil {
+		h.logOperation(c, userID, operation, []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if respondTempQuota(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "Failed to "+operation+" pages: "+err.Error())
+		return
+	}
+
+	uploadResult, err := h.storageService.UploadProcessedFileFromPath(c.Request.Context(), userID, outputFilename, outPath, progress)
+	if err != nil {
+		h.logOperation(c, userID, operation, []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save PDF: "+err.Error())
+		return
+	}
+
+	pageCount := uploadResult.Metadata.PageCount
+	res := result(uploadResult, originalPages, pageCount)
+	h.recordResult(c, userID, operation, []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PipelinePDF handles POST /api/pdf/pipeline
+// Accepts file (or fileId) + steps, a JSON array of operations applied in
+// order in one request, e.g. [{"operation": "rotate", "angle": 90},
+// {"operation": "watermark", "text": "DRAFT"}, {"operation": "compress"}].
+// Only the final PDF is stored.
+func (h *CorePDFHandler) PipelinePDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "pipeline", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", "Invalid file type", 0, startTime)
+		utils.BadRequest(c, "File must be a PDF")
+		return
+	}
+
+	maxSize := h.getMaxFileSize(c, userID)
+	if header.Size > maxSize {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", "File too large", 0, startTime)
+		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
+		return
+	}
+
+	// Steps leaving parameters out get the user's defaults, as the
+	// operations' own endpoints do
+	var prefs *models.Preferences
+	if h.preferences != nil && userID != "" {
+		if prefs, err = h.preferences.Get(c.Request.Context(), userID); err != nil {
+			log.Printf("[Preferences] Failed to load preferences of %s: %v", userID, err)
+		}
+	}
+	steps, err := services.ParsePipeline(c.PostForm("steps"), prefs)
+	if err != nil {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", "Invalid steps", 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, stepResults, err := h.pdfService.RunPipeline(c.Request.Context(), in, steps)
+	if err != nil {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidRotation) || errors.Is(err, services.ErrInvalidWatermark) || errors.Is(err, services.ErrInvalidPageNumbers) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Pipeline failed: "+err.Error())
+		return
+	}
+
+	baseName := strings.TrimSuffix(header.Filename, ".pdf")
+	outputFilename := h.outputName(c, userID, "pipeline", header.Filename, "", fmt.Sprintf("%s_processed.pdf", baseName))
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		h.logOperation(c, userID, "pipeline", []string{header.Filename}, "", "error", "Failed to upload result", 0, startTime)
+		utils.InternalServerError(c, "Failed to save processed PDF: "+err.Error())
+		return
+	}
+
+	pageCount := stepResults[len(stepResults)-1].PageCount
+	res := &models.PipelineResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		InputSize:        in.Size(),
+		Steps:            stepResults,
+	}
+	h.recordResult(c, userID, "pipeline", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"log"
+
+	"brainy-pdf/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetPreferencesService makes compress, watermark and page-numbers fill
+// parameters a request leaves out from the user's saved preferences
+func (h *CorePDFHandler) SetPreferencesService(preferences *services.PreferencesService) {
+	h.preferences = preferences
+}
+
+// applyPreferences sets the form fields of operation that are missing or
+// empty to the user's defaults, so handlers read them like sent values.
+// Call it after the input is opened, when the form has been parsed.
+func (h *CorePDFHandler) applyPreferences(c *gin.Context, userID, operation string) {
+	if h.preferences == nil || userID == "" || c.Request.PostForm == nil {
+		return
+	}
+	prefs, err := h.preferences.Get(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[Preferences] Failed to load preferences of %s: %v", userID, err)
+		return
+	}
+	// gin caches the form by reference, so this map is the one c.PostForm
+	// reads
+	for key, value := range services.PreferenceDefaults(prefs, operation) {
+		if c.Request.PostForm.Get(key) == "" {
+			c.Request.PostForm.Set(key, value)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Long operations report progress when the request carries a "progressId"
+// chosen by the client, which polls GET /api/pdf/progress/:id meanwhile.
+
+// startProgress begins tracking the request's operation; the returned
+// tracker is nil when the client didn't ask for progress
+func (h *CorePDFHandler) startProgress(c *gin.Context, userID, operation string) *services.ProgressTracker {
+	return h.progress.Start(c.Request.Context(), c.PostForm("progressId"), userID, operation)
+}
+
+// finishProgress marks tracked progress done, or failed when an error
+// response was sent. Deferred by handlers that track progress.
+func finishProgress(c *gin.Context, tracker *services.ProgressTracker) {
+	var err error
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		err = errors.New(http.StatusText(status))
+	}
+	tracker.Finish(c.Request.Context(), err)
+}
+
+// rangePages counts the pages in each non-empty range of a validated page
+// range list, e.g. "1-3, 5" gives [3 1]
+func rangePages(ranges string) []int {
+	var pages []int
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n := 1
+		if bounds := strings.Split(part, "-"); len(bounds) == 2 {
+			start, _ := parseInt(strings.TrimSpace(bounds[0]))
+			end, _ := parseInt(strings.TrimSpace(bounds[1]))
+			n = end - start + 1
+		}
+		pages = append(pages, n)
+	}
+	return pages
+}
+
+// sumPages adds up the first n entries of pages
+func sumPages(pages []int, n int) int {
+	total := 0
+	for i := 0; i < n && i < len(pages); i++ {
+		total += pages[i]
+	}
+	return total
+}
+
+// rangeProgress turns per-range progress reports (stage "process") into
+// pages processed on tracker, passing every report on to next
+func rangeProgress(ctx context.Context, tracker *services.ProgressTracker, pages []int, next services.ProgressFunc) services.ProgressFunc {
+	return func(stage string, done, total int64) {
+		if stage == "process" {
+			tracker.Pages(ctx, models.ProgressStageProcessing, sumPages(pages, int(done)))
+		}
+		if next != nil {
+			next(stage, done, total)
+		}
+	}
+}
+
+// Progress handles GET /api/pdf/progress/:id
+// Returns how far the caller's operation sent with that progressId has got
+func (h *CorePDFHandler) Progress(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	p, err := h.progress.Get(c.Request.Context(), c.Param("id"), userID)
+	if errors.Is(err, services.ErrProgressNotFound) {
+		utils.NotFound(c, "No progress recorded for this ID")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch progress")
+		return
+	}
+	utils.Success(c, p)
+}
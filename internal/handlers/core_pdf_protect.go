@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ProtectPDF handles POST /api/pdf/protect
+// Accepts file (or fileId) and password, needed to open the result.
+// Optional ownerPassword and restrict, a comma-separated list of print,
+// copy, modify and annotate that readers without the owner password are
+// held to.
+func (h *CorePDFHandler) ProtectPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "protect", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	opts, err := services.NormalizeProtectOptions(services.ProtectOptions{
+		UserPassword:  c.PostForm("password"),
+		OwnerPassword: c.PostForm("ownerPassword"),
+		Restrictions:  formList(c, "restrict"),
+	})
+	if err != nil {
+		h.logOperation(c, userID, "protect", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	if _, err := h.pdfService.IsEncrypted(in); err != nil {
+		h.logOperation(c, userID, "protect", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+	pageCount, _ := h.pdfService.GetPageCount(in)
+
+	result, err := h.pdfService.Encrypt(c.Request.Context(), in, opts)
+	if err != nil {
+		h.logOperation(c, userID, "protect", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrInvalidProtect) || errors.Is(err, services.ErrAlreadyEncrypted) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to protect PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "protect", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_protected.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	res := &models.ProtectResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		OwnerPassword:    opts.OwnerPassword != opts.UserPassword,
+		Restrictions:     opts.Restrictions,
+	}
+	h.recordResult(c, userID, "protect", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// UnlockPDF handles POST /api/pdf/unlock
+// Accepts file (or fileId) and the password it was protected with, user or
+// owner, and stores a copy without the password or restrictions.
+func (h *CorePDFHandler) UnlockPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "unlock", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if _, err := h.pdfService.IsEncrypted(in); err != nil {
+		h.logOperation(c, userID, "unlock", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, err := h.pdfService.Decrypt(c.Request.Context(), in, c.PostForm("password"))
+	if err != nil {
+		h.logOperation(c, userID, "unlock", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, services.ErrWrongPassword) || errors.Is(err, services.ErrNotEncrypted) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to unlock PDF: "+err.Error())
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "unlock", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_unlocked.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	res := &models.UnlockResult{SingleFileResult: singleFileResult(uploadResult, pageCount)}
+	h.recordResult(c, userID, "unlock", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
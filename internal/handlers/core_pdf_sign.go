@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCertificateSize bounds an uploaded PKCS#12 file
+const maxCertificateSize = 64 * 1024
+
+// SignPDF handles POST /api/pdf/sign
+// Accepts file (or fileId), certificate (a .p12/.pfx file) and its
+// password, and adds a digital signature. For a visible signature pass
+// signatureId from the signature library (signed in) or an image PNG, with
+// page (default: last), x/y and width in points. Optional reason, location
+// and contactInfo are shown by PDF readers. The certificate is used for
+// this request only and never stored.
+func (h *CorePDFHandler) SignPDF(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "sign", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	opts, err := h.signOptions(c, userID)
+	if err != nil {
+		respondSignError(c, err)
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "sign", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	result, sig, err := h.signatures.SignPDF(c.Request.Context(), in, opts)
+	if err != nil {
+		h.logOperation(c, userID, "sign", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		respondSignError(c, err)
+		return
+	}
+
+	outputFilename := h.outputName(c, userID, "sign", header.Filename, "", strings.TrimSuffix(header.Filename, ".pdf")+"_signed.pdf")
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, result, "application/pdf")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save file")
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	page := opts.Page
+	if page == 0 {
+		page = pageCount
+	}
+	res := &models.SignResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		Signature:        *sig,
+		Visible:          len(opts.Image) > 0,
+		Page:             page,
+	}
+	h.recordResult(c, userID, "sign", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
+
+// signOptions reads the certificate and placement of a sign request
+func (h *CorePDFHandler) signOptions(c *gin.Context, userID string) (services.DigitalSignOptions, error) {
+	opts := services.DigitalSignOptions{
+		Password:    c.PostForm("password"),
+		Reason:      c.PostForm("reason"),
+		Location:    c.PostForm("location"),
+		ContactInfo: c.PostForm("contactInfo"),
+	}
+
+	cert, _, err := c.Request.FormFile("certificate")
+	if err != nil {
+		return opts, fmt.Errorf("%w: certificate (a .p12 or .pfx file) is required", services.ErrInvalidSignOptions)
+	}
+	defer cert.Close()
+	opts.Certificate, err = io.ReadAll(io.LimitReader(cert, maxCertificateSize+1))
+	if err != nil || len(opts.Certificate) > maxCertificateSize {
+		return opts, fmt.Errorf("%w: certificate must be at most %d KB", services.ErrInvalidSignOptions, maxCertificateSize/1024)
+	}
+
+	for _, f := range []struct {
+		name  string
+		value *float64
+	}{{"x", &opts.X}, {"y", &opts.Y}, {"width", &opts.Width}} {
+		if v := c.PostForm(f.name); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("%w: %s must be a non-negative number", services.ErrInvalidSignOptions, f.name)
+			}
+			*f.value = n
+		}
+	}
+	if v := c.PostForm("page"); v != "" {
+		if opts.Page, err = strconv.Atoi(v); err != nil || opts.Page < 1 {
+			return opts, fmt.Errorf("%w: page must be a page number", services.ErrInvalidSignOptions)
+		}
+	}
+
+	if signatureID := c.PostForm("signatureId"); signatureID != "" {
+		if userID == "" {
+			return opts, fmt.Errorf("%w: sign in to use saved signatures", services.ErrInvalidSignOptions)
+		}
+		_, opts.Image, err = h.signatures.GetImage(c.Request.Context(), userID, signatureID)
+		return opts, err
+	}
+	if image, _, err := c.Request.FormFile("image"); err == nil {
+		defer image.Close()
+		data, err := io.ReadAll(io.LimitReader(image, services.MaxSignatureSize+1))
+		if err != nil {
+			return opts, fmt.Errorf("%w: failed to read image", services.ErrInvalidSignOptions)
+		}
+		if _, _, _, err := services.ValidateSignatureImage(data); err != nil {
+			return opts, err
+		}
+		opts.Image = data
+	}
+	return opts, nil
+}
+
+func respondSignError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSignatureNotFound):
+		utils.NotFound(c, "Signature not found")
+	case errors.Is(err, services.ErrInvalidSignOptions), errors.Is(err, services.ErrInvalidCertificate),
+		errors.Is(err, services.ErrInvalidSignature), errors.Is(err, services.ErrAlreadyEncrypted):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, "Failed to sign PDF: "+err.Error())
+	}
+}
+
+// VerifySignature handles POST /api/pdf/verify-signature
+// Accepts file (or fileId) and checks its digital signatures: who signed,
+// when, whether the signed bytes are intact and the certificate trusted,
+// and whether the document changed after the last signature.
+func (h *CorePDFHandler) VerifySignature(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "verify-signature", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		h.logOperation(c, userID, "verify-signature", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	signatures, err := h.signatures.VerifyPDFSignatures(c.Request.Context(), in)
+	if err != nil {
+		h.logOperation(c, userID, "verify-signature", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		utils.InternalServerError(c, "Failed to verify signatures: "+err.Error())
+		return
+	}
+
+	res := &models.VerifySignaturesResult{
+		Signed:     len(signatures) > 0,
+		Valid:      len(signatures) > 0,
+		Signatures: signatures,
+	}
+	for _, s := range signatures {
+		res.Valid = res.Valid && s.Valid
+	}
+	if n := len(signatures); n > 0 && !signatures[n-1].CoversWholeDocument {
+		res.Modified, res.Valid = true, false
+	}
+	if stored {
+		res.FileID = strings.TrimSpace(c.PostForm("fileId"))
+	}
+	pageCount, _ := h.pdfService.GetPageCount(in)
+	h.logOperation(c, userID, "verify-signature", []string{header.Filename}, "", "success", "", pageCount, startTime)
+
+	utils.Success(c, res)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/renderer"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Thumbnails handles POST /api/pdf/thumbnails
+// Accepts file (or fileId) and optional pages (e.g. 1-3,5; all by default,
+// at most 50), dpi (72 by default), format (png or jpeg) and quality for
+// JPEGs. Stores one image per page.
+func (h *CorePDFHandler) Thumbnails(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, stored, err := h.openInput(c, userID)
+	if err != nil {
+		h.respondInputError(c, userID, "thumbnails", stored, err, startTime)
+		return
+	}
+	defer file.Close()
+
+	opts := renderer.Options{Format: c.PostForm("format")}
+	if v := c.PostForm("dpi"); v != "" {
+		if opts.DPI, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "dpi must be an integer")
+			return
+		}
+	}
+	if v := c.PostForm("quality"); v != "" {
+		if opts.Quality, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "quality must be an integer")
+			return
+		}
+	}
+	if opts, err = renderer.NormalizeOptions(opts); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	in := inputReader(file, header)
+	pageCount, err := h.pdfService.GetPageCount(in)
+	if err != nil {
+		h.logOperation(c, userID, "thumbnails", []string{header.Filename}, "", "error", "Invalid PDF", 0, startTime)
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+	pages, err := selectPages(c.PostForm("pages"), pageCount)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	thumbs, err := h.thumbnails.Render(c.Request.Context(), in, pages, opts)
+	if err != nil {
+		h.logOperation(c, userID, "thumbnails", []string{header.Filename}, "", "error", err.Error(), 0, startTime)
+		if errors.Is(err, renderer.ErrInvalidOptions) || errors.Is(err, services.ErrUnreadablePDF) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to render pages: "+err.Error())
+		return
+	}
+
+	base := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	res := &models.ThumbnailsResult{Format: opts.Format, Pages: []models.PageThumbnail{}}
+	var outputs []models.OperationOutput
+	for _, t := range thumbs {
+		name := fmt.Sprintf("%s_page_%d%s", base, t.Page, opts.Ext())
+		upload, err := h.storageService.UploadProcessedMedia(c.Request.Context(), userID, name, opts.ContentType(), t.Data)
+		if err != nil {
+			if errors.Is(err, services.ErrStorageLimitExceeded) {
+				utils.Forbidden(c, "Storage limit exceeded. Please upgrade your plan")
+				return
+			}
+			utils.InternalServerError(c, "Failed to save thumbnail")
+			return
+		}
+		output := outputFromUpload(upload, 1)
+		output.Range = strconv.Itoa(t.Page)
+		outputs = append(outputs, output)
+		res.Pages = append(res.Pages, models.PageThumbnail{Page: t.Page, Width: t.Width, Height: t.Height, DPI: t.DPI})
+	}
+	res.SetOutputs(outputs...)
+	h.recordResult(c, userID, "thumbnails", []string{header.Filename}, res, pageCount, startTime)
+
+	utils.Success(c, res)
+}
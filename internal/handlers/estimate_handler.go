@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"strconv"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// EstimateHandler predicts how long operations will take
+type EstimateHandler struct {
+	estimateService *services.EstimateService
+}
+
+// NewEstimateHandler creates a new estimate handler
+func NewEstimateHandler(estimateService *services.EstimateService) *EstimateHandler {
+	return &EstimateHandler{estimateService: estimateService}
+}
+
+// GetEstimate handles GET /api/v1/estimate?operation=compress&size=...&pages=...
+// Returns the expected duration at the median and 90th percentile from
+// recent runs of the operation, plus the queue wait for queued operations.
+// size is in bytes; size and pages are optional and sharpen the estimate.
+func (h *EstimateHandler) GetEstimate(c *gin.Context) {
+	operation := c.Query("operation")
+	if operation == "" || len(operation) > 64 {
+		utils.BadRequest(c, "operation is required")
+		return
+	}
+	var size int64
+	if v := c.Query("size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			utils.BadRequest(c, "size must be a number of bytes")
+			return
+		}
+		size = n
+	}
+	var pages int
+	if v := c.Query("pages"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			utils.BadRequest(c, "pages must be a number")
+			return
+		}
+		pages = n
+	}
+
+	estimate, err := h.estimateService.Estimate(c.Request.Context(), operation, size, pages)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to estimate operation")
+		return
+	}
+	utils.Success(c, estimate)
+}
+
+// RegisterRoutes registers estimate routes
+func (h *EstimateHandler) RegisterRoutes(r *gin.RouterGroup, optionalAuthMiddleware gin.HandlerFunc) {
+	r.GET("/estimate", optionalAuthMiddleware, h.GetEstimate)
+}
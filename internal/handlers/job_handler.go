@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler reports background /api/pdf jobs
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+// NewJobHandler creates a job handler
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// Status handles GET /api/v1/jobs/:id
+// Reports a job's progress while it runs and, once it completes, the
+// operation's result and the fileIds of its outputs. Queued jobs also
+// report their queuePosition.
+func (h *JobHandler) Status(c *gin.Context) {
+	jobID := c.Param("id")
+	if jobID == "" {
+		utils.BadRequest(c, "Job ID required")
+		return
+	}
+
+	if h.jobService == nil {
+		utils.ServiceUnavailable(c, "Background jobs are not available")
+		return
+	}
+
+	job, err := h.jobService.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		utils.NotFound(c, "Job not found")
+		return
+	}
+	if userID, _ := middleware.GetUserID(c); job.UserID != "" && job.UserID != userID {
+		utils.NotFound(c, "Job not found")
+		return
+	}
+
+	response := gin.H{
+		"jobId":       job.ID,
+		"operation":   job.Operation,
+		"status":      job.Status,
+		"lane":        job.Lane,
+		"error":       job.Error,
+		"createdAt":   job.CreatedAt,
+		"completedAt": job.CompletedAt,
+	}
+	if job.Progress != nil {
+		response["progress"] = job.Progress
+	}
+	if job.Status == services.JobStatusQueued {
+		if position, err := h.jobService.QueuePosition(c.Request.Context(), job.ID); err == nil && position > 0 {
+			response["queuePosition"] = position
+		}
+	}
+	if job.Status == services.JobStatusCompleted {
+		response["result"] = job.Result
+		response["fileIds"] = job.FileIDs
+		if len(job.FileIDs) > 0 {
+			response["fileId"] = job.FileIDs[0]
+		}
+	}
+	utils.Success(c, response)
+}
+
+// RegisterRoutes registers job routes. Jobs submitted without signing in
+// are readable by anyone with their ID, like conversion jobs.
+func (h *JobHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	jobs := r.Group("/jobs")
+	jobs.Use(authMiddleware)
+	{
+		jobs.GET("/:id", h.Status)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Restore handles POST /library/:id/restore
+// Starts bringing an archived document back from cold storage. The owner
+// is notified once it can be opened again.
+func (h *LibraryHandler) Restore(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	if h.archive == nil {
+		utils.ServiceDisabled(c, "Archiving", "ARCHIVE_AFTER_MONTHS is not set")
+		return
+	}
+
+	doc, err := h.archive.Restore(c.Request.Context(), userID, c.Param("id"))
+	if errors.Is(err, services.ErrFileNotFound) {
+		utils.NotFound(c, "File not found")
+		return
+	}
+	if errors.Is(err, services.ErrNotArchived) {
+		utils.BadRequest(c, "File is not archived")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to restore file")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"id":      doc.ID.Hex(),
+		"archive": doc.Archive,
+	})
+}
+
+// respondArchived writes the response for a file that is in cold storage
+func respondArchived(c *gin.Context) {
+	utils.Error(c, http.StatusConflict, "FILE_ARCHIVED", "File is archived; restore it with POST /api/v1/library/:id/restore")
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"strconv"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Entities handles GET /library/entities
+// Lists the people, companies, invoice numbers and dates mentioned across
+// the user's library, those in the most documents first. Filter with type
+// and search; pass an entity's value to /library/list?entity= for its
+// documents.
+func (h *LibraryHandler) Entities(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	if h.entityIndex == nil {
+		utils.ServiceDisabled(c, "entity index", "ENTITY_INDEX_ENABLED is off")
+		return
+	}
+
+	query := services.EntityQuery{Type: c.Query("type"), Search: c.Query("search")}
+	if query.Type != "" && !services.ValidEntityType(query.Type) {
+		utils.BadRequest(c, "type must be person, company, invoice_number or date")
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			utils.BadRequest(c, "limit must be a positive number")
+			return
+		}
+		query.Limit = limit
+	}
+
+	entities, err := h.entityIndex.Entities(c.Request.Context(), userID, query)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list entities")
+		return
+	}
+	progress, err := h.entityIndex.Progress(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list entities")
+		return
+	}
+
+	utils.Success(c, models.LibraryEntityList{Entities: entities, Index: progress})
+}
+
+// entityFilter restricts query to the documents mentioning entity, of
+// entityType when given. It reports false after responding when the filter
+// can't be applied.
+func (h *LibraryHandler) entityFilter(c *gin.Context, userID, entity, entityType string, query *services.LibraryQuery) bool {
+	if entity == "" {
+		return true
+	}
+	if h.entityIndex == nil {
+		utils.ServiceDisabled(c, "entity index", "ENTITY_INDEX_ENABLED is off")
+		return false
+	}
+	if entityType != "" && !services.ValidEntityType(entityType) {
+		utils.BadRequest(c, "entityType must be person, company, invoice_number or date")
+		return false
+	}
+
+	ids, err := h.entityIndex.DocumentIDs(c.Request.Context(), userID, entity, entityType)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to search entities")
+		return false
+	}
+	query.OnlyIDs = ids
+	return true
+}
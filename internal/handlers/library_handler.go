@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,51 +9,49 @@ import (
 	"time"
 
 	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
 	"brainy-pdf/pkg/minio"
 	"brainy-pdf/pkg/mongodb"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// LibraryItem represents a user's stored PDF in the library
-type LibraryItem struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    string             `bson:"userId" json:"userId"`
-	FileName  string             `bson:"fileName" json:"fileName"`
-	FileKey   string             `bson:"fileKey" json:"fileKey"`
-	FileURL   string             `bson:"fileUrl" json:"fileUrl"`
-	Size      int64              `bson:"size" json:"size"`
-	PageCount int                `bson:"pageCount" json:"pageCount"`
-	MimeType  string             `bson:"mimeType" json:"mimeType"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
-}
-
 // LibraryHandler handles user library operations
 type LibraryHandler struct {
-	minioClient  *minio.Client
-	mongoClient  *mongodb.Client
-	pdfService   *services.PDFService
-	userService  *services.UserService
+	minioClient          *minio.Client
+	mongoClient          *mongodb.Client
+	pdfService           *services.PDFService
+	userService          *services.UserService
+	transcriptionService *services.TranscriptionService
+	capabilities         *services.CapabilityRegistry
+	storageService       *services.StorageService
+	entityIndex          *services.EntityIndexService // nil when disabled
+	folderRules          *services.FolderRuleService
+	savedSearches        *services.SavedSearchService
+	archive              *services.ArchiveService // nil when disabled
 }
 
 // NewLibraryHandler creates a new library handler
-func NewLibraryHandler(minioClient *minio.Client, mongoClient *mongodb.Client, pdfService *services.PDFService, userService *services.UserService) *LibraryHandler {
+func NewLibraryHandler(minioClient *minio.Client, mongoClient *mongodb.Client, pdfService *services.PDFService, userService *services.UserService, transcriptionService *services.TranscriptionService, capabilities *services.CapabilityRegistry, storageService *services.StorageService, entityIndex *services.EntityIndexService, folderRules *services.FolderRuleService, savedSearches *services.SavedSearchService, archive *services.ArchiveService) *LibraryHandler {
 	return &LibraryHandler{
-		minioClient: minioClient,
-		mongoClient: mongoClient,
-		pdfService:  pdfService,
-		userService: userService,
+		minioClient:          minioClient,
+		mongoClient:          mongoClient,
+		pdfService:           pdfService,
+		userService:          userService,
+		transcriptionService: transcriptionService,
+		capabilities:         capabilities,
+		storageService:       storageService,
+		entityIndex:          entityIndex,
+		folderRules:          folderRules,
+		savedSearches:        savedSearches,
+		archive:              archive,
 	}
 }
 
 // Upload handles POST /library/upload
-// Uploads a PDF to user's library
+// Uploads a PDF to user's library, or every PDF in a ZIP
 func (h *LibraryHandler) Upload(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists || userID == "" {
@@ -69,9 +67,14 @@ func (h *LibraryHandler) Upload(c *gin.Context) {
 	}
 	defer file.Close()
 
+	if services.IsZipFilename(header.Filename) {
+		h.uploadZip(c, userID, file, header)
+		return
+	}
+
 	// Validate file type
 	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		utils.BadRequest(c, "Only PDF files are allowed")
+		utils.BadRequest(c, "Only PDF or ZIP files are allowed")
 		return
 	}
 
@@ -92,21 +95,16 @@ func (h *LibraryHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF
-	if err := h.pdfService.ValidatePDF(data); err != nil {
+	if err := h.pdfService.ValidatePDF(in); err != nil {
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get page count
-	pageCount, err := h.pdfService.GetPageCount(data)
+	pageCount, err := h.pdfService.GetPageCount(in)
 	if err != nil {
 		fmt.Printf("Warning: Failed to get page count for %s: %v\n", header.Filename, err)
         // Keep pageCount as 0 or set to 1 as fallback? 
@@ -114,63 +112,34 @@ func (h *LibraryHandler) Upload(c *gin.Context) {
         // Let's keep 0 but log it.
 	}
 
-	// Generate unique file key
-	fileID := primitive.NewObjectID()
-	fileKey := fmt.Sprintf("library/%s/%s_%s", userID, fileID.Hex(), header.Filename)
-
-	// Upload to MinIO
-	_, err = h.minioClient.UploadBytes(c.Request.Context(), h.minioClient.GetBucketUserFiles(), fileKey, data, "application/pdf")
+	contentHash, err := services.ContentHashReader(io.NewSectionReader(in, 0, in.Size()))
 	if err != nil {
-		utils.InternalServerError(c, "Failed to upload file: "+err.Error())
+		utils.BadRequest(c, "Failed to read file")
 		return
 	}
 
-	// Get file URL
-	fileURL, err := h.minioClient.GetPresignedURL(c.Request.Context(), h.minioClient.GetBucketUserFiles(), fileKey, 7*24*time.Hour)
+	doc, err := h.storageService.AddToLibrary(c.Request.Context(), userID, header.Filename, in, pageCount, contentHash)
 	if err != nil {
-		fileURL = "" // Non-critical, can regenerate later
-	}
-
-	// Save metadata to MongoDB
-	item := LibraryItem{
-		ID:        fileID,
-		UserID:    userID,
-		FileName:  header.Filename,
-		FileKey:   fileKey,
-		FileURL:   fileURL,
-		Size:      header.Size,
-		PageCount: pageCount,
-		MimeType:  "application/pdf",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	_, err = h.mongoClient.Collection("library").InsertOne(c.Request.Context(), item)
-	if err != nil {
-		// Rollback MinIO upload
-		h.minioClient.DeleteFile(context.Background(), h.minioClient.GetBucketUserFiles(), fileKey)
-		utils.InternalServerError(c, "Failed to save file metadata")
+		utils.InternalServerError(c, "Failed to upload file: "+err.Error())
 		return
 	}
 
-	// Update user storage usage
-	if err := h.userService.UpdateStorageUsed(context.Background(), userID, header.Size); err != nil {
-		// Log error but don't fail the request (storage usage might be slightly off but file is saved)
-		fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
-	}
-
+	fileURL, _ := h.storageService.DocumentURL(c.Request.Context(), doc, 7*24*time.Hour)
 	utils.Success(c, gin.H{
-		"id":        item.ID.Hex(),
-		"fileName":  item.FileName,
-		"fileUrl":   item.FileURL,
-		"size":      item.Size,
-		"pageCount": item.PageCount,
-		"createdAt": item.CreatedAt,
+		"id":        doc.ID.Hex(),
+		"fileName":  doc.OriginalName,
+		"fileUrl":   fileURL,
+		"size":      doc.Size,
+		"pageCount": doc.Metadata.PageCount,
+		"createdAt": doc.CreatedAt,
 	})
 }
 
 // List handles GET /library/list
-// Returns all PDFs for the authenticated user
+// Returns all PDFs for the authenticated user, or those matching search
+// (file names and note transcripts), tags (comma separated, all required)
+// and entity (optionally entityType): a person, company, invoice number or
+// date they mention
 func (h *LibraryHandler) List(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists || userID == "" {
@@ -181,68 +150,77 @@ func (h *LibraryHandler) List(c *gin.Context) {
 	// Query parameters
 	sortBy := c.DefaultQuery("sortBy", "createdAt")
 	sortOrder := c.DefaultQuery("sortOrder", "desc")
-	search := c.Query("search")
-
-	// Build filter
-	filter := bson.M{"userId": userID}
-	if search != "" {
-		filter["fileName"] = bson.M{"$regex": search, "$options": "i"}
-	}
-
-	// Build sort
-	sortDirection := -1
-	if sortOrder == "asc" {
-		sortDirection = 1
+	search := models.LibrarySearch{
+		Text:       c.Query("search"),
+		Entity:     c.Query("entity"),
+		EntityType: c.Query("entityType"),
 	}
-	sortField := "createdAt"
-	switch sortBy {
-	case "name":
-		sortField = "fileName"
-	case "size":
-		sortField = "size"
-	case "pages":
-		sortField = "pageCount"
+	if tags := c.Query("tags"); tags != "" {
+		search.Tags = strings.Split(tags, ",")
 	}
 
-	opts := options.Find().SetSort(bson.D{{Key: sortField, Value: sortDirection}})
+	query := services.LibraryQuery{SortField: sortBy, Ascending: sortOrder == "asc"}
+	if !h.searchQuery(c, userID, search, &query) {
+		return
+	}
 
-	cursor, err := h.mongoClient.Collection("library").Find(c.Request.Context(), filter, opts)
+	docs, err := h.storageService.ListLibrary(c.Request.Context(), userID, query)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to fetch library")
 		return
 	}
-	defer cursor.Close(c.Request.Context())
 
-	var items []LibraryItem
-	if err := cursor.All(c.Request.Context(), &items); err != nil {
-		utils.InternalServerError(c, "Failed to decode library items")
-		return
-	}
+	utils.Success(c, h.libraryItems(c, docs))
+}
 
-	// Refresh URLs if expired (optional, can regenerate on-demand)
-	for i, item := range items {
-		if item.FileURL == "" || time.Since(item.UpdatedAt) > 6*24*time.Hour {
-			newURL, err := h.minioClient.GetPresignedURL(c.Request.Context(), h.minioClient.GetBucketUserFiles(), item.FileKey, 7*24*time.Hour)
-			if err == nil {
-				items[i].FileURL = newURL
-			}
+// searchQuery applies a library search to query. It reports false after
+// responding when the search can't be applied.
+func (h *LibraryHandler) searchQuery(c *gin.Context, userID string, search models.LibrarySearch, query *services.LibraryQuery) bool {
+	if search.Text != "" {
+		// Match file names and the transcripts of the documents' notes
+		noted, err := h.notedDocumentIDs(c.Request.Context(), userID, search.Text)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to search notes")
+			return false
 		}
+		query.Search, query.AlsoIDs = search.Text, noted
 	}
-
-	// Build response
-	response := make([]gin.H, len(items))
-	for i, item := range items {
-		response[i] = gin.H{
-			"id":        item.ID.Hex(),
-			"fileName":  item.FileName,
-			"fileUrl":   item.FileURL,
-			"size":      item.Size,
-			"pageCount": item.PageCount,
-			"createdAt": item.CreatedAt,
+	if len(search.Tags) > 0 {
+		tags, err := normalizeTags(search.Tags)
+		if err != nil {
+			utils.BadRequest(c, err.Error())
+			return false
 		}
+		query.Tags = tags
 	}
+	return h.entityFilter(c, userID, search.Entity, search.EntityType, query)
+}
 
-	utils.Success(c, response)
+// libraryItems builds the list response for library documents
+func (h *LibraryHandler) libraryItems(c *gin.Context, docs []models.Document) []gin.H {
+	items := make([]gin.H, len(docs))
+	for i := range docs {
+		fileURL, _ := h.storageService.DocumentURL(c.Request.Context(), &docs[i], 7*24*time.Hour)
+		items[i] = gin.H{
+			"id":        docs[i].ID.Hex(),
+			"fileName":  docs[i].OriginalName,
+			"fileUrl":   fileURL,
+			"size":      docs[i].Size,
+			"pageCount": docs[i].Metadata.PageCount,
+			"tags":      docs[i].Metadata.Tags,
+			"createdAt": docs[i].CreatedAt,
+		}
+		if !docs[i].FolderID.IsZero() {
+			items[i]["folderId"] = docs[i].FolderID.Hex()
+		}
+		if docs[i].AutoFiled != nil {
+			items[i]["autoFiled"] = docs[i].AutoFiled
+		}
+		if docs[i].Archive != nil {
+			items[i]["archive"] = docs[i].Archive
+		}
+	}
+	return items
 }
 
 // Download handles GET /library/download/:id
@@ -254,47 +232,29 @@ func (h *LibraryHandler) Download(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(fileID)
-	if err != nil {
-		utils.BadRequest(c, "Invalid file ID")
+	doc, ok := h.findItem(c, userID)
+	if !ok {
 		return
 	}
 
-	// Find file in MongoDB
-	var item LibraryItem
-	err = h.mongoClient.Collection("library").FindOne(
-		c.Request.Context(),
-		bson.M{"_id": objectID, "userId": userID},
-	).Decode(&item)
-	if err != nil {
-		utils.NotFound(c, "File not found")
+	data, err := h.storageService.ReadDocument(c.Request.Context(), doc)
+	if errors.Is(err, services.ErrFileArchived) {
+		respondArchived(c)
 		return
 	}
-
-	// Stream file from MinIO (Cloudflare R2)
-	fmt.Printf("[DEBUG] Library Download: UserID='%s', FileID='%s', FileKey='%s', Bucket='%s'\n", userID, fileID, item.FileKey, h.minioClient.GetBucketUserFiles())
-	
-	// First check if file exists in R2
-	_, statErr := h.minioClient.GetFileInfo(c.Request.Context(), h.minioClient.GetBucketUserFiles(), item.FileKey)
-	if statErr != nil {
-		fmt.Printf("[ERROR] Library file not found in R2: FileKey='%s', Error='%v'\n", item.FileKey, statErr)
+	if err != nil {
+		fmt.Printf("[ERROR] Library Download failed: FileID='%s', Error='%v'\n", doc.ID.Hex(), err)
 		// File doesn't exist in storage - return 404, not 500
 		utils.NotFound(c, "File not found in storage. It may have been deleted.")
 		return
 	}
-	
-	data, err := h.minioClient.DownloadFile(c.Request.Context(), h.minioClient.GetBucketUserFiles(), item.FileKey)
-	if err != nil {
-		fmt.Printf("[ERROR] Library Download failed: FileKey='%s', Error='%v'\n", item.FileKey, err)
-		utils.InternalServerError(c, "Failed to download file")
-		return
-	}
+
+	h.storageService.MarkAccessed(c.Request.Context(), doc)
 
 	// Set headers for download
-	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, item.FileName))
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", doc.OriginalName))
 	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Length", fmt.Sprintf("%d", item.Size))
+	c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
 
 	// Send response
 	c.Data(http.StatusOK, "application/pdf", data)
@@ -309,52 +269,28 @@ func (h *LibraryHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(fileID)
-	if err != nil {
-		utils.BadRequest(c, "Invalid file ID")
+	doc, ok := h.findItem(c, userID)
+	if !ok {
 		return
 	}
 
-	// Find file in MongoDB
-	var item LibraryItem
-	err = h.mongoClient.Collection("library").FindOne(
-		c.Request.Context(),
-		bson.M{"_id": objectID, "userId": userID},
-	).Decode(&item)
-	if err != nil {
-		utils.NotFound(c, "File not found")
+	err := h.storageService.DeleteFile(c.Request.Context(), doc.ID.Hex(), userID)
+	if errors.Is(err, services.ErrLegalHold) {
+		utils.Error(c, http.StatusLocked, "LEGAL_HOLD", "File is under legal hold and can't be deleted")
 		return
 	}
-
-	// Delete from MinIO
-	err = h.minioClient.DeleteFile(c.Request.Context(), h.minioClient.GetBucketUserFiles(), item.FileKey)
-	if err != nil {
-		// Log but continue - file might already be deleted
-		fmt.Printf("Warning: Failed to delete file from MinIO: %v\n", err)
-	}
-
-	// Delete from MongoDB
-	_, err = h.mongoClient.Collection("library").DeleteOne(
-		c.Request.Context(),
-		bson.M{"_id": objectID, "userId": userID},
-	)
 	if err != nil {
-		utils.InternalServerError(c, "Failed to delete file metadata")
+		utils.InternalServerError(c, "Failed to delete file")
 		return
 	}
-
-	// Update user storage usage (decrement)
-	if err := h.userService.UpdateStorageUsed(context.Background(), userID, -item.Size); err != nil {
-		fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
-	}
+	h.deleteDocumentNotes(c.Request.Context(), userID, doc.ID.Hex())
 
 	utils.Success(c, gin.H{
 		"success": true,
 		"message": "File deleted successfully",
 		"data": gin.H{
-			"id":       fileID,
-			"fileName": item.FileName,
+			"id":       doc.ID.Hex(),
+			"fileName": doc.OriginalName,
 		},
 	})
 }
@@ -368,37 +304,33 @@ func (h *LibraryHandler) GetPresignedURL(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(fileID)
-	if err != nil {
-		utils.BadRequest(c, "Invalid file ID")
+	doc, ok := h.findItem(c, userID)
+	if !ok {
 		return
 	}
 
-	// Find file in MongoDB
-	var item LibraryItem
-	err = h.mongoClient.Collection("library").FindOne(
-		c.Request.Context(),
-		bson.M{"_id": objectID, "userId": userID},
-	).Decode(&item)
-	if err != nil {
-		utils.NotFound(c, "File not found")
+	// Generate fresh URL
+	url, err := h.storageService.DocumentURL(c.Request.Context(), doc, 1*time.Hour)
+	if errors.Is(err, services.ErrFileArchived) {
+		respondArchived(c)
+		return
+	}
+	if errors.Is(err, minio.ErrEncryptedObject) {
+		utils.Error(c, http.StatusConflict, "ENCRYPTED_STORAGE", "Files are encrypted at rest; download them from /api/v1/library/download/"+doc.ID.Hex())
 		return
 	}
-
-	// Generate fresh URL
-	url, err := h.minioClient.GetPresignedURL(c.Request.Context(), h.minioClient.GetBucketUserFiles(), item.FileKey, 1*time.Hour)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to generate URL")
 		return
 	}
+	h.storageService.MarkAccessed(c.Request.Context(), doc)
 
 	utils.Success(c, gin.H{
 		"success": true,
 		"data": gin.H{
-			"id":       fileID,
-			"fileName": item.FileName,
-			"url":      url,
+			"id":        doc.ID.Hex(),
+			"fileName":  doc.OriginalName,
+			"url":       url,
 			"expiresIn": "1 hour",
 		},
 	})
@@ -411,8 +343,24 @@ func (h *LibraryHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.H
 	{
 		library.POST("/upload", h.Upload)
 		library.GET("/list", h.List)
+		library.GET("/entities", h.Entities)
+		library.GET("/rules", h.ListRules)
+		library.POST("/rules", h.CreateRule)
+		library.POST("/rules/test", h.TestRules)
+		library.PUT("/rules/:ruleId", h.UpdateRule)
+		library.DELETE("/rules/:ruleId", h.DeleteRule)
+		library.GET("/searches", h.ListSearches)
+		library.POST("/searches", h.CreateSearch)
+		library.PUT("/searches/:searchId", h.UpdateSearch)
+		library.DELETE("/searches/:searchId", h.DeleteSearch)
+		library.GET("/searches/:searchId/results", h.SearchResults)
 		library.GET("/download/:id", h.Download)
 		library.GET("/url/:id", h.GetPresignedURL)
 		library.DELETE("/:id", h.Delete)
+		library.POST("/:id/restore", h.Restore)
+		library.PUT("/:id/tags", h.SetTags)
+		library.GET("/:id/notes", h.ListNotes)
+		library.DELETE("/:id/notes/:noteId", h.DeleteNote)
+		library.POST("/:id/notes/audio", middleware.RequireCapability(h.capabilities, services.CapabilityTranscription), h.AddAudioNote)
 	}
 }
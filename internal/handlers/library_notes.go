@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// libraryNotesCollection holds the notes attached to library documents
+const libraryNotesCollection = "library_notes"
+
+// AddAudioNote handles POST /library/:id/notes/audio
+// Transcribes a voice recording and attaches it to a library document as a
+// searchable note
+func (h *LibraryHandler) AddAudioNote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	item, ok := h.findItem(c, userID)
+	if !ok {
+		return
+	}
+
+	file, header, err := c.Request.FormFile("audio")
+	if err != nil {
+		utils.BadRequest(c, "No audio file provided")
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	contentType, supported := services.AudioContentTypes[ext]
+	if !supported {
+		utils.BadRequest(c, "Unsupported audio format. Use mp3, m4a, mp4, wav, webm, ogg or flac")
+		return
+	}
+	if header.Size == 0 {
+		utils.BadRequest(c, "Audio file is empty")
+		return
+	}
+	if header.Size > services.MaxAudioSize {
+		utils.BadRequest(c, "Audio file must be less than 25MB")
+		return
+	}
+
+	page := 0
+	if p := c.PostForm("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 0 {
+			utils.BadRequest(c, "page must be a page number")
+			return
+		}
+		if pages := item.Metadata.PageCount; pages > 0 && page > pages {
+			utils.BadRequest(c, fmt.Sprintf("page must be between 1 and %d", pages))
+			return
+		}
+	}
+
+	ok, err = h.userService.CheckStorageLimit(c.Request.Context(), userID, header.Size)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to check storage limit")
+		return
+	}
+	if !ok {
+		utils.BadRequest(c, "Storage limit exceeded. Please upgrade your plan.")
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		utils.BadRequest(c, "Failed to read audio file")
+		return
+	}
+
+	transcript, err := h.transcriptionService.Transcribe(c.Request.Context(), data, header.Filename)
+	if err != nil {
+		errMsg := err.Error()
+		switch {
+		case strings.Contains(errMsg, "rate limit"):
+			utils.TooManyRequests(c, "Transcription rate limit exceeded. Please try again in a few moments.")
+		case strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline"):
+			utils.GatewayTimeout(c, "Transcription timed out. Please try a shorter recording.")
+		default:
+			utils.InternalServerError(c, "Transcription failed: "+errMsg)
+		}
+		return
+	}
+	if transcript.Text == "" {
+		utils.BadRequest(c, "No speech was recognized in the recording")
+		return
+	}
+
+	noteID := primitive.NewObjectID().Hex()
+	audioKey := fmt.Sprintf("library/%s/notes/%s%s", userID, noteID, ext)
+	bucket := h.minioClient.GetBucketUserFiles()
+	if _, err := h.minioClient.UploadBytes(c.Request.Context(), bucket, audioKey, data, contentType); err != nil {
+		utils.InternalServerError(c, "Failed to store audio: "+err.Error())
+		return
+	}
+
+	note := models.LibraryNote{
+		ID:         noteID,
+		DocumentID: item.ID.Hex(),
+		UserID:     userID,
+		Source:     models.NoteSourceVoice,
+		Text:       transcript.Text,
+		Page:       page,
+		Language:   transcript.Language,
+		AudioName:  header.Filename,
+		AudioKey:   audioKey,
+		AudioSize:  header.Size,
+		Duration:   transcript.Duration,
+		CreatedAt:  time.Now(),
+	}
+	if _, err := h.mongoClient.Collection(libraryNotesCollection).InsertOne(c.Request.Context(), note); err != nil {
+		h.minioClient.DeleteFile(context.Background(), bucket, audioKey)
+		utils.InternalServerError(c, "Failed to save note")
+		return
+	}
+
+	if err := h.userService.UpdateStorageUsed(context.Background(), userID, header.Size); err != nil {
+		fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
+	}
+
+	note.AudioURL, _ = h.minioClient.GetPresignedURL(c.Request.Context(), bucket, audioKey, 1*time.Hour)
+	utils.Success(c, note)
+}
+
+// ListNotes handles GET /library/:id/notes
+// Returns a document's notes, oldest first, with playable audio URLs
+func (h *LibraryHandler) ListNotes(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	item, ok := h.findItem(c, userID)
+	if !ok {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := h.mongoClient.Collection(libraryNotesCollection).Find(
+		c.Request.Context(),
+		bson.M{"documentId": item.ID.Hex(), "userId": userID},
+		opts,
+	)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch notes")
+		return
+	}
+	defer cursor.Close(c.Request.Context())
+
+	notes := []models.LibraryNote{}
+	if err := cursor.All(c.Request.Context(), &notes); err != nil {
+		utils.InternalServerError(c, "Failed to decode notes")
+		return
+	}
+
+	for i, note := range notes {
+		if note.AudioKey != "" {
+			notes[i].AudioURL, _ = h.minioClient.GetPresignedURL(c.Request.Context(), h.minioClient.GetBucketUserFiles(), note.AudioKey, 1*time.Hour)
+		}
+	}
+
+	utils.Success(c, notes)
+}
+
+// DeleteNote handles DELETE /library/:id/notes/:noteId
+func (h *LibraryHandler) DeleteNote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	item, ok := h.findItem(c, userID)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"_id": c.Param("noteId"), "documentId": item.ID.Hex(), "userId": userID}
+	var note models.LibraryNote
+	if err := h.mongoClient.Collection(libraryNotesCollection).FindOne(c.Request.Context(), filter).Decode(&note); err != nil {
+		utils.NotFound(c, "Note not found")
+		return
+	}
+
+	if _, err := h.mongoClient.Collection(libraryNotesCollection).DeleteOne(c.Request.Context(), filter); err != nil {
+		utils.InternalServerError(c, "Failed to delete note")
+		return
+	}
+	h.deleteNoteAudio(userID, []models.LibraryNote{note})
+
+	utils.Success(c, gin.H{
+		"id":         note.ID,
+		"documentId": note.DocumentID,
+	})
+}
+
+// deleteDocumentNotes removes every note of a deleted document
+func (h *LibraryHandler) deleteDocumentNotes(ctx context.Context, userID, documentID string) {
+	filter := bson.M{"documentId": documentID, "userId": userID}
+	cursor, err := h.mongoClient.Collection(libraryNotesCollection).Find(ctx, filter)
+	if err != nil {
+		fmt.Printf("Warning: Failed to find notes of %s: %v\n", documentID, err)
+		return
+	}
+	var notes []models.LibraryNote
+	if err := cursor.All(ctx, &notes); err != nil {
+		fmt.Printf("Warning: Failed to decode notes of %s: %v\n", documentID, err)
+		return
+	}
+	if len(notes) == 0 {
+		return
+	}
+
+	if _, err := h.mongoClient.Collection(libraryNotesCollection).DeleteMany(ctx, filter); err != nil {
+		fmt.Printf("Warning: Failed to delete notes of %s: %v\n", documentID, err)
+		return
+	}
+	h.deleteNoteAudio(userID, notes)
+}
+
+// deleteNoteAudio removes the recordings of deleted notes and gives back
+// their storage
+func (h *LibraryHandler) deleteNoteAudio(userID string, notes []models.LibraryNote) {
+	var freed int64
+	for _, note := range notes {
+		if note.AudioKey == "" {
+			continue
+		}
+		if err := h.minioClient.DeleteFile(context.Background(), h.minioClient.GetBucketUserFiles(), note.AudioKey); err != nil {
+			fmt.Printf("Warning: Failed to delete note audio from MinIO: %v\n", err)
+		}
+		freed += note.AudioSize
+	}
+	if freed == 0 {
+		return
+	}
+	if err := h.userService.UpdateStorageUsed(context.Background(), userID, -freed); err != nil {
+		fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
+	}
+}
+
+// notedDocumentIDs returns the documents with a note matching search
+func (h *LibraryHandler) notedDocumentIDs(ctx context.Context, userID, search string) ([]primitive.ObjectID, error) {
+	docIDs, err := h.mongoClient.Collection(libraryNotesCollection).Distinct(ctx, "documentId", bson.M{
+		"userId": userID,
+		"text":   bson.M{"$regex": regexp.QuoteMeta(search), "$options": "i"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(docIDs))
+	for _, v := range docIDs {
+		if s, ok := v.(string); ok {
+			if id, err := primitive.ObjectIDFromHex(s); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// findItem loads the library document named by the :id parameter, writing
+// the error response when it is not the user's
+func (h *LibraryHandler) findItem(c *gin.Context, userID string) (*models.Document, bool) {
+	if _, err := primitive.ObjectIDFromHex(c.Param("id")); err != nil {
+		utils.BadRequest(c, "Invalid file ID")
+		return nil, false
+	}
+
+	doc, err := h.storageService.LibraryDocument(c.Request.Context(), c.Param("id"), userID)
+	if errors.Is(err, services.ErrFileNotFound) {
+		utils.NotFound(c, "File not found")
+		return nil, false
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load file")
+		return nil, false
+	}
+	return doc, true
+}
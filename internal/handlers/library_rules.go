@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FolderRuleRequest creates or replaces a folder rule. Enabled defaults to
+// true; Position 0 keeps the rule's place, or adds it last.
+type FolderRuleRequest struct {
+	Name       string                 `json:"name"`
+	Enabled    *bool                  `json:"enabled"`
+	Position   int                    `json:"position"`
+	Match      string                 `json:"match"`
+	Conditions []models.RuleCondition `json:"conditions"`
+	Actions    models.RuleActions     `json:"actions"`
+}
+
+// TestRulesRequest asks which rules a file would match. Facts come from
+// documentId when given, overridden by filename, documentType and
+// mimeType; rule tests an unsaved rule instead of the saved ones.
+type TestRulesRequest struct {
+	DocumentID   string             `json:"documentId"`
+	Filename     string             `json:"filename"`
+	DocumentType string             `json:"documentType"`
+	MimeType     string             `json:"mimeType"`
+	Rule         *FolderRuleRequest `json:"rule"`
+}
+
+// ListRules handles GET /library/rules
+// Returns the user's folder rules in the order they run
+func (h *LibraryHandler) ListRules(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	rules, err := h.folderRules.List(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list rules")
+		return
+	}
+	utils.Success(c, rules)
+}
+
+// CreateRule handles POST /library/rules
+// Adds a rule filing matching uploads into a folder and tagging them
+func (h *LibraryHandler) CreateRule(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	rule, ok := bindFolderRule(c)
+	if !ok {
+		return
+	}
+
+	created, err := h.folderRules.Create(c.Request.Context(), userID, rule)
+	if errors.Is(err, services.ErrInvalidRule) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save rule")
+		return
+	}
+	utils.Success(c, created)
+}
+
+// UpdateRule handles PUT /library/rules/:ruleId
+// Replaces a rule
+func (h *LibraryHandler) UpdateRule(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	rule, ok := bindFolderRule(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.folderRules.Update(c.Request.Context(), userID, c.Param("ruleId"), rule)
+	switch {
+	case errors.Is(err, services.ErrInvalidRule):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrRuleNotFound):
+		utils.NotFound(c, "Rule not found")
+	case err != nil:
+		utils.InternalServerError(c, "Failed to update rule")
+	default:
+		utils.Success(c, updated)
+	}
+}
+
+// DeleteRule handles DELETE /library/rules/:ruleId
+func (h *LibraryHandler) DeleteRule(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	err := h.folderRules.Delete(c.Request.Context(), userID, c.Param("ruleId"))
+	if errors.Is(err, services.ErrRuleNotFound) {
+		utils.NotFound(c, "Rule not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to delete rule")
+		return
+	}
+	utils.Success(c, gin.H{"id": c.Param("ruleId")})
+}
+
+// TestRules handles POST /library/rules/test
+// Reports which rules a file would match, and the folder and tags it would
+// get, without changing anything
+func (h *LibraryHandler) TestRules(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	var req TestRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	if req.DocumentID == "" && req.Filename == "" {
+		utils.BadRequest(c, "documentId or filename is required")
+		return
+	}
+
+	var rules []models.FolderRule
+	if req.Rule != nil {
+		rule, err := folderRuleFrom(*req.Rule)
+		if err == nil {
+			err = services.ValidateFolderRule(&rule)
+		}
+		if err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		rule.Enabled = true
+		rules = []models.FolderRule{rule}
+	} else {
+		var err error
+		if rules, err = h.folderRules.List(c.Request.Context(), userID); err != nil {
+			utils.InternalServerError(c, "Failed to list rules")
+			return
+		}
+	}
+
+	var facts models.RuleFacts
+	if req.DocumentID != "" {
+		doc, err := h.storageService.LibraryDocument(c.Request.Context(), req.DocumentID, userID)
+		if errors.Is(err, services.ErrFileNotFound) {
+			utils.NotFound(c, "File not found")
+			return
+		}
+		if err != nil {
+			utils.InternalServerError(c, "Failed to find file")
+			return
+		}
+		if facts, _, err = h.folderRules.Facts(c.Request.Context(), doc, rules, nil); err != nil {
+			utils.InternalServerError(c, "Failed to read file: "+err.Error())
+			return
+		}
+	}
+	if req.Filename != "" {
+		facts.Filename = req.Filename
+	}
+	if req.DocumentType != "" {
+		facts.DocumentType = req.DocumentType
+	}
+	if req.MimeType != "" {
+		facts.MimeType = req.MimeType
+	}
+
+	utils.Success(c, services.EvaluateRules(rules, facts))
+}
+
+// bindFolderRule reads a FolderRuleRequest body. It reports false after
+// responding when the body is invalid.
+func bindFolderRule(c *gin.Context) (models.FolderRule, bool) {
+	var req FolderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return models.FolderRule{}, false
+	}
+	rule, err := folderRuleFrom(req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return models.FolderRule{}, false
+	}
+	return rule, true
+}
+
+func folderRuleFrom(req FolderRuleRequest) (models.FolderRule, error) {
+	tags, err := normalizeTags(req.Actions.Tags)
+	if err != nil {
+		return models.FolderRule{}, err
+	}
+	rule := models.FolderRule{
+		Name:       req.Name,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		Position:   req.Position,
+		Match:      req.Match,
+		Conditions: req.Conditions,
+		Actions:    models.RuleActions{Folder: req.Actions.Folder, Tags: tags},
+	}
+	return rule, nil
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchRequest creates or replaces a saved search
+type SavedSearchRequest struct {
+	Name  string               `json:"name"`
+	Query models.LibrarySearch `json:"query"`
+	Alert bool                 `json:"alert"`
+}
+
+// ListSearches handles GET /library/searches
+// Returns the user's saved searches
+func (h *LibraryHandler) ListSearches(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	searches, err := h.savedSearches.List(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list saved searches")
+		return
+	}
+	utils.Success(c, searches)
+}
+
+// CreateSearch handles POST /library/searches
+// Saves a search; with alert set the user is notified of new documents
+// matching it
+func (h *LibraryHandler) CreateSearch(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	search, ok := h.bindSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	created, err := h.savedSearches.Create(c.Request.Context(), userID, search)
+	if errors.Is(err, services.ErrInvalidSavedSearch) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save search")
+		return
+	}
+	utils.Success(c, created)
+}
+
+// UpdateSearch handles PUT /library/searches/:searchId
+// Replaces a saved search's name, query and alert setting
+func (h *LibraryHandler) UpdateSearch(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+	search, ok := h.bindSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.savedSearches.Update(c.Request.Context(), userID, c.Param("searchId"), search)
+	switch {
+	case errors.Is(err, services.ErrInvalidSavedSearch):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrSavedSearchNotFound):
+		utils.NotFound(c, "Saved search not found")
+	case err != nil:
+		utils.InternalServerError(c, "Failed to update saved search")
+	default:
+		utils.Success(c, updated)
+	}
+}
+
+// DeleteSearch handles DELETE /library/searches/:searchId
+func (h *LibraryHandler) DeleteSearch(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	err := h.savedSearches.Delete(c.Request.Context(), userID, c.Param("searchId"))
+	if errors.Is(err, services.ErrSavedSearchNotFound) {
+		utils.NotFound(c, "Saved search not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to delete saved search")
+		return
+	}
+	utils.Success(c, gin.H{"id": c.Param("searchId")})
+}
+
+// SearchResults handles GET /library/searches/:searchId/results
+// Runs a saved search, returning documents as /library/list does
+func (h *LibraryHandler) SearchResults(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	search, err := h.savedSearches.Get(c.Request.Context(), userID, c.Param("searchId"))
+	if errors.Is(err, services.ErrSavedSearchNotFound) {
+		utils.NotFound(c, "Saved search not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to find saved search")
+		return
+	}
+
+	query := services.LibraryQuery{SortField: c.DefaultQuery("sortBy", "createdAt"), Ascending: c.Query("sortOrder") == "asc"}
+	if !h.searchQuery(c, userID, search.Query, &query) {
+		return
+	}
+	docs, err := h.storageService.ListLibrary(c.Request.Context(), userID, query)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch library")
+		return
+	}
+
+	utils.Success(c, h.libraryItems(c, docs))
+}
+
+// bindSavedSearch reads a SavedSearchRequest body. It reports false after
+// responding when the body is invalid or searches an entity while the
+// entity index is off.
+func (h *LibraryHandler) bindSavedSearch(c *gin.Context) (models.SavedSearch, bool) {
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return models.SavedSearch{}, false
+	}
+	if len(req.Query.Tags) > 0 {
+		tags, err := normalizeTags(req.Query.Tags)
+		if err != nil {
+			utils.BadRequest(c, err.Error())
+			return models.SavedSearch{}, false
+		}
+		req.Query.Tags = tags
+	}
+	if req.Query.Entity != "" && h.entityIndex == nil {
+		utils.ServiceDisabled(c, "entity index", "ENTITY_INDEX_ENABLED is off")
+		return models.SavedSearch{}, false
+	}
+	return models.SavedSearch{Name: req.Name, Query: req.Query, Alert: req.Alert}, true
+}
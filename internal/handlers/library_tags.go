@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxLibraryTags   = 20
+	maxLibraryTagLen = 40
+)
+
+// SetTagsRequest replaces a library document's tags
+type SetTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// SetTags handles PUT /library/:id/tags
+// Replaces a document's tags. Tagging a document "confidential" keeps it out
+// of the AI endpoints when the user's organization requires it.
+func (h *LibraryHandler) SetTags(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists || userID == "" {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	item, ok := h.findItem(c, userID)
+	if !ok {
+		return
+	}
+
+	// Documents uploaded before content hashes were recorded need one to be
+	// recognized as confidential
+	var contentHash string
+	if item.ContentHash == "" && containsTag(tags, models.TagConfidential) {
+		data, err := h.storageService.ReadDocument(c.Request.Context(), item)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to read file")
+			return
+		}
+		contentHash = services.ContentHash(data)
+	}
+
+	if err := h.storageService.SetLibraryTags(c.Request.Context(), item, tags, contentHash); err != nil {
+		utils.InternalServerError(c, "Failed to update tags")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"id":   item.ID.Hex(),
+		"tags": tags,
+	})
+}
+
+// normalizeTags lowercases, trims and deduplicates tags
+func normalizeTags(raw []string) ([]string, error) {
+	tags := []string{}
+	for _, t := range raw {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || containsTag(tags, t) {
+			continue
+		}
+		if len(t) > maxLibraryTagLen {
+			return nil, fmt.Errorf("tags must be at most %d characters", maxLibraryTagLen)
+		}
+		tags = append(tags, t)
+	}
+	if len(tags) > maxLibraryTags {
+		return nil, fmt.Errorf("a document can have at most %d tags", maxLibraryTags)
+	}
+	return tags, nil
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadZip imports every PDF in an uploaded archive into the library, as
+// separate uploads would. Other files are skipped; a PDF that is invalid
+// or doesn't fit the storage limit fails on its own. Responds with the
+// number imported and the result of each entry.
+func (h *LibraryHandler) uploadZip(c *gin.Context, userID string, file multipart.File, header *multipart.FileHeader) {
+	if header.Size > 50*1024*1024 {
+		utils.BadRequest(c, "File size must be less than 50MB")
+		return
+	}
+	archive, err := services.OpenZip(file, header.Size, 50*1024*1024)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	imported := 0
+	var stop error
+	entries := make([]models.ZipEntryResult, 0, len(archive.Entries)+len(archive.Skipped))
+	for _, e := range archive.Entries {
+		res := models.ZipEntryResult{Name: e.Name, Size: e.Size, Status: models.ZipEntrySkipped}
+		if stop != nil {
+			res.Reason = stop.Error()
+			entries = append(entries, res)
+			continue
+		}
+		if strings.ToLower(path.Ext(e.Name)) != ".pdf" {
+			res.Reason = "only PDF files are imported"
+			entries = append(entries, res)
+			continue
+		}
+
+		res.Status = models.ZipEntryFailed
+		data, err := archive.ReadAll(e)
+		if err != nil {
+			if errors.Is(err, services.ErrZipTooLarge) {
+				stop = err
+			}
+			res.Reason = err.Error()
+			entries = append(entries, res)
+			continue
+		}
+		res.Size = int64(len(data))
+
+		if ok, err := h.userService.CheckStorageLimit(ctx, userID, res.Size); err != nil || !ok {
+			res.Reason = "storage limit exceeded"
+			if err != nil {
+				res.Reason = "failed to check storage limit"
+			}
+			entries = append(entries, res)
+			continue
+		}
+		if err := h.pdfService.ValidatePDF(bytes.NewReader(data)); err != nil {
+			res.Reason = "invalid PDF: " + err.Error()
+			entries = append(entries, res)
+			continue
+		}
+		pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(data))
+
+		doc, err := h.storageService.AddToLibrary(ctx, userID, e.Name, bytes.NewReader(data), pageCount, services.ContentHash(data))
+		if err != nil {
+			res.Reason = "failed to upload file"
+			entries = append(entries, res)
+			continue
+		}
+		res.Status, res.Reason = models.ZipEntryImported, ""
+		res.DocumentID, res.PageCount = doc.ID.Hex(), doc.Metadata.PageCount
+		entries = append(entries, res)
+		imported++
+	}
+	for _, s := range archive.Skipped {
+		entries = append(entries, models.ZipEntryResult{Name: s.Name, Size: s.Size, Status: models.ZipEntrySkipped, Reason: s.Reason})
+	}
+
+	utils.Success(c, gin.H{
+		"imported": imported,
+		"entries":  entries,
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// LimitsHandler reports the requester's plan limits and remaining quota
+type LimitsHandler struct {
+	userService *services.UserService
+}
+
+// NewLimitsHandler creates a new limits handler
+func NewLimitsHandler(userService *services.UserService) *LimitsHandler {
+	return &LimitsHandler{userService: userService}
+}
+
+// GetLimits handles GET /api/v1/limits
+// Works for anonymous requesters (free plan limits) and authenticated users
+func (h *LimitsHandler) GetLimits(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	limits, err := h.userService.GetUsageLimits(c.Request.Context(), userID)
+	if err != nil {
+		// Signed in but no user record yet: report free plan limits
+		limits, _ = h.userService.GetUsageLimits(c.Request.Context(), "")
+		limits.Authenticated = true
+	}
+
+	utils.Success(c, limits)
+}
+
+// RegisterRoutes registers limits routes
+func (h *LimitsHandler) RegisterRoutes(r *gin.RouterGroup, optionalAuthMiddleware gin.HandlerFunc) {
+	r.GET("/limits", optionalAuthMiddleware, h.GetLimits)
+}
@@ -0,0 +1,555 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// OrgHandler manages organizations, their compliance policies, storage
+// and legal holds
+type OrgHandler struct {
+	orgService       *services.OrgService
+	userService      *services.UserService
+	auditService     *services.AuditService
+	storageRouter    *services.StorageRouter
+	legalHoldService *services.LegalHoldService
+	brandingService  *services.BrandingService // nil unless branding is served
+}
+
+// NewOrgHandler creates a new organization handler
+func NewOrgHandler(orgService *services.OrgService, userService *services.UserService, auditService *services.AuditService, storageRouter *services.StorageRouter, legalHoldService *services.LegalHoldService) *OrgHandler {
+	return &OrgHandler{
+		orgService:       orgService,
+		userService:      userService,
+		auditService:     auditService,
+		storageRouter:    storageRouter,
+		legalHoldService: legalHoldService,
+	}
+}
+
+// SetBrandingService enables the white-label branding endpoints
+func (h *OrgHandler) SetBrandingService(brandingService *services.BrandingService) {
+	h.brandingService = brandingService
+}
+
+// Create handles POST /api/v1/orgs
+// Body: {"name": "..."}; the caller becomes the organization's admin
+func (h *OrgHandler) Create(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	email, _ := middleware.GetUserEmail(c)
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > 100 {
+		utils.BadRequest(c, "name is required and must be at most 100 characters")
+		return
+	}
+
+	org, err := h.orgService.Create(c.Request.Context(), name, models.OrgMember{UserID: userID, Email: email})
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.SuccessWithStatus(c, http.StatusCreated, org)
+}
+
+// Get handles GET /api/v1/orgs/me
+// Returns the caller's organization and their role in it
+func (h *OrgHandler) Get(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadOrg(c, userID)
+	if !ok {
+		return
+	}
+	if h.brandingService != nil {
+		h.brandingService.AttachLogoURL(c.Request.Context(), org.Branding)
+	}
+	utils.Success(c, gin.H{"organization": org, "role": org.Member(userID).Role})
+}
+
+// UpdatePolicy handles PUT /api/v1/orgs/me/policy (org admins)
+// Body: models.OrgPolicy
+func (h *OrgHandler) UpdatePolicy(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	var policy models.OrgPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	if policy.MaxShareExpiryHours < 0 {
+		utils.BadRequest(c, "maxShareExpiryHours must not be negative")
+		return
+	}
+	switch policy.SharePIIPolicy {
+	case "", models.SharePIIOff, models.SharePIIWarn, models.SharePIIBlock:
+	default:
+		utils.BadRequest(c, "sharePiiPolicy must be off, warn or block")
+		return
+	}
+
+	if err := h.orgService.UpdatePolicy(c.Request.Context(), org.ID, policy); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgPolicyUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"previous": org.Policy, "policy": policy},
+	})
+	utils.Success(c, policy)
+}
+
+// AddMember handles POST /api/v1/orgs/me/members (org admins)
+// Body: {"email": "...", "role": "member"}; the user must have signed in once
+func (h *OrgHandler) AddMember(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	if request.Role == "" {
+		request.Role = models.OrgRoleMember
+	}
+	if request.Role != models.OrgRoleMember && request.Role != models.OrgRoleAdmin {
+		utils.BadRequest(c, "role must be member or admin")
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(c.Request.Context(), strings.TrimSpace(request.Email))
+	if err != nil {
+		utils.NotFound(c, "No user with this email has signed in yet")
+		return
+	}
+
+	member := models.OrgMember{UserID: user.FirebaseUID, Email: user.Email, Role: request.Role}
+	if err := h.orgService.AddMember(c.Request.Context(), org.ID, member); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgMemberAdded,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"userId": member.UserID, "role": member.Role},
+	})
+	utils.SuccessWithStatus(c, http.StatusCreated, member)
+}
+
+// RemoveMember handles DELETE /api/v1/orgs/me/members/:uid (org admins)
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	uid := c.Param("uid")
+	if err := h.orgService.RemoveMember(c.Request.Context(), org, uid); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgMemberRemoved,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"userId": uid},
+	})
+	utils.Success(c, gin.H{"removed": true})
+}
+
+// OrgStorageRequest configures an organization's own bucket
+type OrgStorageRequest struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	UseSSL    bool   `json:"useSSL"`
+}
+
+// storage validates the request and returns the configuration it describes
+func (r OrgStorageRequest) storage() (models.OrgStorage, error) {
+	st := models.OrgStorage{
+		Endpoint:  strings.TrimSpace(r.Endpoint),
+		Region:    strings.TrimSpace(r.Region),
+		Bucket:    strings.TrimSpace(r.Bucket),
+		AccessKey: strings.TrimSpace(r.AccessKey),
+		SecretKey: r.SecretKey,
+		UseSSL:    r.UseSSL,
+	}
+	if strings.Contains(st.Endpoint, "://") || strings.Contains(st.Endpoint, "/") {
+		return st, errors.New("endpoint must be a host[:port] without scheme or path")
+	}
+	if st.Endpoint == "" || st.Bucket == "" || st.AccessKey == "" || st.SecretKey == "" {
+		return st, errors.New("endpoint, bucket, accessKey and secretKey are required")
+	}
+	return st, nil
+}
+
+// TestStorage handles POST /api/v1/orgs/me/storage/test (org admins)
+// Body: OrgStorageRequest; checks that the bucket can be used without saving
+// it
+func (h *OrgHandler) TestStorage(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if _, ok := h.loadAdminOrg(c, userID); !ok {
+		return
+	}
+
+	var request OrgStorageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	storage, err := request.storage()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.storageRouter.Test(c.Request.Context(), storage); err != nil {
+		utils.Success(c, gin.H{"ok": false, "error": err.Error()})
+		return
+	}
+	utils.Success(c, gin.H{"ok": true})
+}
+
+// SetStorage handles PUT /api/v1/orgs/me/storage (org admins)
+// Body: OrgStorageRequest. The bucket is tested first; members' new files
+// are stored there, existing files stay where they are.
+func (h *OrgHandler) SetStorage(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	var request OrgStorageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	storage, err := request.storage()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.storageRouter.Test(c.Request.Context(), storage); err != nil {
+		utils.Error(c, http.StatusUnprocessableEntity, "STORAGE_UNREACHABLE", "Storage check failed: "+err.Error())
+		return
+	}
+
+	storage.UpdatedAt = time.Now()
+	if err := h.orgService.SetStorage(c.Request.Context(), org.ID, &storage); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgStorageUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"endpoint": storage.Endpoint, "bucket": storage.Bucket},
+	})
+	utils.Success(c, storage)
+}
+
+// RemoveStorage handles DELETE /api/v1/orgs/me/storage (org admins)
+// Refused while files are still stored in the organization's bucket, as
+// they could no longer be read
+func (h *OrgHandler) RemoveStorage(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+	if org.Storage == nil {
+		utils.NotFound(c, "The organization uses platform storage")
+		return
+	}
+
+	stored, err := h.orgService.StoredFileCount(c.Request.Context(), org.ID.Hex())
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	if stored > 0 {
+		utils.Error(c, http.StatusConflict, "STORAGE_IN_USE", fmt.Sprintf("%d files are still stored in the organization's bucket", stored))
+		return
+	}
+
+	if err := h.orgService.SetStorage(c.Request.Context(), org.ID, nil); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgStorageUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"removed": true},
+	})
+	utils.Success(c, gin.H{"removed": true})
+}
+
+// UpdateBranding handles PUT /api/v1/orgs/me/branding (org admins on the
+// Business plan)
+// Body: {"accentColor": "#1a73e8", "shareDomain": "docs.example.com",
+// "watermarkDownloads": true}; the logo is kept
+func (h *OrgHandler) UpdateBranding(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadBrandingOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	var settings services.BrandingSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	branding, err := h.brandingService.Update(c.Request.Context(), org, settings)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgBrandingUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"accentColor": branding.AccentColor, "shareDomain": branding.ShareDomain, "watermarkDownloads": branding.WatermarkDownloads},
+	})
+	utils.Success(c, branding)
+}
+
+// SetBrandingLogo handles PUT /api/v1/orgs/me/branding/logo (org admins on
+// the Business plan)
+// Multipart: file (PNG or JPEG)
+func (h *OrgHandler) SetBrandingLogo(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadBrandingOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No logo image provided")
+		return
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so oversized uploads are rejected
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxBrandingLogoSize+1))
+	if err != nil {
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	branding, err := h.brandingService.SetLogo(c.Request.Context(), org, data)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgBrandingUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"logo": true},
+	})
+	utils.Success(c, branding)
+}
+
+// VerifyShareDomain handles POST /api/v1/orgs/me/branding/domain/verify
+// (org admins on the Business plan)
+// Checks that the share domain is a CNAME for SHARE_DOMAIN_TARGET; share
+// links use the domain once it is verified
+func (h *OrgHandler) VerifyShareDomain(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadBrandingOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	branding, err := h.brandingService.VerifyShareDomain(c.Request.Context(), org)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgBrandingUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"shareDomain": branding.ShareDomain, "verified": true},
+	})
+	utils.Success(c, gin.H{"branding": branding, "cnameTarget": h.brandingService.DomainTarget()})
+}
+
+// RemoveBranding handles DELETE /api/v1/orgs/me/branding (org admins)
+// Share links go back to the platform's look. Allowed on any plan, so
+// organizations that downgraded can still clear it.
+func (h *OrgHandler) RemoveBranding(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+	if h.brandingService == nil {
+		utils.ServiceUnavailable(c, "Branding is not available")
+		return
+	}
+	if org.Branding == nil {
+		utils.NotFound(c, "The organization has no branding")
+		return
+	}
+
+	if err := h.brandingService.Remove(c.Request.Context(), org); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditOrgBrandingUpdated,
+		ResourceType: "organization",
+		ResourceID:   org.ID.Hex(),
+		Details:      gin.H{"removed": true},
+	})
+	utils.Success(c, gin.H{"removed": true})
+}
+
+// loadBrandingOrg is loadAdminOrg for changing branding, which also needs
+// the admin's plan to include it
+func (h *OrgHandler) loadBrandingOrg(c *gin.Context, userID string) (*models.Organization, bool) {
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return nil, false
+	}
+	if h.brandingService == nil {
+		utils.ServiceUnavailable(c, "Branding is not available")
+		return nil, false
+	}
+	if !h.brandingService.Allowed(c.Request.Context(), userID) {
+		h.respondError(c, services.ErrBrandingUnavailable)
+		return nil, false
+	}
+	return org, true
+}
+
+// loadOrg fetches the caller's organization, writing the error response
+// when they have none
+func (h *OrgHandler) loadOrg(c *gin.Context, userID string) (*models.Organization, bool) {
+	org, err := h.orgService.ForUser(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return nil, false
+	}
+	if org == nil {
+		utils.NotFound(c, "You are not a member of an organization")
+		return nil, false
+	}
+	return org, true
+}
+
+// loadAdminOrg is loadOrg for actions reserved to organization admins
+func (h *OrgHandler) loadAdminOrg(c *gin.Context, userID string) (*models.Organization, bool) {
+	org, ok := h.loadOrg(c, userID)
+	if !ok {
+		return nil, false
+	}
+	if !org.IsAdmin(userID) {
+		utils.Forbidden(c, "Only organization admins can do this")
+		return nil, false
+	}
+	return org, true
+}
+
+func (h *OrgHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrgNotFound), errors.Is(err, services.ErrNotOrgMember):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrAlreadyInOrg):
+		utils.Error(c, http.StatusConflict, "CONFLICT", err.Error())
+	case errors.Is(err, services.ErrLastOrgAdmin), errors.Is(err, services.ErrInvalidBranding):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrBrandingUnavailable):
+		utils.Error(c, http.StatusForbidden, "PLAN_FEATURE_REQUIRED", err.Error())
+	case errors.Is(err, services.ErrShareDomainNotVerified):
+		utils.Error(c, http.StatusUnprocessableEntity, "DOMAIN_NOT_VERIFIED", err.Error())
+	case errors.Is(err, services.ErrShareDomainTaken):
+		utils.Error(c, http.StatusConflict, "DOMAIN_TAKEN", err.Error())
+	case errors.Is(err, services.ErrShareDomainsDisabled):
+		utils.ServiceUnavailable(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// RegisterRoutes registers organization routes
+func (h *OrgHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	orgs := r.Group("/orgs")
+	orgs.Use(authMiddleware)
+	{
+		orgs.POST("", h.Create)
+		orgs.GET("/me", h.Get)
+		orgs.PUT("/me/policy", h.UpdatePolicy)
+		orgs.POST("/me/members", h.AddMember)
+		orgs.DELETE("/me/members/:uid", h.RemoveMember)
+		orgs.POST("/me/storage/test", h.TestStorage)
+		orgs.PUT("/me/storage", h.SetStorage)
+		orgs.DELETE("/me/storage", h.RemoveStorage)
+		orgs.PUT("/me/branding", h.UpdateBranding)
+		orgs.PUT("/me/branding/logo", h.SetBrandingLogo)
+		orgs.POST("/me/branding/domain/verify", h.VerifyShareDomain)
+		orgs.DELETE("/me/branding", h.RemoveBranding)
+		orgs.GET("/me/legal-holds", h.ListLegalHolds)
+		orgs.PUT("/me/legal-holds/:id", h.SetLegalHold)
+		orgs.DELETE("/me/legal-holds/:id", h.ReleaseLegalHold)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxLegalHoldReasonLen = 500
+
+// LegalHoldRequest places a legal hold
+type LegalHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListLegalHolds handles GET /api/v1/orgs/me/legal-holds (org admins)
+// Lists the files held by the caller's organization
+func (h *OrgHandler) ListLegalHolds(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	items, err := h.legalHoldService.List(c.Request.Context(), org.ID.Hex())
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"items": items, "total": len(items)})
+}
+
+// SetLegalHold handles PUT /api/v1/orgs/me/legal-holds/:id (org admins)
+// Body: {"reason": "..."}. Holds a member's file or library document so
+// nobody can delete it and it outlives its retention.
+func (h *OrgHandler) SetLegalHold(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	var request LegalHoldRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if len(reason) > maxLegalHoldReasonLen {
+		utils.BadRequest(c, "reason must be at most 500 characters")
+		return
+	}
+
+	item, ok := h.loadHeldItem(c)
+	if !ok {
+		return
+	}
+	// Only files of current members can be held; a hold by another
+	// organization is left for it to release
+	if org.Member(item.OwnerUID) == nil || (item.LegalHold != nil && item.LegalHold.OrgID != org.ID.Hex()) {
+		utils.NotFound(c, "File not found")
+		return
+	}
+
+	hold := models.LegalHold{OrgID: org.ID.Hex(), SetBy: userID, Reason: reason}
+	if err := h.legalHoldService.Hold(c.Request.Context(), item, hold); err != nil {
+		h.respondLegalHoldError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditLegalHoldSet,
+		ResourceType: item.Kind,
+		ResourceID:   item.ID,
+		Details:      gin.H{"orgId": org.ID.Hex(), "ownerUid": item.OwnerUID, "reason": reason},
+	})
+	utils.Success(c, item)
+}
+
+// ReleaseLegalHold handles DELETE /api/v1/orgs/me/legal-holds/:id (org admins)
+// Releases a hold placed by the caller's organization; the file can be
+// deleted again and expires as usual
+func (h *OrgHandler) ReleaseLegalHold(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	org, ok := h.loadAdminOrg(c, userID)
+	if !ok {
+		return
+	}
+
+	item, ok := h.loadHeldItem(c)
+	if !ok {
+		return
+	}
+	if item.LegalHold == nil || item.LegalHold.OrgID != org.ID.Hex() {
+		utils.NotFound(c, "No legal hold by your organization on this file")
+		return
+	}
+
+	if err := h.legalHoldService.Release(c.Request.Context(), item); err != nil {
+		h.respondLegalHoldError(c, err)
+		return
+	}
+	h.auditService.Record(c.Request.Context(), models.AuditEntry{
+		ActorID:      userID,
+		Action:       models.AuditLegalHoldReleased,
+		ResourceType: item.Kind,
+		ResourceID:   item.ID,
+		Details:      gin.H{"orgId": org.ID.Hex(), "ownerUid": item.OwnerUID},
+	})
+	utils.Success(c, gin.H{"released": true})
+}
+
+// loadHeldItem finds the file named by the :id parameter, writing the error
+// response when there is none
+func (h *OrgHandler) loadHeldItem(c *gin.Context) (*models.HeldItem, bool) {
+	item, err := h.legalHoldService.Find(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondLegalHoldError(c, err)
+		return nil, false
+	}
+	return item, true
+}
+
+func (h *OrgHandler) respondLegalHoldError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrFileNotFound) {
+		utils.NotFound(c, "File not found")
+		return
+	}
+	utils.InternalServerError(c, err.Error())
+}
@@ -0,0 +1,193 @@
+package handlers_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/handlers"
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// Contracts pin down how the Razorpay integration builds its requests and
+// reads the responses, replaying cassettes from internal/testutil/cassettes
+// so they run without keys or network. To refresh a cassette against the
+// live API, run the test with TEST_RECORD_CASSETTES=1 and
+// RAZORPAY_KEY_ID/RAZORPAY_KEY_SECRET (test mode keys) set, and review the
+// diff; credentials are redacted before saving.
+
+const (
+	razorpayOrdersURL = "https://api.razorpay.com/v1/orders"
+	contractUser      = "contract-user-123456"
+)
+
+// TestRazorpayCreateOrderContract drives POST /api/v1/payment/order through
+// the Razorpay gateway and checks the order request: basic auth with the
+// key pair, amount in paise, a receipt within Razorpay's 40 characters,
+// auto-capture and the notes the verify step relies on
+func TestRazorpayCreateOrderContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "razorpay_create_order", "RAZORPAY_KEY_ID", "RAZORPAY_KEY_SECRET")
+	keyID, keySecret := "rzp_test_contract", "contract_secret"
+	if tape.Recording() {
+		keyID, keySecret = os.Getenv("RAZORPAY_KEY_ID"), os.Getenv("RAZORPAY_KEY_SECRET")
+	}
+	gateway := services.NewRazorpayGateway(keyID, keySecret)
+	gateway.SetHTTPClient(tape.Client())
+
+	rec := paymentRequest(t, gateway, keyID, "/api/v1/payment/order", map[string]string{"plan": "pro"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create order: status %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Data struct {
+			OrderID string `json:"orderId"`
+			Amount  int64  `json:"amount"`
+			KeyID   string `json:"keyId"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	sent := tape.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("create order: expected 1 Razorpay request, got %d", len(sent))
+	}
+	req := sent[0]
+	if req.Method != http.MethodPost || req.URL != razorpayOrdersURL {
+		t.Errorf("create order: request is %s %s, want POST %s", req.Method, req.URL, razorpayOrdersURL)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(keyID+":"+keySecret))
+	if got := req.Header.Get("Authorization"); got != wantAuth {
+		t.Errorf("create order: Authorization is %q, want basic auth with the key pair", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("create order: Content-Type is %q", got)
+	}
+
+	var body struct {
+		Amount         int64             `json:"amount"`
+		Currency       string            `json:"currency"`
+		Receipt        string            `json:"receipt"`
+		PaymentCapture int               `json:"payment_capture"`
+		Notes          map[string]string `json:"notes"`
+	}
+	req.JSON(t, &body)
+	if body.Amount != 29900 || body.Currency != "INR" {
+		t.Errorf("create order: amount %d %s, want 29900 INR", body.Amount, body.Currency)
+	}
+	if !strings.HasPrefix(body.Receipt, "rcpt_123456_") || len(body.Receipt) > 40 {
+		t.Errorf("create order: receipt %q should be rcpt_<last 6 of uid>_<unix time> within 40 characters", body.Receipt)
+	}
+	if body.PaymentCapture != 1 {
+		t.Errorf("create order: payment_capture is %d, want 1", body.PaymentCapture)
+	}
+	if body.Notes["userId"] != contractUser || body.Notes["plan"] != "pro" {
+		t.Errorf("create order: notes %v, want userId and plan", body.Notes)
+	}
+
+	var order struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(tape.Response(0).Body, &order); err != nil {
+		t.Fatalf("create order: recorded response: %v", err)
+	}
+	if res.Data.OrderID != order.ID || res.Data.Amount != 29900 || res.Data.KeyID != keyID {
+		t.Errorf("create order: got %+v, want order %s for 29900 with key %s", res.Data, order.ID, keyID)
+	}
+}
+
+// TestRazorpayOrderErrorsContract replays a failed authentication, a rejected
+// order the SDK reports as an empty one rather than an error, and a server
+// error, and checks each fails the order instead of handing checkout a
+// blank ID
+func TestRazorpayOrderErrorsContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "razorpay_create_order_errors")
+	gateway := services.NewRazorpayGateway("rzp_test_revoked", "revoked_secret")
+	gateway.SetHTTPClient(tape.Client())
+
+	for _, want := range []string{"Authentication failed", "razorpay rejected the order", "The server encountered an error"} {
+		rec := paymentRequest(t, gateway, "rzp_test_revoked", "/api/v1/payment/order", map[string]string{"plan": "business"})
+		var res struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(res.Error, want) {
+			t.Errorf("create order: got status %d %q, want 500 mentioning %q", rec.Code, res.Error, want)
+		}
+	}
+}
+
+// TestRazorpaySignatureContract checks payment signatures against the vectors
+// in cassettes/razorpay_signatures.json, which were computed independently
+// of this code, and that POST /api/v1/payment/verify rejects a bad one
+// before touching the user's plan
+func TestRazorpaySignatureContract(t *testing.T) {
+	data := testutil.ReadCassette(t, "razorpay_signatures")
+	var file struct {
+		Vectors []struct {
+			OrderID   string `json:"orderId"`
+			PaymentID string `json:"paymentId"`
+			Secret    string `json:"secret"`
+			Signature string `json:"signature"`
+			Valid     bool   `json:"valid"`
+			Note      string `json:"note"`
+		} `json:"vectors"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("razorpay_signatures.json: %v", err)
+	}
+	for i, v := range file.Vectors {
+		gateway := services.NewRazorpayGateway("rzp_test_contract", v.Secret)
+		if got := gateway.VerifyPayment(v.OrderID, v.PaymentID, v.Signature); got != v.Valid {
+			t.Errorf("signature vector %d (%s): verified %v, want %v", i+1, v.Note, got, v.Valid)
+		}
+		if v.Valid {
+			if got := services.RazorpaySignature(v.OrderID, v.PaymentID, v.Secret); got != v.Signature {
+				t.Errorf("signature vector %d: computed %s, want %s", i+1, got, v.Signature)
+			}
+		}
+	}
+
+	gateway := services.NewRazorpayGateway("rzp_test_contract", "contract_secret")
+	rec := paymentRequest(t, gateway, "rzp_test_contract", "/api/v1/payment/verify", map[string]string{
+		"razorpayOrderId":   "order_NbZ8qR3xKq1TAW",
+		"razorpayPaymentId": "pay_NbZ9c1yU4vJm2L",
+		"razorpaySignature": "f16d7683bf2e514994efff7abd61eaa4d989009144d2afe59b45a0cfca9fedc6",
+		"plan":              "pro",
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Invalid payment signature") {
+		t.Errorf("verify: got status %d %s, want 400 Invalid payment signature", rec.Code, rec.Body.String())
+	}
+}
+
+// paymentRequest sends a JSON POST to the payment routes as contractUser.
+// Only routes that don't reach the user service are used, so no database
+// is needed.
+func paymentRequest(t testing.TB, gateway services.PaymentGateway, keyID, path string, payload interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := func(c *gin.Context) {
+		c.Set(string(middleware.UserIDKey), contractUser)
+		c.Next()
+	}
+	handlers.NewPaymentHandler(&config.Config{RazorpayKeyID: keyID}, gateway, nil, nil).RegisterRoutes(router.Group("/api/v1"), auth)
+
+	req, err := testutil.NewJSONRequest(http.MethodPost, path, payload)
+	if err != nil {
+		t.Fatalf("build %s request: %v", path, err)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
@@ -2,16 +2,13 @@ package handlers
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	razorpay "github.com/razorpay/razorpay-go"
 	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/middleware"
 	"brainy-pdf/internal/models"
@@ -19,22 +16,27 @@ import (
 )
 
 type PaymentHandler struct {
-	client              *razorpay.Client
+	gateway             services.PaymentGateway
 	userService         *services.UserService
 	notificationService *services.NotificationService
 	cfg                 *config.Config
+	subscriptions       *services.SubscriptionService // nil unless recurring billing is set up
 }
 
-func NewPaymentHandler(cfg *config.Config, userService *services.UserService, notificationService *services.NotificationService) *PaymentHandler {
-	client := razorpay.NewClient(cfg.RazorpayKeyID, cfg.RazorpayKeySecret)
+func NewPaymentHandler(cfg *config.Config, gateway services.PaymentGateway, userService *services.UserService, notificationService *services.NotificationService) *PaymentHandler {
 	return &PaymentHandler{
-		client:              client,
+		gateway:             gateway,
 		userService:         userService,
 		notificationService: notificationService,
 		cfg:                 cfg,
 	}
 }
 
+// SetSubscriptionService enables the /payment/subscription endpoints
+func (h *PaymentHandler) SetSubscriptionService(subscriptions *services.SubscriptionService) {
+	h.subscriptions = subscriptions
+}
+
 // CreateOrderRequest represents the request to create an order
 type CreateOrderRequest struct {
 	Plan string `json:"plan" binding:"required"` // pro, enterprise
@@ -62,17 +64,8 @@ func (h *PaymentHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	var amount int64 // Amount in paise
-	switch req.Plan {
-	case "student":
-		amount = 9900 // ₹99
-	case "pro":
-		amount = 29900 // ₹299
-	case "plus":
-		amount = 69900 // ₹699
-	case "business":
-		amount = 199900 // ₹1999
-	default:
+	amount, ok := config.PlanPrices[req.Plan] // Amount in paise
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan"})
 		return
 	}
@@ -84,39 +77,24 @@ func (h *PaymentHandler) CreateOrder(c *gin.Context) {
 	}
 	receiptID := fmt.Sprintf("rcpt_%s_%d", shortUserID, time.Now().Unix())
 
-	data := map[string]interface{}{
-		"amount":          amount,
-		"currency":        "INR",
-		"receipt":         receiptID,
-		"payment_capture": 1,
-		"notes": map[string]interface{}{
-			"userId": userID,
-			"plan":   req.Plan,
-		},
-	}
-
 	log.Printf("CreateOrder Request: Plan=%s, UserID=%s", req.Plan, userID)
 
-	body, err := h.client.Order.Create(data, nil)
+	order, err := h.gateway.CreateOrder(amount, "INR", receiptID, map[string]string{
+		"userId": userID,
+		"plan":   req.Plan,
+	})
 	if err != nil {
 		log.Printf("[Payment Error] Razorpay Order Creation Failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
 		return
 	}
-	
-	log.Printf("[Payment] Razorpay Order Created: %v", body)
 
-	// Razorpay Go SDK returns map[string]interface{}, extract ID
-	orderID, ok := body["id"].(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from payment gateway"})
-		return
-	}
+	log.Printf("[Payment] Razorpay Order Created: %s", order.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"orderId": orderID,
+			"orderId": order.ID,
 			"amount":  amount,
 			"keyId":   h.cfg.RazorpayKeyID,
 		},
@@ -138,13 +116,7 @@ func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
 	}
 
 	// Verify Signature
-	// HMAC SHA256(order_id + "|" + payment_id, secret)
-	data := req.RazorpayOrderID + "|" + req.RazorpayPaymentID
-	hMToVerify := hmac.New(sha256.New, []byte(h.cfg.RazorpayKeySecret))
-	hMToVerify.Write([]byte(data))
-	expectedSignature := hex.EncodeToString(hMToVerify.Sum(nil))
-
-	if expectedSignature != req.RazorpaySignature {
+	if !h.gateway.VerifyPayment(req.RazorpayOrderID, req.RazorpayPaymentID, req.RazorpaySignature) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment signature"})
 		return
 	}
@@ -176,7 +148,7 @@ func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
 		defer cancel()
 		h.notificationService.CreateNotification(
 			ctx,
-			user.ID.Hex(),
+			services.UserRecipient(user.ID.Hex()),
 			"Plan Upgraded!",
 			fmt.Sprintf("You have successfully upgraded to the %s plan. Enjoy your new storage limits!", req.Plan),
 			models.NotificationTypeInfo,
@@ -186,11 +158,120 @@ func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// CreateSubscriptionRequest starts a monthly subscription to a plan
+type CreateSubscriptionRequest struct {
+	Plan string `json:"plan" binding:"required"` // student, pro, plus, business
+}
+
+// VerifySubscriptionRequest confirms a subscription's first payment with
+// what Razorpay Checkout returned
+type VerifySubscriptionRequest struct {
+	RazorpaySubscriptionID string `json:"razorpaySubscriptionId" binding:"required"`
+	RazorpayPaymentID      string `json:"razorpayPaymentId" binding:"required"`
+	RazorpaySignature      string `json:"razorpaySignature" binding:"required"`
+}
+
+// GetSubscription handles GET /api/v1/payment/subscription
+// Returns the user's subscription with its status and renewal date, or
+// null when they never subscribed
+func (h *PaymentHandler) GetSubscription(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	sub, err := h.subscriptions.Get(c.Request.Context(), userID)
+	if err != nil {
+		h.respondSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sub})
+}
+
+// CreateSubscription handles POST /api/v1/payment/subscription
+// Creates a monthly subscription; the plan applies once its first payment
+// is authorized in checkout and confirmed with /payment/subscription/verify
+func (h *PaymentHandler) CreateSubscription(c *gin.Context) {
+	var req CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+
+	sub, err := h.subscriptions.Create(c.Request.Context(), userID, req.Plan)
+	if err != nil {
+		log.Printf("[Payment Error] Subscription creation for %s failed: %v", userID, err)
+		h.respondSubscriptionError(c, err)
+		return
+	}
+	log.Printf("[Payment] Razorpay Subscription Created: %s", sub.GatewaySubscriptionID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"subscription":   sub,
+			"subscriptionId": sub.GatewaySubscriptionID,
+			"checkoutUrl":    sub.CheckoutURL,
+			"amount":         sub.Amount,
+			"keyId":          h.cfg.RazorpayKeyID,
+		},
+	})
+}
+
+// VerifySubscription handles POST /api/v1/payment/subscription/verify
+func (h *PaymentHandler) VerifySubscription(c *gin.Context) {
+	var req VerifySubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+
+	sub, err := h.subscriptions.Activate(c.Request.Context(), userID, req.RazorpaySubscriptionID, req.RazorpayPaymentID, req.RazorpaySignature)
+	if err != nil {
+		h.respondSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sub})
+}
+
+// CancelSubscription handles DELETE /api/v1/payment/subscription
+// Stops renewals; the plan lasts until the end of the paid period
+func (h *PaymentHandler) CancelSubscription(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	sub, err := h.subscriptions.Cancel(c.Request.Context(), userID)
+	if err != nil {
+		h.respondSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sub})
+}
+
+func (h *PaymentHandler) respondSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSubscriptionPlan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan"})
+	case errors.Is(err, services.ErrInvalidSubscriptionSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment signature"})
+	case errors.Is(err, services.ErrSubscriptionExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a subscription. Cancel it and let it run out before subscribing again.", "code": "SUBSCRIPTION_EXISTS"})
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func (h *PaymentHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	payment := router.Group("/payment")
 	payment.Use(authMiddleware)
 	{
 		payment.POST("/order", h.CreateOrder)
 		payment.POST("/verify", h.VerifyPayment)
+		if h.subscriptions != nil {
+			payment.GET("/subscription", h.GetSubscription)
+			payment.POST("/subscription", h.CreateSubscription)
+			payment.POST("/subscription/verify", h.VerifySubscription)
+			payment.DELETE("/subscription", h.CancelSubscription)
+		}
 	}
 }
@@ -0,0 +1,73 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"brainy-pdf/internal/testutil"
+)
+
+// TestCheckoutUpgradesPlan creates an order for the pro plan, has a tampered
+// payment rejected, then verifies the signed one through the fake gateway
+// and checks the user's plan changed
+func TestCheckoutUpgradesPlan(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	uid := "it-checkout-user"
+	env.SeedUser(t, uid, "free")
+
+	req, err := testutil.NewJSONRequest(http.MethodPost, "/api/v1/payment/order", map[string]string{"plan": "pro"})
+	if err != nil {
+		t.Fatalf("build order request: %v", err)
+	}
+	rec := env.Do(req, uid)
+	var order struct {
+		OrderID string `json:"orderId"`
+		Amount  int64  `json:"amount"`
+	}
+	if err := testutil.DecodeData(rec, &order); err != nil || order.OrderID == "" {
+		t.Fatalf("order: %v (body: %s)", err, rec.Body.String())
+	}
+	if order.Amount != 29900 {
+		t.Errorf("order: amount %d, want 29900", order.Amount)
+	}
+
+	verify := func(paymentID, signature string) int {
+		req, err := testutil.NewJSONRequest(http.MethodPost, "/api/v1/payment/verify", map[string]string{
+			"razorpayOrderId":   order.OrderID,
+			"razorpayPaymentId": paymentID,
+			"razorpaySignature": signature,
+			"plan":              "pro",
+		})
+		if err != nil {
+			t.Fatalf("build verify request: %v", err)
+		}
+		return env.Do(req, uid).Code
+	}
+
+	plan := func() string {
+		user, err := env.Users.GetUserByFirebaseUID(context.Background(), uid)
+		if err != nil {
+			t.Fatalf("load user %s: %v", uid, err)
+		}
+		return user.Plan
+	}
+
+	paymentID := "pay_fake000001"
+	if code := verify("pay_fake000002", env.Payments.Sign(order.OrderID, paymentID)); code != http.StatusBadRequest {
+		t.Errorf("verify tampered payment: status %d, want 400", code)
+	}
+	if p := plan(); p != "free" {
+		t.Fatalf("plan changed to %s after a rejected payment", p)
+	}
+
+	if code := verify(paymentID, env.Payments.Sign(order.OrderID, paymentID)); code != http.StatusOK {
+		t.Fatalf("verify: status %d", code)
+	}
+	if p := plan(); p != "pro" {
+		t.Errorf("plan is %s after checkout, want pro", p)
+	}
+}
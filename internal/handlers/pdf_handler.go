@@ -1,9 +1,10 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -45,10 +46,7 @@ func (h *PDFHandler) checkFileSize(c *gin.Context, size int64) bool {
 		return false
 	}
 
-	limits, ok := config.Plans[user.Plan]
-	if !ok {
-		limits = config.Plans["free"]
-	}
+	limits := config.GetPlanLimits(user.Plan)
 
 	if size > limits.MaxFileSize {
 		c.JSON(http.StatusForbidden, gin.H{
@@ -87,7 +85,7 @@ func (h *PDFHandler) Merge(c *gin.Context) {
 	}
 
 	// Read all files
-	var pdfData [][]byte
+	var inputs []services.PDFReader
 	for _, file := range files {
 		f, err := file.Open()
 		if err != nil {
@@ -96,23 +94,19 @@ func (h *PDFHandler) Merge(c *gin.Context) {
 		}
 		defer f.Close()
 
-		data, err := io.ReadAll(f)
-		if err != nil {
-			utils.BadRequest(c, "Failed to read file: "+file.Filename)
-			return
-		}
+		in := inputReader(f, file)
 
 		// Validate PDF
-		if err := h.pdfService.ValidatePDF(data); err != nil {
+		if err := h.pdfService.ValidatePDF(in); err != nil {
 			utils.BadRequest(c, fmt.Sprintf("Invalid PDF file: %s", file.Filename))
 			return
 		}
 
-		pdfData = append(pdfData, data)
+		inputs = append(inputs, in)
 	}
 
 	// Merge PDFs
-	result, err := h.pdfService.Merge(c.Request.Context(), pdfData)
+	result, err := h.pdfService.Merge(c.Request.Context(), inputs)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to merge PDFs: "+err.Error())
 		return
@@ -135,6 +129,7 @@ func (h *PDFHandler) Merge(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"fileId":    uploadResult.FileID,
 		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
 		"filename":  uploadResult.Filename,
 		"size":      uploadResult.Size,
 		"pageCount": result.PageCount,
@@ -160,18 +155,14 @@ func (h *PDFHandler) Split(c *gin.Context) {
 		return
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	if err := h.pdfService.ValidatePDF(data); err != nil {
+	if err := h.pdfService.ValidatePDF(in); err != nil {
 		utils.BadRequest(c, "Invalid PDF file")
 		return
 	}
 
-	result, err := h.pdfService.Split(c.Request.Context(), data, pages)
+	result, err := h.pdfService.Split(c.Request.Context(), in, pages, nil)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to split PDF: "+err.Error())
 		return
@@ -193,10 +184,11 @@ func (h *PDFHandler) Split(c *gin.Context) {
 			continue
 		}
 		urls = append(urls, gin.H{
-			"fileId":   uploadResult.FileID,
-			"url":      uploadResult.URL,
-			"filename": uploadResult.Filename,
-			"size":     uploadResult.Size,
+			"fileId":    uploadResult.FileID,
+			"url":       uploadResult.URL,
+			"retention": uploadResult.Retention,
+			"filename":  uploadResult.Filename,
+			"size":      uploadResult.Size,
 		})
 	}
 
@@ -206,7 +198,6 @@ func (h *PDFHandler) Split(c *gin.Context) {
 	})
 }
 
-
 // Rotate handles POST /api/v1/pdf/rotate
 func (h *PDFHandler) Rotate(c *gin.Context) {
 	file, header, err := c.Request.FormFile("file")
@@ -232,13 +223,9 @@ func (h *PDFHandler) Rotate(c *gin.Context) {
 		return
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.Rotate(c.Request.Context(), data, pages, angle)
+	result, err := h.pdfService.Rotate(c.Request.Context(), in, pages, angle)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to rotate PDF: "+err.Error())
 		return
@@ -260,6 +247,7 @@ func (h *PDFHandler) Rotate(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"fileId":    uploadResult.FileID,
 		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
 		"filename":  uploadResult.Filename,
 		"pageCount": result.PageCount,
 	})
@@ -280,22 +268,18 @@ func (h *PDFHandler) Compress(c *gin.Context) {
 
 	quality := c.DefaultPostForm("quality", "medium")
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	// Validate PDF before processing
-	if err := h.pdfService.ValidatePDF(data); err != nil {
+	if err := h.pdfService.ValidatePDF(in); err != nil {
 		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
 		return
 	}
 
 	// Get page count before compression
-	pageCount, _ := h.pdfService.GetPageCount(data)
+	pageCount, _ := h.pdfService.GetPageCount(in)
 
-	result, err := h.pdfService.Compress(c.Request.Context(), data, quality)
+	result, err := h.pdfService.Compress(c.Request.Context(), in, quality)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to compress PDF: "+err.Error())
 		return
@@ -317,6 +301,7 @@ func (h *PDFHandler) Compress(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"fileId":         uploadResult.FileID,
 		"url":            uploadResult.URL,
+		"retention":      uploadResult.Retention,
 		"filename":       uploadResult.Filename,
 		"originalSize":   result.SizeBefore,
 		"compressedSize": result.SizeAfter,
@@ -345,13 +330,9 @@ func (h *PDFHandler) ExtractPages(c *gin.Context) {
 		return
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.ExtractPages(c.Request.Context(), data, pages)
+	result, err := h.pdfService.ExtractPages(c.Request.Context(), in, pages)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to extract pages: "+err.Error())
 		return
@@ -371,10 +352,11 @@ func (h *PDFHandler) ExtractPages(c *gin.Context) {
 	}
 
 	utils.Success(c, gin.H{
-		"fileId":   uploadResult.FileID,
-		"url":      uploadResult.URL,
-		"filename": uploadResult.Filename,
-		"size":     uploadResult.Size,
+		"fileId":    uploadResult.FileID,
+		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
+		"filename":  uploadResult.Filename,
+		"size":      uploadResult.Size,
 	})
 }
 
@@ -397,13 +379,9 @@ func (h *PDFHandler) RemovePages(c *gin.Context) {
 		return
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.RemovePages(c.Request.Context(), data, pages)
+	result, err := h.pdfService.RemovePages(c.Request.Context(), in, pages)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to remove pages: "+err.Error())
 		return
@@ -422,12 +400,13 @@ func (h *PDFHandler) RemovePages(c *gin.Context) {
 		return
 	}
 
-	newPageCount, _ := h.pdfService.GetPageCount(result)
-	originalPageCount, _ := h.pdfService.GetPageCount(data)
+	newPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	originalPageCount, _ := h.pdfService.GetPageCount(in)
 
 	utils.Success(c, gin.H{
 		"fileId":        uploadResult.FileID,
 		"url":           uploadResult.URL,
+		"retention":     uploadResult.Retention,
 		"filename":      uploadResult.Filename,
 		"size":          uploadResult.Size,
 		"pageCount":     newPageCount,
@@ -466,13 +445,9 @@ func (h *PDFHandler) Organize(c *gin.Context) {
 		order = append(order, n)
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.OrganizePages(c.Request.Context(), data, order)
+	result, err := h.pdfService.OrganizePages(c.Request.Context(), in, order)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to organize pages: "+err.Error())
 		return
@@ -491,12 +466,13 @@ func (h *PDFHandler) Organize(c *gin.Context) {
 		return
 	}
 
-	newPageCount, _ := h.pdfService.GetPageCount(result)
-	originalPageCount, _ := h.pdfService.GetPageCount(data)
+	newPageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(result))
+	originalPageCount, _ := h.pdfService.GetPageCount(in)
 
 	utils.Success(c, gin.H{
 		"fileId":        uploadResult.FileID,
 		"url":           uploadResult.URL,
+		"retention":     uploadResult.Retention,
 		"filename":      uploadResult.Filename,
 		"size":          uploadResult.Size,
 		"pageCount":     newPageCount,
@@ -517,27 +493,20 @@ func (h *PDFHandler) Watermark(c *gin.Context) {
 		return
 	}
 
-	text := c.PostForm("text")
-	if text == "" {
+	if c.PostForm("text") == "" && c.PostForm("type") != services.WatermarkTypeImage {
 		utils.BadRequest(c, "Watermark text required")
 		return
 	}
 
-	position := c.DefaultPostForm("position", "center")
-	opacityStr := c.DefaultPostForm("opacity", "0.3")
-	opacity, _ := strconv.ParseFloat(opacityStr, 64)
-
-	data, err := io.ReadAll(file)
+	opts, err := watermarkOptionsFromForm(c)
 	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	result, err := h.pdfService.AddWatermark(c.Request.Context(), data, services.WatermarkOptions{
-		Text:     text,
-		Position: position,
-		Opacity:  opacity,
-	})
+	in := inputReader(file, header)
+
+	result, err := h.pdfService.AddWatermark(c.Request.Context(), in, opts)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to add watermark: "+err.Error())
 		return
@@ -557,10 +526,12 @@ func (h *PDFHandler) Watermark(c *gin.Context) {
 	}
 
 	utils.Success(c, gin.H{
-		"fileId":   uploadResult.FileID,
-		"url":      uploadResult.URL,
-		"filename": uploadResult.Filename,
-		"size":     uploadResult.Size,
+		"fileId":    uploadResult.FileID,
+		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
+		"filename":  uploadResult.Filename,
+		"size":      uploadResult.Size,
+		"watermark": watermarkSettings(opts),
 	})
 }
 
@@ -577,23 +548,20 @@ func (h *PDFHandler) PageNumbers(c *gin.Context) {
 		return
 	}
 
-	position := c.DefaultPostForm("position", "bottom-center")
-	format := c.DefaultPostForm("format", "{n}")
-	startFromStr := c.DefaultPostForm("startFrom", "1")
-	startFrom, _ := strconv.Atoi(startFromStr)
-
-	data, err := io.ReadAll(file)
+	opts, err := pageNumberOptionsFromForm(c)
 	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	result, err := h.pdfService.AddPageNumbers(c.Request.Context(), data, services.PageNumberOptions{
-		Position:  position,
-		Format:    format,
-		StartFrom: startFrom,
-	})
+	in := inputReader(file, header)
+
+	result, err := h.pdfService.AddPageNumbers(c.Request.Context(), in, opts)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidPageNumbers) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalServerError(c, "Failed to add page numbers: "+err.Error())
 		return
 	}
@@ -612,10 +580,12 @@ func (h *PDFHandler) PageNumbers(c *gin.Context) {
 	}
 
 	utils.Success(c, gin.H{
-		"fileId":   uploadResult.FileID,
-		"url":      uploadResult.URL,
-		"filename": uploadResult.Filename,
-		"size":     uploadResult.Size,
+		"fileId":    uploadResult.FileID,
+		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
+		"filename":  uploadResult.Filename,
+		"size":      uploadResult.Size,
+		"settings":  pageNumberSettings(opts),
 	})
 }
 
@@ -637,13 +607,9 @@ func (h *PDFHandler) Crop(c *gin.Context) {
 	bottom, _ := strconv.ParseFloat(c.DefaultPostForm("bottom", "0"), 64)
 	left, _ := strconv.ParseFloat(c.DefaultPostForm("left", "0"), 64)
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
-	result, err := h.pdfService.Crop(c.Request.Context(), data, services.CropOptions{
+	result, err := h.pdfService.Crop(c.Request.Context(), in, services.CropOptions{
 		Top:    top,
 		Right:  right,
 		Bottom: bottom,
@@ -668,10 +634,11 @@ func (h *PDFHandler) Crop(c *gin.Context) {
 	}
 
 	utils.Success(c, gin.H{
-		"fileId":   uploadResult.FileID,
-		"url":      uploadResult.URL,
-		"filename": uploadResult.Filename,
-		"size":     uploadResult.Size,
+		"fileId":    uploadResult.FileID,
+		"url":       uploadResult.URL,
+		"retention": uploadResult.Retention,
+		"filename":  uploadResult.Filename,
+		"size":      uploadResult.Size,
 	})
 }
 
@@ -688,31 +655,27 @@ func (h *PDFHandler) GetInfo(c *gin.Context) {
 		return
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		utils.BadRequest(c, "Failed to read file")
-		return
-	}
+	in := inputReader(file, header)
 
 	log.Printf("[PDF] GetInfo for file: %s, size: %d", header.Filename, header.Size)
-	info, err := h.pdfService.GetInfo(data)
+	info, err := h.pdfService.GetInfo(in)
 	if err != nil {
 		log.Printf("[PDF] Error getting info: %v", err)
 		utils.InternalServerError(c, "Failed to get PDF info: "+err.Error())
 		return
 	}
 
-	pageCount, err := h.pdfService.GetPageCount(data)
+	pageCount, err := h.pdfService.GetPageCount(in)
 	if err != nil {
 		log.Printf("[PDF] Error getting page count: %v", err)
 		utils.InternalServerError(c, "Failed to parse PDF pages: "+err.Error())
 		return
 	}
 	log.Printf("[PDF] Detected %d pages", pageCount)
-	
+
 	utils.Success(c, gin.H{
 		"pageCount": pageCount,
-		"size":      len(data),
+		"size":      in.Size(),
 		"title":     info["title"],
 		"author":    info["author"],
 		"subject":   info["subject"],
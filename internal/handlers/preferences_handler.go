@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PreferencesHandler manages users' default operation settings
+type PreferencesHandler struct {
+	preferencesService *services.PreferencesService
+}
+
+// NewPreferencesHandler creates a new preferences handler
+func NewPreferencesHandler(preferencesService *services.PreferencesService) *PreferencesHandler {
+	return &PreferencesHandler{preferencesService: preferencesService}
+}
+
+// Get handles GET /api/v1/preferences
+func (h *PreferencesHandler) Get(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	prefs, err := h.preferencesService.Get(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, prefs)
+}
+
+// Update handles PUT /api/v1/preferences
+// Body: {"compression": {"quality": "high"}, "watermark": {"text": "ACME",
+// "color": "#ff6600"}, "pageNumbers": {"format": "Page {n} of {total}"},
+// "share": {"expiresInMinutes": 4320}}. The body replaces every setting;
+// omitted ones go back to the built-in defaults.
+func (h *PreferencesHandler) Update(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var prefs models.Preferences
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	updated, err := h.preferencesService.Update(c.Request.Context(), userID, prefs)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, updated)
+}
+
+// Reset handles DELETE /api/v1/preferences
+func (h *PreferencesHandler) Reset(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.preferencesService.Reset(c.Request.Context(), userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"reset": true})
+}
+
+func (h *PreferencesHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPreferences):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// RegisterRoutes registers preferences routes
+func (h *PreferencesHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	preferences := r.Group("/preferences")
+	preferences.Use(authMiddleware)
+	{
+		preferences.GET("", h.Get)
+		preferences.PUT("", h.Update)
+		preferences.DELETE("", h.Reset)
+	}
+}
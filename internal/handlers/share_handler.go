@@ -1,24 +1,34 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/big"
 	"net/http"
 	"path/filepath"
+	"html/template"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/models"
 	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/renderer"
 	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
 	minioPkg "brainy-pdf/pkg/minio"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ShareHandler struct {
@@ -27,16 +37,187 @@ type ShareHandler struct {
 	serverHost        string // e.g., "http://localhost:3000"
 	notificationService *services.NotificationService
 	conversionService   *services.ConversionService
+	pdfService          *services.PDFService
+	blockUnsafePDFs     bool   // Scan PDFs before sharing and refuse high-risk ones
+	piiPolicy           string // models.SharePIIOff, SharePIIWarn or SharePIIBlock
+	auditService        *services.AuditService
+	orgService          *services.OrgService
+	storageRouter       *services.StorageRouter
+	storageService      *services.StorageService
+	codeAlphabet        string
+	codeLength          int
+	virusScan           *services.VirusScanService // nil unless files are scanned
+	preferences         *services.PreferencesService // nil unless users' default expiry applies
+	branding            *services.BrandingService    // nil unless organizations' branding applies
+	thumbnails          *services.ThumbnailService   // nil unless links can have cover thumbnails
+	counter             *services.ShareCounter       // nil counts every hit from people
+	abuse               *services.AbuseService       // nil unless links can be taken down after abuse reports
 }
 
-func NewShareHandler(minioClient *minioPkg.Client, mongoClient *mongo.Client, dbName, serverHost string, notifService *services.NotificationService, conversionService *services.ConversionService) *ShareHandler {
-	return &ShareHandler{
+func NewShareHandler(minioClient *minioPkg.Client, mongoClient *mongo.Client, dbName, serverHost string, notifService *services.NotificationService, conversionService *services.ConversionService, pdfService *services.PDFService, blockUnsafePDFs bool, piiPolicy string, auditService *services.AuditService, orgService *services.OrgService, storageRouter *services.StorageRouter, storageService *services.StorageService, codeAlphabet string, codeLength int, virusScan *services.VirusScanService) *ShareHandler {
+	h := &ShareHandler{
 		minioClient:         minioClient,
 		db:                  mongoClient.Database(dbName),
 		serverHost:          serverHost,
 		notificationService: notifService,
 		conversionService:   conversionService,
+		pdfService:          pdfService,
+		blockUnsafePDFs:     blockUnsafePDFs,
+		piiPolicy:           piiPolicy,
+		auditService:        auditService,
+		orgService:          orgService,
+		storageRouter:       storageRouter,
+		storageService:      storageService,
+		codeAlphabet:        codeAlphabet,
+		codeLength:          codeLength,
+		virusScan:           virusScan,
 	}
+
+	// Codes and vanity slugs share one namespace
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := h.db.Collection("shares").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		log.Printf("Warning: failed to create unique index on shares.code: %v", err)
+	}
+	return h
+}
+
+// SetPreferencesService makes links created without expiresInMinutes last
+// as long as the user's saved default
+func (h *ShareHandler) SetPreferencesService(preferences *services.PreferencesService) {
+	h.preferences = preferences
+}
+
+// SetBrandingService applies the white-label branding of the creator's
+// organization to share links, their page and downloaded PDFs
+func (h *ShareHandler) SetBrandingService(branding *services.BrandingService) {
+	h.branding = branding
+}
+
+// SetThumbnailService lets links show a rendered page of the PDF as their
+// cover
+func (h *ShareHandler) SetThumbnailService(thumbnails *services.ThumbnailService) {
+	h.thumbnails = thumbnails
+}
+
+// SetShareCounter counts a viewer's repeat visits to a link within the
+// counter's window once
+func (h *ShareHandler) SetShareCounter(counter *services.ShareCounter) {
+	h.counter = counter
+}
+
+// SetAbuseService takes links offline, and stops their owners sharing,
+// when an admin disables them after abuse reports
+func (h *ShareHandler) SetAbuseService(abuse *services.AbuseService) {
+	h.abuse = abuse
+}
+
+// notTakenDown responds and returns false when an admin disabled the link,
+// or all of its creator's links, after reports of abuse
+func (h *ShareHandler) notTakenDown(c *gin.Context, share models.Share) bool {
+	if h.abuse == nil {
+		return true
+	}
+	if share.Takedown != nil || h.abuse.SharingDisabled(c.Request.Context(), share.CreatorID) != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "This link has been disabled for violating our terms",
+			"code":  "SHARE_DISABLED",
+		})
+		return false
+	}
+	return true
+}
+
+// shareHitFields are the stats each kind of hit counts towards, and the
+// notification sent to the link's creator, if any
+var shareHitFields = map[string]struct {
+	field, title, message string
+	notifType             models.NotificationType
+}{
+	services.ShareHitView:     {"stats.views", "File Viewed", "Your shared file '%s' was viewed.", models.NotificationTypeInfo},
+	services.ShareHitPreview:  {"stats.previews", "", "", ""},
+	services.ShareHitDownload: {"stats.downloads", "File Downloaded", "Your shared file '%s' was downloaded.", models.NotificationTypeSuccess},
+}
+
+// recordHit counts a hit of kind on share in its stats, in the background,
+// and notifies the creator. Bots, prefetches, range requests past the start
+// of the file and, with a counter, a viewer's repeat visits aren't counted.
+func (h *ShareHandler) recordHit(c *gin.Context, share models.Share, kind string) {
+	for _, header := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		if strings.Contains(strings.ToLower(c.GetHeader(header)), "prefetch") {
+			return
+		}
+	}
+	if r := strings.ReplaceAll(c.GetHeader("Range"), " ", ""); r != "" && !strings.HasPrefix(r, "bytes=0-") {
+		return
+	}
+	ip, ua := c.ClientIP(), c.Request.UserAgent()
+	if services.IsBotUserAgent(ua) {
+		return
+	}
+
+	hit := shareHitFields[kind]
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if h.counter != nil && !h.counter.Count(ctx, share.Code, kind, ip, ua) {
+			return
+		}
+		h.db.Collection("shares").UpdateOne(ctx,
+			bson.M{"_id": share.ID},
+			bson.M{"$inc": bson.M{hit.field: 1}, "$set": bson.M{"stats.lastAccess": time.Now()}},
+		)
+
+		// Public links are usually opened anonymously, so the creator's own
+		// visits are notified too
+		if share.CreatorID != "" && hit.title != "" {
+			h.notificationService.CreateNotification(
+				ctx,
+				services.FirebaseRecipient(share.CreatorID),
+				hit.title,
+				fmt.Sprintf(hit.message, share.Filename),
+				hit.notifType,
+			)
+		}
+	}()
+}
+
+// brandingFor returns the organization whose branding applies to
+// creatorID's links, or nil
+func (h *ShareHandler) brandingFor(ctx context.Context, creatorID string) *models.Organization {
+	if h.branding == nil {
+		return nil
+	}
+	return h.branding.ForUser(ctx, creatorID)
+}
+
+// servedOnHost reports whether share may be opened on the request's host:
+// an organization's share domain only serves its members' links. It
+// responds and returns false otherwise.
+func (h *ShareHandler) servedOnHost(c *gin.Context, share models.Share) bool {
+	if h.branding == nil {
+		return true
+	}
+	tenant := h.branding.ForShareDomain(c.Request.Context(), c.Request.Host)
+	if tenant == nil || tenant.Member(share.CreatorID) != nil {
+		return true
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or expired"})
+	return false
+}
+
+// CheckDomain handles GET /api/v1/share/domains/check?domain=
+// Answers 200 for verified share domains and 404 otherwise, for reverse
+// proxies issuing TLS certificates on demand (Caddy's on_demand_tls ask)
+func (h *ShareHandler) CheckDomain(c *gin.Context) {
+	if h.branding == nil || h.branding.ForShareDomain(c.Request.Context(), c.Query("domain")) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown share domain"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 // CreateShareRequest
@@ -44,14 +225,126 @@ type CreateShareRequest struct {
 	FileID           string `json:"fileId" binding:"required"`
 	FileType         string `json:"fileType" binding:"required,oneof=library temp"`
 	Filename         string `json:"filename"` // Optional filename for display
+	Slug             string `json:"slug"`     // Optional custom code, on plans with vanity links
 	ExpiresInMinutes int    `json:"expiresInMinutes"` // Minutes, default 1440 (24h)
+	// "download" (default) or "view": view-only links show PDFs in the
+	// share page's viewer, watermarked, and refuse downloads
+	Permission string `json:"permission"`
+	// Optional RFC 3339 time the link opens at; expiresInMinutes then
+	// counts from it
+	ActivatesAt *time.Time `json:"activatesAt"`
+	// Optional landing page: a title and description shown instead of the
+	// filename, and a page of the PDF (1-based) rendered as its cover
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CoverPage   int    `json:"coverPage"`
+	// Share even though the PII check found sensitive data (warn policy)
+	AcknowledgeSensitiveData bool `json:"acknowledgeSensitiveData"`
 }
 
-// generateCode creates a random 8-char hex string
-func generateCode() string {
-	bytes := make([]byte, 4)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
+// maxShareScheduleAhead bounds how far ahead a link may be scheduled to open
+const maxShareScheduleAhead = 30 * 24 * time.Hour
+
+// shareCodeAttempts bounds retries when a generated code is already taken
+const shareCodeAttempts = 5
+
+// shareSlugPattern is what vanity slugs may look like: lowercase letters,
+// digits and inner hyphens, 3 to 48 characters
+var shareSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,46}[a-z0-9]$`)
+
+// errShareSlugTaken means a vanity slug belongs to another active link
+var errShareSlugTaken = errors.New("share slug taken")
+
+// releasedCodePrefix starts the codes of links that gave up their slug.
+// Slugs and generated codes never contain a colon.
+const releasedCodePrefix = "released:"
+
+// generateCode creates a random code from the configured alphabet
+func (h *ShareHandler) generateCode() (string, error) {
+	max := big.NewInt(int64(len(h.codeAlphabet)))
+	code := make([]byte, h.codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = h.codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// insertShare stores share under slug, or under a fresh code when slug is
+// empty, retrying when the code is already taken. Expired links give up
+// their slugs (see releaseSlug).
+func (h *ShareHandler) insertShare(ctx context.Context, share *models.Share, slug string) error {
+	shares := h.db.Collection("shares")
+	if slug != "" {
+		share.Code = slug
+		_, err := shares.InsertOne(ctx, share)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		released, err := h.releaseSlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if !released {
+			return errShareSlugTaken
+		}
+		_, err = shares.InsertOne(ctx, share)
+		if mongo.IsDuplicateKeyError(err) {
+			return errShareSlugTaken
+		}
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < shareCodeAttempts; attempt++ {
+		if share.Code, err = h.generateCode(); err != nil {
+			return err
+		}
+		if _, err = shares.InsertOne(ctx, share); !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("no free share code after %d attempts: %w", shareCodeAttempts, err)
+}
+
+// releaseSlug frees the slug of an expired link for another link. The
+// expired link isn't deleted: it keeps its stats and cover under a new
+// code, abuse reports against it follow it there, and it can no longer be
+// extended. Returns false when no expired link holds the slug.
+func (h *ShareHandler) releaseSlug(ctx context.Context, slug string) (bool, error) {
+	shares := h.db.Collection("shares")
+	now := time.Now()
+	expired := bson.M{"code": slug, "expiresAt": bson.M{"$lte": now}}
+	var old models.Share
+	err := shares.FindOne(ctx, expired).Decode(&old)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	code := releasedCodePrefix + old.ID.Hex()
+	expired["_id"] = old.ID
+	res, err := shares.UpdateOne(ctx, expired, bson.M{"$set": bson.M{
+		"code":         code,
+		"releasedSlug": slug,
+		"releasedAt":   now,
+	}})
+	if err != nil {
+		return false, err
+	}
+	if res.ModifiedCount == 0 {
+		// Extended in the meantime
+		return false, nil
+	}
+	if _, err := h.db.Collection("abuse_reports").UpdateMany(ctx, bson.M{"shareCode": slug}, bson.M{"$set": bson.M{"shareCode": code}}); err != nil {
+		log.Printf("Warning: failed to move abuse reports of released slug %s: %v", slug, err)
+	}
+	return true, nil
 }
 
 // CreateShare generates a public link
@@ -68,18 +361,61 @@ func (h *ShareHandler) CreateShare(c *gin.Context) {
 		return
 	}
 
-	// Default expiration: 24h (1440 mins)
+	switch req.Permission = strings.ToLower(strings.TrimSpace(req.Permission)); req.Permission {
+	case "":
+		req.Permission = models.SharePermissionDownload
+	case models.SharePermissionView, models.SharePermissionDownload:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "permission must be \"view\" or \"download\""})
+		return
+	}
+
+	// Default expiration: the user's preference, else 24h (1440 mins)
+	if req.ExpiresInMinutes <= 0 && h.preferences != nil {
+		if prefs, err := h.preferences.Get(c.Request.Context(), userId); err == nil {
+			req.ExpiresInMinutes = prefs.Share.ExpiresInMinutes
+		}
+	}
 	if req.ExpiresInMinutes <= 0 {
 		req.ExpiresInMinutes = 1440
 	}
 	// Max limit checks (e.g. max 7 days = 10080 mins)
-	if req.ExpiresInMinutes > 10080 {
+	if req.ExpiresInMinutes > services.MaxShareExpiryMinutes {
 		req.ExpiresInMinutes = 1440
 	}
 
-	code := generateCode()
+	// Organization policy can forbid public links or shorten their lifetime
+	policy := h.orgService.PolicyFor(c.Request.Context(), userId)
+	if policy.ExternalSharingDisabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Sharing disabled",
+			"message": "Your organization does not allow public share links.",
+			"code":    "POLICY_SHARING_DISABLED",
+		})
+		return
+	}
+	if max := policy.MaxShareExpiryHours * 60; max > 0 && req.ExpiresInMinutes > max {
+		req.ExpiresInMinutes = max
+	}
+	piiPolicy := h.piiPolicy
+	if policy.SharePIIPolicy != "" {
+		piiPolicy = policy.SharePIIPolicy
+	}
+
 	expiresAt := time.Now().Add(time.Duration(req.ExpiresInMinutes) * time.Minute)
 
+	// Scheduled links last as long, counted from when they open; times
+	// already past open the link right away
+	var activatesAt *time.Time
+	if req.ActivatesAt != nil && req.ActivatesAt.After(time.Now()) {
+		if req.ActivatesAt.After(time.Now().Add(maxShareScheduleAhead)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("activatesAt must be within %d days", int(maxShareScheduleAhead.Hours()/24))})
+			return
+		}
+		activatesAt = req.ActivatesAt
+		expiresAt = activatesAt.Add(time.Duration(req.ExpiresInMinutes) * time.Minute)
+	}
+
 	// Fetch user to check plan
 	var user models.User
 	err := h.db.Collection("users").FindOne(context.Background(), bson.M{"firebaseUid": userId}).Decode(&user)
@@ -97,18 +433,128 @@ func (h *ShareHandler) CreateShare(c *gin.Context) {
 		})
 		return
 	}
+	if user.SharingDisabled != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Sharing disabled",
+			"message": "Your sharing was disabled after reports of abuse: " + user.SharingDisabled.Reason,
+			"code":    "SHARING_DISABLED",
+		})
+		return
+	}
+
+	if req.Title, req.Description, err = normalizeLandingPage(req.Title, req.Description); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if slug != "" {
+		if !config.GetPlanLimits(user.Plan).VanityShareLinks {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Access Denied",
+				"message": "Custom share links are available on Pro and higher plans.",
+				"code":    "PRO_FEATURE_REQUIRED",
+			})
+			return
+		}
+		if !shareSlugPattern.MatchString(slug) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slugs are 3-48 lowercase letters, digits and hyphens, not starting or ending with a hyphen"})
+			return
+		}
+	}
+
+	doc, err := h.shareableDocument(c.Request.Context(), req, userId)
+	switch {
+	case errors.Is(err, services.ErrFileAccessDenied):
+		denyFileAccess(c, "You can only share your own files.")
+		return
+	case errors.Is(err, services.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up file"})
+		return
+	}
+	if !h.scannedClean(c, doc) {
+		return
+	}
+
+	checkPII := piiPolicy == models.SharePIIWarn || piiPolicy == models.SharePIIBlock
+	var data []byte
+	if (h.blockUnsafePDFs || checkPII) && h.pdfService != nil {
+		data, err = h.readSharedFile(c.Request.Context(), req.FileID, doc)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+	}
+	isPDF := bytes.HasPrefix(data, []byte("%PDF"))
+
+	// Public links reach people who didn't choose to trust the sender, so
+	// PDFs that run scripts or programs can be kept out of them
+	if h.blockUnsafePDFs && h.pdfService != nil {
+		if isPDF {
+			report, err := h.pdfService.ScanSecurity(c.Request.Context(), bytes.NewReader(data))
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Unable to scan file: " + err.Error()})
+				return
+			}
+			if !report.Safe {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":   "Unsafe file",
+					"message": "This PDF contains scripts, launch actions or embedded programs and can't be shared publicly. Sanitize it first.",
+					"code":    "UNSAFE_PDF",
+					"scan":    report,
+				})
+				return
+			}
+		}
+	}
+
+	// Documents with identity or card numbers shouldn't leak through a
+	// public link by accident
+	var piiFindings map[string]int
+	if checkPII && h.pdfService != nil && isPDF {
+		piiFindings = h.scanPII(c.Request.Context(), data)
+		if len(piiFindings) > 0 {
+			entry := models.AuditEntry{
+				ActorID:      userId,
+				ResourceType: "share",
+				ResourceID:   req.FileID,
+				Details:      gin.H{"policy": piiPolicy, "findings": piiFindings},
+			}
+			switch {
+			case piiPolicy == models.SharePIIBlock:
+				entry.Action = models.AuditSharePIIBlocked
+				h.auditService.Record(c.Request.Context(), entry)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":    "Sensitive data",
+					"message":  "This PDF contains " + describePII(piiFindings) + " and can't be shared publicly. Redact it first.",
+					"code":     "PII_BLOCKED",
+					"findings": piiFindings,
+				})
+				return
+			case !req.AcknowledgeSensitiveData:
+				c.JSON(http.StatusConflict, gin.H{
+					"error":    "Sensitive data",
+					"message":  "This PDF contains " + describePII(piiFindings) + ". Resend with acknowledgeSensitiveData set to share it anyway.",
+					"code":     "PII_DETECTED",
+					"findings": piiFindings,
+				})
+				return
+			}
+			// Shared anyway; recorded once the link exists
+		}
+	}
 
 	// Fetch filename if not provided
 	filename := req.Filename
 	if filename == "" {
-		// Try to look up the original document filename
-		var doc models.Document
-		if fileObjID, err := primitive.ObjectIDFromHex(req.FileID); err == nil {
-			if err := h.db.Collection("documents").FindOne(context.Background(), bson.M{"_id": fileObjID}).Decode(&doc); err == nil && doc.OriginalName != "" {
-				filename = doc.OriginalName
-			}
+		// Use the original document filename
+		if doc != nil {
+			filename = doc.OriginalName
 		}
-		
+
 		// Fallback defaults if lookup fails
 		if filename == "" {
 			if req.FileType == "temp" {
@@ -119,36 +565,636 @@ func (h *ShareHandler) CreateShare(c *gin.Context) {
 		}
 	}
 
+	// The viewer only shows PDFs
+	if req.Permission == models.SharePermissionView && !sharedPDF(doc, filename) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "View-only links are for PDFs",
+			"message": "Only PDFs can be shared view-only. Share this file with download permission instead.",
+			"code":    "VIEW_ONLY_UNSUPPORTED",
+		})
+		return
+	}
+
+	var coverKey string
+	if req.CoverPage > 0 {
+		var ok bool
+		if coverKey, ok = h.renderCover(c, req.FileID, doc, filename, req.CoverPage, data); !ok {
+			return
+		}
+	}
+
 	share := models.Share{
-		Code:      code,
 		FileID:    req.FileID,
 		FileType:  req.FileType,
 		CreatorID: userId,
 		Filename:  filename,
+		Permission: req.Permission,
+		Title:       req.Title,
+		Description: req.Description,
+		CoverKey:    coverKey,
 		ExpiresAt: expiresAt,
 		CreatedAt: time.Now(),
+		ActivatesAt: activatesAt,
 		Stats: models.ShareStats{
 			Views:     0,
 			Downloads: 0,
 		},
 	}
 
-	_, err = h.db.Collection("shares").InsertOne(context.Background(), share)
+	err = h.insertShare(c.Request.Context(), &share, slug)
+	if err != nil && coverKey != "" {
+		h.minioClient.DeleteFile(c.Request.Context(), h.minioClient.GetBucketUserFiles(), coverKey)
+	}
+	if errors.Is(err, errShareSlugTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This link name is already in use", "code": "SLUG_TAKEN"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
 		return
 	}
+	code := share.Code
+
+	if len(piiFindings) > 0 {
+		h.auditService.Record(c.Request.Context(), models.AuditEntry{
+			ActorID:      userId,
+			Action:       models.AuditSharePIIOverride,
+			ResourceType: "share",
+			ResourceID:   req.FileID,
+			Details:      gin.H{"policy": piiPolicy, "findings": piiFindings, "code": code},
+		})
+	}
 
-	shareUrl := fmt.Sprintf("%s/s/%s", h.serverHost, code)
+	shareUrl := h.sharePageURL(h.brandingFor(c.Request.Context(), userId), code)
 
+	created := gin.H{
+		"code":      code,
+		"url":       shareUrl,
+		"expiresAt": expiresAt,
+		"permission": req.Permission,
+	}
+	if activatesAt != nil {
+		created["activatesAt"] = activatesAt
+	}
+	if coverKey != "" {
+		created["coverUrl"] = h.apiBase(c, h.brandingFor(c.Request.Context(), userId)) + "/api/v1/share/" + code + "/cover"
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"code":      code,
-			"url":       shareUrl,
-			"expiresAt": expiresAt,
-		},
+		"data":    created,
+	})
+}
+
+// UpdateShareRequest changes an existing link. Omitted fields are kept.
+type UpdateShareRequest struct {
+	// RFC 3339; a time already past opens a scheduled link right away
+	ActivatesAt *time.Time `json:"activatesAt"`
+	// RFC 3339; extends or shortens the link. When only activatesAt
+	// changes, the link keeps its lifetime.
+	ExpiresAt *time.Time `json:"expiresAt"`
+	// Landing page; empty strings and coverPage 0 remove them
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	CoverPage   *int    `json:"coverPage"`
+}
+
+// UpdateShare handles PATCH /api/v1/share/:code
+// Lets a link's creator reschedule it, move its expiry and change its
+// landing page. Schedules get the same limits as new links: a paid plan,
+// at most MaxShareExpiryMinutes from when the link opens and the
+// organization's maxShareExpiryHours.
+func (h *ShareHandler) UpdateShare(c *gin.Context) {
+	var req UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: activatesAt and expiresAt must be RFC 3339 times"})
+		return
+	}
+	rescheduled := req.ActivatesAt != nil || req.ExpiresAt != nil
+	if !rescheduled && req.Title == nil && req.Description == nil && req.CoverPage == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to change"})
+		return
+	}
+
+	userId, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var share models.Share
+	err := h.db.Collection("shares").FindOne(c.Request.Context(), bson.M{"code": c.Param("code"), "creatorId": userId}).Decode(&share)
+	if err != nil {
+		n, _ := h.db.Collection("shares").CountDocuments(c.Request.Context(), bson.M{"releasedSlug": c.Param("code"), "creatorId": userId})
+		if n > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "This link expired and its slug is now used by another link",
+				"code":  "SLUG_RELEASED",
+			})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Collection("users").FindOne(c.Request.Context(), bson.M{"firebaseUid": userId}).Decode(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Plan == "" || user.Plan == "free" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Access Denied",
+			"message": "Public sharing is a Pro feature (Plus 4+ models). Upgrade your plan to unlock!",
+			"code":    "PRO_FEATURE_REQUIRED",
+		})
+		return
+	}
+	if share.Takedown != nil || user.SharingDisabled != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This link has been disabled for violating our terms",
+			"code":  "SHARE_DISABLED",
+		})
+		return
+	}
+
+	set := bson.M{}
+	if rescheduled && !h.reschedule(c, &share, req, set) {
+		return
+	}
+
+	if req.Title != nil || req.Description != nil {
+		title, description := share.Title, share.Description
+		if req.Title != nil {
+			title = *req.Title
+		}
+		if req.Description != nil {
+			description = *req.Description
+		}
+		if title, description, err = normalizeLandingPage(title, description); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		set["title"], set["description"] = title, description
+	}
+
+	previousCover := share.CoverKey
+	if req.CoverPage != nil {
+		coverKey := ""
+		if *req.CoverPage > 0 {
+			doc, ok := h.linkedDocument(c, share)
+			if !ok {
+				return
+			}
+			if coverKey, ok = h.renderCover(c, share.FileID, doc, share.Filename, *req.CoverPage, nil); !ok {
+				return
+			}
+		}
+		set["coverKey"] = coverKey
+	}
+
+	if _, err := h.db.Collection("shares").UpdateOne(c.Request.Context(), bson.M{"_id": share.ID}, bson.M{"$set": set}); err != nil {
+		if key, _ := set["coverKey"].(string); key != "" {
+			h.minioClient.DeleteFile(c.Request.Context(), h.minioClient.GetBucketUserFiles(), key)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share link"})
+		return
+	}
+	if _, replaced := set["coverKey"]; replaced && previousCover != "" {
+		h.minioClient.DeleteFile(c.Request.Context(), h.minioClient.GetBucketUserFiles(), previousCover)
+	}
+	if err := h.db.Collection("shares").FindOne(c.Request.Context(), bson.M{"_id": share.ID}).Decode(&share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share link"})
+		return
+	}
+
+	data := gin.H{
+		"code":        share.Code,
+		"expiresAt":   share.ExpiresAt,
+		"title":       share.Title,
+		"description": share.Description,
+	}
+	if share.ActivatesAt != nil {
+		data["activatesAt"] = share.ActivatesAt
+	}
+	if share.CoverKey != "" {
+		data["coverUrl"] = h.apiBase(c, h.brandingFor(c.Request.Context(), share.CreatorID)) + "/api/v1/share/" + share.Code + "/cover"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// reschedule validates a change of share's activation and expiry and adds
+// the fields to save to set. It responds and returns false when the change
+// isn't allowed.
+func (h *ShareHandler) reschedule(c *gin.Context, share *models.Share, req UpdateShareRequest, set bson.M) bool {
+	now := time.Now()
+	lifetime := share.ExpiresAt.Sub(share.ActiveFrom())
+	activatesAt, activationNotified := share.ActivatesAt, share.ActivationNotified
+	if req.ActivatesAt != nil {
+		switch {
+		case req.ActivatesAt.After(now.Add(maxShareScheduleAhead)):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("activatesAt must be within %d days", int(maxShareScheduleAhead.Hours()/24))})
+			return false
+		case req.ActivatesAt.After(now):
+			activatesAt, activationNotified = req.ActivatesAt, false
+		case share.Pending(now):
+			// Opened early: the link is live from now
+			activatesAt = &now
+		}
+	}
+	activeFrom := share.CreatedAt
+	if activatesAt != nil {
+		activeFrom = *activatesAt
+	}
+
+	expiresAt := activeFrom.Add(lifetime)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || !expiresAt.After(activeFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future and after the link opens"})
+		return false
+	}
+	if expiresAt.Sub(activeFrom) > services.MaxShareExpiryMinutes*time.Minute {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Share links can stay open for at most %d days", services.MaxShareExpiryMinutes/1440)})
+		return false
+	}
+	policy := h.orgService.PolicyFor(c.Request.Context(), share.CreatorID)
+	if policy.ExternalSharingDisabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Sharing disabled",
+			"message": "Your organization does not allow public share links.",
+			"code":    "POLICY_SHARING_DISABLED",
+		})
+		return false
+	}
+	if hours := policy.MaxShareExpiryHours; hours > 0 && expiresAt.Sub(activeFrom) > time.Duration(hours)*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Your organization limits share links to %d hours", hours)})
+		return false
+	}
+
+	set["expiresAt"], set["activationNotified"] = expiresAt, activationNotified
+	if activatesAt != nil {
+		set["activatesAt"] = activatesAt
+	}
+	return true
+}
+
+// openNow reports whether share has opened; it responds with when it will
+// and returns false for scheduled links that haven't
+func (h *ShareHandler) openNow(c *gin.Context, share models.Share) bool {
+	if !share.Pending(time.Now()) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       "This link isn't open yet",
+		"code":        "SHARE_NOT_ACTIVE",
+		"activatesAt": share.ActivatesAt,
 	})
+	return false
+}
+
+// shareActivationInterval is how often scheduled links are checked for
+// having opened
+const shareActivationInterval = time.Minute
+
+// RunActivationNotices notifies creators as their scheduled links open, on
+// whichever instance holds the lease, until ctx is done
+func (h *ShareHandler) RunActivationNotices(ctx context.Context, leases *services.LeaseService) {
+	leases.RunPeriodic(ctx, "share-activation", shareActivationInterval, h.notifyActivated)
+}
+
+// notifyActivated sends the notices for links that opened since the last
+// run. Each link is claimed before its notice goes out, so none is sent
+// twice.
+func (h *ShareHandler) notifyActivated(ctx context.Context) {
+	shares := h.db.Collection("shares")
+	now := time.Now()
+	for {
+		var share models.Share
+		err := shares.FindOneAndUpdate(ctx,
+			bson.M{"activatesAt": bson.M{"$lte": now}, "activationNotified": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"activationNotified": true}},
+		).Decode(&share)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+		if err != nil {
+			log.Printf("[Share] Failed to claim opened links: %v", err)
+			return
+		}
+		// Links that ended before opening, after a reschedule, have
+		// nothing to announce
+		if share.CreatorID == "" || !share.ExpiresAt.After(now) {
+			continue
+		}
+		h.notificationService.CreateNotification(
+			ctx,
+			services.FirebaseRecipient(share.CreatorID),
+			"Share Link Live",
+			fmt.Sprintf("Your scheduled link for '%s' is now open.", share.Filename),
+			models.NotificationTypeInfo,
+		)
+	}
+}
+
+// sharePageURL returns the public page of the link code, on the
+// organization's verified share domain when it has one
+func (h *ShareHandler) sharePageURL(org *models.Organization, code string) string {
+	if org != nil && org.Branding.VerifiedShareDomain() != "" {
+		return fmt.Sprintf("%s/s/%s", h.branding.ShareDomainURL(org.Branding.VerifiedShareDomain()), code)
+	}
+	return fmt.Sprintf("%s/s/%s", h.serverHost, code)
+}
+
+// apiBase returns the base URL the share API is reached at: the
+// organization's verified share domain, which serves the API too, or the
+// request's own host
+func (h *ShareHandler) apiBase(c *gin.Context, org *models.Organization) string {
+	if org != nil {
+		if domain := org.Branding.VerifiedShareDomain(); domain != "" {
+			return h.branding.ShareDomainURL(domain)
+		}
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.Request.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
+
+// normalizeLandingPage trims a link's title and description and checks
+// their length
+func normalizeLandingPage(title, description string) (string, string, error) {
+	title, description = strings.TrimSpace(title), strings.TrimSpace(description)
+	if utf8.RuneCountInString(title) > models.MaxShareTitleLength {
+		return "", "", fmt.Errorf("title can be at most %d characters", models.MaxShareTitleLength)
+	}
+	if utf8.RuneCountInString(description) > models.MaxShareDescriptionLength {
+		return "", "", fmt.Errorf("description can be at most %d characters", models.MaxShareDescriptionLength)
+	}
+	return title, description, nil
+}
+
+// shareCoverDPI renders covers about 600 pixels wide for A4 and Letter
+// pages, the size link previews show
+const shareCoverDPI = 72
+
+// renderCover renders page of the shared PDF, doc or the conversion result
+// fileID, as a JPEG cover and stores it, returning its object key. data is
+// the file when already loaded. It responds and returns false when the
+// cover can't be made.
+func (h *ShareHandler) renderCover(c *gin.Context, fileID string, doc *models.Document, filename string, page int, data []byte) (string, bool) {
+	if h.thumbnails == nil || !h.thumbnails.Capability().Available {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cover thumbnails are unavailable on this server"})
+		return "", false
+	}
+	if !sharedPDF(doc, filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only PDFs can have a cover page"})
+		return "", false
+	}
+	if data == nil {
+		var err error
+		if data, err = h.readSharedFile(c.Request.Context(), fileID, doc); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return "", false
+		}
+	}
+
+	thumbs, err := h.thumbnails.Render(c.Request.Context(), bytes.NewReader(data), []int{page}, renderer.Options{DPI: shareCoverDPI, Format: renderer.FormatJPEG})
+	switch {
+	case errors.Is(err, renderer.ErrInvalidOptions), errors.Is(err, services.ErrUnreadablePDF):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coverPage: " + err.Error()})
+		return "", false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render cover: " + err.Error()})
+		return "", false
+	}
+
+	key := fmt.Sprintf("shares/covers/%d.jpg", time.Now().UnixNano())
+	if _, err := h.minioClient.UploadBytes(c.Request.Context(), h.minioClient.GetBucketUserFiles(), key, thumbs[0].Data, "image/jpeg"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store cover"})
+		return "", false
+	}
+	return key, true
+}
+
+// linkedDocument returns the document share points at, nil for a
+// conversion result. It responds and returns false when it is gone or
+// hasn't passed the virus scan.
+func (h *ShareHandler) linkedDocument(c *gin.Context, share models.Share) (*models.Document, bool) {
+	if _, err := primitive.ObjectIDFromHex(share.FileID); err != nil {
+		return nil, true
+	}
+	doc, err := h.sharedDocument(c.Request.Context(), share.FileID, share.FileType, share.CreatorID)
+	if errors.Is(err, services.ErrFileAccessDenied) {
+		denyFileAccess(c, "This link was created by someone who doesn't own the file.")
+		return nil, false
+	}
+	if errors.Is(err, services.ErrFileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Original file not found in library or documents"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up file"})
+		return nil, false
+	}
+	return doc, h.scannedClean(c, doc)
+}
+
+// sharedPDF reports whether a file being shared, doc or a conversion
+// result when doc is nil, is a PDF
+func sharedPDF(doc *models.Document, filename string) bool {
+	if doc != nil && doc.MimeType != "" {
+		return doc.MimeType == "application/pdf"
+	}
+	return strings.EqualFold(filepath.Ext(filename), ".pdf")
+}
+
+// allowedByPolicy applies the share creator's current organization policy
+// to an existing link, so tightening the policy also covers links created
+// before; it responds and returns false when the link may not be used
+func (h *ShareHandler) allowedByPolicy(c *gin.Context, share models.Share) bool {
+	policy := h.orgService.PolicyFor(c.Request.Context(), share.CreatorID)
+	if policy.ExternalSharingDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sharing has been disabled by the owner's organization", "code": "POLICY_SHARING_DISABLED"})
+		return false
+	}
+	if hours := policy.MaxShareExpiryHours; hours > 0 && time.Now().After(share.ActiveFrom().Add(time.Duration(hours)*time.Hour)) {
+		c.JSON(http.StatusGone, gin.H{"error": "Share link expired"})
+		return false
+	}
+	return true
+}
+
+// scanPII counts the SharePIITypes in a PDF's text. PDFs without
+// extractable text, such as scans, have nothing to find.
+func (h *ShareHandler) scanPII(ctx context.Context, data []byte) map[string]int {
+	text, err := h.pdfService.ExtractText(ctx, bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	found := services.DetectSensitivePatterns(text)
+	findings := map[string]int{}
+	for _, t := range models.SharePIITypes {
+		if n := found.Types[t]; n > 0 {
+			findings[t] = n
+		}
+	}
+	return findings
+}
+
+// describePII names the kinds of sensitive data found, e.g. "2 SSNs and 1
+// Aadhaar number"
+func describePII(findings map[string]int) string {
+	names := map[string][2]string{
+		"ssn":         {"SSN", "SSNs"},
+		"credit_card": {"card number", "card numbers"},
+		"aadhaar":     {"Aadhaar number", "Aadhaar numbers"},
+	}
+	var parts []string
+	for _, t := range models.SharePIITypes {
+		n := findings[t]
+		if n == 0 {
+			continue
+		}
+		name := names[t][1]
+		if n == 1 {
+			name = names[t][0]
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, name))
+	}
+	if len(parts) > 1 {
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+	return strings.Join(parts, "")
+}
+
+// scannedClean refuses, with the scan status, files that haven't passed
+// the virus scan; links to them stay blocked until they do. Conversion
+// results, which have no document, aren't scanned.
+func (h *ShareHandler) scannedClean(c *gin.Context, doc *models.Document) bool {
+	err := h.virusScan.Check(doc)
+	switch {
+	case errors.Is(err, services.ErrFileInfected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "Infected file",
+			"message":    "The virus scan found malware in this file, so it can't be shared.",
+			"code":       "FILE_INFECTED",
+			"scanStatus": models.VirusScanInfected,
+		})
+		return false
+	case errors.Is(err, services.ErrScanPending):
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Scan pending",
+			"message":    "This file is still being scanned for viruses. Try again shortly.",
+			"code":       "SCAN_PENDING",
+			"scanStatus": models.VirusScanPending,
+		})
+		return false
+	}
+	return true
+}
+
+// findDocument finds a document by ID, moving it over from ownerID's
+// legacy library if needed
+func (h *ShareHandler) findDocument(ctx context.Context, fileID, ownerID string) (*models.Document, error) {
+	objID, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return nil, services.ErrFileNotFound
+	}
+	var doc models.Document
+	err = h.db.Collection("documents").FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return h.storageService.LibraryDocument(ctx, fileID, ownerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// sharedDocument finds the document a link shared by ownerID points at.
+// Library links stop working once the document leaves the owner's library,
+// and links to another user's file, made before shares were checked, are
+// refused with ErrFileAccessDenied.
+func (h *ShareHandler) sharedDocument(ctx context.Context, fileID, fileType, ownerID string) (*models.Document, error) {
+	doc, err := h.findDocument(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner := services.DocumentOwner(doc); owner != "" && owner != ownerID {
+		return nil, services.ErrFileAccessDenied
+	}
+	if fileType == "library" && (doc.IsTemporary || services.DocumentOwner(doc) != ownerID) {
+		return nil, services.ErrFileNotFound
+	}
+	return doc, nil
+}
+
+// denyFileAccess responds to a share of a file that belongs to someone
+// other than the link's creator
+func denyFileAccess(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Access denied",
+		"message": message,
+		"code":    "FILE_ACCESS_DENIED",
+	})
+}
+
+// shareableDocument checks that userID may share the requested file and
+// returns its document, or nil for a conversion result. Owned files may
+// only be shared by their owner; unowned temporary uploads and conversion
+// results by anyone holding their ID, as that is enough to download them.
+func (h *ShareHandler) shareableDocument(ctx context.Context, req CreateShareRequest, userID string) (*models.Document, error) {
+	if _, err := primitive.ObjectIDFromHex(req.FileID); err != nil {
+		if req.FileType == "library" || h.conversionService == nil {
+			return nil, services.ErrFileNotFound
+		}
+		if _, err := h.conversionService.GetJob(req.FileID); err != nil {
+			return nil, services.ErrFileNotFound
+		}
+		return nil, nil
+	}
+
+	doc, err := h.findDocument(ctx, req.FileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if owner := services.DocumentOwner(doc); owner != "" && owner != userID {
+		return nil, services.ErrFileAccessDenied
+	}
+	if doc.ExpiresAt != nil && time.Now().After(*doc.ExpiresAt) {
+		return nil, services.ErrFileNotFound
+	}
+	if req.FileType == "library" && doc.IsTemporary {
+		return nil, services.ErrFileNotFound
+	}
+	return doc, nil
+}
+
+// readSharedFile loads a file being shared: doc, or the conversion result
+// fileID when doc is nil
+func (h *ShareHandler) readSharedFile(ctx context.Context, fileID string, doc *models.Document) ([]byte, error) {
+	if doc == nil {
+		if h.conversionService == nil {
+			return nil, services.ErrFileNotFound
+		}
+		result, _, _, err := h.conversionService.OpenResult(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		defer result.Close()
+		return io.ReadAll(result)
+	}
+
+	if _, objectKey := doc.Location(); objectKey == "" {
+		return nil, fmt.Errorf("invalid file path %q", doc.MinIOPath)
+	}
+	return h.storageService.ReadDocument(ctx, doc)
 }
 
 // GetShare retrieves the file info and a download URL
@@ -166,48 +1212,57 @@ func (h *ShareHandler) GetShare(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Share link expired"})
 		return
 	}
+	if !h.notTakenDown(c, share) || !h.allowedByPolicy(c, share) || !h.servedOnHost(c, share) || !h.openNow(c, share) {
+		return
+	}
 
 	// Update stats (async)
-	// Update stats (async)
-	go func() {
-		h.db.Collection("shares").UpdateOne(context.Background(), 
-			bson.M{"code": code}, 
-			bson.M{"$inc": bson.M{"stats.views": 1}, "$set": bson.M{"stats.lastAccess": time.Now()}},
-		)
+	h.recordHit(c, share, services.ShareHitView)
 
-		// Notify owner (avoid self-notification would require checking creatorID vs current user, 
-		// but this is public link so usually anonymous viewer)
-		if share.CreatorID != "" {
-			var user models.User
-			if err := h.db.Collection("users").FindOne(context.Background(), bson.M{"firebaseUid": share.CreatorID}).Decode(&user); err == nil {
-				h.notificationService.CreateNotification(
-					context.Background(),
-					user.ID.Hex(),
-					"File Viewed",
-					fmt.Sprintf("Your shared file '%s' was viewed.", share.Filename),
-					models.NotificationTypeInfo,
-				)
-			}
-		}
-	}()
-
-	var downloadURL string
+	// Unified download URL pointing to our backend endpoint, on the
+	// organization's share domain when it has one
+	org := h.brandingFor(c.Request.Context(), share.CreatorID)
+	base := h.apiBase(c, org)
+	// View-only links point at the inline, watermarked copy instead
+	endpoint := "download"
+	if share.ViewOnly() {
+		endpoint = "view"
+	}
+	downloadURL := fmt.Sprintf("%s/api/v1/share/%s/%s", base, endpoint, code)
 
-	// Unified download URL pointing to our backend endpoint
-	// Use the current request's host to construct the download URL dynamically
-	scheme := "http"
-	if c.Request.TLS != nil || c.Request.Header.Get("X-Forwarded-Proto") == "https" {
-		scheme = "https"
+	permission := share.Permission
+	if permission == "" {
+		permission = models.SharePermissionDownload
+	}
+	data := gin.H{
+		"filename": share.Filename,
+		"url":      downloadURL,
+		"expiresAt": share.ExpiresAt,
+		"permission":   permission,
+		"downloadable": !share.ViewOnly(),
+	}
+	// Landing page details, when the creator gave them
+	if share.Title != "" {
+		data["title"] = share.Title
+	}
+	if share.Description != "" {
+		data["description"] = share.Description
+	}
+	if share.CoverKey != "" {
+		data["coverUrl"] = fmt.Sprintf("%s/api/v1/share/%s/cover", base, code)
+	}
+	// The share page shows the owner's organization branding
+	if org != nil {
+		data["branding"] = gin.H{
+			"organization": org.Name,
+			"logoUrl":      org.Branding.LogoURL,
+			"accentColor":  org.Branding.AccentColor,
+		}
 	}
-	downloadURL = fmt.Sprintf("%s://%s/api/v1/share/download/%s", scheme, c.Request.Host, code)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"filename": share.Filename,
-			"url":      downloadURL,
-			"expiresAt": share.ExpiresAt,
-		},
+		"data":    data,
 	})
 }
 
@@ -215,12 +1270,25 @@ func (h *ShareHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware gi
 	fmt.Println("[Share] Registering /share routes")
 	// Protected: Create share
 	router.POST("/share", authMiddleware, h.CreateShare)
+
+	// Protected: Reschedule or change the expiry of your share
+	router.PATCH("/share/:code", authMiddleware, h.UpdateShare)
 	
 	// Public: Access share
 	router.GET("/share/:code", h.GetShare)
+
+	// Public: Open Graph tags for link previews, and the cover they show
+	router.GET("/share/:code/meta", h.Meta)
+	router.GET("/share/:code/cover", h.Cover)
+
+	// Public: TLS issuance check for organizations' share domains
+	router.GET("/share/domains/check", h.CheckDomain)
 	
 	// Public: Download shared file (streaming)
 	router.GET("/share/download/:code", h.Download)
+
+	// Public: Shared PDF for the share page's viewer
+	router.GET("/share/view/:code", h.View)
 }
 
 // Download handles the actual file streaming for shared files
@@ -238,39 +1306,32 @@ func (h *ShareHandler) Download(c *gin.Context) {
 		c.JSON(http.StatusGone, gin.H{"error": "Share link expired"})
 		return
 	}
+	if !h.notTakenDown(c, share) || !h.allowedByPolicy(c, share) || !h.servedOnHost(c, share) || !h.openNow(c, share) {
+		return
+	}
+	if share.ViewOnly() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Download disabled",
+			"message": "This link only allows viewing the file.",
+			"code":    "SHARE_VIEW_ONLY",
+		})
+		return
+	}
 
 	// Increment download count (async)
-	go func() {
-		h.db.Collection("shares").UpdateOne(context.Background(),
-			bson.M{"code": code},
-			bson.M{"$inc": bson.M{"stats.downloads": 1}},
-		)
-
-		// Notify owner
-		if share.CreatorID != "" {
-			var user models.User
-			if err := h.db.Collection("users").FindOne(context.Background(), bson.M{"firebaseUid": share.CreatorID}).Decode(&user); err == nil {
-				h.notificationService.CreateNotification(
-					context.Background(),
-					user.ID.Hex(),
-					"File Downloaded",
-					fmt.Sprintf("Your shared file '%s' was downloaded.", share.Filename),
-					models.NotificationTypeSuccess,
-				)
-			}
-		}
-	}()
+	h.recordHit(c, share, services.ShareHitDownload)
 
 	// Check if FileID is a valid ObjectID (MongoDB document)
 	// If not, it might be a Conversion Job ID (UUID)
-	objID, err := primitive.ObjectIDFromHex(share.FileID)
-	if err != nil {
+	if _, err := primitive.ObjectIDFromHex(share.FileID); err != nil {
 		// Not an ObjectID, check conversion service
 		if h.conversionService != nil {
-			path, filename, err := h.conversionService.GetResultPath(share.FileID)
+			result, filename, size, err := h.conversionService.OpenResult(c.Request.Context(), share.FileID)
 			if err == nil {
-				// Serve local file
-				c.FileAttachment(path, filename)
+				defer result.Close()
+				c.Header("Content-Disposition", utils.ContentDisposition("attachment", filename))
+				c.Header("Content-Length", fmt.Sprintf("%d", size))
+				c.DataFromReader(http.StatusOK, size, "application/octet-stream", result, nil)
 				return
 			}
 		}
@@ -281,42 +1342,30 @@ func (h *ShareHandler) Download(c *gin.Context) {
 	}
 
 	// Fetch actual document record to get MinIO path
-	var bucketName, objectName, filename, mimeType string
-
 	fmt.Printf("[DEBUG] Share Download: FileID='%s', FileType='%s'\n", share.FileID, share.FileType)
 
-	// Try fetching from 'documents' collection first
-	var doc models.Document
-	err = h.db.Collection("documents").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&doc)
-	if err == nil {
-		fmt.Printf("[DEBUG] Found in 'documents' collection: MinIOPath='%s'\n", doc.MinIOPath)
-		parts := strings.SplitN(doc.MinIOPath, "/", 2)
-		if len(parts) == 2 {
-			bucketName = parts[0]
-			objectName = parts[1]
-		}
-		filename = doc.OriginalName
-		mimeType = doc.MimeType
-	} else {
-		fmt.Printf("[DEBUG] Not found in 'documents', trying 'library': %v\n", err)
-		// Fallback: Try fetching from 'library' collection
-		var libItem LibraryItem
-		err = h.db.Collection("library").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&libItem)
-		if err == nil {
-			fmt.Printf("[DEBUG] Found in 'library' collection: FileKey='%s'\n", libItem.FileKey)
-            // Library items only store the object name (FileKey)
-            // The bucket is the configured user files bucket
-            bucketName = h.minioClient.GetBucketUserFiles()
-            objectName = libItem.FileKey
-            
-            filename = libItem.FileName
-            mimeType = libItem.MimeType
-		} else {
-			fmt.Printf("[ERROR] Not found in 'library' either: %v\n", err)
-            // Not found in either
-            c.JSON(http.StatusNotFound, gin.H{"error": "Original file not found in library or documents"})
-            return
-        }
+	doc, err := h.sharedDocument(c.Request.Context(), share.FileID, share.FileType, share.CreatorID)
+	if errors.Is(err, services.ErrFileAccessDenied) {
+		denyFileAccess(c, "This link was created by someone who doesn't own the file.")
+		return
+	}
+	if errors.Is(err, services.ErrFileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Original file not found in library or documents"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up file"})
+		return
+	}
+	if !h.scannedClean(c, doc) {
+		return
+	}
+	bucketName, objectName := doc.Location()
+	filename, mimeType := doc.OriginalName, doc.MimeType
+	client, err := h.storageRouter.ForDocument(c.Request.Context(), doc)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The organization's storage is unavailable. Please try again later."})
+		return
 	}
 
     // Prepare for download if we found the object
@@ -328,13 +1377,13 @@ func (h *ShareHandler) Download(c *gin.Context) {
     fmt.Printf("[DEBUG] Share Download: Bucket='%s', Object='%s'\n", bucketName, objectName)
 
 	// Get file info for size (verify)
-	info, err := h.minioClient.GetFileInfo(context.Background(), bucketName, objectName)
+	_, err = client.GetFileInfo(context.Background(), bucketName, objectName)
 	if err != nil {
         fmt.Printf("[DEBUG] File info check failed: %v\n", err)
         
         // DEBUG: List top files in the bucket to see what is there
         fmt.Printf("[DEBUG] Listing up to 10 files in bucket '%s'...\n", bucketName)
-        files, listErr := h.minioClient.ListObjects(context.Background(), bucketName, "")
+        files, listErr := client.ListObjects(context.Background(), bucketName, "")
         if listErr != nil {
             fmt.Printf("[DEBUG] Failed to list objects: %v\n", listErr)
         } else {
@@ -346,7 +1395,7 @@ func (h *ShareHandler) Download(c *gin.Context) {
 	}
 
 	// Get object stream
-	object, err := h.minioClient.GetObject(context.Background(), bucketName, objectName)
+	object, size, err := client.OpenObject(context.Background(), bucketName, objectName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file. Please try again."})
 		return
@@ -370,11 +1419,218 @@ func (h *ShareHandler) Download(c *gin.Context) {
 		downloadFilename += ".pdf"
 	}
 	
+	var branded []byte
+	if contentType == "application/pdf" {
+		if branded, err = h.brandedPDF(c.Request.Context(), share.CreatorID, object); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file. Please try again."})
+			return
+		}
+		if branded != nil {
+			size = int64(len(branded))
+		}
+	}
+
 	// Force download
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadFilename))
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", downloadFilename))
 	c.Header("Content-Type", contentType)
-	c.Header("Content-Length", fmt.Sprintf("%d", info.Size))
+	c.Header("Content-Length", fmt.Sprintf("%d", size))
 
+	if branded != nil {
+		c.Writer.Write(branded)
+		return
+	}
 	// Stream
 	io.Copy(c.Writer, object)
 }
+
+// brandedPDF returns the shared PDF in object with the logo of the
+// creator's organization stamped on every page, when it watermarks
+// downloads; nil means object is served as stored. A logo that can't be
+// applied leaves the PDF unbranded.
+func (h *ShareHandler) brandedPDF(ctx context.Context, creatorID string, object io.Reader) ([]byte, error) {
+	org := h.brandingFor(ctx, creatorID)
+	if org == nil || !org.Branding.WatermarkDownloads {
+		return nil, nil
+	}
+	logo, err := h.branding.Logo(ctx, org.Branding)
+	if err != nil || logo == nil {
+		if err != nil {
+			log.Printf("[Share] Branding logo of org %s unavailable: %v", org.ID.Hex(), err)
+		}
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, err
+	}
+	stamped, err := h.pdfService.StampImage(ctx, bytes.NewReader(data), logo, services.ImageStampOptions{X: 24, Y: 24, Width: 72, Opacity: 0.6})
+	if err != nil {
+		log.Printf("[Share] Failed to stamp branding of org %s: %v", org.ID.Hex(), err)
+		return data, nil
+	}
+	return stamped, nil
+}
+
+// viewOnlyWatermark is stamped across every page of PDFs served to
+// view-only links
+var viewOnlyWatermark = services.WatermarkOptions{Text: "VIEW ONLY", FontSize: 60, Opacity: 0.15}
+
+// View handles GET /api/v1/share/view/:code
+// Serves a shared PDF inline for the share page's viewer, counted as a
+// preview. View-only links get a copy watermarked across every page.
+func (h *ShareHandler) View(c *gin.Context) {
+	share, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	code := share.Code
+
+	doc, ok := h.linkedDocument(c, share)
+	if !ok {
+		return
+	}
+	if !sharedPDF(doc, share.Filename) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only PDFs can be viewed in the browser"})
+		return
+	}
+
+	data, err := h.readSharedFile(c.Request.Context(), share.FileID, doc)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found in storage. It may have been deleted or expired."})
+		return
+	}
+	if share.ViewOnly() {
+		if data, err = h.pdfService.AddWatermark(c.Request.Context(), bytes.NewReader(data), viewOnlyWatermark); err != nil {
+			// Never hand out an unmarked copy of a view-only file
+			log.Printf("[Share] Failed to watermark view-only share %s: %v", code, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare file for viewing"})
+			return
+		}
+	}
+	branded, err := h.brandedPDF(c.Request.Context(), share.CreatorID, bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare file for viewing"})
+		return
+	}
+	if branded != nil {
+		data = branded
+	}
+
+	h.recordHit(c, share, services.ShareHitPreview)
+	c.Header("Content-Disposition", utils.ContentDisposition("inline", share.Filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// openShare loads the link in the code parameter, responding and returning
+// false when it doesn't exist, has expired, isn't open yet or may not be
+// used under the creator's policy or on this host
+func (h *ShareHandler) openShare(c *gin.Context) (models.Share, bool) {
+	var share models.Share
+	if err := h.db.Collection("shares").FindOne(c.Request.Context(), bson.M{"code": c.Param("code")}).Decode(&share); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or expired"})
+		return share, false
+	}
+	if time.Now().After(share.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Share link expired"})
+		return share, false
+	}
+	if !h.notTakenDown(c, share) || !h.allowedByPolicy(c, share) || !h.servedOnHost(c, share) || !h.openNow(c, share) {
+		return share, false
+	}
+	return share, true
+}
+
+// shareMetaPage is the page link previews are built from: Open Graph and
+// Twitter tags, and a redirect to the share page for people who open it
+var shareMetaPage = template.Must(template.New("meta").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{- if .Image}}
+<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.Image}}">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body><a href="{{.URL}}">{{.Title}}</a></body>
+</html>
+`))
+
+// Meta handles GET /api/v1/share/:code/meta
+// Serves the link's Open Graph tags for chat apps and social sites to
+// preview, redirecting people to the share page. Views aren't counted.
+func (h *ShareHandler) Meta(c *gin.Context) {
+	share, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+
+	org := h.brandingFor(c.Request.Context(), share.CreatorID)
+	page := struct {
+		Title, Description, SiteName, URL, Image string
+	}{
+		Title:       share.Title,
+		Description: share.Description,
+		SiteName:    "BinaryPDF",
+		URL:         h.sharePageURL(org, share.Code),
+	}
+	if page.Title == "" {
+		page.Title = share.Filename
+	}
+	if org != nil {
+		page.SiteName = org.Name
+	}
+	if page.Description == "" {
+		page.Description = "A file shared with you on BinaryPDF."
+		if org != nil {
+			page.Description = fmt.Sprintf("%s shared a file with you.", org.Name)
+		}
+	}
+	if share.CoverKey != "" {
+		page.Image = fmt.Sprintf("%s/api/v1/share/%s/cover", h.apiBase(c, org), share.Code)
+	}
+
+	var buf bytes.Buffer
+	if err := shareMetaPage.Execute(&buf, page); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render page"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Cover handles GET /api/v1/share/:code/cover
+// Serves the link's cover thumbnail
+func (h *ShareHandler) Cover(c *gin.Context) {
+	share, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	if share.CoverKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This link has no cover"})
+		return
+	}
+
+	object, size, err := h.minioClient.OpenObject(c.Request.Context(), h.minioClient.GetBucketUserFiles(), share.CoverKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cover not found"})
+		return
+	}
+	defer object.Close()
+	c.Header("Cache-Control", "public, max-age=300")
+	c.DataFromReader(http.StatusOK, size, "image/jpeg", object, nil)
+}
@@ -0,0 +1,180 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/pdfgen"
+	"brainy-pdf/internal/testutil"
+)
+
+// TestShareOwnership checks that links can only be made to, and only serve,
+// files their creator owns. Run with: go test -tags integration ./internal/handlers/
+func TestShareOwnership(t *testing.T) {
+	env := testutil.NewEnv(t)
+
+	owner, other := "it-share-owner", "it-share-other"
+	env.SeedUser(t, owner, "pro")
+	env.SeedUser(t, other, "pro")
+
+	req, err := testutil.NewMultipartRequest(http.MethodPost, "/api/v1/files/upload",
+		[]testutil.FormFile{{Field: "file", Name: "owned.pdf", Data: pdfgen.Sample(2)}}, nil)
+	if err != nil {
+		t.Fatalf("build upload request: %v", err)
+	}
+	rec := env.Do(req, owner)
+	var uploaded struct {
+		FileID string `json:"fileId"`
+	}
+	if err := testutil.DecodeData(rec, &uploaded); err != nil || uploaded.FileID == "" {
+		t.Fatalf("upload: %v (body: %s)", err, rec.Body.String())
+	}
+
+	share := func(uid string) *httptest.ResponseRecorder {
+		req, err := testutil.NewJSONRequest(http.MethodPost, "/api/v1/share", map[string]interface{}{
+			"fileId":   uploaded.FileID,
+			"fileType": "library",
+		})
+		if err != nil {
+			t.Fatalf("build share request: %v", err)
+		}
+		return env.Do(req, uid)
+	}
+
+	t.Run("NonOwnerCannotShare", func(t *testing.T) {
+		rec := share(other)
+		expectAccessDenied(t, rec)
+
+		n, err := env.Mongo.Collection("shares").CountDocuments(context.Background(), map[string]string{"fileId": uploaded.FileID})
+		if err != nil {
+			t.Fatalf("count shares: %v", err)
+		}
+		if n != 0 {
+			t.Errorf("%d links were stored for the refused share", n)
+		}
+	})
+
+	t.Run("NonOwnerCannotReadThroughShare", func(t *testing.T) {
+		// A link to the owner's file made by another user, as could be
+		// created before shares were checked
+		now := time.Now()
+		_, err := env.Mongo.Collection("shares").InsertOne(context.Background(), models.Share{
+			Code:      "crosstenant1",
+			FileID:    uploaded.FileID,
+			CreatorID: other,
+			FileType:  "temp",
+			Filename:  "owned.pdf",
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now,
+		})
+		if err != nil {
+			t.Fatalf("insert share: %v", err)
+		}
+
+		for _, path := range []string{"/api/v1/share/download/crosstenant1", "/api/v1/share/view/crosstenant1"} {
+			rec := env.Do(httptest.NewRequest(http.MethodGet, path, nil), "")
+			expectAccessDenied(t, rec)
+		}
+	})
+
+	t.Run("OwnerCanShare", func(t *testing.T) {
+		rec := share(owner)
+		var shared struct {
+			Code string `json:"code"`
+		}
+		if err := testutil.DecodeData(rec, &shared); err != nil || shared.Code == "" {
+			t.Fatalf("share: status %d: %v (body: %s)", rec.Code, err, rec.Body.String())
+		}
+
+		rec = env.Do(httptest.NewRequest(http.MethodGet, "/api/v1/share/download/"+shared.Code, nil), "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("download: status %d (body: %s)", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+// TestShareSlugRelease checks that an expired link gives up its vanity slug
+// without being deleted
+func TestShareSlugRelease(t *testing.T) {
+	env := testutil.NewEnv(t)
+	ctx := context.Background()
+
+	former, claimer := "it-slug-former", "it-slug-claimer"
+	env.SeedUser(t, former, "pro")
+	env.SeedUser(t, claimer, "pro")
+
+	expired := time.Now().Add(-time.Hour)
+	_, err := env.Mongo.Collection("shares").InsertOne(ctx, models.Share{
+		Code:      "launch-notes",
+		FileID:    "temp-file",
+		CreatorID: former,
+		FileType:  "temp",
+		Filename:  "notes.pdf",
+		Stats:     models.ShareStats{Views: 7},
+		ExpiresAt: expired,
+		CreatedAt: expired.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("insert share: %v", err)
+	}
+
+	req, err := testutil.NewMultipartRequest(http.MethodPost, "/api/v1/files/upload",
+		[]testutil.FormFile{{Field: "file", Name: "claim.pdf", Data: pdfgen.Sample(1)}}, nil)
+	if err != nil {
+		t.Fatalf("build upload request: %v", err)
+	}
+	var uploaded struct {
+		FileID string `json:"fileId"`
+	}
+	if err := testutil.DecodeData(env.Do(req, claimer), &uploaded); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	req, err = testutil.NewJSONRequest(http.MethodPost, "/api/v1/share", map[string]interface{}{
+		"fileId":   uploaded.FileID,
+		"fileType": "library",
+		"slug":     "launch-notes",
+	})
+	if err != nil {
+		t.Fatalf("build share request: %v", err)
+	}
+	if rec := env.Do(req, claimer); rec.Code != http.StatusOK {
+		t.Fatalf("claim slug: status %d (body: %s)", rec.Code, rec.Body.String())
+	}
+
+	var old models.Share
+	if err := env.Mongo.Collection("shares").FindOne(ctx, map[string]string{"creatorId": former}).Decode(&old); err != nil {
+		t.Fatalf("expired link was deleted: %v", err)
+	}
+	if old.Code == "launch-notes" || old.ReleasedSlug != "launch-notes" || old.Stats.Views != 7 {
+		t.Errorf("expired link: code %q, released slug %q, views %d", old.Code, old.ReleasedSlug, old.Stats.Views)
+	}
+
+	req, err = testutil.NewJSONRequest(http.MethodPatch, "/api/v1/share/launch-notes", map[string]interface{}{
+		"expiresAt": time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("build update request: %v", err)
+	}
+	if rec := env.Do(req, former); rec.Code != http.StatusConflict {
+		t.Errorf("extending the released link: status %d, want 409 (body: %s)", rec.Code, rec.Body.String())
+	}
+}
+
+func expectAccessDenied(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	var res struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec.Code != http.StatusForbidden || res.Code != "FILE_ACCESS_DENIED" {
+		t.Errorf("got status %d code %q, want 403 FILE_ACCESS_DENIED (body: %s)", rec.Code, res.Code, rec.Body.String())
+	}
+}
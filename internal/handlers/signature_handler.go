@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SignatureHandler manages the per-user signature image library
+type SignatureHandler struct {
+	signatureService *services.SignatureService
+}
+
+// NewSignatureHandler creates a new signature handler
+func NewSignatureHandler(signatureService *services.SignatureService) *SignatureHandler {
+	return &SignatureHandler{signatureService: signatureService}
+}
+
+// Create handles POST /api/v1/signatures
+// Multipart: file (PNG), name, kind ("signature" or "initials")
+func (h *SignatureHandler) Create(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No signature image provided")
+		return
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so oversized uploads are rejected
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxSignatureSize+1))
+	if err != nil {
+		utils.BadRequest(c, "Failed to read file")
+		return
+	}
+
+	sig, err := h.signatureService.Create(c.Request.Context(), userID, c.PostForm("name"), c.PostForm("kind"), data)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.SuccessWithStatus(c, http.StatusCreated, sig)
+}
+
+// List handles GET /api/v1/signatures
+func (h *SignatureHandler) List(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	sigs, err := h.signatureService.List(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"signatures": sigs, "total": len(sigs)})
+}
+
+// Get handles GET /api/v1/signatures/:id
+func (h *SignatureHandler) Get(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	sig, err := h.signatureService.Get(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, sig)
+}
+
+// Update handles PUT /api/v1/signatures/:id
+// Body: {"name": "...", "kind": "initials"}
+func (h *SignatureHandler) Update(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var request struct {
+		Name string `json:"name"`
+		Kind string `json:"kind"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	sig, err := h.signatureService.Update(c.Request.Context(), userID, c.Param("id"), request.Name, request.Kind)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, sig)
+}
+
+// Delete handles DELETE /api/v1/signatures/:id
+func (h *SignatureHandler) Delete(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.signatureService.Delete(c.Request.Context(), userID, c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"deleted": true})
+}
+
+func (h *SignatureHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSignatureNotFound):
+		utils.NotFound(c, "Signature not found")
+	case errors.Is(err, services.ErrInvalidSignature):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// RegisterRoutes registers signature routes
+func (h *SignatureHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	signatures := r.Group("/signatures")
+	signatures.Use(authMiddleware)
+	{
+		signatures.POST("", h.Create)
+		signatures.GET("", h.List)
+		signatures.GET("/:id", h.Get)
+		signatures.PUT("/:id", h.Update)
+		signatures.DELETE("/:id", h.Delete)
+	}
+}
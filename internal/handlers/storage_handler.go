@@ -1,23 +1,34 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"strconv"
 
+	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/renderer"
 	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
+	"brainy-pdf/pkg/minio"
 	"github.com/gin-gonic/gin"
 )
 
 // StorageHandler handles file storage endpoints
 type StorageHandler struct {
 	storageService *services.StorageService
+	userService    *services.UserService
+	importer       *services.URLImporter
+	thumbnails     *services.ThumbnailService
+	capabilities   *services.CapabilityRegistry
 }
 
 // NewStorageHandler creates a new storage handler
-func NewStorageHandler(storageService *services.StorageService) *StorageHandler {
-	return &StorageHandler{storageService: storageService}
+func NewStorageHandler(storageService *services.StorageService, userService *services.UserService, importer *services.URLImporter, thumbnails *services.ThumbnailService, capabilities *services.CapabilityRegistry) *StorageHandler {
+	return &StorageHandler{storageService: storageService, userService: userService, importer: importer, thumbnails: thumbnails, capabilities: capabilities}
 }
 
 // Upload handles POST /api/v1/files/upload
@@ -61,6 +72,63 @@ func (h *StorageHandler) Upload(c *gin.Context) {
 	utils.Success(c, result)
 }
 
+// ImportURL handles POST /api/v1/files/import-url
+// Fetches a PDF or Office file from a public URL and stores it like an upload
+func (h *StorageHandler) ImportURL(c *gin.Context) {
+	var req struct {
+		URL       string `json:"url" binding:"required"`
+		Temporary bool   `json:"temporary"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "url is required")
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	isTemporary := req.Temporary || userID == ""
+
+	// Remote files get the same size limit as uploads on the user's plan
+	plan := "free"
+	if userID != "" {
+		if user, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), userID); err == nil {
+			plan = user.Plan
+		}
+	}
+	maxSize := config.GetMaxFileSizeForPlan(plan)
+
+	file, err := h.importer.Fetch(c.Request.Context(), req.URL, maxSize)
+	switch {
+	case errors.Is(err, services.ErrImportURLInvalid), errors.Is(err, services.ErrImportURLBlocked):
+		utils.BadRequest(c, err.Error())
+		return
+	case errors.Is(err, services.ErrImportTooLarge):
+		utils.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", fmt.Sprintf("Remote file exceeds your plan limit of %d MB", maxSize/(1024*1024)))
+		return
+	case errors.Is(err, services.ErrImportUnsupportedType):
+		utils.Error(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_FILE_TYPE", err.Error())
+		return
+	case err != nil:
+		utils.Error(c, http.StatusBadGateway, "IMPORT_FAILED", err.Error())
+		return
+	}
+
+	result, err := h.storageService.UploadFile(
+		c.Request.Context(),
+		userID,
+		file.Filename,
+		file.ContentType,
+		bytes.NewReader(file.Data),
+		int64(len(file.Data)),
+		isTemporary,
+	)
+	if err != nil {
+		utils.InternalServerError(c, "Upload failed: "+err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
+
 // GetFile handles GET /api/v1/files/:id
 func (h *StorageHandler) GetFile(c *gin.Context) {
 	fileID := c.Param("id")
@@ -87,6 +155,7 @@ func (h *StorageHandler) GetFile(c *gin.Context) {
 		"metadata":     doc.Metadata,
 		"isTemporary":  doc.IsTemporary,
 		"expiresAt":    doc.ExpiresAt,
+		"retention":    h.storageService.RetentionFor(c.Request.Context(), doc),
 		"createdAt":    doc.CreatedAt,
 		"url":          url,
 	})
@@ -101,19 +170,80 @@ func (h *StorageHandler) Download(c *gin.Context) {
 	}
 
 	doc, data, err := h.storageService.GetFile(c.Request.Context(), fileID)
+	if errors.Is(err, services.ErrFileArchived) {
+		respondArchived(c)
+		return
+	}
 	if err != nil {
 		utils.NotFound(c, "File not found")
 		return
 	}
 
 	// Set headers for download
-	c.Header("Content-Disposition", "attachment; filename=\""+doc.OriginalName+"\"")
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", doc.OriginalName))
 	c.Header("Content-Type", doc.MimeType)
 	c.Header("Content-Length", strconv.FormatInt(int64(len(data)), 10))
 
 	c.Data(200, doc.MimeType, data)
 }
 
+// Thumbnail handles GET /api/v1/files/:id/thumbnail/:page
+// Renders one page of a stored PDF as an image. Optional query parameters:
+// dpi (72 by default), format (png or jpeg) and quality for JPEGs.
+func (h *StorageHandler) Thumbnail(c *gin.Context) {
+	page, err := strconv.Atoi(c.Param("page"))
+	if err != nil || page < 1 {
+		utils.BadRequest(c, "page must be a positive integer")
+		return
+	}
+	opts := renderer.Options{Format: c.Query("format")}
+	if v := c.Query("dpi"); v != "" {
+		if opts.DPI, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "dpi must be an integer")
+			return
+		}
+	}
+	if v := c.Query("quality"); v != "" {
+		if opts.Quality, err = strconv.Atoi(v); err != nil {
+			utils.BadRequest(c, "quality must be an integer")
+			return
+		}
+	}
+	if opts, err = renderer.NormalizeOptions(opts); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	_, data, err := h.storageService.GetFileForUser(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrFileArchived) {
+			respondArchived(c)
+			return
+		}
+		if errors.Is(err, services.ErrFileNotFound) || errors.Is(err, services.ErrFileAccessDenied) {
+			utils.NotFound(c, "File not found")
+			return
+		}
+		utils.InternalServerError(c, "Failed to load file: "+err.Error())
+		return
+	}
+
+	thumbs, err := h.thumbnails.Render(c.Request.Context(), bytes.NewReader(data), []int{page}, opts)
+	if err != nil {
+		if errors.Is(err, renderer.ErrInvalidOptions) || errors.Is(err, services.ErrUnreadablePDF) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to render page: "+err.Error())
+		return
+	}
+
+	// A stored file never changes, so its pages can be cached
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, opts.ContentType(), thumbs[0].Data)
+}
+
 // Delete handles DELETE /api/v1/files/:id
 func (h *StorageHandler) Delete(c *gin.Context) {
 	fileID := c.Param("id")
@@ -125,6 +255,10 @@ func (h *StorageHandler) Delete(c *gin.Context) {
 	userID, _ := middleware.GetUserID(c)
 
 	err := h.storageService.DeleteFile(c.Request.Context(), fileID, userID)
+	if errors.Is(err, services.ErrLegalHold) {
+		utils.Error(c, http.StatusLocked, "LEGAL_HOLD", "File is under legal hold and can't be deleted")
+		return
+	}
 	if err != nil {
 		utils.NotFound(c, "File not found or unauthorized")
 		return
@@ -135,6 +269,31 @@ func (h *StorageHandler) Delete(c *gin.Context) {
 	})
 }
 
+// SaveToLibrary handles POST /api/v1/files/:id/save
+// Keeps a temporary upload or operation output in the caller's library
+func (h *StorageHandler) SaveToLibrary(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	result, err := h.storageService.SaveToLibrary(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrFileAccessDenied):
+			utils.NotFound(c, "File not found")
+		case errors.Is(err, services.ErrStorageLimitExceeded):
+			utils.Forbidden(c, "Storage limit exceeded. Please upgrade your plan")
+		default:
+			utils.InternalServerError(c, "Failed to save file: "+err.Error())
+		}
+		return
+	}
+
+	utils.Success(c, result)
+}
+
 // ListLibrary handles GET /api/v1/library
 func (h *StorageHandler) ListLibrary(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -189,8 +348,10 @@ func (h *StorageHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.H
 	files.Use(optionalAuth)
 	{
 		files.POST("/upload", h.Upload)
+		files.POST("/import-url", h.ImportURL)
 		files.GET("/:id", h.GetFile)
 		files.GET("/:id/download", h.Download)
+		files.GET("/:id/thumbnail/:page", middleware.RequireCapability(h.capabilities, services.CapabilityThumbnails), h.Thumbnail)
 	}
 
 	// Protected routes
@@ -198,6 +359,7 @@ func (h *StorageHandler) RegisterRoutes(r *gin.RouterGroup, authMiddleware gin.H
 	filesProtected.Use(authMiddleware)
 	{
 		filesProtected.DELETE("/:id", h.Delete)
+		filesProtected.POST("/:id/save", h.SaveToLibrary)
 	}
 
 	// Library routes (protected)
@@ -276,6 +438,14 @@ func (h *StorageHandler) GetPresignedURL(c *gin.Context) {
 	}
 
 	url, err := h.storageService.GetDownloadURL(c.Request.Context(), fileID)
+	if errors.Is(err, services.ErrFileArchived) {
+		respondArchived(c)
+		return
+	}
+	if errors.Is(err, minio.ErrEncryptedObject) {
+		utils.Error(c, http.StatusConflict, "ENCRYPTED_STORAGE", "Files are encrypted at rest; download them from /api/v1/files/"+fileID+"/download")
+		return
+	}
 	if err != nil {
 		utils.NotFound(c, "File not found")
 		return
@@ -323,7 +493,7 @@ func (h *StorageHandler) Preview(c *gin.Context) {
 	// For PDFs, serve directly for browser preview
 	if doc.MimeType == "application/pdf" {
 		c.Header("Content-Type", "application/pdf")
-		c.Header("Content-Disposition", "inline; filename=\""+doc.OriginalName+"\"")
+		c.Header("Content-Disposition", utils.ContentDisposition("inline", doc.OriginalName))
 		c.Header("Content-Length", strconv.FormatInt(int64(len(data)), 10))
 		
 		c.Writer.WriteHeader(200)
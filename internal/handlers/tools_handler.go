@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ToolsHandler lists the available tools so the UI can render them dynamically
+type ToolsHandler struct {
+	userService  *services.UserService
+	capabilities *services.CapabilityRegistry
+}
+
+// NewToolsHandler creates a new tools handler. Tools whose required
+// capability is unavailable in the registry are reported as unavailable.
+func NewToolsHandler(userService *services.UserService, capabilities *services.CapabilityRegistry) *ToolsHandler {
+	return &ToolsHandler{
+		userService:  userService,
+		capabilities: capabilities,
+	}
+}
+
+// toolInfo is a tool spec plus its availability for the requester
+type toolInfo struct {
+	services.ToolSpec
+	Available         bool   `json:"available"`
+	UnavailableReason string `json:"unavailableReason,omitempty"`
+	MaxFileSize       int64  `json:"maxFileSize"`
+}
+
+// ListTools handles GET /api/v1/tools
+func (h *ToolsHandler) ListTools(c *gin.Context) {
+	plan := "free"
+	if userID, _ := middleware.GetUserID(c); userID != "" {
+		if user, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), userID); err == nil {
+			plan = user.Plan
+		}
+	}
+	maxFileSize := config.GetMaxFileSizeForPlan(plan)
+
+	var tools []toolInfo
+	for _, spec := range services.ToolCatalog() {
+		info := toolInfo{ToolSpec: spec, Available: true, MaxFileSize: maxFileSize}
+		for _, name := range spec.Requires {
+			if capability := h.capabilities.Get(name); !capability.Available {
+				info.Available = false
+				info.UnavailableReason = capability.Reason
+				break
+			}
+		}
+		tools = append(tools, info)
+	}
+
+	utils.Success(c, gin.H{
+		"tools":        tools,
+		"plan":         plan,
+		"capabilities": h.capabilities.All(),
+	})
+}
+
+// RegisterRoutes registers tools routes
+func (h *ToolsHandler) RegisterRoutes(r *gin.RouterGroup, optionalAuthMiddleware gin.HandlerFunc) {
+	r.GET("/tools", optionalAuthMiddleware, h.ListTools)
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/middleware"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler exposes multi-step editing sessions: upload once, apply
+// rotate/delete/reorder edits with undo/redo, then render on commit
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+	pdfService       *services.PDFService
+	storageService   *services.StorageService
+	userService      *services.UserService
+}
+
+// NewWorkspaceHandler creates a new workspace handler
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService, pdfService *services.PDFService, storageService *services.StorageService, userService *services.UserService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		pdfService:       pdfService,
+		storageService:   storageService,
+		userService:      userService,
+	}
+}
+
+// Create handles POST /api/v1/workspace
+func (h *WorkspaceHandler) Create(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "No PDF file provided")
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
+		utils.BadRequest(c, "File must be a PDF")
+		return
+	}
+
+	plan := "free"
+	if userID != "" {
+		if user, err := h.userService.GetUserByFirebaseUID(c.Request.Context(), userID); err == nil {
+			plan = user.Plan
+		}
+	}
+	maxSize := config.GetMaxFileSizeForPlan(plan)
+	if header.Size > maxSize {
+		utils.BadRequest(c, fmt.Sprintf("File size exceeds your plan limit of %d MB", maxSize/(1024*1024)))
+		return
+	}
+
+	in := inputReader(file, header)
+	if err := h.pdfService.ValidatePDF(in); err != nil {
+		utils.BadRequest(c, "Invalid PDF file: "+err.Error())
+		return
+	}
+
+	ws, err := h.workspaceService.Create(c.Request.Context(), userID, header.Filename, in)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to create workspace: "+err.Error())
+		return
+	}
+
+	state, err := h.workspaceService.State(ws)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.SuccessWithStatus(c, http.StatusCreated, state)
+}
+
+// Get handles GET /api/v1/workspace/:id
+func (h *WorkspaceHandler) Get(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	ws, err := h.workspaceService.Get(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	state, err := h.workspaceService.State(ws)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, state)
+}
+
+// AddEdit handles POST /api/v1/workspace/:id/edits
+// Body: {"type": "rotate", "pages": [3], "angle": 90}
+func (h *WorkspaceHandler) AddEdit(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var edit models.WorkspaceEdit
+	if err := c.ShouldBindJSON(&edit); err != nil {
+		utils.BadRequest(c, "Invalid edit: "+err.Error())
+		return
+	}
+
+	state, err := h.workspaceService.AddEdit(c.Request.Context(), c.Param("id"), userID, edit)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, state)
+}
+
+// Undo handles POST /api/v1/workspace/:id/undo
+func (h *WorkspaceHandler) Undo(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	state, err := h.workspaceService.Undo(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, state)
+}
+
+// Redo handles POST /api/v1/workspace/:id/redo
+func (h *WorkspaceHandler) Redo(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	state, err := h.workspaceService.Redo(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, state)
+}
+
+// Commit handles POST /api/v1/workspace/:id/commit
+// Renders the applied edits once and stores the result like any operation output
+func (h *WorkspaceHandler) Commit(c *gin.Context) {
+	startTime := time.Now()
+	userID, _ := middleware.GetUserID(c)
+
+	ws, err := h.workspaceService.Get(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	data, err := h.workspaceService.Render(c.Request.Context(), ws)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to render workspace: "+err.Error())
+		return
+	}
+
+	outputFilename := strings.TrimSuffix(ws.Filename, ".pdf") + "_edited.pdf"
+	uploadResult, err := h.storageService.UploadProcessedFile(c.Request.Context(), userID, outputFilename, data, "")
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save edited PDF: "+err.Error())
+		return
+	}
+
+	pageCount, _ := h.pdfService.GetPageCount(bytes.NewReader(data))
+	res := &models.WorkspaceCommitResult{
+		SingleFileResult: singleFileResult(uploadResult, pageCount),
+		WorkspaceID:      ws.ID,
+		EditsApplied:     ws.Cursor,
+	}
+	res.Operation = "workspace"
+	res.ProcessingMs = time.Since(startTime).Milliseconds()
+
+	utils.Success(c, res)
+}
+
+// Delete handles DELETE /api/v1/workspace/:id
+func (h *WorkspaceHandler) Delete(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.workspaceService.Delete(c.Request.Context(), c.Param("id"), userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"deleted": true})
+}
+
+// respondError maps workspace service errors to responses
+func (h *WorkspaceHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWorkspaceNotFound):
+		utils.NotFound(c, "Workspace not found or expired")
+	case errors.Is(err, services.ErrWorkspaceConflict):
+		utils.Error(c, http.StatusConflict, "CONFLICT", "Workspace was changed by another request; reload and retry")
+	case errors.Is(err, services.ErrInvalidEdit),
+		errors.Is(err, services.ErrNothingToUndo),
+		errors.Is(err, services.ErrNothingToRedo):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, "Workspace error: "+err.Error())
+	}
+}
+
+// RegisterRoutes registers workspace routes
+func (h *WorkspaceHandler) RegisterRoutes(r *gin.RouterGroup, optionalAuthMiddleware gin.HandlerFunc) {
+	workspace := r.Group("/workspace")
+	workspace.Use(optionalAuthMiddleware)
+	{
+		workspace.POST("", h.Create)
+		workspace.GET("/:id", h.Get)
+		workspace.POST("/:id/edits", h.AddEdit)
+		workspace.POST("/:id/undo", h.Undo)
+		workspace.POST("/:id/redo", h.Redo)
+		workspace.POST("/:id/commit", h.Commit)
+		workspace.DELETE("/:id", h.Delete)
+	}
+}
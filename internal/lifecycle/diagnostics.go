@@ -0,0 +1,65 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// LogStartup logs one JSON line describing the process and its
+// configuration, so a service manager's journal shows how it was started.
+// details adds the command's own settings; it must not hold secrets.
+func LogStartup(component string, details map[string]interface{}) {
+	host, _ := os.Hostname()
+	cwd, _ := os.Getwd()
+	diag := map[string]interface{}{
+		"component":      component,
+		"version":        version(),
+		"goVersion":      runtime.Version(),
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"pid":            os.Getpid(),
+		"host":           host,
+		"workingDir":     cwd,
+		"serviceManager": serviceManager(),
+	}
+	for k, v := range details {
+		diag[k] = v
+	}
+
+	data, err := json.Marshal(diag)
+	if err != nil {
+		log.Printf("[Startup] Failed to encode diagnostics: %v", err)
+		return
+	}
+	log.Printf("[Startup] %s", data)
+}
+
+// version is the VCS revision the binary was built from, if recorded
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
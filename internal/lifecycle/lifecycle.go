@@ -0,0 +1,68 @@
+// Package lifecycle runs a command under a service manager: it waits for
+// stop and reload requests (signals, or service control requests on
+// Windows), reports state changes to systemd, maintains a PID file and logs
+// startup diagnostics.
+package lifecycle
+
+import (
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runSignals is Run for a process started from a shell or by systemd:
+// SIGHUP reloads, SIGINT and SIGTERM stop
+func runSignals(reload, shutdown func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		log.Println("[Lifecycle] SIGHUP received, reloading configuration")
+		notify("RELOADING=1")
+		reload()
+		notify("READY=1")
+	}
+
+	notify("STOPPING=1")
+	shutdown()
+}
+
+// Ready tells systemd (Type=notify) that startup has finished. It does
+// nothing when not started by systemd.
+func Ready() {
+	notify("READY=1")
+}
+
+// notify sends state to the systemd notification socket, if any
+func notify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("[Lifecycle] Failed to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("[Lifecycle] Failed to notify systemd: %v", err)
+	}
+}
+
+// serviceManager names what started the process, for diagnostics
+func serviceManager() string {
+	if isWindowsService() {
+		return "windows-service"
+	}
+	if os.Getenv("NOTIFY_SOCKET") != "" || os.Getenv("INVOCATION_ID") != "" {
+		return "systemd"
+	}
+	return "none"
+}
@@ -0,0 +1,31 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WritePIDFile writes the process ID to path and returns a function that
+// removes it again. An empty path writes nothing.
+func WritePIDFile(path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create PID file dir: %w", err)
+	}
+	if old, err := os.ReadFile(path); err == nil {
+		log.Printf("[Lifecycle] Replacing PID file %s left by process %s", path, old)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+	return func() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[Lifecycle] Failed to remove PID file %s: %v", path, err)
+		}
+	}, nil
+}
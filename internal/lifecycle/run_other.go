@@ -0,0 +1,14 @@
+//go:build !windows
+
+package lifecycle
+
+// Run blocks until the process is asked to stop, calling reload for every
+// reload request on the way, then calls shutdown and returns. name is the
+// service name on Windows.
+func Run(name string, reload, shutdown func()) {
+	runSignals(reload, shutdown)
+}
+
+func isWindowsService() bool {
+	return false
+}
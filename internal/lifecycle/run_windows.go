@@ -0,0 +1,56 @@
+//go:build windows
+
+package lifecycle
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// Run blocks until the process is asked to stop, calling reload for every
+// reload request on the way, then calls shutdown and returns. Run as a
+// Windows service named name, a stop or shutdown request stops and a
+// parameter change (sc control <name> paramchange) reloads; otherwise
+// Ctrl+C stops.
+func Run(name string, reload, shutdown func()) {
+	if !isWindowsService() {
+		runSignals(reload, shutdown)
+		return
+	}
+	if err := svc.Run(name, &service{reload: reload, shutdown: shutdown}); err != nil {
+		log.Printf("[Lifecycle] Windows service %s failed: %v", name, err)
+	}
+}
+
+func isWindowsService() bool {
+	is, err := svc.IsWindowsService()
+	return err == nil && is
+}
+
+// service answers the service control manager. The service stays stop
+// pending until shutdown returns, so it isn't killed mid-shutdown.
+type service struct {
+	reload   func()
+	shutdown func()
+}
+
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.ParamChange:
+			log.Println("[Lifecycle] Parameter change received, reloading configuration")
+			s.reload()
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			s.shutdown()
+			return false, 0
+		}
+	}
+	return false, 0
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"net/http"
 	"strings"
 
+	"brainy-pdf/internal/models"
+	"brainy-pdf/internal/services"
 	"brainy-pdf/internal/utils"
 	"brainy-pdf/pkg/firebase"
 	"github.com/gin-gonic/gin"
@@ -18,8 +21,9 @@ const (
 	UserEmailKey ContextKey = "userEmail"
 )
 
-// AuthMiddleware creates a Firebase authentication middleware
-func AuthMiddleware(firebaseClient *firebase.Client) gin.HandlerFunc {
+// AuthMiddleware creates a Firebase authentication middleware. Sign-ins and
+// successful DELETE requests are recorded in the user's activity.
+func AuthMiddleware(firebaseClient *firebase.Client, activity *services.ActivityService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -52,12 +56,13 @@ func AuthMiddleware(firebaseClient *firebase.Client) gin.HandlerFunc {
 			c.Set(string(UserEmailKey), email)
 		}
 
-		c.Next()
+		recordActivity(c, activity, token.UID, token.AuthTime)
 	}
 }
 
-// OptionalAuthMiddleware tries to authenticate but allows unauthenticated requests
-func OptionalAuthMiddleware(firebaseClient *firebase.Client) gin.HandlerFunc {
+// OptionalAuthMiddleware tries to authenticate but allows unauthenticated
+// requests, recording activity like AuthMiddleware when authenticated
+func OptionalAuthMiddleware(firebaseClient *firebase.Client, activity *services.ActivityService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -83,7 +88,33 @@ func OptionalAuthMiddleware(firebaseClient *firebase.Client) gin.HandlerFunc {
 			c.Set(string(UserEmailKey), email)
 		}
 
+		recordActivity(c, activity, token.UID, token.AuthTime)
+	}
+}
+
+// recordActivity records the sign-in behind a verified token, runs the
+// request, and records it when it deleted something
+func recordActivity(c *gin.Context, activity *services.ActivityService, userID string, authTime int64) {
+	if activity == nil {
 		c.Next()
+		return
+	}
+	activity.RecordLogin(c.Request.Context(), userID, authTime, c.ClientIP(), c.Request.UserAgent())
+
+	c.Next()
+
+	if c.Request.Method == http.MethodDelete && c.Writer.Status() < 300 {
+		details := map[string]interface{}{"route": c.FullPath()}
+		for _, p := range c.Params {
+			details[p.Key] = p.Value
+		}
+		activity.Record(c.Request.Context(), models.ActivityEntry{
+			UserID:    userID,
+			Action:    models.ActivityDeleted,
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Details:   details,
+		})
 	}
 }
 
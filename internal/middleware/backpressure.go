@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// BackpressureMiddleware refuses new heavy jobs (non-GET requests under the
+// given path prefixes) with 503 and a Retry-After hint while the resource
+// monitor reports the process as overloaded
+func BackpressureMiddleware(monitor *services.ResourceMonitor, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		heavy := false
+		for _, p := range prefixes {
+			if strings.HasPrefix(c.Request.URL.Path, p) {
+				heavy = true
+				break
+			}
+		}
+		if !heavy {
+			c.Next()
+			return
+		}
+
+		if overloaded, reason := monitor.Overloaded(); overloaded {
+			retryAfter := int(monitor.RetryAfter().Seconds())
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.ErrorWithDetails(c, http.StatusServiceUnavailable, "SERVER_BUSY",
+				"Server is under heavy load, please retry in "+strconv.Itoa(retryAfter)+" seconds", reason)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireCapability responds 503 SERVICE_DISABLED unless every named
+// capability is available
+func RequireCapability(registry *services.CapabilityRegistry, names ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, name := range names {
+			capability := registry.Get(name)
+			if !capability.Available {
+				utils.ServiceDisabled(c, name, capability.Reason)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,20 +1,57 @@
 package middleware
 
 import (
+	"sync/atomic"
 	"time"
 
+	"brainy-pdf/internal/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 // CORSMiddleware creates a CORS middleware with the specified allowed origins
 func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
-	return cors.New(cors.Config{
+	return cors.New(corsConfig(allowedOrigins))
+}
+
+func corsConfig(allowedOrigins []string) cors.Config {
+	return cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", SessionIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", utils.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
-	})
+	}
+}
+
+// ReloadableCORS is CORSMiddleware whose allowed origins can be replaced
+// while the server runs
+type ReloadableCORS struct {
+	handler atomic.Value // gin.HandlerFunc
+}
+
+// NewReloadableCORS creates the middleware with the given allowed origins
+func NewReloadableCORS(allowedOrigins []string) *ReloadableCORS {
+	r := &ReloadableCORS{}
+	r.handler.Store(CORSMiddleware(allowedOrigins))
+	return r
+}
+
+// SetOrigins replaces the allowed origins, keeping the current ones if
+// the new list is invalid
+func (r *ReloadableCORS) SetOrigins(allowedOrigins []string) error {
+	cfg := corsConfig(allowedOrigins)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	r.handler.Store(cors.New(cfg))
+	return nil
+}
+
+// Handler returns the middleware
+func (r *ReloadableCORS) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r.handler.Load().(gin.HandlerFunc)(c)
+	}
 }
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMiddleware refuses writes and processing (non-GET requests)
+// with 503 and a Retry-After hint while maintenance mode is on. Requests
+// under the exempt path prefixes, such as the admin API used to turn it
+// off, go through.
+func MaintenanceMiddleware(maintenance *services.MaintenanceService, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !maintenance.Active() {
+			c.Next()
+			return
+		}
+		for _, p := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, p) {
+				c.Next()
+				return
+			}
+		}
+
+		state := maintenance.State()
+		message := state.Message
+		if message == "" {
+			message = "The service is under maintenance; changes are paused"
+		}
+		c.Header("Retry-After", strconv.Itoa(state.RetryAfterSeconds))
+		utils.Error(c, http.StatusServiceUnavailable, "MAINTENANCE", message)
+		c.Abort()
+	}
+}
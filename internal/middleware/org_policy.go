@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ConfidentialAIGuard refuses AI requests from members of organizations
+// whose policy disables AI for confidential documents when an uploaded file
+// is a copy of a library document tagged confidential. Files are matched by
+// content, so renaming a copy doesn't get it past the check.
+func ConfidentialAIGuard(orgs *services.OrgService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		if userID == "" || !strings.HasPrefix(c.ContentType(), "multipart/") {
+			c.Next()
+			return
+		}
+
+		org, err := orgs.ForUser(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("[Org] Policy lookup for %s failed: %v", userID, err)
+		}
+		if org == nil || !org.Policy.AIDisabledForConfidential {
+			c.Next()
+			return
+		}
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.Next() // The handler reports the malformed upload
+			return
+		}
+		for _, headers := range form.File {
+			for _, header := range headers {
+				f, err := header.Open()
+				if err != nil {
+					continue
+				}
+				hash, err := services.ContentHashReader(f)
+				f.Close()
+				if err != nil {
+					continue
+				}
+
+				confidential, err := orgs.IsConfidential(c.Request.Context(), org, hash)
+				if err != nil {
+					utils.InternalServerError(c, "Failed to check organization policy")
+					c.Abort()
+					return
+				}
+				if confidential {
+					utils.Error(c, http.StatusForbidden, "POLICY_VIOLATION",
+						header.Filename+" is tagged confidential and "+org.Name+" does not allow AI processing of confidential documents")
+					c.Abort()
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"brainy-pdf/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDKey is the key for the request ID in context
+	RequestIDKey ContextKey = "requestId"
+	// SessionIDKey is the key for the client's session ID in context
+	SessionIDKey ContextKey = "sessionId"
+
+	// SessionIDHeader carries an ID the frontend keeps per browser tab, so
+	// a user's requests can be followed across page loads
+	SessionIDHeader = "X-Session-ID"
+)
+
+// RequestIDMiddleware gives every request an ID, taken from X-Request-ID
+// when a proxy already assigned one, and echoes it in the response so it
+// ties together the access log, operation logs and the client's report
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(utils.RequestIDHeader)
+		if !validHeaderID(id) {
+			id = uuid.New().String()
+		}
+		c.Set(string(RequestIDKey), id)
+		c.Header(utils.RequestIDHeader, id)
+
+		if session := c.GetHeader(SessionIDHeader); validHeaderID(session) {
+			c.Set(string(SessionIDKey), session)
+		}
+		c.Next()
+	}
+}
+
+// AccessLogFormatter is gin's access log line with the request ID added
+func AccessLogFormatter(p gin.LogFormatterParams) string {
+	requestID, _ := p.Keys[string(RequestIDKey)].(string)
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %s | %-7s %#v\n%s",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		p.StatusCode,
+		p.Latency.Truncate(time.Microsecond),
+		p.ClientIP,
+		requestID,
+		p.Method,
+		p.Path,
+		p.ErrorMessage,
+	)
+}
+
+// GetRequestID extracts the request ID from context
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(string(RequestIDKey))
+}
+
+// GetSessionID extracts the client's session ID from context
+func GetSessionID(c *gin.Context) string {
+	return c.GetString(string(SessionIDKey))
+}
+
+// validHeaderID accepts client-supplied IDs that are safe to log
+func validHeaderID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"strings"
+
+	minioPkg "brainy-pdf/pkg/minio"
+	"github.com/gin-gonic/gin"
+)
+
+// StorageRegionMiddleware tags each request with the storage region read
+// from header, so presigned URLs made for it point at that region's read
+// replica. The header holds a region name, or a country code mapped to a
+// region by countries, as set by a CDN or load balancer.
+func StorageRegionMiddleware(header string, regions map[string]bool, countries map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := strings.TrimSpace(c.GetHeader(header))
+		region := strings.ToLower(value)
+		if !regions[region] {
+			region = countries[strings.ToUpper(value)]
+		}
+		if regions[region] {
+			c.Request = c.Request.WithContext(minioPkg.WithRegion(c.Request.Context(), region))
+		}
+		c.Next()
+	}
+}
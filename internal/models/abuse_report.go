@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Abuse report reasons
+const (
+	AbuseReasonCopyright  = "copyright"  // DMCA or other infringement notice
+	AbuseReasonMalware    = "malware"    // The file is malicious
+	AbuseReasonPhishing   = "phishing"   // The file or page impersonates someone to steal details
+	AbuseReasonHarassment = "harassment" // Targets or exposes a person
+	AbuseReasonIllegal    = "illegal"    // Other unlawful content
+	AbuseReasonSpam       = "spam"
+	AbuseReasonOther      = "other"
+)
+
+// AbuseReasons are the reasons a link can be reported for
+var AbuseReasons = []string{
+	AbuseReasonCopyright, AbuseReasonMalware, AbuseReasonPhishing,
+	AbuseReasonHarassment, AbuseReasonIllegal, AbuseReasonSpam, AbuseReasonOther,
+}
+
+// Abuse report statuses
+const (
+	AbuseReportOpen      = "open"      // Waiting for an admin
+	AbuseReportDismissed = "dismissed" // Reviewed; no action taken
+	AbuseReportActioned  = "actioned"  // The link or its owner's sharing was disabled
+)
+
+// Actions an admin can resolve an abuse report with
+const (
+	AbuseActionDismiss      = "dismiss"
+	AbuseActionDisableShare = "disable_share" // Disable the reported link
+	AbuseActionDisableUser  = "disable_user"  // Disable every link of its owner and stop them creating more
+)
+
+// Abuse report limits
+const (
+	MaxAbuseDetailsLength = 2000
+)
+
+// AbuseReport is a public report that a share link serves abusive content
+type AbuseReport struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShareCode string             `bson:"shareCode" json:"shareCode"`
+	OwnerID   string             `bson:"ownerId" json:"ownerId"` // Firebase UID of the link's creator
+	Filename  string             `bson:"filename" json:"filename"`
+	Reason    string             `bson:"reason" json:"reason"`
+	Details   string             `bson:"details,omitempty" json:"details,omitempty"`
+	// Optional address the reporter can be contacted at, e.g. for DMCA
+	// counter-notices
+	ReporterEmail string `bson:"reporterEmail,omitempty" json:"reporterEmail,omitempty"`
+	// Hash of the reporter's IP address and user agent, so repeat reports
+	// can be told apart without storing either
+	ReporterHash string `bson:"reporterHash" json:"-"`
+
+	Status     string     `bson:"status" json:"status"`
+	Action     string     `bson:"action,omitempty" json:"action,omitempty"`
+	Note       string     `bson:"note,omitempty" json:"note,omitempty"` // Admin's note, sent to the owner when actioned
+	ReviewedBy string     `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+// Takedown records that an admin disabled a share link, or all of a user's
+// links, after reports of abuse
+type Takedown struct {
+	Reason     string    `bson:"reason" json:"reason"`
+	DisabledBy string    `bson:"disabledBy" json:"-"` // Firebase UID of the admin
+	DisabledAt time.Time `bson:"disabledAt" json:"disabledAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Account activity shown to users
+const (
+	ActivityLogin       = "login"        // Signed in, once per Firebase sign-in
+	ActivityPlanChanged = "plan.changed" // Subscription plan changed, by payment or an admin
+	ActivityDeleted     = "deleted"      // Something deleted through the API
+)
+
+// ActivityEntry is an event on a user's account in user_activity, for the
+// user to review and spot access they don't recognize
+type ActivityEntry struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	UserID    string                 `bson:"userId" json:"-"`
+	Action    string                 `bson:"action" json:"action"`
+	IP        string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string                 `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	AuthTime  int64                  `bson:"authTime,omitempty" json:"-"` // sign-in a login entry records
+	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Archive statuses
+const (
+	ArchiveStatusArchived  = "archived"  // in cold storage; restore to read
+	ArchiveStatusRestoring = "restoring" // being copied back
+)
+
+// Archive records that a document left unread for months was moved to
+// cold storage: the archive bucket, a cheaper storage class, or both.
+// Bucket is where it is copied back to on restore when it was moved.
+type Archive struct {
+	Status             string     `bson:"status" json:"status"`
+	ArchivedAt         time.Time  `bson:"archivedAt" json:"archivedAt"`
+	Bucket             string     `bson:"bucket,omitempty" json:"-"`
+	StorageClass       string     `bson:"storageClass,omitempty" json:"storageClass,omitempty"`
+	RestoreRequestedAt *time.Time `bson:"restoreRequestedAt,omitempty" json:"restoreRequestedAt,omitempty"`
+	ThawRequestedAt    *time.Time `bson:"thawRequestedAt,omitempty" json:"-"` // when S3 was asked to restore the archival class
+	Error              string     `bson:"error,omitempty" json:"error,omitempty"`
+}
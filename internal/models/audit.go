@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions
+const (
+	AuditSharePIIOverride          = "share.pii_override" // Shared despite a sensitive data warning
+	AuditSharePIIBlocked           = "share.pii_blocked"  // Share refused because of sensitive data
+	AuditOrgPolicyUpdated          = "org.policy_updated" // Organization compliance policy changed
+	AuditOrgMemberAdded            = "org.member_added"
+	AuditOrgMemberRemoved          = "org.member_removed"
+	AuditOrgStorageUpdated         = "org.storage_updated"       // Organization's own bucket set or removed
+	AuditOrgBrandingUpdated        = "org.branding_updated"      // Logo, accent color or share domain changed
+	AuditDataKeyRotated            = "encryption.key_rotated"    // A user's data key replaced and files re-encrypted
+	AuditDataKeysRewrapped         = "encryption.keys_rewrapped" // Data keys moved to a new master key
+	AuditLegalHoldSet              = "legal_hold.set"
+	AuditLegalHoldReleased         = "legal_hold.released"
+	AuditMaintenanceChanged        = "maintenance.changed" // Maintenance mode turned on or off
+	AuditStorageMigrationStarted   = "storage_migration.started"
+	AuditStorageMigrationCutover   = "storage_migration.cutover" // Documents switched to the migrated bucket
+	AuditStorageMigrationCancelled = "storage_migration.cancelled"
+	AuditBackupStarted             = "backup.started"
+	AuditAbuseReportResolved       = "abuse_report.resolved"
+	AuditShareDisabled             = "share.disabled" // Link taken down after reports of abuse
+	AuditShareEnabled              = "share.enabled"
+	AuditUserSharingDisabled       = "user.sharing_disabled" // All of a user's links taken down
+	AuditUserSharingEnabled        = "user.sharing_enabled"
+)
+
+// AuditEntry records a security-relevant decision in audit_logs: who did
+// what to which resource, with action-specific details
+type AuditEntry struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ActorID      string                 `bson:"actorId" json:"actorId"`
+	Action       string                 `bson:"action" json:"action"`
+	ResourceType string                 `bson:"resourceType" json:"resourceType"`
+	ResourceID   string                 `bson:"resourceId" json:"resourceId"`
+	Details      map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt    time.Time              `bson:"createdAt" json:"createdAt"`
+}
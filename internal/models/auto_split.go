@@ -0,0 +1,40 @@
+package models
+
+// How a document boundary was found by POST /api/pdf/auto-split
+const (
+	BoundarySourceLayout = "layout"
+	BoundarySourceAI     = "ai"
+)
+
+// DetectedDocument is one document found in a PDF holding several, such as
+// a stack of invoices scanned together. Date is YYYY-MM-DD when it could be
+// read unambiguously, otherwise as printed. Signals name the layout clues
+// that started the document: "page-marker" ("Page 1 of 2"), "title"
+// ("INVOICE" at the top), "document-number" and "header-change".
+type DetectedDocument struct {
+	FirstPage    int      `bson:"firstPage" json:"firstPage"`
+	LastPage     int      `bson:"lastPage" json:"lastPage"`
+	Range        string   `bson:"range" json:"range"`
+	DocumentType string   `bson:"documentType,omitempty" json:"documentType,omitempty"`
+	Vendor       string   `bson:"vendor,omitempty" json:"vendor,omitempty"`
+	Date         string   `bson:"date,omitempty" json:"date,omitempty"`
+	Confidence   float64  `bson:"confidence" json:"confidence"`
+	Source       string   `bson:"source" json:"source"`
+	Signals      []string `bson:"signals,omitempty" json:"signals,omitempty"`
+	Filename     string   `bson:"filename,omitempty" json:"filename,omitempty"`
+	FileID       string   `bson:"fileId,omitempty" json:"fileId,omitempty"`
+}
+
+// AutoSplitResult is returned by POST /api/pdf/auto-split. Files holds one
+// PDF per document unless DryRun is set, when only Documents is filled in.
+type AutoSplitResult struct {
+	OperationResult `bson:",inline"`
+	Files           []OperationOutput  `bson:"-" json:"files"`
+	TotalFiles      int                `bson:"totalFiles" json:"totalFiles"`
+	FailedParts     []FailedPart       `bson:"failedParts,omitempty" json:"failedParts,omitempty"`
+	InputFile       string             `bson:"inputFile" json:"inputFile"`
+	InputPages      int                `bson:"inputPages" json:"inputPages"`
+	AIAssisted      bool               `bson:"aiAssisted" json:"aiAssisted"`
+	DryRun          bool               `bson:"dryRun,omitempty" json:"dryRun,omitempty"`
+	Documents       []DetectedDocument `bson:"documents" json:"documents"`
+}
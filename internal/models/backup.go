@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Backup states
+const (
+	BackupPending   = "pending" // waiting for the backup job
+	BackupRunning   = "running"
+	BackupCompleted = "completed" // manifest uploaded
+	BackupFailed    = "failed"
+)
+
+// Backup is an admin-triggered snapshot of the database and a listing of
+// stored objects, written to the backup bucket under its ID
+type Backup struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Status      string             `bson:"status" json:"status"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	Bucket      string             `bson:"bucket" json:"bucket"`
+	ManifestKey string             `bson:"manifestKey,omitempty" json:"manifestKey,omitempty"`
+
+	Documents int64  `bson:"documents" json:"documents"` // database documents dumped
+	Objects   int64  `bson:"objects" json:"objects"`     // stored objects listed
+	Bytes     int64  `bson:"bytes" json:"bytes"`         // size of the objects listed
+	StartedBy string `bson:"startedBy" json:"startedBy"`
+
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time  `bson:"updatedAt" json:"updatedAt"`
+	CompletedAt *time.Time `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
+
+// BackupManifest describes a backup's files in the backup bucket, with the
+// SHA-256 of each so a restore can check nothing was lost or altered
+type BackupManifest struct {
+	ID        string    `json:"id"`
+	Database  string    `json:"database"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Snapshot is true when every collection was read at the same point in
+	// time, which needs a replica set
+	Snapshot bool `json:"snapshot"`
+	// Maintenance is true when maintenance mode was on when the backup
+	// started and finished, so objects can't have changed in between
+	Maintenance bool               `json:"maintenance"`
+	Collections []BackupCollection `json:"collections"`
+	Buckets     []BackupBucket     `json:"buckets"`
+}
+
+// BackupCollection is a collection dumped as gzipped extended JSON lines
+type BackupCollection struct {
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+	Documents int64  `json:"documents"`
+	SHA256    string `json:"sha256"` // of the dump file
+}
+
+// BackupBucket is a bucket's object listing, gzipped JSON lines of
+// BackupObject
+type BackupBucket struct {
+	Name    string `json:"name"`
+	Key     string `json:"key"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"` // of the listing file
+}
+
+// BackupObject is a stored object as listed in a backup. The hash is of
+// the bytes as stored, so encrypted files verify without their keys.
+type BackupObject struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"` // unset for objects in archival storage classes, which can't be read
+}
+
+// BackupVerification reports how a backup compares with what it lists
+type BackupVerification struct {
+	Collections int      `json:"collections"` // dump files checked
+	Objects     int64    `json:"objects"`     // stored objects checked
+	Missing     []string `json:"missing,omitempty"`
+	Changed     []string `json:"changed,omitempty"` // objects whose contents differ
+	Corrupt     []string `json:"corrupt,omitempty"` // backup files that don't match the manifest
+}
+
+// OK reports whether everything listed was found intact
+func (v *BackupVerification) OK() bool {
+	return len(v.Missing) == 0 && len(v.Changed) == 0 && len(v.Corrupt) == 0
+}
@@ -0,0 +1,48 @@
+package models
+
+// Contract review risk levels
+const (
+	ContractRiskLow    = "low"
+	ContractRiskMedium = "medium"
+	ContractRiskHigh   = "high"
+)
+
+// ContractParty is a party to the contract and the pages naming it
+type ContractParty struct {
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"` // e.g. Supplier, Customer, Landlord
+	Pages []int  `json:"pages"`
+}
+
+// ContractDate is a date the contract sets, such as the effective date, a
+// term end or a notice deadline
+type ContractDate struct {
+	Label string `json:"label"`
+	Date  string `json:"date"` // As written in the contract
+	Pages []int  `json:"pages"`
+}
+
+// ContractClause is a clause worth a reader's attention. Quote is a short
+// excerpt of the contract wording.
+type ContractClause struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Risk    string `json:"risk"` // low, medium, high
+	Quote   string `json:"quote,omitempty"`
+	Pages   []int  `json:"pages"`
+}
+
+// ContractReview is returned by POST /api/v1/ai/contract-review. Risks are
+// the main concerns for the reader, most serious first.
+type ContractReview struct {
+	PageCount    int              `json:"pageCount"`
+	RiskLevel    string           `json:"riskLevel"`
+	Summary      string           `json:"summary"`
+	Risks        []string         `json:"risks"`
+	Parties      []ContractParty  `json:"parties"`
+	Dates        []ContractDate   `json:"dates"`
+	Renewal      []ContractClause `json:"renewal"`
+	Termination  []ContractClause `json:"termination"`
+	Liabilities  []ContractClause `json:"liabilities"`
+	UnusualTerms []ContractClause `json:"unusualTerms"`
+}
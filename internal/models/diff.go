@@ -0,0 +1,45 @@
+package models
+
+// Kinds of text change between two document versions
+const (
+	TextAdded   = "added"
+	TextRemoved = "removed"
+	TextChanged = "changed"
+)
+
+// TextChange is one run of differing sentences. PageBefore and PageAfter
+// locate it in the original and revised versions; for added or removed
+// text they are the page where it would have been.
+type TextChange struct {
+	Type       string `json:"type"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+	PageBefore int    `json:"pageBefore"`
+	PageAfter  int    `json:"pageAfter"`
+}
+
+// MaterialChange is a change the AI judged to matter: one that alters
+// rights, obligations, amounts, dates or scope. Pages are in the revised
+// version.
+type MaterialChange struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Impact      string `json:"impact"` // low, medium, high
+	Pages       []int  `json:"pages"`
+}
+
+// DiffSummary is returned by POST /api/v1/ai/diff-summary. Changes holds
+// the text diff itself, cut off after the first few hundred changes when
+// Truncated is set.
+type DiffSummary struct {
+	PageCountBefore int              `json:"pageCountBefore"`
+	PageCountAfter  int              `json:"pageCountAfter"`
+	Identical       bool             `json:"identical"`
+	Added           int              `json:"added"`
+	Removed         int              `json:"removed"`
+	Changed         int              `json:"changed"`
+	Summary         string           `json:"summary"`
+	MaterialChanges []MaterialChange `json:"materialChanges"`
+	Changes         []TextChange     `json:"changes"`
+	Truncated       bool             `json:"truncated"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// How the page in a photo was flattened by POST /api/pdf/scan-document
+const (
+	ScanCorrectionDetected = "detected" // page edges found automatically
+	ScanCorrectionManual   = "manual"   // corners sent by the client
+	ScanCorrectionNone     = "none"     // no page found; the whole photo is kept
+)
+
+// ScanPoint is a pixel position in a photo, measured from its top-left
+// corner after EXIF rotation, as the photo is displayed
+type ScanPoint struct {
+	X float64 `bson:"x" json:"x"`
+	Y float64 `bson:"y" json:"y"`
+}
+
+// ScannedPage describes how one photo became a page. Corners are the
+// page's corners in the photo: top-left, top-right, bottom-right,
+// bottom-left.
+type ScannedPage struct {
+	Page       int         `bson:"page" json:"page"`
+	Correction string      `bson:"correction" json:"correction"`
+	Corners    []ScanPoint `bson:"corners" json:"corners"`
+	Width      int         `bson:"width" json:"width"` // Corrected page, in pixels
+	Height     int         `bson:"height" json:"height"`
+}
+
+// DocumentScanResult is returned by POST /api/pdf/scan-document
+type DocumentScanResult struct {
+	SingleFileResult `bson:",inline"`
+	Mode             string        `bson:"mode" json:"mode"`
+	PageSize         string        `bson:"pageSize" json:"pageSize"`
+	Pages            []ScannedPage `bson:"pages" json:"pages"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// DataKey is one version of a user's data encryption key, stored wrapped
+// by the master key. Its ID, "<userId>:<version>", is written into every
+// object it encrypts, so older versions stay readable after a rotation.
+type DataKey struct {
+	ID          string     `bson:"_id" json:"id"`
+	UserID      string     `bson:"userId" json:"userId"`
+	Version     int        `bson:"version" json:"version"`
+	WrappedKey  []byte     `bson:"wrappedKey" json:"-"`
+	MasterKeyID string     `bson:"masterKeyId" json:"masterKeyId"`
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+	RetiredAt   *time.Time `bson:"retiredAt,omitempty" json:"retiredAt,omitempty"`
+}
+
+// KeyRotationResult reports a user data key rotation
+type KeyRotationResult struct {
+	UserID      string `json:"userId"`
+	Version     int    `json:"version"`
+	Reencrypted int    `json:"reencrypted"` // objects rewritten under the new key
+	Failed      int    `json:"failed"`      // objects left under an older key
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Entity types
+const (
+	EntityPerson        = "person"
+	EntityCompany       = "company"
+	EntityInvoiceNumber = "invoice_number"
+	EntityDate          = "date"
+)
+
+// Entity index statuses
+const (
+	EntityIndexPending = "pending" // queued or being read
+	EntityIndexDone    = "indexed" // entities are in the index
+	EntityIndexError   = "error"   // the document could not be read
+)
+
+// EntityIndex records whether a library document's entities are in the
+// index. Source is "ai" when names were extracted by the AI model and
+// "pattern" when only pattern matching was used.
+type EntityIndex struct {
+	Status      string     `bson:"status" json:"status"`
+	Entities    int        `bson:"entities" json:"entities"`
+	Source      string     `bson:"source,omitempty" json:"source,omitempty"`
+	Error       string     `bson:"error,omitempty" json:"error,omitempty"`
+	RequestedAt time.Time  `bson:"requestedAt" json:"requestedAt"`
+	IndexedAt   *time.Time `bson:"indexedAt,omitempty" json:"indexedAt,omitempty"`
+}
+
+// DocumentEntity is one entity mentioned in a library document. Key is the
+// value normalized for matching, so "ACME Corp." and "Acme Corporation"
+// share a key; dates are keyed YYYY-MM-DD.
+type DocumentEntity struct {
+	UserID     string    `bson:"userId" json:"-"`
+	DocumentID string    `bson:"documentId" json:"documentId"`
+	Type       string    `bson:"type" json:"type"`
+	Value      string    `bson:"value" json:"value"`
+	Key        string    `bson:"key" json:"key"`
+	Pages      []int     `bson:"pages" json:"pages"`
+	Mentions   int       `bson:"mentions" json:"mentions"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// LibraryEntity is an entity across a user's library, with the documents
+// mentioning it, most recent first
+type LibraryEntity struct {
+	Type        string   `bson:"type" json:"type"`
+	Value       string   `bson:"value" json:"value"`
+	Key         string   `bson:"key" json:"key"`
+	Documents   int      `bson:"documents" json:"documents"`
+	Mentions    int      `bson:"mentions" json:"mentions"`
+	DocumentIDs []string `bson:"documentIds" json:"documentIds"`
+}
+
+// EntityIndexProgress counts library documents by entity index status, so
+// clients can tell when results are still filling in
+type EntityIndexProgress struct {
+	Indexed int64 `json:"indexed"`
+	Pending int64 `json:"pending"`
+	Failed  int64 `json:"failed"`
+}
+
+// LibraryEntityList is returned by GET /api/v1/library/entities
+type LibraryEntityList struct {
+	Entities []LibraryEntity     `json:"entities"`
+	Index    EntityIndexProgress `json:"index"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Estimate bases, from most to least specific
+const (
+	EstimateBasisSimilar   = "similar"   // past runs of about the same size and pages
+	EstimateBasisPages     = "pages"     // time per page of past runs, times the pages
+	EstimateBasisSize      = "size"      // time per MB of past runs, times the size
+	EstimateBasisOperation = "operation" // every past run, whatever its size
+	EstimateBasisNone      = "none"      // no history yet
+)
+
+// EstimateRange is a duration in milliseconds at the median and the 90th
+// percentile
+type EstimateRange struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+}
+
+// OperationEstimate is how long an operation is expected to take, drawn
+// from its recent successful runs. Queued operations also report the jobs
+// ahead and the wait behind them.
+type OperationEstimate struct {
+	Operation   string         `json:"operation"`
+	Size        int64          `json:"size,omitempty"`
+	Pages       int            `json:"pages,omitempty"`
+	Basis       string         `json:"basis"`
+	Samples     int            `json:"samples"`
+	DurationMs  EstimateRange  `json:"durationMs"`
+	Queued      bool           `json:"queued"`
+	QueueDepth  int64          `json:"queueDepth,omitempty"`
+	QueueWaitMs *EstimateRange `json:"queueWaitMs,omitempty"`
+	TotalMs     EstimateRange  `json:"totalMs"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields a folder rule condition can test
+const (
+	RuleFieldFilename     = "filename"     // the uploaded file's name
+	RuleFieldDocumentType = "documentType" // invoice, receipt, statement... read from the first page
+	RuleFieldMimeType     = "mimeType"
+)
+
+// Folder rule condition operators; all ignore case
+const (
+	RuleOpContains   = "contains"
+	RuleOpEquals     = "equals"
+	RuleOpStartsWith = "startsWith"
+	RuleOpEndsWith   = "endsWith"
+)
+
+// Folder rule match modes
+const (
+	RuleMatchAny = "any"
+	RuleMatchAll = "all"
+)
+
+// RuleCondition tests one field of an uploaded file
+type RuleCondition struct {
+	Field    string `bson:"field" json:"field"`
+	Operator string `bson:"operator" json:"operator"`
+	Value    string `bson:"value" json:"value"`
+}
+
+// RuleActions is what a matching rule does to the file. Folder is a path
+// such as "/Finance/2024"; missing folders are created.
+type RuleActions struct {
+	Folder string   `bson:"folder,omitempty" json:"folder,omitempty"`
+	Tags   []string `bson:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// FolderRule files a user's uploads into a folder and tags them when they
+// match. Rules run in Position order; Match is "any" or "all" of the
+// conditions.
+type FolderRule struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerUID   string             `bson:"ownerUid" json:"-"`
+	Name       string             `bson:"name" json:"name"`
+	Enabled    bool               `bson:"enabled" json:"enabled"`
+	Position   int                `bson:"position" json:"position"`
+	Match      string             `bson:"match" json:"match"`
+	Conditions []RuleCondition    `bson:"conditions" json:"conditions"`
+	Actions    RuleActions        `bson:"actions" json:"actions"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// RuleFacts are the fields of a file that rules are tested against
+type RuleFacts struct {
+	Filename     string `json:"filename"`
+	DocumentType string `json:"documentType,omitempty"`
+	MimeType     string `json:"mimeType,omitempty"`
+}
+
+// RuleMatch names a rule that matched a file
+type RuleMatch struct {
+	RuleID string `bson:"ruleId" json:"ruleId"`
+	Name   string `bson:"name" json:"name"`
+}
+
+// RuleOutcome is the result of running a user's rules on a file: the
+// folder of the first matching rule that names one and the tags of every
+// matching rule
+type RuleOutcome struct {
+	Facts   RuleFacts   `json:"facts"`
+	Matched []RuleMatch `json:"matched"`
+	Folder  string      `json:"folder,omitempty"`
+	Tags    []string    `json:"tags"`
+}
+
+// AutoFiling records the rules applied to a document on upload
+type AutoFiling struct {
+	Rules    []RuleMatch        `bson:"rules" json:"rules"`
+	Folder   string             `bson:"folder,omitempty" json:"folder,omitempty"`
+	FolderID primitive.ObjectID `bson:"folderId,omitempty" json:"folderId,omitempty"`
+	Tags     []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	FiledAt  time.Time          `bson:"filedAt" json:"filedAt"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// Form field types
+const (
+	FormFieldText     = "text"
+	FormFieldDate     = "date"
+	FormFieldCheckBox = "checkbox"
+	FormFieldRadio    = "radio"    // Radio button group; Value is the selected option
+	FormFieldComboBox = "combobox" // Drop-down; Editable ones take any text
+	FormFieldListBox  = "listbox"  // Value is a list; Multi ones allow several
+)
+
+// FormField is an AcroForm field of a PDF. Value is a string, a bool for
+// checkboxes or a list of strings for list boxes.
+type FormField struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name,omitempty"`
+	Type      string      `json:"type"`
+	Pages     []int       `json:"pages"`
+	Value     interface{} `json:"value"`
+	Options   []string    `json:"options,omitempty"` // Choices of radio, combo and list boxes
+	Format    string      `json:"format,omitempty"`  // Date format, e.g. yyyy-mm-dd
+	Multiline bool        `json:"multiline,omitempty"`
+	Multi     bool        `json:"multi,omitempty"`
+	Editable  bool        `json:"editable,omitempty"`
+	Locked    bool        `json:"locked"`
+}
+
+// FormFieldsResult is returned by POST /api/pdf/form/fields
+type FormFieldsResult struct {
+	FileID    string      `json:"fileId,omitempty"`
+	PageCount int         `json:"pageCount"`
+	Fields    []FormField `json:"fields"`
+}
+
+// FormFillResult is returned by POST /api/pdf/form/fill
+type FormFillResult struct {
+	SingleFileResult `bson:",inline"`
+	Filled           []string `bson:"filled" json:"filled"` // Names (or IDs) of the fields set
+	Flattened        bool     `bson:"flattened" json:"flattened"`
+}
+
+// FormFlattenResult is returned by POST /api/pdf/form/flatten
+type FormFlattenResult struct {
+	SingleFileResult `bson:",inline"`
+	Widgets          int `bson:"widgets" json:"widgets"` // Field widgets burned into page content
+}
@@ -0,0 +1,9 @@
+package models
+
+// FromImagesResult is returned by POST /api/pdf/from-images
+type FromImagesResult struct {
+	SingleFileResult `bson:",inline"`
+	PageSize         string  `bson:"pageSize" json:"pageSize"`
+	Orientation      string  `bson:"orientation" json:"orientation"`
+	Margin           float64 `bson:"margin" json:"margin"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// LegalHold preserves a file for litigation or an investigation: until an
+// organization admin releases it, neither its owner nor cleanup or
+// retention can delete it
+type LegalHold struct {
+	OrgID  string    `bson:"orgId" json:"orgId"`
+	SetBy  string    `bson:"setBy" json:"setBy"` // Firebase UID of the admin
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	SetAt  time.Time `bson:"setAt" json:"setAt"`
+}
+
+// Kinds of held items
+const (
+	HeldItemFile    = "file"    // a stored file or operation output
+	HeldItemLibrary = "library" // a library document
+)
+
+// HeldItem is a file under legal hold
+type HeldItem struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"` // file, library
+	OwnerUID  string     `json:"ownerUid"`
+	FileName  string     `json:"fileName"`
+	Size      int64      `json:"size"`
+	LegalHold *LegalHold `json:"legalHold"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Library note sources
+const (
+	NoteSourceVoice = "voice"
+)
+
+// LibraryNote is a comment attached to a document in a user's library. Voice
+// notes keep the recording alongside its transcript; the transcript is what
+// library search matches.
+type LibraryNote struct {
+	ID         string    `bson:"_id" json:"id"`
+	DocumentID string    `bson:"documentId" json:"documentId"`
+	UserID     string    `bson:"userId" json:"-"`
+	Source     string    `bson:"source" json:"source"`
+	Text       string    `bson:"text" json:"text"`
+	Page       int       `bson:"page,omitempty" json:"page,omitempty"` // 0 for the whole document
+	Language   string    `bson:"language,omitempty" json:"language,omitempty"`
+	AudioName  string    `bson:"audioName,omitempty" json:"audioName,omitempty"`
+	AudioKey   string    `bson:"audioKey,omitempty" json:"-"`
+	AudioSize  int64     `bson:"audioSize,omitempty" json:"audioSize,omitempty"`
+	AudioURL   string    `bson:"-" json:"audioUrl,omitempty"`
+	Duration   float64   `bson:"duration,omitempty" json:"duration,omitempty"` // seconds
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+}
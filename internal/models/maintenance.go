@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// MaintenanceState is the deployment-wide maintenance switch. While
+// enabled, reads keep working but writes and processing are refused and
+// queued jobs wait.
+type MaintenanceState struct {
+	Enabled           bool       `bson:"enabled" json:"enabled"`
+	Message           string     `bson:"message,omitempty" json:"message,omitempty"`
+	RetryAfterSeconds int        `bson:"retryAfterSeconds" json:"retryAfterSeconds"` // hint sent in Retry-After
+	StartedBy         string     `bson:"startedBy,omitempty" json:"startedBy,omitempty"`
+	StartedAt         *time.Time `bson:"startedAt,omitempty" json:"startedAt,omitempty"`
+	UpdatedAt         time.Time  `bson:"updatedAt" json:"updatedAt"`
+}
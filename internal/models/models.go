@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +20,8 @@ type User struct {
 	StorageLimit int64             `bson:"storageLimit" json:"storageLimit"`
 	AIChatCount  int               `bson:"aiChatCount" json:"aiChatCount"`
 	ToolkitCount int               `bson:"toolkitCount" json:"toolkitCount"`
+	FilenameTemplate string        `bson:"filenameTemplate,omitempty" json:"filenameTemplate,omitempty"` // default for output filenames
+	SharingDisabled  *Takedown     `bson:"sharingDisabled,omitempty" json:"sharingDisabled,omitempty"` // set when an admin disabled the user's share links
 	LastReset    time.Time          `bson:"lastReset" json:"lastReset"`
 	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
@@ -28,19 +31,52 @@ type User struct {
 type Document struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID       primitive.ObjectID `bson:"userId,omitempty" json:"userId"`
+	OwnerUID     string             `bson:"ownerUid,omitempty" json:"-"` // Firebase UID of the owner
 	Filename     string             `bson:"filename" json:"filename"`
 	OriginalName string             `bson:"originalName" json:"originalName"`
 	MimeType     string             `bson:"mimeType" json:"mimeType"`
 	Size         int64              `bson:"size" json:"size"`
-	MinIOPath    string             `bson:"minioPath" json:"minioPath"`
+	Bucket       string             `bson:"bucket,omitempty" json:"-"`    // bucket holding the file; read with Location
+	ObjectKey    string             `bson:"objectKey,omitempty" json:"-"` // object key within Bucket
+	MinIOPath    string             `bson:"minioPath" json:"minioPath"`   // "bucket/key", kept for older readers
+	StorageID    string             `bson:"storageId,omitempty" json:"-"` // organization whose bucket holds the file; empty for platform storage
+	Source       string             `bson:"source,omitempty" json:"source,omitempty"` // library for library uploads; empty for uploads and outputs
 	FolderID     primitive.ObjectID `bson:"folderId,omitempty" json:"folderId,omitempty"`
 	Metadata     DocumentMetadata   `bson:"metadata" json:"metadata"`
 	IsTemporary  bool               `bson:"isTemporary" json:"isTemporary"`
 	ExpiresAt    *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	ContentHash  string             `bson:"contentHash,omitempty" json:"-"` // matches uploads against confidential documents
+	LegalHold    *LegalHold         `bson:"legalHold,omitempty" json:"legalHold,omitempty"`
+	VirusScan    *VirusScan         `bson:"virusScan,omitempty" json:"virusScan,omitempty"`
+	EntityIndex  *EntityIndex       `bson:"entityIndex,omitempty" json:"entityIndex,omitempty"`
+	AutoFiled    *AutoFiling        `bson:"autoFiled,omitempty" json:"autoFiled,omitempty"`
+	Archive      *Archive           `bson:"archive,omitempty" json:"archive,omitempty"`
+	LastAccessedAt *time.Time       `bson:"lastAccessedAt,omitempty" json:"lastAccessedAt,omitempty"` // last opened by the owner, to the day
 	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
+// DocumentSourceLibrary marks documents uploaded to the library rather
+// than kept from an upload or operation output
+const DocumentSourceLibrary = "library"
+
+// Location returns the bucket and object key holding the file. Records
+// stored before Bucket and ObjectKey existed are read from MinIOPath.
+func (d *Document) Location() (bucket, objectKey string) {
+	if d.Bucket != "" {
+		return d.Bucket, d.ObjectKey
+	}
+	bucket, objectKey, _ = strings.Cut(d.MinIOPath, "/")
+	return bucket, objectKey
+}
+
+// SetLocation records where the file is stored
+func (d *Document) SetLocation(bucket, objectKey string) {
+	d.Bucket = bucket
+	d.ObjectKey = objectKey
+	d.MinIOPath = bucket + "/" + objectKey
+}
+
 // DocumentMetadata holds PDF-specific metadata
 type DocumentMetadata struct {
 	PageCount int      `bson:"pageCount" json:"pageCount"`
@@ -53,6 +89,7 @@ type DocumentMetadata struct {
 type Folder struct {
 	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
 	UserID    primitive.ObjectID  `bson:"userId" json:"userId"`
+	OwnerUID  string              `bson:"ownerUid,omitempty" json:"-"` // Firebase UID of the owner
 	Name      string              `bson:"name" json:"name"`
 	ParentID  *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
 	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
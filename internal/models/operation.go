@@ -0,0 +1,331 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Operation status values
+const (
+	OperationStatusSuccess = "success"
+	OperationStatusError   = "error"
+)
+
+// OperationOutput is a file produced by an operation
+type OperationOutput struct {
+	FileID    string     `bson:"fileId" json:"fileId"`
+	URL       string     `bson:"-" json:"url"` // presigned, so never persisted
+	Filename  string     `bson:"filename" json:"filename"`
+	PageCount int        `bson:"pageCount" json:"pageCount"`
+	Size      int64      `bson:"size" json:"size"`
+	Range     string     `bson:"range,omitempty" json:"range,omitempty"`
+	Retention *Retention `bson:"-" json:"retention,omitempty"` // changes once saved, so never persisted
+}
+
+// Retention says how long a stored file is kept. Temporary files are
+// deleted at ExpiresAt unless saved to the library by POSTing to SaveURL,
+// which requires signing in when RequiresSignIn is set.
+type Retention struct {
+	Temporary      bool       `json:"temporary"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	Plan           string     `json:"plan,omitempty"`
+	RetentionDays  int        `json:"retentionDays,omitempty"` // The plan's retention for outputs
+	SaveURL        string     `json:"saveUrl,omitempty"`
+	RequiresSignIn bool       `json:"requiresSignIn,omitempty"`
+}
+
+// OperationLog is a PDF operation recorded in operation_logs. Result holds
+// the operation's typed result (one of the *Result structs below) and
+// InputBytes the size of the request that ran it.
+type OperationLog struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"userId,omitempty" json:"-"`
+	Operation    string             `bson:"operation" json:"operation"`
+	InputFiles   []string           `bson:"inputFiles" json:"inputFiles"`
+	OutputFileID string             `bson:"outputFileId,omitempty" json:"outputFileId,omitempty"`
+	OutputFiles  []string           `bson:"outputFiles,omitempty" json:"outputFiles,omitempty"`
+	Outputs      []OperationOutput  `bson:"outputs,omitempty" json:"outputs,omitempty"`
+	Result       interface{}        `bson:"result,omitempty" json:"result,omitempty"`
+	PageCount    int                `bson:"pageCount,omitempty" json:"pageCount,omitempty"`
+	InputBytes   int64              `bson:"inputBytes,omitempty" json:"inputBytes,omitempty"`
+	Status       string             `bson:"status" json:"status"` // success, error
+	ErrorMessage string             `bson:"errorMessage,omitempty" json:"errorMessage,omitempty"`
+	ProcessingMs int64              `bson:"processingMs" json:"processingMs"`
+	// Correlate the operation with the access log and the client session
+	RequestID string    `bson:"requestId,omitempty" json:"requestId,omitempty"`
+	SessionID string    `bson:"sessionId,omitempty" json:"-"`
+	IP        string    `bson:"ip,omitempty" json:"-"`
+	UserAgent string    `bson:"userAgent,omitempty" json:"-"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// OperationResult holds the fields every operation response carries
+type OperationResult struct {
+	OperationID  string            `bson:"-" json:"operationId,omitempty"`
+	Operation    string            `bson:"operation" json:"operation"`
+	Outputs      []OperationOutput `bson:"-" json:"outputs"`
+	ProcessingMs int64             `bson:"processingMs" json:"processingMs"`
+}
+
+// SetOutputs records the files an operation produced
+func (r *OperationResult) SetOutputs(outputs ...OperationOutput) {
+	r.Outputs = outputs
+}
+
+// Base returns the common fields; implemented by every *Result type
+func (r *OperationResult) Base() *OperationResult {
+	return r
+}
+
+// SingleFileResult is the result of operations producing one PDF. The
+// output's fields are flattened into the response for convenience.
+type SingleFileResult struct {
+	OperationResult `bson:",inline"`
+	OperationOutput `bson:",inline"`
+}
+
+// MergeResult is returned by POST /api/pdf/merge
+type MergeResult struct {
+	SingleFileResult `bson:",inline"`
+	InputFiles       int `bson:"inputFiles" json:"inputFiles"`
+}
+
+// FailedPart is a split part that could not be stored
+type FailedPart struct {
+	Range    string `bson:"range" json:"range"`
+	Filename string `bson:"filename" json:"filename"`
+	Attempts int    `bson:"attempts" json:"attempts"`
+	Error    string `bson:"error" json:"error"`
+}
+
+// SplitResult is returned by POST /api/pdf/split. Parts that still failed
+// to upload after retrying are listed in FailedParts rather than dropped.
+type SplitResult struct {
+	OperationResult `bson:",inline"`
+	Files           []OperationOutput `bson:"-" json:"files"`
+	TotalFiles      int               `bson:"totalFiles" json:"totalFiles"`
+	FailedParts     []FailedPart      `bson:"failedParts,omitempty" json:"failedParts,omitempty"`
+	InputFile       string            `bson:"inputFile" json:"inputFile"`
+	InputPages      int               `bson:"inputPages" json:"inputPages"`
+	LargeFileMode   bool              `bson:"largeFileMode,omitempty" json:"largeFileMode,omitempty"`
+}
+
+// RotateResult is returned by POST /api/pdf/rotate
+type RotateResult struct {
+	SingleFileResult `bson:",inline"`
+	Angle            int            `bson:"angle" json:"angle"`                             // 0 for per-page rotations
+	Rotations        map[string]int `bson:"rotations,omitempty" json:"rotations,omitempty"` // angle by page number
+	LargeFileMode    bool           `bson:"largeFileMode,omitempty" json:"largeFileMode,omitempty"`
+}
+
+// CompressResult is returned by POST /api/pdf/compress
+type CompressResult struct {
+	SingleFileResult `bson:",inline"`
+	OriginalSize     int64             `bson:"originalSize" json:"originalSize"`
+	CompressedSize   int64             `bson:"compressedSize" json:"compressedSize"`
+	Reduction        string            `bson:"reduction" json:"reduction"`
+	Quality          string            `bson:"quality" json:"quality"`
+	Mode             string            `bson:"mode" json:"mode"` // standard or photo
+	LargeFileMode    bool              `bson:"largeFileMode,omitempty" json:"largeFileMode,omitempty"`
+	ImageProfile     *ImageProfile     `bson:"imageProfile,omitempty" json:"imageProfile,omitempty"`
+	Photo            *PhotoCompression `bson:"photo,omitempty" json:"photo,omitempty"`
+}
+
+// ImageProfile measures how much of a PDF is image data, from the stored
+// bytes of its image XObjects
+type ImageProfile struct {
+	TotalBytes    int64   `bson:"totalBytes" json:"totalBytes"`
+	ImageBytes    int64   `bson:"imageBytes" json:"imageBytes"`
+	JPEGBytes     int64   `bson:"jpegBytes" json:"jpegBytes"`
+	Images        int     `bson:"images" json:"images"`
+	JPEGImages    int     `bson:"jpegImages" json:"jpegImages"`
+	ImageShare    float64 `bson:"imageShare" json:"imageShare"` // ImageBytes / TotalBytes, 0-1
+	ImageHeavy    bool    `bson:"imageHeavy" json:"imageHeavy"`
+	SuggestedMode string  `bson:"suggestedMode" json:"suggestedMode"` // photo when image-heavy with JPEGs to recompress
+}
+
+// PhotoCompression reports what photo-mode compression did to a PDF's
+// JPEG images or, on a dry run, would do
+type PhotoCompression struct {
+	DPI                int    `bson:"dpi" json:"dpi"`
+	JPEGQuality        int    `bson:"jpegQuality" json:"jpegQuality"`
+	ImagesRecompressed int    `bson:"imagesRecompressed" json:"imagesRecompressed"`
+	ImagesDownsampled  int    `bson:"imagesDownsampled" json:"imagesDownsampled"`
+	ImagesSkipped      int    `bson:"imagesSkipped" json:"imagesSkipped"` // not JPEG, CMYK, or no smaller when recompressed
+	ImageBytesBefore   int64  `bson:"imageBytesBefore" json:"imageBytesBefore"`
+	ImageBytesAfter    int64  `bson:"imageBytesAfter" json:"imageBytesAfter"`
+	EstimatedSavings   int64  `bson:"estimatedSavings" json:"estimatedSavings"`
+	EstimatedReduction string `bson:"estimatedReduction" json:"estimatedReduction"`
+}
+
+// CompressEstimate is returned by POST /api/pdf/compress with dryRun=true.
+// Nothing is stored.
+type CompressEstimate struct {
+	Filename           string            `json:"filename"`
+	Mode               string            `json:"mode"`
+	Quality            string            `json:"quality"`
+	OriginalSize       int64             `json:"originalSize"`
+	EstimatedSize      int64             `json:"estimatedSize"`
+	EstimatedReduction string            `json:"estimatedReduction"`
+	ImageProfile       *ImageProfile     `json:"imageProfile,omitempty"`
+	Photo              *PhotoCompression `json:"photo,omitempty"`
+	DryRun             bool              `json:"dryRun"`
+}
+
+// CropMargins are the margins removed from each page, in points
+type CropMargins struct {
+	Top    float64 `bson:"top" json:"top"`
+	Right  float64 `bson:"right" json:"right"`
+	Bottom float64 `bson:"bottom" json:"bottom"`
+	Left   float64 `bson:"left" json:"left"`
+}
+
+// CropResult is returned by POST /api/pdf/crop
+type CropResult struct {
+	SingleFileResult `bson:",inline"`
+	Margins          CropMargins `bson:"margins" json:"margins"`
+}
+
+// WatermarkSettings echoes the applied watermark
+type WatermarkSettings struct {
+	Type     string  `bson:"type" json:"type"` // text or image
+	Text     string  `bson:"text" json:"text"`
+	Position string  `bson:"position" json:"position"`
+	Opacity  float64 `bson:"opacity" json:"opacity"`
+	FontSize int     `bson:"fontSize" json:"fontSize"`
+	Color    string  `bson:"color" json:"color"`
+	Font     string  `bson:"font" json:"font"`
+	Mode     string  `bson:"mode" json:"mode"`
+	Rotation float64 `bson:"rotation" json:"rotation"`
+	Tile     bool    `bson:"tile" json:"tile"`
+	Scale    float64 `bson:"scale,omitempty" json:"scale,omitempty"` // image width relative to the page
+}
+
+// WatermarkResult is returned by POST /api/pdf/watermark
+type WatermarkResult struct {
+	SingleFileResult `bson:",inline"`
+	Watermark        WatermarkSettings `bson:"watermark" json:"watermark"`
+}
+
+// PageNumberSettings echoes the applied page numbering
+type PageNumberSettings struct {
+	Position    string `bson:"position" json:"position"`
+	Format      string `bson:"format" json:"format"`
+	StartFrom   int    `bson:"startFrom" json:"startFrom"`
+	Style       string `bson:"style" json:"style"`
+	FrontMatter int    `bson:"frontMatter" json:"frontMatter"`
+	Skip        string `bson:"skip,omitempty" json:"skip,omitempty"`
+	Mirror      bool   `bson:"mirror" json:"mirror"`
+}
+
+// PageNumbersResult is returned by POST /api/pdf/page-numbers
+type PageNumbersResult struct {
+	SingleFileResult `bson:",inline"`
+	Settings         PageNumberSettings `bson:"settings" json:"settings"`
+}
+
+// ReorderResult is returned by POST /api/pdf/reorder
+type ReorderResult struct {
+	SingleFileResult `bson:",inline"`
+	OriginalPages    int   `bson:"originalPages" json:"originalPages"`
+	NewOrder         []int `bson:"newOrder" json:"newOrder"`
+}
+
+// RemovePagesResult is returned by POST /api/pdf/remove
+type RemovePagesResult struct {
+	SingleFileResult `bson:",inline"`
+	OriginalPages    int    `bson:"originalPages" json:"originalPages"`
+	PagesRemoved     int    `bson:"pagesRemoved" json:"pagesRemoved"`
+	RemovedPages     string `bson:"removedPages" json:"removedPages"`
+	LargeFileMode    bool   `bson:"largeFileMode,omitempty" json:"largeFileMode,omitempty"`
+}
+
+// ExtractResult is returned by POST /api/pdf/extract
+type ExtractResult struct {
+	SingleFileResult `bson:",inline"`
+	OriginalPages    int    `bson:"originalPages" json:"originalPages"`
+	ExtractedPages   string `bson:"extractedPages" json:"extractedPages"`
+	LargeFileMode    bool   `bson:"largeFileMode,omitempty" json:"largeFileMode,omitempty"`
+}
+
+// InvertSettings echoes the applied dark-mode colors
+type InvertSettings struct {
+	Background string `bson:"background" json:"background"`
+	Foreground string `bson:"foreground" json:"foreground"`
+	Images     string `bson:"images" json:"images"`
+}
+
+// InvertResult is returned by POST /api/pdf/invert
+type InvertResult struct {
+	SingleFileResult `bson:",inline"`
+	Settings         InvertSettings `bson:"settings" json:"settings"`
+}
+
+// ProtectResult is returned by POST /api/pdf/protect. Passwords are never
+// echoed.
+type ProtectResult struct {
+	SingleFileResult `bson:",inline"`
+	OwnerPassword    bool     `bson:"ownerPassword" json:"ownerPassword"` // A separate owner password was set
+	Restrictions     []string `bson:"restrictions" json:"restrictions"`
+}
+
+// UnlockResult is returned by POST /api/pdf/unlock
+type UnlockResult struct {
+	SingleFileResult `bson:",inline"`
+}
+
+// PageThumbnail describes one rendered page
+type PageThumbnail struct {
+	Page   int `bson:"page" json:"page"`
+	Width  int `bson:"width" json:"width"` // pixels
+	Height int `bson:"height" json:"height"`
+	DPI    int `bson:"dpi" json:"dpi"` // lowered for very large pages
+}
+
+// ThumbnailsResult is returned by POST /api/pdf/thumbnails. Each output is
+// the image of the page its Range names, in the order of Pages.
+type ThumbnailsResult struct {
+	OperationResult `bson:",inline"`
+	Format          string          `bson:"format" json:"format"`
+	Pages           []PageThumbnail `bson:"pages" json:"pages"`
+}
+
+// TextPlacement is one block of text drawn by POST /api/pdf/draw-text
+type TextPlacement struct {
+	Text     string  `bson:"text" json:"text"`
+	X        float64 `bson:"x" json:"x"`
+	Y        float64 `bson:"y" json:"y"`
+	FontSize float64 `bson:"fontSize" json:"fontSize"`
+	Color    string  `bson:"color" json:"color"`
+	Font     string  `bson:"font" json:"font"`
+	Pages    string  `bson:"pages,omitempty" json:"pages,omitempty"`
+	Width    float64 `bson:"width,omitempty" json:"width,omitempty"`
+	Align    string  `bson:"align" json:"align"`
+	Rotation float64 `bson:"rotation" json:"rotation"`
+	Unit     string  `bson:"unit" json:"unit"` // "pt" or "percent" of each page's size
+}
+
+// DrawTextResult is returned by POST /api/pdf/draw-text
+type DrawTextResult struct {
+	SingleFileResult `bson:",inline"`
+	Placements       []TextPlacement `bson:"placements" json:"placements"`
+}
+
+// PageGeometry is the displayed size of one page in points, with width and
+// height already swapped for pages rotated by 90 or 270 degrees
+type PageGeometry struct {
+	Page     int     `json:"page"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation int     `json:"rotation"`
+}
+
+// PageLayoutResult is returned by GET /api/pdf/:fileId/pages
+type PageLayoutResult struct {
+	FileID    string         `json:"fileId"`
+	Filename  string         `json:"filename"`
+	PageCount int            `json:"pageCount"`
+	Unit      string         `json:"unit"`
+	Pages     []PageGeometry `json:"pages"`
+}
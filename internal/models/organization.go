@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization member roles
+const (
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// TagConfidential marks library documents covered by the
+// AIDisabledForConfidential policy
+const TagConfidential = "confidential"
+
+// OrgPolicy holds an organization's compliance settings, enforced for every
+// member. Zero values leave the deployment's defaults in place.
+type OrgPolicy struct {
+	// Public share links expire within this many hours (0: no limit)
+	MaxShareExpiryHours int `bson:"maxShareExpiryHours" json:"maxShareExpiryHours"`
+	// Members can't create public share links, and existing links stop working
+	ExternalSharingDisabled bool `bson:"externalSharingDisabled" json:"externalSharingDisabled"`
+	// AI endpoints refuse files matching a library document tagged confidential
+	AIDisabledForConfidential bool `bson:"aiDisabledForConfidential" json:"aiDisabledForConfidential"`
+	// Overrides SHARE_PII_POLICY for members: off, warn or block ("" to inherit)
+	SharePIIPolicy string `bson:"sharePiiPolicy,omitempty" json:"sharePiiPolicy,omitempty"`
+}
+
+// OrgStorage is an organization's own S3-compatible bucket. Members' files
+// are stored there; their metadata stays in the platform database.
+type OrgStorage struct {
+	Endpoint  string    `bson:"endpoint" json:"endpoint"` // host[:port], e.g. s3.eu-west-1.amazonaws.com
+	Region    string    `bson:"region,omitempty" json:"region,omitempty"`
+	Bucket    string    `bson:"bucket" json:"bucket"`
+	AccessKey string    `bson:"accessKey" json:"accessKey"`
+	SecretKey string    `bson:"secretKey" json:"-"`
+	UseSSL    bool      `bson:"useSSL" json:"useSSL"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// OrgBranding white-labels the share links of an organization's members,
+// on plans with WhiteLabelBranding
+type OrgBranding struct {
+	LogoKey     string `bson:"logoKey,omitempty" json:"-"` // PNG or JPEG in the user files bucket
+	LogoURL     string `bson:"-" json:"logoUrl,omitempty"`
+	AccentColor string `bson:"accentColor,omitempty" json:"accentColor,omitempty"` // #rrggbb
+	// Host serving the frontend for share links, e.g. docs.example.com;
+	// used once its CNAME is verified
+	ShareDomain         string `bson:"shareDomain,omitempty" json:"shareDomain,omitempty"`
+	ShareDomainVerified bool   `bson:"shareDomainVerified" json:"shareDomainVerified"`
+	// Shared PDFs are downloaded with the logo stamped on every page
+	WatermarkDownloads bool      `bson:"watermarkDownloads" json:"watermarkDownloads"`
+	UpdatedAt          time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// VerifiedShareDomain returns the share domain once it is verified, or ""
+func (b *OrgBranding) VerifiedShareDomain() string {
+	if b == nil || !b.ShareDomainVerified {
+		return ""
+	}
+	return b.ShareDomain
+}
+
+// OrgMember is a user's membership of an organization
+type OrgMember struct {
+	UserID   string    `bson:"userId" json:"userId"` // Firebase UID
+	Email    string    `bson:"email" json:"email"`
+	Role     string    `bson:"role" json:"role"` // admin, member
+	JoinedAt time.Time `bson:"joinedAt" json:"joinedAt"`
+}
+
+// Organization groups users under shared compliance policies. A user
+// belongs to at most one organization.
+type Organization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Members   []OrgMember        `bson:"members" json:"members"`
+	Policy    OrgPolicy          `bson:"policy" json:"policy"`
+	Storage   *OrgStorage        `bson:"storage,omitempty" json:"storage,omitempty"`
+	Branding  *OrgBranding       `bson:"branding,omitempty" json:"branding,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Member returns the membership of userID, or nil
+func (o *Organization) Member(userID string) *OrgMember {
+	for i := range o.Members {
+		if o.Members[i].UserID == userID {
+			return &o.Members[i]
+		}
+	}
+	return nil
+}
+
+// IsAdmin reports whether userID administers the organization
+func (o *Organization) IsAdmin(userID string) bool {
+	m := o.Member(userID)
+	return m != nil && m.Role == OrgRoleAdmin
+}
+
+// MemberIDs returns the Firebase UIDs of all members
+func (o *Organization) MemberIDs() []string {
+	ids := make([]string, len(o.Members))
+	for i, m := range o.Members {
+		ids[i] = m.UserID
+	}
+	return ids
+}
@@ -0,0 +1,32 @@
+package models
+
+// Heading sources
+const (
+	HeadingSourceFont    = "font"
+	HeadingSourcePattern = "pattern"
+	HeadingSourceAI      = "ai"
+)
+
+// Heading is a detected section title. Y is its baseline in points from the
+// bottom of the page; Source tells whether it was found by font size or
+// weight, by numbering such as "2.1" or "Chapter 3", or confirmed by AI.
+type Heading struct {
+	Title    string  `bson:"title" json:"title"`
+	Level    int     `bson:"level" json:"level"`
+	Page     int     `bson:"page" json:"page"`
+	Y        float64 `bson:"y" json:"y"`
+	FontSize float64 `bson:"fontSize" json:"fontSize"`
+	Source   string  `bson:"source" json:"source"`
+}
+
+// StructureResult is returned by POST /api/pdf/detect-structure. Outputs
+// holds the bookmarked PDF when BookmarksWritten is set.
+type StructureResult struct {
+	OperationResult   `bson:",inline"`
+	PageCount         int       `bson:"pageCount" json:"pageCount"`
+	BodyFontSize      float64   `bson:"bodyFontSize" json:"bodyFontSize"`
+	ExistingBookmarks int       `bson:"existingBookmarks" json:"existingBookmarks"`
+	AIAssisted        bool      `bson:"aiAssisted" json:"aiAssisted"`
+	BookmarksWritten  bool      `bson:"bookmarksWritten" json:"bookmarksWritten"`
+	Headings          []Heading `bson:"headings" json:"headings"`
+}
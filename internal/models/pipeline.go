@@ -0,0 +1,17 @@
+package models
+
+// PipelineStepResult reports one step of a pipeline
+type PipelineStepResult struct {
+	Operation    string `bson:"operation" json:"operation"`
+	PageCount    int    `bson:"pageCount" json:"pageCount"`
+	Size         int64  `bson:"size" json:"size"` // Bytes after the step
+	ProcessingMs int64  `bson:"processingMs" json:"processingMs"`
+}
+
+// PipelineResult is returned by POST /api/pdf/pipeline. Only the final
+// PDF is stored.
+type PipelineResult struct {
+	SingleFileResult `bson:",inline"`
+	InputSize        int64                `bson:"inputSize" json:"inputSize"`
+	Steps            []PipelineStepResult `bson:"steps" json:"steps"`
+}
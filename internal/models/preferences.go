@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Preferences are a user's default operation settings. Each one is applied
+// when a request leaves the parameter out; zero values mean the built-in
+// default.
+type Preferences struct {
+	UserID      string                 `bson:"_id" json:"-"`
+	Compression CompressionPreferences `bson:"compression" json:"compression"`
+	Watermark   WatermarkPreferences   `bson:"watermark" json:"watermark"`
+	PageNumbers PageNumberPreferences  `bson:"pageNumbers" json:"pageNumbers"`
+	Share       SharePreferences       `bson:"share" json:"share"`
+	UpdatedAt   time.Time              `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CompressionPreferences are the defaults of POST /api/pdf/compress
+type CompressionPreferences struct {
+	Quality string `bson:"quality,omitempty" json:"quality,omitempty"` // low, medium or high
+	Mode    string `bson:"mode,omitempty" json:"mode,omitempty"`       // standard or photo
+}
+
+// WatermarkPreferences are the defaults of text watermarks, e.g. a
+// company's name in its brand color
+type WatermarkPreferences struct {
+	Text     string  `bson:"text,omitempty" json:"text,omitempty"`
+	Position string  `bson:"position,omitempty" json:"position,omitempty"`
+	Opacity  float64 `bson:"opacity,omitempty" json:"opacity,omitempty"`
+	FontSize int     `bson:"fontSize,omitempty" json:"fontSize,omitempty"`
+	Color    string  `bson:"color,omitempty" json:"color,omitempty"`
+	Font     string  `bson:"font,omitempty" json:"font,omitempty"`
+	Mode     string  `bson:"mode,omitempty" json:"mode,omitempty"` // diagonal or horizontal
+}
+
+// PageNumberPreferences are the defaults of POST /api/pdf/page-numbers
+type PageNumberPreferences struct {
+	Position  string `bson:"position,omitempty" json:"position,omitempty"`
+	Format    string `bson:"format,omitempty" json:"format,omitempty"`
+	Style     string `bson:"style,omitempty" json:"style,omitempty"`
+	StartFrom int    `bson:"startFrom,omitempty" json:"startFrom,omitempty"`
+}
+
+// SharePreferences are the defaults of new share links
+type SharePreferences struct {
+	ExpiresInMinutes int `bson:"expiresInMinutes,omitempty" json:"expiresInMinutes,omitempty"`
+}
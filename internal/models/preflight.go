@@ -0,0 +1,57 @@
+package models
+
+// Preflight severities. Errors are likely to ruin the print; warnings are
+// worth checking with the print shop.
+const (
+	PreflightError   = "error"
+	PreflightWarning = "warning"
+)
+
+// PreflightIssue is one problem found by POST /api/pdf/preflight, with the
+// pages it occurs on. Check is image-resolution, color-space, page-boxes,
+// transparency or fonts.
+type PreflightIssue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Pages    []int  `json:"pages,omitempty"`
+}
+
+// PreflightImage is one placement of an image. DPI is its effective
+// resolution at the placed size, the lower of the horizontal and vertical.
+type PreflightImage struct {
+	Page       int     `json:"page"`
+	Name       string  `json:"name"` // Resource name, or "inline"
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	DPI        float64 `json:"dpi"`
+	ColorSpace string  `json:"colorSpace"`
+}
+
+// PreflightFont is a font used by the document
+type PreflightFont struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Embedded bool   `json:"embedded"`
+	Pages    []int  `json:"pages"`
+}
+
+// PreflightReport is the outcome of a print preflight. Passed is false
+// when any issue is an error.
+type PreflightReport struct {
+	Passed       bool             `json:"passed"`
+	MinDPI       int              `json:"minDpi"`
+	BleedMM      float64          `json:"bleedMm"`
+	ColorSpaces  []string         `json:"colorSpaces"` // Gray, RGB, CMYK, Spot, Lab
+	Transparency bool             `json:"transparency"`
+	Images       []PreflightImage `json:"images"`
+	Fonts        []PreflightFont  `json:"fonts"`
+	Issues       []PreflightIssue `json:"issues"`
+}
+
+// PreflightResult is returned by POST /api/pdf/preflight
+type PreflightResult struct {
+	FileID    string `json:"fileId,omitempty"`
+	PageCount int    `json:"pageCount"`
+	PreflightReport
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Operation progress stages
+const (
+	ProgressStageReading    = "reading"    // inputs are being read and checked
+	ProgressStageProcessing = "processing" // pages are being split or merged
+	ProgressStageUploading  = "uploading"  // outputs are being stored
+)
+
+// OperationProgress is the progress of a running PDF operation, polled by
+// the client while its request is open. Status takes the job status values
+// (processing, completed, failed).
+type OperationProgress struct {
+	ID             string    `bson:"_id" json:"id"`
+	UserID         string    `bson:"userId,omitempty" json:"-"`
+	Operation      string    `bson:"operation" json:"operation"`
+	Status         string    `bson:"status" json:"status"`
+	Stage          string    `bson:"stage" json:"stage"`
+	PagesProcessed int       `bson:"pagesProcessed" json:"pagesProcessed"`
+	TotalPages     int       `bson:"totalPages" json:"totalPages"`
+	Progress       int       `bson:"progress" json:"progress"` // percent, across all stages
+	Error          string    `bson:"error,omitempty" json:"error,omitempty"`
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt"`
+	ExpiresAt      time.Time `bson:"expiresAt" json:"-"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// Read-aloud modes
+const (
+	ReadAloudDocument = "document" // One MP3 for the selected pages
+	ReadAloudPages    = "pages"    // One MP3 per page
+)
+
+// ReadAloudResult is returned by POST /api/v1/ai/read-aloud. Each output is
+// an MP3 whose Range names the pages it reads; pages without text are
+// listed in SkippedPages and get no audio.
+type ReadAloudResult struct {
+	OperationResult `bson:",inline"`
+	Mode            string `json:"mode"`
+	Voice           string `json:"voice"`
+	Characters      int    `json:"characters"`
+	Pages           []int  `json:"pages"`
+	SkippedPages    []int  `json:"skippedPages"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// RedactionFinding is one occurrence burned out of a PDF. Value is masked
+// so the response doesn't repeat what was removed.
+type RedactionFinding struct {
+	Page  int        `json:"page"`
+	Type  string     `json:"type"` // email, ssn, ..., term, or a type named by the AI such as name
+	Value string     `json:"value"`
+	Rects []TextRect `json:"rects"`
+}
+
+// RedactionReport lists what a redaction removed
+type RedactionReport struct {
+	Findings           []RedactionFinding `json:"findings"`
+	Total              int                `json:"total"`
+	Types              map[string]int     `json:"types"`
+	Pages              []int              `json:"pages"`              // Pages with redactions
+	RemovedGlyphs      int                `json:"removedGlyphs"`      // Characters removed from the text layer
+	RemovedAnnotations int                `json:"removedAnnotations"` // Comments and other annotations over redactions
+	AIAssisted         bool               `json:"aiAssisted"`
+	AIReviewedPages    int                `json:"aiReviewedPages,omitempty"` // Pages the AI read; later pages are matched by pattern only
+}
+
+// RedactResult is returned by POST /api/v1/ai/redact
+type RedactResult struct {
+	SingleFileResult `bson:",inline"`
+	Redactions       RedactionReport `json:"redactions"`
+}
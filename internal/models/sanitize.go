@@ -0,0 +1,24 @@
+package models
+
+// SanitizeReport lists what POST /api/pdf/sanitize removed. Counts are of
+// PDF objects, so one script reachable from several places counts once per
+// place.
+type SanitizeReport struct {
+	DocumentInfo []string `bson:"documentInfo" json:"documentInfo"` // Info keys, e.g. Author
+	XMPMetadata  int      `bson:"xmpMetadata" json:"xmpMetadata"`
+	JavaScript   int      `bson:"javaScript" json:"javaScript"`
+	Actions      int      `bson:"actions" json:"actions"` // Launch, submit, import and other risky actions
+	XFA          bool     `bson:"xfa" json:"xfa"`         // XML form data, which may carry scripts
+	Attachments  []string `bson:"attachments" json:"attachments"`
+	HiddenLayers []string `bson:"hiddenLayers" json:"hiddenLayers"` // Layers off by default, content removed
+	PrivateData  int      `bson:"privateData" json:"privateData"`   // Application data (PieceInfo)
+	Thumbnails   int      `bson:"thumbnails" json:"thumbnails"`
+	Revisions    int      `bson:"revisions" json:"revisions"` // Earlier incremental updates discarded
+}
+
+// SanitizeResult is returned by POST /api/pdf/sanitize
+type SanitizeResult struct {
+	SingleFileResult `bson:",inline"`
+	OriginalSize     int64          `bson:"originalSize" json:"originalSize"`
+	Removed          SanitizeReport `bson:"removed" json:"removed"`
+}
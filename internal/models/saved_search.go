@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LibrarySearch selects library documents. Every criterion given must
+// hold: Text matches file names and note transcripts (and, for alerts, the
+// text of new documents), Tags must all be on the document and Entity is a
+// person, company, invoice number or date it mentions.
+type LibrarySearch struct {
+	Text       string   `bson:"text,omitempty" json:"text,omitempty"`
+	Tags       []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	Entity     string   `bson:"entity,omitempty" json:"entity,omitempty"`
+	EntityType string   `bson:"entityType,omitempty" json:"entityType,omitempty"`
+}
+
+// SavedSearch is a library search kept by a user. With Alert set, the user
+// is notified whenever a newly uploaded document matches it.
+type SavedSearch struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerUID      string             `bson:"ownerUid" json:"-"`
+	Name          string             `bson:"name" json:"name"`
+	Query         LibrarySearch      `bson:"query" json:"query"`
+	Alert         bool               `bson:"alert" json:"alert"`
+	Alerts        int                `bson:"alerts" json:"alerts"` // notifications sent
+	LastMatchedAt *time.Time         `bson:"lastMatchedAt,omitempty" json:"lastMatchedAt,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
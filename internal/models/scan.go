@@ -0,0 +1,39 @@
+package models
+
+// Severities of scan findings, from least to most dangerous
+const (
+	ScanRiskNone   = "none"
+	ScanRiskLow    = "low"
+	ScanRiskMedium = "medium"
+	ScanRiskHigh   = "high"
+)
+
+// ScanFinding is one risky item found by POST /api/pdf/scan. Trigger says
+// what runs it: "open" for the document open action, "event" for
+// additional actions on pages, fields and the document, "document" for
+// document-level scripts and "click" for links and buttons.
+type ScanFinding struct {
+	Type     string `json:"type"` // javascript, launch, uri, submit, import, embedded-file, embedded-executable, xfa
+	Severity string `json:"severity"`
+	Trigger  string `json:"trigger,omitempty"`
+	Detail   string `json:"detail,omitempty"` // URL, program or attachment name
+}
+
+// ScanReport is the outcome of a security scan. Risk is the highest
+// severity found, or "none".
+type ScanReport struct {
+	Risk                string        `json:"risk"`
+	Safe                bool          `json:"safe"` // No high-severity findings
+	JavaScript          int           `json:"javaScript"`
+	LaunchActions       int           `json:"launchActions"`
+	URIs                []string      `json:"uris"`
+	EmbeddedExecutables []string      `json:"embeddedExecutables"`
+	Findings            []ScanFinding `json:"findings"`
+}
+
+// ScanResult is returned by POST /api/pdf/scan
+type ScanResult struct {
+	FileID    string `json:"fileId,omitempty"`
+	PageCount int    `json:"pageCount"`
+	ScanReport
+}
@@ -0,0 +1,29 @@
+package models
+
+// TextRect is a rectangle in PDF points, measured from the page's
+// bottom-left corner
+type TextRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// SearchMatch is one occurrence of the query. Context is the surrounding
+// line. Matches don't span lines yet, so Rects holds a single rectangle.
+type SearchMatch struct {
+	Page    int        `json:"page"`
+	Text    string     `json:"text"`
+	Context string     `json:"context"`
+	Rects   []TextRect `json:"rects"`
+}
+
+// SearchResult is returned by POST /api/pdf/search
+type SearchResult struct {
+	FileID       string        `json:"fileId,omitempty"`
+	Query        string        `json:"query"`
+	PageCount    int           `json:"pageCount"`
+	TotalMatches int           `json:"totalMatches"`
+	Truncated    bool          `json:"truncated"`
+	Matches      []SearchMatch `json:"matches"`
+}
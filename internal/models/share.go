@@ -6,6 +6,23 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Share PII policies: what happens when a PDF being shared publicly contains
+// the SharePIITypes
+const (
+	SharePIIOff   = "off"
+	SharePIIWarn  = "warn"  // Refuse until the user acknowledges the findings
+	SharePIIBlock = "block" // Refuse outright
+)
+
+// Share permissions: what a link's recipients may do with the file
+const (
+	SharePermissionView     = "view"     // Read in the share page's viewer, watermarked, no download
+	SharePermissionDownload = "download" // Download the file as stored
+)
+
+// SharePIITypes are the sensitive data types checked before sharing
+var SharePIITypes = []string{"ssn", "credit_card", "aadhaar"}
+
 type Share struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Code      string             `bson:"code" json:"code"`       // Unique 8-char code
@@ -13,13 +30,58 @@ type Share struct {
 	CreatorID string             `bson:"creatorId" json:"creatorId"`
 	FileType  string             `bson:"fileType" json:"fileType"` // "library" or "temp"
 	Filename  string             `bson:"filename" json:"filename"`
+	// SharePermissionView or SharePermissionDownload; links created before
+	// permissions existed have none and allow downloading
+	Permission string            `bson:"permission,omitempty" json:"permission"`
 	Stats     ShareStats         `bson:"stats" json:"stats"`
 	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	// Scheduled links only open from ActivatesAt; the creator is notified
+	// once they do
+	ActivatesAt        *time.Time `bson:"activatesAt,omitempty" json:"activatesAt,omitempty"`
+	ActivationNotified bool       `bson:"activationNotified,omitempty" json:"-"`
+	// Optional landing page: shown on the share page and in link previews
+	// instead of the filename
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	CoverKey    string `bson:"coverKey,omitempty" json:"-"` // Object key of the rendered cover thumbnail
+	// Set when an admin disabled the link after reports of abuse
+	Takedown *Takedown `bson:"takedown,omitempty" json:"takedown,omitempty"`
+	// Set when the link expired and another link took its vanity slug; the
+	// link keeps its stats under a code that can't be requested
+	ReleasedSlug string     `bson:"releasedSlug,omitempty" json:"-"`
+	ReleasedAt   *time.Time `bson:"releasedAt,omitempty" json:"-"`
+}
+
+// Share landing page limits
+const (
+	MaxShareTitleLength       = 120
+	MaxShareDescriptionLength = 500
+)
+
+// ActiveFrom returns when the link opened or opens
+func (s Share) ActiveFrom() time.Time {
+	if s.ActivatesAt != nil {
+		return *s.ActivatesAt
+	}
+	return s.CreatedAt
+}
+
+// Pending reports whether the link is scheduled to open after now
+func (s Share) Pending(now time.Time) bool {
+	return s.ActivatesAt != nil && now.Before(*s.ActivatesAt)
+}
+
+// ViewOnly reports whether the link only allows viewing the file
+func (s Share) ViewOnly() bool {
+	return s.Permission == SharePermissionView
 }
 
+// ShareStats counts people's visits to a link: bots, prefetches and a
+// viewer's repeat visits within a window aren't counted
 type ShareStats struct {
 	Views     int       `bson:"views" json:"views"`
+	Previews  int       `bson:"previews" json:"previews"` // Shown in the share page's viewer
 	Downloads int       `bson:"downloads" json:"downloads"`
 	LastAccess time.Time `bson:"lastAccess" json:"lastAccess"`
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Signature kinds
+const (
+	SignatureKindSignature = "signature"
+	SignatureKindInitials  = "initials"
+)
+
+// Signature is a reusable signature or initials image owned by a user
+type Signature struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerUID        string             `bson:"ownerUid" json:"-"`
+	Name            string             `bson:"name" json:"name"`
+	Kind            string             `bson:"kind" json:"kind"`
+	ObjectKey       string             `bson:"objectKey" json:"-"`
+	Width           int                `bson:"width" json:"width"`
+	Height          int                `bson:"height" json:"height"`
+	Size            int64              `bson:"size" json:"size"`
+	HasTransparency bool               `bson:"hasTransparency" json:"hasTransparency"`
+	URL             string             `bson:"-" json:"url,omitempty"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// StampResult is returned by POST /api/pdf/stamp-signature
+type StampResult struct {
+	SingleFileResult `bson:",inline"`
+	SignatureID      string `bson:"signatureId" json:"signatureId"`
+	StampedPages     string `bson:"stampedPages" json:"stampedPages"`
+}
+
+// DigitalSignature describes a certificate-based signature of a PDF
+type DigitalSignature struct {
+	Field        string     `bson:"field" json:"field"`   // Signature form field
+	Signer       string     `bson:"signer" json:"signer"` // Common name of the signing certificate
+	Email        string     `bson:"email,omitempty" json:"email,omitempty"`
+	Subject      string     `bson:"subject" json:"subject"`
+	Issuer       string     `bson:"issuer" json:"issuer"`
+	SerialNumber string     `bson:"serialNumber" json:"serialNumber"`
+	NotBefore    time.Time  `bson:"notBefore" json:"notBefore"`
+	NotAfter     time.Time  `bson:"notAfter" json:"notAfter"`
+	SignedAt     *time.Time `bson:"signedAt,omitempty" json:"signedAt,omitempty"` // As claimed by the signer
+	Reason       string     `bson:"reason,omitempty" json:"reason,omitempty"`
+	Location     string     `bson:"location,omitempty" json:"location,omitempty"`
+	ContactInfo  string     `bson:"contactInfo,omitempty" json:"contactInfo,omitempty"`
+	SubFilter    string     `bson:"subFilter,omitempty" json:"subFilter,omitempty"`
+}
+
+// SignatureVerification is the check of one DigitalSignature. Valid means
+// the signed bytes are unchanged and signed by the certificate; Trusted
+// that the certificate chains to a trusted root; CertificateValid that it
+// was in its validity period when signed. CoversWholeDocument is false
+// when the document was changed (or signed again) afterwards.
+type SignatureVerification struct {
+	DigitalSignature    `bson:",inline"`
+	Valid               bool   `json:"valid"`
+	CoversWholeDocument bool   `json:"coversWholeDocument"`
+	CertificateValid    bool   `json:"certificateValid"`
+	Trusted             bool   `json:"trusted"`
+	SelfSigned          bool   `json:"selfSigned"`
+	Error               string `json:"error,omitempty"` // Why Valid is false
+}
+
+// SignResult is returned by POST /api/pdf/sign
+type SignResult struct {
+	SingleFileResult `bson:",inline"`
+	Signature        DigitalSignature `bson:"signature" json:"signature"`
+	Visible          bool             `bson:"visible" json:"visible"`
+	Page             int              `bson:"page" json:"page"`
+}
+
+// VerifySignaturesResult is returned by POST /api/pdf/verify-signature.
+// Modified is true when the document changed after its last signature.
+type VerifySignaturesResult struct {
+	FileID     string                  `json:"fileId,omitempty"`
+	Signed     bool                    `json:"signed"`
+	Valid      bool                    `json:"valid"` // Signed, every signature valid and not Modified
+	Modified   bool                    `json:"modified"`
+	Signatures []SignatureVerification `json:"signatures"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Storage migration phases
+const (
+	StorageMigrationCopying   = "copying"   // copying objects to the target
+	StorageMigrationCopied    = "copied"    // everything copied, waiting for cutover
+	StorageMigrationCutover   = "cutover"   // copying late changes and rewriting records
+	StorageMigrationCompleted = "completed" // documents point at the target bucket
+	StorageMigrationCancelled = "cancelled"
+)
+
+// StorageMigrationTarget is the bucket a migration copies to
+type StorageMigrationTarget struct {
+	Endpoint  string `bson:"endpoint" json:"endpoint"` // host[:port]
+	Region    string `bson:"region,omitempty" json:"region,omitempty"`
+	Bucket    string `bson:"bucket" json:"bucket"`
+	AccessKey string `bson:"accessKey" json:"accessKey"`
+	SecretKey string `bson:"secretKey" json:"-"`
+	UseSSL    bool   `bson:"useSSL" json:"useSSL"`
+}
+
+// StorageMigration copies one of the platform's buckets to another bucket
+// or endpoint, then rewrites document locations to point at it. Progress is
+// recorded after every object so it resumes where it stopped.
+type StorageMigration struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	SourceBucket string                 `bson:"sourceBucket" json:"sourceBucket"`
+	Target       StorageMigrationTarget `bson:"target" json:"target"`
+	Status       string                 `bson:"status" json:"status"`
+	Paused       bool                   `bson:"paused" json:"paused"`
+	Error        string                 `bson:"error,omitempty" json:"error,omitempty"` // why it paused itself
+
+	LastKey     string `bson:"lastKey,omitempty" json:"lastKey,omitempty"` // last object copied
+	SyncKey     string `bson:"syncKey,omitempty" json:"syncKey,omitempty"` // last object checked during cutover
+	Copied      int64  `bson:"copied" json:"copied"`
+	CopiedBytes int64  `bson:"copiedBytes" json:"copiedBytes"`
+	Resynced    int64  `bson:"resynced" json:"resynced"`   // objects copied again during cutover
+	Rewritten   int64  `bson:"rewritten" json:"rewritten"` // documents pointed at the target
+	StartedBy   string `bson:"startedBy" json:"startedBy"`
+
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time  `bson:"updatedAt" json:"updatedAt"`
+	CompletedAt *time.Time `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Kinds of files counting toward a user's storage
+const (
+	StoredFileLibrary   = "library"    // a library document
+	StoredFileSaved     = "file"       // an upload or operation output kept under /api/v1/files
+	StoredFileVoiceNote = "voice_note" // a voice note attached to a library document
+)
+
+// StoredFile is one file counting toward a user's storage
+type StoredFile struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"` // library, file, voice_note
+	FileName   string    `json:"fileName"`
+	Size       int64     `json:"size"`
+	DocumentID string    `json:"documentId,omitempty"` // library document a voice note belongs to
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// StorageCategory is the usage of one kind of file
+type StorageCategory struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// StorageBreakdown splits a user's storage usage by kind of file
+type StorageBreakdown struct {
+	Used       int64           `json:"used"` // usage counted against the limit
+	Limit      int64           `json:"limit"`
+	Grace      int64           `json:"grace"` // bytes that may be used past the limit
+	Library    StorageCategory `json:"library"`
+	Outputs    StorageCategory `json:"outputs"` // uploads and operation outputs saved under /api/v1/files
+	VoiceNotes StorageCategory `json:"voiceNotes"`
+	// File versions and trash are not kept yet; they always report zero
+	Versions StorageCategory `json:"versions"`
+	Trash    StorageCategory `json:"trash"`
+	Largest  []StoredFile    `json:"largest"`
+}
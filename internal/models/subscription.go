@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Subscription statuses
+const (
+	SubscriptionCreated   = "created"   // Waiting for the first payment to be authorized
+	SubscriptionActive    = "active"    // Paid up; renews every month
+	SubscriptionPastDue   = "past_due"  // A renewal charge failed and is being retried
+	SubscriptionCancelled = "cancelled" // Won't renew; the plan lasts until CurrentPeriodEnd
+	SubscriptionExpired   = "expired"   // Lapsed; the user is back on the free plan
+)
+
+// Subscription is a user's recurring plan payment. A user has at most one;
+// subscribing again after it expires replaces it.
+type Subscription struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID string             `bson:"userId" json:"-"` // Firebase UID
+	Plan   string             `bson:"plan" json:"plan"`
+	Status string             `bson:"status" json:"status"`
+	Amount int64              `bson:"amount" json:"amount"` // Paise per month
+
+	GatewaySubscriptionID string `bson:"gatewaySubscriptionId" json:"subscriptionId"`
+	GatewayPlanID         string `bson:"gatewayPlanId" json:"-"`
+	// Hosted page where the first payment is authorized, while created
+	CheckoutURL string `bson:"checkoutUrl,omitempty" json:"checkoutUrl,omitempty"`
+
+	// End of the paid period: the renewal date, or when a cancelled
+	// subscription ends
+	CurrentPeriodEnd *time.Time `bson:"currentPeriodEnd,omitempty" json:"currentPeriodEnd,omitempty"`
+	CancelledAt      *time.Time `bson:"cancelledAt,omitempty" json:"cancelledAt,omitempty"`
+	CreatedAt        time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time  `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Live reports whether the subscription still holds or is about to hold
+// the user's plan
+func (s *Subscription) Live() bool {
+	return s.Status != SubscriptionExpired
+}
@@ -0,0 +1,35 @@
+package models
+
+// Template check requirement outcomes
+const (
+	RequirementPass = "pass"
+	RequirementFail = "fail"
+)
+
+// TemplateRequirement is something a document must contain, such as a
+// signature block, a date or clause 7. Description says what counts.
+type TemplateRequirement struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// RequirementResult is the outcome of one requirement. Evidence quotes
+// the document where it is met; Reason explains a failure.
+type RequirementResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status"` // pass or fail
+	Evidence    string `json:"evidence,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Pages       []int  `json:"pages"`
+}
+
+// TemplateCheck is returned by POST /api/v1/ai/template-check. Passed is
+// true when every requirement passed.
+type TemplateCheck struct {
+	PageCount    int                 `json:"pageCount"`
+	Passed       bool                `json:"passed"`
+	PassCount    int                 `json:"passCount"`
+	FailCount    int                 `json:"failCount"`
+	Requirements []RequirementResult `json:"requirements"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Virus scan statuses
+const (
+	VirusScanPending  = "pending"  // queued or being scanned
+	VirusScanClean    = "clean"    // nothing found
+	VirusScanInfected = "infected" // the scanner matched a signature
+	VirusScanError    = "error"    // the scan failed and will be retried
+)
+
+// VirusScan is the result of scanning a stored file for malware. Files
+// without one predate scanning or were stored while it was off.
+type VirusScan struct {
+	Status      string     `bson:"status" json:"status"`
+	Scanner     string     `bson:"scanner,omitempty" json:"scanner,omitempty"`
+	Signature   string     `bson:"signature,omitempty" json:"signature,omitempty"` // malware found, when infected
+	Error       string     `bson:"error,omitempty" json:"error,omitempty"`
+	RequestedAt time.Time  `bson:"requestedAt" json:"requestedAt"`
+	ScannedAt   *time.Time `bson:"scannedAt,omitempty" json:"scannedAt,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Workspace edit types
+const (
+	WorkspaceEditRotate  = "rotate"
+	WorkspaceEditDelete  = "delete"
+	WorkspaceEditReorder = "reorder"
+)
+
+// WorkspaceEdit is one step in a workspace's edit list. Pages and Order refer
+// to page positions in the document as it looks before this edit (1-based).
+type WorkspaceEdit struct {
+	Type  string `bson:"type" json:"type" binding:"required"`
+	Pages []int  `bson:"pages,omitempty" json:"pages,omitempty"` // rotate, delete
+	Angle int    `bson:"angle,omitempty" json:"angle,omitempty"` // rotate: 90, 180, 270
+	Order []int  `bson:"order,omitempty" json:"order,omitempty"` // reorder: every position, once
+}
+
+// WorkspacePage is a page of the edited document: which page of the source
+// PDF it is and how far it has been rotated
+type WorkspacePage struct {
+	Source   int `json:"source"`
+	Rotation int `json:"rotation"`
+}
+
+// Workspace is an editing session over an uploaded PDF. Edits[:Cursor] are
+// applied; Edits[Cursor:] can be redone until a new edit replaces them.
+type Workspace struct {
+	ID          string          `bson:"_id" json:"id"`
+	UserID      string          `bson:"userId,omitempty" json:"-"`
+	Filename    string          `bson:"filename" json:"filename"`
+	SourceKey   string          `bson:"sourceKey" json:"-"`
+	SourcePages int             `bson:"sourcePages" json:"sourcePages"`
+	Size        int64           `bson:"size" json:"size"`
+	Edits       []WorkspaceEdit `bson:"edits" json:"edits"`
+	Cursor      int             `bson:"cursor" json:"cursor"`
+	Version     int             `bson:"version" json:"version"`
+	CreatedAt   time.Time       `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time       `bson:"updatedAt" json:"updatedAt"`
+	ExpiresAt   time.Time       `bson:"expiresAt" json:"expiresAt"`
+}
+
+// WorkspaceState is a workspace plus the page list its applied edits produce
+type WorkspaceState struct {
+	*Workspace
+	Pages   []WorkspacePage `json:"pages"`
+	CanUndo bool            `json:"canUndo"`
+	CanRedo bool            `json:"canRedo"`
+}
+
+// WorkspaceCommitResult is returned by POST /api/v1/workspace/:id/commit
+type WorkspaceCommitResult struct {
+	SingleFileResult `bson:",inline"`
+	WorkspaceID      string `bson:"workspaceId" json:"workspaceId"`
+	EditsApplied     int    `bson:"editsApplied" json:"editsApplied"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// Archive entry statuses
+const (
+	ZipEntryQueued   = "queued"   // Added to the conversion job
+	ZipEntryImported = "imported" // Added to the library
+	ZipEntrySkipped  = "skipped"  // Unsupported type, unsafe path or over a limit
+	ZipEntryFailed   = "failed"   // Supported but couldn't be processed
+)
+
+// ZipEntryResult reports what happened to one file of an uploaded ZIP
+type ZipEntryResult struct {
+	Name       string `json:"name"` // Path inside the archive
+	Size       int64  `json:"size"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	OutputName string `json:"outputName,omitempty"` // Name in the conversion result
+	DocumentID string `json:"documentId,omitempty"` // Library document created
+	PageCount  int    `json:"pageCount,omitempty"`
+}
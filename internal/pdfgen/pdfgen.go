@@ -0,0 +1,131 @@
+// Package pdfgen generates small, valid PDFs in memory: the fixtures of the
+// integration tests and benchmarks, and the demo documents cmd/seed uploads.
+package pdfgen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"strings"
+)
+
+// Builder assembles small but valid PDFs without touching disk.
+// Text pages use the built-in Helvetica font; image pages embed a JPEG.
+type Builder struct {
+	pages []samplePage
+}
+
+type samplePage struct {
+	lines  []string
+	jpeg   []byte
+	width  int
+	height int
+}
+
+// AddTextPage appends an A4 page with the given lines of text
+func (b *Builder) AddTextPage(lines ...string) *Builder {
+	b.pages = append(b.pages, samplePage{lines: lines})
+	return b
+}
+
+// AddImagePage appends an A4 page filled with a noisy width x height JPEG.
+// Noise keeps the JPEG from compressing to nothing so sizes stay realistic.
+func (b *Builder) AddImagePage(width, height int, seed int64) *Builder {
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(rng.Intn(256)), uint8(x % 256), uint8(y % 256), 255})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	b.pages = append(b.pages, samplePage{jpeg: buf.Bytes(), width: width, height: height})
+	return b
+}
+
+// Bytes renders the document
+func (b *Builder) Bytes() []byte {
+	// Object layout: 1 catalog, 2 pages tree, 3 font, then per page
+	// [page, content, (image)]
+	objects := [][]byte{nil, nil, []byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")}
+	var kids []string
+
+	for _, p := range b.pages {
+		pageNum := len(objects) + 1
+		contentNum := pageNum + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageNum))
+
+		var content bytes.Buffer
+		resources := "/Font << /F1 3 0 R >>"
+		if p.jpeg != nil {
+			imageNum := contentNum + 1
+			resources += fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", imageNum)
+			content.WriteString("q 515 0 0 762 40 40 cm /Im1 Do Q\n")
+		} else {
+			content.WriteString("BT /F1 12 Tf 50 790 Td 14 TL\n")
+			for _, line := range p.lines {
+				fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDFString(line))
+			}
+			content.WriteString("ET\n")
+		}
+
+		objects = append(objects,
+			[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << %s >> /Contents %d 0 R >>", resources, contentNum)),
+			pdfStream(fmt.Sprintf("<< /Length %d >>", content.Len()), content.Bytes()),
+		)
+		if p.jpeg != nil {
+			dict := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", p.width, p.height, len(p.jpeg))
+			objects = append(objects, pdfStream(dict, p.jpeg))
+		}
+	}
+
+	objects[0] = []byte("<< /Type /Catalog /Pages 2 0 R >>")
+	objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(obj)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+// Sample returns a text-only PDF with the given number of pages, each
+// page labelled "Page N" so split/reorder results can be asserted on
+func Sample(pages int) []byte {
+	b := &Builder{}
+	for i := 1; i <= pages; i++ {
+		b.AddTextPage(fmt.Sprintf("Page %d", i), "Brainy PDF integration fixture")
+	}
+	return b.Bytes()
+}
+
+func pdfStream(dict string, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(dict)
+	buf.WriteString("\nstream\n")
+	buf.Write(data)
+	buf.WriteString("\nendstream")
+	return buf.Bytes()
+}
+
+func escapePDFString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	return r.Replace(s)
+}
@@ -0,0 +1,146 @@
+// Package renderer rasterizes PDF pages to PNG or JPEG with poppler's
+// pdftoppm, which the Docker image installs with poppler-utils
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Image formats
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+)
+
+// Resolution and quality bounds
+const (
+	DefaultDPI     = 72
+	MinDPI         = 18
+	MaxDPI         = 300
+	DefaultQuality = 85
+
+	// renderTimeout bounds one page; pathological pages can take pdftoppm
+	// minutes
+	renderTimeout = time.Minute
+)
+
+var (
+	// ErrUnavailable is returned when pdftoppm isn't installed
+	ErrUnavailable = errors.New("pdftoppm is not installed")
+	// ErrInvalidOptions wraps render option validation failures
+	ErrInvalidOptions = errors.New("invalid render options")
+)
+
+// Options controls how pages are rendered
+type Options struct {
+	DPI     int    // Pixels per inch, DefaultDPI when 0
+	Format  string // FormatPNG (default) or FormatJPEG
+	Quality int    // JPEG quality from 1 to 100, DefaultQuality when 0
+}
+
+// NormalizeOptions validates opts and fills in the defaults. "jpg" is
+// accepted for FormatJPEG.
+func NormalizeOptions(opts Options) (Options, error) {
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", FormatPNG:
+		opts.Format = FormatPNG
+	case FormatJPEG, "jpg":
+		opts.Format = FormatJPEG
+	default:
+		return opts, fmt.Errorf("%w: format must be png or jpeg", ErrInvalidOptions)
+	}
+	if opts.DPI == 0 {
+		opts.DPI = DefaultDPI
+	}
+	if opts.DPI < MinDPI || opts.DPI > MaxDPI {
+		return opts, fmt.Errorf("%w: dpi must be between %d and %d", ErrInvalidOptions, MinDPI, MaxDPI)
+	}
+	if opts.Quality == 0 {
+		opts.Quality = DefaultQuality
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return opts, fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidOptions)
+	}
+	return opts, nil
+}
+
+// ContentType returns the MIME type of images rendered with opts
+func (o Options) ContentType() string {
+	if o.Format == FormatJPEG {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Ext returns the file extension of images rendered with opts
+func (o Options) Ext() string {
+	if o.Format == FormatJPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Renderer runs pdftoppm
+type Renderer struct {
+	path string
+}
+
+// New finds pdftoppm at path, or on PATH when path is empty. The renderer
+// reports itself unavailable when it isn't found.
+func New(path string) *Renderer {
+	if path == "" {
+		path, _ = exec.LookPath("pdftoppm")
+	} else if _, err := os.Stat(path); err != nil {
+		path = ""
+	}
+	return &Renderer{path: path}
+}
+
+// Available reports whether pdftoppm was found. Safe to call on a nil
+// renderer.
+func (r *Renderer) Available() bool {
+	return r != nil && r.path != ""
+}
+
+// RenderPage renders page (1-based) of the PDF at pdfPath into dir and
+// returns the image. opts must be normalized.
+func (r *Renderer) RenderPage(ctx context.Context, pdfPath, dir string, page int, opts Options) ([]byte, error) {
+	if !r.Available() {
+		return nil, ErrUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	// -singlefile writes <prefix>.png or <prefix>.jpg without a page suffix
+	prefix := filepath.Join(dir, "page-"+strconv.Itoa(page))
+	args := []string{"-f", strconv.Itoa(page), "-l", strconv.Itoa(page), "-r", strconv.Itoa(opts.DPI), "-singlefile"}
+	if opts.Format == FormatJPEG {
+		args = append(args, "-jpeg", "-jpegopt", "quality="+strconv.Itoa(opts.Quality))
+	} else {
+		args = append(args, "-png")
+	}
+	args = append(args, pdfPath, prefix)
+
+	output, err := exec.CommandContext(ctx, r.path, args...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("rendering page %d timed out after %s", page, renderTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pdftoppm failed on page %d: %v: %s", page, err, strings.TrimSpace(string(output)))
+	}
+
+	data, err := os.ReadFile(prefix + opts.Ext())
+	if err != nil {
+		return nil, fmt.Errorf("pdftoppm wrote no image for page %d: %w", page, err)
+	}
+	return data, nil
+}
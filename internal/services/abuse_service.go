@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const abuseReportCollection = "abuse_reports"
+
+// Abuse report errors surfaced to handlers
+var (
+	ErrInvalidAbuseReport  = errors.New("invalid abuse report")
+	ErrAbuseReportNotFound = errors.New("abuse report not found")
+	ErrAbuseReportResolved = errors.New("abuse report already resolved")
+	ErrReportedShareGone   = errors.New("share link not found")
+	ErrTakedownUserMissing = errors.New("user not found")
+)
+
+// AbuseReportInput is a report as submitted by a member of the public
+type AbuseReportInput struct {
+	Code      string // The reported link's code
+	Reason    string // One of models.AbuseReasons
+	Details   string
+	Email     string // Optional contact address
+	IP        string
+	UserAgent string
+}
+
+// AbuseService takes reports of abusive share links, keeps the queue
+// admins review them in, and disables links or users' sharing
+type AbuseService struct {
+	mongoClient   *mongodb.Client
+	notifications *NotificationService
+}
+
+// NewAbuseService creates an abuse service
+func NewAbuseService(mongoClient *mongodb.Client, notifications *NotificationService) *AbuseService {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mongoClient.Collection(abuseReportCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "shareCode", Value: 1}, {Key: "reporterHash", Value: 1}}},
+	}); err != nil {
+		log.Printf("[Abuse] Failed to create abuse report indexes: %v", err)
+	}
+	return &AbuseService{mongoClient: mongoClient, notifications: notifications}
+}
+
+// Report files a report against a link. A reporter's repeat reports of the
+// same link while one is open return the open one.
+func (s *AbuseService) Report(ctx context.Context, in AbuseReportInput) (*models.AbuseReport, error) {
+	reason := strings.ToLower(strings.TrimSpace(in.Reason))
+	valid := false
+	for _, r := range models.AbuseReasons {
+		valid = valid || r == reason
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: reason must be one of %s", ErrInvalidAbuseReport, strings.Join(models.AbuseReasons, ", "))
+	}
+	details := strings.TrimSpace(in.Details)
+	if utf8.RuneCountInString(details) > models.MaxAbuseDetailsLength {
+		return nil, fmt.Errorf("%w: details must be at most %d characters", ErrInvalidAbuseReport, models.MaxAbuseDetailsLength)
+	}
+	if reason == models.AbuseReasonOther && details == "" {
+		return nil, fmt.Errorf("%w: describe the problem in details", ErrInvalidAbuseReport)
+	}
+	email := strings.TrimSpace(in.Email)
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: email is not a valid address", ErrInvalidAbuseReport)
+		}
+		email = addr.Address
+	}
+
+	var share models.Share
+	err := s.mongoClient.Collection("shares").FindOne(ctx, bson.M{"code": strings.TrimSpace(in.Code)}).Decode(&share)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrReportedShareGone
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up share: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(in.IP + "|" + in.UserAgent))
+	report := models.AbuseReport{
+		ShareCode:     share.Code,
+		OwnerID:       share.CreatorID,
+		Filename:      share.Filename,
+		Reason:        reason,
+		Details:       details,
+		ReporterEmail: email,
+		ReporterHash:  hex.EncodeToString(sum[:]),
+		Status:        models.AbuseReportOpen,
+		CreatedAt:     time.Now(),
+	}
+
+	reports := s.mongoClient.Collection(abuseReportCollection)
+	var existing models.AbuseReport
+	err = reports.FindOne(ctx, bson.M{
+		"shareCode":    report.ShareCode,
+		"reporterHash": report.ReporterHash,
+		"status":       models.AbuseReportOpen,
+	}).Decode(&existing)
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to look up reports: %w", err)
+	}
+
+	res, err := reports.InsertOne(ctx, report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+	report.ID = res.InsertedID.(primitive.ObjectID)
+	return &report, nil
+}
+
+// List returns reports with status, or all when status is empty, oldest
+// first so the queue is worked in order
+func (s *AbuseService) List(ctx context.Context, status string, limit int) ([]models.AbuseReport, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := s.mongoClient.Collection(abuseReportCollection).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	reports := []models.AbuseReport{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	return reports, nil
+}
+
+// Resolve closes an open report with action. Disabling the link or its
+// owner's sharing also closes the other open reports it settles, and
+// notifies the owner with note as the reason.
+func (s *AbuseService) Resolve(ctx context.Context, id, action, note, adminID string) (*models.AbuseReport, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrAbuseReportNotFound
+	}
+	var report models.AbuseReport
+	err = s.mongoClient.Collection(abuseReportCollection).FindOne(ctx, bson.M{"_id": objID}).Decode(&report)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAbuseReportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up report: %w", err)
+	}
+	if report.Status != models.AbuseReportOpen {
+		return nil, ErrAbuseReportResolved
+	}
+
+	note = strings.TrimSpace(note)
+	reason := note
+	if reason == "" {
+		reason = "Reported for " + report.Reason
+	}
+
+	// Reports the action settles
+	settled := bson.M{"_id": objID}
+	status := models.AbuseReportActioned
+	switch action {
+	case models.AbuseActionDismiss:
+		status = models.AbuseReportDismissed
+	case models.AbuseActionDisableShare:
+		if err := s.DisableShare(ctx, report.ShareCode, reason, adminID); err != nil {
+			return nil, err
+		}
+		settled = bson.M{"shareCode": report.ShareCode}
+	case models.AbuseActionDisableUser:
+		if err := s.DisableSharing(ctx, report.OwnerID, reason, adminID); err != nil {
+			return nil, err
+		}
+		settled = bson.M{"ownerId": report.OwnerID}
+	default:
+		return nil, fmt.Errorf("%w: action must be %s, %s or %s", ErrInvalidAbuseReport,
+			models.AbuseActionDismiss, models.AbuseActionDisableShare, models.AbuseActionDisableUser)
+	}
+
+	now := time.Now()
+	settled["status"] = models.AbuseReportOpen
+	if _, err := s.mongoClient.Collection(abuseReportCollection).UpdateMany(ctx, settled, bson.M{"$set": bson.M{
+		"status":     status,
+		"action":     action,
+		"note":       note,
+		"reviewedBy": adminID,
+		"reviewedAt": now,
+	}}); err != nil {
+		return nil, fmt.Errorf("failed to resolve report: %w", err)
+	}
+
+	report.Status = status
+	report.Action = action
+	report.Note = note
+	report.ReviewedBy = adminID
+	report.ReviewedAt = &now
+	return &report, nil
+}
+
+// DisableShare takes the link code down and notifies its owner
+func (s *AbuseService) DisableShare(ctx context.Context, code, reason, adminID string) error {
+	var share models.Share
+	err := s.mongoClient.Collection("shares").FindOneAndUpdate(ctx,
+		bson.M{"code": code},
+		bson.M{"$set": bson.M{"takedown": models.Takedown{Reason: reason, DisabledBy: adminID, DisabledAt: time.Now()}}},
+	).Decode(&share)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrReportedShareGone
+	}
+	if err != nil {
+		return fmt.Errorf("failed to disable share: %w", err)
+	}
+	if share.Takedown == nil {
+		s.notify(ctx, share.CreatorID, "Share Link Disabled",
+			fmt.Sprintf("Your link for '%s' was disabled after a report of abuse: %s", share.Filename, reason))
+	}
+	return nil
+}
+
+// EnableShare restores a link taken down with DisableShare
+func (s *AbuseService) EnableShare(ctx context.Context, code string) error {
+	res, err := s.mongoClient.Collection("shares").UpdateOne(ctx, bson.M{"code": code}, bson.M{"$unset": bson.M{"takedown": ""}})
+	if err != nil {
+		return fmt.Errorf("failed to enable share: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrReportedShareGone
+	}
+	return nil
+}
+
+// DisableSharing stops the user creating links and disables the ones they
+// have, and notifies them
+func (s *AbuseService) DisableSharing(ctx context.Context, firebaseUID, reason, adminID string) error {
+	var user models.User
+	err := s.mongoClient.Users().FindOneAndUpdate(ctx,
+		bson.M{"firebaseUid": firebaseUID},
+		bson.M{"$set": bson.M{
+			"sharingDisabled": models.Takedown{Reason: reason, DisabledBy: adminID, DisabledAt: time.Now()},
+			"updatedAt":       time.Now(),
+		}},
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrTakedownUserMissing
+	}
+	if err != nil {
+		return fmt.Errorf("failed to disable sharing: %w", err)
+	}
+	if user.SharingDisabled == nil {
+		s.notify(ctx, firebaseUID, "Sharing Disabled",
+			"Your share links were disabled and you can no longer create new ones after reports of abuse: "+reason)
+	}
+	return nil
+}
+
+// EnableSharing lets a user whose sharing was disabled share again. Links
+// taken down on their own stay down.
+func (s *AbuseService) EnableSharing(ctx context.Context, firebaseUID string) error {
+	res, err := s.mongoClient.Users().UpdateOne(ctx, bson.M{"firebaseUid": firebaseUID}, bson.M{
+		"$unset": bson.M{"sharingDisabled": ""},
+		"$set":   bson.M{"updatedAt": time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable sharing: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrTakedownUserMissing
+	}
+	return nil
+}
+
+// SharingDisabled returns why the user's sharing was disabled, nil when it
+// wasn't. Lookup failures are logged and treated as not disabled.
+func (s *AbuseService) SharingDisabled(ctx context.Context, firebaseUID string) *models.Takedown {
+	var user struct {
+		SharingDisabled *models.Takedown `bson:"sharingDisabled"`
+	}
+	err := s.mongoClient.Users().FindOne(ctx, bson.M{"firebaseUid": firebaseUID},
+		options.FindOne().SetProjection(bson.M{"sharingDisabled": 1})).Decode(&user)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("[Abuse] Sharing lookup for %s failed: %v", firebaseUID, err)
+		}
+		return nil
+	}
+	return user.SharingDisabled
+}
+
+func (s *AbuseService) notify(ctx context.Context, firebaseUID, title, message string) {
+	if s.notifications == nil || firebaseUID == "" {
+		return
+	}
+	if err := s.notifications.CreateNotification(ctx, FirebaseRecipient(firebaseUID), title, message, models.NotificationTypeWarning); err != nil {
+		log.Printf("[Abuse] Failed to notify %s: %v", firebaseUID, err)
+	}
+}
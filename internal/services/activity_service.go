@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// activityCollection holds users' account activity
+const activityCollection = "user_activity"
+
+// ActivityService records what happens on users' accounts, like the audit
+// log does for administrators
+type ActivityService struct {
+	mongoClient *mongodb.Client
+
+	mu     sync.Mutex
+	logins map[string]int64 // last sign-in recorded per user, to skip repeated tokens
+}
+
+// NewActivityService creates an activity service
+func NewActivityService(mongoClient *mongodb.Client) *ActivityService {
+	return &ActivityService{mongoClient: mongoClient, logins: make(map[string]int64)}
+}
+
+// Record appends an entry to a user's activity. Failures are logged rather
+// than returned so that recording never fails the request.
+func (s *ActivityService) Record(ctx context.Context, entry models.ActivityEntry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if _, err := s.mongoClient.Collection(activityCollection).InsertOne(ctx, entry); err != nil {
+		log.Printf("[Activity] Failed to record %s for %s: %v", entry.Action, entry.UserID, err)
+	}
+}
+
+// RecordLogin records a sign-in seen on a verified token. Every request
+// carries a token, so each sign-in (its auth_time) is recorded once.
+func (s *ActivityService) RecordLogin(ctx context.Context, userID string, authTime int64, ip, userAgent string) {
+	s.mu.Lock()
+	seen := s.logins[userID] == authTime
+	s.logins[userID] = authTime
+	s.mu.Unlock()
+	if seen {
+		return
+	}
+
+	entry := models.ActivityEntry{
+		UserID:    userID,
+		Action:    models.ActivityLogin,
+		IP:        ip,
+		UserAgent: userAgent,
+		AuthTime:  authTime,
+		CreatedAt: time.Now(),
+	}
+	filter := bson.M{"userId": userID, "action": models.ActivityLogin, "authTime": authTime}
+	_, err := s.mongoClient.Collection(activityCollection).UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": entry}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[Activity] Failed to record login for %s: %v", userID, err)
+	}
+}
+
+// List returns a user's newest activity, optionally only one action
+func (s *ActivityService) List(ctx context.Context, userID, action string, limit int) ([]models.ActivityEntry, error) {
+	filter := bson.M{"userId": userID}
+	if action != "" {
+		filter["action"] = action
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := s.mongoClient.Collection(activityCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.ActivityEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+const (
+	// maxAIAutoSplitPages bounds the pages shown to the model; documents
+	// after them are kept as detected from layout
+	maxAIAutoSplitPages = 150
+	// autoSplitPageChars bounds the text of one page shown to the model
+	autoSplitPageChars = 400
+)
+
+// ClassifyDocuments has the AI decide where documents start in a stack of
+// pages, given the text of each page and the documents found from layout,
+// and name each one's type, vendor and date. What the model leaves out is
+// taken from the layout.
+func (s *AIService) ClassifyDocuments(ctx context.Context, pages []DocumentPage, detected []models.DetectedDocument) ([]models.DetectedDocument, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("OpenRouter API not configured")
+	}
+
+	reviewed := pages
+	if len(reviewed) > maxAIAutoSplitPages {
+		reviewed = reviewed[:maxAIAutoSplitPages]
+	}
+	layoutStarts := map[int]bool{}
+	for _, doc := range detected {
+		layoutStarts[doc.FirstPage] = true
+	}
+
+	var list strings.Builder
+	for _, page := range reviewed {
+		hint := "continues"
+		if layoutStarts[page.Page] {
+			hint = "may start a document"
+		}
+		text := strings.TrimSpace(page.Text)
+		if text == "" {
+			text = "(no text)"
+		}
+		list.WriteString(fmt.Sprintf("\n--- PAGE %d (layout: %s) ---\n%s\n", page.Page, hint, truncateText(text, autoSplitPageChars)))
+	}
+
+	log.Printf("[AI] ClassifyDocuments: reading %d of %d pages...", len(reviewed), len(pages))
+
+	prompt := fmt.Sprintf(`These pages come from one PDF holding several separate documents scanned or printed together, such as invoices, receipts and statements. Each page shows its first lines of text and whether its layout suggests it starts a new document.
+
+Group the pages into documents. A document is a run of consecutive pages; a page starts a new document when it begins a different invoice, receipt, letter or form, not when it continues the previous one. For each document give:
+- startPage: its first page
+- type: invoice, receipt, statement, credit note, purchase order, quote, letter, contract, form or other
+- vendor: the company or person who issued it, empty if unknown
+- date: its issue date as YYYY-MM-DD, empty if unknown
+- confidence: 0.0-1.0 that the document starts on startPage
+
+Pages:
+%s
+Respond in JSON format only:
+{"documents": [{"startPage": 1, "type": "invoice", "vendor": "...", "date": "2024-03-01", "confidence": 0.9}]}`, list.String())
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify documents: %w", err)
+	}
+
+	var parsed struct {
+		Documents []struct {
+			StartPage  int     `json:"startPage"`
+			Type       string  `json:"type"`
+			Vendor     string  `json:"vendor"`
+			Date       string  `json:"date"`
+			Confidence float64 `json:"confidence"`
+		} `json:"documents"`
+	}
+	if err := decodeJSONObject(responseText, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse AI documents: %w", err)
+	}
+
+	byStart := map[int]models.DetectedDocument{}
+	for _, d := range parsed.Documents {
+		if d.StartPage < 1 || d.StartPage > len(reviewed) {
+			continue
+		}
+		if d.Confidence <= 0 || d.Confidence > 1 {
+			d.Confidence = 0.8
+		}
+		byStart[d.StartPage] = models.DetectedDocument{
+			FirstPage:    d.StartPage,
+			DocumentType: strings.ToLower(strings.TrimSpace(d.Type)),
+			Vendor:       strings.TrimSpace(d.Vendor),
+			Date:         findDate(d.Date),
+			Confidence:   d.Confidence,
+			Source:       models.BoundarySourceAI,
+		}
+	}
+	if len(byStart) == 0 {
+		return nil, fmt.Errorf("unexpected AI response for documents")
+	}
+	if _, ok := byStart[1]; !ok {
+		byStart[1] = models.DetectedDocument{FirstPage: 1, Confidence: 1, Source: models.BoundarySourceAI}
+	}
+
+	// Layout signals still explain boundaries the model agrees with
+	signals := map[int][]string{}
+	for _, doc := range detected {
+		signals[doc.FirstPage] = doc.Signals
+	}
+	docs := make([]models.DetectedDocument, 0, len(byStart))
+	for _, doc := range byStart {
+		doc.Signals = signals[doc.FirstPage]
+		docs = append(docs, doc)
+	}
+	for _, doc := range detected {
+		if doc.FirstPage > len(reviewed) {
+			docs = append(docs, models.DetectedDocument{
+				FirstPage:  doc.FirstPage,
+				Confidence: doc.Confidence,
+				Source:     doc.Source,
+				Signals:    doc.Signals,
+			})
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].FirstPage < docs[j].FirstPage })
+
+	return closeDocuments(docs, pages), nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+// contractFindings is what one section of a contract yields
+type contractFindings struct {
+	Parties      []models.ContractParty  `json:"parties"`
+	Dates        []models.ContractDate   `json:"dates"`
+	Renewal      []models.ContractClause `json:"renewal"`
+	Termination  []models.ContractClause `json:"termination"`
+	Liabilities  []models.ContractClause `json:"liabilities"`
+	UnusualTerms []models.ContractClause `json:"unusual_terms"`
+}
+
+// contractRiskRank orders risk levels for picking the most serious
+var contractRiskRank = map[string]int{
+	models.ContractRiskLow:    1,
+	models.ContractRiskMedium: 2,
+	models.ContractRiskHigh:   3,
+}
+
+// ReviewContract identifies the parties, dates and key clauses of a
+// contract, given the text of each page, and rates the risk to a party
+// signing it. Groups of pages are read in parallel with page markers so
+// every finding cites its pages; the merged findings are then assessed as
+// a whole.
+func (s *AIService) ReviewContract(ctx context.Context, pages []string) (*models.ContractReview, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("OpenRouter API not configured")
+	}
+
+	marked := make([]string, len(pages))
+	for i, page := range pages {
+		if strings.TrimSpace(page) != "" {
+			marked[i] = fmt.Sprintf("[Page %d]\n%s", i+1, page)
+		}
+	}
+	sections := groupPages(marked, summarySectionChars)
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no text to review")
+	}
+
+	log.Printf("[AI] ReviewContract: reading %d sections...", len(sections))
+
+	found := make([]contractFindings, len(sections))
+	err := runLimited(ctx, len(sections), summaryConcurrency, func(ctx context.Context, i int) error {
+		var err error
+		found[i], err = s.reviewContractSection(ctx, sections[i], i, len(sections), len(pages))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	review := mergeContractFindings(found)
+	review.PageCount = len(pages)
+	if err := s.assessContract(ctx, review); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[AI] ReviewContract completed. Risk: %s", review.RiskLevel)
+	return review, nil
+}
+
+// reviewContractSection extracts the findings of one group of pages
+func (s *AIService) reviewContractSection(ctx context.Context, section textSection, index, total, pageCount int) (contractFindings, error) {
+	prompt := fmt.Sprintf(`You are a contract analyst reviewing %s of a contract with %d pages. Markers like [Page 3] show where each page starts.
+
+Extract from this text only, with the page numbers where each item appears:
+- parties: the contracting parties and their roles
+- dates: effective date, term, expiry, renewal and notice deadlines, payment dates
+- renewal: automatic renewal, extension and price changes on renewal
+- termination: termination rights, notice periods, termination fees
+- liabilities: liability caps or their absence, indemnities, penalties, warranties
+- unusual_terms: terms that are one-sided, unusually broad or uncommon for this kind of contract
+
+Rate each clause's risk to a party signing the contract as "low", "medium" or "high". Quote at most 30 words of the contract wording. Use empty arrays for categories that do not appear.
+
+Output strictly in this JSON format:
+{
+  "parties": [{"name": "...", "role": "...", "pages": [1]}],
+  "dates": [{"label": "Effective date", "date": "...", "pages": [1]}],
+  "renewal": [{"title": "...", "summary": "...", "risk": "low", "quote": "...", "pages": [2]}],
+  "termination": [],
+  "liabilities": [],
+  "unusual_terms": []
+}
+
+Contract Text:
+%s`, strings.ToLower(section.label(index, total)), pageCount, section.Text)
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return contractFindings{}, fmt.Errorf("failed to review contract: %w", err)
+	}
+
+	var found contractFindings
+	if err := decodeJSONObject(responseText, &found); err != nil {
+		// One unreadable section should not sink the review
+		log.Printf("[AI] ReviewContract: section %d: %v", index+1, err)
+		return contractFindings{}, nil
+	}
+
+	first, last := section.FirstPage, section.LastPage
+	if first == 0 {
+		first, last = 1, pageCount
+	}
+	for i := range found.Parties {
+		found.Parties[i].Pages = clampPages(found.Parties[i].Pages, first, last)
+	}
+	for i := range found.Dates {
+		found.Dates[i].Pages = clampPages(found.Dates[i].Pages, first, last)
+	}
+	for _, clauses := range [][]models.ContractClause{found.Renewal, found.Termination, found.Liabilities, found.UnusualTerms} {
+		for i := range clauses {
+			clauses[i].Pages = clampPages(clauses[i].Pages, first, last)
+			clauses[i].Risk = normalizeContractRisk(clauses[i].Risk)
+		}
+	}
+	return found, nil
+}
+
+// assessContract fills in the overall risk level, summary and main risks
+// from the merged findings
+func (s *AIService) assessContract(ctx context.Context, review *models.ContractReview) error {
+	findings, _ := json.Marshal(contractFindings{
+		Parties:      review.Parties,
+		Dates:        review.Dates,
+		Renewal:      review.Renewal,
+		Termination:  review.Termination,
+		Liabilities:  review.Liabilities,
+		UnusualTerms: review.UnusualTerms,
+	})
+
+	prompt := fmt.Sprintf(`You are a contract analyst. Below are the parties, dates and key clauses found in a %d-page contract, with page numbers.
+
+Assess the contract for a party about to sign it:
+1. Give an overall risk level: "low", "medium" or "high".
+2. Write a plain-language summary of what the contract does and its main obligations (1-2 paragraphs).
+3. List the main risks or points to negotiate, most serious first, citing pages like "(p. 4)".
+
+Output strictly in this JSON format:
+{
+  "risk_level": "medium",
+  "summary": "...",
+  "risks": ["...", "..."]
+}
+
+Findings:
+%s`, review.PageCount, truncateText(string(findings), summaryReduceChars))
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return fmt.Errorf("failed to assess contract: %w", err)
+	}
+
+	var assessment struct {
+		RiskLevel string   `json:"risk_level"`
+		Summary   string   `json:"summary"`
+		Risks     []string `json:"risks"`
+	}
+	if err := decodeJSONObject(responseText, &assessment); err != nil {
+		log.Printf("[AI] ReviewContract: assessment: %v", err)
+		assessment.Summary = strings.TrimSpace(responseText)
+	}
+
+	review.Summary = assessment.Summary
+	review.Risks = assessment.Risks
+	if review.Risks == nil {
+		review.Risks = []string{}
+	}
+
+	// The overall level is never below the most serious clause
+	review.RiskLevel = models.ContractRiskLow
+	if level := strings.ToLower(strings.TrimSpace(assessment.RiskLevel)); contractRiskRank[level] > 0 {
+		review.RiskLevel = level
+	}
+	for _, clauses := range [][]models.ContractClause{review.Renewal, review.Termination, review.Liabilities, review.UnusualTerms} {
+		for _, c := range clauses {
+			if contractRiskRank[c.Risk] > contractRiskRank[review.RiskLevel] {
+				review.RiskLevel = c.Risk
+			}
+		}
+	}
+	return nil
+}
+
+// mergeContractFindings joins the findings of all sections in page order,
+// merging parties and dates found in more than one
+func mergeContractFindings(found []contractFindings) *models.ContractReview {
+	review := &models.ContractReview{
+		Parties:      []models.ContractParty{},
+		Dates:        []models.ContractDate{},
+		Renewal:      []models.ContractClause{},
+		Termination:  []models.ContractClause{},
+		Liabilities:  []models.ContractClause{},
+		UnusualTerms: []models.ContractClause{},
+	}
+
+	parties := map[string]int{}
+	dates := map[string]int{}
+	for _, f := range found {
+		for _, p := range f.Parties {
+			key := strings.ToLower(strings.TrimSpace(p.Name))
+			if key == "" {
+				continue
+			}
+			if i, ok := parties[key]; ok {
+				review.Parties[i].Pages = mergePages(review.Parties[i].Pages, p.Pages)
+				if review.Parties[i].Role == "" {
+					review.Parties[i].Role = p.Role
+				}
+				continue
+			}
+			parties[key] = len(review.Parties)
+			review.Parties = append(review.Parties, p)
+		}
+		for _, d := range f.Dates {
+			key := strings.ToLower(strings.TrimSpace(d.Label)) + "|" + strings.ToLower(strings.TrimSpace(d.Date))
+			if strings.TrimSpace(d.Date) == "" {
+				continue
+			}
+			if i, ok := dates[key]; ok {
+				review.Dates[i].Pages = mergePages(review.Dates[i].Pages, d.Pages)
+				continue
+			}
+			dates[key] = len(review.Dates)
+			review.Dates = append(review.Dates, d)
+		}
+		review.Renewal = append(review.Renewal, f.Renewal...)
+		review.Termination = append(review.Termination, f.Termination...)
+		review.Liabilities = append(review.Liabilities, f.Liabilities...)
+		review.UnusualTerms = append(review.UnusualTerms, f.UnusualTerms...)
+	}
+	return review
+}
+
+// clampPages drops page numbers outside first..last; an empty result falls
+// back to first
+func clampPages(pages []int, first, last int) []int {
+	out := []int{}
+	for _, p := range pages {
+		if p >= first && p <= last {
+			out = mergePages(out, []int{p})
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, first)
+	}
+	return out
+}
+
+// mergePages returns the sorted union of two page lists
+func mergePages(a, b []int) []int {
+	seen := map[int]bool{}
+	out := []int{}
+	for _, p := range append(append([]int{}, a...), b...) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+func normalizeContractRisk(risk string) string {
+	risk = strings.ToLower(strings.TrimSpace(risk))
+	if contractRiskRank[risk] == 0 {
+		return models.ContractRiskMedium
+	}
+	return risk
+}
+
+// decodeJSONObject unmarshals the outermost JSON object in a model
+// response, which may be wrapped in markdown or prose
+func decodeJSONObject(responseText string, v interface{}) error {
+	jsonStart := strings.Index(responseText, "{")
+	jsonEnd := strings.LastIndex(responseText, "}")
+	if jsonStart == -1 || jsonEnd < jsonStart {
+		return fmt.Errorf("AI response was not in expected JSON format")
+	}
+	return json.Unmarshal([]byte(responseText[jsonStart:jsonEnd+1]), v)
+}
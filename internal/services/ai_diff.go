@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+// diffChangeChars bounds the text of one change shown to the model
+const diffChangeChars = 800
+
+// SummarizeDiff has the AI pick out the material changes between two
+// versions of a document from their text diff and summarize them. Large
+// diffs are read in parallel groups whose material changes are then
+// summarized together.
+func (s *AIService) SummarizeDiff(ctx context.Context, changes []models.TextChange) (string, []models.MaterialChange, error) {
+	if s.apiKey == "" {
+		return "", nil, fmt.Errorf("OpenRouter API not configured")
+	}
+
+	// Each change becomes a section so groups keep revised page ranges
+	var sections []textSection
+	for _, c := range changes {
+		sections = append(sections, textSection{FirstPage: c.PageAfter, LastPage: c.PageAfter, Text: formatChange(c)})
+	}
+	batches := batchSections(sections, summarySectionChars)
+
+	log.Printf("[AI] SummarizeDiff: %d changes in %d groups", len(changes), len(batches))
+
+	found := make([][]models.MaterialChange, len(batches))
+	summaries := make([]string, len(batches))
+	err := runLimited(ctx, len(batches), summaryConcurrency, func(ctx context.Context, i int) error {
+		var err error
+		summaries[i], found[i], err = s.materialChanges(ctx, batches[i])
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	material := []models.MaterialChange{}
+	for _, f := range found {
+		material = append(material, f...)
+	}
+	if len(batches) == 1 {
+		return summaries[0], material, nil
+	}
+
+	summary, err := s.summarizeMaterialChanges(ctx, material)
+	if err != nil {
+		return "", nil, err
+	}
+	return summary, material, nil
+}
+
+// materialChanges reads one group of changes
+func (s *AIService) materialChanges(ctx context.Context, batch []textSection) (string, []models.MaterialChange, error) {
+	var diff strings.Builder
+	for _, sec := range batch {
+		diff.WriteString(sec.Text)
+		diff.WriteString("\n")
+	}
+
+	prompt := fmt.Sprintf(`You are comparing two versions of a document, such as two drafts of a contract. Below are the text differences between the original and the revised version, in document order, with page numbers in the revised version.
+
+1. Identify the MATERIAL changes: those that alter rights, obligations, amounts, prices, dates, deadlines, scope, parties or risk. Ignore formatting, typo fixes, renumbering and rewording that keeps the meaning.
+2. Rate each change's impact on a reader of the revised version as "low", "medium" or "high".
+3. Write a short plain-language summary of what changed overall.
+
+Output strictly in this JSON format:
+{
+  "summary": "...",
+  "material_changes": [{"title": "...", "description": "what changed, from what to what", "impact": "medium", "pages": [3]}]
+}
+
+Differences:
+%s`, diff.String())
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize changes: %w", err)
+	}
+
+	var result struct {
+		Summary         string                  `json:"summary"`
+		MaterialChanges []models.MaterialChange `json:"material_changes"`
+	}
+	if err := decodeJSONObject(responseText, &result); err != nil {
+		log.Printf("[AI] SummarizeDiff: %v", err)
+		return strings.TrimSpace(responseText), nil, nil
+	}
+
+	first, last := batch[0].FirstPage, batch[len(batch)-1].LastPage
+	for i := range result.MaterialChanges {
+		result.MaterialChanges[i].Pages = clampPages(result.MaterialChanges[i].Pages, first, last)
+		result.MaterialChanges[i].Impact = normalizeContractRisk(result.MaterialChanges[i].Impact)
+	}
+	return result.Summary, result.MaterialChanges, nil
+}
+
+// summarizeMaterialChanges writes the overall summary when the diff was
+// read in several groups
+func (s *AIService) summarizeMaterialChanges(ctx context.Context, material []models.MaterialChange) (string, error) {
+	var list strings.Builder
+	for _, m := range material {
+		fmt.Fprintf(&list, "- [%s impact, p. %s] %s: %s\n", m.Impact, joinPages(m.Pages), m.Title, m.Description)
+	}
+	if list.Len() == 0 {
+		return "The documents differ only in wording or formatting; no material changes were found.", nil
+	}
+
+	prompt := fmt.Sprintf(`Below are the material changes between two versions of a document. Write a plain-language summary (1-2 paragraphs) of what changed overall, leading with the changes of highest impact. Output only the summary.
+
+Changes:
+%s`, truncateText(list.String(), summaryReduceChars))
+
+	summary, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize changes: %w", err)
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// formatChange lays out one change for the model
+func formatChange(c models.TextChange) string {
+	switch c.Type {
+	case models.TextAdded:
+		return fmt.Sprintf("[Added, p. %d]\n+ %s\n", c.PageAfter, truncateText(c.After, diffChangeChars))
+	case models.TextRemoved:
+		return fmt.Sprintf("[Removed, was p. %d, now p. %d]\n- %s\n", c.PageBefore, c.PageAfter, truncateText(c.Before, diffChangeChars))
+	}
+	return fmt.Sprintf("[Changed, p. %d]\n- %s\n+ %s\n", c.PageAfter, truncateText(c.Before, diffChangeChars), truncateText(c.After, diffChangeChars))
+}
+
+func joinPages(pages []int) string {
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// entityAIChars bounds the text shown to the model when naming the people
+// and companies in a document; later pages are matched by pattern only
+const entityAIChars = 12000
+
+// ExtractEntityNames has the AI list the people and companies a document
+// mentions, given the text of each page. Only names are returned; the
+// caller finds where they appear.
+func (s *AIService) ExtractEntityNames(ctx context.Context, pages []string) (people, companies []string, err error) {
+	if s.apiKey == "" {
+		return nil, nil, fmt.Errorf("OpenRouter API not configured")
+	}
+	sections := groupPages(pages, entityAIChars)
+	if len(sections) == 0 {
+		return nil, nil, nil
+	}
+
+	prompt := fmt.Sprintf(`List the people and the companies or organizations named in this document text, such as senders, recipients, vendors, customers, signatories and contacts.
+
+Write each name exactly as it appears in the text. Leave out generic roles ("the Customer"), job titles, products, places and email addresses. Use empty arrays when there are none.
+
+Respond in JSON format only:
+{"people": ["Jane Smith"], "companies": ["Acme Supplies Ltd"]}
+
+Document Text:
+%s`, sections[0].Text)
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to extract entities: %w", err)
+	}
+
+	var parsed struct {
+		People    []string `json:"people"`
+		Companies []string `json:"companies"`
+	}
+	if err := decodeJSONObject(responseText, &parsed); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse AI entities: %w", err)
+	}
+	for _, name := range parsed.People {
+		if name = strings.TrimSpace(name); name != "" {
+			people = append(people, name)
+		}
+	}
+	for _, name := range parsed.Companies {
+		if name = strings.TrimSpace(name); name != "" {
+			companies = append(companies, name)
+		}
+	}
+	return people, companies, nil
+}
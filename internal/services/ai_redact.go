@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// redactAIChars bounds the text shown to the model per request when
+	// finding personal information to redact
+	redactAIChars = 12000
+	// redactAISections bounds the requests per document; later pages are
+	// redacted by pattern only
+	redactAISections = 10
+)
+
+// SensitiveText is personal information found by the AI, written exactly
+// as it appears in the text
+type SensitiveText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// FindSensitiveText has the AI find personal information that patterns
+// can't, such as names, addresses and dates of birth, given the text of
+// each page. It returns the findings and the number of pages reviewed,
+// which is less than len(pages) for documents longer than
+// redactAISections requests.
+func (s *AIService) FindSensitiveText(ctx context.Context, pages []string) ([]SensitiveText, int, error) {
+	if s.apiKey == "" {
+		return nil, 0, fmt.Errorf("OpenRouter API not configured")
+	}
+	sections := groupPages(pages, redactAIChars)
+	reviewed := len(pages)
+	if len(sections) > redactAISections {
+		// Pages before the first section left out were reviewed in full
+		reviewed = sections[redactAISections].FirstPage - 1
+		if reviewed < 0 {
+			reviewed = 0
+		}
+		sections = sections[:redactAISections]
+	}
+
+	seen := make(map[string]bool)
+	var found []SensitiveText
+	for _, section := range sections {
+		prompt := fmt.Sprintf(`Find the personal information in this document text that should be redacted before sharing it: names of people, postal addresses, dates of birth, account and policy numbers, medical details and legal case numbers.
+
+Copy each item exactly as it appears in the text, character for character, so it can be found again. Leave out email addresses, phone numbers, card numbers and social security numbers, company names, job titles and generic roles ("the Patient"). Use an empty array when there are none.
+
+Respond in JSON format only:
+{"findings": [{"type": "name", "text": "Jane Smith"}, {"type": "address", "text": "12 High Street"}]}
+
+Document Text:
+%s`, section.Text)
+
+		responseText, err := s.callOpenRouter(ctx, prompt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to find sensitive text: %w", err)
+		}
+		var parsed struct {
+			Findings []SensitiveText `json:"findings"`
+		}
+		if err := decodeJSONObject(responseText, &parsed); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse AI findings: %w", err)
+		}
+		for _, f := range parsed.Findings {
+			f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+			f.Text = strings.Join(strings.Fields(f.Text), " ")
+			if f.Text == "" || seen[f.Type+"\x00"+f.Text] {
+				continue
+			}
+			if f.Type == "" {
+				f.Type = "personal"
+			}
+			seen[f.Type+"\x00"+f.Text] = true
+			found = append(found, f)
+		}
+	}
+	return found, reviewed, nil
+}
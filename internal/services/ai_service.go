@@ -11,6 +11,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -24,6 +25,7 @@ import (
 // OpenRouter API configuration
 const (
 	OpenRouterAPIURL   = "https://openrouter.ai/api/v1/chat/completions"
+	OpenRouterKeyURL   = "https://openrouter.ai/api/v1/auth/key" // describes the key in use
 	OpenRouterModel    = "google/gemma-3-27b-it:free"
 )
 
@@ -51,7 +53,7 @@ type ChatResponse struct {
 	Choices []ChatChoice `json:"choices"`
 	Error   *struct {
 		Message string `json:"message"`
-		Code    string `json:"code"`
+		Code    int    `json:"code"` // HTTP-style status, also sent with 200 responses
 	} `json:"error,omitempty"`
 }
 
@@ -83,6 +85,87 @@ func NewAIService(ctx context.Context, openRouterAPIKey string) (*AIService, err
 	}, nil
 }
 
+// Capability reports whether AI features backed by OpenRouter are usable.
+// Safe to call on a nil service.
+func (s *AIService) Capability() Capability {
+	c := Capability{Name: CapabilityAI}
+	switch {
+	case s == nil:
+		c.Reason = "AI service failed to start"
+	case s.apiKey == "":
+		c.Reason = "OpenRouter API key is not configured"
+	default:
+		c.Available = true
+	}
+	return c
+}
+
+// OCRCapability reports whether scanned PDFs can be OCRed (requires
+// Tesseract); text-based PDFs are still read without it
+func (s *AIService) OCRCapability() Capability {
+	c := Capability{Name: CapabilityOCR}
+	switch {
+	case s == nil:
+		c.Reason = "AI service failed to start"
+	default:
+		if _, err := exec.LookPath("tesseract"); err != nil {
+			c.Reason = "Tesseract is not installed; only text-based PDFs can be read"
+		} else {
+			c.Available = true
+		}
+	}
+	return c
+}
+
+// SetHTTPClient replaces the client used to call OpenRouter, e.g. with one
+// that replays recorded responses
+func (s *AIService) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// newOpenRouterRequest builds a chat completion request with the headers
+// OpenRouter expects; HTTP-Referer and X-Title attribute the app on
+// openrouter.ai
+func (s *AIService) newOpenRouterRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, OpenRouterAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("HTTP-Referer", "https://binarypdf.com")
+	req.Header.Set("X-Title", "BinaryPDF")
+	return req, nil
+}
+
+// CheckKey verifies the API key with OpenRouter without running a model
+func (s *AIService) CheckKey(ctx context.Context) error {
+	if s.apiKey == "" {
+		return fmt.Errorf("OpenRouter API key not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, OpenRouterKeyURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach OpenRouter: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("OpenRouter rejected the API key")
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("OpenRouter returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // callOpenRouter makes a request to the OpenRouter API with retry logic
 func (s *AIService) callOpenRouter(ctx context.Context, prompt string) (string, error) {
 	if s.apiKey == "" {
@@ -118,16 +201,11 @@ func (s *AIService) callOpenRouter(ctx context.Context, prompt string) (string,
 			}
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", OpenRouterAPIURL, bytes.NewBuffer(jsonData))
+		req, err := s.newOpenRouterRequest(ctx, jsonData)
 		if err != nil {
 			return "", fmt.Errorf("failed to create request: %w", err)
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+s.apiKey)
-		req.Header.Set("HTTP-Referer", "https://binarypdf.com")
-		req.Header.Set("X-Title", "BinaryPDF")
-
 		log.Printf("[AI] Calling OpenRouter with model: %s (attempt %d)", OpenRouterModel, attempt+1)
 
 		resp, err := s.httpClient.Do(req)
@@ -184,7 +262,7 @@ type OCRServiceResult struct {
 // ExtractTextOCR extracts text from a scanned PDF
 // Note: OpenRouter text models don't support vision, so this returns a fallback message
 // The AI handler falls back to text extraction for regular PDFs
-func (s *AIService) ExtractTextOCR(ctx context.Context, pdfData []byte) (*OCRServiceResult, error) {
+func (s *AIService) ExtractTextOCR(ctx context.Context, in PDFReader) (*OCRServiceResult, error) {
 	// OpenRouter's text-only models don't support vision/OCR
 	// Return an error to let the handler fall back to text extraction
 	return nil, fmt.Errorf("OCR not available: current AI model does not support image processing")
@@ -248,6 +326,36 @@ func (s *AIService) SummarizePDF(ctx context.Context, text string, length string
 		return nil, fmt.Errorf("OpenRouter API not configured")
 	}
 
+	wordCount := len(strings.Fields(text))
+
+	// Documents beyond one section are summarized hierarchically rather
+	// than cut off
+	sections := groupPages(SplitPages(text), summarySectionChars)
+	if len(sections) > 1 {
+		return s.summarizeSections(ctx, sections, length, wordCount)
+	}
+
+	prompt := summaryPrompt(length, "Document Content:", text)
+
+	log.Printf("[AI] SummarizePDF: calling OpenRouter...")
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate analysis: %w", err)
+	}
+
+	result, err := parseSummarizeResult(responseText, wordCount)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[AI] SummarizePDF completed successfully. Type: %s", result.DocumentType)
+	return result, nil
+}
+
+// summaryPrompt builds the document intelligence prompt shared by
+// SummarizePDF and CombineSummaries
+func summaryPrompt(length, contentLabel, content string) string {
 	lengthInstruction := "medium length (2-3 paragraphs)"
 	switch length {
 	case "short":
@@ -256,7 +364,7 @@ func (s *AIService) SummarizePDF(ctx context.Context, text string, length string
 		lengthInstruction = "detailed (4-5 paragraphs)"
 	}
 
-	prompt := fmt.Sprintf(`You are an advanced Document Intelligence AI.
+	return fmt.Sprintf(`You are an advanced Document Intelligence AI.
 	
 Document processing context:
 1. Treat OCR text as VALID HUMAN CONTENT, even if it has minor noise.
@@ -283,28 +391,24 @@ Output strictly in this JSON format:
   "summary": "..."
 }
 
-Document Content:
-%s`, lengthInstruction, truncateText(text, 30000))
-
-	log.Printf("[AI] SummarizePDF: calling OpenRouter...")
-
-	responseText, err := s.callOpenRouter(ctx, prompt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate analysis: %w", err)
-	}
+%s
+%s`, lengthInstruction, contentLabel, content)
+}
 
-	// Parse JSON response
+// parseSummarizeResult reads the JSON object out of a summary response,
+// falling back to the raw text as the summary when it does not parse
+func parseSummarizeResult(responseText string, wordCount int) (*SummarizeResult, error) {
 	// Find JSON start and end to handle potential markdown formatting
 	jsonStart := strings.Index(responseText, "{")
 	jsonEnd := strings.LastIndex(responseText, "}")
-	
+
 	if jsonStart == -1 || jsonEnd == -1 || jsonEnd < jsonStart {
 		log.Printf("[AI] Error: valid JSON not found in response: %s", responseText)
 		return nil, fmt.Errorf("AI response was not in expected JSON format")
 	}
-	
+
 	jsonContent := responseText[jsonStart : jsonEnd+1]
-	
+
 	var result SummarizeResult
 	if err := json.Unmarshal([]byte(jsonContent), &result); err != nil {
 		log.Printf("[AI] JSON unmarshal error: %v. Content: %s", err, jsonContent)
@@ -312,14 +416,11 @@ Document Content:
 		return &SummarizeResult{
 			DocumentType: "Unknown",
 			Summary:      responseText,
-			WordCount:    len(strings.Fields(text)),
+			WordCount:    wordCount,
 		}, nil
 	}
 
-	// Calculate word count from input text
-	result.WordCount = len(strings.Fields(text))
-
-	log.Printf("[AI] SummarizePDF completed successfully. Type: %s", result.DocumentType)
+	result.WordCount = wordCount
 	return &result, nil
 }
 
@@ -380,16 +481,11 @@ Context:
 			}
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", OpenRouterAPIURL, bytes.NewBuffer(jsonData))
+		req, err := s.newOpenRouterRequest(ctx, jsonData)
 		if err != nil {
 			return "", fmt.Errorf("failed to create request: %w", err)
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+s.apiKey)
-		req.Header.Set("HTTP-Referer", "https://binarypdf.com")
-		req.Header.Set("X-Title", "BinaryPDF")
-
 		log.Printf("[AI] Calling OpenRouter Chat with model: %s (attempt %d)", OpenRouterModel, attempt+1)
 
 		resp, err := s.httpClient.Do(req)
@@ -422,6 +518,10 @@ Context:
 			return "", fmt.Errorf("failed to parse response: %w", err)
 		}
 
+		if chatResp.Error != nil {
+			return "", fmt.Errorf("API error: %s", chatResp.Error.Message)
+		}
+
 		if len(chatResp.Choices) == 0 {
 			return "", fmt.Errorf("no response from AI model")
 		}
@@ -440,24 +540,34 @@ type SensitiveDataServiceResult struct {
 	Types    map[string]int                `json:"types"`
 }
 
-// DetectSensitiveData detects sensitive information in text
-func (s *AIService) DetectSensitiveData(ctx context.Context, text string) (*SensitiveDataServiceResult, error) {
+// sensitivePatterns match the sensitive data types found without AI
+var sensitivePatterns = map[string]*regexp.Regexp{
+	"email":       regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+	"phone":       regexp.MustCompile(`(\+\d{1,3}[-.\s]?)?\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}`),
+	"ssn":         regexp.MustCompile(`\d{3}-\d{2}-\d{4}`),
+	"credit_card": regexp.MustCompile(`\d{4}[-\s]?\d{4}[-\s]?\d{4}[-\s]?\d{4}`),
+	"ip_address":  regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`),
+	"aadhaar":     regexp.MustCompile(`\b[2-9]\d{3}[\s-]?\d{4}[\s-]?\d{4}\b`),
+}
+
+// sensitiveValidators drop pattern matches that fail a checksum
+var sensitiveValidators = map[string]func(string) bool{
+	"aadhaar": validAadhaar,
+}
+
+// DetectSensitivePatterns finds sensitive data by pattern alone, without
+// calling the AI
+func DetectSensitivePatterns(text string) *SensitiveDataServiceResult {
 	result := &SensitiveDataServiceResult{
 		Types: make(map[string]int),
 	}
 
-	// Use regex patterns for common sensitive data types
-	patterns := map[string]*regexp.Regexp{
-		"email":       regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
-		"phone":       regexp.MustCompile(`(\+\d{1,3}[-.\s]?)?\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}`),
-		"ssn":         regexp.MustCompile(`\d{3}-\d{2}-\d{4}`),
-		"credit_card": regexp.MustCompile(`\d{4}[-\s]?\d{4}[-\s]?\d{4}[-\s]?\d{4}`),
-		"ip_address":  regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`),
-	}
-
-	for dataType, pattern := range patterns {
+	for dataType, pattern := range sensitivePatterns {
 		matches := pattern.FindAllString(text, -1)
 		for _, match := range matches {
+			if valid := sensitiveValidators[dataType]; valid != nil && !valid(match) {
+				continue
+			}
 			result.Findings = append(result.Findings, models.SensitiveDataFinding{
 				Type:     dataType,
 				Value:    maskSensitiveValue(match, dataType),
@@ -468,6 +578,14 @@ func (s *AIService) DetectSensitiveData(ctx context.Context, text string) (*Sens
 		}
 	}
 
+	result.Total = len(result.Findings)
+	return result
+}
+
+// DetectSensitiveData detects sensitive information in text
+func (s *AIService) DetectSensitiveData(ctx context.Context, text string) (*SensitiveDataServiceResult, error) {
+	result := DetectSensitivePatterns(text)
+
 	// If OpenRouter AI is available, use it for more sophisticated detection
 	if s.apiKey != "" && len(result.Findings) == 0 {
 		aiResult, err := s.detectWithAI(ctx, text)
@@ -778,6 +896,81 @@ Respond in JSON format:
 	return result, nil
 }
 
+// maxAIHeadingCandidates bounds the candidates sent to the model; any
+// beyond it are kept as detected
+const maxAIHeadingCandidates = 300
+
+// RefineHeadings asks the model which detected candidates are real section
+// headings and at which level. Candidates it rejects are dropped; the ones
+// it keeps are marked as AI-sourced.
+func (s *AIService) RefineHeadings(ctx context.Context, candidates []models.Heading, bodyFontSize float64) ([]models.Heading, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	reviewed := candidates
+	if len(reviewed) > maxAIHeadingCandidates {
+		reviewed = reviewed[:maxAIHeadingCandidates]
+	}
+
+	var list strings.Builder
+	for i, h := range reviewed {
+		list.WriteString(fmt.Sprintf("%d | page %d | %.1fpt | %s\n", i, h.Page, h.FontSize, truncateText(h.Title, 150)))
+	}
+
+	prompt := fmt.Sprintf(`These lines were extracted from a PDF as possible section headings. Body text is set in %.1fpt.
+
+Decide which lines are real headings that belong in a table of contents, and give each a level:
+1 for chapters or top-level sections, 2 for subsections, 3 for anything deeper.
+Drop running headers, captions, table cells, sentences and other false positives.
+
+Candidates (index | page | font size | text):
+%s
+Respond in JSON format only:
+{"headings": [{"index": 0, "level": 1}]}`, bodyFontSize, list.String())
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refine headings: %w", err)
+	}
+
+	start, end := strings.Index(responseText, "{"), strings.LastIndex(responseText, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("unexpected AI response for headings")
+	}
+	var parsed struct {
+		Headings []struct {
+			Index int `json:"index"`
+			Level int `json:"level"`
+		} `json:"headings"`
+	}
+	if err := json.Unmarshal([]byte(responseText[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse AI headings: %w", err)
+	}
+
+	levels := map[int]int{}
+	for _, h := range parsed.Headings {
+		if h.Index >= 0 && h.Index < len(reviewed) {
+			levels[h.Index] = h.Level
+		}
+	}
+	refined := make([]models.Heading, 0, len(candidates))
+	for i, h := range candidates {
+		if i < len(reviewed) {
+			level, ok := levels[i]
+			if !ok {
+				continue
+			}
+			if level >= 1 && level <= 3 {
+				h.Level = level
+			}
+			h.Source = models.HeadingSourceAI
+		}
+		refined = append(refined, h)
+	}
+	return refined, nil
+}
+
 // MergeAnalysis represents analysis for merging PDFs
 type MergeAnalysis struct {
 	FileIndex    int            `json:"fileIndex"`
@@ -896,10 +1089,10 @@ Respond in JSON format:
 }
 
 // OCRForMerge performs OCR on scanned PDFs before merging
-func (s *AIService) OCRForMerge(ctx context.Context, pdfData []byte) (*OCRServiceResult, error) {
+func (s *AIService) OCRForMerge(ctx context.Context, in PDFReader) (*OCRServiceResult, error) {
 	// First try normal text extraction
 	// If text is minimal, perform OCR
-	return s.ExtractTextOCR(ctx, pdfData)
+	return s.ExtractTextOCR(ctx, in)
 }
 
 // Close cleans up resources (no-op for HTTP client)
@@ -917,6 +1110,53 @@ func truncateText(text string, maxLen int) string {
 	return text[:maxLen] + "..."
 }
 
+// validAadhaar checks the Verhoeff check digit that ends every Aadhaar
+// number, which rules out most other 12-digit numbers
+func validAadhaar(value string) bool {
+	var digits []int
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) != 12 {
+		return false
+	}
+
+	c := 0
+	for i := range digits {
+		c = verhoeffMul[c][verhoeffPerm[i%8][digits[len(digits)-1-i]]]
+	}
+	return c == 0
+}
+
+// Verhoeff checksum tables: the dihedral group D5's multiplication and the
+// position permutations
+var (
+	verhoeffMul = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPerm = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 2, 1, 5, 9, 3, 6, 8},
+	}
+)
+
 func maskSensitiveValue(value, dataType string) string {
 	switch dataType {
 	case "email":
@@ -935,6 +1175,8 @@ func maskSensitiveValue(value, dataType string) string {
 		if len(value) >= 4 {
 			return "****-****-****-" + value[len(value)-4:]
 		}
+	case "aadhaar":
+		return "XXXX XXXX " + value[len(value)-4:]
 	}
 	
 	// Default masking
@@ -0,0 +1,204 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"brainy-pdf/internal/services"
+	"brainy-pdf/internal/testutil"
+)
+
+// Contracts pin down how the OpenRouter integration builds its requests
+// and reads the responses, replaying cassettes from
+// internal/testutil/cassettes so they run without keys or network. To
+// refresh a cassette against the live API, run the test with
+// TEST_RECORD_CASSETTES=1 and OPENROUTER_API_KEY set, and review the diff;
+// credentials are redacted before saving.
+
+// TestOpenRouterChatContract asks a question through ChatWithPDF and checks
+// the chat completion request: bearer auth, the attribution headers, the
+// model and limits, and the document context, history and question in order
+func TestOpenRouterChatContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "openrouter_chat", "OPENROUTER_API_KEY")
+	ai := contractAIService(t, tape)
+
+	history := []services.ChatMessage{
+		{Role: "user", Content: "Who is the invoice for?"},
+		{Role: "assistant", Content: "Demo Corp."},
+	}
+	answer, err := ai.ChatWithPDF(context.Background(), contractInvoice, "What is the total due?", history)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+
+	sent := tape.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("chat: expected 1 OpenRouter request, got %d", len(sent))
+	}
+	body := checkOpenRouterRequest(t, sent[0], 2048)
+	if len(body.Messages) != 4 {
+		t.Fatalf("chat: expected system, 2 history and 1 user message, got %d messages", len(body.Messages))
+	}
+	if body.Messages[0].Role != "system" || !strings.Contains(body.Messages[0].Content, contractInvoice) {
+		t.Errorf("chat: first message should be the system prompt with the document, got a %s message", body.Messages[0].Role)
+	}
+	for i, m := range history {
+		if body.Messages[i+1] != m {
+			t.Errorf("chat: message %d is %+v, want history %+v", i+2, body.Messages[i+1], m)
+		}
+	}
+	if last := body.Messages[3]; last.Role != "user" || last.Content != "What is the total due?" {
+		t.Errorf("chat: last message is %+v, want the question", last)
+	}
+
+	if want := completionContent(t, tape.Response(0)); answer != want {
+		t.Errorf("chat: answer %q, want %q", answer, want)
+	}
+}
+
+// TestOpenRouterChatRetryContract replays a 429 followed by an answer and
+// checks ChatWithPDF backs off and resends the same request
+func TestOpenRouterChatRetryContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "openrouter_chat_rate_limited")
+	ai := contractAIService(t, tape)
+
+	answer, err := ai.ChatWithPDF(context.Background(), contractInvoice, "What is the total due?", nil)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	sent := tape.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("chat: expected a retry after the 429, got %d requests", len(sent))
+	}
+	if string(sent[0].Body) != string(sent[1].Body) {
+		t.Errorf("chat: the retry sent a different body")
+	}
+	checkOpenRouterRequest(t, sent[1], 2048)
+	if want := completionContent(t, tape.Response(1)); answer != want {
+		t.Errorf("chat: answer %q, want %q", answer, want)
+	}
+}
+
+// TestOpenRouterSummarizeContract summarizes a short document and checks the
+// request carries the document in one prompt with the requested length,
+// and that the fenced JSON reply is parsed into the result
+func TestOpenRouterSummarizeContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "openrouter_summarize", "OPENROUTER_API_KEY")
+	ai := contractAIService(t, tape)
+
+	result, err := ai.SummarizePDF(context.Background(), contractInvoice, "short")
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+
+	sent := tape.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("summarize: expected 1 OpenRouter request, got %d", len(sent))
+	}
+	body := checkOpenRouterRequest(t, sent[0], 8192)
+	if len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+		t.Fatalf("summarize: expected a single user message, got %+v", body.Messages)
+	}
+	prompt := body.Messages[0].Content
+	if !strings.Contains(prompt, contractInvoice) || !strings.Contains(prompt, "short (1 paragraph") {
+		t.Errorf("summarize: prompt lacks the document or the length instruction")
+	}
+
+	content := completionContent(t, tape.Response(0))
+	var want services.SummarizeResult
+	if err := json.Unmarshal([]byte(content[strings.Index(content, "{"):strings.LastIndex(content, "}")+1]), &want); err != nil {
+		t.Fatalf("summarize: recorded reply is not JSON: %v", err)
+	}
+	if result.DocumentType != want.DocumentType || result.Summary != want.Summary || len(result.ImportantPoints) != len(want.ImportantPoints) {
+		t.Errorf("summarize: got %+v, want %+v", result, want)
+	}
+	if result.WordCount != len(strings.Fields(contractInvoice)) {
+		t.Errorf("summarize: word count %d, want %d", result.WordCount, len(strings.Fields(contractInvoice)))
+	}
+}
+
+// TestOpenRouterErrorsContract replays a rejected key and an error OpenRouter
+// returns with status 200, and checks both surface as errors
+func TestOpenRouterErrorsContract(t *testing.T) {
+	tape := testutil.UseCassette(t, "openrouter_errors")
+	ai := contractAIService(t, tape)
+
+	if _, err := ai.ChatWithPDF(context.Background(), contractInvoice, "What is the total due?", nil); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("chat with a rejected key: got %v, want a status 401 error", err)
+	}
+	if _, err := ai.ChatWithPDF(context.Background(), contractInvoice, "What is the total due?", nil); err == nil || !strings.Contains(err.Error(), "Provider returned error") {
+		t.Errorf("chat with an error body: got %v, want the provider error", err)
+	}
+}
+
+const contractInvoice = "INVOICE #2041\nBill to: Demo Corp\nConsulting, 10 hours at $150.00\nTotal due: $1,500.00 within 30 days"
+
+type openRouterBody struct {
+	Model       string                 `json:"model"`
+	Messages    []services.ChatMessage `json:"messages"`
+	Temperature float64                `json:"temperature"`
+	MaxTokens   int                    `json:"max_tokens"`
+}
+
+// checkOpenRouterRequest checks what every chat completion request shares
+// and returns the decoded body
+func checkOpenRouterRequest(t testing.TB, req testutil.SentRequest, maxTokens int) openRouterBody {
+	t.Helper()
+
+	if req.Method != http.MethodPost || req.URL != services.OpenRouterAPIURL {
+		t.Errorf("openrouter: request is %s %s, want POST %s", req.Method, req.URL, services.OpenRouterAPIURL)
+	}
+	if got := req.Header.Get("Authorization"); !strings.HasPrefix(got, "Bearer ") || len(got) == len("Bearer ") {
+		t.Errorf("openrouter: Authorization is %q, want a bearer key", got)
+	}
+	for name, want := range map[string]string{
+		"Content-Type": "application/json",
+		"HTTP-Referer": "https://binarypdf.com",
+		"X-Title":      "BinaryPDF",
+	} {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("openrouter: %s is %q, want %q", name, got, want)
+		}
+	}
+
+	var body openRouterBody
+	req.JSON(t, &body)
+	if body.Model != services.OpenRouterModel {
+		t.Errorf("openrouter: model %q, want %q", body.Model, services.OpenRouterModel)
+	}
+	if body.Temperature != 0.3 || body.MaxTokens != maxTokens {
+		t.Errorf("openrouter: temperature %v and max_tokens %d, want 0.3 and %d", body.Temperature, body.MaxTokens, maxTokens)
+	}
+	return body
+}
+
+// completionContent reads the assistant reply out of a chat completion
+func completionContent(t testing.TB, resp testutil.RecordedResponse) string {
+	t.Helper()
+
+	var completion services.ChatResponse
+	if err := json.Unmarshal(resp.Body, &completion); err != nil || len(completion.Choices) == 0 {
+		t.Fatalf("openrouter: recorded response has no reply: %v", err)
+	}
+	return completion.Choices[0].Message.Content
+}
+
+// contractAIService returns an AI service that calls OpenRouter through tape
+func contractAIService(t testing.TB, tape *testutil.Tape) *services.AIService {
+	t.Helper()
+
+	key := "sk-or-v1-contract"
+	if tape.Recording() {
+		key = os.Getenv("OPENROUTER_API_KEY")
+	}
+	ai, err := services.NewAIService(context.Background(), key)
+	if err != nil {
+		t.Fatalf("create AI service: %v", err)
+	}
+	ai.SetHTTPClient(tape.Client())
+	return ai
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// PageBreak separates pages in extracted text handed to the summarizer, so
+// sections can be built from whole pages and cite them
+const PageBreak = "\f"
+
+const (
+	// summarySectionChars bounds the document text summarized in one call;
+	// consecutive pages are grouped into sections up to this size
+	summarySectionChars = 12000
+	// summaryReduceChars bounds the notes combined in one call. Beyond it
+	// the notes are first summarized again in groups.
+	summaryReduceChars = 24000
+	// summaryMaxLevels bounds the rounds of re-summarizing notes
+	summaryMaxLevels = 4
+	// summaryConcurrency bounds the OpenRouter calls made at once for one
+	// document
+	summaryConcurrency = 4
+)
+
+// textSection is a run of pages, or the notes summarizing them. Pages are
+// 1-based and inclusive; FirstPage is 0 when the text had no page breaks.
+type textSection struct {
+	FirstPage int
+	LastPage  int
+	Text      string
+}
+
+// label names the pages a section covers, e.g. "Pages 3-7"
+func (t textSection) label(index, total int) string {
+	switch {
+	case t.FirstPage == 0:
+		return fmt.Sprintf("Part %d of %d", index+1, total)
+	case t.FirstPage == t.LastPage:
+		return fmt.Sprintf("Page %d", t.FirstPage)
+	}
+	return fmt.Sprintf("Pages %d-%d", t.FirstPage, t.LastPage)
+}
+
+// SplitPages splits text joined with PageBreak back into pages
+func SplitPages(text string) []string {
+	return strings.Split(text, PageBreak)
+}
+
+// groupPages packs consecutive pages into sections of at most size bytes.
+// A page longer than size is split over several sections.
+func groupPages(pages []string, size int) []textSection {
+	numbered := len(pages) > 1
+
+	var sections []textSection
+	var cur textSection
+	flush := func() {
+		if cur.Text != "" {
+			sections = append(sections, cur)
+		}
+		cur = textSection{}
+	}
+
+	for i, page := range pages {
+		page = strings.TrimSpace(page)
+		if page == "" {
+			continue
+		}
+		n := 0
+		if numbered {
+			n = i + 1
+		}
+
+		if len(page) > size {
+			flush()
+			for _, piece := range chunkText(page, size) {
+				sections = append(sections, textSection{FirstPage: n, LastPage: n, Text: piece})
+			}
+			continue
+		}
+
+		if cur.Text != "" && len(cur.Text)+2+len(page) > size {
+			flush()
+		}
+		if cur.Text == "" {
+			cur.FirstPage = n
+			cur.Text = page
+		} else {
+			cur.Text += "\n\n" + page
+		}
+		cur.LastPage = n
+	}
+	flush()
+	return sections
+}
+
+// chunkText splits text into pieces of at most size bytes, breaking at a
+// paragraph, line or sentence end, or a space, in the back half of each
+// piece when there is one
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := size
+		for _, sep := range []string{"\n\n", "\n", ". ", " "} {
+			if i := strings.LastIndex(text[size/2:size], sep); i >= 0 {
+				cut = size/2 + i + len(sep)
+				break
+			}
+		}
+		for cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut++
+		}
+		if chunk := strings.TrimSpace(text[:cut]); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = text[cut:]
+	}
+	if chunk := strings.TrimSpace(text); chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// summarizeSections summarizes a document too long for one call: each
+// section is condensed to notes in parallel, then the notes are combined
+func (s *AIService) summarizeSections(ctx context.Context, sections []textSection, length string, wordCount int) (*SummarizeResult, error) {
+	log.Printf("[AI] Summarizing %d sections...", len(sections))
+
+	notes := make([]textSection, len(sections))
+	err := runLimited(ctx, len(sections), summaryConcurrency, func(ctx context.Context, i int) error {
+		text, err := s.SummarizeSection(ctx, sections[i], i, len(sections))
+		notes[i] = textSection{FirstPage: sections[i].FirstPage, LastPage: sections[i].LastPage, Text: text}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.CombineSummaries(ctx, notes, length, wordCount)
+}
+
+// SummarizeSection condenses one section of a document that is too long to
+// summarize in a single call. The notes it returns are merged by
+// CombineSummaries.
+func (s *AIService) SummarizeSection(ctx context.Context, section textSection, index, total int) (string, error) {
+	if s.apiKey == "" {
+		return "", fmt.Errorf("OpenRouter API not configured")
+	}
+
+	label := section.label(index, total)
+	prompt := fmt.Sprintf(`You are summarizing one part (%s) of a longer document.
+
+Write concise notes on this part only:
+- the main facts, arguments and conclusions
+- names, dates, amounts and other key entities exactly as written
+- anything that looks important for the document as a whole
+
+Treat OCR noise as valid content. Do not add an introduction or refer to "this part". Use at most 250 words.
+
+Document Part:
+%s`, label, section.Text)
+
+	notes, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", strings.ToLower(label), err)
+	}
+	return strings.TrimSpace(notes), nil
+}
+
+// CombineSummaries reduces section notes, in document order, to the same
+// structured result SummarizePDF returns. While the notes are too long to
+// read at once they are summarized again in groups of neighbouring
+// sections. wordCount is the length of the original text.
+func (s *AIService) CombineSummaries(ctx context.Context, notes []textSection, length string, wordCount int) (*SummarizeResult, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("OpenRouter API not configured")
+	}
+
+	for level := 1; sectionsLength(notes) > summaryReduceChars && level <= summaryMaxLevels; level++ {
+		batches := batchSections(notes, summaryReduceChars)
+		log.Printf("[AI] CombineSummaries: level %d, %d notes into %d groups", level, len(notes), len(batches))
+
+		reduced := make([]textSection, len(batches))
+		err := runLimited(ctx, len(batches), summaryConcurrency, func(ctx context.Context, i int) error {
+			var err error
+			reduced[i], err = s.reduceNotes(ctx, batches[i])
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		notes = reduced
+	}
+
+	prompt := summaryPrompt(length,
+		"The document was too long to read at once, so each part was summarized separately. Part notes, in document order:",
+		truncateText(formatSections(notes), summaryReduceChars))
+
+	log.Printf("[AI] CombineSummaries: combining %d parts...", len(notes))
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine summaries: %w", err)
+	}
+	return parseSummarizeResult(responseText, wordCount)
+}
+
+// reduceNotes merges the notes of neighbouring sections into notes for the
+// pages they span together
+func (s *AIService) reduceNotes(ctx context.Context, batch []textSection) (textSection, error) {
+	merged := textSection{FirstPage: batch[0].FirstPage, LastPage: batch[len(batch)-1].LastPage}
+
+	prompt := fmt.Sprintf(`Below are notes on consecutive parts of a longer document, in order.
+
+Merge them into one set of concise notes that keeps the main facts, conclusions and key entities (names, dates, amounts) and drops repetition. Keep page references where they matter. Use at most 400 words and do not add an introduction.
+
+%s`, formatSections(batch))
+
+	text, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return merged, fmt.Errorf("failed to combine summaries: %w", err)
+	}
+	merged.Text = strings.TrimSpace(text)
+	return merged, nil
+}
+
+// formatSections lays out notes under their page labels
+func formatSections(sections []textSection) string {
+	var b strings.Builder
+	for i, sec := range sections {
+		fmt.Fprintf(&b, "[%s]\n%s\n\n", sec.label(i, len(sections)), sec.Text)
+	}
+	return b.String()
+}
+
+func sectionsLength(sections []textSection) int {
+	n := 0
+	for _, sec := range sections {
+		n += len(sec.Text)
+	}
+	return n
+}
+
+// batchSections groups consecutive sections so each group's text fits in
+// size bytes; a section longer than size gets a group of its own
+func batchSections(sections []textSection, size int) [][]textSection {
+	var batches [][]textSection
+	var cur []textSection
+	n := 0
+	for _, sec := range sections {
+		if len(cur) > 0 && n+len(sec.Text) > size {
+			batches = append(batches, cur)
+			cur, n = nil, 0
+		}
+		cur = append(cur, sec)
+		n += len(sec.Text)
+	}
+	if len(cur) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// runLimited calls fn for 0..n-1 with at most limit calls running at once.
+// The first error cancels the context passed to the remaining calls and is
+// returned.
+func runLimited(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+// Template requirement limits
+const (
+	templateMaxRequirements   = 50
+	templateMaxNameChars      = 200
+	templateMaxDescriptionLen = 1000
+)
+
+// ErrInvalidTemplate wraps template spec validation failures
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// templateBullet matches list markers at the start of a requirement line
+var templateBullet = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+
+// templateLeadIn matches the "must include" a one-line spec may start with
+var templateLeadIn = regexp.MustCompile(`(?i)^(?:the document )?must (?:include|contain|have)\s*:?\s*`)
+
+// ParseTemplateRequirements reads a template spec: a JSON array of
+// requirement names or {name, description} objects, or plain text with
+// one requirement per line. A single line is split at commas and
+// semicolons, so "must include signature block, date, clause 7" is three
+// requirements.
+func ParseTemplateRequirements(spec string) ([]models.TemplateRequirement, error) {
+	spec = strings.TrimSpace(spec)
+	var reqs []models.TemplateRequirement
+
+	if strings.HasPrefix(spec, "[") {
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(spec), &items); err != nil {
+			return nil, fmt.Errorf("%w: requirements is not a valid JSON array", ErrInvalidTemplate)
+		}
+		for i, item := range items {
+			var req models.TemplateRequirement
+			if err := json.Unmarshal(item, &req.Name); err != nil {
+				if err := json.Unmarshal(item, &req); err != nil {
+					return nil, fmt.Errorf("%w: requirement %d must be a string or an object with name and description", ErrInvalidTemplate, i+1)
+				}
+			}
+			reqs = append(reqs, req)
+		}
+	} else {
+		lines := strings.Split(spec, "\n")
+		if len(lines) == 1 {
+			spec = templateLeadIn.ReplaceAllString(spec, "")
+			lines = strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ';' })
+		}
+		for _, line := range lines {
+			reqs = append(reqs, models.TemplateRequirement{Name: templateBullet.ReplaceAllString(strings.TrimSpace(line), "")})
+		}
+	}
+
+	out := reqs[:0]
+	for i, req := range reqs {
+		req.Name = strings.TrimSpace(req.Name)
+		req.Description = strings.TrimSpace(req.Description)
+		if req.Name == "" {
+			continue
+		}
+		if len(req.Name) > templateMaxNameChars || len(req.Description) > templateMaxDescriptionLen {
+			return nil, fmt.Errorf("%w: requirement %d is too long (name up to %d characters, description up to %d)",
+				ErrInvalidTemplate, i+1, templateMaxNameChars, templateMaxDescriptionLen)
+		}
+		out = append(out, req)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: at least one requirement is needed", ErrInvalidTemplate)
+	}
+	if len(out) > templateMaxRequirements {
+		return nil, fmt.Errorf("%w: at most %d requirements can be checked at once", ErrInvalidTemplate, templateMaxRequirements)
+	}
+	return out, nil
+}
+
+// templateFinding is what one section of a document says about one
+// requirement
+type templateFinding struct {
+	Requirement int    `json:"requirement"`
+	Met         bool   `json:"met"`
+	Evidence    string `json:"evidence"`
+	Reason      string `json:"reason"`
+	Pages       []int  `json:"pages"`
+}
+
+// CheckTemplate checks that a document, given the text of each page,
+// contains every requirement of a template. Groups of pages are read in
+// parallel; a requirement passes when any of them meets it, and a failure
+// keeps the reason given where the document came closest, e.g. a date
+// field that is there but blank.
+func (s *AIService) CheckTemplate(ctx context.Context, pages []string, reqs []models.TemplateRequirement) (*models.TemplateCheck, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("OpenRouter API not configured")
+	}
+
+	marked := make([]string, len(pages))
+	for i, page := range pages {
+		if strings.TrimSpace(page) != "" {
+			marked[i] = fmt.Sprintf("[Page %d]\n%s", i+1, page)
+		}
+	}
+	sections := groupPages(marked, summarySectionChars)
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no text to check")
+	}
+
+	log.Printf("[AI] CheckTemplate: checking %d requirements in %d sections...", len(reqs), len(sections))
+
+	found := make([][]templateFinding, len(sections))
+	readable := make([]bool, len(sections))
+	err := runLimited(ctx, len(sections), summaryConcurrency, func(ctx context.Context, i int) error {
+		var err error
+		found[i], readable[i], err = s.checkTemplateSection(ctx, sections[i], i, len(sections), len(pages), reqs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var unchecked []string
+	for i, ok := range readable {
+		if !ok {
+			unchecked = append(unchecked, strings.ToLower(sections[i].label(i, len(sections))))
+		}
+	}
+	if len(unchecked) == len(sections) {
+		return nil, fmt.Errorf("failed to check template: AI response was not in expected JSON format")
+	}
+
+	check := &models.TemplateCheck{PageCount: len(pages), Requirements: make([]models.RequirementResult, len(reqs))}
+	for r, req := range reqs {
+		res := models.RequirementResult{Name: req.Name, Description: req.Description, Status: models.RequirementFail, Pages: []int{}}
+		var closest string
+		for _, section := range found {
+			for _, f := range section {
+				if f.Requirement != r+1 {
+					continue
+				}
+				switch {
+				case f.Met:
+					if res.Status == models.RequirementFail {
+						res.Status, res.Evidence, res.Pages = models.RequirementPass, f.Evidence, []int{}
+					}
+					res.Pages = mergePages(res.Pages, f.Pages)
+				case res.Status == models.RequirementFail && len(f.Pages) > 0 && closest == "":
+					// Part of it is there, e.g. a label without a value
+					closest = f.Reason
+					res.Pages = mergePages(res.Pages, f.Pages)
+				}
+			}
+		}
+		if res.Status == models.RequirementFail {
+			res.Reason = closest
+			if res.Reason == "" {
+				res.Reason = "Not found in the document"
+			}
+			if len(unchecked) > 0 {
+				res.Reason += fmt.Sprintf(" (%s could not be checked)", strings.Join(unchecked, ", "))
+			}
+			check.FailCount++
+		} else {
+			check.PassCount++
+		}
+		check.Requirements[r] = res
+	}
+	check.Passed = check.FailCount == 0
+
+	log.Printf("[AI] CheckTemplate completed: %d passed, %d failed", check.PassCount, check.FailCount)
+	return check, nil
+}
+
+// checkTemplateSection asks which requirements one group of pages meets.
+// readable is false when the response could not be understood.
+func (s *AIService) checkTemplateSection(ctx context.Context, section textSection, index, total, pageCount int, reqs []models.TemplateRequirement) ([]templateFinding, bool, error) {
+	var list strings.Builder
+	for i, req := range reqs {
+		fmt.Fprintf(&list, "%d. %s", i+1, req.Name)
+		if req.Description != "" {
+			fmt.Fprintf(&list, ": %s", req.Description)
+		}
+		list.WriteString("\n")
+	}
+
+	prompt := fmt.Sprintf(`You are checking a submitted document against an intake template. This is %s of a %d-page document. Markers like [Page 3] show where each page starts; lines like [Form field Date: ...] are values filled into the PDF's form fields.
+
+For each requirement below, decide whether this text meets it. A section or clause is met when it is present with real content. A field such as a date, name, amount or signature is met only when it is filled in; a label or blank line alone does not count. Do not guess about text you cannot see.
+
+For each requirement give:
+- met: true or false
+- evidence: when met, a quote of at most 30 words from the document
+- pages: the pages where it appears, or where part of it appears when not met
+- reason: when not met, what is missing, e.g. "Date field is blank" or "No clause 7"
+
+Requirements:
+%s
+Output strictly in this JSON format, one entry per requirement:
+{
+  "results": [{"requirement": 1, "met": true, "evidence": "...", "pages": [3], "reason": ""}]
+}
+
+Document Text:
+%s`, strings.ToLower(section.label(index, total)), pageCount, list.String(), section.Text)
+
+	responseText, err := s.callOpenRouter(ctx, prompt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check template: %w", err)
+	}
+
+	var parsed struct {
+		Results []templateFinding `json:"results"`
+	}
+	if err := decodeJSONObject(responseText, &parsed); err != nil {
+		log.Printf("[AI] CheckTemplate: section %d: %v", index+1, err)
+		return nil, false, nil
+	}
+
+	first, last := section.FirstPage, section.LastPage
+	if first == 0 {
+		first, last = 1, pageCount
+	}
+	for i := range parsed.Results {
+		f := &parsed.Results[i]
+		f.Evidence = strings.TrimSpace(f.Evidence)
+		f.Reason = strings.TrimSpace(f.Reason)
+		if len(f.Pages) > 0 || f.Met {
+			f.Pages = clampPages(f.Pages, first, last)
+		}
+	}
+	return parsed.Results, true, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotArchived is returned when restoring a document that isn't archived
+var ErrNotArchived = errors.New("file is not archived")
+
+const (
+	archiveLease    = "archive"
+	archiveInterval = time.Hour
+	archiveBatch    = 100
+	// archiveConcurrency bounds the restores run at once per instance
+	archiveConcurrency = 2
+	// archiveThawDays is how long S3 keeps the readable copy of an object
+	// in an archival class while it is copied back
+	archiveThawDays = 7
+	// archiveRestoreRetry is how long after a restore is asked for the
+	// periodic job takes it over, e.g. while S3 thaws the object
+	archiveRestoreRetry = 5 * time.Minute
+)
+
+// ArchiveService moves library documents nobody has opened for months to
+// cold storage: the archive bucket, when set, and the archive storage
+// class, when set. Archived documents stay listed but can't be read until
+// their owner restores them; restores copy them back in the background
+// and notify the owner once they are ready. Only documents in platform
+// storage are archived, not those in an organization's own bucket.
+type ArchiveService struct {
+	mongoClient   *mongodb.Client
+	platform      *minioPkg.Client
+	notifications *NotificationService
+	afterMonths   int
+	bucket        string
+	storageClass  string
+	slots         chan struct{}
+}
+
+// NewArchiveService creates an archive service that archives documents
+// unopened for afterMonths into bucket and/or storageClass, one of which
+// is required
+func NewArchiveService(mongoClient *mongodb.Client, platform *minioPkg.Client, notifications *NotificationService, afterMonths int, bucket, storageClass string) (*ArchiveService, error) {
+	if afterMonths < 1 {
+		return nil, fmt.Errorf("archiving needs at least one month of inactivity")
+	}
+	if bucket == "" && storageClass == "" {
+		return nil, fmt.Errorf("archiving needs an archive bucket or storage class")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if bucket != "" {
+		if err := platform.EnsureBucket(ctx, bucket); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := mongoClient.Documents().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "archive.status", Value: 1}},
+	}); err != nil {
+		log.Printf("[Archive] Failed to create index: %v", err)
+	}
+
+	return &ArchiveService{
+		mongoClient:   mongoClient,
+		platform:      platform,
+		notifications: notifications,
+		afterMonths:   afterMonths,
+		bucket:        bucket,
+		storageClass:  storageClass,
+		slots:         make(chan struct{}, archiveConcurrency),
+	}, nil
+}
+
+// Run archives inactive documents and carries on restores waiting for S3
+// from whichever instance holds the archive lease. Blocks until ctx is
+// cancelled.
+func (s *ArchiveService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, archiveLease, archiveInterval, func(ctx context.Context) {
+		cutoff := time.Now().AddDate(0, -s.afterMonths, 0)
+		inactive := bson.M{
+			"ownerUid":    bson.M{"$ne": ""},
+			"isTemporary": false,
+			"storageId":   bson.M{"$in": bson.A{nil, ""}},
+			"archive":     bson.M{"$exists": false},
+			"createdAt":   bson.M{"$lt": cutoff},
+			"$or": bson.A{
+				bson.M{"lastAccessedAt": bson.M{"$exists": false}},
+				bson.M{"lastAccessedAt": bson.M{"$lt": cutoff}},
+			},
+		}
+		archived := 0
+		for _, doc := range s.find(ctx, inactive) {
+			if err := s.archive(ctx, &doc); err != nil {
+				log.Printf("[Archive] Failed to archive %s: %v", doc.ID.Hex(), err)
+				continue
+			}
+			archived++
+		}
+		if archived > 0 {
+			log.Printf("[Archive] Archived %d documents", archived)
+		}
+
+		waiting := bson.M{
+			"archive.status":             models.ArchiveStatusRestoring,
+			"archive.restoreRequestedAt": bson.M{"$lt": time.Now().Add(-archiveRestoreRetry)},
+		}
+		for _, doc := range s.find(ctx, waiting) {
+			s.rehydrate(ctx, &doc)
+		}
+	})
+}
+
+func (s *ArchiveService) find(ctx context.Context, filter bson.M) []models.Document {
+	cursor, err := s.mongoClient.Documents().Find(ctx, filter, options.Find().SetLimit(archiveBatch))
+	if err != nil {
+		log.Printf("[Archive] Failed to find documents: %v", err)
+		return nil
+	}
+	var docs []models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		log.Printf("[Archive] Failed to decode documents: %v", err)
+		return nil
+	}
+	return docs
+}
+
+// archive copies doc to cold storage and marks it archived. Copies moved
+// to the archive bucket replace the original only once the record points
+// at them.
+func (s *ArchiveService) archive(ctx context.Context, doc *models.Document) error {
+	bucket, key := doc.Location()
+	if bucket != s.platform.GetBucketUserFiles() {
+		return nil // legacy locations are left where they are
+	}
+
+	archive := models.Archive{Status: models.ArchiveStatusArchived, ArchivedAt: time.Now(), StorageClass: s.storageClass}
+	set := bson.M{"archive": &archive}
+	target := bucket
+	if s.bucket != "" {
+		target = s.bucket
+		archive.Bucket = bucket
+		set["bucket"], set["objectKey"], set["minioPath"] = target, key, target+"/"+key
+	}
+	if err := s.platform.CopyStored(ctx, bucket, key, target, key, s.storageClass); err != nil {
+		return err
+	}
+
+	// Skip documents opened since they were found
+	res, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{
+		"_id":     doc.ID,
+		"archive": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"lastAccessedAt": bson.M{"$exists": false}},
+			bson.M{"lastAccessedAt": doc.LastAccessedAt},
+		},
+	}, bson.M{"$set": set})
+	if err != nil || res.MatchedCount == 0 {
+		if target != bucket {
+			s.platform.DeleteFile(ctx, target, key)
+		}
+		return err
+	}
+	if target != bucket {
+		if err := s.platform.DeleteFile(ctx, bucket, key); err != nil {
+			log.Printf("[Archive] Failed to remove original of %s: %v", doc.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// Restore starts copying an archived document in userID's library back
+// out of cold storage, returning it as restoring. The owner is notified
+// when it can be read again. Restoring a document already being restored
+// is a no-op.
+func (s *ArchiveService) Restore(ctx context.Context, userID, id string) (*models.Document, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	filter := LibraryFilter(userID)
+	filter["_id"] = objID
+	filter["archive.status"] = models.ArchiveStatusArchived
+
+	now := time.Now()
+	var doc models.Document
+	err = s.mongoClient.Documents().FindOneAndUpdate(ctx, filter, bson.M{
+		"$set":   bson.M{"archive.status": models.ArchiveStatusRestoring, "archive.restoreRequestedAt": now},
+		"$unset": bson.M{"archive.error": ""},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		delete(filter, "archive.status")
+		if err := s.mongoClient.Documents().FindOne(ctx, filter).Decode(&doc); err != nil {
+			return nil, ErrFileNotFound
+		}
+		if doc.Archive == nil {
+			return nil, ErrNotArchived
+		}
+		return &doc, nil // already restoring
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore file: %w", err)
+	}
+
+	go func() {
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		s.rehydrate(ctx, &doc)
+	}()
+	return &doc, nil
+}
+
+// rehydrate copies a restoring document back to regular storage. Objects
+// in an archival class are first restored by S3, which can take hours;
+// the periodic job tries again until the copy succeeds.
+func (s *ArchiveService) rehydrate(ctx context.Context, doc *models.Document) {
+	bucket, key := doc.Location()
+	target := bucket
+	if doc.Archive.Bucket != "" {
+		target = doc.Archive.Bucket
+	}
+	storageClass := ""
+	if doc.Archive.StorageClass != "" {
+		storageClass = "STANDARD"
+	}
+	restoring := bson.M{"_id": doc.ID, "archive.status": models.ArchiveStatusRestoring}
+
+	err := s.platform.CopyStored(ctx, bucket, key, target, key, storageClass)
+	if minioPkg.IsArchived(err) {
+		if doc.Archive.ThawRequestedAt != nil {
+			return
+		}
+		if err := s.platform.RestoreArchived(ctx, bucket, key, archiveThawDays); err != nil {
+			s.fail(ctx, doc, restoring, err)
+			return
+		}
+		s.mongoClient.Documents().UpdateOne(ctx, restoring, bson.M{"$set": bson.M{"archive.thawRequestedAt": time.Now()}})
+		return
+	}
+	if err != nil {
+		s.fail(ctx, doc, restoring, err)
+		return
+	}
+
+	set := bson.M{"lastAccessedAt": time.Now()}
+	if target != bucket {
+		set["bucket"], set["objectKey"], set["minioPath"] = target, key, target+"/"+key
+	}
+	res, err := s.mongoClient.Documents().UpdateOne(ctx, restoring, bson.M{"$set": set, "$unset": bson.M{"archive": ""}})
+	if err != nil || res.MatchedCount == 0 {
+		return
+	}
+	if target != bucket {
+		if err := s.platform.DeleteFile(ctx, bucket, key); err != nil {
+			log.Printf("[Archive] Failed to remove archived copy of %s: %v", doc.ID.Hex(), err)
+		}
+	}
+
+	message := fmt.Sprintf("%s is out of the archive and ready to open.", doc.OriginalName)
+	if err := s.notifications.CreateNotification(ctx, FirebaseRecipient(doc.OwnerUID), "File restored", message, models.NotificationTypeSuccess); err != nil {
+		log.Printf("[Archive] Failed to notify %s: %v", doc.OwnerUID, err)
+	}
+}
+
+// fail puts a document back in the archive after a restore failed, so its
+// owner can try again
+func (s *ArchiveService) fail(ctx context.Context, doc *models.Document, restoring bson.M, err error) {
+	log.Printf("[Archive] Failed to restore %s: %v", doc.ID.Hex(), err)
+	res, updateErr := s.mongoClient.Documents().UpdateOne(ctx, restoring, bson.M{
+		"$set":   bson.M{"archive.status": models.ArchiveStatusArchived, "archive.error": "Restore failed; try again"},
+		"$unset": bson.M{"archive.thawRequestedAt": ""},
+	})
+	if updateErr != nil || res.MatchedCount == 0 {
+		return
+	}
+	message := fmt.Sprintf("%s could not be restored from the archive. Please try again.", doc.OriginalName)
+	s.notifications.CreateNotification(ctx, FirebaseRecipient(doc.OwnerUID), "Restore failed", message, models.NotificationTypeError)
+}
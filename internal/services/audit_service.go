@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditCollection holds the audit log
+const auditCollection = "audit_logs"
+
+// AuditService records security-relevant decisions, such as overriding a
+// sharing guardrail, for administrators to review
+type AuditService struct {
+	mongoClient *mongodb.Client
+	forwarder   *LogForwarder // nil unless logs are shipped to a SIEM
+}
+
+// NewAuditService creates an audit service. Entries are also sent to
+// forwarder when it is not nil.
+func NewAuditService(mongoClient *mongodb.Client, forwarder *LogForwarder) *AuditService {
+	return &AuditService{mongoClient: mongoClient, forwarder: forwarder}
+}
+
+// Record appends an entry to the audit log. Failures are logged rather than
+// returned so that auditing never fails the request being audited.
+func (s *AuditService) Record(ctx context.Context, entry models.AuditEntry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if _, err := s.mongoClient.Collection(auditCollection).InsertOne(ctx, entry); err != nil {
+		log.Printf("[Audit] Failed to record %s by %s on %s: %v", entry.Action, entry.ActorID, entry.ResourceID, err)
+	}
+	s.forwarder.ForwardAudit(entry)
+}
+
+// List returns the newest audit entries, optionally only those of one actor
+// or action
+func (s *AuditService) List(ctx context.Context, actorID, action string, limit int) ([]models.AuditEntry, error) {
+	filter := bson.M{}
+	if actorID != "" {
+		filter["actorId"] = actorID
+	}
+	if action != "" {
+		filter["action"] = action
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := s.mongoClient.Collection(auditCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by BackupService
+var (
+	ErrBackupNotFound   = errors.New("backup not found")
+	ErrBackupInProgress = errors.New("another backup is in progress")
+	ErrBackupNotEmpty   = errors.New("collections to restore already hold documents")
+	ErrBackupCorrupt    = errors.New("backup does not match its manifest")
+)
+
+// errBackupStopped ends a run whose lease moved to another instance
+var errBackupStopped = errors.New("backup stopped")
+
+const (
+	backupLease      = "backup"
+	backupInterval   = 30 * time.Second
+	backupBatch      = 100
+	backupInsertSize = 500
+	backupMaxLine    = 17 << 20 // a 16MB document in extended JSON
+)
+
+// backupSkipped are collections left out of backups: backup records
+// themselves and state that only means something to running instances
+var backupSkipped = map[string]bool{"backups": true, "leases": true, "job_queue": true}
+
+// BackupService writes disaster-recovery backups to the backup bucket: a
+// dump of every collection and a listing of the stored objects with their
+// SHA-256, tied together by a manifest. Collections are read from a single
+// snapshot on replica sets; with maintenance mode on, the listing matches
+// the dump too. Stored objects themselves are not copied; they are meant
+// to be protected by the object store's own replication or versioning, and
+// restores check they are still there and unchanged.
+type BackupService struct {
+	mongoClient *mongodb.Client
+	platform    *minioPkg.Client
+	maintenance *MaintenanceService // nil outside the API
+	bucket      string
+	buckets     []string // buckets whose objects are listed
+}
+
+// NewBackupService creates a backup service writing to bucket and listing
+// the objects in buckets
+func NewBackupService(mongoClient *mongodb.Client, platform *minioPkg.Client, maintenance *MaintenanceService, bucket string, buckets []string) (*BackupService, error) {
+	for _, b := range buckets {
+		if b == bucket {
+			return nil, fmt.Errorf("backup bucket %s is also backed up", bucket)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := platform.EnsureBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+	return &BackupService{
+		mongoClient: mongoClient,
+		platform:    platform,
+		maintenance: maintenance,
+		bucket:      bucket,
+		buckets:     buckets,
+	}, nil
+}
+
+func (s *BackupService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("backups")
+}
+
+// Start records a backup for the backup job to take
+func (s *BackupService) Start(ctx context.Context, by string) (*models.Backup, error) {
+	active, err := s.collection().CountDocuments(ctx, bson.M{"status": bson.M{"$in": bson.A{models.BackupPending, models.BackupRunning}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check backups: %w", err)
+	}
+	if active > 0 {
+		return nil, ErrBackupInProgress
+	}
+
+	now := time.Now()
+	b := &models.Backup{
+		ID:        primitive.NewObjectID(),
+		Status:    models.BackupPending,
+		Bucket:    s.bucket,
+		StartedBy: by,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.collection().InsertOne(ctx, b); err != nil {
+		return nil, fmt.Errorf("failed to save backup: %w", err)
+	}
+	return b, nil
+}
+
+// Get returns a backup by ID
+func (s *BackupService) Get(ctx context.Context, id string) (*models.Backup, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrBackupNotFound
+	}
+	var b models.Backup
+	err = s.collection().FindOne(ctx, bson.M{"_id": objID}).Decode(&b)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backup: %w", err)
+	}
+	return &b, nil
+}
+
+// List returns all backups, newest first
+func (s *BackupService) List(ctx context.Context) ([]models.Backup, error) {
+	cursor, err := s.collection().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	backups := []models.Backup{}
+	if err := cursor.All(ctx, &backups); err != nil {
+		return nil, fmt.Errorf("failed to decode backups: %w", err)
+	}
+	return backups, nil
+}
+
+// Run takes pending backups from whichever instance holds the backup
+// lease. A backup left running by an instance that stopped is started
+// over. Blocks until ctx is cancelled.
+func (s *BackupService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, backupLease, backupInterval, func(ctx context.Context) {
+		var b models.Backup
+		err := s.collection().FindOneAndUpdate(ctx,
+			bson.M{"status": bson.M{"$in": bson.A{models.BackupPending, models.BackupRunning}}},
+			bson.M{"$set": bson.M{"status": models.BackupRunning, "updatedAt": time.Now()}},
+			options.FindOneAndUpdate().SetSort(bson.M{"createdAt": 1}).SetReturnDocument(options.After),
+		).Decode(&b)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+		if err != nil {
+			log.Printf("[Backup] Failed to load backup: %v", err)
+			return
+		}
+
+		log.Printf("[Backup] Starting %s", b.ID.Hex())
+		manifest, err := s.write(ctx, leases, &b)
+		if errors.Is(err, errBackupStopped) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[Backup] %s failed: %v", b.ID.Hex(), err)
+			s.collection().UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{
+				"status":    models.BackupFailed,
+				"error":     err.Error(),
+				"updatedAt": time.Now(),
+			}})
+			return
+		}
+
+		now := time.Now()
+		update := bson.M{"status": models.BackupCompleted, "manifestKey": manifest, "updatedAt": now, "completedAt": now}
+		s.collection().UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": update})
+		log.Printf("[Backup] %s completed: %d documents, %d objects", b.ID.Hex(), b.Documents, b.Objects)
+	})
+}
+
+// write dumps the database, lists the stored objects and uploads the
+// manifest, returning its key
+func (s *BackupService) write(ctx context.Context, leases *LeaseService, b *models.Backup) (string, error) {
+	id := b.ID.Hex()
+	manifest := models.BackupManifest{
+		ID:          id,
+		Database:    s.mongoClient.Database().Name(),
+		CreatedAt:   time.Now(),
+		Maintenance: s.maintenance != nil && s.maintenance.Active(),
+	}
+
+	names, err := s.mongoClient.Database().ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	// Read every collection at one point in time where the deployment
+	// supports it; standalone servers don't
+	readCtx := ctx
+	session, err := s.mongoClient.MongoClient().StartSession(options.Session().SetSnapshot(true))
+	if err == nil {
+		defer session.EndSession(ctx)
+		snapshotCtx := mongo.NewSessionContext(ctx, session)
+		err = s.collection().FindOne(snapshotCtx, bson.M{}).Err()
+		if err == nil || errors.Is(err, mongo.ErrNoDocuments) {
+			readCtx, manifest.Snapshot = snapshotCtx, true
+		}
+	}
+	if !manifest.Snapshot {
+		log.Printf("[Backup] Snapshot reads unavailable; collections are read one after another")
+	}
+
+	b.Documents = 0
+	for _, name := range names {
+		if backupSkipped[name] || strings.HasPrefix(name, "system.") {
+			continue
+		}
+		if err := keepBackupLease(ctx, leases); err != nil {
+			return "", err
+		}
+		entry, err := s.dumpCollection(ctx, readCtx, id, name)
+		if err != nil {
+			return "", err
+		}
+		manifest.Collections = append(manifest.Collections, *entry)
+		b.Documents += entry.Documents
+	}
+	if err := s.progress(ctx, b, bson.M{"documents": b.Documents}); err != nil {
+		return "", err
+	}
+
+	b.Objects, b.Bytes = 0, 0
+	for _, bucket := range s.buckets {
+		entry, err := s.listBucket(ctx, leases, b, bucket)
+		if err != nil {
+			return "", err
+		}
+		manifest.Buckets = append(manifest.Buckets, *entry)
+	}
+	if manifest.Maintenance && !s.maintenance.Active() {
+		manifest.Maintenance = false
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	key := backupManifestKey(id)
+	if _, err := s.platform.UploadBytes(ctx, s.bucket, key, data, "application/json"); err != nil {
+		return "", fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	return key, nil
+}
+
+// dumpCollection uploads a collection as gzipped canonical extended JSON,
+// one document per line, reading it with readCtx
+func (s *BackupService) dumpCollection(ctx, readCtx context.Context, id, name string) (*models.BackupCollection, error) {
+	entry := &models.BackupCollection{Name: name, Key: fmt.Sprintf("%s/collections/%s.jsonl.gz", id, name)}
+	sum, err := s.upload(ctx, entry.Key, func(w io.Writer) error {
+		cursor, err := s.mongoClient.Collection(name).Find(readCtx, bson.M{})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		for cursor.Next(readCtx) {
+			line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return err
+			}
+			entry.Documents++
+		}
+		return cursor.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump %s: %w", name, err)
+	}
+	entry.SHA256 = sum
+	return entry, nil
+}
+
+// listBucket uploads the listing of a bucket's objects with the SHA-256
+// of each as stored
+func (s *BackupService) listBucket(ctx context.Context, leases *LeaseService, b *models.Backup, bucket string) (*models.BackupBucket, error) {
+	entry := &models.BackupBucket{Name: bucket, Key: fmt.Sprintf("%s/objects/%s.jsonl.gz", b.ID.Hex(), bucket)}
+	sum, err := s.upload(ctx, entry.Key, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		after := ""
+		for {
+			objects, err := s.platform.ListObjectsAfter(ctx, bucket, after, backupBatch)
+			if err != nil {
+				return fmt.Errorf("failed to list objects: %w", err)
+			}
+			if len(objects) == 0 {
+				return nil
+			}
+			for _, obj := range objects {
+				if err := keepBackupLease(ctx, leases); err != nil {
+					return err
+				}
+				sum, size, err := s.hashObject(ctx, bucket, obj.Key)
+				if errors.Is(err, ErrFileNotFound) {
+					continue // deleted since it was listed
+				}
+				if minioPkg.IsArchived(err) {
+					sum, size, err = "", obj.Size, nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := enc.Encode(models.BackupObject{Key: obj.Key, Size: size, SHA256: sum}); err != nil {
+					return err
+				}
+				entry.Objects++
+				entry.Bytes += size
+			}
+			after = objects[len(objects)-1].Key
+			if err := s.progress(ctx, b, bson.M{"objects": b.Objects + entry.Objects, "bytes": b.Bytes + entry.Bytes}); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", bucket, err)
+	}
+	entry.SHA256 = sum
+	b.Objects += entry.Objects
+	b.Bytes += entry.Bytes
+	return entry, nil
+}
+
+// upload gzips what write produces into a temporary file and uploads it to
+// key in the backup bucket, returning the SHA-256 of the uploaded file
+func (s *BackupService) upload(ctx context.Context, key string, write func(w io.Writer) error) (string, error) {
+	f, err := os.CreateTemp("", "backup-*.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	if err := write(gz); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := s.platform.UploadFile(ctx, s.bucket, key, f, size, "application/gzip"); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashObject returns the SHA-256 and size of an object as stored, or
+// ErrFileNotFound when it doesn't exist
+func (s *BackupService) hashObject(ctx context.Context, bucket, key string) (string, int64, error) {
+	obj, err := s.platform.GetObject(ctx, bucket, key)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer obj.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, obj)
+	if err != nil {
+		if minioPkg.IsNotFound(err) {
+			return "", 0, ErrFileNotFound
+		}
+		return "", 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// progress saves counts of a running backup. It fails with
+// errBackupStopped once the backup is no longer running.
+func (s *BackupService) progress(ctx context.Context, b *models.Backup, set bson.M) error {
+	set["updatedAt"] = time.Now()
+	res, err := s.collection().UpdateOne(ctx, bson.M{"_id": b.ID, "status": models.BackupRunning}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return errBackupStopped
+	}
+	return nil
+}
+
+// keepBackupLease renews the backup lease between steps, as a backup
+// lasts far longer than the lease
+func keepBackupLease(ctx context.Context, leases *LeaseService) error {
+	if ctx.Err() != nil {
+		return errBackupStopped
+	}
+	acquired, err := leases.TryAcquire(ctx, backupLease, backupInterval+backupInterval/2)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errBackupStopped
+	}
+	return nil
+}
+
+func backupManifestKey(id string) string {
+	return id + "/manifest.json"
+}
+
+// Manifest downloads the manifest of backup id from the backup bucket
+func (s *BackupService) Manifest(ctx context.Context, id string) (*models.BackupManifest, error) {
+	data, err := s.platform.DownloadFile(ctx, s.bucket, backupManifestKey(id))
+	if err != nil {
+		if minioPkg.IsNotFound(err) {
+			return nil, ErrBackupNotFound
+		}
+		return nil, err
+	}
+	var manifest models.BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: unreadable manifest: %v", ErrBackupCorrupt, err)
+	}
+	if manifest.ID != id {
+		return nil, fmt.Errorf("%w: manifest is for backup %s", ErrBackupCorrupt, manifest.ID)
+	}
+	return &manifest, nil
+}
+
+// Verify checks every file of a backup against its manifest and, with
+// objects, that every object listed is still stored unchanged
+func (s *BackupService) Verify(ctx context.Context, manifest *models.BackupManifest, objects bool) (*models.BackupVerification, error) {
+	v := &models.BackupVerification{}
+	for _, c := range manifest.Collections {
+		err := s.readBackupFile(ctx, c.Key, c.SHA256, func(io.Reader) error { return nil })
+		if err != nil && !errors.Is(err, ErrBackupCorrupt) {
+			return nil, err
+		}
+		if err != nil {
+			v.Corrupt = append(v.Corrupt, c.Key)
+		}
+		v.Collections++
+	}
+
+	for _, b := range manifest.Buckets {
+		err := s.readBackupFile(ctx, b.Key, b.SHA256, func(r io.Reader) error {
+			if !objects {
+				return nil
+			}
+			dec := json.NewDecoder(r)
+			for {
+				var obj models.BackupObject
+				if err := dec.Decode(&obj); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				changed, err := s.objectChanged(ctx, b.Name, obj)
+				switch {
+				case errors.Is(err, ErrFileNotFound):
+					v.Missing = append(v.Missing, b.Name+"/"+obj.Key)
+				case err != nil:
+					return err
+				case changed:
+					v.Changed = append(v.Changed, b.Name+"/"+obj.Key)
+				}
+				v.Objects++
+			}
+		})
+		if err != nil && !errors.Is(err, ErrBackupCorrupt) {
+			return nil, err
+		}
+		if err != nil {
+			v.Corrupt = append(v.Corrupt, b.Key)
+		}
+	}
+	return v, nil
+}
+
+// objectChanged reports whether a listed object no longer matches its
+// hash, or its size for objects in archival storage classes. It fails with
+// ErrFileNotFound when the object is gone.
+func (s *BackupService) objectChanged(ctx context.Context, bucket string, obj models.BackupObject) (bool, error) {
+	if obj.SHA256 == "" {
+		info, err := s.platform.GetFileInfo(ctx, bucket, obj.Key)
+		if minioPkg.IsNotFound(err) {
+			return false, ErrFileNotFound
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", obj.Key, err)
+		}
+		return info.Size != obj.Size, nil
+	}
+	sum, _, err := s.hashObject(ctx, bucket, obj.Key)
+	if err != nil {
+		return false, err
+	}
+	return sum != obj.SHA256, nil
+}
+
+// Restore loads the collections of a backup into the database. Unless
+// replace is set it refuses to touch collections that hold documents;
+// with replace their documents are deleted first. Indexes are not part of
+// backups; services create theirs at startup.
+func (s *BackupService) Restore(ctx context.Context, manifest *models.BackupManifest, replace bool) error {
+	if !replace {
+		for _, c := range manifest.Collections {
+			n, err := s.mongoClient.Collection(c.Name).EstimatedDocumentCount(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check %s: %w", c.Name, err)
+			}
+			if n > 0 {
+				return fmt.Errorf("%w: %s", ErrBackupNotEmpty, c.Name)
+			}
+		}
+	}
+
+	for _, c := range manifest.Collections {
+		coll := s.mongoClient.Collection(c.Name)
+		if replace {
+			if _, err := coll.DeleteMany(ctx, bson.M{}); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", c.Name, err)
+			}
+		}
+		restored := int64(0)
+		err := s.readBackupFile(ctx, c.Key, c.SHA256, func(r io.Reader) error {
+			scanner := bufio.NewScanner(r)
+			scanner.Buffer(make([]byte, 64*1024), backupMaxLine)
+			batch := make([]interface{}, 0, backupInsertSize)
+			flush := func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+				if _, err := coll.InsertMany(ctx, batch); err != nil {
+					return err
+				}
+				restored += int64(len(batch))
+				batch = batch[:0]
+				return nil
+			}
+			for scanner.Scan() {
+				var doc bson.D
+				if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+					return err
+				}
+				batch = append(batch, doc)
+				if len(batch) == backupInsertSize {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			return flush()
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", c.Name, err)
+		}
+		if restored != c.Documents {
+			return fmt.Errorf("%w: restored %d of %d documents in %s", ErrBackupCorrupt, restored, c.Documents, c.Name)
+		}
+		log.Printf("[Backup] Restored %d documents in %s", restored, c.Name)
+	}
+	return nil
+}
+
+// readBackupFile passes the decompressed contents of a backup file to
+// read, then checks the file against sum. It fails with ErrBackupCorrupt
+// when the file is missing or doesn't match.
+func (s *BackupService) readBackupFile(ctx context.Context, key, sum string, read func(r io.Reader) error) error {
+	obj, err := s.platform.GetObject(ctx, s.bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	file := io.TeeReader(obj, h)
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		if minioPkg.IsNotFound(err) {
+			return fmt.Errorf("%w: %s is missing", ErrBackupCorrupt, key)
+		}
+		return fmt.Errorf("%w: %s: %v", ErrBackupCorrupt, key, err)
+	}
+	if err := read(gz); err != nil {
+		return err
+	}
+	// Hash the whole file, whatever read left unread
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBackupCorrupt, key, err)
+	}
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return fmt.Errorf("%w: %s", ErrBackupCorrupt, key)
+	}
+	return nil
+}
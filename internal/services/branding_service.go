@@ -0,0 +1,279 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+)
+
+// Branding logo limits
+const (
+	MaxBrandingLogoSize      = 512 * 1024 // bytes
+	MaxBrandingLogoDimension = 2000       // pixels, either side
+)
+
+// Branding errors surfaced to handlers
+var (
+	ErrInvalidBranding        = errors.New("invalid branding")
+	ErrBrandingUnavailable    = errors.New("white-label branding is available on the Business plan")
+	ErrShareDomainsDisabled   = errors.New("custom share domains are not enabled on this server")
+	ErrShareDomainNotVerified = errors.New("share domain not verified")
+	ErrShareDomainTaken       = errors.New("share domain is used by another organization")
+)
+
+var (
+	accentColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+	shareDomainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// BrandingSettings are an organization's branding options other than the
+// logo, which is uploaded on its own
+type BrandingSettings struct {
+	AccentColor        string `json:"accentColor"`
+	ShareDomain        string `json:"shareDomain"`
+	WatermarkDownloads bool   `json:"watermarkDownloads"`
+}
+
+// BrandingService manages organizations' white-label branding and resolves
+// the branding that applies to a member's share links
+type BrandingService struct {
+	orgService  *OrgService
+	userService *UserService
+	minioClient *minioPkg.Client
+	// Host share domains must be a CNAME for; empty disables them
+	domainTarget string
+	domainHTTPS  bool
+	lookupCNAME  func(ctx context.Context, host string) (string, error)
+}
+
+// NewBrandingService creates a branding service. Organizations' share
+// domains are verified as CNAMEs for domainTarget.
+func NewBrandingService(orgService *OrgService, userService *UserService, minioClient *minioPkg.Client, domainTarget string, domainHTTPS bool) *BrandingService {
+	return &BrandingService{
+		orgService:   orgService,
+		userService:  userService,
+		minioClient:  minioClient,
+		domainTarget: domainTarget,
+		domainHTTPS:  domainHTTPS,
+		lookupCNAME:  net.DefaultResolver.LookupCNAME,
+	}
+}
+
+// Allowed reports whether userID's plan includes white-label branding
+func (s *BrandingService) Allowed(ctx context.Context, userID string) bool {
+	user, err := s.userService.GetUserByFirebaseUID(ctx, userID)
+	if err != nil {
+		return false
+	}
+	return config.GetPlanLimits(user.Plan).WhiteLabelBranding
+}
+
+// Update sets the organization's accent color, share domain and download
+// watermark, keeping its logo. A new share domain needs verifying with
+// VerifyShareDomain before links use it.
+func (s *BrandingService) Update(ctx context.Context, org *models.Organization, settings BrandingSettings) (*models.OrgBranding, error) {
+	accent := strings.ToLower(strings.TrimSpace(settings.AccentColor))
+	if accent != "" && !accentColorPattern.MatchString(accent) {
+		return nil, fmt.Errorf("%w: accentColor must be a hex color such as #1a73e8", ErrInvalidBranding)
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(settings.ShareDomain)), ".")
+	if domain != "" && !shareDomainPattern.MatchString(domain) {
+		return nil, fmt.Errorf("%w: shareDomain must be a host name such as docs.example.com", ErrInvalidBranding)
+	}
+
+	branding := models.OrgBranding{}
+	if org.Branding != nil {
+		branding.LogoKey = org.Branding.LogoKey
+		branding.ShareDomainVerified = org.Branding.ShareDomainVerified && org.Branding.ShareDomain == domain && domain != ""
+	}
+	if settings.WatermarkDownloads && branding.LogoKey == "" {
+		return nil, fmt.Errorf("%w: upload a logo before watermarking downloads", ErrInvalidBranding)
+	}
+	branding.AccentColor = accent
+	branding.ShareDomain = domain
+	branding.WatermarkDownloads = settings.WatermarkDownloads
+	branding.UpdatedAt = time.Now()
+
+	if err := s.orgService.SetBranding(ctx, org.ID, &branding); err != nil {
+		return nil, err
+	}
+	s.AttachLogoURL(ctx, &branding)
+	return &branding, nil
+}
+
+// SetLogo stores a PNG or JPEG logo for the organization, replacing the
+// previous one
+func (s *BrandingService) SetLogo(ctx context.Context, org *models.Organization, data []byte) (*models.OrgBranding, error) {
+	if len(data) > MaxBrandingLogoSize {
+		return nil, fmt.Errorf("%w: logo exceeds %d KB", ErrInvalidBranding, MaxBrandingLogoSize/1024)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg") {
+		return nil, fmt.Errorf("%w: logo must be a PNG or JPEG", ErrInvalidBranding)
+	}
+	if cfg.Width > MaxBrandingLogoDimension || cfg.Height > MaxBrandingLogoDimension {
+		return nil, fmt.Errorf("%w: logo must be at most %dx%d pixels", ErrInvalidBranding, MaxBrandingLogoDimension, MaxBrandingLogoDimension)
+	}
+
+	branding := models.OrgBranding{}
+	if org.Branding != nil {
+		branding = *org.Branding
+	}
+	previous := branding.LogoKey
+
+	// A new key per upload, so cached copies of the old logo aren't served
+	ext := map[string]string{"png": "png", "jpeg": "jpg"}[format]
+	branding.LogoKey = fmt.Sprintf("orgs/%s/branding/logo-%d.%s", org.ID.Hex(), time.Now().UnixNano(), ext)
+	branding.UpdatedAt = time.Now()
+	if _, err := s.minioClient.UploadBytes(ctx, s.minioClient.GetBucketUserFiles(), branding.LogoKey, data, "image/"+format); err != nil {
+		return nil, fmt.Errorf("failed to store logo: %w", err)
+	}
+	if err := s.orgService.SetBranding(ctx, org.ID, &branding); err != nil {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketUserFiles(), branding.LogoKey)
+		return nil, err
+	}
+	if previous != "" {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketUserFiles(), previous)
+	}
+
+	s.AttachLogoURL(ctx, &branding)
+	return &branding, nil
+}
+
+// Remove deletes the organization's branding and logo
+func (s *BrandingService) Remove(ctx context.Context, org *models.Organization) error {
+	if err := s.orgService.SetBranding(ctx, org.ID, nil); err != nil {
+		return err
+	}
+	if org.Branding != nil && org.Branding.LogoKey != "" {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketUserFiles(), org.Branding.LogoKey)
+	}
+	return nil
+}
+
+// VerifyShareDomain checks that the organization's share domain is a CNAME
+// for the server's share domain target and, if so, starts using it for
+// links
+func (s *BrandingService) VerifyShareDomain(ctx context.Context, org *models.Organization) (*models.OrgBranding, error) {
+	if s.domainTarget == "" {
+		return nil, ErrShareDomainsDisabled
+	}
+	if org.Branding == nil || org.Branding.ShareDomain == "" {
+		return nil, fmt.Errorf("%w: set a shareDomain first", ErrInvalidBranding)
+	}
+	domain := org.Branding.ShareDomain
+
+	cname, err := s.lookupCNAME(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s has no CNAME record for %s yet", ErrShareDomainNotVerified, domain, s.domainTarget)
+	}
+	if cname = strings.TrimSuffix(strings.ToLower(cname), "."); cname != s.domainTarget {
+		return nil, fmt.Errorf("%w: %s points to %s, not %s", ErrShareDomainNotVerified, domain, cname, s.domainTarget)
+	}
+
+	other, err := s.orgService.ForShareDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil && other.ID != org.ID {
+		return nil, ErrShareDomainTaken
+	}
+
+	branding := *org.Branding
+	branding.ShareDomainVerified = true
+	branding.UpdatedAt = time.Now()
+	if err := s.orgService.SetBranding(ctx, org.ID, &branding); err != nil {
+		return nil, err
+	}
+	s.AttachLogoURL(ctx, &branding)
+	return &branding, nil
+}
+
+// DomainTarget returns the host share domains must be a CNAME for, "" when
+// custom domains are disabled
+func (s *BrandingService) DomainTarget() string {
+	return s.domainTarget
+}
+
+// ShareDomainURL returns the base URL of a verified share domain
+func (s *BrandingService) ShareDomainURL(domain string) string {
+	if s.domainHTTPS {
+		return "https://" + domain
+	}
+	return "http://" + domain
+}
+
+// ForShareDomain returns the organization mapped to host, a request's Host
+// header, or nil when host is not a verified share domain. Lookup failures
+// are logged and treated as unmapped.
+func (s *BrandingService) ForShareDomain(ctx context.Context, host string) *models.Organization {
+	if s.domainTarget == "" || host == "" {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == s.domainTarget || !shareDomainPattern.MatchString(host) {
+		return nil
+	}
+
+	org, err := s.orgService.ForShareDomain(ctx, host)
+	if err != nil {
+		log.Printf("[Branding] Share domain lookup for %s failed: %v", host, err)
+		return nil
+	}
+	return org
+}
+
+// ForUser returns the organization whose branding applies to userID's
+// share links, or nil when they have none or their plan doesn't include
+// it. Lookup failures are logged and leave links unbranded.
+func (s *BrandingService) ForUser(ctx context.Context, userID string) *models.Organization {
+	if userID == "" {
+		return nil
+	}
+	org, err := s.orgService.ForUser(ctx, userID)
+	if err != nil {
+		log.Printf("[Branding] Organization lookup for %s failed: %v", userID, err)
+		return nil
+	}
+	if org == nil || org.Branding == nil || !s.Allowed(ctx, userID) {
+		return nil
+	}
+	s.AttachLogoURL(ctx, org.Branding)
+	return org
+}
+
+// Logo returns the branding's logo image, nil if it has none
+func (s *BrandingService) Logo(ctx context.Context, branding *models.OrgBranding) ([]byte, error) {
+	if branding == nil || branding.LogoKey == "" {
+		return nil, nil
+	}
+	data, err := s.minioClient.DownloadFile(ctx, s.minioClient.GetBucketUserFiles(), branding.LogoKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load logo: %w", err)
+	}
+	return data, nil
+}
+
+// AttachLogoURL sets a short-lived link to the logo for display
+func (s *BrandingService) AttachLogoURL(ctx context.Context, branding *models.OrgBranding) {
+	if branding == nil || branding.LogoKey == "" {
+		return
+	}
+	branding.LogoURL, _ = s.minioClient.GetPresignedURL(ctx, s.minioClient.GetBucketUserFiles(), branding.LogoKey, 1*time.Hour)
+}
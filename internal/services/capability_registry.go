@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sort"
+	"sync"
+)
+
+// CapabilityAuth is reported by main depending on Firebase initialization
+const CapabilityAuth = "auth"
+
+// CapabilityRegistry tracks which optional services are usable so that
+// handlers can refuse requests with a clear SERVICE_DISABLED error instead
+// of failing deep inside a missing dependency
+type CapabilityRegistry struct {
+	mu       sync.RWMutex
+	checks   map[string]func() Capability
+	disabled map[string]bool
+}
+
+// NewCapabilityRegistry creates an empty registry
+func NewCapabilityRegistry() *CapabilityRegistry {
+	return &CapabilityRegistry{checks: make(map[string]func() Capability)}
+}
+
+// Register adds a capability whose state is re-evaluated on every lookup,
+// typically a service's self-reporting Capability method
+func (r *CapabilityRegistry) Register(name string, check func() Capability) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Set records a capability with fixed state
+func (r *CapabilityRegistry) Set(name string, available bool, reason string) {
+	c := Capability{Name: name, Available: available, Reason: reason}
+	r.Register(name, func() Capability { return c })
+}
+
+// SetDisabled switches off the named capabilities, replacing the names
+// switched off before, whatever their services report
+func (r *CapabilityRegistry) SetDisabled(names []string) {
+	disabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		disabled[name] = true
+	}
+	r.mu.Lock()
+	r.disabled = disabled
+	r.mu.Unlock()
+}
+
+// Get returns the named capability; unregistered names are unavailable
+func (r *CapabilityRegistry) Get(name string) Capability {
+	r.mu.RLock()
+	check, ok := r.checks[name]
+	disabled := r.disabled[name]
+	r.mu.RUnlock()
+	if !ok {
+		return Capability{Name: name, Reason: "Service is not configured"}
+	}
+	if disabled {
+		return Capability{Name: name, Reason: "Disabled by the operator"}
+	}
+	c := check()
+	c.Name = name
+	return c
+}
+
+// Available reports whether the named capability is usable
+func (r *CapabilityRegistry) Available(name string) bool {
+	return r.Get(name).Available
+}
+
+// All returns every registered capability, sorted by name
+func (r *CapabilityRegistry) All() []Capability {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+	caps := make([]Capability, 0, len(names))
+	for _, name := range names {
+		caps = append(caps, r.Get(name))
+	}
+	return caps
+}
@@ -13,7 +13,11 @@ import (
 	"sync"
 	"time"
 
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // JobStatus represents the state of a conversion job
@@ -28,35 +32,66 @@ const (
 
 // ConversionJob represents a document conversion task
 type ConversionJob struct {
-	ID             string    `json:"id"`
-	Status         JobStatus `json:"status"`
-	InputFiles     []string  `json:"-"` // temp file paths
-	OriginalNames  []string  `json:"originalNames"`
-	OutputFormat   string    `json:"outputFormat"`
-	ResultPath     string    `json:"-"` // path to result file or ZIP
-	ResultFilename string    `json:"resultFilename"`
-	Progress       int       `json:"progress"`
-	ProcessedFiles int       `json:"processedFiles"`
-	TotalFiles     int       `json:"totalFiles"`
-	Error          string    `json:"error,omitempty"`
-	CreatedAt      time.Time `json:"createdAt"`
-	CompletedAt    time.Time `json:"completedAt,omitempty"`
+	ID             string    `json:"id" bson:"_id"`
+	Status         JobStatus `json:"status" bson:"status"`
+	Lane           string    `json:"lane,omitempty" bson:"lane,omitempty"`
+	InputFiles     []string  `json:"-" bson:"inputFiles"`          // temp file paths on the submitting instance
+	InputKeys      []string  `json:"-" bson:"inputKeys,omitempty"` // staged copies in the temp bucket
+	OriginalNames  []string  `json:"originalNames" bson:"originalNames"`
+	OutputFormat   string    `json:"outputFormat" bson:"outputFormat"`
+	ConversionOptions `bson:",inline"`
+	ResultPath     string    `json:"-" bson:"resultPath,omitempty"` // path to result file or ZIP
+	ResultKey      string    `json:"-" bson:"resultKey,omitempty"`  // object in the temp bucket
+	ResultSize     int64     `json:"-" bson:"resultSize,omitempty"`
+	ResultFilename string    `json:"resultFilename" bson:"resultFilename,omitempty"`
+	Progress       int       `json:"progress" bson:"progress"`
+	ProcessedFiles int       `json:"processedFiles" bson:"processedFiles"`
+	TotalFiles     int       `json:"totalFiles" bson:"totalFiles"`
+	Error          string    `json:"error,omitempty" bson:"error,omitempty"`
+	Instance       string    `json:"-" bson:"instance,omitempty"` // instance that processed the job
+	Attempts       int       `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	CompletedAt    time.Time `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
 }
 
+// ConversionOptions shape the output of particular kinds of input
+type ConversionOptions struct {
+	Spreadsheet *SpreadsheetOptions `json:"spreadsheet,omitempty" bson:"spreadsheet,omitempty"` // xls/xlsx converted to PDF
+	Handout     *HandoutOptions     `json:"handout,omitempty" bson:"handout,omitempty"`         // presentations converted to PDF
+}
+
+// conversionJobsCollection holds job state shared by all instances
+const conversionJobsCollection = "conversion_jobs"
+
+// conversionMaxAttempts bounds redeliveries of a job whose worker keeps
+// dying (e.g. LibreOffice crashing the pod) before it is marked failed
+const conversionMaxAttempts = 3
+
 // ConversionService handles document conversion using LibreOffice
 type ConversionService struct {
 	jobs       sync.Map
-	jobQueue   chan string
+	queue      JobQueue
 	workerPool int
 	tempDir    string
 	outputDir  string
-	wg         sync.WaitGroup
+	scratch    *ScratchSpace // a private temp dir per job
+	workers    *queueWorkers
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	// Shared state: when set, job records live in Mongo and results in the
+	// MinIO temp bucket so any instance can report status and serve downloads
+	mongoClient *mongodb.Client
+	minioClient *minioPkg.Client
+	instanceID  string
 }
 
-// NewConversionService creates a new conversion service
-func NewConversionService(workerCount int) (*ConversionService, error) {
+// NewConversionService creates a new conversion service. mongoClient and
+// minioClient may be nil, in which case jobs are only visible to this process.
+// queue may be nil for an in-process queue; pass a shared queue so that jobs
+// submitted on one instance can be processed by workers on another.
+// workerCount may be 0 for instances that only submit jobs.
+func NewConversionService(workerCount int, mongoClient *mongodb.Client, minioClient *minioPkg.Client, queue JobQueue) (*ConversionService, error) {
 	tempDir := filepath.Join(os.TempDir(), "brainy-pdf-convert")
 	outputDir := filepath.Join(tempDir, "output")
 
@@ -67,69 +102,256 @@ func NewConversionService(workerCount int) (*ConversionService, error) {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output dir: %w", err)
 	}
+	scratch, err := NewScratchSpace(filepath.Join(tempDir, "jobs"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if queue == nil {
+		queue = NewMemoryJobQueue(10 * time.Minute)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	s := &ConversionService{
-		jobQueue:   make(chan string, 100),
+		queue:      queue,
 		workerPool: workerCount,
 		tempDir:    tempDir,
 		outputDir:  outputDir,
+		scratch:    scratch,
+		workers:    newQueueWorkers("Conversion", queue, QueueTopicConversion, workerCount),
 		ctx:        ctx,
 		cancel:     cancel,
+
+		mongoClient: mongoClient,
+		minioClient: minioClient,
 	}
+	host, _ := os.Hostname()
+	s.instanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
 
 	// Start worker pool
-	for i := 0; i < workerCount; i++ {
-		s.wg.Add(1)
-		go s.worker(i)
-	}
+	s.workers.start(ctx, func(jobID string, attempts int) bool {
+		s.processJob(jobID, attempts)
+		return true
+	})
 
 	fmt.Printf("[Conversion] Started %d workers, temp dir: %s\n", workerCount, tempDir)
 	return s, nil
 }
 
-// Close shuts down the conversion service
+// Close stops the workers. In-flight jobs that have not been acked are
+// redelivered by the queue once their visibility timeout expires.
 func (s *ConversionService) Close() {
 	s.cancel()
-	close(s.jobQueue)
-	s.wg.Wait()
+	s.workers.wait()
 }
 
-// SubmitJob creates a new conversion job and returns the job ID
-func (s *ConversionService) SubmitJob(inputFiles, originalNames []string, outputFormat string) (string, error) {
+// SubmitJob creates a new conversion job in lane and returns the job ID.
+// options apply to the inputs of the kinds they are for.
+func (s *ConversionService) SubmitJob(lane string, inputFiles, originalNames []string, outputFormat string, options ConversionOptions) (string, error) {
 	jobID := uuid.New().String()
 
 	job := &ConversionJob{
 		ID:            jobID,
 		Status:        JobStatusQueued,
+		Lane:          lane,
 		InputFiles:    inputFiles,
 		OriginalNames: originalNames,
 		OutputFormat:  strings.ToLower(outputFormat),
+		ConversionOptions: options,
 		TotalFiles:    len(inputFiles),
 		CreatedAt:     time.Now(),
 	}
 
-	s.jobs.Store(jobID, job)
+	// Stage inputs in shared storage so a worker on any instance can run the job
+	if err := s.stageInputs(job); err != nil {
+		return "", fmt.Errorf("failed to stage input files: %w", err)
+	}
 
-	// Queue the job
-	select {
-	case s.jobQueue <- jobID:
-		fmt.Printf("[Conversion] Job %s queued with %d files\n", jobID, len(inputFiles))
-	default:
-		return "", fmt.Errorf("job queue is full")
+	s.saveJob(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.queue.Enqueue(ctx, QueueTopicConversion, job.Lane, jobID); err != nil {
+		s.jobs.Delete(jobID)
+		s.deleteInputs(job)
+		return "", err
 	}
 
+	fmt.Printf("[Conversion] Job %s queued with %d files\n", jobID, len(inputFiles))
 	return jobID, nil
 }
 
+// stageInputs uploads the job's input files to the temp bucket
+func (s *ConversionService) stageInputs(job *ConversionJob) error {
+	if s.minioClient == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for i, path := range job.InputFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		key := fmt.Sprintf("conversions/%s/input/%d%s", job.ID, i, filepath.Ext(path))
+		_, err = s.minioClient.UploadFile(ctx, s.minioClient.GetBucketTemp(), key, f, info.Size(), "application/octet-stream")
+		f.Close()
+		if err != nil {
+			s.deleteInputs(job)
+			return err
+		}
+		job.InputKeys = append(job.InputKeys, key)
+	}
+	return nil
+}
+
+// deleteInputs removes staged input objects
+func (s *ConversionService) deleteInputs(job *ConversionJob) {
+	if s.minioClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, key := range job.InputKeys {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketTemp(), key)
+	}
+}
+
+// localInputs returns a local path for every input, downloading staged
+// copies into scratch when the job was submitted on another instance
+func (s *ConversionService) localInputs(job *ConversionJob, scratch *Scratch) ([]string, error) {
+	paths := make([]string, len(job.InputFiles))
+	for i, path := range job.InputFiles {
+		if _, err := os.Stat(path); err == nil {
+			paths[i] = path
+			continue
+		}
+		if i >= len(job.InputKeys) || s.minioClient == nil {
+			return paths, fmt.Errorf("input file %d is not available on this instance", i+1)
+		}
+
+		obj, err := s.minioClient.GetObject(s.ctx, s.minioClient.GetBucketTemp(), job.InputKeys[i])
+		if err != nil {
+			return paths, err
+		}
+		local := scratch.Path("input" + filepath.Ext(path))
+		out, err := os.Create(local)
+		if err != nil {
+			obj.Close()
+			return paths, err
+		}
+		_, err = io.Copy(scratch.Writer(out), obj)
+		obj.Close()
+		out.Close()
+		if err != nil {
+			return paths, err
+		}
+		paths[i] = local
+	}
+	return paths, nil
+}
+
+// SetPauseCheck makes workers hold off dequeuing while fn returns true,
+// e.g. while the resource monitor reports the host as overloaded
+func (s *ConversionService) SetPauseCheck(fn func() bool) {
+	s.workers.paused = fn
+}
+
+// SetReservedWorkers keeps n workers for jobs in paid lanes, so paid jobs
+// never wait for a standard job to finish. At least one worker still
+// serves every lane, so standard jobs keep completing.
+func (s *ConversionService) SetReservedWorkers(n int) {
+	s.workers.setReserved(n)
+}
+
+// SetTempQuota bounds each job's temp files, inputs and outputs together,
+// to bytes; 0 leaves them unbounded. Jobs over it fail.
+func (s *ConversionService) SetTempQuota(bytes int64) {
+	s.scratch.SetQuota(bytes)
+}
+
+// ScratchSpace returns where the service's job directories are made
+func (s *ConversionService) ScratchSpace() *ScratchSpace {
+	return s.scratch
+}
+
+// CleanupFinished removes local artifacts of jobs that reached a final state
+// more than maxAge ago. Published results remain downloadable from the temp
+// bucket.
+func (s *ConversionService) CleanupFinished(ctx context.Context, maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	s.jobs.Range(func(key, val interface{}) bool {
+		job := val.(*ConversionJob)
+		if job.Status != JobStatusCompleted && job.Status != JobStatusFailed {
+			return true
+		}
+		if job.CompletedAt.IsZero() || job.CompletedAt.After(cutoff) {
+			return true
+		}
+		os.RemoveAll(filepath.Join(s.outputDir, job.ID))
+		s.jobs.Delete(key)
+		removed++
+		return true
+	})
+	if removed > 0 {
+		fmt.Printf("[Conversion] Cleared artifacts of %d finished jobs\n", removed)
+	}
+}
+
+// QueueDepth returns the number of conversion jobs waiting for a worker
+func (s *ConversionService) QueueDepth(ctx context.Context) (int64, error) {
+	return s.queue.Depth(ctx, QueueTopicConversion)
+}
+
+// QueuePosition returns the job's place in the queue, from 1, or 0 once a
+// worker has taken it
+func (s *ConversionService) QueuePosition(ctx context.Context, jobID string) (int64, error) {
+	return s.queue.Position(ctx, QueueTopicConversion, jobID)
+}
+
+// saveJob records job state locally and, when configured, in Mongo
+func (s *ConversionService) saveJob(job *ConversionJob) {
+	s.jobs.Store(job.ID, job)
+
+	if s.mongoClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.mongoClient.Collection(conversionJobsCollection).ReplaceOne(ctx,
+		bson.M{"_id": job.ID}, job, options.Replace().SetUpsert(true))
+	if err != nil {
+		fmt.Printf("[Conversion] Failed to persist job %s: %v\n", job.ID, err)
+	}
+}
+
 // GetJob returns the current state of a job
 func (s *ConversionService) GetJob(jobID string) (*ConversionJob, error) {
-	val, ok := s.jobs.Load(jobID)
-	if !ok {
-		return nil, fmt.Errorf("job not found")
+	if val, ok := s.jobs.Load(jobID); ok {
+		return val.(*ConversionJob), nil
+	}
+
+	// Job may have been submitted to another instance
+	if s.mongoClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var job ConversionJob
+		err := s.mongoClient.Collection(conversionJobsCollection).FindOne(ctx, bson.M{"_id": jobID}).Decode(&job)
+		if err == nil {
+			return &job, nil
+		}
 	}
-	return val.(*ConversionJob), nil
+	return nil, fmt.Errorf("job not found")
 }
 
 // GetResultPath returns the path to the result file
@@ -144,53 +366,138 @@ func (s *ConversionService) GetResultPath(jobID string) (string, string, error)
 	return job.ResultPath, job.ResultFilename, nil
 }
 
-// worker processes jobs from the queue
-func (s *ConversionService) worker(id int) {
-	defer s.wg.Done()
+// OpenResult opens a completed job's result, from local disk when this
+// instance produced it or from shared storage otherwise
+func (s *ConversionService) OpenResult(ctx context.Context, jobID string) (io.ReadCloser, string, int64, error) {
+	job, err := s.GetJob(jobID)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if job.Status != JobStatusCompleted {
+		return nil, "", 0, fmt.Errorf("job not completed")
+	}
 
-	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		case jobID, ok := <-s.jobQueue:
-			if !ok {
-				return
+	if job.ResultPath != "" {
+		if f, err := os.Open(job.ResultPath); err == nil {
+			if info, err := f.Stat(); err == nil {
+				return f, job.ResultFilename, info.Size(), nil
 			}
-			s.processJob(jobID)
+			f.Close()
+		}
+	}
+
+	if job.ResultKey != "" && s.minioClient != nil {
+		obj, err := s.minioClient.GetObject(ctx, s.minioClient.GetBucketTemp(), job.ResultKey)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to open result: %w", err)
 		}
+		return obj, job.ResultFilename, job.ResultSize, nil
 	}
+
+	return nil, "", 0, fmt.Errorf("result file not found")
 }
 
-// processJob handles the actual conversion
-func (s *ConversionService) processJob(jobID string) {
-	val, ok := s.jobs.Load(jobID)
-	if !ok {
+// publishResult copies a finished result to the temp bucket so other
+// instances can serve it
+func (s *ConversionService) publishResult(job *ConversionJob) error {
+	if s.minioClient == nil {
+		return nil
+	}
+
+	f, err := os.Open(job.ResultPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// The filename came from the client; it is only used for the download
+	key := fmt.Sprintf("conversions/%s/%s", job.ID, minioPkg.GenerateUniqueFilename(job.ResultFilename))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if _, err := s.minioClient.UploadFile(ctx, s.minioClient.GetBucketTemp(), key, f, info.Size(), resultContentType(job.ResultFilename)); err != nil {
+		return err
+	}
+
+	job.ResultKey = key
+	job.ResultSize = info.Size()
+	return nil
+}
+
+// resultContentType maps a result filename to its MIME type
+func resultContentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return "application/pdf"
+	case ".docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ".odt":
+		return "application/vnd.oasis.opendocument.text"
+	case ".zip":
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+// processJob handles the actual conversion. Deliveries are at-least-once,
+// so a job that already reached a final state is skipped.
+func (s *ConversionService) processJob(jobID string, attempts int) {
+	job, err := s.GetJob(jobID)
+	if err != nil {
+		fmt.Printf("[Conversion] Job %s: %v, dropping message\n", jobID, err)
+		return
+	}
+	if job.Status == JobStatusCompleted || job.Status == JobStatusFailed {
+		return
+	}
+
+	job.Attempts = attempts
+	if attempts > conversionMaxAttempts {
+		s.failJob(job, fmt.Sprintf("Gave up after %d attempts", conversionMaxAttempts))
+		s.deleteInputs(job)
 		return
 	}
-	job := val.(*ConversionJob)
 
 	// Update status to processing
 	job.Status = JobStatusProcessing
-	s.jobs.Store(jobID, job)
+	job.Instance = s.instanceID
+	s.saveJob(job)
 
-	fmt.Printf("[Conversion] Processing job %s (%d files → %s)\n", jobID, job.TotalFiles, job.OutputFormat)
+	// Everything the job writes stays in its own directory until the
+	// result is moved out, so a failed or crashed job leaves nothing behind
+	scratch, err := s.scratch.New("convert")
+	if err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to create temp dir: %v", err))
+		return
+	}
+	defer scratch.Close()
 
-	// Create job output directory
-	jobOutputDir := filepath.Join(s.outputDir, jobID)
-	if err := os.MkdirAll(jobOutputDir, 0755); err != nil {
-		s.failJob(job, fmt.Sprintf("Failed to create output dir: %v", err))
+	inputFiles, err := s.localInputs(job, scratch)
+	if err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to fetch input files: %v", err))
+		s.cleanup(inputFiles)
+		s.deleteInputs(job)
 		return
 	}
 
+	fmt.Printf("[Conversion] Processing job %s (%d files → %s)\n", jobID, job.TotalFiles, job.OutputFormat)
+
 	var convertedFiles []string
 	var convertedNames []string
 
 	// Process each file
-	for i, inputPath := range job.InputFiles {
-		outputPath, err := s.convertFile(inputPath, jobOutputDir, job.OutputFormat)
+	for i, inputPath := range inputFiles {
+		outputPath, err := s.convertFile(scratch, inputPath, job.OutputFormat, job.ConversionOptions)
+		if err == nil {
+			err = scratch.Check()
+		}
 		if err != nil {
 			s.failJob(job, fmt.Sprintf("Failed to convert file %d: %v", i+1, err))
-			s.cleanup(job.InputFiles, convertedFiles)
+			s.cleanup(inputFiles)
+			s.deleteInputs(job)
 			return
 		}
 
@@ -205,47 +512,123 @@ func (s *ConversionService) processJob(jobID string) {
 		// Update progress
 		job.ProcessedFiles = i + 1
 		job.Progress = ((i + 1) * 100) / job.TotalFiles
-		s.jobs.Store(jobID, job)
+		s.saveJob(job)
 
 		fmt.Printf("[Conversion] Job %s: %d/%d files completed\n", jobID, i+1, job.TotalFiles)
 	}
 
 	// If multiple files, create ZIP
+	resultPath := ""
 	if len(convertedFiles) > 1 {
-		zipPath := filepath.Join(jobOutputDir, "converted_files.zip")
-		if err := s.createZip(zipPath, convertedFiles, convertedNames); err != nil {
+		zipPath := scratch.Path("converted_files.zip")
+		err := s.createZip(zipPath, convertedFiles, convertedNames)
+		if err == nil {
+			err = scratch.Check()
+		}
+		if err != nil {
 			s.failJob(job, fmt.Sprintf("Failed to create ZIP: %v", err))
-			s.cleanup(job.InputFiles, convertedFiles)
+			s.cleanup(inputFiles)
+			s.deleteInputs(job)
 			return
 		}
-		job.ResultPath = zipPath
+		resultPath = zipPath
 		job.ResultFilename = "converted_files.zip"
 	} else if len(convertedFiles) == 1 {
-		job.ResultPath = convertedFiles[0]
+		resultPath = convertedFiles[0]
 		job.ResultFilename = convertedNames[0]
 	}
 
+	// Keep the result past the job's directory until CleanupFinished
+	jobOutputDir := filepath.Join(s.outputDir, jobID)
+	if err := os.MkdirAll(jobOutputDir, 0755); err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to create output dir: %v", err))
+		return
+	}
+	job.ResultPath = filepath.Join(jobOutputDir, filepath.Base(resultPath))
+	if err := os.Rename(resultPath, job.ResultPath); err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to save result: %v", err))
+		return
+	}
+
 	// Cleanup input files
-	for _, f := range job.InputFiles {
-		os.Remove(f)
+	s.cleanup(inputFiles)
+	s.deleteInputs(job)
+
+	// Share the result with other instances
+	if err := s.publishResult(job); err != nil {
+		fmt.Printf("[Conversion] Job %s: failed to publish result: %v\n", jobID, err)
 	}
 
 	// Mark as completed
 	job.Status = JobStatusCompleted
 	job.Progress = 100
 	job.CompletedAt = time.Now()
-	s.jobs.Store(jobID, job)
+	s.saveJob(job)
 
 	fmt.Printf("[Conversion] Job %s completed: %s\n", jobID, job.ResultFilename)
 }
 
-// convertFile converts a single file using LibreOffice
-func (s *ConversionService) convertFile(inputPath, outputDir, outputFormat string) (string, error) {
-	sofficePath := s.findSofficePath()
+// Capability reports whether documents can be converted. Safe to call on a
+// nil service (initialization failed).
+func (s *ConversionService) Capability() Capability {
+	c := Capability{Name: CapabilityConversion}
+	switch {
+	case s == nil:
+		c.Reason = "Conversion service failed to start"
+	case s.workerPool == 0 && s.mongoClient == nil:
+		c.Reason = "No conversion workers configured"
+	case s.workerPool > 0 && SofficePath() == "":
+		c.Reason = "LibreOffice (soffice) is not installed"
+	default:
+		c.Available = true
+	}
+	return c
+}
+
+// convertFile converts a single file into scratch using LibreOffice
+func (s *ConversionService) convertFile(scratch *Scratch, inputPath, outputFormat string, options ConversionOptions) (string, error) {
+	convertTo := outputFormat
+	if options.Spreadsheet != nil && outputFormat == "pdf" && IsSpreadsheetFile(inputPath) {
+		prepared, err := s.prepareSpreadsheet(scratch, inputPath, options.Spreadsheet)
+		if err != nil {
+			return "", err
+		}
+		inputPath = prepared
+		convertTo = "pdf:calc_pdf_Export"
+	}
+	handout := options.Handout != nil && outputFormat == "pdf" && IsPresentationFile(inputPath)
+
+	outputPath, err := s.runSoffice(scratch, inputPath, convertTo, outputFormat)
+	if err != nil || !handout {
+		return outputPath, err
+	}
+	slides, err := os.ReadFile(outputPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := MakeHandout(slides, *options.Handout)
+	if err != nil {
+		return "", fmt.Errorf("failed to lay out handout: %w", err)
+	}
+	return scratch.WriteFile("handout.pdf", data)
+}
+
+// runSoffice runs LibreOffice's --convert-to on inputPath, convertTo
+// naming the format and optionally its export filter, and returns the
+// output, which has extension ext
+func (s *ConversionService) runSoffice(scratch *Scratch, inputPath, convertTo, ext string) (string, error) {
+	sofficePath := SofficePath()
 	if sofficePath == "" {
 		return "", fmt.Errorf("LibreOffice (soffice) not found")
 	}
 
+	// LibreOffice names its output after the input, so each file gets its
+	// own output directory
+	outputDir := scratch.Path("output")
+	if err := os.Mkdir(outputDir, 0755); err != nil {
+		return "", err
+	}
+
 	// Build command with robust flags
 	args := []string{
 		"--headless",
@@ -254,7 +637,7 @@ func (s *ConversionService) convertFile(inputPath, outputDir, outputFormat strin
 		"--nolockcheck",
 		"--nologo",
 		"--norestore",
-		"--convert-to", outputFormat,
+		"--convert-to", convertTo,
 		"--outdir", outputDir,
 		inputPath,
 	}
@@ -265,7 +648,7 @@ func (s *ConversionService) convertFile(inputPath, outputDir, outputFormat strin
 	fmt.Printf("[Conversion] Executing: %s %v\n", sofficePath, args)
 
 	cmd := exec.CommandContext(ctx, sofficePath, args...)
-	cmd.Env = append(os.Environ(), "HOME="+s.tempDir) // LibreOffice needs HOME
+	cmd.Env = append(os.Environ(), "HOME="+scratch.Dir()) // LibreOffice needs HOME; its profile goes with the job
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -278,7 +661,7 @@ func (s *ConversionService) convertFile(inputPath, outputDir, outputFormat strin
 
 	// Find the output file
 	baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
-	outputPath := filepath.Join(outputDir, baseName+"."+outputFormat)
+	outputPath := filepath.Join(outputDir, baseName+"."+ext)
 
 	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("output file not created: %s", outputPath)
@@ -287,8 +670,9 @@ func (s *ConversionService) convertFile(inputPath, outputDir, outputFormat strin
 	return outputPath, nil
 }
 
-// findSofficePath locates the LibreOffice executable
-func (s *ConversionService) findSofficePath() string {
+// SofficePath locates the LibreOffice executable, or returns "" when it
+// is not installed
+func SofficePath() string {
 	var paths []string
 
 	switch runtime.GOOS {
@@ -418,18 +802,16 @@ func (s *ConversionService) failJob(job *ConversionJob, errMsg string) {
 	job.Status = JobStatusFailed
 	job.Error = errMsg
 	job.CompletedAt = time.Now()
-	s.jobs.Store(job.ID, job)
+	s.saveJob(job)
 	fmt.Printf("[Conversion] Job %s failed: %s\n", job.ID, errMsg)
 }
 
-// cleanup removes temporary files
-func (s *ConversionService) cleanup(inputFiles, outputFiles []string) {
+// cleanup removes the job's input files. Those fetched into its scratch
+// directory go with it; this removes uploads kept by the submitting handler.
+func (s *ConversionService) cleanup(inputFiles []string) {
 	for _, f := range inputFiles {
 		os.Remove(f)
 	}
-	for _, f := range outputFiles {
-		os.Remove(f)
-	}
 }
 
 // GetSupportedConversions returns valid input→output format mappings
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dataKeyCollection holds users' wrapped data keys
+const dataKeyCollection = "encryption_keys"
+
+// sealedMagic starts every encrypted object. It is followed by the length
+// of the data key ID (uint16), the ID, the GCM nonce and the ciphertext.
+var sealedMagic = []byte("BPE1")
+
+// ErrUnknownMasterKey means a data key was wrapped by a master key that is
+// no longer configured
+var ErrUnknownMasterKey = errors.New("data key is wrapped by an unknown master key")
+
+// ErrPlaintextObject is returned when reading an object that isn't encrypted
+// while plaintext objects aren't allowed
+var ErrPlaintextObject = errors.New("object is not encrypted")
+
+// EncryptionService implements envelope encryption for the user files
+// bucket: each user's files are encrypted with AES-256-GCM under their own
+// data key, and data keys are stored wrapped by the master key.
+//
+// Rotating a user's key adds a new version for future writes and rewrites
+// their files; rotating the master key only rewraps the data keys.
+//
+// Objects that aren't encrypted can't be read unless allowPlaintext is set.
+// It is meant for migrating storage written before encryption was enabled:
+// set it, rotate every user's key, then clear it, so that plaintext planted
+// in the bucket is refused rather than served as the user's file.
+type EncryptionService struct {
+	mongoClient    *mongodb.Client
+	minioClient    *minio.Client
+	router         *StorageRouter // nil rotates the platform's bucket only
+	masterID       string
+	masters        map[string]cipher.AEAD // by master key ID; includes previous keys
+	allowPlaintext bool
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD // unwrapped data keys by ID
+}
+
+// NewEncryptionService creates an encryption service from a base64 master
+// key of 32 bytes. previousKeys are former master keys, kept to unwrap data
+// keys until RewrapKeys has moved them to the current one. allowPlaintext
+// lets objects stored before encryption was enabled be read.
+func NewEncryptionService(mongoClient *mongodb.Client, minioClient *minio.Client, masterKey string, previousKeys []string, allowPlaintext bool) (*EncryptionService, error) {
+	s := &EncryptionService{
+		mongoClient:    mongoClient,
+		minioClient:    minioClient,
+		masters:        make(map[string]cipher.AEAD),
+		keys:           make(map[string]cipher.AEAD),
+		allowPlaintext: allowPlaintext,
+	}
+
+	var err error
+	if s.masterID, err = s.addMasterKey(masterKey); err != nil {
+		return nil, fmt.Errorf("invalid ENCRYPTION_MASTER_KEY: %w", err)
+	}
+	for _, k := range previousKeys {
+		if _, err := s.addMasterKey(k); err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_PREVIOUS_MASTER_KEYS entry: %w", err)
+		}
+	}
+
+	log.Printf("[Encryption] Encrypting user files at rest (master key %s)", s.masterID)
+	if allowPlaintext {
+		log.Printf("[Encryption] Reading unencrypted files is allowed until they are migrated")
+	}
+	return s, nil
+}
+
+// SetStorageRouter lets key rotation reach files stored in organizations'
+// own buckets
+func (s *EncryptionService) SetStorageRouter(router *StorageRouter) {
+	s.router = router
+}
+
+// addMasterKey decodes a master key and returns its ID, a fingerprint that
+// identifies it without revealing it
+func (s *EncryptionService) addMasterKey(encoded string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+	id := hex.EncodeToString(sum[:8])
+	s.masters[id] = aead
+	return id, nil
+}
+
+// Encrypt seals an object under the current data key of the user owning
+// objectPath
+func (s *EncryptionService) Encrypt(ctx context.Context, objectPath string, plaintext []byte) ([]byte, error) {
+	userID := objectOwner(objectPath)
+	if userID == "" {
+		return nil, fmt.Errorf("cannot tell the owner of %s", objectPath)
+	}
+	key, err := s.currentKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.dataKey(ctx, key.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(sealedMagic)+2+len(key.ID)+aead.NonceSize())
+	header = append(header, sealedMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(key.ID)))
+	header = append(header, key.ID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, plaintext, header), nil
+}
+
+// Decrypt opens an object sealed by Encrypt. Other data is returned as is
+// while plaintext is allowed, and refused with ErrPlaintextObject otherwise.
+func (s *EncryptionService) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	keyID, ok := sealedKeyID(data)
+	if !ok {
+		if s.allowPlaintext {
+			return data, nil
+		}
+		return nil, ErrPlaintextObject
+	}
+	aead, err := s.dataKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := len(sealedMagic) + 2 + len(keyID) + aead.NonceSize()
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("encrypted object is truncated")
+	}
+	header := data[:headerLen]
+	nonce := header[headerLen-aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, data[headerLen:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt object: %w", err)
+	}
+	return plaintext, nil
+}
+
+// RotateUserKey creates a new data key version for userID and re-encrypts
+// their files with it: those under their prefixes in the platform's bucket
+// and in the bucket of every organization holding one of their documents,
+// and any other document of theirs. Files that aren't re-encrypted, such as
+// archived documents or those in a bucket that can't be reached, stay
+// readable under their previous key and are counted in the result.
+func (s *EncryptionService) RotateUserKey(ctx context.Context, userID string) (*models.KeyRotationResult, error) {
+	cursor, err := s.mongoClient.Documents().Find(ctx, bson.M{"ownerUid": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	var docs []models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode documents: %w", err)
+	}
+	// The platform's bucket also holds files without a document, such as
+	// thumbnails and versions, so it is always rotated
+	byStorage := map[string][]models.Document{"": nil}
+	for _, doc := range docs {
+		byStorage[doc.StorageID] = append(byStorage[doc.StorageID], doc)
+	}
+
+	current, err := s.currentKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	next, err := s.createKey(ctx, userID, current.Version+1)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.collection().UpdateOne(ctx, bson.M{"_id": current.ID}, bson.M{"$set": bson.M{"retiredAt": now}})
+
+	result := &models.KeyRotationResult{UserID: userID, Version: next.Version}
+	for storageID, docs := range byStorage {
+		s.rotateStorage(ctx, userID, storageID, docs, result)
+	}
+	return result, nil
+}
+
+// rotateStorage re-encrypts userID's files in one storage, adding them to
+// result
+func (s *EncryptionService) rotateStorage(ctx context.Context, userID, storageID string, docs []models.Document, result *models.KeyRotationResult) {
+	client, err := s.storage(ctx, storageID)
+	if err != nil {
+		log.Printf("[Encryption] Failed to reach storage %q for %s: %v", storageID, userID, err)
+		result.Failed += len(docs)
+		return
+	}
+
+	bucket := client.GetBucketUserFiles()
+	done := make(map[string]bool) // object keys already tried
+	rotate := func(objectPath string) {
+		done[objectPath] = true
+		if err := s.reencrypt(ctx, client, bucket, objectPath); err != nil {
+			log.Printf("[Encryption] Failed to re-encrypt %s: %v", objectPath, err)
+			result.Failed++
+			return
+		}
+		result.Reencrypted++
+	}
+
+	for _, prefix := range []string{userID + "/", "library/" + userID + "/"} {
+		objects, err := client.ListAllObjects(ctx, bucket, prefix)
+		if err != nil {
+			// Documents are still tried one by one below
+			log.Printf("[Encryption] Failed to list %s in storage %q: %v", prefix, storageID, err)
+			continue
+		}
+		for _, objectPath := range objects {
+			rotate(objectPath)
+		}
+	}
+
+	for _, doc := range docs {
+		docBucket, objectPath := doc.Location()
+		switch {
+		case doc.Archive != nil:
+			// Rewritten once restored; until then it keeps its key
+			log.Printf("[Encryption] Not re-encrypting archived document %s", doc.ID.Hex())
+			result.Failed++
+		case docBucket != bucket || done[objectPath]:
+			// Temporary files aren't encrypted
+		default:
+			rotate(objectPath)
+		}
+	}
+}
+
+// storage returns the storage with the given ID
+func (s *EncryptionService) storage(ctx context.Context, storageID string) (*minio.Client, error) {
+	if s.router != nil {
+		return s.router.Client(ctx, storageID)
+	}
+	if storageID != "" {
+		return nil, fmt.Errorf("organization storage is not available")
+	}
+	return s.minioClient, nil
+}
+
+// reencrypt rewrites an object under its owner's current data key
+func (s *EncryptionService) reencrypt(ctx context.Context, client *minio.Client, bucket, objectPath string) error {
+	info, err := client.GetFileInfo(ctx, bucket, objectPath)
+	if err != nil {
+		return err
+	}
+	data, err := client.DownloadFile(ctx, bucket, objectPath)
+	if err != nil {
+		return err
+	}
+	_, err = client.UploadBytes(ctx, bucket, objectPath, data, info.ContentType)
+	return err
+}
+
+// RewrapKeys moves every data key wrapped by a previous master key to the
+// current one and returns how many were rewrapped. Afterwards the previous
+// master keys can be removed from the configuration.
+func (s *EncryptionService) RewrapKeys(ctx context.Context) (int, error) {
+	cursor, err := s.collection().Find(ctx, bson.M{"masterKeyId": bson.M{"$ne": s.masterID}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	var keys []models.DataKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return 0, fmt.Errorf("failed to decode data keys: %w", err)
+	}
+
+	rewrapped := 0
+	for _, key := range keys {
+		raw, err := s.unwrap(key)
+		if err != nil {
+			return rewrapped, fmt.Errorf("data key %s: %w", key.ID, err)
+		}
+		wrapped, err := s.wrap(raw)
+		if err != nil {
+			return rewrapped, err
+		}
+		_, err = s.collection().UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{"$set": bson.M{
+			"wrappedKey":  wrapped,
+			"masterKeyId": s.masterID,
+		}})
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to save data key %s: %w", key.ID, err)
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// currentKey returns the user's newest data key, creating the first one
+func (s *EncryptionService) currentKey(ctx context.Context, userID string) (*models.DataKey, error) {
+	var key models.DataKey
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	err := s.collection().FindOne(ctx, bson.M{"userId": userID}, opts).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s.createKey(ctx, userID, 1)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key: %w", err)
+	}
+	return &key, nil
+}
+
+// createKey generates and stores a data key version. If a concurrent
+// request created the same version first, that key is returned instead.
+func (s *EncryptionService) createKey(ctx context.Context, userID string, version int) (*models.DataKey, error) {
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, err
+	}
+	wrapped, err := s.wrap(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.DataKey{
+		ID:          fmt.Sprintf("%s:%d", userID, version),
+		UserID:      userID,
+		Version:     version,
+		WrappedKey:  wrapped,
+		MasterKeyID: s.masterID,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := s.collection().InsertOne(ctx, key); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			var existing models.DataKey
+			if err := s.collection().FindOne(ctx, bson.M{"_id": key.ID}).Decode(&existing); err != nil {
+				return nil, fmt.Errorf("failed to load data key: %w", err)
+			}
+			return &existing, nil
+		}
+		return nil, fmt.Errorf("failed to save data key: %w", err)
+	}
+	return key, nil
+}
+
+// dataKey returns the unwrapped data key with the given ID
+func (s *EncryptionService) dataKey(ctx context.Context, keyID string) (cipher.AEAD, error) {
+	s.mu.Lock()
+	aead, ok := s.keys[keyID]
+	s.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	var key models.DataKey
+	if err := s.collection().FindOne(ctx, bson.M{"_id": keyID}).Decode(&key); err != nil {
+		return nil, fmt.Errorf("failed to load data key %s: %w", keyID, err)
+	}
+	raw, err := s.unwrap(key)
+	if err != nil {
+		return nil, fmt.Errorf("data key %s: %w", keyID, err)
+	}
+	if aead, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keys[keyID] = aead
+	s.mu.Unlock()
+	return aead, nil
+}
+
+// wrap encrypts a data key with the current master key
+func (s *EncryptionService) wrap(raw []byte) ([]byte, error) {
+	master := s.masters[s.masterID]
+	nonce := make([]byte, master.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return master.Seal(nonce, nonce, raw, nil), nil
+}
+
+// unwrap decrypts a data key with the master key that wrapped it
+func (s *EncryptionService) unwrap(key models.DataKey) ([]byte, error) {
+	master, ok := s.masters[key.MasterKeyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	if len(key.WrappedKey) < master.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is truncated")
+	}
+	nonce, sealed := key.WrappedKey[:master.NonceSize()], key.WrappedKey[master.NonceSize():]
+	return master.Open(nil, nonce, sealed, nil)
+}
+
+func (s *EncryptionService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(dataKeyCollection)
+}
+
+// sealedKeyID returns the data key ID of an encrypted object
+func sealedKeyID(data []byte) (string, bool) {
+	if !bytes.HasPrefix(data, sealedMagic) || len(data) < len(sealedMagic)+2 {
+		return "", false
+	}
+	n := int(binary.BigEndian.Uint16(data[len(sealedMagic):]))
+	start := len(sealedMagic) + 2
+	if len(data) < start+n {
+		return "", false
+	}
+	return string(data[start : start+n]), true
+}
+
+// objectOwner returns the user a user files bucket object belongs to.
+// Library documents live under "library/<uid>/", everything else under
+// "<uid>/".
+func objectOwner(objectPath string) string {
+	parts := strings.SplitN(objectPath, "/", 3)
+	if parts[0] == "library" && len(parts) > 1 {
+		return parts[1]
+	}
+	if len(parts) > 1 {
+		return parts[0]
+	}
+	return ""
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"brainy-pdf/internal/models"
+)
+
+// maxEntitiesPerDocument bounds the entities indexed for one document; the
+// most mentioned are kept
+const maxEntitiesPerDocument = 500
+
+var (
+	// companyName matches up to five capitalized words ending in a company
+	// suffix, e.g. "Acme Supplies Ltd", "Globex Corporation"
+	companyName = regexp.MustCompile(`\b((?:[A-Z][A-Za-z0-9&'-]*\s+){0,4}[A-Z][A-Za-z0-9&'-]*),?\s+(Ltd|Limited|Inc|LLC|LLP|GmbH|Corp|Corporation|Co|Company|PLC|Plc|Pvt\.?\s+Ltd|AG|S\.A|B\.V)\b\.?`)
+	// personTitled matches names after an honorific, e.g. "Dr. Jane Smith"
+	personTitled = regexp.MustCompile(`\b(?:Mr|Mrs|Ms|Miss|Mx|Dr|Prof)\.?\s+([A-Z][a-z'-]+(?:\s+[A-Z][a-z'-]+){0,2})`)
+	// personLabelled matches names after a label, e.g. "Attn: Jane Smith"
+	personLabelled = regexp.MustCompile(`(?i:attn|attention|contact|contact person|signed by|prepared by|approved by|authori[sz]ed by|customer name|client name|employee name)\s*:?\s+([A-Z][a-z'-]+(?:\s+[A-Z]\.)?(?:\s+[A-Z][a-z'-]+){1,2})\b`)
+	// invoiceNumber matches "Invoice No: INV-1042", "Bill # 2024/17"
+	invoiceNumber = regexp.MustCompile(`(?i)\b(?:invoice|inv|bill)\s*(?:no\.?|number|num\.?|#)\s*[:#.]?\s*([A-Z0-9][A-Z0-9\-/]{2,})`)
+	// companySuffixWords are dropped from company keys
+	companySuffixWords = map[string]bool{
+		"ltd": true, "limited": true, "inc": true, "llc": true, "llp": true, "gmbh": true, "corp": true,
+		"corporation": true, "co": true, "company": true, "plc": true, "pvt": true, "ag": true, "sa": true, "bv": true,
+	}
+)
+
+// entityTypes are the types of entity indexed, tried in turn when a query
+// doesn't give one
+var entityTypes = []string{models.EntityPerson, models.EntityCompany, models.EntityInvoiceNumber, models.EntityDate}
+
+// ExtractEntities finds the people, companies, invoice numbers and dates
+// mentioned in a document by pattern, given the text of each page one line
+// per row. Dates are only indexed when day and month are unambiguous.
+func ExtractEntities(pages []string) []models.DocumentEntity {
+	found := entitySet{}
+	for i, text := range pages {
+		page := i + 1
+		for _, line := range strings.Split(text, "\n") {
+			for _, m := range companyName.FindAllStringSubmatch(line, -1) {
+				found.add(models.EntityCompany, m[1]+" "+m[2], page)
+			}
+			for _, re := range []*regexp.Regexp{personTitled, personLabelled} {
+				for _, m := range re.FindAllStringSubmatch(line, -1) {
+					if !companySuffix.MatchString(m[1]) {
+						found.add(models.EntityPerson, m[1], page)
+					}
+				}
+			}
+			for _, m := range invoiceNumber.FindAllStringSubmatch(line, -1) {
+				if strings.ContainsAny(m[1], "0123456789") {
+					found.add(models.EntityInvoiceNumber, m[1], page)
+				}
+			}
+			for _, date := range datesIn(line) {
+				found.add(models.EntityDate, date, page)
+			}
+		}
+	}
+	return found.entities()
+}
+
+// FindMentions adds to entities each name of the given type found
+// verbatim, ignoring case, on the pages. Names found nowhere, or already
+// among entities, are left out.
+func FindMentions(entities []models.DocumentEntity, entityType string, names []string, pages []string) []models.DocumentEntity {
+	found := entitySet{}
+	for i := range entities {
+		e := entities[i]
+		found[e.Type+"\x00"+e.Key] = &e
+	}
+	lower := make([]string, len(pages))
+	for i, text := range pages {
+		lower[i] = strings.ToLower(text)
+	}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		needle := strings.ToLower(name)
+		if _, known := found[entityType+"\x00"+EntityKey(entityType, name)]; known || len(needle) < 3 {
+			continue
+		}
+		for i, text := range lower {
+			for n := strings.Count(text, needle); n > 0; n-- {
+				found.add(entityType, name, i+1)
+			}
+		}
+	}
+	return found.entities()
+}
+
+// entitySet collects entities by type and key
+type entitySet map[string]*models.DocumentEntity
+
+func (s entitySet) add(entityType, value string, page int) {
+	value = strings.Trim(strings.Join(strings.Fields(value), " "), " ,.;:")
+	if entityType == models.EntityInvoiceNumber {
+		value = strings.ToUpper(value)
+	}
+	key := EntityKey(entityType, value)
+	if key == "" {
+		return
+	}
+	e, ok := s[entityType+"\x00"+key]
+	if !ok {
+		e = &models.DocumentEntity{Type: entityType, Value: value, Key: key}
+		s[entityType+"\x00"+key] = e
+	}
+	e.Mentions++
+	if n := len(e.Pages); n == 0 || e.Pages[n-1] != page {
+		e.Pages = mergePages(e.Pages, []int{page})
+	}
+}
+
+// entities returns the set, most mentioned first, cut to
+// maxEntitiesPerDocument
+func (s entitySet) entities() []models.DocumentEntity {
+	out := make([]models.DocumentEntity, 0, len(s))
+	for _, e := range s {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Mentions != out[j].Mentions {
+			return out[i].Mentions > out[j].Mentions
+		}
+		if out[i].Type != out[j].Type {
+			return out[i].Type < out[j].Type
+		}
+		return out[i].Key < out[j].Key
+	})
+	if len(out) > maxEntitiesPerDocument {
+		out = out[:maxEntitiesPerDocument]
+	}
+	return out
+}
+
+// EntityKey normalizes an entity for matching: words are lowercased with
+// punctuation dropped, company suffixes are ignored so "ACME Corp." matches
+// "Acme Corporation", invoice numbers ignore spaces and case, and dates are
+// keyed YYYY-MM-DD. An empty type gives the plain word key.
+func EntityKey(entityType, value string) string {
+	switch entityType {
+	case models.EntityInvoiceNumber:
+		return strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	case models.EntityDate:
+		return findDate(value)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&'
+	})
+	if entityType == models.EntityCompany {
+		// "S.A." splits into "s" and "a"
+		for len(words) > 1 && (companySuffixWords[words[len(words)-1]] || companySuffixWords[strings.Join(words[len(words)-2:], "")]) {
+			if !companySuffixWords[words[len(words)-1]] {
+				words = words[:len(words)-1]
+			}
+			words = words[:len(words)-1]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// datesIn returns every date in s as YYYY-MM-DD, skipping those whose day
+// and month can't be told apart
+func datesIn(s string) []string {
+	var dates []string
+	for _, re := range []*regexp.Regexp{isoDate, dayMonthDate, monthDayDate, numericDate} {
+		for _, m := range re.FindAllString(s, -1) {
+			if date := findDate(m); date != "" && isoDate.MatchString(date) {
+				dates = append(dates, date)
+			}
+		}
+	}
+	return dates
+}
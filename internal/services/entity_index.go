@@ -0,0 +1,390 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	entityCollection = "library_entities"
+	entityIndexLease = "entity-index"
+	// entityIndexInterval is how often library documents missed at upload,
+	// such as those stored before the index existed, are picked up
+	entityIndexInterval = 10 * time.Minute
+	entityIndexBatch    = 50
+	// entityIndexConcurrency bounds the documents read at once per instance
+	entityIndexConcurrency = 2
+	// entityIndexStale is how long a document may stay pending before it is
+	// queued again, e.g. after the instance reading it stopped
+	entityIndexStale = 30 * time.Minute
+	// entityIndexMaxSize and entityIndexMaxPages bound the work for one
+	// document; larger files are indexed from their first pages
+	entityIndexMaxSize  = 50 * 1024 * 1024
+	entityIndexMaxPages = 300
+	// entityDocumentIDs bounds the documents listed with each entity
+	entityDocumentIDs = 20
+)
+
+// EntityQuery selects entities across a user's library
+type EntityQuery struct {
+	Type   string // person, company, invoice_number or date; "" for all
+	Search string // matches entity values, ignoring case
+	Limit  int
+}
+
+// EntityIndexService extracts the people, companies, invoice numbers and
+// dates mentioned in library PDFs in the background and keeps one row per
+// document and entity, so the library can be browsed and filtered by them.
+// Documents are queued on upload and removed on delete; a periodic job
+// picks up any left unindexed. Names are found by pattern, and also by the
+// AI model when aiService is set, except for confidential documents of
+// organizations that disable AI for them.
+type EntityIndexService struct {
+	mongoClient *mongodb.Client
+	storage     *StorageService
+	pdfService  *PDFService
+	aiService   *AIService
+	orgService  *OrgService
+	slots       chan struct{}
+}
+
+// NewEntityIndexService creates an entity index. aiService may be nil to
+// extract by pattern only.
+func NewEntityIndexService(mongoClient *mongodb.Client, storage *StorageService, pdfService *PDFService, aiService *AIService, orgService *OrgService) *EntityIndexService {
+	s := &EntityIndexService{
+		mongoClient: mongoClient,
+		storage:     storage,
+		pdfService:  pdfService,
+		aiService:   aiService,
+		orgService:  orgService,
+		slots:       make(chan struct{}, entityIndexConcurrency),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "type", Value: 1}, {Key: "key", Value: 1}}},
+		{Keys: bson.D{{Key: "documentId", Value: 1}}},
+	}); err != nil {
+		log.Printf("[EntityIndex] Failed to create indexes: %v", err)
+	}
+	return s
+}
+
+func (s *EntityIndexService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(entityCollection)
+}
+
+// AfterUpload implements UploadHook by queueing new library PDFs
+func (s *EntityIndexService) AfterUpload(doc models.Document) {
+	if doc.IsTemporary || doc.OwnerUID == "" || doc.MimeType != "application/pdf" {
+		return
+	}
+	s.queue(doc)
+}
+
+// AfterDelete implements DeleteHook by dropping the document's entities
+func (s *EntityIndexService) AfterDelete(doc models.Document) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.collection().DeleteMany(ctx, bson.M{"documentId": doc.ID.Hex()}); err != nil {
+		log.Printf("[EntityIndex] Failed to remove %s: %v", doc.ID.Hex(), err)
+	}
+}
+
+// Run indexes library documents left unindexed from whichever instance
+// holds the entity index lease. Blocks until ctx is cancelled.
+func (s *EntityIndexService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, entityIndexLease, entityIndexInterval, func(ctx context.Context) {
+		filter := bson.M{
+			"ownerUid":    bson.M{"$ne": ""},
+			"isTemporary": false,
+			"mimeType":    "application/pdf",
+			"$or":         unindexed(),
+		}
+		cursor, err := s.mongoClient.Documents().Find(ctx, filter, options.Find().SetLimit(entityIndexBatch))
+		if err != nil {
+			log.Printf("[EntityIndex] Failed to find unindexed documents: %v", err)
+			return
+		}
+		var docs []models.Document
+		if err := cursor.All(ctx, &docs); err != nil {
+			log.Printf("[EntityIndex] Failed to decode unindexed documents: %v", err)
+			return
+		}
+		for _, doc := range docs {
+			s.queue(doc)
+		}
+	})
+}
+
+// unindexed matches documents never indexed or whose indexing stalled
+func unindexed() bson.A {
+	return bson.A{
+		bson.M{"entityIndex": bson.M{"$exists": false}},
+		bson.M{
+			"entityIndex.status":      models.EntityIndexPending,
+			"entityIndex.requestedAt": bson.M{"$lt": time.Now().Add(-entityIndexStale)},
+		},
+	}
+}
+
+// queue marks doc pending and indexes it in the background, unless another
+// instance already claimed it
+func (s *EntityIndexService) queue(doc models.Document) {
+	pending := models.EntityIndex{Status: models.EntityIndexPending, RequestedAt: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := s.mongoClient.Documents().UpdateOne(ctx,
+		bson.M{"_id": doc.ID, "$or": unindexed()},
+		bson.M{"$set": bson.M{"entityIndex": pending}})
+	if err != nil {
+		log.Printf("[EntityIndex] Failed to queue %s: %v", doc.ID.Hex(), err)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		return
+	}
+	go s.index(doc, pending)
+}
+
+// index reads one queued document and replaces its entities
+func (s *EntityIndexService) index(doc models.Document, result models.EntityIndex) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	entities, source, err := s.extract(ctx, &doc)
+	now := time.Now()
+	if err == nil {
+		err = s.replace(ctx, &doc, entities, now)
+	}
+	if err != nil {
+		result.Status = models.EntityIndexError
+		result.Error = err.Error()
+		log.Printf("[EntityIndex] Failed to index %s: %v", doc.ID.Hex(), err)
+	} else {
+		result.Status = models.EntityIndexDone
+		result.Entities = len(entities)
+		result.Source = source
+		result.IndexedAt = &now
+	}
+
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	res, err := s.mongoClient.Documents().UpdateOne(saveCtx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"entityIndex": result}})
+	if err != nil {
+		log.Printf("[EntityIndex] Failed to save status for %s: %v", doc.ID.Hex(), err)
+		return
+	}
+	if res.MatchedCount == 0 {
+		// Deleted while it was being read
+		s.AfterDelete(doc)
+	}
+}
+
+// extract reads a document's entities, returning "ai" as the source when
+// the model named its people and companies
+func (s *EntityIndexService) extract(ctx context.Context, doc *models.Document) ([]models.DocumentEntity, string, error) {
+	if doc.Size > entityIndexMaxSize {
+		return nil, "", fmt.Errorf("file is larger than %d MB", entityIndexMaxSize/(1024*1024))
+	}
+	data, err := s.storage.ReadDocument(ctx, doc)
+	if err != nil {
+		return nil, "", err
+	}
+	pages, _, err := s.pdfService.PageLineTexts(ctx, bytes.NewReader(data), entityIndexMaxPages)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read text: %w", err)
+	}
+
+	entities := ExtractEntities(pages)
+	if s.aiService == nil || !s.aiAllowed(ctx, doc) {
+		return entities, "pattern", nil
+	}
+	people, companies, err := s.aiService.ExtractEntityNames(ctx, pages)
+	if err != nil {
+		// Pattern matches still stand when the model fails
+		log.Printf("[EntityIndex] AI extraction failed for %s, using patterns: %v", doc.ID.Hex(), err)
+		return entities, "pattern", nil
+	}
+	entities = FindMentions(entities, models.EntityPerson, people, pages)
+	entities = FindMentions(entities, models.EntityCompany, companies, pages)
+	return entities, "ai", nil
+}
+
+// aiAllowed reports whether doc may be sent to the AI model under its
+// owner's organization policy
+func (s *EntityIndexService) aiAllowed(ctx context.Context, doc *models.Document) bool {
+	confidential := false
+	for _, tag := range doc.Metadata.Tags {
+		confidential = confidential || tag == models.TagConfidential
+	}
+	if !confidential {
+		return true
+	}
+	org, err := s.orgService.ForUser(ctx, doc.OwnerUID)
+	if err != nil {
+		log.Printf("[EntityIndex] Policy lookup for %s failed, using patterns: %v", doc.OwnerUID, err)
+		return false
+	}
+	return org == nil || !org.Policy.AIDisabledForConfidential
+}
+
+// replace swaps the document's rows in the index for entities
+func (s *EntityIndexService) replace(ctx context.Context, doc *models.Document, entities []models.DocumentEntity, now time.Time) error {
+	documentID := doc.ID.Hex()
+	if _, err := s.collection().DeleteMany(ctx, bson.M{"documentId": documentID}); err != nil {
+		return fmt.Errorf("failed to clear entities: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	rows := make([]interface{}, len(entities))
+	for i, e := range entities {
+		e.UserID, e.DocumentID, e.CreatedAt = doc.OwnerUID, documentID, now
+		rows[i] = e
+	}
+	if _, err := s.collection().InsertMany(ctx, rows); err != nil {
+		return fmt.Errorf("failed to save entities: %w", err)
+	}
+	return nil
+}
+
+// Entities lists the entities across userID's library, those in the most
+// documents first, each with the documents mentioning it most recently
+// indexed first
+func (s *EntityIndexService) Entities(ctx context.Context, userID string, q EntityQuery) ([]models.LibraryEntity, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	match := bson.M{"userId": userID}
+	if q.Type != "" {
+		match["type"] = q.Type
+	}
+	if q.Search != "" {
+		match["value"] = bson.M{"$regex": regexp.QuoteMeta(q.Search), "$options": "i"}
+	}
+
+	cursor, err := s.collection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         bson.M{"type": "$type", "key": "$key"},
+			"value":       bson.M{"$first": "$value"},
+			"documents":   bson.M{"$sum": 1},
+			"mentions":    bson.M{"$sum": "$mentions"},
+			"documentIds": bson.M{"$push": "$documentId"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "documents", Value: -1}, {Key: "mentions", Value: -1}, {Key: "_id.key", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"type":        "$_id.type",
+			"key":         "$_id.key",
+			"value":       1,
+			"documents":   1,
+			"mentions":    1,
+			"documentIds": bson.M{"$slice": bson.A{"$documentIds", entityDocumentIDs}},
+		}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	entities := []models.LibraryEntity{}
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, fmt.Errorf("failed to decode entities: %w", err)
+	}
+	return entities, nil
+}
+
+// Progress counts userID's library PDFs by entity index status
+func (s *EntityIndexService) Progress(ctx context.Context, userID string) (models.EntityIndexProgress, error) {
+	var p models.EntityIndexProgress
+	for status, count := range map[string]*int64{
+		models.EntityIndexDone:  &p.Indexed,
+		models.EntityIndexError: &p.Failed,
+	} {
+		filter := LibraryFilter(userID)
+		filter["mimeType"] = "application/pdf"
+		filter["entityIndex.status"] = status
+		n, err := s.mongoClient.Documents().CountDocuments(ctx, filter)
+		if err != nil {
+			return p, fmt.Errorf("failed to count indexed documents: %w", err)
+		}
+		*count = n
+	}
+
+	filter := LibraryFilter(userID)
+	filter["mimeType"] = "application/pdf"
+	filter["$or"] = bson.A{
+		bson.M{"entityIndex": bson.M{"$exists": false}},
+		bson.M{"entityIndex.status": models.EntityIndexPending},
+	}
+	n, err := s.mongoClient.Documents().CountDocuments(ctx, filter)
+	if err != nil {
+		return p, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	p.Pending = n
+	return p, nil
+}
+
+// DocumentIDs returns the documents in userID's library mentioning value,
+// matched by key so "ACME Corp" finds "Acme Corporation". With no type,
+// value is matched as any type.
+func (s *EntityIndexService) DocumentIDs(ctx context.Context, userID, value, entityType string) ([]primitive.ObjectID, error) {
+	types := []string{entityType}
+	if entityType == "" {
+		types = entityTypes
+	}
+	var keys bson.A
+	for _, t := range types {
+		if key := EntityKey(t, value); key != "" {
+			keys = append(keys, bson.M{"type": t, "key": key})
+		}
+	}
+	ids := []primitive.ObjectID{}
+	if len(keys) == 0 {
+		return ids, nil
+	}
+
+	values, err := s.collection().Distinct(ctx, "documentId", bson.M{"userId": userID, "$or": keys})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find documents by entity: %w", err)
+	}
+	for _, v := range values {
+		if hex, ok := v.(string); ok {
+			if id, err := primitive.ObjectIDFromHex(hex); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ValidEntityType reports whether t names an entity type
+func ValidEntityType(t string) bool {
+	switch t {
+	case models.EntityPerson, models.EntityCompany, models.EntityInvoiceNumber, models.EntityDate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// estimateWindow and estimateSamples bound the past runs an estimate
+	// is drawn from: the most recent within the window
+	estimateWindow  = 30 * 24 * time.Hour
+	estimateSamples = 1000
+	// minEstimateSamples is how many runs a basis needs to be used
+	minEstimateSamples = 5
+)
+
+// estimateQueue is a background job queue an operation waits in
+type estimateQueue struct {
+	collection string // job records with createdAt and completedAt
+	workers    int
+	depth      func(ctx context.Context) (int64, error)
+}
+
+// EstimateService predicts how long an operation will take from the
+// percentiles of its recent successful runs in operation_logs, scaled to
+// the pages and size given. Operations run through a job queue are
+// predicted from their job records and add the wait behind the jobs
+// queued now.
+type EstimateService struct {
+	mongoClient *mongodb.Client
+	queues      map[string]estimateQueue
+}
+
+// NewEstimateService creates an estimate service
+func NewEstimateService(mongoClient *mongodb.Client) *EstimateService {
+	return &EstimateService{mongoClient: mongoClient, queues: map[string]estimateQueue{}}
+}
+
+// AddConversionQueue estimates the convert operation from conversion
+// jobs, run by workers per instance. Call it during startup.
+func (s *EstimateService) AddConversionQueue(conversion *ConversionService, workers int) {
+	s.queues["convert"] = estimateQueue{collection: conversionJobsCollection, workers: workers, depth: conversion.QueueDepth}
+}
+
+// AddSummaryQueue estimates the summarize operation from summary jobs,
+// run by workers per instance. Call it during startup.
+func (s *EstimateService) AddSummaryQueue(summary *SummaryService, workers int) {
+	s.queues["summarize"] = estimateQueue{collection: summaryJobsCollection, workers: workers, depth: summary.QueueDepth}
+}
+
+// estimateSample is one past run
+type estimateSample struct {
+	ProcessingMs int64 `bson:"processingMs"`
+	PageCount    int   `bson:"pageCount"`
+	InputBytes   int64 `bson:"inputBytes"`
+}
+
+// Estimate predicts the duration of operation on a file of size bytes and
+// pages pages; either may be 0 when unknown
+func (s *EstimateService) Estimate(ctx context.Context, operation string, size int64, pages int) (*models.OperationEstimate, error) {
+	est := &models.OperationEstimate{Operation: operation, Size: size, Pages: pages}
+
+	if q, ok := s.queues[operation]; ok {
+		samples, err := s.jobSamples(ctx, q.collection)
+		if err != nil {
+			return nil, err
+		}
+		estimateFrom(est, samples, size, pages)
+
+		est.Queued = true
+		if est.QueueDepth, err = q.depth(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read queue depth: %w", err)
+		}
+		// Each job ahead takes about as long as this one, shared between
+		// the workers
+		workers := int64(max(q.workers, 1))
+		est.QueueWaitMs = &models.EstimateRange{
+			P50: est.QueueDepth * est.DurationMs.P50 / workers,
+			P90: est.QueueDepth * est.DurationMs.P90 / workers,
+		}
+		est.TotalMs = models.EstimateRange{
+			P50: est.DurationMs.P50 + est.QueueWaitMs.P50,
+			P90: est.DurationMs.P90 + est.QueueWaitMs.P90,
+		}
+		return est, nil
+	}
+
+	cursor, err := s.mongoClient.Collection("operation_logs").Find(ctx, bson.M{
+		"operation": operation,
+		"status":    models.OperationStatusSuccess,
+		"createdAt": bson.M{"$gte": time.Now().Add(-estimateWindow)},
+	}, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(estimateSamples).
+		SetProjection(bson.M{"processingMs": 1, "pageCount": 1, "inputBytes": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation history: %w", err)
+	}
+	var samples []estimateSample
+	if err := cursor.All(ctx, &samples); err != nil {
+		return nil, fmt.Errorf("failed to decode operation history: %w", err)
+	}
+	estimateFrom(est, samples, size, pages)
+	est.TotalMs = est.DurationMs
+	return est, nil
+}
+
+// jobSamples reads how long recent completed jobs took from submission
+func (s *EstimateService) jobSamples(ctx context.Context, collection string) ([]estimateSample, error) {
+	cursor, err := s.mongoClient.Collection(collection).Find(ctx, bson.M{
+		"status":      JobStatusCompleted,
+		"completedAt": bson.M{"$gte": time.Now().Add(-estimateWindow)},
+	}, options.Find().
+		SetSort(bson.D{{Key: "completedAt", Value: -1}}).
+		SetLimit(estimateSamples).
+		SetProjection(bson.M{"createdAt": 1, "completedAt": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job history: %w", err)
+	}
+	var jobs []struct {
+		CreatedAt   time.Time `bson:"createdAt"`
+		CompletedAt time.Time `bson:"completedAt"`
+	}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to decode job history: %w", err)
+	}
+	samples := make([]estimateSample, 0, len(jobs))
+	for _, job := range jobs {
+		if ms := job.CompletedAt.Sub(job.CreatedAt).Milliseconds(); ms > 0 {
+			samples = append(samples, estimateSample{ProcessingMs: ms})
+		}
+	}
+	return samples, nil
+}
+
+// estimateFrom fills in est's basis, samples and duration. Runs of about
+// the same size are used when there are enough; otherwise the time per
+// page or per MB of every run is scaled up, and failing that every run
+// counts alike.
+func estimateFrom(est *models.OperationEstimate, samples []estimateSample, size int64, pages int) {
+	if size > 0 || pages > 0 {
+		var similar []float64
+		for _, sm := range samples {
+			if (pages == 0 || near(float64(sm.PageCount), float64(pages))) &&
+				(size == 0 || near(float64(sm.InputBytes), float64(size))) {
+				similar = append(similar, float64(sm.ProcessingMs))
+			}
+		}
+		if len(similar) >= minEstimateSamples {
+			setEstimate(est, models.EstimateBasisSimilar, similar, 1)
+			return
+		}
+	}
+	if pages > 0 {
+		var perPage []float64
+		for _, sm := range samples {
+			if sm.PageCount > 0 {
+				perPage = append(perPage, float64(sm.ProcessingMs)/float64(sm.PageCount))
+			}
+		}
+		if len(perPage) >= minEstimateSamples {
+			setEstimate(est, models.EstimateBasisPages, perPage, float64(pages))
+			return
+		}
+	}
+	if size > 0 {
+		const mb = 1024 * 1024
+		var perMB []float64
+		for _, sm := range samples {
+			if sm.InputBytes > 0 {
+				perMB = append(perMB, float64(sm.ProcessingMs)/(float64(sm.InputBytes)/mb))
+			}
+		}
+		if len(perMB) >= minEstimateSamples {
+			setEstimate(est, models.EstimateBasisSize, perMB, float64(size)/mb)
+			return
+		}
+	}
+	if len(samples) == 0 {
+		est.Basis = models.EstimateBasisNone
+		return
+	}
+	all := make([]float64, len(samples))
+	for i, sm := range samples {
+		all[i] = float64(sm.ProcessingMs)
+	}
+	setEstimate(est, models.EstimateBasisOperation, all, 1)
+}
+
+// near reports whether v is within a factor of two of target
+func near(v, target float64) bool {
+	return v >= target/2 && v <= target*2
+}
+
+func setEstimate(est *models.OperationEstimate, basis string, values []float64, scale float64) {
+	sort.Float64s(values)
+	est.Basis = basis
+	est.Samples = len(values)
+	est.DurationMs = models.EstimateRange{
+		P50: int64(math.Ceil(samplePercentile(values, 0.5) * scale)),
+		P90: int64(math.Ceil(samplePercentile(values, 0.9) * scale)),
+	}
+}
+
+// samplePercentile returns the p-th percentile of sorted values, interpolating
+// between the nearest two
+func samplePercentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(pos-float64(lower))
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxFilenameLength bounds a stored display name, in bytes, as most
+// filesystems do
+const MaxFilenameLength = 255
+
+// untitledFilename replaces names with nothing left after normalizing
+const untitledFilename = "untitled"
+
+// NormalizeFilename turns a client-supplied filename into a safe display
+// name: any directory part ("../../x.pdf", "C:\x.pdf") is dropped, the
+// name is NFC-normalized, control and reserved characters become "_",
+// surrounding dots and spaces are trimmed and the name is cut to
+// MaxFilenameLength bytes, keeping its extension. Display names are kept
+// on the document; object keys never contain them.
+func NormalizeFilename(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = sanitizeFilenamePart(norm.NFC.String(name))
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return untitledFilename
+	}
+
+	if len(name) > MaxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = truncateUTF8(strings.TrimSuffix(name, ext), MaxFilenameLength-len(ext))
+		name = strings.TrimRight(name, " .") + ext
+	}
+	return name
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxFilenameTemplateLength bounds the length of a filename template
+const MaxFilenameTemplateLength = 120
+
+// maxOutputFilenameLength bounds a rendered name, before the extension
+const maxOutputFilenameLength = 200
+
+// ErrInvalidFilenameTemplate is returned for templates with unknown tokens
+// or characters unsafe in a filename
+var ErrInvalidFilenameTemplate = errors.New("invalid filename template")
+
+// filenameTokenPattern matches a template token such as {original}
+var filenameTokenPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// filenameTokens are the tokens a template may use
+var filenameTokens = map[string]bool{
+	"{original}":  true, // input filename without extension
+	"{operation}": true, // e.g. merge, split, rotate
+	"{date}":      true, // YYYYMMDD
+	"{time}":      true, // HHMMSS
+	"{range}":     true, // page range of a split part, e.g. 1-3
+}
+
+// FilenameVars are the values substituted into a filename template
+type FilenameVars struct {
+	Original  string
+	Operation string
+	Range     string
+	Time      time.Time
+}
+
+// ValidateFilenameTemplate checks that tmpl only uses known tokens and no
+// path separators, reserved or control characters
+func ValidateFilenameTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("%w: template is empty", ErrInvalidFilenameTemplate)
+	}
+	if len(tmpl) > MaxFilenameTemplateLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidFilenameTemplate, MaxFilenameTemplateLength)
+	}
+	for _, token := range filenameTokenPattern.FindAllString(tmpl, -1) {
+		if !filenameTokens[token] {
+			return fmt.Errorf("%w: unknown token %s", ErrInvalidFilenameTemplate, token)
+		}
+	}
+	literal := filenameTokenPattern.ReplaceAllString(tmpl, "")
+	if strings.ContainsAny(literal, "{}") {
+		return fmt.Errorf("%w: unbalanced braces", ErrInvalidFilenameTemplate)
+	}
+	if strings.Contains(literal, "..") || strings.HasPrefix(tmpl, ".") {
+		return fmt.Errorf("%w: must not start with a dot or contain \"..\"", ErrInvalidFilenameTemplate)
+	}
+	for _, r := range literal {
+		if unsafeFilenameRune(r) {
+			return fmt.Errorf("%w: character %q is not allowed", ErrInvalidFilenameTemplate, r)
+		}
+	}
+	return nil
+}
+
+// RenderFilename fills in a validated template and appends ".pdf". Token
+// values come from user input, so unsafe characters in them are replaced.
+func RenderFilename(tmpl string, vars FilenameVars) string {
+	values := map[string]string{
+		"{original}":  vars.Original,
+		"{operation}": vars.Operation,
+		"{date}":      vars.Time.Format("20060102"),
+		"{time}":      vars.Time.Format("150405"),
+		"{range}":     vars.Range,
+	}
+	name := filenameTokenPattern.ReplaceAllStringFunc(tmpl, func(token string) string {
+		return sanitizeFilenamePart(values[token])
+	})
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".pdf")
+	name = strings.TrimLeft(name, ".")
+	if len(name) > maxOutputFilenameLength {
+		name = name[:maxOutputFilenameLength]
+	}
+	if name == "" {
+		name = sanitizeFilenamePart(vars.Operation)
+	}
+	return name + ".pdf"
+}
+
+// sanitizeFilenamePart replaces characters unsafe in a filename with "_"
+func sanitizeFilenamePart(s string) string {
+	s = strings.ReplaceAll(s, "..", "_")
+	return strings.Map(func(r rune) rune {
+		if unsafeFilenameRune(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// unsafeFilenameRune reports path separators, characters reserved on
+// Windows and control characters
+func unsafeFilenameRune(r rune) bool {
+	return strings.ContainsRune(`/\:*?"<>|`, r) || unicode.IsControl(r)
+}
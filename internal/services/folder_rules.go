@@ -0,0 +1,444 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by FolderRuleService
+var (
+	ErrRuleNotFound = errors.New("folder rule not found")
+	ErrInvalidRule  = errors.New("invalid folder rule")
+)
+
+const (
+	folderRuleCollection = "folder_rules"
+	maxFolderRules       = 50
+	maxRuleConditions    = 10
+	maxRuleValueLen      = 200
+	maxRuleNameLen       = 100
+	maxFolderDepth       = 5
+	maxFolderNameLen     = 100
+	// folderRuleConcurrency bounds the uploads filed at once per instance
+	folderRuleConcurrency = 4
+)
+
+// FolderRuleService keeps each user's folder rules and runs them on every
+// file stored in their library, moving matches into a folder and tagging
+// them. Rules run in the background after upload, since testing a
+// document's type means reading its first page.
+type FolderRuleService struct {
+	mongoClient *mongodb.Client
+	storage     *StorageService
+	pdfService  *PDFService
+	slots       chan struct{}
+}
+
+// NewFolderRuleService creates a folder rule service
+func NewFolderRuleService(mongoClient *mongodb.Client, storage *StorageService, pdfService *PDFService) *FolderRuleService {
+	s := &FolderRuleService{
+		mongoClient: mongoClient,
+		storage:     storage,
+		pdfService:  pdfService,
+		slots:       make(chan struct{}, folderRuleConcurrency),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "ownerUid", Value: 1}, {Key: "position", Value: 1}},
+	}); err != nil {
+		log.Printf("[FolderRules] Failed to create rule index: %v", err)
+	}
+	// Folders created by rules are unique per parent, so concurrent uploads
+	// filed to a new folder share it
+	if _, err := s.mongoClient.Folders().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "ownerUid", Value: 1}, {Key: "parentId", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"ownerUid": bson.M{"$exists": true}}),
+	}); err != nil {
+		log.Printf("[FolderRules] Failed to create folder index: %v", err)
+	}
+	return s
+}
+
+func (s *FolderRuleService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(folderRuleCollection)
+}
+
+// List returns userID's rules in the order they run
+func (s *FolderRuleService) List(ctx context.Context, userID string) ([]models.FolderRule, error) {
+	cursor, err := s.collection().Find(ctx, bson.M{"ownerUid": userID},
+		options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder rules: %w", err)
+	}
+	rules := []models.FolderRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode folder rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Create adds a rule for userID, run after their existing rules unless
+// it gives a position
+func (s *FolderRuleService) Create(ctx context.Context, userID string, rule models.FolderRule) (*models.FolderRule, error) {
+	if err := ValidateFolderRule(&rule); err != nil {
+		return nil, err
+	}
+	n, err := s.collection().CountDocuments(ctx, bson.M{"ownerUid": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count folder rules: %w", err)
+	}
+	if n >= maxFolderRules {
+		return nil, fmt.Errorf("%w: at most %d rules", ErrInvalidRule, maxFolderRules)
+	}
+
+	now := time.Now()
+	rule.ID = primitive.NewObjectID()
+	rule.OwnerUID = userID
+	if rule.Position <= 0 {
+		rule.Position = int(n) + 1
+	}
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	if _, err := s.collection().InsertOne(ctx, rule); err != nil {
+		return nil, fmt.Errorf("failed to save folder rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// Update replaces one of userID's rules, keeping its position unless a
+// new one is given
+func (s *FolderRuleService) Update(ctx context.Context, userID, id string, rule models.FolderRule) (*models.FolderRule, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRuleNotFound
+	}
+	if err := ValidateFolderRule(&rule); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{
+		"name":       rule.Name,
+		"enabled":    rule.Enabled,
+		"match":      rule.Match,
+		"conditions": rule.Conditions,
+		"actions":    rule.Actions,
+		"updatedAt":  time.Now(),
+	}
+	if rule.Position > 0 {
+		set["position"] = rule.Position
+	}
+	var updated models.FolderRule
+	err = s.collection().FindOneAndUpdate(ctx, bson.M{"_id": objID, "ownerUid": userID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update folder rule: %w", err)
+	}
+	return &updated, nil
+}
+
+// Delete removes one of userID's rules
+func (s *FolderRuleService) Delete(ctx context.Context, userID, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrRuleNotFound
+	}
+	res, err := s.collection().DeleteOne(ctx, bson.M{"_id": objID, "ownerUid": userID})
+	if err != nil {
+		return fmt.Errorf("failed to delete folder rule: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// ValidateFolderRule checks a rule and normalizes it in place: names are
+// trimmed, match defaults to "any", operators and document types are
+// matched ignoring case and the folder path is cleaned. Tags are expected
+// to be normalized already.
+func ValidateFolderRule(rule *models.FolderRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" || len(rule.Name) > maxRuleNameLen {
+		return fmt.Errorf("%w: name is required and at most %d characters", ErrInvalidRule, maxRuleNameLen)
+	}
+	switch rule.Match = strings.ToLower(strings.TrimSpace(rule.Match)); rule.Match {
+	case "":
+		rule.Match = models.RuleMatchAny
+	case models.RuleMatchAny, models.RuleMatchAll:
+	default:
+		return fmt.Errorf("%w: match must be any or all", ErrInvalidRule)
+	}
+
+	if len(rule.Conditions) == 0 || len(rule.Conditions) > maxRuleConditions {
+		return fmt.Errorf("%w: give 1-%d conditions", ErrInvalidRule, maxRuleConditions)
+	}
+	for i := range rule.Conditions {
+		cond := &rule.Conditions[i]
+		switch cond.Field {
+		case models.RuleFieldFilename, models.RuleFieldDocumentType, models.RuleFieldMimeType:
+		default:
+			return fmt.Errorf("%w: condition field must be filename, documentType or mimeType", ErrInvalidRule)
+		}
+		switch op := strings.ToLower(cond.Operator); op {
+		case "contains", "equals":
+			cond.Operator = op
+		case "startswith":
+			cond.Operator = models.RuleOpStartsWith
+		case "endswith":
+			cond.Operator = models.RuleOpEndsWith
+		default:
+			return fmt.Errorf("%w: condition operator must be contains, equals, startsWith or endsWith", ErrInvalidRule)
+		}
+		cond.Value = strings.TrimSpace(cond.Value)
+		if cond.Value == "" || len(cond.Value) > maxRuleValueLen {
+			return fmt.Errorf("%w: condition value is required and at most %d characters", ErrInvalidRule, maxRuleValueLen)
+		}
+	}
+
+	if rule.Actions.Folder != "" {
+		folder, err := CleanFolderPath(rule.Actions.Folder)
+		if err != nil {
+			return err
+		}
+		rule.Actions.Folder = folder
+	}
+	if rule.Actions.Folder == "" && len(rule.Actions.Tags) == 0 {
+		return fmt.Errorf("%w: a rule must move files to a folder or tag them", ErrInvalidRule)
+	}
+	return nil
+}
+
+// CleanFolderPath returns a folder path as "/Finance/2024": slashes
+// separate folders, surrounding spaces and empty parts are dropped
+func CleanFolderPath(path string) (string, error) {
+	parts := folderNames(path)
+	if len(parts) == 0 || len(parts) > maxFolderDepth {
+		return "", fmt.Errorf("%w: folder must name 1-%d folders, e.g. /Finance/2024", ErrInvalidRule, maxFolderDepth)
+	}
+	for _, name := range parts {
+		if len(name) > maxFolderNameLen {
+			return "", fmt.Errorf("%w: folder names must be at most %d characters", ErrInvalidRule, maxFolderNameLen)
+		}
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+func folderNames(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// EvaluateRules runs the enabled rules, in order, against a file's facts.
+// The first matching rule that names a folder decides the folder; the tags
+// of every matching rule are added.
+func EvaluateRules(rules []models.FolderRule, facts models.RuleFacts) models.RuleOutcome {
+	outcome := models.RuleOutcome{Facts: facts, Matched: []models.RuleMatch{}, Tags: []string{}}
+	for _, rule := range rules {
+		if !rule.Enabled || !ruleMatches(rule, facts) {
+			continue
+		}
+		outcome.Matched = append(outcome.Matched, models.RuleMatch{RuleID: rule.ID.Hex(), Name: rule.Name})
+		if outcome.Folder == "" {
+			outcome.Folder = rule.Actions.Folder
+		}
+		for _, tag := range rule.Actions.Tags {
+			if !containsString(outcome.Tags, tag) {
+				outcome.Tags = append(outcome.Tags, tag)
+			}
+		}
+	}
+	return outcome
+}
+
+func ruleMatches(rule models.FolderRule, facts models.RuleFacts) bool {
+	all := rule.Match == models.RuleMatchAll
+	for _, cond := range rule.Conditions {
+		if conditionMatches(cond, facts) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+func conditionMatches(cond models.RuleCondition, facts models.RuleFacts) bool {
+	var field string
+	switch cond.Field {
+	case models.RuleFieldFilename:
+		field = facts.Filename
+	case models.RuleFieldDocumentType:
+		field = facts.DocumentType
+	case models.RuleFieldMimeType:
+		field = facts.MimeType
+	}
+	field, value := strings.ToLower(field), strings.ToLower(cond.Value)
+	switch cond.Operator {
+	case models.RuleOpContains:
+		return strings.Contains(field, value)
+	case models.RuleOpEquals:
+		return field == value
+	case models.RuleOpStartsWith:
+		return strings.HasPrefix(field, value)
+	case models.RuleOpEndsWith:
+		return strings.HasSuffix(field, value)
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// needsDocumentType reports whether any enabled rule tests the document
+// type, which means reading the file
+func needsDocumentType(rules []models.FolderRule) bool {
+	for _, rule := range rules {
+		for _, cond := range rule.Conditions {
+			if rule.Enabled && cond.Field == models.RuleFieldDocumentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Facts returns the fields of a stored document that rules test. The
+// document type is only read when one of rules needs it; data is the
+// file's contents when already at hand, else nil.
+func (s *FolderRuleService) Facts(ctx context.Context, doc *models.Document, rules []models.FolderRule, data []byte) (models.RuleFacts, []byte, error) {
+	facts := models.RuleFacts{Filename: doc.OriginalName, MimeType: doc.MimeType}
+	if doc.MimeType != "application/pdf" || !needsDocumentType(rules) {
+		return facts, data, nil
+	}
+	if data == nil {
+		var err error
+		if data, err = s.storage.ReadDocument(ctx, doc); err != nil {
+			return facts, nil, err
+		}
+	}
+	docType, err := s.pdfService.DocumentType(ctx, bytes.NewReader(data))
+	if err != nil {
+		return facts, data, fmt.Errorf("failed to read document type: %w", err)
+	}
+	facts.DocumentType = docType
+	return facts, data, nil
+}
+
+// AfterUpload implements UploadHook by filing new library files in the
+// background
+func (s *FolderRuleService) AfterUpload(doc models.Document) {
+	if doc.IsTemporary || doc.OwnerUID == "" {
+		return
+	}
+	go s.file(doc)
+}
+
+// file runs the owner's rules on one stored document and applies the
+// outcome
+func (s *FolderRuleService) file(doc models.Document) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	rules, err := s.List(ctx, doc.OwnerUID)
+	if err != nil {
+		log.Printf("[FolderRules] Failed to load rules for %s: %v", doc.OwnerUID, err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+	facts, data, err := s.Facts(ctx, &doc, rules, nil)
+	if err != nil {
+		// Rules on the file name still apply
+		log.Printf("[FolderRules] %s: %v", doc.ID.Hex(), err)
+	}
+	outcome := EvaluateRules(rules, facts)
+	if len(outcome.Matched) == 0 {
+		return
+	}
+
+	filed := models.AutoFiling{Rules: outcome.Matched, Folder: outcome.Folder, Tags: outcome.Tags, FiledAt: time.Now()}
+	set := bson.M{"updatedAt": filed.FiledAt}
+	if outcome.Folder != "" {
+		folder, err := s.ensureFolder(ctx, doc.OwnerUID, outcome.Folder)
+		if err != nil {
+			log.Printf("[FolderRules] Failed to create folder %s for %s: %v", outcome.Folder, doc.OwnerUID, err)
+			return
+		}
+		filed.FolderID = folder.ID
+		set["folderId"] = folder.ID
+	}
+	set["autoFiled"] = filed
+	update := bson.M{"$set": set}
+	if len(outcome.Tags) > 0 {
+		update["$addToSet"] = bson.M{"metadata.tags": bson.M{"$each": outcome.Tags}}
+		// Confidential documents are recognized by content
+		if containsString(outcome.Tags, models.TagConfidential) && doc.ContentHash == "" {
+			if data == nil {
+				data, err = s.storage.ReadDocument(ctx, &doc)
+			}
+			if err == nil {
+				set["contentHash"] = ContentHash(data)
+			}
+		}
+	}
+	if _, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{"_id": doc.ID}, update); err != nil {
+		log.Printf("[FolderRules] Failed to file %s: %v", doc.ID.Hex(), err)
+	}
+}
+
+// ensureFolder returns the folder at path in userID's library, creating
+// any folders missing along it
+func (s *FolderRuleService) ensureFolder(ctx context.Context, userID, path string) (*models.Folder, error) {
+	var folder *models.Folder
+	for _, name := range folderNames(path) {
+		var parentID *primitive.ObjectID
+		if folder != nil {
+			parentID = &folder.ID
+		}
+		filter := bson.M{"ownerUid": userID, "parentId": parentID, "name": name}
+		update := bson.M{"$setOnInsert": bson.M{"createdAt": time.Now()}}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var next models.Folder
+		err := s.mongoClient.Folders().FindOneAndUpdate(ctx, filter, update, opts).Decode(&next)
+		if mongo.IsDuplicateKeyError(err) {
+			// Created meanwhile by another upload
+			err = s.mongoClient.Folders().FindOne(ctx, filter).Decode(&next)
+		}
+		if err != nil {
+			return nil, err
+		}
+		folder = &next
+	}
+	return folder, nil
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Handout layout, in points
+const (
+	handoutMargin      = 36
+	handoutRowGap      = 14
+	handoutSlideShare  = 0.5 // of the page width inside the margins
+	handoutNotesGap    = 18
+	handoutLineSpacing = 22
+)
+
+// HandoutSlidesPerPage lists the layouts a handout can have
+var HandoutSlidesPerPage = []int{2, 4, 6}
+
+// ErrInvalidHandout wraps handout option validation failures
+var ErrInvalidHandout = errors.New("invalid handout options")
+
+// HandoutOptions turn a presentation converted to PDF into a handout:
+// SlidesPerPage slides down the left of each page, each with ruled lines
+// for notes beside it
+type HandoutOptions struct {
+	SlidesPerPage int    `json:"slidesPerPage" bson:"slidesPerPage"`
+	PageSize      string `json:"pageSize" bson:"pageSize"` // "A4" or "Letter"
+}
+
+// NewHandoutOptions validates handout options and fills in the defaults:
+// 4 slides per page on A4
+func NewHandoutOptions(slidesPerPage int, pageSize string) (*HandoutOptions, error) {
+	if slidesPerPage == 0 {
+		slidesPerPage = 4
+	}
+	valid := false
+	for _, n := range HandoutSlidesPerPage {
+		valid = valid || n == slidesPerPage
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: slidesPerPage must be 2, 4 or 6", ErrInvalidHandout)
+	}
+
+	size := strings.ToLower(strings.TrimSpace(pageSize))
+	if size == "" {
+		size = "a4"
+	}
+	name, ok := scanPageSizes[size]
+	if !ok {
+		return nil, fmt.Errorf("%w: pageSize must be \"A4\" or \"Letter\"", ErrInvalidHandout)
+	}
+	return &HandoutOptions{SlidesPerPage: slidesPerPage, PageSize: name}, nil
+}
+
+// IsPresentationFile reports whether name is a ppt or pptx presentation
+func IsPresentationFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".ppt" || ext == ".pptx"
+}
+
+// MakeHandout imposes the pages of a converted presentation onto handout
+// pages with pdfcpu's n-up tiling, drawing a frame round each slide and
+// note lines beside it. Slides keep their vector content; links and
+// bookmarks, which point at the original pages, are dropped.
+func MakeHandout(data []byte, opts HandoutOptions) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	pdfCtx, err := api.ReadContext(bytes.NewReader(data), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, fmt.Errorf("failed to validate pdf: %w", err)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+	slides := pdfCtx.PageCount
+	if slides == 0 {
+		return nil, errors.New("presentation has no slides")
+	}
+
+	dim := types.PaperSize[opts.PageSize]
+	pagesDict := types.Dict{
+		"Type":     types.Name("Pages"),
+		"Count":    types.Integer(0),
+		"MediaBox": types.RectForDim(dim.Width, dim.Height).Array(),
+	}
+	pagesIndRef, err := pdfCtx.IndRefForNewObject(pagesDict)
+	if err != nil {
+		return nil, err
+	}
+
+	nup := model.DefaultNUpConfig()
+	nup.PageDim = dim
+	nup.Border = false // a slide without content would get no frame
+	nup.Margin = 0
+
+	dims, err := pdfCtx.PageDims()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read slide sizes: %w", err)
+	}
+	n := opts.SlidesPerPage
+	for first := 1; first <= slides; first += n {
+		var content bytes.Buffer
+		forms := types.NewDict()
+		for i := 0; i < n && first+i <= slides; i++ {
+			slide, lines := handoutCell(dim, n, i, dims[first+i-1])
+			if err := pdfCtx.NUpTilePDFBytesForPDF(first+i, forms, &content, slide, nup, false); err != nil {
+				return nil, fmt.Errorf("failed to place slide %d: %w", first+i, err)
+			}
+			fmt.Fprintf(&content, "q 0.5 w 0.4 G %.2f %.2f %.2f %.2f re S 0.7 G ", slide.LL.X, slide.LL.Y, slide.Width(), slide.Height())
+			for _, y := range lines.ys {
+				fmt.Fprintf(&content, "%.2f %.2f m %.2f %.2f l S ", lines.x0, y, lines.x1, y)
+			}
+			content.WriteString("Q ")
+		}
+		if err := addHandoutPage(pdfCtx, forms, content.Bytes(), dim, pagesDict, pagesIndRef); err != nil {
+			return nil, err
+		}
+	}
+
+	rootDict, err := pdfCtx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	rootDict.Update("Pages", *pagesIndRef)
+	for _, key := range []string{"Outlines", "PageLabels", "PageMode", "StructTreeRoot", "MarkInfo", "OpenAction", "Dests"} {
+		rootDict.Delete(key)
+	}
+	pdfCtx.PageCount = (slides + n - 1) / n
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// handoutLines are the note lines beside a slide
+type handoutLines struct {
+	x0, x1 float64
+	ys     []float64
+}
+
+// handoutCell lays out the i-th of n rows of a handout page: the slide,
+// fitted to the left of the row with its own aspect ratio so the tiling
+// neither rotates nor letterboxes it, and the note lines to its right
+func handoutCell(page *types.Dim, n, i int, slide types.Dim) (*types.Rectangle, handoutLines) {
+	contentWidth := page.Width - 2*handoutMargin
+	rowHeight := (page.Height - 2*handoutMargin) / float64(n)
+	top := page.Height - handoutMargin - float64(i)*rowHeight
+	boxWidth, boxHeight := contentWidth*handoutSlideShare, rowHeight-handoutRowGap
+
+	width, height := boxWidth, boxWidth*slide.Height/slide.Width
+	if height > boxHeight {
+		width, height = boxHeight*slide.Width/slide.Height, boxHeight
+	}
+	rect := types.NewRectangle(handoutMargin, top-height, handoutMargin+width, top)
+
+	lines := handoutLines{x0: rect.UR.X + handoutNotesGap, x1: page.Width - handoutMargin}
+	for y := top - handoutLineSpacing; y >= top-boxHeight; y -= handoutLineSpacing {
+		lines.ys = append(lines.ys, y)
+	}
+	return rect, lines
+}
+
+// addHandoutPage appends a page drawing content with forms to the page
+// tree, as pdfcpu's n-up does
+func addHandoutPage(pdfCtx *model.Context, forms types.Dict, content []byte, dim *types.Dim, pagesDict types.Dict, pagesIndRef *types.IndirectRef) error {
+	resIndRef, err := pdfCtx.IndRefForNewObject(types.Dict{"XObject": forms})
+	if err != nil {
+		return err
+	}
+	sd, err := pdfCtx.NewStreamDictForBuf(content)
+	if err != nil {
+		return err
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	contentsIndRef, err := pdfCtx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	pageIndRef, err := pdfCtx.IndRefForNewObject(types.Dict{
+		"Type":      types.Name("Page"),
+		"Parent":    *pagesIndRef,
+		"MediaBox":  types.RectForDim(dim.Width, dim.Height).Array(),
+		"Resources": *resIndRef,
+		"Contents":  *contentsIndRef,
+	})
+	if err != nil {
+		return err
+	}
+	return model.AppendPageTree(pageIndRef, 1, pagesDict)
+}
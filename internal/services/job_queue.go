@@ -0,0 +1,545 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/pkg/mongodb"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Queue topics
+const (
+	QueueTopicConversion = "conversion"
+	QueueTopicSummary    = "summary"
+	QueueTopicPDF        = "pdf"
+)
+
+// Queue lanes, from first served to last. Each plan's lane is set in
+// config.Plans, or overridden in the plan limits file.
+const (
+	QueueLaneExpress  = "express"
+	QueueLanePriority = "priority"
+	QueueLaneStandard = "standard"
+)
+
+// PaidQueueLanes are the lanes reserved workers take jobs from
+var PaidQueueLanes = []string{QueueLaneExpress, QueueLanePriority}
+
+// queueLanePriority ranks a lane; messages in higher ranked lanes are
+// served first
+func queueLanePriority(lane string) int {
+	switch lane {
+	case QueueLaneExpress:
+		return 2
+	case QueueLanePriority:
+		return 1
+	}
+	return 0
+}
+
+// QueueLaneForPlan returns the lane jobs of a plan are queued in
+func QueueLaneForPlan(plan string) string {
+	lane := config.GetPlanLimits(plan).QueueLane
+	if queueLanePriority(lane) == 0 {
+		return QueueLaneStandard
+	}
+	return lane
+}
+
+// defaultQueueMaxWait is how long a message may wait before it is served
+// ahead of every lane
+const defaultQueueMaxWait = 5 * time.Minute
+
+// queuedBefore reports whether message a is served before b. Messages
+// waiting since aged or earlier go first, oldest first, so standard jobs
+// still complete while paid lanes are busy; the rest go by lane, then age.
+func queuedBefore(aLane string, aCreated time.Time, bLane string, bCreated time.Time, aged time.Time) bool {
+	aAged, bAged := !aCreated.After(aged), !bCreated.After(aged)
+	if aAged != bAged {
+		return aAged
+	}
+	if !aAged {
+		if pa, pb := queueLanePriority(aLane), queueLanePriority(bLane); pa != pb {
+			return pa > pb
+		}
+	}
+	return aCreated.Before(bCreated)
+}
+
+// ErrQueueClosed is returned by Dequeue, and by the memory queue's Enqueue
+// and Nack, after the queue is closed
+var ErrQueueClosed = errors.New("queue closed")
+
+// QueueMessage is one delivery of a job reference. Receipt identifies this
+// particular delivery; Ack/Extend with a stale receipt are no-ops.
+type QueueMessage struct {
+	ID       string
+	Topic    string
+	Lane     string
+	JobID    string
+	Attempts int
+	Receipt  string
+}
+
+// JobQueue is an at-least-once work queue. A dequeued message stays
+// invisible to other consumers for the visibility timeout; if it is not
+// acked (or extended) in time it is delivered again. Messages are served
+// by lane, then oldest first, except that a message waiting longer than
+// the queue's max wait goes ahead of every lane.
+type JobQueue interface {
+	Enqueue(ctx context.Context, topic, lane, jobID string) error
+	// Dequeue blocks until a message is available in one of lanes (any
+	// lane when none are given) or ctx is done
+	Dequeue(ctx context.Context, topic string, lanes ...string) (*QueueMessage, error)
+	Ack(ctx context.Context, msg *QueueMessage) error
+	// Nack makes the message visible again immediately
+	Nack(ctx context.Context, msg *QueueMessage) error
+	// Extend pushes the visibility deadline of an in-flight message
+	Extend(ctx context.Context, msg *QueueMessage, d time.Duration) error
+	// Depth returns the number of messages waiting (not in flight)
+	Depth(ctx context.Context, topic string) (int64, error)
+	// Position returns where jobID's message stands among the messages
+	// waiting on topic, from 1 for the next served, or 0 when it is not
+	// waiting (in flight or gone). Reserved workers are not accounted for.
+	Position(ctx context.Context, topic, jobID string) (int64, error)
+	Close() error
+}
+
+// NewJobQueue builds the configured backend: "memory" (single process,
+// for development), or "mongo" or "redis" (shared by every API and worker
+// instance). Messages waiting longer than maxWait are served ahead of
+// every lane.
+func NewJobQueue(backend string, mongoClient *mongodb.Client, redisURL string, visibility, maxWait time.Duration) (JobQueue, error) {
+	if visibility <= 0 {
+		visibility = 10 * time.Minute
+	}
+	if maxWait <= 0 {
+		maxWait = defaultQueueMaxWait
+	}
+	switch backend {
+	case "", "memory":
+		q := NewMemoryJobQueue(visibility)
+		q.maxWait = maxWait
+		return q, nil
+	case "mongo":
+		if mongoClient == nil {
+			return nil, fmt.Errorf("mongo queue backend requires a MongoDB connection")
+		}
+		q := NewMongoJobQueue(mongoClient, visibility)
+		q.maxWait = maxWait
+		return q, nil
+	case "redis":
+		if redisURL == "" {
+			return nil, fmt.Errorf("redis queue backend requires REDIS_URL")
+		}
+		q, err := NewRedisJobQueue(redisURL, visibility)
+		if err != nil {
+			return nil, err
+		}
+		q.maxWait = maxWait
+		return q, nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q (supported: memory, mongo, redis)", backend)
+	}
+}
+
+// MemoryJobQueue is an in-process JobQueue
+type MemoryJobQueue struct {
+	mu         sync.Mutex
+	visibility time.Duration
+	maxWait    time.Duration
+	messages   map[string][]*memoryMessage
+	notify     chan struct{}
+	closed     bool
+	closeOnce  sync.Once
+}
+
+type memoryMessage struct {
+	QueueMessage
+	visibleAt time.Time
+	createdAt time.Time
+}
+
+// NewMemoryJobQueue creates an in-process queue
+func NewMemoryJobQueue(visibility time.Duration) *MemoryJobQueue {
+	return &MemoryJobQueue{
+		visibility: visibility,
+		maxWait:    defaultQueueMaxWait,
+		messages:   make(map[string][]*memoryMessage),
+		notify:     make(chan struct{}),
+	}
+}
+
+// wake signals every waiting Dequeue, as each may serve different lanes.
+// Callers hold q.mu.
+func (q *MemoryJobQueue) wake() {
+	if q.closed {
+		return
+	}
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// Enqueue adds a job reference to the topic
+func (q *MemoryJobQueue) Enqueue(ctx context.Context, topic, lane, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	now := time.Now()
+	q.messages[topic] = append(q.messages[topic], &memoryMessage{
+		QueueMessage: QueueMessage{ID: uuid.New().String(), Topic: topic, Lane: lane, JobID: jobID},
+		visibleAt:    now,
+		createdAt:    now,
+	})
+	q.wake()
+	return nil
+}
+
+// Dequeue returns the first visible message in lanes, waiting if there is
+// none
+func (q *MemoryJobQueue) Dequeue(ctx context.Context, topic string, lanes ...string) (*QueueMessage, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrQueueClosed
+		}
+
+		now := time.Now()
+		aged := now.Add(-q.maxWait)
+		var next time.Time
+		var first *memoryMessage
+		for _, m := range q.messages[topic] {
+			if len(lanes) > 0 && !containsString(lanes, m.Lane) {
+				continue
+			}
+			if m.visibleAt.After(now) {
+				if next.IsZero() || m.visibleAt.Before(next) {
+					next = m.visibleAt
+				}
+				continue
+			}
+			if first == nil || queuedBefore(m.Lane, m.createdAt, first.Lane, first.createdAt, aged) {
+				first = m
+			}
+		}
+		if first != nil {
+			first.Attempts++
+			first.Receipt = uuid.New().String()
+			first.visibleAt = now.Add(q.visibility)
+			msg := first.QueueMessage
+			q.mu.Unlock()
+			return &msg, nil
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		// Sleep until something is enqueued or an in-flight message expires
+		wait := time.Minute
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (q *MemoryJobQueue) find(msg *QueueMessage) (int, *memoryMessage) {
+	for i, m := range q.messages[msg.Topic] {
+		if m.ID == msg.ID && m.Receipt == msg.Receipt {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+// Ack removes a delivered message
+func (q *MemoryJobQueue) Ack(ctx context.Context, msg *QueueMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, _ := q.find(msg); i >= 0 {
+		list := q.messages[msg.Topic]
+		q.messages[msg.Topic] = append(list[:i], list[i+1:]...)
+	}
+	return nil
+}
+
+// Nack makes a delivered message visible again
+func (q *MemoryJobQueue) Nack(ctx context.Context, msg *QueueMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if _, m := q.find(msg); m != nil {
+		m.visibleAt = time.Now()
+		q.wake()
+	}
+	return nil
+}
+
+// Extend pushes back the visibility deadline of an in-flight message
+func (q *MemoryJobQueue) Extend(ctx context.Context, msg *QueueMessage, d time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, m := q.find(msg); m != nil {
+		m.visibleAt = time.Now().Add(d)
+	}
+	return nil
+}
+
+// Depth counts visible messages on the topic
+func (q *MemoryJobQueue) Depth(ctx context.Context, topic string) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n int64
+	now := time.Now()
+	for _, m := range q.messages[topic] {
+		if !m.visibleAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Position ranks jobID's message among the visible messages on the topic
+func (q *MemoryJobQueue) Position(ctx context.Context, topic, jobID string) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var mine *memoryMessage
+	for _, m := range q.messages[topic] {
+		if m.JobID == jobID {
+			mine = m
+			break
+		}
+	}
+	if mine == nil || mine.visibleAt.After(now) {
+		return 0, nil
+	}
+	aged := now.Add(-q.maxWait)
+	position := int64(1)
+	for _, m := range q.messages[topic] {
+		if m != mine && !m.visibleAt.After(now) && queuedBefore(m.Lane, m.createdAt, mine.Lane, mine.createdAt, aged) {
+			position++
+		}
+	}
+	return position, nil
+}
+
+// Close stops all pending Dequeue calls. Closing again is a no-op.
+func (q *MemoryJobQueue) Close() error {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.closed = true
+		close(q.notify)
+	})
+	return nil
+}
+
+// MongoJobQueue stores messages in the job_queue collection. Dequeue claims
+// a message atomically with findOneAndUpdate, so any number of API and
+// worker instances can consume the same topic.
+type MongoJobQueue struct {
+	mongoClient  *mongodb.Client
+	visibility   time.Duration
+	maxWait      time.Duration
+	pollInterval time.Duration
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+type mongoQueueDoc struct {
+	ID        string    `bson:"_id"`
+	Topic     string    `bson:"topic"`
+	Lane      string    `bson:"lane,omitempty"`
+	Priority  int       `bson:"priority"` // queueLanePriority of Lane, to sort on
+	JobID     string    `bson:"jobId"`
+	Attempts  int       `bson:"attempts"`
+	Receipt   string    `bson:"receipt,omitempty"`
+	VisibleAt time.Time `bson:"visibleAt"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// NewMongoJobQueue creates a Mongo-backed queue
+func NewMongoJobQueue(mongoClient *mongodb.Client, visibility time.Duration) *MongoJobQueue {
+	q := &MongoJobQueue{
+		mongoClient:  mongoClient,
+		visibility:   visibility,
+		maxWait:      defaultQueueMaxWait,
+		pollInterval: time.Second,
+		done:         make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	q.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "topic", Value: 1}, {Key: "visibleAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "topic", Value: 1}, {Key: "priority", Value: -1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "topic", Value: 1}, {Key: "jobId", Value: 1}}},
+	})
+
+	return q
+}
+
+func (q *MongoJobQueue) collection() *mongo.Collection {
+	return q.mongoClient.Collection("job_queue")
+}
+
+// Enqueue inserts a visible message
+func (q *MongoJobQueue) Enqueue(ctx context.Context, topic, lane, jobID string) error {
+	now := time.Now()
+	_, err := q.collection().InsertOne(ctx, mongoQueueDoc{
+		ID:        uuid.New().String(),
+		Topic:     topic,
+		Lane:      lane,
+		Priority:  queueLanePriority(lane),
+		JobID:     jobID,
+		VisibleAt: now,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// Dequeue claims the first visible message in lanes, polling while there
+// is none. A message past the max wait is claimed oldest first; otherwise
+// the highest lane is.
+func (q *MongoJobQueue) Dequeue(ctx context.Context, topic string, lanes ...string) (*QueueMessage, error) {
+	for {
+		now := time.Now()
+		filter := bson.M{"topic": topic, "visibleAt": bson.M{"$lte": now}}
+		if len(lanes) > 0 {
+			filter["lane"] = bson.M{"$in": lanes}
+		}
+		update := bson.M{
+			"$set": bson.M{"visibleAt": now.Add(q.visibility), "receipt": uuid.New().String()},
+			"$inc": bson.M{"attempts": 1},
+		}
+
+		aged := bson.M{"createdAt": bson.M{"$lte": now.Add(-q.maxWait)}}
+		for k, v := range filter {
+			aged[k] = v
+		}
+		doc, err := q.claim(ctx, aged, update, bson.D{{Key: "createdAt", Value: 1}})
+		if err == mongo.ErrNoDocuments {
+			doc, err = q.claim(ctx, filter, update, bson.D{{Key: "priority", Value: -1}, {Key: "createdAt", Value: 1}})
+		}
+		if err == nil {
+			return &QueueMessage{ID: doc.ID, Topic: doc.Topic, Lane: doc.Lane, JobID: doc.JobID, Attempts: doc.Attempts, Receipt: doc.Receipt}, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to dequeue: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.done:
+			return nil, ErrQueueClosed
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+// claim atomically takes the first message matching filter in sort order
+func (q *MongoJobQueue) claim(ctx context.Context, filter, update bson.M, sort bson.D) (*mongoQueueDoc, error) {
+	opts := options.FindOneAndUpdate().SetSort(sort).SetReturnDocument(options.After)
+	var doc mongoQueueDoc
+	if err := q.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Ack deletes the message if this delivery still owns it
+func (q *MongoJobQueue) Ack(ctx context.Context, msg *QueueMessage) error {
+	_, err := q.collection().DeleteOne(ctx, bson.M{"_id": msg.ID, "receipt": msg.Receipt})
+	return err
+}
+
+// Nack makes the message visible again
+func (q *MongoJobQueue) Nack(ctx context.Context, msg *QueueMessage) error {
+	_, err := q.collection().UpdateOne(ctx,
+		bson.M{"_id": msg.ID, "receipt": msg.Receipt},
+		bson.M{"$set": bson.M{"visibleAt": time.Now()}})
+	return err
+}
+
+// Extend pushes back the visibility deadline
+func (q *MongoJobQueue) Extend(ctx context.Context, msg *QueueMessage, d time.Duration) error {
+	_, err := q.collection().UpdateOne(ctx,
+		bson.M{"_id": msg.ID, "receipt": msg.Receipt},
+		bson.M{"$set": bson.M{"visibleAt": time.Now().Add(d)}})
+	return err
+}
+
+// Depth counts visible messages on the topic
+func (q *MongoJobQueue) Depth(ctx context.Context, topic string) (int64, error) {
+	return q.collection().CountDocuments(ctx, bson.M{"topic": topic, "visibleAt": bson.M{"$lte": time.Now()}})
+}
+
+// Position counts the visible messages served before jobID's, in the order
+// Dequeue claims them
+func (q *MongoJobQueue) Position(ctx context.Context, topic, jobID string) (int64, error) {
+	now := time.Now()
+	var mine mongoQueueDoc
+	err := q.collection().FindOne(ctx, bson.M{"topic": topic, "jobId": jobID}).Decode(&mine)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find queued job %s: %w", jobID, err)
+	}
+	if mine.VisibleAt.After(now) {
+		return 0, nil
+	}
+
+	aged := now.Add(-q.maxWait)
+	ahead := bson.A{bson.M{"createdAt": bson.M{"$lt": mine.CreatedAt, "$lte": aged}}}
+	if mine.CreatedAt.After(aged) {
+		ahead = append(ahead,
+			bson.M{"priority": bson.M{"$gt": mine.Priority}},
+			bson.M{"priority": mine.Priority, "createdAt": bson.M{"$lt": mine.CreatedAt}},
+		)
+	}
+	n, err := q.collection().CountDocuments(ctx, bson.M{
+		"topic":     topic,
+		"_id":       bson.M{"$ne": mine.ID},
+		"visibleAt": bson.M{"$lte": now},
+		"$or":       ahead,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to rank queued job %s: %w", jobID, err)
+	}
+	return n + 1, nil
+}
+
+// Close stops pending Dequeue calls; messages stay in Mongo
+func (q *MongoJobQueue) Close() error {
+	q.closeOnce.Do(func() { close(q.done) })
+	return nil
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisQueueGroup is the consumer group every instance reads through
+const redisQueueGroup = "workers"
+
+// redisQueueLanes are the lanes Dequeue looks at when given none
+var redisQueueLanes = []string{QueueLaneExpress, QueueLanePriority, QueueLaneStandard}
+
+// The scripts below act on a delivery only while its receipt, the consumer
+// it was claimed by, still holds it. KEYS[1] is the stream; ARGV is the
+// group, the entry ID and the receipt.
+var (
+	// redisQueueAck acknowledges and deletes the entry, and drops the
+	// one-off consumer
+	redisQueueAck = redis.NewScript(`
+local p = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1)
+if #p == 0 or p[1][2] ~= ARGV[3] then return 0 end
+redis.call('XACK', KEYS[1], ARGV[1], ARGV[2])
+redis.call('XDEL', KEYS[1], ARGV[2])
+redis.call('XGROUP', 'DELCONSUMER', KEYS[1], ARGV[1], ARGV[3])
+return 1`)
+
+	// redisQueueIdle sets how long the entry counts as idle, ARGV[4] in
+	// milliseconds; it is delivered again once that reaches the visibility
+	// timeout
+	redisQueueIdle = redis.NewScript(`
+local p = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1)
+if #p == 0 or p[1][2] ~= ARGV[3] then return 0 end
+redis.call('XCLAIM', KEYS[1], ARGV[1], ARGV[3], 0, ARGV[2], 'IDLE', ARGV[4], 'RETRYCOUNT', p[1][4], 'JUSTID')
+return 1`)
+
+	// redisQueueReclaim claims an entry that has been idle for at least
+	// ARGV[4] milliseconds for the consumer ARGV[3], dropping the consumer
+	// that held it, and returns its delivery count, or false when another
+	// instance got there first
+	redisQueueReclaim = redis.NewScript(`
+local p = redis.call('XPENDING', KEYS[1], ARGV[1], 'IDLE', ARGV[4], ARGV[2], ARGV[2], 1)
+if #p == 0 then return false end
+local claimed = redis.call('XCLAIM', KEYS[1], ARGV[1], ARGV[3], ARGV[4], ARGV[2], 'RETRYCOUNT', p[1][4] + 1, 'JUSTID')
+redis.call('XGROUP', 'DELCONSUMER', KEYS[1], ARGV[1], p[1][2])
+if #claimed == 0 then return false end
+return p[1][4] + 1`)
+)
+
+// RedisJobQueue keeps each topic's lanes in Redis streams (Redis 6.2 or
+// later), read through one consumer group so any number of API and worker
+// instances can consume them. Every delivery is claimed by a consumer of
+// its own, whose name is the receipt; a delivery idle for the visibility
+// timeout is claimed again by the next Dequeue. Acked entries are deleted,
+// so a stream only holds waiting and in-flight messages.
+type RedisJobQueue struct {
+	client       *redis.Client
+	prefix       string
+	visibility   time.Duration
+	maxWait      time.Duration
+	pollInterval time.Duration
+	done         chan struct{}
+	closeOnce    sync.Once
+
+	mu     sync.Mutex
+	groups map[string]bool // streams known to have the consumer group
+}
+
+// redisCandidate is the next message a lane would serve
+type redisCandidate struct {
+	lane    string
+	id      string
+	created time.Time
+	pending bool // delivered before and idle past the visibility timeout
+}
+
+// NewRedisJobQueue connects to the Redis at url, e.g.
+// redis://:password@localhost:6379/0
+func NewRedisJobQueue(url string, visibility time.Duration) (*RedisJobQueue, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &RedisJobQueue{
+		client:       client,
+		prefix:       "jobqueue",
+		visibility:   visibility,
+		maxWait:      defaultQueueMaxWait,
+		pollInterval: time.Second,
+		done:         make(chan struct{}),
+		groups:       make(map[string]bool),
+	}, nil
+}
+
+// stream returns the stream of a topic's lane, creating it and its
+// consumer group on first use. Messages without a lane are standard.
+func (q *RedisJobQueue) stream(ctx context.Context, topic, lane string) (string, error) {
+	if lane == "" {
+		lane = QueueLaneStandard
+	}
+	key := q.prefix + ":" + topic + ":" + lane
+
+	q.mu.Lock()
+	known := q.groups[key]
+	q.mu.Unlock()
+	if known {
+		return key, nil
+	}
+
+	err := q.client.XGroupCreateMkStream(ctx, key, redisQueueGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return "", fmt.Errorf("failed to create consumer group on %s: %w", key, err)
+	}
+	q.mu.Lock()
+	q.groups[key] = true
+	q.mu.Unlock()
+	return key, nil
+}
+
+// Enqueue appends a message to the lane's stream
+func (q *RedisJobQueue) Enqueue(ctx context.Context, topic, lane, jobID string) error {
+	key, err := q.stream(ctx, topic, lane)
+	if err != nil {
+		return err
+	}
+	if err := q.client.XAdd(ctx, &redis.XAddArgs{Stream: key, Values: []interface{}{"jobId", jobID}}).Err(); err != nil {
+		return fmt.Errorf("failed to enqueue job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// Dequeue claims the first visible message in lanes, polling while there
+// is none. Each lane offers its oldest expired delivery or, failing that,
+// its oldest undelivered message; the one served first is claimed.
+func (q *RedisJobQueue) Dequeue(ctx context.Context, topic string, lanes ...string) (*QueueMessage, error) {
+	if len(lanes) == 0 {
+		lanes = redisQueueLanes
+	}
+	for {
+		select {
+		case <-q.done:
+			return nil, ErrQueueClosed
+		default:
+		}
+
+		aged := time.Now().Add(-q.maxWait)
+		var first *redisCandidate
+		for _, lane := range lanes {
+			c, err := q.candidate(ctx, topic, lane)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("failed to dequeue: %w", err)
+			}
+			if c != nil && (first == nil || queuedBefore(c.lane, c.created, first.lane, first.created, aged)) {
+				first = c
+			}
+		}
+
+		if first != nil {
+			msg, err := q.claim(ctx, topic, first)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("failed to dequeue: %w", err)
+			}
+			if msg != nil {
+				return msg, nil
+			}
+			// Another instance claimed it first
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.done:
+			return nil, ErrQueueClosed
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+// candidate returns the message lane would serve next, or nil
+func (q *RedisJobQueue) candidate(ctx context.Context, topic, lane string) (*redisCandidate, error) {
+	key, err := q.stream(ctx, topic, lane)
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: key, Group: redisQueueGroup, Idle: q.visibility, Start: "-", End: "+", Count: 1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 {
+		return &redisCandidate{lane: lane, id: expired[0].ID, created: streamIDTime(expired[0].ID), pending: true}, nil
+	}
+
+	groups, err := q.client.XInfoGroups(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	lastDelivered := "0-0"
+	for _, g := range groups {
+		if g.Name == redisQueueGroup {
+			lastDelivered = g.LastDeliveredID
+		}
+	}
+	next, err := q.client.XRangeN(ctx, key, "("+lastDelivered, "+", 1).Result()
+	if err != nil || len(next) == 0 {
+		return nil, err
+	}
+	return &redisCandidate{lane: lane, id: next[0].ID, created: streamIDTime(next[0].ID)}, nil
+}
+
+// claim takes c for a new consumer, returning nil when it was taken first
+func (q *RedisJobQueue) claim(ctx context.Context, topic string, c *redisCandidate) (*QueueMessage, error) {
+	key, err := q.stream(ctx, topic, c.lane)
+	if err != nil {
+		return nil, err
+	}
+	receipt := uuid.New().String()
+
+	if c.pending {
+		attempts, err := redisQueueReclaim.Run(ctx, q.client, []string{key},
+			redisQueueGroup, c.id, receipt, q.visibility.Milliseconds()).Int()
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobID, err := q.jobID(ctx, key, c.id)
+		if err != nil {
+			return nil, err
+		}
+		return &QueueMessage{ID: c.id, Topic: topic, Lane: c.lane, JobID: jobID, Attempts: attempts, Receipt: receipt}, nil
+	}
+
+	// The oldest undelivered message of the lane, which is c unless another
+	// instance took it
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: redisQueueGroup, Consumer: receipt, Streams: []string{key, ">"}, Count: 1, Block: -1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+	entry := streams[0].Messages[0]
+	jobID, _ := entry.Values["jobId"].(string)
+	return &QueueMessage{ID: entry.ID, Topic: topic, Lane: c.lane, JobID: jobID, Attempts: 1, Receipt: receipt}, nil
+}
+
+// jobID reads the job reference of a stream entry
+func (q *RedisJobQueue) jobID(ctx context.Context, key, id string) (string, error) {
+	entries, err := q.client.XRangeN(ctx, key, id, id, 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("queue entry %s is gone", id)
+	}
+	jobID, _ := entries[0].Values["jobId"].(string)
+	return jobID, nil
+}
+
+// Ack deletes the message if this delivery still owns it
+func (q *RedisJobQueue) Ack(ctx context.Context, msg *QueueMessage) error {
+	key, err := q.stream(ctx, msg.Topic, msg.Lane)
+	if err != nil {
+		return err
+	}
+	return redisQueueAck.Run(ctx, q.client, []string{key}, redisQueueGroup, msg.ID, msg.Receipt).Err()
+}
+
+// Nack makes the message visible again
+func (q *RedisJobQueue) Nack(ctx context.Context, msg *QueueMessage) error {
+	return q.setIdle(ctx, msg, q.visibility)
+}
+
+// Extend pushes back the visibility deadline. A delivery's deadline is
+// measured from when it was last claimed, so it is pushed back by at most
+// the visibility timeout.
+func (q *RedisJobQueue) Extend(ctx context.Context, msg *QueueMessage, d time.Duration) error {
+	idle := q.visibility - d
+	if idle < 0 {
+		idle = 0
+	}
+	return q.setIdle(ctx, msg, idle)
+}
+
+func (q *RedisJobQueue) setIdle(ctx context.Context, msg *QueueMessage, idle time.Duration) error {
+	key, err := q.stream(ctx, msg.Topic, msg.Lane)
+	if err != nil {
+		return err
+	}
+	return redisQueueIdle.Run(ctx, q.client, []string{key}, redisQueueGroup, msg.ID, msg.Receipt, idle.Milliseconds()).Err()
+}
+
+// Depth counts visible messages on the topic: undelivered ones and
+// deliveries past the visibility timeout
+func (q *RedisJobQueue) Depth(ctx context.Context, topic string) (int64, error) {
+	waiting, err := q.waiting(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(waiting)), nil
+}
+
+// Position ranks jobID's message among the visible messages on the topic,
+// in the order Dequeue serves them
+func (q *RedisJobQueue) Position(ctx context.Context, topic, jobID string) (int64, error) {
+	waiting, err := q.waiting(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	var mine *redisWaiting
+	for i := range waiting {
+		if waiting[i].jobID == jobID {
+			mine = &waiting[i]
+			break
+		}
+	}
+	if mine == nil {
+		return 0, nil
+	}
+
+	aged := time.Now().Add(-q.maxWait)
+	position := int64(1)
+	for i := range waiting {
+		m := &waiting[i]
+		if m != mine && queuedBefore(m.lane, m.created, mine.lane, mine.created, aged) {
+			position++
+		}
+	}
+	return position, nil
+}
+
+// redisWaiting is a visible message, as Depth and Position see it
+type redisWaiting struct {
+	lane    string
+	jobID   string
+	created time.Time
+}
+
+// waiting lists the visible messages in every lane of the topic
+func (q *RedisJobQueue) waiting(ctx context.Context, topic string) ([]redisWaiting, error) {
+	var waiting []redisWaiting
+	for _, lane := range redisQueueLanes {
+		key, err := q.stream(ctx, topic, lane)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := q.client.XRange(ctx, key, "-", "+").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		inFlight := make(map[string]bool)
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: key, Group: redisQueueGroup, Start: "-", End: "+", Count: int64(len(entries)),
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending messages of %s: %w", key, err)
+		}
+		for _, p := range pending {
+			if p.Idle < q.visibility {
+				inFlight[p.ID] = true
+			}
+		}
+
+		for _, e := range entries {
+			if inFlight[e.ID] {
+				continue
+			}
+			jobID, _ := e.Values["jobId"].(string)
+			waiting = append(waiting, redisWaiting{lane: lane, jobID: jobID, created: streamIDTime(e.ID)})
+		}
+	}
+	return waiting, nil
+}
+
+// Close stops pending Dequeue calls and disconnects; messages stay in
+// Redis
+func (q *RedisJobQueue) Close() error {
+	var err error
+	q.closeOnce.Do(func() {
+		close(q.done)
+		err = q.client.Close()
+	})
+	return err
+}
+
+// streamIDTime is when a stream entry was added, from the milliseconds
+// part of its ID
+func streamIDTime(id string) time.Time {
+	ms, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// queueWorkers is a pool of workers taking one topic's jobs from the queue.
+// Workers hold off while paused, the first reserved of them only serve
+// paid lanes, and a delivery stays invisible to other workers while it
+// runs. ConversionService, SummaryService and JobService each run one.
+type queueWorkers struct {
+	name  string // log prefix
+	queue JobQueue
+	topic string
+	size  int
+	wg    sync.WaitGroup
+
+	// paused, when set, reports whether workers should stop taking new jobs
+	paused func() bool
+	// reserved is how many workers only take jobs from paid lanes
+	reserved int
+}
+
+func newQueueWorkers(name string, queue JobQueue, topic string, size int) *queueWorkers {
+	return &queueWorkers{name: name, queue: queue, topic: topic, size: size}
+}
+
+// setReserved keeps n workers for paid lanes. At least one worker still
+// serves every lane, so standard jobs keep completing.
+func (w *queueWorkers) setReserved(n int) {
+	w.reserved = min(n, w.size-1)
+}
+
+// start runs the workers until ctx is done. process runs one delivery of a
+// job and reports whether it is finished with; a delivery it returns false
+// for, e.g. because it was interrupted by shutdown, is left for redelivery.
+func (w *queueWorkers) start(ctx context.Context, process func(jobID string, attempts int) bool) {
+	for i := 0; i < w.size; i++ {
+		w.wg.Add(1)
+		go w.run(ctx, i, process)
+	}
+}
+
+// wait blocks until the workers have stopped
+func (w *queueWorkers) wait() {
+	w.wg.Wait()
+}
+
+// run is one worker's loop
+func (w *queueWorkers) run(ctx context.Context, id int, process func(string, int) bool) {
+	defer w.wg.Done()
+
+	for {
+		if w.paused != nil && w.paused() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		var lanes []string
+		if id < w.reserved {
+			lanes = PaidQueueLanes
+		}
+		msg, err := w.queue.Dequeue(ctx, w.topic, lanes...)
+		if err != nil {
+			if ctx.Err() != nil || err == ErrQueueClosed {
+				return
+			}
+			fmt.Printf("[%s] Worker %d: dequeue failed: %v\n", w.name, id, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		w.handle(ctx, msg, process)
+	}
+}
+
+// handle runs one delivery, extending its visibility while the job runs
+// and acking it once process is finished with it
+func (w *queueWorkers) handle(ctx context.Context, msg *QueueMessage, process func(string, int) bool) {
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+				w.queue.Extend(heartbeatCtx, msg, 5*time.Minute)
+			}
+		}
+	}()
+
+	if !process(msg.JobID, msg.Attempts) {
+		return
+	}
+
+	ackCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := w.queue.Ack(ackCtx, msg); err != nil {
+		fmt.Printf("[%s] Job %s: ack failed: %v\n", w.name, msg.JobID, err)
+	}
+}
+
+// PDFJobInput is a file uploaded with a background PDF operation
+type PDFJobInput struct {
+	Field    string `bson:"field"` // form field it was uploaded as, e.g. "files"
+	Filename string `bson:"filename"`
+	Size     int64  `bson:"size"`
+	Path     string `bson:"path"`          // copy on the submitting instance
+	Key      string `bson:"key,omitempty"` // staged copy in the temp bucket
+}
+
+// PDFJob is an /api/pdf operation run in the background. Result is the
+// data the synchronous endpoint would have returned, and FileIDs the
+// stored files it produced.
+type PDFJob struct {
+	ID          string                    `json:"id" bson:"_id"`
+	UserID      string                    `json:"-" bson:"userId,omitempty"`
+	Operation   string                    `json:"operation" bson:"operation"`
+	Status      JobStatus                 `json:"status" bson:"status"`
+	Lane        string                    `json:"lane,omitempty" bson:"lane,omitempty"`
+	Fields      map[string][]string       `json:"-" bson:"fields,omitempty"`
+	Query       string                    `json:"-" bson:"query,omitempty"`
+	Inputs      []PDFJobInput             `json:"-" bson:"inputs,omitempty"`
+	Progress    *models.OperationProgress `json:"progress,omitempty" bson:"-"`
+	Result      json.RawMessage           `json:"result,omitempty" bson:"result,omitempty"`
+	FileIDs     []string                  `json:"fileIds,omitempty" bson:"fileIds,omitempty"`
+	Error       string                    `json:"error,omitempty" bson:"error,omitempty"`
+	Instance    string                    `json:"-" bson:"instance,omitempty"`
+	Attempts    int                       `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	CreatedAt   time.Time                 `json:"createdAt" bson:"createdAt"`
+	CompletedAt time.Time                 `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+}
+
+// PDFJobRunner runs a job's operation with its uploads at the local paths
+// given, in the order of job.Inputs, and returns the operation's result
+type PDFJobRunner func(ctx context.Context, job *PDFJob, inputs []string) (json.RawMessage, error)
+
+// pdfJobsCollection holds job state shared by all instances
+const pdfJobsCollection = "pdf_jobs"
+
+// pdfJobMaxAttempts bounds redeliveries of a job whose worker keeps dying
+const pdfJobMaxAttempts = 3
+
+// ErrJobNotFound means no job has the ID asked for
+var ErrJobNotFound = errors.New("job not found")
+
+// JobService runs /api/pdf operations as background jobs, for inputs too
+// large to process within a request. Uploads are staged like conversion
+// inputs and the operation itself is run by the PDFJobRunner the handler
+// passes to Start, so a job does exactly what the synchronous endpoint
+// would. Progress is reported through ProgressService under the job ID.
+type JobService struct {
+	jobs       sync.Map
+	queue      JobQueue
+	workers    *queueWorkers
+	workerPool int
+	tempDir    string
+	run        PDFJobRunner
+	progress   *ProgressService
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// Shared state, as for ConversionService
+	mongoClient *mongodb.Client
+	minioClient *minioPkg.Client
+	instanceID  string
+}
+
+// NewJobService creates a job service. mongoClient, minioClient and queue
+// follow the same rules as NewConversionService; workerCount may be 0 for
+// instances that only submit jobs. Workers start with Start.
+func NewJobService(workerCount int, mongoClient *mongodb.Client, minioClient *minioPkg.Client, queue JobQueue, progress *ProgressService) (*JobService, error) {
+	tempDir := filepath.Join(os.TempDir(), "brainy-pdf-jobs")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	if queue == nil {
+		queue = NewMemoryJobQueue(10 * time.Minute)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &JobService{
+		queue:      queue,
+		workers:    newQueueWorkers("Jobs", queue, QueueTopicPDF, workerCount),
+		workerPool: workerCount,
+		tempDir:    tempDir,
+		progress:   progress,
+		ctx:        ctx,
+		cancel:     cancel,
+
+		mongoClient: mongoClient,
+		minioClient: minioClient,
+	}
+	host, _ := os.Hostname()
+	s.instanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	return s, nil
+}
+
+// Start starts the workers, which run jobs with run
+func (s *JobService) Start(run PDFJobRunner) {
+	s.run = run
+	s.workers.start(s.ctx, s.processJob)
+	fmt.Printf("[Jobs] Started %d workers\n", s.workerPool)
+}
+
+// Close stops the workers. Unacked jobs are redelivered by the queue.
+func (s *JobService) Close() {
+	s.cancel()
+	s.workers.wait()
+}
+
+// SetPauseCheck makes workers hold off dequeuing while fn returns true
+func (s *JobService) SetPauseCheck(fn func() bool) {
+	s.workers.paused = fn
+}
+
+// SetReservedWorkers keeps n workers for jobs in paid lanes, as for
+// ConversionService
+func (s *JobService) SetReservedWorkers(n int) {
+	s.workers.setReserved(n)
+}
+
+// SubmitJob queues operation, the name of an /api/pdf endpoint, for userID
+// in lane. fields and query are the request's form and query values and
+// files its uploads by form field. userID may be empty.
+func (s *JobService) SubmitJob(userID, lane, operation string, fields url.Values, query string, files map[string][]*multipart.FileHeader) (*PDFJob, error) {
+	job := &PDFJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Operation: operation,
+		Status:    JobStatusQueued,
+		Lane:      lane,
+		Fields:    fields,
+		Query:     query,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.stageInputs(job, files); err != nil {
+		return nil, fmt.Errorf("failed to stage input files: %w", err)
+	}
+
+	s.saveJob(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.queue.Enqueue(ctx, QueueTopicPDF, job.Lane, job.ID); err != nil {
+		s.jobs.Delete(job.ID)
+		s.deleteInputs(job)
+		return nil, err
+	}
+
+	fmt.Printf("[Jobs] Job %s queued: %s with %d files\n", job.ID, operation, len(job.Inputs))
+	return job, nil
+}
+
+// stageInputs copies the uploads to local disk and, when configured, to
+// the temp bucket so a worker on any instance can run the job
+func (s *JobService) stageInputs(job *PDFJob, files map[string][]*multipart.FileHeader) error {
+	fields := make([]string, 0, len(files))
+	for field := range files {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	dir := filepath.Join(s.tempDir, job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, field := range fields {
+		for _, header := range files[field] {
+			input := PDFJobInput{
+				Field:    field,
+				Filename: header.Filename,
+				Size:     header.Size,
+				Path:     filepath.Join(dir, fmt.Sprintf("%d%s", len(job.Inputs), minioPkg.SafeExtension(header.Filename))),
+			}
+			if err := copyUpload(header, input.Path); err != nil {
+				s.deleteInputs(job)
+				return err
+			}
+			job.Inputs = append(job.Inputs, input)
+
+			if s.minioClient == nil {
+				continue
+			}
+			f, err := os.Open(input.Path)
+			if err != nil {
+				s.deleteInputs(job)
+				return err
+			}
+			key := fmt.Sprintf("pdf-jobs/%s/input/%d%s", job.ID, len(job.Inputs)-1, minioPkg.SafeExtension(header.Filename))
+			_, err = s.minioClient.UploadFile(ctx, s.minioClient.GetBucketTemp(), key, f, header.Size, "application/octet-stream")
+			f.Close()
+			if err != nil {
+				s.deleteInputs(job)
+				return err
+			}
+			job.Inputs[len(job.Inputs)-1].Key = key
+		}
+	}
+	return nil
+}
+
+// copyUpload saves an uploaded file to path
+func copyUpload(header *multipart.FileHeader, path string) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// localInputs returns a local path for every input, downloading staged
+// copies when the job was submitted on another instance
+func (s *JobService) localInputs(job *PDFJob) ([]string, error) {
+	paths := make([]string, len(job.Inputs))
+	for i, input := range job.Inputs {
+		if _, err := os.Stat(input.Path); err == nil {
+			paths[i] = input.Path
+			continue
+		}
+		if input.Key == "" || s.minioClient == nil {
+			return nil, fmt.Errorf("input file %d is not available on this instance", i+1)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(input.Path), 0755); err != nil {
+			return nil, err
+		}
+		obj, err := s.minioClient.GetObject(s.ctx, s.minioClient.GetBucketTemp(), input.Key)
+		if err != nil {
+			return nil, err
+		}
+		out, err := os.Create(input.Path)
+		if err != nil {
+			obj.Close()
+			return nil, err
+		}
+		_, err = io.Copy(out, obj)
+		obj.Close()
+		out.Close()
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = input.Path
+	}
+	return paths, nil
+}
+
+// deleteInputs removes the job's local and staged inputs
+func (s *JobService) deleteInputs(job *PDFJob) {
+	os.RemoveAll(filepath.Join(s.tempDir, job.ID))
+	if s.minioClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, input := range job.Inputs {
+		if input.Key != "" {
+			s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketTemp(), input.Key)
+		}
+	}
+}
+
+// saveJob records a snapshot of the job locally and, when configured, in Mongo
+func (s *JobService) saveJob(job *PDFJob) {
+	snapshot := *job
+	s.jobs.Store(job.ID, &snapshot)
+
+	if s.mongoClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.mongoClient.Collection(pdfJobsCollection).ReplaceOne(ctx,
+		bson.M{"_id": job.ID}, &snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		fmt.Printf("[Jobs] Failed to persist job %s: %v\n", job.ID, err)
+	}
+}
+
+// loadJob returns the stored state of a job
+func (s *JobService) loadJob(jobID string) (*PDFJob, error) {
+	if val, ok := s.jobs.Load(jobID); ok {
+		job := *val.(*PDFJob)
+		return &job, nil
+	}
+
+	// Job may have been submitted to another instance
+	if s.mongoClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var job PDFJob
+		err := s.mongoClient.Collection(pdfJobsCollection).FindOne(ctx, bson.M{"_id": jobID}).Decode(&job)
+		if err == nil {
+			return &job, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// GetJob returns the current state of a job, with the operation's progress
+// while it is processing
+func (s *JobService) GetJob(ctx context.Context, jobID string) (*PDFJob, error) {
+	job, err := s.loadJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == JobStatusProcessing {
+		if p, err := s.progress.Get(ctx, job.ID, job.UserID); err == nil {
+			job.Progress = p
+		}
+	}
+	return job, nil
+}
+
+// CleanupFinished forgets local copies of jobs that reached a final state
+// more than maxAge ago; they stay readable from Mongo
+func (s *JobService) CleanupFinished(ctx context.Context, maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	s.jobs.Range(func(key, val interface{}) bool {
+		job := val.(*PDFJob)
+		if (job.Status == JobStatusCompleted || job.Status == JobStatusFailed) &&
+			!job.CompletedAt.IsZero() && job.CompletedAt.Before(cutoff) {
+			s.jobs.Delete(key)
+		}
+		return true
+	})
+}
+
+// QueueDepth returns the number of PDF jobs waiting for a worker
+func (s *JobService) QueueDepth(ctx context.Context) (int64, error) {
+	return s.queue.Depth(ctx, QueueTopicPDF)
+}
+
+// QueuePosition returns the job's place in the queue, from 1, or 0 once a
+// worker has taken it
+func (s *JobService) QueuePosition(ctx context.Context, jobID string) (int64, error) {
+	return s.queue.Position(ctx, QueueTopicPDF, jobID)
+}
+
+// processJob runs one delivery of a job. Deliveries are at-least-once, so
+// a job that already reached a final state is skipped. It returns false
+// when the service is shutting down and the job should be redelivered.
+func (s *JobService) processJob(jobID string, attempts int) bool {
+	job, err := s.loadJob(jobID)
+	if err != nil {
+		fmt.Printf("[Jobs] Job %s: %v, dropping message\n", jobID, err)
+		return true
+	}
+	if job.Status == JobStatusCompleted || job.Status == JobStatusFailed {
+		return true
+	}
+
+	job.Attempts = attempts
+	if attempts > pdfJobMaxAttempts {
+		s.failJob(job, fmt.Sprintf("Gave up after %d attempts", pdfJobMaxAttempts))
+		return true
+	}
+
+	inputs, err := s.localInputs(job)
+	if err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to fetch input files: %v", err))
+		return true
+	}
+
+	job.Status = JobStatusProcessing
+	job.Instance = s.instanceID
+	s.saveJob(job)
+
+	fmt.Printf("[Jobs] Processing job %s (%s)\n", jobID, job.Operation)
+
+	result, err := s.run(s.ctx, job, inputs)
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return false
+		}
+		s.failJob(job, err.Error())
+		return true
+	}
+	s.deleteInputs(job)
+
+	job.Status = JobStatusCompleted
+	job.Result = result
+	job.FileIDs = resultFileIDs(result)
+	job.CompletedAt = time.Now()
+	s.saveJob(job)
+
+	fmt.Printf("[Jobs] Job %s completed with %d files\n", jobID, len(job.FileIDs))
+	return true
+}
+
+// resultFileIDs lists the stored outputs of an operation result
+func resultFileIDs(result json.RawMessage) []string {
+	var base struct {
+		Outputs []struct {
+			FileID string `json:"fileId"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(result, &base); err != nil {
+		return nil
+	}
+	var ids []string
+	for _, o := range base.Outputs {
+		if o.FileID != "" {
+			ids = append(ids, o.FileID)
+		}
+	}
+	return ids
+}
+
+// failJob marks a job as failed
+func (s *JobService) failJob(job *PDFJob, errMsg string) {
+	s.deleteInputs(job)
+	job.Status = JobStatusFailed
+	job.Error = errMsg
+	job.CompletedAt = time.Now()
+	s.saveJob(job)
+	fmt.Printf("[Jobs] Job %s failed: %s\n", job.ID, errMsg)
+}
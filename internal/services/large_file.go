@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// ProgressFunc receives progress updates for long-running file operations.
+// stage is a short label ("upload", "spool", "process"), done/total are bytes
+// (or units of work when the stage has no byte size).
+type ProgressFunc func(stage string, done, total int64)
+
+// progressReader reports bytes read through a ProgressFunc
+type progressReader struct {
+	r        io.Reader
+	stage    string
+	total    int64
+	done     int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.stage, p.done, p.total)
+	}
+	return n, err
+}
+
+// NewProgressReader wraps r so that every read is reported to progress
+func NewProgressReader(r io.Reader, stage string, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return r
+	}
+	return &progressReader{r: r, stage: stage, total: total, progress: progress}
+}
+
+// NewScratch creates a private temp directory for one request's files
+// within the per-job quota. The caller must Close it.
+func (s *PDFService) NewScratch(job string) (*Scratch, error) {
+	return s.scratch.New(job)
+}
+
+// ScratchSpace returns where the service's temp directories are made
+func (s *PDFService) ScratchSpace() *ScratchSpace {
+	return s.scratch
+}
+
+// SpoolToDisk streams r into a file in scratch without buffering it in
+// memory, failing with ErrScratchQuota once the job's quota is used up
+func (s *PDFService) SpoolToDisk(ctx context.Context, scratch *Scratch, r io.Reader, size int64, progress ProgressFunc) (string, error) {
+	path := scratch.Path("spool.pdf")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(scratch.Writer(f), NewProgressReader(r, "spool", size, progress))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to spool upload: %w", err)
+	}
+	return path, ctx.Err()
+}
+
+// ValidatePDFFile validates a PDF on disk
+func (s *PDFService) ValidatePDFFile(path string) error {
+	_, err := api.ReadContextFile(path)
+	return err
+}
+
+// GetPageCountFile returns the page count of a PDF on disk
+func (s *PDFService) GetPageCountFile(path string) (int, error) {
+	return api.PageCountFile(path)
+}
+
+// CompressFile optimizes inPath into a new file in scratch and returns its
+// path
+func (s *PDFService) CompressFile(ctx context.Context, scratch *Scratch, inPath, quality string, progress ProgressFunc) (string, *CompressResult, error) {
+	before, err := os.Stat(inPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	report(progress, "process", 0, 1)
+	outPath := scratch.Path("compress_output.pdf")
+	if err := api.OptimizeFile(inPath, outPath, s.getConfig()); err != nil {
+		return "", nil, fmt.Errorf("compress failed: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", nil, err
+	}
+	report(progress, "process", 1, 1)
+
+	after, err := os.Stat(outPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return outPath, &CompressResult{
+		SizeBefore:  before.Size(),
+		SizeAfter:   after.Size(),
+		Compression: float64(before.Size()-after.Size()) / float64(before.Size()) * 100,
+	}, nil
+}
+
+// RotateFile rotates pages of inPath into a new file in scratch and
+// returns its path
+func (s *PDFService) RotateFile(ctx context.Context, scratch *Scratch, inPath, pages string, angle int, progress ProgressFunc) (string, error) {
+	var pageSelection []string
+	if pages != "" && pages != "1-" {
+		pageSelection = []string{pages}
+	}
+
+	report(progress, "process", 0, 1)
+	outPath := scratch.Path("rotate_output.pdf")
+	if err := api.RotateFile(inPath, outPath, angle, pageSelection, s.getConfig()); err != nil {
+		return "", fmt.Errorf("rotate failed: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", err
+	}
+	report(progress, "process", 1, 1)
+
+	return outPath, nil
+}
+
+// RotatePagesFile is the file-to-file variant of RotatePages
+func (s *PDFService) RotatePagesFile(ctx context.Context, scratch *Scratch, inPath string, rotations map[int]int, progress ProgressFunc) (string, error) {
+	report(progress, "process", 0, 1)
+	f, err := os.Open(inPath)
+	if err != nil {
+		return "", err
+	}
+	pdfCtx, err := api.ReadContext(f, s.getConfig())
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := rotatePages(ctx, pdfCtx, rotations); err != nil {
+		return "", err
+	}
+
+	outPath := scratch.Path("rotate_output.pdf")
+	if err := api.WriteContextFile(pdfCtx, outPath); err != nil {
+		return "", fmt.Errorf("failed to write pdf: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", err
+	}
+	report(progress, "process", 1, 1)
+
+	return outPath, nil
+}
+
+// ExtractPagesFile is the file-to-file variant of ExtractPages
+func (s *PDFService) ExtractPagesFile(ctx context.Context, scratch *Scratch, inPath, pages string, progress ProgressFunc) (string, error) {
+	selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return "", fmt.Errorf("invalid page selection: %w", err)
+	}
+
+	report(progress, "process", 0, 1)
+	outPath := scratch.Path("extract_output.pdf")
+	if err := api.CollectFile(inPath, outPath, selection, s.getConfig()); err != nil {
+		return "", fmt.Errorf("extract failed: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", err
+	}
+	report(progress, "process", 1, 1)
+
+	return outPath, ctx.Err()
+}
+
+// RemovePagesFile is the file-to-file variant of RemovePages
+func (s *PDFService) RemovePagesFile(ctx context.Context, scratch *Scratch, inPath, pages string, progress ProgressFunc) (string, error) {
+	selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return "", fmt.Errorf("invalid page selection: %w", err)
+	}
+
+	report(progress, "process", 0, 1)
+	outPath := scratch.Path("remove_output.pdf")
+	if err := api.RemovePagesFile(inPath, outPath, selection, s.getConfig()); err != nil {
+		return "", fmt.Errorf("remove failed: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", err
+	}
+	report(progress, "process", 1, 1)
+
+	return outPath, ctx.Err()
+}
+
+// MergeFiles is the file-to-file variant of Merge, combining inPaths in
+// order into a new file in scratch
+func (s *PDFService) MergeFiles(ctx context.Context, scratch *Scratch, inPaths []string, progress ProgressFunc) (string, error) {
+	if len(inPaths) < 2 {
+		return "", fmt.Errorf("at least 2 files required for merge")
+	}
+
+	report(progress, "process", 0, 1)
+	outPath := scratch.Path("merged.pdf")
+	if err := api.MergeCreateFile(inPaths, outPath, false, s.getConfig()); err != nil {
+		return "", fmt.Errorf("merge failed: %w", err)
+	}
+	if err := scratch.Check(); err != nil {
+		return "", err
+	}
+	report(progress, "process", 1, 1)
+
+	return outPath, ctx.Err()
+}
+
+// SplitFile writes one file in scratch per comma-separated range of
+// inPath. Progress is reported per range.
+func (s *PDFService) SplitFile(ctx context.Context, scratch *Scratch, inPath, pages string, progress ProgressFunc) ([]string, error) {
+	var parts []string
+	for _, part := range strings.Split(pages, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("split failed: no page ranges given")
+	}
+
+	var outputs []string
+	for i, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		outPath := scratch.Path("split_output.pdf")
+		if err := api.CollectFile(inPath, outPath, []string{part}, s.getConfig()); err != nil {
+			return nil, fmt.Errorf("split failed for range %s: %w", part, err)
+		}
+		if err := scratch.Check(); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, outPath)
+		report(progress, "process", int64(i+1), int64(len(parts)))
+	}
+
+	return outputs, nil
+}
+
+func report(progress ProgressFunc, stage string, done, total int64) {
+	if progress != nil {
+		progress(stage, done, total)
+	}
+}
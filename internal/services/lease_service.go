@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lease is a named, time-bounded lock held by one instance
+type Lease struct {
+	Name      string    `bson:"_id" json:"name"`
+	Holder    string    `bson:"holder" json:"holder"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// LeaseService provides Mongo-backed leases so that periodic jobs run on
+// exactly one instance when the API is scaled horizontally
+type LeaseService struct {
+	mongoClient *mongodb.Client
+	holderID    string
+}
+
+// NewLeaseService creates a lease service identified by hostname, pid and a
+// random suffix so restarts never inherit a previous process's leases
+func NewLeaseService(mongoClient *mongodb.Client) *LeaseService {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return &LeaseService{
+		mongoClient: mongoClient,
+		holderID:    fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+	}
+}
+
+// HolderID returns the identity this instance uses for leases
+func (s *LeaseService) HolderID() string {
+	return s.holderID
+}
+
+func (s *LeaseService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("leases")
+}
+
+// TryAcquire takes or renews the named lease for ttl. It returns false
+// (without error) when another live instance holds it.
+func (s *LeaseService) TryAcquire(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	// Matches when the lease is free, expired, or already ours; otherwise
+	// the upsert collides with the existing _id and we lose the race
+	filter := bson.M{
+		"_id": name,
+		"$or": []bson.M{
+			{"expiresAt": bson.M{"$lt": now}},
+			{"holder": s.holderID},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"holder":    s.holderID,
+		"expiresAt": now.Add(ttl),
+		"updatedAt": now,
+	}}
+
+	_, err := s.collection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// Release gives up the named lease if this instance holds it
+func (s *LeaseService) Release(ctx context.Context, name string) error {
+	_, err := s.collection().DeleteOne(ctx, bson.M{"_id": name, "holder": s.holderID})
+	return err
+}
+
+// GetLease returns the current state of the named lease
+func (s *LeaseService) GetLease(ctx context.Context, name string) (*Lease, error) {
+	var lease Lease
+	if err := s.collection().FindOne(ctx, bson.M{"_id": name}).Decode(&lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// RunPeriodic calls fn every interval on whichever instance holds the named
+// lease. The lease outlives one interval so the leader keeps it between
+// ticks; if the leader dies another instance takes over once it expires.
+// Blocks until ctx is cancelled.
+func (s *LeaseService) RunPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context)) {
+	ttl := interval + interval/2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.Release(releaseCtx, name)
+			cancel()
+			return
+		case <-ticker.C:
+			acquired, err := s.TryAcquire(ctx, name, ttl)
+			if err != nil {
+				log.Printf("[Lease] %s: %v", name, err)
+				continue
+			}
+			if !acquired {
+				continue
+			}
+			fn(ctx)
+		}
+	}
+}
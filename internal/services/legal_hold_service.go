@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrLegalHold is returned when deleting a file under legal hold
+var ErrLegalHold = errors.New("file is under legal hold")
+
+// notHeld matches files that are not under legal hold; deletions filter on
+// it so a hold placed concurrently still wins
+var notHeld = bson.M{"$exists": false}
+
+// heldLibraryItem is the part of a library document a hold needs
+type heldLibraryItem struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	UserID    string             `bson:"userId"`
+	FileName  string             `bson:"fileName"`
+	Size      int64              `bson:"size"`
+	LegalHold *models.LegalHold  `bson:"legalHold"`
+}
+
+// LegalHoldService places and releases legal holds on stored files and
+// library documents
+type LegalHoldService struct {
+	mongoClient *mongodb.Client
+}
+
+// NewLegalHoldService creates a legal hold service
+func NewLegalHoldService(mongoClient *mongodb.Client) *LegalHoldService {
+	return &LegalHoldService{mongoClient: mongoClient}
+}
+
+// Find returns the file or library document with the given ID
+func (s *LegalHoldService) Find(ctx context.Context, id string) (*models.HeldItem, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+
+	var doc models.Document
+	err = s.mongoClient.Documents().FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if err == nil {
+		return &models.HeldItem{
+			ID:        id,
+			Kind:      models.HeldItemFile,
+			OwnerUID:  DocumentOwner(&doc),
+			FileName:  doc.OriginalName,
+			Size:      doc.Size,
+			LegalHold: doc.LegalHold,
+		}, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to look up file: %w", err)
+	}
+
+	var item heldLibraryItem
+	err = s.mongoClient.Collection(legacyLibraryCollection).FindOne(ctx, bson.M{"_id": objID, "migratedAt": notMigrated}).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file: %w", err)
+	}
+	return item.held(), nil
+}
+
+// Hold places a legal hold on an item, replacing any existing one
+func (s *LegalHoldService) Hold(ctx context.Context, item *models.HeldItem, hold models.LegalHold) error {
+	hold.SetAt = time.Now()
+	if err := s.update(ctx, item, bson.M{"$set": bson.M{"legalHold": hold}}); err != nil {
+		return err
+	}
+	item.LegalHold = &hold
+	return nil
+}
+
+// Release lifts the legal hold on an item
+func (s *LegalHoldService) Release(ctx context.Context, item *models.HeldItem) error {
+	if err := s.update(ctx, item, bson.M{"$unset": bson.M{"legalHold": ""}}); err != nil {
+		return err
+	}
+	item.LegalHold = nil
+	return nil
+}
+
+func (s *LegalHoldService) update(ctx context.Context, item *models.HeldItem, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(item.ID)
+	if err != nil {
+		return ErrFileNotFound
+	}
+	if item.Kind == models.HeldItemLibrary {
+		res, err := s.mongoClient.Collection(legacyLibraryCollection).UpdateOne(ctx, bson.M{"_id": objID, "migratedAt": notMigrated}, update)
+		if err != nil {
+			return fmt.Errorf("failed to update legal hold: %w", err)
+		}
+		if res.MatchedCount > 0 {
+			return nil
+		}
+		// Moved into documents since it was found
+	}
+	res, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update legal hold: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+// List returns the held items, all of them or those held by one
+// organization when orgID is set
+func (s *LegalHoldService) List(ctx context.Context, orgID string) ([]models.HeldItem, error) {
+	filter := bson.M{"legalHold": bson.M{"$exists": true}}
+	if orgID != "" {
+		filter["legalHold.orgId"] = orgID
+	}
+
+	cursor, err := s.mongoClient.Documents().Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list held files: %w", err)
+	}
+	var docs []models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode held files: %w", err)
+	}
+
+	filter["migratedAt"] = notMigrated
+	cursor, err = s.mongoClient.Collection(legacyLibraryCollection).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list held library documents: %w", err)
+	}
+	var libItems []heldLibraryItem
+	if err := cursor.All(ctx, &libItems); err != nil {
+		return nil, fmt.Errorf("failed to decode held library documents: %w", err)
+	}
+
+	items := make([]models.HeldItem, 0, len(docs)+len(libItems))
+	for i := range docs {
+		items = append(items, models.HeldItem{
+			ID:        docs[i].ID.Hex(),
+			Kind:      models.HeldItemFile,
+			OwnerUID:  DocumentOwner(&docs[i]),
+			FileName:  docs[i].OriginalName,
+			Size:      docs[i].Size,
+			LegalHold: docs[i].LegalHold,
+		})
+	}
+	for _, item := range libItems {
+		items = append(items, *item.held())
+	}
+	return items, nil
+}
+
+func (i heldLibraryItem) held() *models.HeldItem {
+	return &models.HeldItem{
+		ID:        i.ID.Hex(),
+		Kind:      models.HeldItemLibrary,
+		OwnerUID:  i.UserID,
+		FileName:  i.FileName,
+		Size:      i.Size,
+		LegalHold: i.LegalHold,
+	}
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+)
+
+// Kinds of forwarded log events
+const (
+	LogEventAudit     = "audit"
+	LogEventOperation = "operation"
+)
+
+const (
+	logForwardMinBackoff = time.Second
+	logForwardMaxBackoff = 5 * time.Minute
+	logForwardTimeout    = 30 * time.Second
+	// logForwardQueueSize holds events logged while a batch is being sent
+	logForwardQueueSize = 1024
+)
+
+// LogForwarderConfig configures shipping logs to a SIEM
+type LogForwarderConfig struct {
+	Transport      string        // "http" or "syslog"; empty disables forwarding
+	Endpoint       string        // URL for http, host:port for syslog
+	SyslogProtocol string        // udp, tcp or tls
+	AuthHeader     string        // Authorization header for http, e.g. "Bearer <token>"
+	BatchSize      int           // events per request
+	FlushInterval  time.Duration // longest an event waits before being sent
+	BufferSize     int           // events kept while the SIEM is unreachable
+}
+
+// LogEvent is a forwarded audit entry or operation log
+type LogEvent struct {
+	Type      string      `json:"type"` // audit, operation
+	Timestamp time.Time   `json:"timestamp"`
+	Host      string      `json:"host"`
+	Event     interface{} `json:"event"`
+}
+
+// operationEvent is the part of an operation log sent to the SIEM; typed
+// results and output URLs stay in the database
+type operationEvent struct {
+	ID           string   `json:"id,omitempty"`
+	UserID       string   `json:"userId,omitempty"`
+	Operation    string   `json:"operation"`
+	InputFiles   []string `json:"inputFiles"`
+	OutputFileID string   `json:"outputFileId,omitempty"`
+	OutputFiles  []string `json:"outputFiles,omitempty"`
+	PageCount    int      `json:"pageCount,omitempty"`
+	Status       string   `json:"status"`
+	ErrorMessage string   `json:"errorMessage,omitempty"`
+	ProcessingMs int64    `json:"processingMs"`
+	RequestID    string   `json:"requestId,omitempty"`
+	SessionID    string   `json:"sessionId,omitempty"`
+	IP           string   `json:"ip,omitempty"`
+	UserAgent    string   `json:"userAgent,omitempty"`
+}
+
+// logSink delivers a batch of events to the SIEM
+type logSink interface {
+	Send(ctx context.Context, events []LogEvent) error
+	Close() error
+}
+
+// LogForwarder ships audit and operation logs to an external SIEM over
+// HTTP or syslog. Events are buffered in memory and sent in batches; while
+// the SIEM is unreachable sends are retried with exponential backoff, and
+// once the buffer is full the oldest events are dropped so logging never
+// slows requests down. A nil forwarder discards everything.
+type LogForwarder struct {
+	sink          logSink
+	host          string
+	batchSize     int
+	flushInterval time.Duration
+	bufferSize    int
+
+	events chan LogEvent
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewLogForwarder creates a log forwarder and starts sending. It returns
+// nil when cfg.Transport is empty.
+func NewLogForwarder(cfg LogForwarderConfig) (*LogForwarder, error) {
+	var sink logSink
+	switch strings.ToLower(cfg.Transport) {
+	case "":
+		return nil, nil
+	case "http", "https":
+		if !strings.HasPrefix(cfg.Endpoint, "http://") && !strings.HasPrefix(cfg.Endpoint, "https://") {
+			return nil, fmt.Errorf("SIEM endpoint must be an http(s) URL")
+		}
+		sink = &httpLogSink{
+			url:        cfg.Endpoint,
+			authHeader: cfg.AuthHeader,
+			client:     &http.Client{Timeout: logForwardTimeout},
+		}
+	case "syslog":
+		if _, _, err := net.SplitHostPort(cfg.Endpoint); err != nil {
+			return nil, fmt.Errorf("SIEM syslog endpoint must be host:port: %w", err)
+		}
+		protocol := strings.ToLower(cfg.SyslogProtocol)
+		switch protocol {
+		case "":
+			protocol = "tcp"
+		case "udp", "tcp", "tls":
+		default:
+			return nil, fmt.Errorf("unknown syslog protocol %q", cfg.SyslogProtocol)
+		}
+		sink = &syslogSink{protocol: protocol, addr: cfg.Endpoint}
+	default:
+		return nil, fmt.Errorf("unknown SIEM transport %q", cfg.Transport)
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	cfg.BufferSize = max(cfg.BufferSize, cfg.BatchSize)
+	host, _ := os.Hostname()
+
+	f := &LogForwarder{
+		sink:          sink,
+		host:          host,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		bufferSize:    cfg.BufferSize,
+		events:        make(chan LogEvent, logForwardQueueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go f.run()
+	log.Printf("[SIEM] Forwarding audit and operation logs over %s to %s", strings.ToLower(cfg.Transport), cfg.Endpoint)
+	return f, nil
+}
+
+// ForwardAudit queues an audit entry
+func (f *LogForwarder) ForwardAudit(entry models.AuditEntry) {
+	f.forward(LogEventAudit, entry.CreatedAt, entry)
+}
+
+// ForwardOperation queues an operation log
+func (f *LogForwarder) ForwardOperation(op models.OperationLog) {
+	event := operationEvent{
+		UserID:       op.UserID,
+		Operation:    op.Operation,
+		InputFiles:   op.InputFiles,
+		OutputFileID: op.OutputFileID,
+		OutputFiles:  op.OutputFiles,
+		PageCount:    op.PageCount,
+		Status:       op.Status,
+		ErrorMessage: op.ErrorMessage,
+		ProcessingMs: op.ProcessingMs,
+		RequestID:    op.RequestID,
+		SessionID:    op.SessionID,
+		IP:           op.IP,
+		UserAgent:    op.UserAgent,
+	}
+	if !op.ID.IsZero() {
+		event.ID = op.ID.Hex()
+	}
+	f.forward(LogEventOperation, op.CreatedAt, event)
+}
+
+func (f *LogForwarder) forward(kind string, at time.Time, event interface{}) {
+	if f == nil {
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	select {
+	case f.events <- LogEvent{Type: kind, Timestamp: at.UTC(), Host: f.host, Event: event}:
+	default:
+		f.drop(1)
+	}
+}
+
+// Close sends what is buffered, giving up after a few seconds, and stops
+// forwarding
+func (f *LogForwarder) Close() {
+	if f == nil {
+		return
+	}
+	close(f.stop)
+	<-f.done
+}
+
+func (f *LogForwarder) drop(n int) {
+	f.mu.Lock()
+	f.dropped += n
+	f.mu.Unlock()
+}
+
+// takeDropped returns and resets the count of events dropped since the
+// last call
+func (f *LogForwarder) takeDropped() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.dropped
+	f.dropped = 0
+	return n
+}
+
+func (f *LogForwarder) run() {
+	defer close(f.done)
+	defer f.sink.Close()
+
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	var (
+		pending  []LogEvent
+		failures int
+		retryAt  time.Time
+	)
+	flush := func(ctx context.Context) {
+		if n := f.takeDropped(); n > 0 {
+			log.Printf("[SIEM] Dropped %d log events, the buffer is full", n)
+		}
+		for len(pending) > 0 {
+			n := min(f.batchSize, len(pending))
+			if err := f.sink.Send(ctx, pending[:n]); err != nil {
+				failures++
+				backoff := min(logForwardMinBackoff<<min(failures-1, 16), logForwardMaxBackoff)
+				retryAt = time.Now().Add(backoff)
+				log.Printf("[SIEM] Failed to send %d log events, retrying in %s: %v", n, backoff, err)
+				return
+			}
+			pending = pending[n:]
+			failures = 0
+		}
+	}
+
+	for {
+		select {
+		case e := <-f.events:
+			if len(pending) >= f.bufferSize {
+				pending = pending[1:]
+				f.drop(1)
+			}
+			pending = append(pending, e)
+			if len(pending) >= f.batchSize && time.Now().After(retryAt) {
+				flush(context.Background())
+			}
+		case <-ticker.C:
+			if time.Now().After(retryAt) {
+				flush(context.Background())
+			}
+		case <-f.stop:
+		drain:
+			for {
+				select {
+				case e := <-f.events:
+					pending = append(pending, e)
+				default:
+					break drain
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(ctx)
+			cancel()
+			if len(pending) > 0 {
+				log.Printf("[SIEM] Shutting down with %d unsent log events", len(pending))
+			}
+			return
+		}
+	}
+}
+
+// httpLogSink posts batches as a JSON array
+type httpLogSink struct {
+	url        string
+	authHeader string
+	client     *http.Client
+}
+
+func (s *httpLogSink) Send(ctx context.Context, events []LogEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.authHeader != "" {
+		req.Header.Set("Authorization", s.authHeader)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("SIEM responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpLogSink) Close() error {
+	return nil
+}
+
+// syslogSink writes RFC 5424 messages, octet-counted over TCP and TLS
+// (RFC 6587) and one per datagram over UDP, reconnecting after errors
+type syslogSink struct {
+	protocol string // udp, tcp, tls
+	addr     string
+	conn     net.Conn
+}
+
+// Syslog facility local0, severities notice and info; failed operations
+// are sent as warnings
+const (
+	syslogFacility       = 16
+	syslogSeverityWarn   = 4
+	syslogSeverityNotice = 5
+	syslogSeverityInfo   = 6
+)
+
+func (s *syslogSink) Send(ctx context.Context, events []LogEvent) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	} else {
+		s.conn.SetWriteDeadline(time.Now().Add(logForwardTimeout))
+	}
+
+	for _, e := range events {
+		msg, err := formatSyslog(e)
+		if err != nil {
+			return err
+		}
+		if s.protocol != "udp" {
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: logForwardTimeout}
+	if s.protocol == "tls" {
+		host, _, _ := net.SplitHostPort(s.addr)
+		return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", s.addr)
+	}
+	return dialer.DialContext(ctx, s.protocol, s.addr)
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// formatSyslog renders an event as an RFC 5424 message whose body is the
+// event as JSON
+func formatSyslog(e LogEvent) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := syslogSeverityInfo
+	switch ev := e.Event.(type) {
+	case models.AuditEntry:
+		severity = syslogSeverityNotice
+	case operationEvent:
+		if ev.Status != models.OperationStatusSuccess {
+			severity = syslogSeverityWarn
+		}
+	}
+	host := e.Host
+	if host == "" {
+		host = "-"
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s brainy-pdf - %s - ",
+		syslogFacility*8+severity, e.Timestamp.Format(time.RFC3339Nano), host, e.Type)
+	return append([]byte(header), body...), nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maintenanceID is the settings document holding the maintenance state
+	maintenanceID = "maintenance"
+	// maintenancePollInterval is how quickly other instances follow a change
+	maintenancePollInterval = 5 * time.Second
+	// DefaultMaintenanceRetryAfter is the Retry-After hint when none is set
+	DefaultMaintenanceRetryAfter = 300
+)
+
+// MaintenanceService holds the maintenance switch shared by every API and
+// worker instance. The state is kept in Mongo and cached in memory, so
+// checking it on each request is cheap; instances poll for changes.
+type MaintenanceService struct {
+	mongoClient *mongodb.Client
+
+	mu    sync.RWMutex
+	state models.MaintenanceState
+}
+
+// NewMaintenanceService creates a maintenance service, loading the current
+// state
+func NewMaintenanceService(ctx context.Context, mongoClient *mongodb.Client) *MaintenanceService {
+	s := &MaintenanceService{mongoClient: mongoClient}
+	if err := s.Refresh(ctx); err != nil {
+		log.Printf("[Maintenance] Failed to load state: %v", err)
+	}
+	return s
+}
+
+func (s *MaintenanceService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("settings")
+}
+
+// Active reports whether maintenance mode is on. Safe to call on a nil
+// service.
+func (s *MaintenanceService) Active() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.Enabled
+}
+
+// State returns the cached maintenance state
+func (s *MaintenanceService) State() models.MaintenanceState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Set turns maintenance mode on or off for every instance
+func (s *MaintenanceService) Set(ctx context.Context, enabled bool, message string, retryAfterSeconds int, by string) (models.MaintenanceState, error) {
+	now := time.Now()
+	state := models.MaintenanceState{Enabled: enabled, UpdatedAt: now}
+	if enabled {
+		state.Message = message
+		state.RetryAfterSeconds = retryAfterSeconds
+		if state.RetryAfterSeconds <= 0 {
+			state.RetryAfterSeconds = DefaultMaintenanceRetryAfter
+		}
+		state.StartedBy = by
+		state.StartedAt = &now
+		// Keep the original start when only the message changes
+		if current := s.State(); current.Enabled {
+			state.StartedBy = current.StartedBy
+			state.StartedAt = current.StartedAt
+		}
+	}
+
+	_, err := s.collection().ReplaceOne(ctx, bson.M{"_id": maintenanceID}, state, options.Replace().SetUpsert(true))
+	if err != nil {
+		return s.State(), fmt.Errorf("failed to save maintenance state: %w", err)
+	}
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return state, nil
+}
+
+// Refresh reloads the state from Mongo
+func (s *MaintenanceService) Refresh(ctx context.Context) error {
+	var state models.MaintenanceState
+	err := s.collection().FindOne(ctx, bson.M{"_id": maintenanceID}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		state = models.MaintenanceState{}
+	} else if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	changed := s.state.Enabled != state.Enabled
+	s.state = state
+	s.mu.Unlock()
+	if changed && state.Enabled {
+		log.Printf("[Maintenance] Maintenance mode on, pausing writes and queued jobs")
+	} else if changed {
+		log.Printf("[Maintenance] Maintenance mode off")
+	}
+	return nil
+}
+
+// Run follows changes made by other instances until ctx is done
+func (s *MaintenanceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(maintenancePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(ctx, maintenancePollInterval)
+			if err := s.Refresh(refreshCtx); err != nil {
+				log.Printf("[Maintenance] Failed to refresh state: %v", err)
+			}
+			cancel()
+		}
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -9,11 +10,50 @@ import (
 	"brainy-pdf/pkg/mongodb"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Recipient identifies the user a notification is for. Handlers know
+// users by Firebase UID and services mostly by Mongo ID; both resolve to
+// the same user.
+type Recipient struct {
+	UserID      string // Mongo ObjectID hex
+	FirebaseUID string
+}
+
+// UserRecipient addresses a notification by Mongo user ID
+func UserRecipient(userID string) Recipient {
+	return Recipient{UserID: userID}
+}
+
+// FirebaseRecipient addresses a notification by Firebase UID
+func FirebaseRecipient(firebaseUID string) Recipient {
+	return Recipient{FirebaseUID: firebaseUID}
+}
+
+func (r Recipient) String() string {
+	if r.FirebaseUID != "" {
+		return "uid:" + r.FirebaseUID
+	}
+	return r.UserID
+}
+
+// NotificationChannel delivers notifications beyond the in-app inbox,
+// e.g. by email or push. Delivery is best effort: failures are logged and
+// don't affect the inbox or other channels.
+type NotificationChannel interface {
+	Name() string
+	Deliver(ctx context.Context, user *models.User, notification *models.Notification) error
+}
+
+// ErrRecipientNotFound means a notification's recipient doesn't resolve to
+// a user
+var ErrRecipientNotFound = errors.New("notification recipient not found")
+
 type NotificationService struct {
 	mongoClient *mongodb.Client
+	channels    []NotificationChannel
 }
 
 func NewNotificationService(mongoClient *mongodb.Client) *NotificationService {
@@ -22,16 +62,46 @@ func NewNotificationService(mongoClient *mongodb.Client) *NotificationService {
 	}
 }
 
-// CreateNotification creates a new notification for a user
-func (s *NotificationService) CreateNotification(ctx context.Context, userID, title, message string, notifType models.NotificationType) error {
-	userObjID, err := primitive.ObjectIDFromHex(userID)
+// AddChannel delivers future notifications through ch as well as the
+// in-app inbox. Call it during startup, before notifications are sent.
+func (s *NotificationService) AddChannel(ch NotificationChannel) {
+	s.channels = append(s.channels, ch)
+}
+
+// resolve finds the user a recipient refers to
+func (s *NotificationService) resolve(ctx context.Context, to Recipient) (*models.User, error) {
+	filter := bson.M{"firebaseUid": to.FirebaseUID}
+	if to.FirebaseUID == "" {
+		userObjID, err := primitive.ObjectIDFromHex(to.UserID)
+		if err != nil {
+			return nil, ErrRecipientNotFound
+		}
+		filter = bson.M{"_id": userObjID}
+	}
+
+	var user models.User
+	err := s.mongoClient.Users().FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRecipientNotFound
+	}
 	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateNotification adds a notification to a user's inbox and sends it
+// through the configured channels
+func (s *NotificationService) CreateNotification(ctx context.Context, to Recipient, title, message string, notifType models.NotificationType) error {
+	user, err := s.resolve(ctx, to)
+	if err != nil {
+		log.Printf("[Notification] Failed to resolve recipient %s: %v", to, err)
 		return err
 	}
 
 	notification := models.Notification{
 		ID:        primitive.NewObjectID(),
-		UserID:    userObjID,
+		UserID:    user.ID,
 		Title:     title,
 		Message:   message,
 		Type:      notifType,
@@ -42,10 +112,16 @@ func (s *NotificationService) CreateNotification(ctx context.Context, userID, ti
 	_, err = s.mongoClient.Collection("notifications").InsertOne(ctx, notification)
 	if err != nil {
 		log.Printf("[Notification] Failed to insert notification: %v", err)
-	} else {
-		log.Printf("[Notification] Created notification for user %s: %s", userID, title)
+		return err
 	}
-	return err
+	log.Printf("[Notification] Created notification for user %s: %s", user.ID.Hex(), title)
+
+	for _, ch := range s.channels {
+		if err := ch.Deliver(ctx, user, &notification); err != nil {
+			log.Printf("[Notification] %s delivery to user %s failed: %v", ch.Name(), user.ID.Hex(), err)
+		}
+	}
+	return nil
 }
 
 // GetUserNotifications retrieves unread and recent read notifications for a user
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orgCollection holds organizations with their members and policies
+const orgCollection = "organizations"
+
+var (
+	ErrOrgNotFound  = errors.New("organization not found")
+	ErrAlreadyInOrg = errors.New("user already belongs to an organization")
+	ErrNotOrgMember = errors.New("user is not a member of this organization")
+	ErrLastOrgAdmin = errors.New("an organization needs at least one admin")
+)
+
+// OrgService manages organizations and resolves the compliance policy that
+// applies to a user
+type OrgService struct {
+	mongoClient *mongodb.Client
+}
+
+// NewOrgService creates an organization service
+func NewOrgService(mongoClient *mongodb.Client) *OrgService {
+	return &OrgService{mongoClient: mongoClient}
+}
+
+// Create makes a new organization administered by its creator
+func (s *OrgService) Create(ctx context.Context, name string, owner models.OrgMember) (*models.Organization, error) {
+	if org, err := s.ForUser(ctx, owner.UserID); err != nil {
+		return nil, err
+	} else if org != nil {
+		return nil, ErrAlreadyInOrg
+	}
+
+	now := time.Now()
+	owner.Role = models.OrgRoleAdmin
+	owner.JoinedAt = now
+	org := &models.Organization{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Members:   []models.OrgMember{owner},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.mongoClient.Collection(orgCollection).InsertOne(ctx, org); err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	return org, nil
+}
+
+// Get returns an organization by ID
+func (s *OrgService) Get(ctx context.Context, id string) (*models.Organization, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrOrgNotFound
+	}
+	var org models.Organization
+	err = s.mongoClient.Collection(orgCollection).FindOne(ctx, bson.M{"_id": objID}).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrgNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up organization: %w", err)
+	}
+	return &org, nil
+}
+
+// ForUser returns the organization userID belongs to, or nil when none
+func (s *OrgService) ForUser(ctx context.Context, userID string) (*models.Organization, error) {
+	var org models.Organization
+	err := s.mongoClient.Collection(orgCollection).FindOne(ctx, bson.M{"members.userId": userID}).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up organization: %w", err)
+	}
+	return &org, nil
+}
+
+// PolicyFor returns the policy that applies to userID: their
+// organization's, or the zero policy. Lookup failures fall back to the zero
+// policy and are logged, so a Mongo hiccup doesn't take sharing down.
+func (s *OrgService) PolicyFor(ctx context.Context, userID string) models.OrgPolicy {
+	if userID == "" {
+		return models.OrgPolicy{}
+	}
+	org, err := s.ForUser(ctx, userID)
+	if err != nil {
+		log.Printf("[Org] Policy lookup for %s failed: %v", userID, err)
+		return models.OrgPolicy{}
+	}
+	if org == nil {
+		return models.OrgPolicy{}
+	}
+	return org.Policy
+}
+
+// AddMember adds a user who is not yet in any organization
+func (s *OrgService) AddMember(ctx context.Context, orgID primitive.ObjectID, member models.OrgMember) error {
+	if org, err := s.ForUser(ctx, member.UserID); err != nil {
+		return err
+	} else if org != nil {
+		return ErrAlreadyInOrg
+	}
+
+	member.JoinedAt = time.Now()
+	res, err := s.mongoClient.Collection(orgCollection).UpdateOne(ctx,
+		bson.M{"_id": orgID},
+		bson.M{"$push": bson.M{"members": member}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// RemoveMember removes a user, refusing to remove the last admin
+func (s *OrgService) RemoveMember(ctx context.Context, org *models.Organization, userID string) error {
+	member := org.Member(userID)
+	if member == nil {
+		return ErrNotOrgMember
+	}
+	if member.Role == models.OrgRoleAdmin {
+		admins := 0
+		for _, m := range org.Members {
+			if m.Role == models.OrgRoleAdmin {
+				admins++
+			}
+		}
+		if admins == 1 {
+			return ErrLastOrgAdmin
+		}
+	}
+
+	_, err := s.mongoClient.Collection(orgCollection).UpdateOne(ctx,
+		bson.M{"_id": org.ID},
+		bson.M{"$pull": bson.M{"members": bson.M{"userId": userID}}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return nil
+}
+
+// UpdatePolicy replaces an organization's policy
+func (s *OrgService) UpdatePolicy(ctx context.Context, orgID primitive.ObjectID, policy models.OrgPolicy) error {
+	res, err := s.mongoClient.Collection(orgCollection).UpdateOne(ctx,
+		bson.M{"_id": orgID},
+		bson.M{"$set": bson.M{"policy": policy, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update policy: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// SetStorage sets the organization's own bucket, or removes it when storage
+// is nil
+func (s *OrgService) SetStorage(ctx context.Context, orgID primitive.ObjectID, storage *models.OrgStorage) error {
+	set := bson.M{"updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if storage != nil {
+		set["storage"] = storage
+	} else {
+		update["$unset"] = bson.M{"storage": ""}
+	}
+	res, err := s.mongoClient.Collection(orgCollection).UpdateOne(ctx, bson.M{"_id": orgID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update storage: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// SetBranding sets the organization's branding, or removes it when branding
+// is nil
+func (s *OrgService) SetBranding(ctx context.Context, orgID primitive.ObjectID, branding *models.OrgBranding) error {
+	set := bson.M{"updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if branding != nil {
+		set["branding"] = branding
+	} else {
+		update["$unset"] = bson.M{"branding": ""}
+	}
+	res, err := s.mongoClient.Collection(orgCollection).UpdateOne(ctx, bson.M{"_id": orgID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update branding: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// ForShareDomain returns the organization that verified domain as its
+// share domain, or nil
+func (s *OrgService) ForShareDomain(ctx context.Context, domain string) (*models.Organization, error) {
+	var org models.Organization
+	err := s.mongoClient.Collection(orgCollection).FindOne(ctx, bson.M{
+		"branding.shareDomain":         domain,
+		"branding.shareDomainVerified": true,
+	}).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up share domain: %w", err)
+	}
+	return &org, nil
+}
+
+// StoredFileCount returns how many documents are stored in the
+// organization's own bucket
+func (s *OrgService) StoredFileCount(ctx context.Context, orgID string) (int64, error) {
+	n, err := s.mongoClient.Documents().CountDocuments(ctx, bson.M{"storageId": orgID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count stored files: %w", err)
+	}
+	return n, nil
+}
+
+// IsConfidential reports whether a file with the given content hash is a
+// library document of one of the organization's members tagged
+// confidential
+func (s *OrgService) IsConfidential(ctx context.Context, org *models.Organization, contentHash string) (bool, error) {
+	n, err := s.mongoClient.Documents().CountDocuments(ctx, bson.M{
+		"ownerUid":      bson.M{"$in": org.MemberIDs()},
+		"metadata.tags": models.TagConfidential,
+		"contentHash":   contentHash,
+	})
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+	// Library documents not yet moved out of the legacy collection
+	n, err = s.mongoClient.Collection(legacyLibraryCollection).CountDocuments(ctx, bson.M{
+		"userId":      bson.M{"$in": org.MemberIDs()},
+		"tags":        models.TagConfidential,
+		"contentHash": contentHash,
+		"migratedAt":  notMigrated,
+	})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ContentHash identifies a file by its contents, so copies of a document
+// can be recognized wherever they are uploaded
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHashReader is ContentHash of everything read from r, hashed as it
+// is read
+func ContentHashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	razorpay "github.com/razorpay/razorpay-go"
+)
+
+// PaymentOrder is a checkout order created with the payment gateway
+type PaymentOrder struct {
+	ID       string
+	Amount   int64 // In the currency's smallest unit, e.g. paise
+	Currency string
+	Receipt  string
+	Status   string
+}
+
+// PaymentGateway creates checkout orders and verifies the signatures the
+// checkout returns for completed payments
+type PaymentGateway interface {
+	CreateOrder(amount int64, currency, receipt string, notes map[string]string) (*PaymentOrder, error)
+	VerifyPayment(orderID, paymentID, signature string) bool
+}
+
+// GatewaySubscription is a recurring subscription held with the payment
+// gateway
+type GatewaySubscription struct {
+	ID         string
+	PlanID     string
+	Status     string     // Razorpay's: created, authenticated, active, pending, halted, cancelled, completed, expired
+	ShortURL   string     // Hosted page where the customer authorizes the first payment
+	CurrentEnd *time.Time // End of the paid billing period, once there is one
+}
+
+// SubscriptionGateway creates billing plans and recurring subscriptions on
+// them, and verifies the signature checkout returns once one is authorized
+type SubscriptionGateway interface {
+	CreatePlan(name string, amount int64, currency string) (string, error)
+	CreateSubscription(planID string, totalCount int, notes map[string]string) (*GatewaySubscription, error)
+	FetchSubscription(id string) (*GatewaySubscription, error)
+	CancelSubscription(id string, atCycleEnd bool) (*GatewaySubscription, error)
+	VerifySubscription(subscriptionID, paymentID, signature string) bool
+}
+
+// RazorpayGateway is the PaymentGateway and SubscriptionGateway backed by
+// Razorpay
+type RazorpayGateway struct {
+	client    *razorpay.Client
+	keySecret string
+}
+
+func NewRazorpayGateway(keyID, keySecret string) *RazorpayGateway {
+	return &RazorpayGateway{client: razorpay.NewClient(keyID, keySecret), keySecret: keySecret}
+}
+
+// SetHTTPClient replaces the client used to call Razorpay, e.g. with one
+// that replays recorded responses
+func (g *RazorpayGateway) SetHTTPClient(client *http.Client) {
+	g.client.Request.HTTPClient = client
+}
+
+// CreateOrder creates an order captured automatically once paid
+func (g *RazorpayGateway) CreateOrder(amount int64, currency, receipt string, notes map[string]string) (*PaymentOrder, error) {
+	orderNotes := make(map[string]interface{}, len(notes))
+	for k, v := range notes {
+		orderNotes[k] = v
+	}
+	body, err := g.client.Order.Create(map[string]interface{}{
+		"amount":          amount,
+		"currency":        currency,
+		"receipt":         receipt,
+		"payment_capture": 1,
+		"notes":           orderNotes,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SDK returns the decoded JSON object, and an empty one with no
+	// error for errors whose internal_error_code is BAD_REQUEST_ERROR
+	id, ok := body["id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("razorpay rejected the order")
+	}
+	order := &PaymentOrder{ID: id, Amount: amount, Currency: currency, Receipt: receipt}
+	order.Status, _ = body["status"].(string)
+	return order, nil
+}
+
+// CheckCredentials verifies the API keys by listing one order, without
+// creating anything
+func (g *RazorpayGateway) CheckCredentials() error {
+	body, err := g.client.Order.All(map[string]interface{}{"count": 1}, nil)
+	if err != nil {
+		return err
+	}
+	// Rejected keys come back as BAD_REQUEST_ERROR, i.e. an empty body
+	if _, ok := body["items"]; !ok {
+		return fmt.Errorf("razorpay rejected the credentials")
+	}
+	return nil
+}
+
+// VerifyPayment checks the signature Razorpay Checkout returns with a
+// payment
+func (g *RazorpayGateway) VerifyPayment(orderID, paymentID, signature string) bool {
+	expected := RazorpaySignature(orderID, paymentID, g.keySecret)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// RazorpaySignature computes the checkout signature of a payment:
+// hex(HMAC-SHA256(orderID + "|" + paymentID, keySecret))
+func RazorpaySignature(orderID, paymentID, keySecret string) string {
+	mac := hmac.New(sha256.New, []byte(keySecret))
+	mac.Write([]byte(orderID + "|" + paymentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreatePlan creates a monthly billing plan and returns its ID
+func (g *RazorpayGateway) CreatePlan(name string, amount int64, currency string) (string, error) {
+	body, err := g.client.Plan.Create(map[string]interface{}{
+		"period":   "monthly",
+		"interval": 1,
+		"item": map[string]interface{}{
+			"name":     name,
+			"amount":   amount,
+			"currency": currency,
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	id, ok := body["id"].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("razorpay rejected the plan")
+	}
+	return id, nil
+}
+
+// CreateSubscription subscribes to planID for totalCount billing cycles,
+// charged automatically once the customer authorizes the first payment
+func (g *RazorpayGateway) CreateSubscription(planID string, totalCount int, notes map[string]string) (*GatewaySubscription, error) {
+	subNotes := make(map[string]interface{}, len(notes))
+	for k, v := range notes {
+		subNotes[k] = v
+	}
+	body, err := g.client.Subscription.Create(map[string]interface{}{
+		"plan_id":         planID,
+		"total_count":     totalCount,
+		"customer_notify": 1,
+		"notes":           subNotes,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseRazorpaySubscription(body, "razorpay rejected the subscription")
+}
+
+// FetchSubscription returns a subscription's current state
+func (g *RazorpayGateway) FetchSubscription(id string) (*GatewaySubscription, error) {
+	body, err := g.client.Subscription.Fetch(id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseRazorpaySubscription(body, "razorpay subscription not found")
+}
+
+// CancelSubscription cancels a subscription, at the end of the paid period
+// when atCycleEnd is set and right away otherwise
+func (g *RazorpayGateway) CancelSubscription(id string, atCycleEnd bool) (*GatewaySubscription, error) {
+	cancelAtCycleEnd := 0
+	if atCycleEnd {
+		cancelAtCycleEnd = 1
+	}
+	body, err := g.client.Subscription.Cancel(id, map[string]interface{}{"cancel_at_cycle_end": cancelAtCycleEnd}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseRazorpaySubscription(body, "razorpay rejected the cancellation")
+}
+
+// VerifySubscription checks the signature Razorpay Checkout returns once a
+// subscription's first payment is authorized: the payment signature with
+// the payment ID first
+func (g *RazorpayGateway) VerifySubscription(subscriptionID, paymentID, signature string) bool {
+	expected := RazorpaySignature(paymentID, subscriptionID, g.keySecret)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// parseRazorpaySubscription reads a subscription entity. As with orders, an
+// empty body means Razorpay rejected the request.
+func parseRazorpaySubscription(body map[string]interface{}, rejected string) (*GatewaySubscription, error) {
+	id, ok := body["id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("%s", rejected)
+	}
+	sub := &GatewaySubscription{ID: id}
+	sub.PlanID, _ = body["plan_id"].(string)
+	sub.Status, _ = body["status"].(string)
+	sub.ShortURL, _ = body["short_url"].(string)
+	// Unix seconds, decoded as a JSON number; null until the first charge
+	if end, ok := body["current_end"].(float64); ok && end > 0 {
+		t := time.Unix(int64(end), 0)
+		sub.CurrentEnd = &t
+	}
+	return sub, nil
+}
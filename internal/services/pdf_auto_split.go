@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"brainy-pdf/internal/models"
+)
+
+// Auto-split limits
+const (
+	MaxAutoSplitPages = 500
+	// autoSplitHeaderLines are the top lines of a page read as its header
+	autoSplitHeaderLines = 6
+	// autoSplitStartScore is the layout evidence needed to start a document
+	autoSplitStartScore = 2
+)
+
+// ErrInvalidAutoSplit wraps PDFs that auto-split cannot work on
+var ErrInvalidAutoSplit = errors.New("invalid auto-split")
+
+var (
+	// pageMarker matches "Page 2 of 3" and "Page 2/3"
+	pageMarker = regexp.MustCompile(`(?i)\bpage\s+(\d{1,4})\s*(?:of|/)\s*(\d{1,4})\b`)
+	// documentTitle matches a header line naming a kind of document
+	documentTitle = regexp.MustCompile(`(?i)^(?:tax\s+|commercial\s+|proforma\s+|pro-forma\s+)?(invoice|receipt|statement|credit\s+note|debit\s+note|purchase\s+order|quotation|quote|bill|delivery\s+note|remittance\s+advice|packing\s+slip)\b`)
+	// documentNumber matches "Invoice No: INV-1042", "Receipt #88123"
+	documentNumber = regexp.MustCompile(`(?i)\b(?:invoice|receipt|order|statement|document|ref|reference|inv)\s*(?:no\.?|number|num\.?|#)\s*[:#.]?\s*([A-Z0-9][A-Z0-9\-/]{2,})`)
+	isoDate        = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	numericDate    = regexp.MustCompile(`\b(\d{1,2})[/.\-](\d{1,2})[/.\-](\d{4})\b`)
+	dayMonthDate   = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?,?\s+(\d{4})\b`)
+	monthDayDate   = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	// companySuffix marks a line as a company name
+	companySuffix = regexp.MustCompile(`(?i)\b(ltd|limited|inc|llc|llp|gmbh|corp|corporation|co|company|plc|pvt|s\.?a|b\.?v|ag)\.?$`)
+)
+
+// DocumentPage is the text and layout of one page, as used to find where
+// documents start
+type DocumentPage struct {
+	Page   int
+	Header []string // Top lines, first to last
+	Text   string
+	// Marker is the "Page x of y" printed on the page, zero when absent
+	Marker [2]int
+	Title  string
+	Number string
+	Date   string
+	Vendor string
+}
+
+// HasText reports whether the page has a text layer
+func (p DocumentPage) HasText() bool {
+	return strings.TrimSpace(p.Text) != ""
+}
+
+// ReadDocumentPages reads the text and header of every page. Pages without
+// a text layer come back empty, so scanned stacks need OCR first.
+func (s *PDFService) ReadDocumentPages(ctx context.Context, in PDFReader) ([]DocumentPage, error) {
+	texts, total, err := s.PageLineTexts(ctx, in, MaxAutoSplitPages)
+	if err != nil {
+		return nil, err
+	}
+	if total > MaxAutoSplitPages {
+		return nil, fmt.Errorf("%w: at most %d pages can be split automatically", ErrInvalidAutoSplit, MaxAutoSplitPages)
+	}
+
+	pages := make([]DocumentPage, len(texts))
+	for i, text := range texts {
+		pages[i] = newDocumentPage(i+1, text)
+	}
+	return pages, nil
+}
+
+// DocumentType names the kind of document a PDF is, such as "invoice" or
+// "receipt", from the title at the top of its first page. It returns ""
+// when the page has no such title or no text layer.
+func (s *PDFService) DocumentType(ctx context.Context, in PDFReader) (string, error) {
+	texts, _, err := s.PageLineTexts(ctx, in, 1)
+	if err != nil || len(texts) == 0 {
+		return "", err
+	}
+	return newDocumentPage(1, texts[0]).Title, nil
+}
+
+// newDocumentPage describes one page from its text, one line per row
+func newDocumentPage(number int, text string) DocumentPage {
+	page := DocumentPage{Page: number, Text: text}
+	if text != "" {
+		page.Header = strings.Split(text, "\n")
+		if len(page.Header) > autoSplitHeaderLines {
+			page.Header = page.Header[:autoSplitHeaderLines]
+		}
+	}
+	describePage(&page)
+	return page
+}
+
+// describePage fills in the clues read from a page's text
+func describePage(page *DocumentPage) {
+	if m := pageMarker.FindStringSubmatch(page.Text); m != nil {
+		current, _ := strconv.Atoi(m[1])
+		total, _ := strconv.Atoi(m[2])
+		if current >= 1 && current <= total {
+			page.Marker = [2]int{current, total}
+		}
+	}
+	for _, line := range page.Header {
+		if m := documentTitle.FindStringSubmatch(line); m != nil {
+			page.Title = strings.ToLower(strings.Join(strings.Fields(m[1]), " "))
+			break
+		}
+	}
+	if m := documentNumber.FindStringSubmatch(page.Text); m != nil {
+		page.Number = strings.ToUpper(m[1])
+	}
+	page.Date = documentDate(page.Text)
+	page.Vendor = documentVendor(page.Header)
+}
+
+// documentDate returns the date on a line mentioning one ("Invoice date:
+// 3 March 2024"), else the first date on the page
+func documentDate(text string) string {
+	var first string
+	for _, line := range strings.Split(text, "\n") {
+		date := findDate(line)
+		if date == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(line), "date") {
+			return date
+		}
+		if first == "" {
+			first = date
+		}
+	}
+	return first
+}
+
+// findDate returns the first date in s as YYYY-MM-DD, or as printed when
+// day and month can't be told apart (03/04/2024)
+func findDate(s string) string {
+	if m := isoDate.FindStringSubmatch(s); m != nil {
+		if date, ok := isoDateOf(m[1], m[2], m[3]); ok {
+			return date
+		}
+	}
+	if m := dayMonthDate.FindStringSubmatch(s); m != nil {
+		if date, ok := isoDateOf(m[3], monthNumber(m[2]), m[1]); ok {
+			return date
+		}
+	}
+	if m := monthDayDate.FindStringSubmatch(s); m != nil {
+		if date, ok := isoDateOf(m[3], monthNumber(m[1]), m[2]); ok {
+			return date
+		}
+	}
+	if m := numericDate.FindStringSubmatch(s); m != nil {
+		a, _ := strconv.Atoi(m[1])
+		b, _ := strconv.Atoi(m[2])
+		switch {
+		case a > 12:
+			if date, ok := isoDateOf(m[3], m[2], m[1]); ok {
+				return date
+			}
+		case b > 12:
+			if date, ok := isoDateOf(m[3], m[1], m[2]); ok {
+				return date
+			}
+		default:
+			return m[0]
+		}
+	}
+	return ""
+}
+
+// isoDateOf formats a valid calendar date as YYYY-MM-DD
+func isoDateOf(year, month, day string) (string, bool) {
+	t, err := time.Parse("2006-1-2", year+"-"+month+"-"+day)
+	if err != nil {
+		return "", false
+	}
+	return t.Format("2006-01-02"), true
+}
+
+func monthNumber(name string) string {
+	months := "janfebmaraprmayjunjulaugsepoctnovdec"
+	return strconv.Itoa(strings.Index(months, strings.ToLower(name[:3]))/3 + 1)
+}
+
+// documentVendor guesses who issued a document from its header: a line
+// ending in a company suffix, else the first line that reads like a name
+func documentVendor(header []string) string {
+	var candidate string
+	for _, line := range header {
+		if len([]rune(line)) < 2 || len([]rune(line)) > 60 || documentTitle.MatchString(line) ||
+			pageMarker.MatchString(line) || findDate(line) != "" || documentNumber.MatchString(line) {
+			continue
+		}
+		letters, numerals := 0, 0
+		for _, r := range line {
+			switch {
+			case unicode.IsLetter(r):
+				letters++
+			case unicode.IsDigit(r):
+				numerals++
+			}
+		}
+		if letters < 2 || numerals > letters || strings.Contains(line, "@") || strings.Contains(strings.ToLower(line), "www.") {
+			continue
+		}
+		if companySuffix.MatchString(line) {
+			return line
+		}
+		if candidate == "" {
+			candidate = line
+		}
+	}
+	return candidate
+}
+
+// DetectDocuments finds where documents start from layout alone. A
+// "Page 1 of n" marker always starts one and "Page 2 of n" never does;
+// otherwise a page starts a document when it has enough of a title at the
+// top, a document number other than the current one's and a header unlike
+// the current first page's. Pages without text stay with the document
+// before them.
+func DetectDocuments(pages []DocumentPage) []models.DetectedDocument {
+	if len(pages) == 0 {
+		return nil
+	}
+
+	starts := []models.DetectedDocument{{FirstPage: 1, Confidence: 1, Source: models.BoundarySourceLayout}}
+	first := pages[0]
+	for i := 1; i < len(pages); i++ {
+		page, prev := pages[i], pages[i-1]
+		var signals []string
+		confidence := 0.0
+		switch {
+		case page.Marker[0] == 1:
+			signals, confidence = []string{"page-marker"}, 0.9
+		case page.Marker[0] > 1, prev.Marker[0] > 0 && prev.Marker[0] < prev.Marker[1], !page.HasText():
+			// A continuation page
+		default:
+			similarity := headerSimilarity(page.Header, first.Header)
+			score := 0
+			// Later pages of a document often repeat its title and header
+			if page.Title != "" && (page.Title != first.Title || similarity < 0.5) {
+				score += 2
+				signals = append(signals, "title")
+			}
+			if page.Number != "" && first.Number != "" && page.Number != first.Number {
+				score += 2
+				signals = append(signals, "document-number")
+			}
+			if page.Number != "" && page.Number == first.Number {
+				score -= 2
+			}
+			if first.HasText() && len(page.Header) > 0 && similarity < 0.2 {
+				score++
+				signals = append(signals, "header-change")
+			}
+			if score < autoSplitStartScore {
+				signals = nil
+			}
+			confidence = 0.45 + 0.1*float64(score)
+		}
+		if len(signals) == 0 {
+			continue
+		}
+		if confidence > 0.95 {
+			confidence = 0.95
+		}
+		starts = append(starts, models.DetectedDocument{
+			FirstPage:  page.Page,
+			Confidence: confidence,
+			Source:     models.BoundarySourceLayout,
+			Signals:    signals,
+		})
+		first = page
+	}
+
+	return closeDocuments(starts, pages)
+}
+
+// closeDocuments ends each document where the next one starts and fills
+// in what the pages say about it
+func closeDocuments(docs []models.DetectedDocument, pages []DocumentPage) []models.DetectedDocument {
+	for i := range docs {
+		doc := &docs[i]
+		doc.LastPage = len(pages)
+		if i+1 < len(docs) {
+			doc.LastPage = docs[i+1].FirstPage - 1
+		}
+		doc.Range = strconv.Itoa(doc.FirstPage)
+		if doc.LastPage > doc.FirstPage {
+			doc.Range += "-" + strconv.Itoa(doc.LastPage)
+		}
+		for _, page := range pages[doc.FirstPage-1 : doc.LastPage] {
+			if doc.DocumentType == "" {
+				doc.DocumentType = page.Title
+			}
+			if doc.Vendor == "" {
+				doc.Vendor = page.Vendor
+			}
+			if doc.Date == "" {
+				doc.Date = page.Date
+			}
+		}
+	}
+	return docs
+}
+
+// headerSimilarity is the share of words two headers have in common,
+// ignoring case and numbers
+func headerSimilarity(a, b []string) float64 {
+	words := func(lines []string) map[string]bool {
+		set := map[string]bool{}
+		for _, w := range strings.Fields(runningKey(strings.Join(lines, " "))) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// AutoSplitRanges joins the documents' page ranges as Split takes them
+func AutoSplitRanges(docs []models.DetectedDocument) string {
+	ranges := make([]string, len(docs))
+	for i, doc := range docs {
+		ranges[i] = doc.Range
+	}
+	return strings.Join(ranges, ", ")
+}
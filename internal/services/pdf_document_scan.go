@@ -0,0 +1,681 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Document scanning turns phone photos of paper pages into a PDF. Each
+// photo is turned upright from its EXIF orientation, the page is found and
+// warped flat, its contrast is enhanced, and the pages are laid out in
+// order on paper-sized pages.
+
+// Enhancement modes for ScanDocument
+const (
+	ScanModeColor     = "color"
+	ScanModeGrayscale = "grayscale"
+	ScanModeBW        = "bw"
+)
+
+const (
+	// MaxScanPhotos bounds the photos in one scan
+	MaxScanPhotos = 50
+	// scanMaxPixels refuses photos too large to decode safely
+	scanMaxPixels = 50_000_000
+	// scanMaxSide caps the long side of a corrected page, about A4 at 300 DPI
+	scanMaxSide = 3508
+	// scanDetectSide is the long side of the small copy searched for the page
+	scanDetectSide = 600
+	// scanMinPageArea is the smallest share of a photo a detected page covers
+	scanMinPageArea = 0.2
+	// scanBWOffset is how much darker than its surroundings, in percent, a
+	// pixel must be to turn black in bw mode
+	scanBWOffset = 12
+	// scanJPEGQuality is the quality pages are stored at
+	scanJPEGQuality = 85
+)
+
+// ErrInvalidDocumentScan wraps document scan input validation failures
+var ErrInvalidDocumentScan = errors.New("invalid document scan")
+
+// scanPageSizes maps accepted page sizes to pdfcpu paper names
+var scanPageSizes = map[string]string{"a4": "A4", "letter": "Letter"}
+
+// DocumentScanOptions configures ScanDocument
+type DocumentScanOptions struct {
+	Mode     string // ScanModeColor, ScanModeGrayscale or ScanModeBW
+	PageSize string // "A4" or "Letter"; each page is fitted to it
+	// Corners optionally gives each photo's page corners, top-left first
+	// and clockwise. Photos without an entry, or with a nil one, are
+	// searched for the page.
+	Corners [][]models.ScanPoint
+}
+
+// NormalizeDocumentScanOptions validates opts for a scan of photos photos
+// and fills in the defaults: grayscale on A4
+func NormalizeDocumentScanOptions(opts DocumentScanOptions, photos int) (DocumentScanOptions, error) {
+	opts.Mode = strings.ToLower(strings.TrimSpace(opts.Mode))
+	if opts.Mode == "" {
+		opts.Mode = ScanModeGrayscale
+	}
+	switch opts.Mode {
+	case ScanModeColor, ScanModeGrayscale, ScanModeBW:
+	default:
+		return opts, fmt.Errorf("%w: mode must be %q, %q or %q", ErrInvalidDocumentScan, ScanModeColor, ScanModeGrayscale, ScanModeBW)
+	}
+
+	size := strings.ToLower(strings.TrimSpace(opts.PageSize))
+	if size == "" {
+		size = "a4"
+	}
+	name, ok := scanPageSizes[size]
+	if !ok {
+		return opts, fmt.Errorf("%w: pageSize must be \"A4\" or \"Letter\"", ErrInvalidDocumentScan)
+	}
+	opts.PageSize = name
+
+	if len(opts.Corners) > photos {
+		return opts, fmt.Errorf("%w: corners has %d entries for %d images", ErrInvalidDocumentScan, len(opts.Corners), photos)
+	}
+	for i, corners := range opts.Corners {
+		if corners != nil && len(corners) != 4 {
+			return opts, fmt.Errorf("%w: corners for image %d must have 4 points", ErrInvalidDocumentScan, i+1)
+		}
+	}
+	return opts, nil
+}
+
+// ScanDocument assembles photos (JPEG or PNG) into a scanned PDF, one page
+// per photo in order. progress, which may be nil, receives stage "process"
+// with the number of photos done.
+func (s *PDFService) ScanDocument(ctx context.Context, photos [][]byte, opts DocumentScanOptions, progress ProgressFunc) ([]byte, []models.ScannedPage, error) {
+	if len(photos) == 0 {
+		return nil, nil, fmt.Errorf("%w: at least one image is required", ErrInvalidDocumentScan)
+	}
+	if len(photos) > MaxScanPhotos {
+		return nil, nil, fmt.Errorf("%w: at most %d images can be scanned at once", ErrInvalidDocumentScan, MaxScanPhotos)
+	}
+
+	pages := make([]models.ScannedPage, len(photos))
+	encoded := make([]io.Reader, len(photos))
+	for i, data := range photos {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		var corners []models.ScanPoint
+		if i < len(opts.Corners) {
+			corners = opts.Corners[i]
+		}
+
+		page, jpg, err := scanPhoto(data, corners, opts.Mode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		page.Page = i + 1
+		pages[i] = page
+		encoded[i] = bytes.NewReader(jpg)
+
+		if progress != nil {
+			progress("process", int64(i+1), int64(len(photos)))
+		}
+	}
+
+	imp := pdfcpu.DefaultImportConfig()
+	imp.PageSize = opts.PageSize
+	imp.PageDim = types.PaperSize[opts.PageSize]
+	imp.UserDim = true
+	imp.Pos = types.Center
+	imp.Scale = 1
+
+	var out bytes.Buffer
+	if err := api.ImportImages(nil, &out, encoded, imp, s.getConfig()); err != nil {
+		return nil, nil, fmt.Errorf("failed to assemble PDF: %w", err)
+	}
+	return out.Bytes(), pages, nil
+}
+
+// scanPhoto turns one photo into a flattened, enhanced page encoded as JPEG
+func scanPhoto(data []byte, corners []models.ScanPoint, mode string) (models.ScannedPage, []byte, error) {
+	var page models.ScannedPage
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return page, nil, fmt.Errorf("%w: not a JPEG or PNG image", ErrInvalidDocumentScan)
+	}
+	if cfg.Width*cfg.Height > scanMaxPixels {
+		return page, nil, fmt.Errorf("%w: image exceeds %d megapixels", ErrInvalidDocumentScan, scanMaxPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return page, nil, fmt.Errorf("%w: failed to decode image: %v", ErrInvalidDocumentScan, err)
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	photo := orientPhoto(img, orientation)
+	w, h := float64(photo.Rect.Dx()), float64(photo.Rect.Dy())
+
+	switch {
+	case corners != nil:
+		for _, p := range corners {
+			if p.X < 0 || p.Y < 0 || p.X > w || p.Y > h {
+				return page, nil, fmt.Errorf("%w: corners must lie within the %dx%d image", ErrInvalidDocumentScan, int(w), int(h))
+			}
+		}
+		page.Correction = models.ScanCorrectionManual
+	default:
+		var ok bool
+		if corners, ok = findPage(photo); ok {
+			page.Correction = models.ScanCorrectionDetected
+		} else {
+			page.Correction = models.ScanCorrectionNone
+			corners = []models.ScanPoint{{X: 0, Y: 0}, {X: w, Y: 0}, {X: w, Y: h}, {X: 0, Y: h}}
+		}
+	}
+	page.Corners = corners
+
+	flat, err := warpPage(photo, corners)
+	if err != nil {
+		return page, nil, fmt.Errorf("%w: %v", ErrInvalidDocumentScan, err)
+	}
+	page.Width, page.Height = flat.Rect.Dx(), flat.Rect.Dy()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, enhancePage(flat, mode), &jpeg.Options{Quality: scanJPEGQuality}); err != nil {
+		return page, nil, fmt.Errorf("failed to encode page: %w", err)
+	}
+	return page, buf.Bytes(), nil
+}
+
+// jpegOrientation reads the EXIF orientation (1-8) of a JPEG, 1 when it
+// has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Image data starts; EXIF comes before it
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first IFD of a TIFF
+// structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for k := 0; k < entries; k++ {
+		e := ifd + 2 + 12*k
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientPhoto copies img into an RGBA image turned upright for an EXIF
+// orientation
+func orientPhoto(img image.Image, orientation int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := b.Dx(), b.Dy()
+	ow, oh := w, h
+	if orientation >= 5 {
+		ow, oh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+	for y := 0; y < oh; y++ {
+		for x := 0; x < ow; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = w - 1 - x
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sy = h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(out.Pix[out.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return out
+}
+
+// luma is the ITU-R BT.601 brightness of an RGB pixel
+func luma(r, g, b uint8) uint8 {
+	return uint8((299*int(r) + 587*int(g) + 114*int(b)) / 1000)
+}
+
+// findPage looks for a bright page against a darker background and
+// returns its corners, or false when nothing page-like stands out
+func findPage(photo *image.RGBA) ([]models.ScanPoint, bool) {
+	pw, ph := photo.Rect.Dx(), photo.Rect.Dy()
+	scale := math.Max(1, float64(max(pw, ph))/scanDetectSide)
+	w, h := int(float64(pw)/scale), int(float64(ph)/scale)
+	if w < 16 || h < 16 {
+		return nil, false
+	}
+
+	// Average each block of the photo into a small grayscale copy
+	small := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*ph/h, max((y+1)*ph/h, y*ph/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*pw/w, max((x+1)*pw/w, x*pw/w+1)
+			sum, n := 0, 0
+			for sy := y0; sy < y1; sy++ {
+				row := photo.Pix[photo.PixOffset(x0, sy):]
+				for sx := 0; sx < x1-x0; sx++ {
+					p := row[4*sx:]
+					sum += int(luma(p[0], p[1], p[2]))
+					n++
+				}
+			}
+			small[y*w+x] = uint8(sum / n)
+		}
+	}
+
+	blob := brightestBlob(small, w, h, otsuThreshold(small))
+	total := float64(w * h)
+	if float64(blob.area) < scanMinPageArea*total || float64(blob.area) > 0.97*total {
+		// Nothing page-sized, or the page already fills the photo
+		return nil, false
+	}
+
+	quad := []models.ScanPoint{
+		{X: float64(blob.corners[0][0]), Y: float64(blob.corners[0][1])},
+		{X: float64(blob.corners[1][0]), Y: float64(blob.corners[1][1])},
+		{X: float64(blob.corners[2][0]), Y: float64(blob.corners[2][1])},
+		{X: float64(blob.corners[3][0]), Y: float64(blob.corners[3][1])},
+	}
+	// The blob must fill the quadrilateral around it, as a page does
+	if !convexQuad(quad) || float64(blob.area) < 0.8*quadArea(quad) {
+		return nil, false
+	}
+
+	for i := range quad {
+		quad[i].X = math.Min((quad[i].X+0.5)*scale, float64(pw))
+		quad[i].Y = math.Min((quad[i].Y+0.5)*scale, float64(ph))
+	}
+	return quad, true
+}
+
+// pageBlob is a connected region of bright pixels with its extreme
+// corners: top-left, top-right, bottom-right and bottom-left
+type pageBlob struct {
+	area    int
+	corners [4][2]int
+}
+
+// brightestBlob returns the largest 4-connected region of pixels brighter
+// than threshold
+func brightestBlob(pix []uint8, w, h int, threshold uint8) pageBlob {
+	var best pageBlob
+	seen := make([]bool, len(pix))
+	var stack []int
+	for start := range pix {
+		if seen[start] || pix[start] <= threshold {
+			continue
+		}
+		blob := pageBlob{}
+		// Extremes of x+y and x-y give the corners of a roughly upright page
+		minSum, maxSum, maxDiff, minDiff := math.MaxInt, math.MinInt, math.MinInt, math.MaxInt
+
+		seen[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			blob.area++
+			if x+y < minSum {
+				minSum, blob.corners[0] = x+y, [2]int{x, y}
+			}
+			if x-y > maxDiff {
+				maxDiff, blob.corners[1] = x-y, [2]int{x, y}
+			}
+			if x+y > maxSum {
+				maxSum, blob.corners[2] = x+y, [2]int{x, y}
+			}
+			if x-y < minDiff {
+				minDiff, blob.corners[3] = x-y, [2]int{x, y}
+			}
+
+			for _, n := range [4]int{i - 1, i + 1, i - w, i + w} {
+				if n < 0 || n >= len(pix) || (n == i-1 && x == 0) || (n == i+1 && x == w-1) {
+					continue
+				}
+				if !seen[n] && pix[n] > threshold {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+		if blob.area > best.area {
+			best = blob
+		}
+	}
+	return best
+}
+
+// otsuThreshold picks the gray level best separating pix into dark and
+// bright pixels
+func otsuThreshold(pix []uint8) uint8 {
+	var hist [256]int
+	for _, v := range pix {
+		hist[v]++
+	}
+	var sum float64
+	for v, n := range hist {
+		sum += float64(v * n)
+	}
+
+	var sumDark, best float64
+	var dark int
+	var threshold uint8
+	for v := 0; v < 256; v++ {
+		dark += hist[v]
+		if dark == 0 {
+			continue
+		}
+		bright := len(pix) - dark
+		if bright == 0 {
+			break
+		}
+		sumDark += float64(v * hist[v])
+		meanDark := sumDark / float64(dark)
+		meanBright := (sum - sumDark) / float64(bright)
+		between := float64(dark) * float64(bright) * (meanDark - meanBright) * (meanDark - meanBright)
+		if between > best {
+			best, threshold = between, uint8(v)
+		}
+	}
+	return threshold
+}
+
+// convexQuad reports whether quad is convex and runs clockwise on screen,
+// as top-left, top-right, bottom-right, bottom-left do
+func convexQuad(quad []models.ScanPoint) bool {
+	for i := range quad {
+		a, b, c := quad[i], quad[(i+1)%4], quad[(i+2)%4]
+		if (b.X-a.X)*(c.Y-b.Y)-(b.Y-a.Y)*(c.X-b.X) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// quadArea is the area enclosed by quad
+func quadArea(quad []models.ScanPoint) float64 {
+	area := 0.0
+	for i := range quad {
+		a, b := quad[i], quad[(i+1)%4]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return math.Abs(area) / 2
+}
+
+// warpPage cuts the page outlined by quad out of photo and maps it onto an
+// upright rectangle, removing perspective and skew
+func warpPage(photo *image.RGBA, quad []models.ScanPoint) (*image.RGBA, error) {
+	if !convexQuad(quad) {
+		return nil, errors.New("corners must outline the page clockwise from its top-left corner")
+	}
+	dist := func(a, b models.ScanPoint) float64 { return math.Hypot(b.X-a.X, b.Y-a.Y) }
+	w := math.Max(dist(quad[0], quad[1]), dist(quad[3], quad[2]))
+	h := math.Max(dist(quad[0], quad[3]), dist(quad[1], quad[2]))
+	if long := math.Max(w, h); long > scanMaxSide {
+		w, h = w*scanMaxSide/long, h*scanMaxSide/long
+	}
+	ow, oh := int(math.Round(w)), int(math.Round(h))
+	if ow < 16 || oh < 16 {
+		return nil, errors.New("the page outlined by corners is too small")
+	}
+
+	m, err := homography(float64(ow), float64(oh), quad)
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+	for y := 0; y < oh; y++ {
+		fy := float64(y) + 0.5
+		for x := 0; x < ow; x++ {
+			fx := float64(x) + 0.5
+			d := m[6]*fx + m[7]*fy + 1
+			u := (m[0]*fx+m[1]*fy+m[2])/d - 0.5
+			v := (m[3]*fx+m[4]*fy+m[5])/d - 0.5
+			sampleBilinear(photo, u, v, out.Pix[out.PixOffset(x, y):][:4])
+		}
+	}
+	return out, nil
+}
+
+// homography solves for the projective transform taking the rectangle
+// (0,0)-(w,h) onto quad, returning the first 8 entries of its 3x3 matrix
+// (the last is 1)
+func homography(w, h float64, quad []models.ScanPoint) ([8]float64, error) {
+	var m [8]float64
+	rect := [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}}
+	var a [8][9]float64
+	for i, p := range rect {
+		x, y, u, v := p[0], p[1], quad[i].X, quad[i].Y
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Gauss-Jordan elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return m, errors.New("corners don't outline a page")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := 0; r < 8; r++ {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	for i := range m {
+		m[i] = a[i][8] / a[i][i]
+	}
+	return m, nil
+}
+
+// sampleBilinear writes the color of img at (u, v), interpolated between
+// the four nearest pixels and clamped to the edges, to dst
+func sampleBilinear(img *image.RGBA, u, v float64, dst []uint8) {
+	maxX, maxY := float64(img.Rect.Dx()-1), float64(img.Rect.Dy()-1)
+	u = math.Max(0, math.Min(u, maxX))
+	v = math.Max(0, math.Min(v, maxY))
+	x0, y0 := int(u), int(v)
+	x1, y1 := min(x0+1, int(maxX)), min(y0+1, int(maxY))
+	fx, fy := u-float64(x0), v-float64(y0)
+
+	p00 := img.Pix[img.PixOffset(x0, y0):]
+	p10 := img.Pix[img.PixOffset(x1, y0):]
+	p01 := img.Pix[img.PixOffset(x0, y1):]
+	p11 := img.Pix[img.PixOffset(x1, y1):]
+	for c := 0; c < 3; c++ {
+		top := float64(p00[c])*(1-fx) + float64(p10[c])*fx
+		bottom := float64(p01[c])*(1-fx) + float64(p11[c])*fx
+		dst[c] = uint8(top*(1-fy) + bottom*fy + 0.5)
+	}
+	dst[3] = 0xFF
+}
+
+// enhancePage stretches the page's levels so paper turns white and ink
+// dark, then converts it to mode: color, grayscale or black and white
+func enhancePage(page *image.RGBA, mode string) image.Image {
+	gray := image.NewGray(page.Rect)
+	var hist [256]int
+	for i := range gray.Pix {
+		p := page.Pix[4*i:]
+		l := luma(p[0], p[1], p[2])
+		gray.Pix[i] = l
+		hist[l]++
+	}
+
+	// Most of a page is paper, so the 95th percentile is the paper's shade
+	levels := stretchLevels(percentile(hist, len(gray.Pix), 0.01), percentile(hist, len(gray.Pix), 0.95))
+	switch mode {
+	case ScanModeColor:
+		for i := 0; i < len(page.Pix); i += 4 {
+			page.Pix[i] = levels[page.Pix[i]]
+			page.Pix[i+1] = levels[page.Pix[i+1]]
+			page.Pix[i+2] = levels[page.Pix[i+2]]
+		}
+		return page
+	case ScanModeBW:
+		for i, v := range gray.Pix {
+			gray.Pix[i] = levels[v]
+		}
+		binarize(gray)
+		return gray
+	default:
+		for i, v := range gray.Pix {
+			gray.Pix[i] = levels[v]
+		}
+		return gray
+	}
+}
+
+// percentile returns the gray level below which share of the n pixels in
+// hist fall
+func percentile(hist [256]int, n int, share float64) uint8 {
+	target := int(share * float64(n))
+	count := 0
+	for v, c := range hist {
+		count += c
+		if count > target {
+			return uint8(v)
+		}
+	}
+	return 255
+}
+
+// stretchLevels maps lo to black and hi to white, linearly between. Pages
+// with too little contrast to stretch are left as they are.
+func stretchLevels(lo, hi uint8) [256]uint8 {
+	var levels [256]uint8
+	for v := range levels {
+		switch {
+		case int(hi)-int(lo) < 32:
+			levels[v] = uint8(v)
+		case v <= int(lo):
+			levels[v] = 0
+		case v >= int(hi):
+			levels[v] = 255
+		default:
+			levels[v] = uint8((v - int(lo)) * 255 / (int(hi) - int(lo)))
+		}
+	}
+	return levels
+}
+
+// binarize turns a grayscale page black and white, comparing each pixel
+// with the mean of its neighbourhood so shadows and uneven light don't
+// swallow the text
+func binarize(g *image.Gray) {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	integral := make([]uint32, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row uint32
+		for x := 0; x < w; x++ {
+			row += uint32(g.Pix[y*g.Stride+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	r := max(max(w, h)/32, 4)
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-r, 0), min(y+r+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-r, 0), min(x+r+1, w)
+			count := uint64((x1 - x0) * (y1 - y0))
+			sum := uint64(integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0])
+			i := y*g.Stride + x
+			if uint64(g.Pix[i])*count*100 < sum*(100-scanBWOffset) {
+				g.Pix[i] = 0
+			} else {
+				g.Pix[i] = 255
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"brainy-pdf/internal/assets"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var (
+	installFontsOnce sync.Once
+	installFontsErr  error
+)
+
+// installEmbeddedFonts installs the fonts embedded in the binary into
+// pdfcpu's user font directory, so StandardFonts can name them. pdfcpu
+// installs fonts from files, so they pass through a temp dir.
+func installEmbeddedFonts() error {
+	installFontsOnce.Do(func() {
+		// Creates pdfcpu's config dir and sets font.UserFontDir
+		model.NewDefaultConfiguration()
+
+		dir, err := os.MkdirTemp("", "brainy-pdf-fonts-")
+		if err != nil {
+			installFontsErr = fmt.Errorf("failed to create font dir: %w", err)
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		for name, data := range assets.Fonts() {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				installFontsErr = fmt.Errorf("failed to write font %s: %w", name, err)
+				return
+			}
+			if err := font.InstallTrueTypeFont(font.UserFontDir, path); err != nil {
+				installFontsErr = fmt.Errorf("failed to install font %s: %w", name, err)
+				return
+			}
+		}
+		installFontsErr = font.LoadUserFonts()
+	})
+	return installFontsErr
+}
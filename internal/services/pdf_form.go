@@ -0,0 +1,492 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Form errors surfaced to handlers
+var (
+	ErrInvalidForm = errors.New("invalid form data")
+	ErrNoForm      = errors.New("pdf has no form fields")
+)
+
+// annotHidden is the annotation flag of widgets that are never shown or
+// printed
+const annotHidden = 1 << 1
+
+// readForm reads a PDF and exports its AcroForm, nil when it has none
+func (s *PDFService) readForm(in PDFReader, cmd model.CommandMode) (*model.Context, *form.Form, error) {
+	conf := s.getConfig()
+	conf.Cmd = cmd
+	pdfCtx, _, _, _, err := api.ReadValidateAndOptimize(readSeeker(in), conf, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return nil, nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+	if pdfCtx.Form == nil {
+		return pdfCtx, nil, nil
+	}
+	group, ok, err := form.ExportForm(pdfCtx.XRefTable, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read form: %w", err)
+	}
+	if !ok || len(group.Forms) == 0 {
+		return pdfCtx, nil, nil
+	}
+	return pdfCtx, &group.Forms[0], nil
+}
+
+// FormFields lists a PDF's AcroForm fields with their current values,
+// ordered by page. PDFs without a form have none.
+func (s *PDFService) FormFields(ctx context.Context, in PDFReader) ([]models.FormField, error) {
+	_, f, err := s.readForm(in, model.EXPORTFORMFIELDS)
+	if err != nil {
+		return nil, err
+	}
+	fields := []models.FormField{}
+	if f == nil {
+		return fields, nil
+	}
+
+	for _, t := range f.TextFields {
+		fields = append(fields, models.FormField{ID: t.ID, Name: t.Name, Type: models.FormFieldText, Pages: t.Pages, Value: t.Value, Multiline: t.Multiline, Locked: t.Locked})
+	}
+	for _, d := range f.DateFields {
+		fields = append(fields, models.FormField{ID: d.ID, Name: d.Name, Type: models.FormFieldDate, Pages: d.Pages, Value: d.Value, Format: d.Format, Locked: d.Locked})
+	}
+	for _, cb := range f.CheckBoxes {
+		fields = append(fields, models.FormField{ID: cb.ID, Name: cb.Name, Type: models.FormFieldCheckBox, Pages: cb.Pages, Value: cb.Value, Locked: cb.Locked})
+	}
+	for _, r := range f.RadioButtonGroups {
+		fields = append(fields, models.FormField{ID: r.ID, Name: r.Name, Type: models.FormFieldRadio, Pages: r.Pages, Value: r.Value, Options: r.Options, Locked: r.Locked})
+	}
+	for _, cb := range f.ComboBoxes {
+		fields = append(fields, models.FormField{ID: cb.ID, Name: cb.Name, Type: models.FormFieldComboBox, Pages: cb.Pages, Value: cb.Value, Options: cb.Options, Editable: cb.Editable, Locked: cb.Locked})
+	}
+	for _, lb := range f.ListBoxes {
+		values := lb.Values
+		if values == nil {
+			values = []string{}
+		}
+		fields = append(fields, models.FormField{ID: lb.ID, Name: lb.Name, Type: models.FormFieldListBox, Pages: lb.Pages, Value: values, Options: lb.Options, Multi: lb.Multi, Locked: lb.Locked})
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return firstPage(fields[i].Pages) < firstPage(fields[j].Pages)
+	})
+	return fields, nil
+}
+
+func firstPage(pages []int) int {
+	if len(pages) == 0 {
+		return math.MaxInt
+	}
+	return pages[0]
+}
+
+// FillForm sets a PDF's form fields from values, keyed by field name or ID:
+// strings for text, date, radio and combo fields, booleans for checkboxes
+// and a string or list of strings for list boxes. Every key must match a
+// field and every choice one of its options. It returns the keys set.
+func (s *PDFService) FillForm(ctx context.Context, in PDFReader, values map[string]interface{}) ([]byte, []string, error) {
+	if len(values) == 0 {
+		return nil, nil, fmt.Errorf("%w: no field values given", ErrInvalidForm)
+	}
+	_, f, err := s.readForm(in, model.EXPORTFORMFIELDS)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f == nil {
+		return nil, nil, ErrNoForm
+	}
+
+	used := map[string]bool{}
+	lookup := func(id, name string) (interface{}, string, bool) {
+		for _, key := range []string{name, id} {
+			if v, ok := values[key]; ok && key != "" {
+				used[key] = true
+				return v, key, true
+			}
+		}
+		return nil, "", false
+	}
+
+	for _, t := range f.TextFields {
+		if v, key, ok := lookup(t.ID, t.Name); ok {
+			if t.Value, err = formText(key, v); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, d := range f.DateFields {
+		if v, key, ok := lookup(d.ID, d.Name); ok {
+			if d.Value, err = formText(key, v); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, cb := range f.CheckBoxes {
+		if v, key, ok := lookup(cb.ID, cb.Name); ok {
+			if cb.Value, err = formBool(key, v); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, r := range f.RadioButtonGroups {
+		if v, key, ok := lookup(r.ID, r.Name); ok {
+			if r.Value, err = formChoice(key, v, r.Options); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, cb := range f.ComboBoxes {
+		if v, key, ok := lookup(cb.ID, cb.Name); ok {
+			options := cb.Options
+			if cb.Editable {
+				options = nil
+			}
+			if cb.Value, err = formChoice(key, v, options); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	for _, lb := range f.ListBoxes {
+		if v, key, ok := lookup(lb.ID, lb.Name); ok {
+			if lb.Values, err = formChoices(key, v, lb.Options, lb.Multi); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var unknown []string
+	for key := range values {
+		if !used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, nil, fmt.Errorf("%w: no field named %s", ErrInvalidForm, strings.Join(unknown, ", "))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	filled, err := json.Marshal(form.FormGroup{Forms: []form.Form{*f}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode form data: %w", err)
+	}
+	var out bytes.Buffer
+	err = api.FillForm(readSeeker(in), bytes.NewReader(filled), &out, s.getConfig())
+	if errors.Is(err, api.ErrNoFormFieldsAffected) {
+		// The values were already set
+		out.Reset()
+		io.Copy(&out, readSeeker(in))
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to fill form: %w", err)
+	}
+
+	keys := make([]string, 0, len(used))
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return out.Bytes(), keys, nil
+}
+
+// formText converts a value for a text or date field
+func formText(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("%w: %s takes text", ErrInvalidForm, key)
+}
+
+// formBool converts a value for a checkbox
+func formBool(key string, v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "on", "1", "x":
+			return true, nil
+		case "false", "no", "off", "0", "":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: %s is a checkbox and takes true or false", ErrInvalidForm, key)
+}
+
+// formChoice converts a value for a radio or combo field; empty options
+// allow any text
+func formChoice(key string, v interface{}, options []string) (string, error) {
+	s, err := formText(key, v)
+	if err != nil || s == "" || len(options) == 0 {
+		return s, err
+	}
+	for _, o := range options {
+		if o == s {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s must be one of %s", ErrInvalidForm, key, strings.Join(options, ", "))
+}
+
+// formChoices converts a value for a list box
+func formChoices(key string, v interface{}, options []string, multi bool) ([]string, error) {
+	var values []string
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			s, err := formChoice(key, item, options)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+	default:
+		s, err := formChoice(key, v, options)
+		if err != nil {
+			return nil, err
+		}
+		if s != "" {
+			values = []string{s}
+		}
+	}
+	if len(values) > 1 && !multi {
+		return nil, fmt.Errorf("%w: %s takes a single choice", ErrInvalidForm, key)
+	}
+	return values, nil
+}
+
+// FlattenForm burns a PDF's form fields into its pages: each widget's
+// appearance is drawn into the page content, then the widgets and the form
+// are removed, so the values show everywhere but can no longer be edited.
+// It returns how many widgets were flattened.
+func (s *PDFService) FlattenForm(ctx context.Context, in PDFReader) ([]byte, int, error) {
+	pdfCtx, f, err := s.readForm(in, model.LOCKFORMFIELDS)
+	if err != nil {
+		return nil, 0, err
+	}
+	if f == nil {
+		return nil, 0, ErrNoForm
+	}
+
+	// Locking has pdfcpu build the appearances of fields that lack one,
+	// such as unlocked combo boxes; fields it can't render keep their own
+	if _, err := form.LockFormFields(pdfCtx, nil); err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare form appearances: %w", err)
+	}
+
+	flattened := 0
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		n, err := flattenPage(pdfCtx, pageNr, flattened)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to flatten page %d: %w", pageNr, err)
+		}
+		flattened += n
+	}
+
+	root, err := pdfCtx.Catalog()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	root.Delete("AcroForm")
+	pdfCtx.Form = nil
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, 0, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), flattened, nil
+}
+
+// flattenPage draws the appearances of a page's widgets into its content
+// and removes the widgets. XObject names are numbered from n so each page's
+// names are distinct from those on pages sharing its resources.
+func flattenPage(pdfCtx *model.Context, pageNr, n int) (int, error) {
+	page, _, _, err := pdfCtx.PageDict(pageNr, false)
+	if err != nil || page == nil {
+		return 0, err
+	}
+	annots, err := pdfCtx.DereferenceArray(page["Annots"])
+	if err != nil || len(annots) == 0 {
+		return 0, err
+	}
+
+	var draw bytes.Buffer
+	xobjects := types.Dict{}
+	kept := types.Array{}
+	flattened := 0
+	for _, o := range annots {
+		annot, err := pdfCtx.DereferenceDict(o)
+		if err != nil || annot == nil || annot.NameEntry("Subtype") == nil || *annot.NameEntry("Subtype") != "Widget" {
+			kept = append(kept, o)
+			continue
+		}
+		flattened++
+
+		if flags := annot.IntEntry("F"); flags != nil && *flags&annotHidden != 0 {
+			continue
+		}
+		ref, sd, err := widgetAppearance(pdfCtx, annot)
+		if err != nil {
+			return 0, err
+		}
+		if sd == nil {
+			continue
+		}
+		rect, err := pdfCtx.RectForArray(annot.ArrayEntry("Rect"))
+		if err != nil || rect == nil {
+			continue
+		}
+		cm, ok := appearanceTransform(pdfCtx, sd, rect)
+		if !ok {
+			continue
+		}
+
+		name := fmt.Sprintf("BPFlat%d", n+flattened)
+		xobjects[name] = *ref
+		fmt.Fprintf(&draw, "q %s cm /%s Do Q\n", cm, name)
+	}
+	if flattened == 0 {
+		return 0, nil
+	}
+
+	if len(kept) > 0 {
+		page.Update("Annots", kept)
+	} else {
+		page.Delete("Annots")
+	}
+	if draw.Len() == 0 {
+		return flattened, nil
+	}
+
+	resources := inheritedResources(pdfCtx, page)
+	if resources == nil {
+		resources = types.Dict{}
+		page.Update("Resources", resources)
+	}
+	existing, err := pdfCtx.DereferenceDict(resources["XObject"])
+	if err != nil {
+		return 0, err
+	}
+	if existing == nil {
+		existing = types.Dict{}
+		resources.Update("XObject", existing)
+	}
+	for name, ref := range xobjects {
+		existing.Update(name, ref)
+	}
+
+	content, err := pageContent(pdfCtx, page)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("q\n")
+	buf.Write(content)
+	buf.WriteString("Q\n")
+	buf.Write(draw.Bytes())
+	return flattened, setPageContent(pdfCtx, page, buf.Bytes())
+}
+
+// widgetAppearance returns a widget's normal appearance stream: the one
+// for its current state for checkboxes and radio buttons. Widgets without
+// one return nil.
+func widgetAppearance(pdfCtx *model.Context, annot types.Dict) (*types.IndirectRef, *types.StreamDict, error) {
+	ap, err := pdfCtx.DereferenceDict(annot["AP"])
+	if err != nil || ap == nil {
+		return nil, nil, err
+	}
+	o := ap["N"]
+	if states, err := pdfCtx.DereferenceDict(o); err == nil && states != nil {
+		state := annot.NameEntry("AS")
+		if state == nil {
+			return nil, nil, nil
+		}
+		// Unchecked boxes often have no "Off" appearance: nothing is drawn
+		if o = states[*state]; o == nil {
+			return nil, nil, nil
+		}
+	}
+
+	sd, _, err := pdfCtx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil, nil, err
+	}
+	ref, ok := o.(types.IndirectRef)
+	if !ok {
+		r, err := pdfCtx.IndRefForNewObject(*sd)
+		if err != nil {
+			return nil, nil, err
+		}
+		ref = *r
+	}
+	// Appearances are form XObjects, but the keys saying so are optional
+	sd.Dict.Update("Type", types.Name("XObject"))
+	sd.Dict.Update("Subtype", types.Name("Form"))
+	return &ref, sd, nil
+}
+
+// appearanceTransform returns the cm operands that place an appearance on
+// its widget's rectangle: its bounding box, transformed by its matrix,
+// scaled and moved onto rect
+func appearanceTransform(pdfCtx *model.Context, sd *types.StreamDict, rect *types.Rectangle) (string, bool) {
+	bbox, err := pdfCtx.RectForArray(sd.Dict.ArrayEntry("BBox"))
+	if err != nil || bbox == nil {
+		return "", false
+	}
+	m := matrix{1, 0, 0, 1, 0, 0}
+	if arr := sd.Dict.ArrayEntry("Matrix"); len(arr) == 6 {
+		for i, o := range arr {
+			if m[i], err = pdfCtx.DereferenceNumber(o); err != nil {
+				return "", false
+			}
+		}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{{bbox.LL.X, bbox.LL.Y}, {bbox.UR.X, bbox.LL.Y}, {bbox.LL.X, bbox.UR.Y}, {bbox.UR.X, bbox.UR.Y}} {
+		x, y := m.apply(p[0], p[1])
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return "", false
+	}
+
+	a := rect.Width() / (maxX - minX)
+	d := rect.Height() / (maxY - minY)
+	e := rect.LL.X - minX*a
+	f := rect.LL.Y - minY*d
+	return fmt.Sprintf("%s 0 0 %s %s %s", formatNumber(a), formatNumber(d), formatNumber(e), formatNumber(f)), true
+}
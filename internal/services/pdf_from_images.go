@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Page orientations for ImagesToPDF
+const (
+	ImagePagePortrait  = "portrait"
+	ImagePageLandscape = "landscape"
+	ImagePageAuto      = "auto" // each page follows its image
+)
+
+const (
+	// ImagePageFit sizes each page to its image, one point per pixel
+	ImagePageFit = "Fit"
+	// MaxImagesToPDF bounds the images assembled into one PDF
+	MaxImagesToPDF = 200
+	// imagesMaxMargin bounds the margin, two inches
+	imagesMaxMargin = 144
+	// imagesJPEGQuality is the quality a JPEG is stored at when it has to
+	// be turned upright
+	imagesJPEGQuality = 92
+)
+
+// ErrInvalidImagesToPDF wraps images-to-PDF input validation failures
+var ErrInvalidImagesToPDF = errors.New("invalid images")
+
+// imagePageSizes maps accepted page sizes to pdfcpu paper names
+var imagePageSizes = map[string]string{"a4": "A4", "letter": "Letter", "legal": "Legal", "fit": ImagePageFit}
+
+// ImagesToPDFOptions configures ImagesToPDF
+type ImagesToPDFOptions struct {
+	PageSize    string  // "A4", "Letter", "Legal" or ImagePageFit
+	Orientation string  // ImagePagePortrait, ImagePageLandscape or ImagePageAuto
+	Margin      float64 // white space round each image, in points
+}
+
+// NormalizeImagesToPDFOptions validates opts and fills in the defaults:
+// A4, oriented to each image, without a margin
+func NormalizeImagesToPDFOptions(opts ImagesToPDFOptions) (ImagesToPDFOptions, error) {
+	size := strings.ToLower(strings.TrimSpace(opts.PageSize))
+	if size == "" {
+		size = "a4"
+	}
+	name, ok := imagePageSizes[size]
+	if !ok {
+		return opts, fmt.Errorf("%w: pageSize must be \"A4\", \"Letter\", \"Legal\" or \"Fit\"", ErrInvalidImagesToPDF)
+	}
+	opts.PageSize = name
+
+	opts.Orientation = strings.ToLower(strings.TrimSpace(opts.Orientation))
+	if opts.Orientation == "" {
+		opts.Orientation = ImagePageAuto
+	}
+	switch opts.Orientation {
+	case ImagePagePortrait, ImagePageLandscape, ImagePageAuto:
+	default:
+		return opts, fmt.Errorf("%w: orientation must be %q, %q or %q", ErrInvalidImagesToPDF, ImagePagePortrait, ImagePageLandscape, ImagePageAuto)
+	}
+
+	if math.IsNaN(opts.Margin) || opts.Margin < 0 || opts.Margin > imagesMaxMargin {
+		return opts, fmt.Errorf("%w: margin must be between 0 and %d points", ErrInvalidImagesToPDF, imagesMaxMargin)
+	}
+	return opts, nil
+}
+
+// ImagesToPDF assembles images (JPEG or PNG) into a PDF, one page per
+// image in order, each scaled to fit inside the margin and centered.
+// Unlike ScanDocument the images are not corrected: JPEGs are embedded as
+// they are, unless their EXIF orientation says they have to be turned.
+func (s *PDFService) ImagesToPDF(ctx context.Context, images [][]byte, opts ImagesToPDFOptions) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: at least one image is required", ErrInvalidImagesToPDF)
+	}
+	if len(images) > MaxImagesToPDF {
+		return nil, fmt.Errorf("%w: at most %d images can be assembled at once", ErrInvalidImagesToPDF, MaxImagesToPDF)
+	}
+
+	conf := s.getConfig()
+	conf.Cmd = model.IMPORTIMAGES
+	pdfCtx, err := pdfcpu.CreateContextWithXRefTable(conf, types.PaperSize["A4"])
+	if err != nil {
+		return nil, err
+	}
+	pagesIndRef, err := pdfCtx.Pages()
+	if err != nil {
+		return nil, err
+	}
+	pagesDict, err := pdfCtx.DereferenceDict(*pagesIndRef)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, data := range images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		upright, width, height, err := uprightImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+
+		imp := pdfcpu.DefaultImportConfig()
+		imp.PageDim = imagePageDim(width, height, opts)
+		imp.UserDim = true
+		imp.Pos = types.Center
+		imp.ScaleAbs = true
+		imp.Scale = math.Min((imp.PageDim.Width-2*opts.Margin)/width, (imp.PageDim.Height-2*opts.Margin)/height)
+
+		pageIndRef, err := pdfcpu.NewPageForImage(pdfCtx.XRefTable, bytes.NewReader(upright), pagesIndRef, imp)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: failed to add page: %w", i+1, err)
+		}
+		if err := model.AppendPageTree(pageIndRef, 1, pagesDict); err != nil {
+			return nil, err
+		}
+		pdfCtx.PageCount++
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// uprightImage checks that data is a JPEG or PNG and returns it as
+// displayed, with its size in pixels. JPEGs with an EXIF rotation are
+// turned and re-encoded, since PDF readers ignore EXIF.
+func uprightImage(data []byte) ([]byte, float64, float64, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, 0, 0, fmt.Errorf("%w: not a JPEG or PNG image", ErrInvalidImagesToPDF)
+	}
+	if cfg.Width*cfg.Height > scanMaxPixels {
+		return nil, 0, 0, fmt.Errorf("%w: image exceeds %d megapixels", ErrInvalidImagesToPDF, scanMaxPixels/1_000_000)
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	if orientation <= 1 || orientation > 8 {
+		return data, float64(cfg.Width), float64(cfg.Height), nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: failed to decode image: %v", ErrInvalidImagesToPDF, err)
+	}
+	photo := orientPhoto(img, orientation)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, photo, &jpeg.Options{Quality: imagesJPEGQuality}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), float64(photo.Rect.Dx()), float64(photo.Rect.Dy()), nil
+}
+
+// imagePageDim is the page for an image of width x height pixels
+func imagePageDim(width, height float64, opts ImagesToPDFOptions) *types.Dim {
+	if opts.PageSize == ImagePageFit {
+		return &types.Dim{Width: width + 2*opts.Margin, Height: height + 2*opts.Margin}
+	}
+	dim := *types.PaperSize[opts.PageSize]
+	landscape := opts.Orientation == ImagePageLandscape || (opts.Orientation == ImagePageAuto && width > height)
+	if landscape {
+		dim.Width, dim.Height = dim.Height, dim.Width
+	}
+	return &dim
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// PDFReader is a document an operation reads in place: an upload, which
+// the multipart parser keeps in memory or spools to disk
+// (io.NewSectionReader over the multipart.File), a stored file opened from
+// storage, or bytes from an earlier step (bytes.NewReader). Operations read
+// it through ReadAt, so they never take a copy of the whole input.
+type PDFReader interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// readSeeker returns a reader over the whole of in, independent of other
+// reads of it, for APIs that read and seek
+func readSeeker(in PDFReader) *io.SectionReader {
+	return io.NewSectionReader(in, 0, in.Size())
+}
+
+// writeInput copies in to path in scratch, for pdfcpu's file-to-file
+// operations, within the job's temp quota
+func writeInput(scratch *Scratch, path string, in PDFReader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(scratch.Writer(f), readSeeker(in))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write input: %w", err)
+	}
+	return nil
+}
+
+// readInput loads in, for the few operations that hand back their input
+// unchanged
+func readInput(in PDFReader) ([]byte, error) {
+	return io.ReadAll(readSeeker(in))
+}
+
+// countInReader counts the occurrences of pattern in in, reading it a chunk
+// at a time
+func countInReader(in PDFReader, pattern []byte) int {
+	const chunk = 1 << 20
+	buf := make([]byte, len(pattern)-1+chunk)
+	count, carry := 0, 0
+	for off := int64(0); off < in.Size(); off += chunk {
+		n, err := in.ReadAt(buf[carry:carry+chunk], off)
+		window := buf[:carry+n]
+		count += bytes.Count(window, pattern)
+		if err != nil {
+			break
+		}
+		// A tail too short to hold a match carries a match across the
+		// boundary into the next window
+		carry = min(len(pattern)-1, len(window))
+		copy(buf, window[len(window)-carry:])
+	}
+	return count
+}
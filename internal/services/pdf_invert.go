@@ -0,0 +1,473 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Image handling for InvertColors
+const (
+	InvertImagesKeep = "keep"
+	InvertImagesDim  = "dim"
+)
+
+// ErrInvalidInvert wraps invert option validation failures
+var ErrInvalidInvert = errors.New("invalid invert options")
+
+// dimGState names the graphics state added to resources to dim images
+const dimGState = "BPDFDim"
+
+// dimOpacity is the opacity of the background-colored veil over images
+const dimOpacity = 0.35
+
+type InvertOptions struct {
+	Background string // Hex page color; white maps to it
+	Foreground string // Hex color black text becomes
+	Images     string // InvertImagesKeep or InvertImagesDim
+}
+
+// NormalizeInvertOptions validates opts and fills in the defaults: a
+// #1E1E1E page, #E6E6E6 text and images kept as they are
+func NormalizeInvertOptions(opts InvertOptions) (InvertOptions, error) {
+	if opts.Background == "" {
+		opts.Background = "#1E1E1E"
+	}
+	if !ValidHexColor(opts.Background) {
+		return opts, fmt.Errorf("%w: background must be a hex value like #1E1E1E", ErrInvalidInvert)
+	}
+	if opts.Foreground == "" {
+		opts.Foreground = "#E6E6E6"
+	}
+	if !ValidHexColor(opts.Foreground) {
+		return opts, fmt.Errorf("%w: foreground must be a hex value like #E6E6E6", ErrInvalidInvert)
+	}
+
+	opts.Images = strings.ToLower(strings.TrimSpace(opts.Images))
+	if opts.Images == "" {
+		opts.Images = InvertImagesKeep
+	}
+	if opts.Images != InvertImagesKeep && opts.Images != InvertImagesDim {
+		return opts, fmt.Errorf("%w: images must be %q or %q", ErrInvalidInvert, InvertImagesKeep, InvertImagesDim)
+	}
+	return opts, nil
+}
+
+// InvertColors produces a dark-mode version of a PDF by rewriting the
+// colors in its content streams rather than rasterizing, so text stays
+// selectable. Each page gets a Background fill, and every gray, RGB or
+// CMYK color has its lightness inverted with the hue kept, then is scaled
+// between Background (white) and Foreground (black), so red stays red and
+// black text becomes light. Images, shadings and patterns keep their
+// colors; with Images set to dim, images are veiled in the background
+// color. Annotations are not changed.
+func (s *PDFService) InvertColors(ctx context.Context, in PDFReader, opts InvertOptions) ([]byte, error) {
+	opts, err := NormalizeInvertOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, fmt.Errorf("failed to validate pdf: %w", err)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+
+	r := &colorRemapper{
+		pdfCtx: pdfCtx,
+		bg:     hexRGB(opts.Background),
+		fg:     hexRGB(opts.Foreground),
+		dim:    opts.Images == InvertImagesDim,
+		forms:  map[int]bool{},
+	}
+	for i := 1; i <= pdfCtx.PageCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := r.invertPage(i); err != nil {
+			return nil, fmt.Errorf("failed to invert page %d: %w", i, err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// colorRemapper rewrites color operators in page and form content
+type colorRemapper struct {
+	pdfCtx *model.Context
+	bg, fg [3]float64
+	dim    bool
+	forms  map[int]bool // Object numbers of forms already rewritten
+}
+
+func (r *colorRemapper) invertPage(pageNr int) error {
+	page, _, inherited, err := r.pdfCtx.PageDict(pageNr, false)
+	if err != nil {
+		return err
+	}
+	if page == nil || inherited == nil {
+		return nil
+	}
+
+	resources := inheritedResources(r.pdfCtx, page)
+	if resources == nil {
+		resources = types.Dict{}
+		page.Update("Resources", resources)
+	}
+	if r.dim {
+		r.addDimState(resources)
+	}
+
+	content, err := pageContent(r.pdfCtx, page)
+	if err != nil {
+		return err
+	}
+	remapped, err := r.remap(content, resources)
+	if err != nil {
+		return err
+	}
+
+	box := inherited.MediaBox
+	if inherited.CropBox != nil {
+		box = inherited.CropBox
+	}
+	var buf bytes.Buffer
+	if box != nil {
+		fmt.Fprintf(&buf, "q %s rg %.2f %.2f %.2f %.2f re f Q\n",
+			r.color(r.bg), box.LL.X, box.LL.Y, box.Width(), box.Height())
+	}
+	// The initial color is black, which must turn light as well
+	black := r.mapRGB(0, 0, 0)
+	fmt.Fprintf(&buf, "%s rg %s RG\n", r.color(black), r.color(black))
+	buf.Write(remapped)
+	return setPageContent(r.pdfCtx, page, buf.Bytes())
+}
+
+// addDimState adds the translucent graphics state used to veil images
+func (r *colorRemapper) addDimState(resources types.Dict) {
+	states, err := r.pdfCtx.DereferenceDict(resources["ExtGState"])
+	if err != nil || states == nil {
+		states = types.Dict{}
+		resources.Update("ExtGState", states)
+	}
+	states.Update(dimGState, types.Dict{
+		"Type": types.Name("ExtGState"),
+		"ca":   types.Float(dimOpacity),
+	})
+}
+
+// colorState is the number of components of the current fill and stroke
+// color spaces, or 0 when they aren't gray, RGB or CMYK
+type colorState struct {
+	fill, stroke int
+}
+
+// remap rewrites the color operators of a content stream that uses the
+// given resources, and the forms it paints
+func (r *colorRemapper) remap(content []byte, resources types.Dict) ([]byte, error) {
+	var out bytes.Buffer
+	state := colorState{fill: 1, stroke: 1}
+	var stack []colorState
+
+	for _, op := range contentOperations(content) {
+		replacement := ""
+		switch op.operator {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "g", "rg", "k", "G", "RG", "K":
+			stroke := op.operator == strings.ToUpper(op.operator)
+			n := map[string]int{"g": 1, "rg": 3, "k": 4}[strings.ToLower(op.operator)]
+			if stroke {
+				state.stroke = n
+			} else {
+				state.fill = n
+			}
+			replacement = r.setColor(op.operands, n, stroke)
+		case "cs", "CS":
+			n := 0
+			if len(op.operands) == 1 {
+				n = r.components(op.operands[0], resources)
+			}
+			stroke := op.operator == "CS"
+			if stroke {
+				state.stroke = n
+			} else {
+				state.fill = n
+			}
+			if n > 0 {
+				// Setting a color space resets the color to black
+				black := r.color(r.mapRGB(0, 0, 0))
+				suffix := " rg"
+				if stroke {
+					suffix = " RG"
+				}
+				replacement = string(content[op.start:op.end]) + "\n" + black + suffix
+			}
+		case "sc", "scn":
+			replacement = r.setColor(op.operands, state.fill, false)
+		case "SC", "SCN":
+			replacement = r.setColor(op.operands, state.stroke, true)
+		case "Do":
+			if len(op.operands) != 1 {
+				break
+			}
+			isImage, err := r.paintXObject(op.operands[0], resources)
+			if err != nil {
+				return nil, err
+			}
+			if isImage && r.dim {
+				replacement = string(content[op.start:op.end]) + "\n" + r.veil()
+			}
+		case "EI":
+			if r.dim {
+				replacement = string(content[op.start:op.end]) + "\n" + r.veil()
+			}
+		}
+
+		if replacement != "" {
+			out.WriteString(replacement)
+		} else {
+			out.Write(content[op.start:op.end])
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// setColor returns the RGB operator replacing a color given as n gray,
+// RGB or CMYK components, or "" to keep the original
+func (r *colorRemapper) setColor(operands []string, n int, stroke bool) string {
+	if n == 0 || len(operands) != n {
+		return ""
+	}
+	v := make([]float64, n)
+	for i, o := range operands {
+		f, err := strconv.ParseFloat(o, 64)
+		if err != nil {
+			return ""
+		}
+		v[i] = math.Max(0, math.Min(1, f))
+	}
+
+	var mapped [3]float64
+	switch n {
+	case 1:
+		mapped = r.mapRGB(v[0], v[0], v[0])
+	case 3:
+		mapped = r.mapRGB(v[0], v[1], v[2])
+	case 4:
+		k := 1 - v[3]
+		mapped = r.mapRGB((1-v[0])*k, (1-v[1])*k, (1-v[2])*k)
+	default:
+		return ""
+	}
+	if stroke {
+		return r.color(mapped) + " RG"
+	}
+	return r.color(mapped) + " rg"
+}
+
+// components returns the number of components of a gray, RGB or CMYK color
+// space named in content, or 0 for other spaces
+func (r *colorRemapper) components(name string, resources types.Dict) int {
+	switch name {
+	case "/DeviceGray", "/G":
+		return 1
+	case "/DeviceRGB", "/RGB":
+		return 3
+	case "/DeviceCMYK", "/CMYK":
+		return 4
+	}
+	spaces, err := r.pdfCtx.DereferenceDict(resources["ColorSpace"])
+	if err != nil || spaces == nil {
+		return 0
+	}
+	o, err := r.pdfCtx.Dereference(spaces[strings.TrimPrefix(name, "/")])
+	if err != nil || o == nil {
+		return 0
+	}
+	if n, ok := o.(types.Name); ok {
+		return r.components("/"+string(n), nil)
+	}
+	a, ok := o.(types.Array)
+	if !ok || len(a) < 2 {
+		return 0
+	}
+	family, _ := a[0].(types.Name)
+	switch family {
+	case "CalGray":
+		return 1
+	case "CalRGB":
+		return 3
+	case "ICCBased":
+		sd, _, err := r.pdfCtx.DereferenceStreamDict(a[1])
+		if err != nil || sd == nil {
+			return 0
+		}
+		if n := sd.IntEntry("N"); n != nil && (*n == 1 || *n == 3 || *n == 4) {
+			return *n
+		}
+	}
+	return 0
+}
+
+// paintXObject rewrites the form named by a Do operator, once per form,
+// and reports whether the name is an image instead
+func (r *colorRemapper) paintXObject(name string, resources types.Dict) (bool, error) {
+	xobjects, err := r.pdfCtx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return false, nil
+	}
+	o := xobjects[strings.TrimPrefix(name, "/")]
+	sd, _, err := r.pdfCtx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return false, nil
+	}
+	subtype := sd.Subtype()
+	if subtype != nil && *subtype == "Image" {
+		return true, nil
+	}
+	ref, ok := o.(types.IndirectRef)
+	if subtype == nil || *subtype != "Form" || !ok || r.forms[ref.ObjectNumber.Value()] {
+		return false, nil
+	}
+	r.forms[ref.ObjectNumber.Value()] = true
+
+	// Forms without resources of their own use the page's
+	formResources, _ := r.pdfCtx.DereferenceDict(sd.Dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	} else if r.dim {
+		r.addDimState(formResources)
+	}
+	if err := sd.Decode(); err != nil {
+		return false, err
+	}
+	remapped, err := r.remap(sd.Content, formResources)
+	if err != nil {
+		return false, err
+	}
+	if err := replaceStreamContent(sd, remapped); err != nil {
+		return false, err
+	}
+	r.pdfCtx.Table[ref.ObjectNumber.Value()].Object = *sd
+	return false, nil
+}
+
+// veil paints the background color over the unit square an image was
+// just drawn in
+func (r *colorRemapper) veil() string {
+	return fmt.Sprintf("q /%s gs %s rg 0 0 1 1 re f Q", dimGState, r.color(r.bg))
+}
+
+// mapRGB inverts the lightness of a color, keeping its hue, and scales the
+// result so white lands on the background and black on the foreground
+func (r *colorRemapper) mapRGB(red, green, blue float64) [3]float64 {
+	h, s, l := rgbToHSL(red, green, blue)
+	red, green, blue = hslToRGB(h, s, 1-l)
+	c := [3]float64{red, green, blue}
+	var out [3]float64
+	for i := range c {
+		out[i] = r.bg[i] + (r.fg[i]-r.bg[i])*c[i]
+	}
+	return out
+}
+
+func (r *colorRemapper) color(c [3]float64) string {
+	parts := make([]string, len(c))
+	for i, v := range c {
+		parts[i] = strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
+
+// hexRGB converts a validated #RRGGBB color to components in 0..1
+func hexRGB(hex string) [3]float64 {
+	v, _ := strconv.ParseUint(hex[1:], 16, 32)
+	return [3]float64{
+		float64(v>>16&0xff) / 255,
+		float64(v>>8&0xff) / 255,
+		float64(v&0xff) / 255,
+	}
+}
+
+func rgbToHSL(r, g, b float64) (h, s, l float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h / 6, s, l
+}
+
+func hslToRGB(h, s, l float64) (r, g, b float64) {
+	if s == 0 {
+		return l, l, l
+	}
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	return hueToRGB(p, q, h+1.0/3), hueToRGB(p, q, h), hueToRGB(p, q, h-1.0/3)
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t++
+	}
+	if t > 1 {
+		t--
+	}
+	switch {
+	case t < 1.0/6:
+		return p + (q-p)*6*t
+	case t < 0.5:
+		return q
+	case t < 2.0/3:
+		return p + (q-p)*(2.0/3-t)*6
+	}
+	return p
+}
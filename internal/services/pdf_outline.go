@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"brainy-pdf/internal/models"
+	"github.com/ledongthuc/pdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// Outline limits
+const (
+	DefaultMaxHeadings = 200
+	MaxHeadings        = 1000
+	maxHeadingLevel    = 3
+)
+
+// ErrInvalidOutline wraps outline option validation failures
+var ErrInvalidOutline = errors.New("invalid outline")
+
+var (
+	// numberedHeading matches "2 Scope", "2.1 Scope" and "2.1. Scope"
+	numberedHeading = regexp.MustCompile(`^(\d{1,2}(?:\.\d{1,2}){0,3})\.?\s+\p{L}`)
+	// namedHeading matches "Chapter 3", "PART II", "Appendix A: ..."
+	namedHeading = regexp.MustCompile(`(?i)^(chapter|part|section|appendix|article|schedule)\s+([0-9]+|[ivxlc]+|[a-z])\b`)
+	digits       = regexp.MustCompile(`\d+`)
+)
+
+// OutlineOptions configures DetectHeadings
+type OutlineOptions struct {
+	MaxHeadings int // DefaultMaxHeadings when 0
+}
+
+// DocumentOutline is the structure inferred from a document's text
+type DocumentOutline struct {
+	PageCount         int
+	BodyFontSize      float64
+	ExistingBookmarks int
+	Headings          []models.Heading
+}
+
+// outlineLine is a text line with the typography used to rank it
+type outlineLine struct {
+	text     string
+	page     int
+	y        float64
+	fontSize float64
+	bold     bool
+}
+
+// DetectHeadings infers section headings from font sizes, weights and
+// numbering patterns. Lines repeated on most pages are treated as running
+// headers or footers and skipped. Pages without a text layer contribute
+// nothing, so scanned documents need OCR first.
+func (s *PDFService) DetectHeadings(ctx context.Context, in PDFReader, opts OutlineOptions) (*DocumentOutline, error) {
+	if opts.MaxHeadings == 0 {
+		opts.MaxHeadings = DefaultMaxHeadings
+	}
+	if opts.MaxHeadings < 1 || opts.MaxHeadings > MaxHeadings {
+		return nil, fmt.Errorf("%w: maxHeadings must be between 1 and %d", ErrInvalidOutline, MaxHeadings)
+	}
+
+	f, err := pdf.NewReader(in, in.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+
+	outline := &DocumentOutline{PageCount: f.NumPage(), Headings: []models.Heading{}}
+	if bms, err := api.Bookmarks(readSeeker(in), s.getConfig()); err == nil {
+		outline.ExistingBookmarks = countBookmarks(bms)
+	}
+
+	var lines []outlineLine
+	sizeRunes := map[float64]int{}
+	for pageNum := 1; pageNum <= outline.PageCount; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := f.Page(pageNum)
+		if p.V.IsNull() {
+			continue
+		}
+		pageLines, err := PageTextLines(p)
+		if err != nil {
+			continue
+		}
+		for _, tl := range pageLines {
+			line := outlineLine{text: strings.Join(strings.Fields(string(tl.Text)), " "), page: pageNum}
+			if line.text == "" {
+				continue
+			}
+			for _, g := range tl.Glyphs {
+				size := math.Round(g.FontSize*2) / 2
+				sizeRunes[size] += len([]rune(g.S))
+				line.fontSize = math.Max(line.fontSize, size)
+				line.y = g.Y
+				if isBoldFont(g.Font) {
+					line.bold = true
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// The body size is the one most text is set in
+	for size, n := range sizeRunes {
+		if n > sizeRunes[outline.BodyFontSize] || (n == sizeRunes[outline.BodyFontSize] && size < outline.BodyFontSize) {
+			outline.BodyFontSize = size
+		}
+	}
+
+	repeated := runningLines(lines, outline.PageCount)
+	var candidates []models.Heading
+	for _, line := range lines {
+		if repeated[runningKey(line.text)] {
+			continue
+		}
+		h, ok := classifyHeading(line, outline.BodyFontSize)
+		if !ok {
+			continue
+		}
+		// Titles wrapped over several lines arrive as consecutive candidates
+		if n := len(candidates); n > 0 {
+			prev := &candidates[n-1]
+			if prev.Page == h.Page && prev.FontSize == h.FontSize && prev.Source == h.Source &&
+				prev.Source == models.HeadingSourceFont && prev.Y-h.Y <= h.FontSize*1.6 && len([]rune(prev.Title)) < 120 {
+				prev.Title += " " + h.Title
+				continue
+			}
+		}
+		candidates = append(candidates, h)
+	}
+
+	assignHeadingLevels(candidates, outline.BodyFontSize)
+	if len(candidates) > opts.MaxHeadings {
+		candidates = candidates[:opts.MaxHeadings]
+	}
+	outline.Headings = append(outline.Headings, candidates...)
+	return outline, nil
+}
+
+// classifyHeading decides whether line looks like a heading. Levels are
+// only set for numbered headings here; assignHeadingLevels does the rest.
+func classifyHeading(line outlineLine, bodySize float64) (models.Heading, bool) {
+	h := models.Heading{Title: line.text, Page: line.page, Y: math.Round(line.y*100) / 100, FontSize: line.fontSize}
+
+	runes := []rune(line.text)
+	words := len(strings.Fields(line.text))
+	letters := 0
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < 2 || len(runes) > 120 || words > 16 {
+		return h, false
+	}
+
+	if m := numberedHeading.FindStringSubmatch(line.text); m != nil && words <= 12 && (line.bold || line.fontSize > bodySize) {
+		h.Source = models.HeadingSourcePattern
+		h.Level = strings.Count(m[1], ".") + 1
+		return h, true
+	}
+	if namedHeading.MatchString(line.text) && words <= 12 {
+		h.Source = models.HeadingSourcePattern
+		h.Level = 1
+		return h, true
+	}
+	if bodySize > 0 && line.fontSize >= bodySize*1.15 {
+		h.Source = models.HeadingSourceFont
+		return h, true
+	}
+	if line.bold && line.fontSize >= bodySize && words <= 10 && !strings.ContainsAny(string(runes[len(runes)-1]), ".,;") {
+		h.Source = models.HeadingSourceFont
+		return h, true
+	}
+	return h, false
+}
+
+// assignHeadingLevels ranks the distinct heading font sizes, largest
+// first, and gives bold body-size headings the level below them. Levels
+// from numbering are kept.
+func assignHeadingLevels(headings []models.Heading, bodySize float64) {
+	var sizes []float64
+	seen := map[float64]bool{}
+	for _, h := range headings {
+		if h.Source == models.HeadingSourceFont && h.FontSize > bodySize && !seen[h.FontSize] {
+			seen[h.FontSize] = true
+			sizes = append(sizes, h.FontSize)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+
+	for i := range headings {
+		h := &headings[i]
+		if h.Level == 0 {
+			h.Level = len(sizes) + 1
+			for rank, size := range sizes {
+				if h.FontSize >= size {
+					h.Level = rank + 1
+					break
+				}
+			}
+		}
+		if h.Level > maxHeadingLevel {
+			h.Level = maxHeadingLevel
+		}
+	}
+}
+
+// runningLines returns the keys of lines found on more than half of the
+// pages of documents long enough for running headers to be telling
+func runningLines(lines []outlineLine, pageCount int) map[string]bool {
+	repeated := map[string]bool{}
+	if pageCount < 4 {
+		return repeated
+	}
+	pages := map[string]map[int]bool{}
+	for _, line := range lines {
+		key := runningKey(line.text)
+		if pages[key] == nil {
+			pages[key] = map[int]bool{}
+		}
+		pages[key][line.page] = true
+	}
+	for key, on := range pages {
+		if len(on)*2 > pageCount {
+			repeated[key] = true
+		}
+	}
+	return repeated
+}
+
+// runningKey ignores case and numbers, so "Page 3 of 40" matches across pages
+func runningKey(text string) string {
+	return digits.ReplaceAllString(strings.ToLower(text), "#")
+}
+
+func isBoldFont(name string) bool {
+	name = strings.ToLower(name)
+	for _, weight := range []string{"bold", "black", "heavy", "semibold", "demi"} {
+		if strings.Contains(name, weight) {
+			return true
+		}
+	}
+	return false
+}
+
+func countBookmarks(bms []pdfcpu.Bookmark) int {
+	n := len(bms)
+	for _, bm := range bms {
+		n += countBookmarks(bm.Kids)
+	}
+	return n
+}
+
+// AddOutline replaces the document's bookmarks with the given headings,
+// nested by level
+func (s *PDFService) AddOutline(ctx context.Context, in PDFReader, headings []models.Heading) ([]byte, error) {
+	if len(headings) == 0 {
+		return nil, fmt.Errorf("%w: no headings to write", ErrInvalidOutline)
+	}
+
+	type node struct {
+		bm    pdfcpu.Bookmark
+		level int
+		kids  []*node
+	}
+	root := &node{}
+	stack := []*node{root}
+	for _, h := range headings {
+		for len(stack) > 1 && stack[len(stack)-1].level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		n := &node{bm: pdfcpu.Bookmark{Title: h.Title, PageFrom: h.Page}, level: h.Level}
+		parent := stack[len(stack)-1]
+		parent.kids = append(parent.kids, n)
+		stack = append(stack, n)
+	}
+
+	var build func(nodes []*node) []pdfcpu.Bookmark
+	build = func(nodes []*node) []pdfcpu.Bookmark {
+		bms := make([]pdfcpu.Bookmark, len(nodes))
+		for i, n := range nodes {
+			bms[i] = n.bm
+			bms[i].Kids = build(n.kids)
+		}
+		return bms
+	}
+
+	var out bytes.Buffer
+	if err := api.AddBookmarks(readSeeker(in), &out, build(root.kids), true, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("failed to write bookmarks: %w", err)
+	}
+	return out.Bytes(), nil
+}
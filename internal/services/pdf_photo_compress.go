@@ -0,0 +1,392 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Photo mode compresses the PDFs standard compression barely touches:
+// scans and photo-heavy documents, where most bytes are JPEG images. Each
+// JPEG is downsampled to a target resolution at the largest size it is
+// drawn and re-encoded at a lower quality.
+
+// Compression modes
+const (
+	CompressModeStandard = "standard"
+	CompressModePhoto    = "photo"
+)
+
+const (
+	// imageHeavyShare is the share of a file's bytes in images from which
+	// it counts as image-heavy
+	imageHeavyShare = 0.6
+	// photoMinGain is how much smaller, as a share, a recompressed image
+	// must be to replace the original
+	photoMinGain = 0.1
+	// photoDownsampleSlack leaves images alone that are only slightly
+	// above the target resolution
+	photoDownsampleSlack = 1.1
+)
+
+// ErrInvalidCompress wraps compress option validation failures
+var ErrInvalidCompress = errors.New("invalid compress options")
+
+// photoJPEGQuality maps compress quality levels to photo mode's JPEG quality
+var photoJPEGQuality = map[string]int{"low": 50, "medium": 65, "high": 80}
+
+type PhotoCompressOptions struct {
+	DPI         int // Target resolution where each image is drawn largest
+	JPEGQuality int // 1-100
+}
+
+// NormalizePhotoCompressOptions builds photo mode options from a compress
+// quality level (low, medium or high) and an optional target dpi, 150 by
+// default
+func NormalizePhotoCompressOptions(quality, dpi string) (PhotoCompressOptions, error) {
+	opts := PhotoCompressOptions{DPI: 150, JPEGQuality: photoJPEGQuality[quality]}
+	if opts.JPEGQuality == 0 {
+		opts.JPEGQuality = photoJPEGQuality["medium"]
+	}
+	if dpi = strings.TrimSpace(dpi); dpi != "" {
+		n, err := strconv.Atoi(dpi)
+		if err != nil || n < 72 || n > 600 {
+			return opts, fmt.Errorf("%w: dpi must be between 72 and 600", ErrInvalidCompress)
+		}
+		opts.DPI = n
+	}
+	return opts, nil
+}
+
+// ImageProfile measures the image data in a PDF of size bytes
+func (s *PDFService) ImageProfile(rs io.ReadSeeker, size int64) (*models.ImageProfile, error) {
+	pdfCtx, err := api.ReadContext(rs, s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	return imageProfile(pdfCtx, size), nil
+}
+
+// imageProfile totals the stored bytes of pdfCtx's image XObjects.
+// Photo mode is suggested for image-heavy files whose images are mostly
+// JPEGs, the only images it recompresses.
+func imageProfile(pdfCtx *model.Context, size int64) *models.ImageProfile {
+	p := &models.ImageProfile{TotalBytes: size, SuggestedMode: CompressModeStandard}
+	for _, objNr := range imageObjects(pdfCtx) {
+		sd := pdfCtx.XRefTable.Table[objNr].Object.(types.StreamDict)
+		n := streamSize(sd)
+		p.Images++
+		p.ImageBytes += n
+		if isDCTImage(sd) {
+			p.JPEGImages++
+			p.JPEGBytes += n
+		}
+	}
+	if size > 0 {
+		p.ImageShare = math.Min(1, math.Round(float64(p.ImageBytes)/float64(size)*1000)/1000)
+	}
+	p.ImageHeavy = p.ImageShare >= imageHeavyShare
+	if p.ImageHeavy && p.JPEGBytes*2 >= p.ImageBytes {
+		p.SuggestedMode = CompressModePhoto
+	}
+	return p
+}
+
+// CompressPhotos recompresses the JPEG images of a PDF of size bytes and
+// writes the result, also optimized as in standard mode, to w. With a nil
+// w nothing is written: the report estimates the savings of a real run.
+// progress, which may be nil, receives stage "process" per image.
+func (s *PDFService) CompressPhotos(ctx context.Context, rs io.ReadSeeker, size int64, w io.Writer, opts PhotoCompressOptions, progress ProgressFunc) (*models.ImageProfile, *models.PhotoCompression, error) {
+	pdfCtx, err := api.ReadContext(rs, s.getConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	profile := imageProfile(pdfCtx, size)
+	placements := imagePlacements(ctx, pdfCtx)
+
+	result := &models.PhotoCompression{DPI: opts.DPI, JPEGQuality: opts.JPEGQuality}
+	images := imageObjects(pdfCtx)
+	for i, objNr := range images {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		entry := pdfCtx.XRefTable.Table[objNr]
+		sd := entry.Object.(types.StreamDict)
+		before := streamSize(sd)
+		result.ImageBytesBefore += before
+
+		img := recompressJPEG(sd, placements[objNr], opts)
+		if img == nil {
+			result.ImagesSkipped++
+			result.ImageBytesAfter += before
+		} else {
+			result.ImagesRecompressed++
+			if img.downsampled {
+				result.ImagesDownsampled++
+			}
+			result.ImageBytesAfter += int64(len(img.data))
+			if w != nil {
+				entry.Object = img.replace(sd)
+			}
+		}
+		report(progress, "process", int64(i+1), int64(len(images)))
+	}
+	result.EstimatedSavings = result.ImageBytesBefore - result.ImageBytesAfter
+	if size > 0 {
+		result.EstimatedReduction = fmt.Sprintf("%.1f%%", float64(result.EstimatedSavings)/float64(size)*100)
+	}
+
+	if w == nil {
+		return profile, result, nil
+	}
+	if err := api.OptimizeContext(pdfCtx); err != nil {
+		return nil, nil, fmt.Errorf("compress failed: %w", err)
+	}
+	if err := api.WriteContext(pdfCtx, w); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return profile, result, nil
+}
+
+// imageObjects lists the object numbers of pdfCtx's image XObjects in order
+func imageObjects(pdfCtx *model.Context) []int {
+	var objNrs []int
+	for objNr, entry := range pdfCtx.XRefTable.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		if subtype := sd.Subtype(); subtype != nil && *subtype == "Image" {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	sort.Ints(objNrs)
+	return objNrs
+}
+
+// streamSize is the stored length of a stream
+func streamSize(sd types.StreamDict) int64 {
+	if sd.Raw == nil && sd.StreamLength != nil {
+		return *sd.StreamLength
+	}
+	return int64(len(sd.Raw))
+}
+
+// isDCTImage reports whether an image is stored as a plain JPEG
+func isDCTImage(sd types.StreamDict) bool {
+	return len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == "DCTDecode"
+}
+
+// imagePlacement is the largest size, in inches, an image is drawn at
+type imagePlacement struct {
+	width, height float64
+}
+
+// imagePlacements finds where each image XObject is drawn largest on any
+// page, keyed by object number. Images drawn only from patterns or
+// annotations aren't found and keep their resolution.
+func imagePlacements(ctx context.Context, pdfCtx *model.Context) map[int]imagePlacement {
+	placements := map[int]imagePlacement{}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return placements
+	}
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		if ctx.Err() != nil {
+			break
+		}
+		page, _, _, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil || page == nil {
+			continue
+		}
+		content, err := pageContent(pdfCtx, page)
+		if err != nil {
+			continue
+		}
+		walkPlacements(pdfCtx, content, inheritedResources(pdfCtx, page), identityMatrix, placements, 0)
+	}
+	return placements
+}
+
+// walkPlacements records the images a content stream draws, following
+// the forms it paints
+func walkPlacements(pdfCtx *model.Context, content []byte, resources types.Dict, ctm matrix, placements map[int]imagePlacement, depth int) {
+	var stack []matrix
+	for _, op := range contentOperations(content) {
+		switch op.operator {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := parseMatrix(op.operands); ok {
+				ctm = m.times(ctm)
+			}
+		case "Do":
+			if len(op.operands) != 1 {
+				continue
+			}
+			xobjects, err := pdfCtx.DereferenceDict(resources["XObject"])
+			if err != nil || xobjects == nil {
+				continue
+			}
+			ref, ok := xobjects[strings.TrimPrefix(op.operands[0], "/")].(types.IndirectRef)
+			if !ok {
+				continue
+			}
+			sd, _, err := pdfCtx.DereferenceStreamDict(ref)
+			if err != nil || sd == nil || sd.Subtype() == nil {
+				continue
+			}
+
+			switch *sd.Subtype() {
+			case "Image":
+				objNr := ref.ObjectNumber.Value()
+				p := placements[objNr]
+				p.width = math.Max(p.width, math.Hypot(ctm[0], ctm[1])/72)
+				p.height = math.Max(p.height, math.Hypot(ctm[2], ctm[3])/72)
+				placements[objNr] = p
+			case "Form":
+				if depth >= maxFormDepth {
+					continue
+				}
+				formCTM := ctm
+				if m, ok := formMatrix(pdfCtx, sd); ok {
+					formCTM = m.times(ctm)
+				}
+				formResources, _ := pdfCtx.DereferenceDict(sd.Dict["Resources"])
+				if formResources == nil {
+					formResources = resources
+				}
+				if err := sd.Decode(); err != nil {
+					continue
+				}
+				walkPlacements(pdfCtx, sd.Content, formResources, formCTM, placements, depth+1)
+			}
+		}
+	}
+}
+
+// recompressedImage is a JPEG image re-encoded by photo mode
+type recompressedImage struct {
+	data          []byte
+	width, height int
+	downsampled   bool
+}
+
+// recompressJPEG re-encodes a JPEG image at opts.JPEGQuality, downsampled
+// to opts.DPI where it's drawn largest. It returns nil for images it
+// leaves alone: other formats, CMYK JPEGs, and images that wouldn't get
+// noticeably smaller.
+func recompressJPEG(sd types.StreamDict, placement imagePlacement, opts PhotoCompressOptions) *recompressedImage {
+	if !isDCTImage(sd) || len(sd.Raw) == 0 {
+		return nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+	if err != nil {
+		return nil
+	}
+	switch img.(type) {
+	case *image.Gray, *image.YCbCr, *image.RGBA:
+	default:
+		// CMYK keeps its Adobe conventions untouched
+		return nil
+	}
+
+	b := img.Bounds()
+	out := &recompressedImage{width: b.Dx(), height: b.Dy()}
+	if placement.width > 0 && placement.height > 0 {
+		dpi := math.Min(float64(out.width)/placement.width, float64(out.height)/placement.height)
+		if dpi > float64(opts.DPI)*photoDownsampleSlack {
+			scale := float64(opts.DPI) / dpi
+			out.width = max(1, int(math.Round(float64(out.width)*scale)))
+			out.height = max(1, int(math.Round(float64(out.height)*scale)))
+			img = downsample(img, out.width, out.height)
+			out.downsampled = true
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.JPEGQuality}); err != nil {
+		return nil
+	}
+	if float64(buf.Len()) > float64(len(sd.Raw))*(1-photoMinGain) {
+		return nil
+	}
+	out.data = buf.Bytes()
+	return out
+}
+
+// replace returns sd holding the recompressed image. The color space is
+// kept: grayscale JPEGs stay grayscale and color ones color.
+func (r *recompressedImage) replace(sd types.StreamDict) types.StreamDict {
+	length := int64(len(r.data))
+	sd.Raw = r.data
+	sd.Content = nil
+	sd.StreamLength = &length
+	sd.StreamLengthObjNr = nil
+	sd.FilterPipeline = []types.PDFFilter{{Name: "DCTDecode"}}
+	sd.Update("Length", types.Integer(length))
+	sd.Update("Width", types.Integer(r.width))
+	sd.Update("Height", types.Integer(r.height))
+	delete(sd.Dict, "DecodeParms")
+	return sd
+}
+
+// downsample shrinks img to w x h, averaging the pixels each output pixel
+// covers. Grayscale images stay grayscale.
+func downsample(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	if gray, ok := img.(*image.Gray); ok {
+		out := image.NewGray(image.Rect(0, 0, w, h))
+		boxAverage(gray.Pix[gray.PixOffset(b.Min.X, b.Min.Y):], gray.Stride, 1, b.Dx(), b.Dy(), out.Pix, out.Stride, w, h)
+		return out
+	}
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	boxAverage(src.Pix, src.Stride, 4, b.Dx(), b.Dy(), out.Pix, out.Stride, w, h)
+	return out
+}
+
+// boxAverage resamples sw x sh pixels of channels bytes each onto a
+// smaller dw x dh grid
+func boxAverage(src []uint8, srcStride, channels, sw, sh int, dst []uint8, dstStride, dw, dh int) {
+	sums := make([]int, channels)
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			clear(sums)
+			for sy := y0; sy < y1; sy++ {
+				row := src[sy*srcStride+x0*channels : sy*srcStride+x1*channels]
+				for i, v := range row {
+					sums[i%channels] += int(v)
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			for ch, sum := range sums {
+				dst[y*dstStride+x*channels+ch] = uint8(sum / n)
+			}
+		}
+	}
+}
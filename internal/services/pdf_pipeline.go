@@ -0,0 +1,285 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+)
+
+// ErrInvalidPipeline wraps pipeline validation failures
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// MaxPipelineSteps bounds the operations of one pipeline
+const MaxPipelineSteps = 10
+
+// Operations a pipeline step can run
+const (
+	PipelineRotate      = "rotate"
+	PipelineCrop        = "crop"
+	PipelineWatermark   = "watermark"
+	PipelinePageNumbers = "page-numbers"
+	PipelineCompress    = "compress"
+	PipelineReorder     = "reorder"
+	PipelineRemove      = "remove"
+	PipelineExtract     = "extract"
+	PipelineSanitize    = "sanitize"
+)
+
+// PipelineStep is one operation of a pipeline. Parameters are named as in
+// the operation's own endpoint; watermarks are text only.
+type PipelineStep struct {
+	Operation string `json:"operation"`
+
+	// rotate, remove and extract
+	Pages     string         `json:"pages,omitempty"`
+	Angle     int            `json:"angle,omitempty"`
+	Rotations map[string]int `json:"rotations,omitempty"`
+	// reorder
+	Order []int `json:"order,omitempty"`
+	// compress
+	Quality string `json:"quality,omitempty"`
+	// crop
+	Top    float64 `json:"top,omitempty"`
+	Right  float64 `json:"right,omitempty"`
+	Bottom float64 `json:"bottom,omitempty"`
+	Left   float64 `json:"left,omitempty"`
+	// watermark and page-numbers
+	Position string `json:"position,omitempty"`
+	// watermark
+	Text     string  `json:"text,omitempty"`
+	Opacity  float64 `json:"opacity,omitempty"`
+	FontSize float64 `json:"fontSize,omitempty"`
+	Color    string  `json:"color,omitempty"`
+	Font     string  `json:"font,omitempty"`
+	Mode     string  `json:"mode,omitempty"`
+	Rotation float64 `json:"rotation,omitempty"`
+	Tile     bool    `json:"tile,omitempty"`
+	// page-numbers
+	Format      string `json:"format,omitempty"`
+	Style       string `json:"style,omitempty"`
+	StartFrom   int    `json:"startFrom,omitempty"`
+	FrontMatter int    `json:"frontMatter,omitempty"`
+	Skip        string `json:"skip,omitempty"`
+	Mirror      bool   `json:"mirror,omitempty"`
+
+	// Validated options, set by ParsePipeline
+	rotations   map[int]int
+	watermark   WatermarkOptions
+	pageNumbers PageNumberOptions
+}
+
+// ParsePipeline decodes and validates a JSON array of steps, e.g.
+// [{"operation": "rotate", "angle": 90}, {"operation": "compress"}].
+// Parameters a step leaves out are taken from prefs, when given, as in the
+// operation's own endpoint.
+func ParsePipeline(raw string, prefs *models.Preferences) ([]PipelineStep, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("%w: steps is required", ErrInvalidPipeline)
+	}
+	var steps []PipelineStep
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&steps); err != nil {
+		return nil, fmt.Errorf("%w: steps must be a JSON array of operations: %v", ErrInvalidPipeline, err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: no steps", ErrInvalidPipeline)
+	}
+	if len(steps) > MaxPipelineSteps {
+		return nil, fmt.Errorf("%w: at most %d steps", ErrInvalidPipeline, MaxPipelineSteps)
+	}
+
+	for i := range steps {
+		if prefs != nil {
+			steps[i].applyPreferences(prefs)
+		}
+		if err := steps[i].validate(); err != nil {
+			return nil, fmt.Errorf("%w: step %d (%s): %v", ErrInvalidPipeline, i+1, steps[i].Operation, err)
+		}
+	}
+	return steps, nil
+}
+
+// applyPreferences fills the parameters a step left out from the user's
+// defaults
+func (step *PipelineStep) applyPreferences(prefs *models.Preferences) {
+	fill := func(dst *string, value string) {
+		if *dst == "" {
+			*dst = value
+		}
+	}
+	switch step.Operation {
+	case PipelineCompress:
+		fill(&step.Quality, prefs.Compression.Quality)
+	case PipelineWatermark:
+		w := prefs.Watermark
+		fill(&step.Text, w.Text)
+		fill(&step.Position, w.Position)
+		fill(&step.Color, w.Color)
+		fill(&step.Font, w.Font)
+		fill(&step.Mode, w.Mode)
+		if step.Opacity == 0 {
+			step.Opacity = w.Opacity
+		}
+		if step.FontSize == 0 {
+			step.FontSize = float64(w.FontSize)
+		}
+	case PipelinePageNumbers:
+		p := prefs.PageNumbers
+		fill(&step.Position, p.Position)
+		fill(&step.Format, p.Format)
+		fill(&step.Style, p.Style)
+		if step.StartFrom == 0 {
+			step.StartFrom = p.StartFrom
+		}
+	}
+}
+
+// validate checks a step's parameters the way its endpoint does
+func (step *PipelineStep) validate() error {
+	var err error
+	switch step.Operation {
+	case PipelineRotate:
+		if len(step.Rotations) > 0 {
+			if step.Angle != 0 || step.Pages != "" {
+				return errors.New("send either angle and pages or rotations, not both")
+			}
+			step.rotations = make(map[int]int, len(step.Rotations))
+			for page, angle := range step.Rotations {
+				n, err := strconv.Atoi(page)
+				if err != nil {
+					return fmt.Errorf("rotations keys must be page numbers, got %q", page)
+				}
+				step.rotations[n] = angle
+			}
+			return nil
+		}
+		if step.Angle != 90 && step.Angle != 180 && step.Angle != 270 {
+			return errors.New("angle must be 90, 180 or 270")
+		}
+	case PipelineCrop:
+		if step.Top < 0 || step.Right < 0 || step.Bottom < 0 || step.Left < 0 {
+			return errors.New("crop values must be non-negative")
+		}
+	case PipelineWatermark:
+		step.watermark, err = NormalizeWatermarkOptions(WatermarkOptions{
+			Type:     WatermarkTypeText,
+			Text:     step.Text,
+			Position: step.Position,
+			Opacity:  step.Opacity,
+			FontSize: step.FontSize,
+			Color:    step.Color,
+			Font:     step.Font,
+			Mode:     step.Mode,
+			Rotation: step.Rotation,
+			Tile:     step.Tile,
+		})
+		return err
+	case PipelinePageNumbers:
+		step.pageNumbers, err = NormalizePageNumberOptions(PageNumberOptions{
+			Position:    step.Position,
+			Format:      step.Format,
+			StartFrom:   step.StartFrom,
+			Style:       step.Style,
+			FrontMatter: step.FrontMatter,
+			Skip:        step.Skip,
+			Mirror:      step.Mirror,
+		})
+		return err
+	case PipelineCompress:
+		if step.Quality == "" {
+			step.Quality = "medium"
+		}
+		if step.Quality != "low" && step.Quality != "medium" && step.Quality != "high" {
+			return errors.New("quality must be low, medium or high")
+		}
+	case PipelineReorder:
+		if len(step.Order) == 0 {
+			return errors.New("order is required")
+		}
+	case PipelineRemove, PipelineExtract:
+		if step.Pages == "" {
+			return errors.New("pages is required")
+		}
+	case PipelineSanitize:
+	case "":
+		return errors.New("operation is required")
+	default:
+		return fmt.Errorf("unsupported operation %q", step.Operation)
+	}
+	return nil
+}
+
+// RunPipeline applies steps from ParsePipeline to in, each on the previous
+// step's output, and returns the final PDF with a report of every step.
+// Nothing is stored in between.
+func (s *PDFService) RunPipeline(ctx context.Context, in PDFReader, steps []PipelineStep) ([]byte, []models.PipelineStepResult, error) {
+	var data []byte
+	results := make([]models.PipelineStepResult, 0, len(steps))
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		start := time.Now()
+		out, err := s.runPipelineStep(ctx, in, step)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %d (%s): %w", i+1, step.Operation, err)
+		}
+		data, in = out, bytes.NewReader(out)
+
+		pageCount, _ := s.GetPageCount(in)
+		results = append(results, models.PipelineStepResult{
+			Operation:    step.Operation,
+			PageCount:    pageCount,
+			Size:         int64(len(data)),
+			ProcessingMs: time.Since(start).Milliseconds(),
+		})
+	}
+	return data, results, nil
+}
+
+func (s *PDFService) runPipelineStep(ctx context.Context, in PDFReader, step PipelineStep) ([]byte, error) {
+	switch step.Operation {
+	case PipelineRotate:
+		var res *RotateResult
+		var err error
+		if step.rotations != nil {
+			res, err = s.RotatePages(ctx, in, step.rotations)
+		} else {
+			res, err = s.Rotate(ctx, in, step.Pages, step.Angle)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return res.Data, nil
+	case PipelineCrop:
+		return s.Crop(ctx, in, CropOptions{Top: step.Top, Right: step.Right, Bottom: step.Bottom, Left: step.Left})
+	case PipelineWatermark:
+		return s.AddWatermark(ctx, in, step.watermark)
+	case PipelinePageNumbers:
+		return s.AddPageNumbers(ctx, in, step.pageNumbers)
+	case PipelineCompress:
+		res, err := s.Compress(ctx, in, step.Quality)
+		if err != nil {
+			return nil, err
+		}
+		return res.Data, nil
+	case PipelineReorder:
+		return s.OrganizePages(ctx, in, step.Order)
+	case PipelineRemove:
+		return s.RemovePages(ctx, in, step.Pages)
+	case PipelineExtract:
+		return s.ExtractPages(ctx, in, step.Pages)
+	case PipelineSanitize:
+		out, _, err := s.Sanitize(ctx, in)
+		return out, err
+	}
+	return nil, fmt.Errorf("unsupported operation %q", step.Operation)
+}
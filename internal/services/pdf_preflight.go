@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ErrInvalidPreflight wraps preflight option validation failures
+var ErrInvalidPreflight = errors.New("invalid preflight options")
+
+// maxPreflightImages bounds the image placements listed in a report; all
+// placements are still checked
+const maxPreflightImages = 500
+
+// maxFormDepth bounds how deeply nested form XObjects are followed
+const maxFormDepth = 12
+
+type PreflightOptions struct {
+	MinDPI  int     // Images below this are flagged; below half of it is an error
+	BleedMM float64 // Bleed required beyond the trim box on every side
+}
+
+// NormalizePreflightOptions validates opts and fills in the defaults of
+// 300 DPI and 3 mm bleed
+func NormalizePreflightOptions(opts PreflightOptions) (PreflightOptions, error) {
+	if opts.MinDPI == 0 {
+		opts.MinDPI = 300
+	}
+	if opts.MinDPI < 72 || opts.MinDPI > 2400 {
+		return opts, fmt.Errorf("%w: minDpi must be between 72 and 2400", ErrInvalidPreflight)
+	}
+	if opts.BleedMM == 0 {
+		opts.BleedMM = 3
+	}
+	if opts.BleedMM < 0 || opts.BleedMM > 25 {
+		return opts, fmt.Errorf("%w: bleed must be between 0 and 25 mm", ErrInvalidPreflight)
+	}
+	return opts, nil
+}
+
+// Preflight checks whether a PDF is ready for commercial printing: the
+// effective resolution of placed images, RGB color, trim and bleed boxes,
+// transparency and font embedding. It walks the content of every page and
+// the forms it paints, tracking the transformation matrix to size images.
+func (s *PDFService) Preflight(ctx context.Context, in PDFReader, opts PreflightOptions) (*models.PreflightReport, error) {
+	opts, err := NormalizePreflightOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, fmt.Errorf("failed to validate pdf: %w", err)
+	}
+	boundaries, err := pdfCtx.PageBoundaries(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page boxes: %w", err)
+	}
+
+	p := &preflighter{
+		pdfCtx:      pdfCtx,
+		opts:        opts,
+		issues:      map[string]*models.PreflightIssue{},
+		colorSpaces: map[string][]int{},
+		fonts:       map[string]*models.PreflightFont{},
+		report: &models.PreflightReport{
+			MinDPI:  opts.MinDPI,
+			BleedMM: opts.BleedMM,
+			Images:  []models.PreflightImage{},
+		},
+	}
+
+	for i, pb := range boundaries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pageNr := i + 1
+		p.checkBoxes(pageNr, pb)
+
+		page, _, _, err := pdfCtx.PageDict(pageNr, false)
+		if err != nil || page == nil {
+			continue
+		}
+		if group, err := pdfCtx.DereferenceDict(page["Group"]); err == nil && group != nil {
+			if s := group.NameEntry("S"); s != nil && *s == "Transparency" {
+				p.transparency(pageNr, "the page is a transparency group")
+			}
+		}
+		content, err := pageContent(pdfCtx, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", pageNr, err)
+		}
+		p.walk(content, inheritedResources(pdfCtx, page), identityMatrix, pageNr, 0)
+	}
+
+	return p.finish(), nil
+}
+
+// matrix is a PDF transformation matrix [a b c d e f]
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// times returns m applied before n, as the cm operator concatenates
+func (m matrix) times(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// preflighter collects findings while walking page content
+type preflighter struct {
+	pdfCtx      *model.Context
+	opts        PreflightOptions
+	report      *models.PreflightReport
+	issues      map[string]*models.PreflightIssue // Keyed by check and message
+	colorSpaces map[string][]int                  // Family to pages
+	fonts       map[string]*models.PreflightFont
+	imageCount  int
+}
+
+// issue records a problem on a page, merging it with the same problem on
+// other pages
+func (p *preflighter) issue(check, severity, message string, page int) {
+	key := check + "\x00" + message
+	is, ok := p.issues[key]
+	if !ok {
+		is = &models.PreflightIssue{Check: check, Severity: severity, Message: message}
+		p.issues[key] = is
+	}
+	if page > 0 && (len(is.Pages) == 0 || is.Pages[len(is.Pages)-1] != page) {
+		is.Pages = append(is.Pages, page)
+	}
+}
+
+func (p *preflighter) transparency(page int, reason string) {
+	p.report.Transparency = true
+	p.issue("transparency", models.PreflightWarning,
+		"Transparency ("+reason+") may print differently unless flattened", page)
+}
+
+func (p *preflighter) useColorSpace(family string, page int) {
+	if family == "" {
+		return
+	}
+	pages := p.colorSpaces[family]
+	if len(pages) == 0 || pages[len(pages)-1] != page {
+		p.colorSpaces[family] = append(pages, page)
+	}
+}
+
+// checkBoxes flags pages without a trim box or with too little bleed
+func (p *preflighter) checkBoxes(page int, pb model.PageBoundaries) {
+	if pb.Trim == nil && pb.Art == nil {
+		p.issue("page-boxes", models.PreflightWarning,
+			"No TrimBox; the printer will treat the whole page as the finished size", page)
+	}
+	trim, bleed := pb.TrimBox(), pb.BleedBox()
+	if trim == nil || bleed == nil || p.opts.BleedMM == 0 {
+		return
+	}
+	margin := math.Min(
+		math.Min(trim.LL.X-bleed.LL.X, bleed.UR.X-trim.UR.X),
+		math.Min(trim.LL.Y-bleed.LL.Y, bleed.UR.Y-trim.UR.Y),
+	)
+	required := p.opts.BleedMM * 72 / 25.4
+	if margin < required-0.01 {
+		p.issue("page-boxes", models.PreflightWarning,
+			fmt.Sprintf("Bleed is %s mm, less than the %s mm required", formatMM(math.Max(margin, 0)), formatMM(p.opts.BleedMM)), page)
+	}
+}
+
+// walk inspects a content stream drawn with the given resources and
+// transformation, following the forms it paints
+func (p *preflighter) walk(content []byte, resources types.Dict, ctm matrix, page, depth int) {
+	p.checkFonts(resources, page)
+	var stack []matrix
+
+	for _, op := range contentOperations(content) {
+		switch op.operator {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := parseMatrix(op.operands); ok {
+				ctm = m.times(ctm)
+			}
+		case "g", "G":
+			p.useColorSpace("Gray", page)
+		case "rg", "RG":
+			p.useColorSpace("RGB", page)
+		case "k", "K":
+			p.useColorSpace("CMYK", page)
+		case "cs", "CS":
+			if len(op.operands) == 1 {
+				p.useColorSpace(p.colorFamily(p.namedColorSpace(op.operands[0], resources)), page)
+			}
+		case "gs":
+			if len(op.operands) == 1 {
+				p.checkGState(op.operands[0], resources, page)
+			}
+		case "Do":
+			if len(op.operands) == 1 {
+				p.paint(op.operands[0], resources, ctm, page, depth)
+			}
+		case "EI":
+			p.inlineImage(op.operands, resources, ctm, page)
+		}
+	}
+}
+
+// paint checks an image placement or walks a form
+func (p *preflighter) paint(name string, resources types.Dict, ctm matrix, page, depth int) {
+	xobjects, err := p.pdfCtx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return
+	}
+	sd, _, err := p.pdfCtx.DereferenceStreamDict(xobjects[strings.TrimPrefix(name, "/")])
+	if err != nil || sd == nil {
+		return
+	}
+	subtype := sd.Subtype()
+	if subtype == nil {
+		return
+	}
+
+	switch *subtype {
+	case "Image":
+		if mask := sd.BooleanEntry("ImageMask"); mask != nil && *mask {
+			return
+		}
+		if sd.Dict["SMask"] != nil {
+			p.transparency(page, "an image with a soft mask")
+		}
+		width, height := sd.IntEntry("Width"), sd.IntEntry("Height")
+		if width == nil || height == nil {
+			return
+		}
+		family := p.colorFamily(sd.Dict["ColorSpace"])
+		p.useColorSpace(family, page)
+		p.image(page, strings.TrimPrefix(name, "/"), *width, *height, family, ctm)
+
+	case "Form":
+		if depth >= maxFormDepth {
+			return
+		}
+		if group, err := p.pdfCtx.DereferenceDict(sd.Dict["Group"]); err == nil && group != nil {
+			if s := group.NameEntry("S"); s != nil && *s == "Transparency" {
+				p.transparency(page, "a transparency group")
+			}
+		}
+		formCTM := ctm
+		if m, ok := formMatrix(p.pdfCtx, sd); ok {
+			formCTM = m.times(ctm)
+		}
+		formResources, _ := p.pdfCtx.DereferenceDict(sd.Dict["Resources"])
+		if formResources == nil {
+			formResources = resources
+		}
+		if err := sd.Decode(); err != nil {
+			return
+		}
+		p.walk(sd.Content, formResources, formCTM, page, depth+1)
+	}
+}
+
+// inlineImage checks an inline image from its BI dictionary operands
+func (p *preflighter) inlineImage(operands []string, resources types.Dict, ctm matrix, page int) {
+	var width, height int
+	family := ""
+	for i := 0; i+1 < len(operands); i++ {
+		switch operands[i] {
+		case "/W", "/Width":
+			width, _ = strconv.Atoi(operands[i+1])
+		case "/H", "/Height":
+			height, _ = strconv.Atoi(operands[i+1])
+		case "/IM", "/ImageMask":
+			if operands[i+1] == "true" {
+				return
+			}
+		case "/CS", "/ColorSpace":
+			family = p.colorFamily(p.namedColorSpace(operands[i+1], resources))
+		}
+	}
+	if width == 0 || height == 0 {
+		return
+	}
+	p.useColorSpace(family, page)
+	p.image(page, "inline", width, height, family, ctm)
+}
+
+// image records a placement, the unit square scaled by ctm
+func (p *preflighter) image(page int, name string, width, height int, family string, ctm matrix) {
+	placedW := math.Hypot(ctm[0], ctm[1]) / 72
+	placedH := math.Hypot(ctm[2], ctm[3]) / 72
+	if placedW == 0 || placedH == 0 {
+		return
+	}
+	dpi := math.Min(float64(width)/placedW, float64(height)/placedH)
+	dpi = math.Round(dpi*10) / 10
+
+	p.imageCount++
+	if p.imageCount <= maxPreflightImages {
+		p.report.Images = append(p.report.Images, models.PreflightImage{
+			Page:       page,
+			Name:       name,
+			Width:      width,
+			Height:     height,
+			DPI:        dpi,
+			ColorSpace: family,
+		})
+	}
+
+	switch {
+	case dpi < float64(p.opts.MinDPI)/2:
+		p.issue("image-resolution", models.PreflightError,
+			fmt.Sprintf("Images below %d DPI will print visibly pixelated", p.opts.MinDPI/2), page)
+	case dpi < float64(p.opts.MinDPI):
+		p.issue("image-resolution", models.PreflightWarning,
+			fmt.Sprintf("Images below %d DPI may print soft", p.opts.MinDPI), page)
+	}
+}
+
+// checkGState flags graphics states that introduce transparency
+func (p *preflighter) checkGState(name string, resources types.Dict, page int) {
+	states, err := p.pdfCtx.DereferenceDict(resources["ExtGState"])
+	if err != nil || states == nil {
+		return
+	}
+	gs, err := p.pdfCtx.DereferenceDict(states[strings.TrimPrefix(name, "/")])
+	if err != nil || gs == nil {
+		return
+	}
+	for _, key := range []string{"ca", "CA"} {
+		if v, err := p.pdfCtx.DereferenceNumber(gs[key]); err == nil && gs[key] != nil && v < 1 {
+			p.transparency(page, "opacity below 100%")
+			return
+		}
+	}
+	if mask, ok := gs["SMask"].(types.Name); gs["SMask"] != nil && (!ok || mask != "None") {
+		p.transparency(page, "a soft mask")
+		return
+	}
+	if bm, ok := gs["BM"].(types.Name); ok && bm != "Normal" && bm != "Compatible" {
+		p.transparency(page, "blend mode "+string(bm))
+	}
+}
+
+// checkFonts records the fonts in a resource dict and whether they are
+// embedded
+func (p *preflighter) checkFonts(resources types.Dict, page int) {
+	fonts, err := p.pdfCtx.DereferenceDict(resources["Font"])
+	if err != nil || fonts == nil {
+		return
+	}
+	for name, o := range fonts {
+		font, err := p.pdfCtx.DereferenceDict(o)
+		if err != nil || font == nil {
+			continue
+		}
+		key := name
+		if ref, ok := o.(types.IndirectRef); ok {
+			key = "obj" + strconv.Itoa(ref.ObjectNumber.Value())
+		}
+		if f, ok := p.fonts[key]; ok {
+			if f.Pages[len(f.Pages)-1] != page {
+				f.Pages = append(f.Pages, page)
+			}
+			continue
+		}
+
+		f := &models.PreflightFont{Name: name, Pages: []int{page}}
+		if base := font.NameEntry("BaseFont"); base != nil {
+			f.Name = *base
+			if i := strings.IndexByte(f.Name, '+'); i == 6 {
+				// Drop the subset tag, e.g. ABCDEF+Helvetica
+				f.Name = f.Name[7:]
+			}
+		}
+		if st := font.Subtype(); st != nil {
+			f.Type = *st
+		}
+		f.Embedded = p.fontEmbedded(font)
+		p.fonts[key] = f
+	}
+}
+
+func (p *preflighter) fontEmbedded(font types.Dict) bool {
+	if st := font.Subtype(); st != nil {
+		switch *st {
+		case "Type3":
+			// Glyphs are content streams in the font itself
+			return true
+		case "Type0":
+			descendants, err := p.pdfCtx.DereferenceArray(font["DescendantFonts"])
+			if err != nil || len(descendants) == 0 {
+				return false
+			}
+			if font, err = p.pdfCtx.DereferenceDict(descendants[0]); err != nil || font == nil {
+				return false
+			}
+		}
+	}
+	descriptor, err := p.pdfCtx.DereferenceDict(font["FontDescriptor"])
+	if err != nil || descriptor == nil {
+		return false
+	}
+	return descriptor["FontFile"] != nil || descriptor["FontFile2"] != nil || descriptor["FontFile3"] != nil
+}
+
+// namedColorSpace resolves a color space operand to a device name or the
+// color space object in the resources
+func (p *preflighter) namedColorSpace(name string, resources types.Dict) types.Object {
+	name = strings.TrimPrefix(name, "/")
+	switch name {
+	case "DeviceGray", "G", "DeviceRGB", "RGB", "DeviceCMYK", "CMYK", "Pattern":
+		return types.Name(name)
+	}
+	spaces, err := p.pdfCtx.DereferenceDict(resources["ColorSpace"])
+	if err != nil || spaces == nil {
+		return nil
+	}
+	return spaces[name]
+}
+
+// colorFamily classifies a color space as Gray, RGB, CMYK, Spot or Lab,
+// or "" for patterns and unknown spaces
+func (p *preflighter) colorFamily(o types.Object) string {
+	o, err := p.pdfCtx.Dereference(o)
+	if err != nil || o == nil {
+		return ""
+	}
+	if n, ok := o.(types.Name); ok {
+		switch n {
+		case "DeviceGray", "G", "CalGray":
+			return "Gray"
+		case "DeviceRGB", "RGB", "CalRGB":
+			return "RGB"
+		case "DeviceCMYK", "CMYK":
+			return "CMYK"
+		}
+		return ""
+	}
+	a, ok := o.(types.Array)
+	if !ok || len(a) == 0 {
+		return ""
+	}
+	family, _ := a[0].(types.Name)
+	switch family {
+	case "CalGray", "CalRGB":
+		return p.colorFamily(family)
+	case "Lab":
+		return "Lab"
+	case "Separation", "DeviceN":
+		return "Spot"
+	case "Indexed", "I":
+		if len(a) > 1 {
+			return p.colorFamily(a[1])
+		}
+	case "ICCBased":
+		if len(a) > 1 {
+			sd, _, err := p.pdfCtx.DereferenceStreamDict(a[1])
+			if err != nil || sd == nil {
+				return ""
+			}
+			if n := sd.IntEntry("N"); n != nil {
+				return map[int]string{1: "Gray", 3: "RGB", 4: "CMYK"}[*n]
+			}
+		}
+	}
+	return ""
+}
+
+// finish turns the collected findings into the report
+func (p *preflighter) finish() *models.PreflightReport {
+	report := p.report
+
+	report.ColorSpaces = []string{}
+	for family := range p.colorSpaces {
+		report.ColorSpaces = append(report.ColorSpaces, family)
+	}
+	sort.Strings(report.ColorSpaces)
+	for _, page := range p.colorSpaces["RGB"] {
+		p.issue("color-space", models.PreflightWarning,
+			"RGB color will be converted to CMYK by the printer; colors may shift", page)
+	}
+
+	report.Fonts = []models.PreflightFont{}
+	for _, f := range p.fonts {
+		report.Fonts = append(report.Fonts, *f)
+		if !f.Embedded {
+			for _, page := range f.Pages {
+				p.issue("fonts", models.PreflightError, "Font "+f.Name+" is not embedded", page)
+			}
+		}
+	}
+	sort.Slice(report.Fonts, func(i, j int) bool { return report.Fonts[i].Name < report.Fonts[j].Name })
+
+	report.Issues = []models.PreflightIssue{}
+	for _, is := range p.issues {
+		sort.Ints(is.Pages)
+		report.Issues = append(report.Issues, *is)
+	}
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity == models.PreflightError
+		}
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		return a.Message < b.Message
+	})
+
+	report.Passed = true
+	for _, is := range report.Issues {
+		if is.Severity == models.PreflightError {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// formMatrix reads a form XObject's Matrix entry
+func formMatrix(pdfCtx *model.Context, form *types.StreamDict) (matrix, bool) {
+	var m matrix
+	a, err := pdfCtx.DereferenceArray(form.Dict["Matrix"])
+	if err != nil || len(a) != 6 {
+		return m, false
+	}
+	for i, o := range a {
+		f, err := pdfCtx.DereferenceNumber(o)
+		if err != nil {
+			return m, false
+		}
+		m[i] = f
+	}
+	return m, true
+}
+
+// parseMatrix reads the six operands of cm
+func parseMatrix(operands []string) (matrix, bool) {
+	var m matrix
+	if len(operands) != 6 {
+		return m, false
+	}
+	for i, o := range operands {
+		f, err := strconv.ParseFloat(o, 64)
+		if err != nil {
+			return m, false
+		}
+		m[i] = f
+	}
+	return m, true
+}
+
+func formatMM(mm float64) string {
+	return strconv.FormatFloat(math.Round(mm*10)/10, 'f', -1, 64)
+}
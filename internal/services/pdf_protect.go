@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Restrictions Protect can place on a PDF opened with the user password
+const (
+	RestrictPrint    = "print"
+	RestrictCopy     = "copy"
+	RestrictModify   = "modify"
+	RestrictAnnotate = "annotate"
+)
+
+// restrictionFlags are the permission bits each restriction clears
+var restrictionFlags = map[string]model.PermissionFlags{
+	RestrictPrint:    model.PermissionPrintRev2 | model.PermissionPrintRev3,
+	RestrictCopy:     model.PermissionExtract | model.PermissionExtractRev3,
+	RestrictModify:   model.PermissionModify | model.PermissionAssembleRev3,
+	RestrictAnnotate: model.PermissionModAnnFillForm | model.PermissionFillRev3,
+}
+
+var (
+	// ErrInvalidProtect wraps protect option validation failures
+	ErrInvalidProtect = errors.New("invalid protect options")
+	// ErrWrongPassword is returned when a PDF can't be opened with the
+	// password given
+	ErrWrongPassword = errors.New("incorrect password")
+	// ErrAlreadyEncrypted is returned when protecting a protected PDF
+	ErrAlreadyEncrypted = errors.New("PDF is already password protected")
+	// ErrNotEncrypted is returned when unlocking an unprotected PDF
+	ErrNotEncrypted = errors.New("PDF is not password protected")
+)
+
+type ProtectOptions struct {
+	UserPassword  string   // Needed to open the PDF
+	OwnerPassword string   // Lifts Restrictions; defaults to UserPassword
+	Restrictions  []string // RestrictPrint, RestrictCopy, ...
+}
+
+// NormalizeProtectOptions validates opts, lowercasing and deduplicating
+// the restrictions. Restrictions need an owner password different from
+// the user password, as anyone with the owner password may lift them.
+func NormalizeProtectOptions(opts ProtectOptions) (ProtectOptions, error) {
+	if opts.UserPassword == "" {
+		return opts, fmt.Errorf("%w: password is required", ErrInvalidProtect)
+	}
+
+	seen := make(map[string]bool)
+	var restrictions []string
+	for _, r := range opts.Restrictions {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if r == "" || seen[r] {
+			continue
+		}
+		if _, ok := restrictionFlags[r]; !ok {
+			return opts, fmt.Errorf("%w: unknown restriction %q; use %s, %s, %s or %s", ErrInvalidProtect, r, RestrictPrint, RestrictCopy, RestrictModify, RestrictAnnotate)
+		}
+		seen[r] = true
+		restrictions = append(restrictions, r)
+	}
+	opts.Restrictions = restrictions
+
+	if len(opts.Restrictions) > 0 && (opts.OwnerPassword == "" || opts.OwnerPassword == opts.UserPassword) {
+		return opts, fmt.Errorf("%w: restrictions need an owner password different from the password", ErrInvalidProtect)
+	}
+	if opts.OwnerPassword == "" {
+		opts.OwnerPassword = opts.UserPassword
+	}
+	return opts, nil
+}
+
+// protectConfig returns the pdfcpu configuration encrypting with opts
+func (s *PDFService) protectConfig(opts ProtectOptions) *model.Configuration {
+	conf := model.NewAESConfiguration(opts.UserPassword, opts.OwnerPassword, 256)
+	conf.ValidationMode = model.ValidationRelaxed
+	conf.Permissions = model.PermissionsAll
+	for _, r := range opts.Restrictions {
+		conf.Permissions &^= restrictionFlags[r]
+	}
+	return conf
+}
+
+// Encrypt protects a PDF with AES-256: opts.UserPassword is needed to open
+// it, and readers opening it with that password are held to
+// opts.Restrictions
+func (s *PDFService) Encrypt(ctx context.Context, in PDFReader, opts ProtectOptions) ([]byte, error) {
+	opts, err := NormalizeProtectOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted, err := s.IsEncrypted(in); err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	} else if encrypted {
+		return nil, ErrAlreadyEncrypted
+	}
+
+	var buf bytes.Buffer
+	if err := api.Encrypt(readSeeker(in), &buf, s.protectConfig(opts)); err != nil {
+		return nil, fmt.Errorf("failed to encrypt pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt removes the protection from a PDF given its user or owner
+// password. PDFs protected with only an owner password open with an
+// empty password, but lifting their restrictions takes the owner one.
+func (s *PDFService) Decrypt(ctx context.Context, in PDFReader, password string) ([]byte, error) {
+	if encrypted, err := s.IsEncrypted(in); err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	} else if !encrypted {
+		return nil, ErrNotEncrypted
+	}
+
+	conf := s.getConfig()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	var buf bytes.Buffer
+	if err := api.Decrypt(readSeeker(in), &buf, conf); err != nil {
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, ErrWrongPassword
+		}
+		return nil, fmt.Errorf("failed to decrypt pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// IsEncrypted reports whether a PDF has an encryption dictionary, without
+// needing its password
+func (s *PDFService) IsEncrypted(in PDFReader) (bool, error) {
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return pdfCtx.Encrypt != nil, nil
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ErrInvalidRedact wraps redaction option validation failures
+var ErrInvalidRedact = errors.New("invalid redaction")
+
+// RedactArea is a rectangle to burn out of a page, in points from the
+// bottom-left corner as reported by SearchText
+type RedactArea struct {
+	Page int
+	Rect models.TextRect
+}
+
+// RedactStats counts what RedactAreas removed
+type RedactStats struct {
+	Glyphs      int // Characters removed from page content
+	Annotations int // Annotations overlapping an area, e.g. comments
+}
+
+// RedactAreas burns areas out of a PDF. Characters whose glyph center lies
+// in an area are removed from the page content, so they can no longer be
+// selected, copied or extracted, and the text around them keeps its
+// position. Annotations overlapping an area are removed, and each area is
+// then painted over in fill (#RRGGBB). The file is written from scratch,
+// so earlier incremental updates holding the original text are dropped.
+// Text inside form XObjects, images and form field values are painted
+// over but not removed.
+func (s *PDFService) RedactAreas(ctx context.Context, in PDFReader, areas []RedactArea, fill string) ([]byte, *RedactStats, error) {
+	if !ValidHexColor(fill) {
+		return nil, nil, fmt.Errorf("%w: color must be a hex color like #000000", ErrInvalidRedact)
+	}
+
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, nil, fmt.Errorf("failed to validate pdf: %w", err)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return nil, nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+
+	byPage := make(map[int][]models.TextRect)
+	for _, a := range areas {
+		if a.Page < 1 || a.Page > pdfCtx.PageCount {
+			return nil, nil, fmt.Errorf("%w: page %d is out of range (document has %d pages)", ErrInvalidRedact, a.Page, pdfCtx.PageCount)
+		}
+		if a.Rect.Width > 0 && a.Rect.Height > 0 {
+			byPage[a.Page] = append(byPage[a.Page], a.Rect)
+		}
+	}
+
+	stats := &RedactStats{}
+	for pageNr := 1; pageNr <= pdfCtx.PageCount; pageNr++ {
+		if len(byPage[pageNr]) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if err := redactPage(pdfCtx, pageNr, byPage[pageNr], hexRGB(fill), stats); err != nil {
+			return nil, nil, fmt.Errorf("failed to redact page %d: %w", pageNr, err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), stats, nil
+}
+
+// redactPage removes the text under rects from a page's content, removes
+// the annotations over them and paints them over
+func redactPage(pdfCtx *model.Context, pageNr int, rects []models.TextRect, fill [3]float64, stats *RedactStats) error {
+	page, _, _, err := pdfCtx.PageDict(pageNr, false)
+	if err != nil {
+		return err
+	}
+	if page == nil {
+		return nil
+	}
+
+	content, err := pageContent(pdfCtx, page)
+	if err != nil {
+		return err
+	}
+	r := &textRedactor{
+		pdfCtx:    pdfCtx,
+		resources: inheritedResources(pdfCtx, page),
+		rects:     rects,
+		fonts:     make(map[string]*redactFont),
+	}
+	cleaned := r.rewrite(content)
+	stats.Glyphs += r.removed
+
+	// Isolate the page's graphics state so the boxes are drawn in default
+	// user space
+	var buf bytes.Buffer
+	buf.WriteString("q\n")
+	buf.Write(cleaned)
+	buf.WriteString("Q\n")
+	fmt.Fprintf(&buf, "q %s %s %s rg\n", formatNumber(fill[0]), formatNumber(fill[1]), formatNumber(fill[2]))
+	for _, rc := range rects {
+		fmt.Fprintf(&buf, "%s %s %s %s re\n", formatNumber(rc.X), formatNumber(rc.Y), formatNumber(rc.Width), formatNumber(rc.Height))
+	}
+	buf.WriteString("f Q\n")
+	if err := setPageContent(pdfCtx, page, buf.Bytes()); err != nil {
+		return err
+	}
+
+	stats.Annotations += removeAnnotations(pdfCtx, page, rects)
+	return nil
+}
+
+// removeAnnotations drops the annotations of a page that overlap rects,
+// along with their popups, and returns how many were dropped. Form field
+// widgets are kept, as their values live in the form as well.
+func removeAnnotations(pdfCtx *model.Context, page types.Dict, rects []models.TextRect) int {
+	annots, err := pdfCtx.DereferenceArray(page["Annots"])
+	if err != nil || len(annots) == 0 {
+		return 0
+	}
+
+	drop := make([]bool, len(annots))
+	popups := make(map[int]bool)
+	for i, o := range annots {
+		d, err := pdfCtx.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+		if subtype := d.NameEntry("Subtype"); subtype != nil && *subtype == "Widget" {
+			continue
+		}
+		if !annotationOverlaps(pdfCtx, d, rects) {
+			continue
+		}
+		drop[i] = true
+		if ref, ok := d["Popup"].(types.IndirectRef); ok {
+			popups[ref.ObjectNumber.Value()] = true
+		}
+	}
+
+	var kept types.Array
+	removed := 0
+	for i, o := range annots {
+		if ref, ok := o.(types.IndirectRef); ok && popups[ref.ObjectNumber.Value()] {
+			drop[i] = true
+		}
+		if drop[i] {
+			removed++
+			continue
+		}
+		kept = append(kept, o)
+	}
+	if removed == 0 {
+		return 0
+	}
+	if len(kept) == 0 {
+		page.Delete("Annots")
+	} else {
+		page.Update("Annots", kept)
+	}
+	return removed
+}
+
+// annotationOverlaps reports whether an annotation's Rect intersects any
+// of rects
+func annotationOverlaps(pdfCtx *model.Context, annot types.Dict, rects []models.TextRect) bool {
+	a, err := pdfCtx.DereferenceArray(annot["Rect"])
+	if err != nil || len(a) != 4 {
+		return false
+	}
+	var v [4]float64
+	for i, o := range a {
+		if v[i], err = pdfCtx.DereferenceNumber(o); err != nil {
+			return false
+		}
+	}
+	llx, urx := math.Min(v[0], v[2]), math.Max(v[0], v[2])
+	lly, ury := math.Min(v[1], v[3]), math.Max(v[1], v[3])
+	for _, rc := range rects {
+		if llx < rc.X+rc.Width && urx > rc.X && lly < rc.Y+rc.Height && ury > rc.Y {
+			return true
+		}
+	}
+	return false
+}
+
+// apply maps a point through m
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// textState holds the text parameters of the graphics state
+type textState struct {
+	font                       *redactFont
+	size, tc, tw, th, tl, rise float64
+}
+
+// textRedactor removes the glyphs under rects from page content, tracking
+// the text state to place every glyph
+type textRedactor struct {
+	pdfCtx    *model.Context
+	resources types.Dict
+	rects     []models.TextRect
+	fonts     map[string]*redactFont
+	removed   int
+}
+
+// rewrite returns content with the glyphs under the rects removed. Text
+// operations without such glyphs are kept byte for byte.
+func (r *textRedactor) rewrite(content []byte) []byte {
+	type savedState struct {
+		ctm matrix
+		ts  textState
+	}
+	var out bytes.Buffer
+	var stack []savedState
+	ctm, tm, tlm := identityMatrix, identityMatrix, identityMatrix
+	ts := textState{th: 1}
+
+	for _, op := range contentOperations(content) {
+		replacement := ""
+		switch op.operator {
+		case "q":
+			stack = append(stack, savedState{ctm, ts})
+		case "Q":
+			if len(stack) > 0 {
+				ctm, ts = stack[len(stack)-1].ctm, stack[len(stack)-1].ts
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := parseMatrix(op.operands); ok {
+				ctm = m.times(ctm)
+			}
+		case "BT":
+			tm, tlm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(op.operands) == 2 {
+				ts.font = r.font(op.operands[0])
+				ts.size = operandNumber(op.operands[1])
+			}
+		case "Tc", "Tw", "Tz", "TL", "Ts":
+			if len(op.operands) != 1 {
+				break
+			}
+			v := operandNumber(op.operands[0])
+			switch op.operator {
+			case "Tc":
+				ts.tc = v
+			case "Tw":
+				ts.tw = v
+			case "Tz":
+				ts.th = v / 100
+			case "TL":
+				ts.tl = v
+			case "Ts":
+				ts.rise = v
+			}
+		case "Td", "TD":
+			if len(op.operands) != 2 {
+				break
+			}
+			tx, ty := operandNumber(op.operands[0]), operandNumber(op.operands[1])
+			if op.operator == "TD" {
+				ts.tl = -ty
+			}
+			tlm = matrix{1, 0, 0, 1, tx, ty}.times(tlm)
+			tm = tlm
+		case "Tm":
+			if m, ok := parseMatrix(op.operands); ok {
+				tm, tlm = m, m
+			}
+		case "T*":
+			tlm = matrix{1, 0, 0, 1, 0, -ts.tl}.times(tlm)
+			tm = tlm
+		case "Tj", "TJ", "'", "\"":
+			replacement = r.show(op, &ts, &tm, &tlm, ctm)
+		}
+
+		if replacement != "" {
+			out.WriteString(replacement)
+		} else {
+			out.Write(content[op.start:op.end])
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// show advances the text matrix over a text-showing operation and returns
+// the TJ replacing it when any of its glyphs are redacted, or "". Removed
+// glyphs become position adjustments so the glyphs after them stay put.
+func (r *textRedactor) show(op contentOp, ts *textState, tm, tlm *matrix, ctm matrix) string {
+	operands := op.operands
+	prefix := ""
+	switch op.operator {
+	case "\"":
+		if len(operands) != 3 {
+			return ""
+		}
+		ts.tw, ts.tc = operandNumber(operands[0]), operandNumber(operands[1])
+		prefix = operands[0] + " Tw " + operands[1] + " Tc "
+		operands = operands[2:]
+		fallthrough
+	case "'":
+		*tlm = matrix{1, 0, 0, 1, 0, -ts.tl}.times(*tlm)
+		*tm = *tlm
+		prefix += "T* "
+	}
+
+	step := 1
+	if ts.font != nil && ts.font.twoByte {
+		step = 2
+	}
+	var parts []string // Elements of the replacement TJ array
+	var pending []byte // Kept codes not yet in parts
+	gap := 0.0         // Adjustment not yet in parts, in thousandths of an em
+	changed := false
+	flushCodes := func() {
+		if len(pending) > 0 {
+			parts = append(parts, "<"+hex.EncodeToString(pending)+">")
+			pending = nil
+		}
+	}
+	flushGap := func() {
+		if gap != 0 {
+			parts = append(parts, formatNumber(gap))
+			gap = 0
+		}
+	}
+
+	for _, e := range operands {
+		if e == "[" || e == "]" || e == "" {
+			continue
+		}
+		if e[0] != '(' && e[0] != '<' {
+			n := operandNumber(e)
+			*tm = matrix{1, 0, 0, 1, -n / 1000 * ts.size * ts.th, 0}.times(*tm)
+			flushCodes()
+			gap += n
+			continue
+		}
+
+		codes := decodePDFString(e)
+		for i := 0; i+step <= len(codes); i += step {
+			code := int(codes[i])
+			if step == 2 {
+				code = code<<8 | int(codes[i+1])
+			}
+			w := ts.font.width(code)
+			tx := w/1000*ts.size + ts.tc
+			if step == 1 && code == ' ' {
+				tx += ts.tw
+			}
+			tx *= ts.th
+
+			if r.covered(w, ts, *tm, ctm) {
+				changed = true
+				r.removed++
+				flushCodes()
+				if scale := ts.size * ts.th; scale != 0 {
+					gap -= tx * 1000 / scale
+				}
+			} else {
+				flushGap()
+				pending = append(pending, codes[i:i+step]...)
+			}
+			*tm = matrix{1, 0, 0, 1, tx, 0}.times(*tm)
+		}
+	}
+	if !changed {
+		return ""
+	}
+	flushCodes()
+	flushGap()
+	return prefix + "[" + strings.Join(parts, " ") + "] TJ"
+}
+
+// covered reports whether the center of a glyph w thousandths of an em
+// wide, drawn at tm, lies in one of the rects
+func (r *textRedactor) covered(w float64, ts *textState, tm, ctm matrix) bool {
+	trm := matrix{ts.size * ts.th, 0, 0, ts.size, 0, ts.rise}.times(tm).times(ctm)
+	// Halfway along the advance, a third of the way up from the baseline
+	x, y := trm.apply(w/2000, 0.3)
+	for _, rc := range r.rects {
+		if x >= rc.X && x <= rc.X+rc.Width && y >= rc.Y && y <= rc.Y+rc.Height {
+			return true
+		}
+	}
+	return false
+}
+
+// redactFont holds the glyph widths of a font, in thousandths of an em
+type redactFont struct {
+	twoByte bool // Type0 font with two-byte codes
+	first   int
+	widths  []float64       // Simple fonts, from FirstChar
+	cids    map[int]float64 // Type0 fonts
+	missing float64         // Width of codes not listed
+	core    string          // Standard font, measured from its metrics
+	scale   float64         // Type3 glyph space to thousandths of an em
+}
+
+// width returns the width of the glyph for code; unknown fonts assume
+// half an em
+func (f *redactFont) width(code int) float64 {
+	if f == nil {
+		return 500
+	}
+	if f.twoByte {
+		if w, ok := f.cids[code]; ok {
+			return w
+		}
+		return f.missing
+	}
+	if i := code - f.first; i >= 0 && i < len(f.widths) {
+		return f.widths[i] * f.scale
+	}
+	if f.core != "" {
+		return float64(font.CharWidth(f.core, rune(code)))
+	}
+	return f.missing
+}
+
+// font loads the widths of the font named in a Tf operand
+func (r *textRedactor) font(name string) *redactFont {
+	if f, ok := r.fonts[name]; ok {
+		return f
+	}
+	f := r.loadFont(strings.TrimPrefix(name, "/"))
+	r.fonts[name] = f
+	return f
+}
+
+func (r *textRedactor) loadFont(name string) *redactFont {
+	f := &redactFont{missing: 500, scale: 1}
+	fonts, err := r.pdfCtx.DereferenceDict(r.resources["Font"])
+	if err != nil || fonts == nil {
+		return f
+	}
+	fd, err := r.pdfCtx.DereferenceDict(fonts[name])
+	if err != nil || fd == nil {
+		return f
+	}
+
+	if subtype := fd.NameEntry("Subtype"); subtype != nil && *subtype == "Type0" {
+		f.twoByte = true
+		f.missing = 1000
+		f.cids = make(map[int]float64)
+		descendants, err := r.pdfCtx.DereferenceArray(fd["DescendantFonts"])
+		if err != nil || len(descendants) == 0 {
+			return f
+		}
+		cid, err := r.pdfCtx.DereferenceDict(descendants[0])
+		if err != nil || cid == nil {
+			return f
+		}
+		if dw, err := r.pdfCtx.DereferenceNumber(cid["DW"]); err == nil && cid["DW"] != nil {
+			f.missing = dw
+		}
+		r.loadCIDWidths(f, cid["W"])
+		return f
+	} else if subtype != nil && *subtype == "Type3" {
+		if m, err := r.pdfCtx.DereferenceArray(fd["FontMatrix"]); err == nil && len(m) == 6 {
+			if a, err := r.pdfCtx.DereferenceNumber(m[0]); err == nil {
+				f.scale = a * 1000
+			}
+		}
+	}
+
+	if first, err := r.pdfCtx.DereferenceNumber(fd["FirstChar"]); err == nil && fd["FirstChar"] != nil {
+		f.first = int(first)
+	}
+	if widths, err := r.pdfCtx.DereferenceArray(fd["Widths"]); err == nil {
+		for _, o := range widths {
+			w, _ := r.pdfCtx.DereferenceNumber(o)
+			f.widths = append(f.widths, w)
+		}
+	}
+	if desc, err := r.pdfCtx.DereferenceDict(fd["FontDescriptor"]); err == nil && desc != nil && desc["MissingWidth"] != nil {
+		if mw, err := r.pdfCtx.DereferenceNumber(desc["MissingWidth"]); err == nil {
+			f.missing = mw
+		}
+	}
+	if base := fd.NameEntry("BaseFont"); base != nil && font.IsCoreFont(*base) {
+		f.core = *base
+	}
+	return f
+}
+
+// loadCIDWidths reads a CIDFont's W array, whose entries are either
+// "c [w1 w2 ...]" or "cFirst cLast w"
+func (r *textRedactor) loadCIDWidths(f *redactFont, o types.Object) {
+	w, err := r.pdfCtx.DereferenceArray(o)
+	if err != nil {
+		return
+	}
+	for i := 0; i+1 < len(w); {
+		first, err := r.pdfCtx.DereferenceNumber(w[i])
+		if err != nil {
+			return
+		}
+		if list, err := r.pdfCtx.DereferenceArray(w[i+1]); err == nil && list != nil {
+			for j, wo := range list {
+				f.cids[int(first)+j], _ = r.pdfCtx.DereferenceNumber(wo)
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, err1 := r.pdfCtx.DereferenceNumber(w[i+1])
+		width, err2 := r.pdfCtx.DereferenceNumber(w[i+2])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		for c := int(first); c <= int(last) && c <= 0xFFFF; c++ {
+			f.cids[c] = width
+		}
+		i += 3
+	}
+}
+
+// operandNumber parses a numeric operand, 0 when it isn't one
+func operandNumber(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+// decodePDFString returns the bytes of a literal "(...)" or hex "<...>"
+// string token
+func decodePDFString(token string) []byte {
+	if strings.HasPrefix(token, "<") {
+		digits := make([]byte, 0, len(token))
+		for i := 1; i < len(token) && token[i] != '>'; i++ {
+			if !isPDFWhitespace(token[i]) {
+				digits = append(digits, token[i])
+			}
+		}
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		out := make([]byte, len(digits)/2)
+		n, _ := hex.Decode(out, digits)
+		return out[:n]
+	}
+
+	s := strings.TrimSuffix(strings.TrimPrefix(token, "("), ")")
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r':
+			// Line continuation
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		default:
+			if c >= '0' && c <= '7' {
+				v := 0
+				for j := 0; j < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; j++ {
+					v = v*8 + int(s[i]-'0')
+					i++
+				}
+				i--
+				out = append(out, byte(v))
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,640 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// riskyActions are the action types removed besides JavaScript: they open
+// other files or programs, or send and load form data
+var riskyActions = map[string]bool{
+	"Launch":     true,
+	"SubmitForm": true,
+	"ImportData": true,
+	"GoToE":      true,
+	"Rendition":  true,
+}
+
+// Sanitize strips everything from a PDF that could identify its author or
+// run on opening before it is shared: the document info and XMP metadata,
+// JavaScript and risky actions, XFA forms, attachments, the content of
+// layers hidden by default, application private data, thumbnails and the
+// incremental-update history. Visible page content is kept as is. The
+// writer adds a fresh Producer and dates to the info dictionary and a new
+// file ID.
+func (s *PDFService) Sanitize(ctx context.Context, in PDFReader) ([]byte, *models.SanitizeReport, error) {
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, nil, fmt.Errorf("failed to validate pdf: %w", err)
+	}
+
+	report := &models.SanitizeReport{DocumentInfo: []string{}, Attachments: []string{}, HiddenLayers: []string{}}
+	report.Revisions = countInReader(in, []byte("%%EOF")) - 1
+	if pdfCtx.Read.Linearized {
+		// The first-page section of a linearized file ends in its own %%EOF
+		report.Revisions--
+	}
+	if report.Revisions < 0 {
+		report.Revisions = 0
+	}
+
+	if pdfCtx.Info != nil {
+		if info, err := pdfCtx.DereferenceDict(*pdfCtx.Info); err == nil {
+			for key := range info {
+				report.DocumentInfo = append(report.DocumentInfo, key)
+			}
+			sort.Strings(report.DocumentInfo)
+		}
+		pdfCtx.Info = nil
+	}
+	pdfCtx.ID = nil
+
+	root, err := pdfCtx.Catalog()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	if err := removeHiddenLayers(pdfCtx, root, report); err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if attachments, err := pdfCtx.ListAttachments(); err == nil {
+		for _, a := range attachments {
+			report.Attachments = append(report.Attachments, a.FileName)
+		}
+	}
+	if pdfCtx.Names["EmbeddedFiles"] != nil || root.DictEntry("Collection") != nil {
+		if err := pdfCtx.RemoveEmbeddedFilesNameTree(); err != nil {
+			return nil, nil, fmt.Errorf("failed to remove attachments: %w", err)
+		}
+	}
+	if js := pdfCtx.Names["JavaScript"]; js != nil {
+		if keys, err := js.KeyList(); err == nil {
+			report.JavaScript += len(keys)
+		}
+		delete(pdfCtx.Names, "JavaScript")
+		if err := pdfCtx.RemoveNameTree("JavaScript"); err != nil {
+			return nil, nil, fmt.Errorf("failed to remove scripts: %w", err)
+		}
+	}
+	root.Delete("AF")
+	if form, err := pdfCtx.DereferenceDict(root["AcroForm"]); err == nil && form != nil && form["XFA"] != nil {
+		form.Delete("XFA")
+		report.XFA = true
+	}
+
+	// Scrub every object, including dicts nested directly inside others
+	for _, entry := range pdfCtx.Table {
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		scrubObject(pdfCtx, entry.Object, report)
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return out.Bytes(), report, nil
+}
+
+// scrubObject removes metadata, private data, thumbnails and script or
+// risky action entries from o and the direct objects within it
+func scrubObject(pdfCtx *model.Context, o types.Object, report *models.SanitizeReport) {
+	switch o := o.(type) {
+	case types.Dict:
+		scrubDict(pdfCtx, o, report)
+		for _, v := range o {
+			scrubObject(pdfCtx, v, report)
+		}
+	case types.StreamDict:
+		scrubDict(pdfCtx, o.Dict, report)
+		for _, v := range o.Dict {
+			scrubObject(pdfCtx, v, report)
+		}
+	case types.Array:
+		for _, v := range o {
+			scrubObject(pdfCtx, v, report)
+		}
+	}
+}
+
+func scrubDict(pdfCtx *model.Context, d types.Dict, report *models.SanitizeReport) {
+	if d["Metadata"] != nil {
+		d.Delete("Metadata")
+		report.XMPMetadata++
+	}
+	if d["PieceInfo"] != nil {
+		d.Delete("PieceInfo")
+		report.PrivateData++
+	}
+	if d["Thumb"] != nil {
+		d.Delete("Thumb")
+		report.Thumbnails++
+	}
+	// Additional actions fire on events such as opening a page or
+	// focusing a field; they are almost always scripts
+	if aa, err := pdfCtx.DereferenceDict(d["AA"]); err == nil && aa != nil {
+		d.Delete("AA")
+		report.JavaScript += aa.Len()
+	}
+	for _, key := range []string{"A", "OpenAction", "Next"} {
+		action, err := pdfCtx.DereferenceDict(d[key])
+		if err != nil || action == nil {
+			continue
+		}
+		kind := action.NameEntry("S")
+		switch {
+		case kind == nil:
+		case *kind == "JavaScript":
+			d.Delete(key)
+			report.JavaScript++
+		case riskyActions[*kind]:
+			d.Delete(key)
+			report.Actions++
+		}
+	}
+}
+
+// removeHiddenLayers deletes the content of optional content groups that
+// are off by default, then the layer definitions, so what remains is what
+// a viewer shows by default. Hidden layers are otherwise one toggle away
+// from revealing their content.
+func removeHiddenLayers(pdfCtx *model.Context, root types.Dict, report *models.SanitizeReport) error {
+	props, err := pdfCtx.DereferenceDict(root["OCProperties"])
+	if err != nil || props == nil {
+		return nil
+	}
+	root.Delete("OCProperties")
+
+	config, err := pdfCtx.DereferenceDict(props["D"])
+	if err != nil || config == nil {
+		return nil
+	}
+	refSet := func(key string) map[int]bool {
+		set := map[int]bool{}
+		a, _ := pdfCtx.DereferenceArray(config[key])
+		for _, o := range a {
+			if ref, ok := o.(types.IndirectRef); ok {
+				set[ref.ObjectNumber.Value()] = true
+			}
+		}
+		return set
+	}
+
+	hidden := refSet("OFF")
+	if base := config.NameEntry("BaseState"); base != nil && *base == "OFF" {
+		on := refSet("ON")
+		hidden = map[int]bool{}
+		all, _ := pdfCtx.DereferenceArray(props["OCGs"])
+		for _, o := range all {
+			if ref, ok := o.(types.IndirectRef); ok && !on[ref.ObjectNumber.Value()] {
+				hidden[ref.ObjectNumber.Value()] = true
+			}
+		}
+	}
+	if len(hidden) == 0 {
+		return nil
+	}
+	for objNr := range hidden {
+		entry := pdfCtx.Table[objNr]
+		if entry == nil {
+			continue
+		}
+		ocg, ok := entry.Object.(types.Dict)
+		if !ok {
+			continue
+		}
+		name, _ := pdfCtx.DereferenceText(ocg["Name"])
+		report.HiddenLayers = append(report.HiddenLayers, name)
+	}
+	sort.Strings(report.HiddenLayers)
+
+	l := &layerStripper{pdfCtx: pdfCtx, hidden: hidden}
+	objNrs := make([]int, 0, len(pdfCtx.Table))
+	for objNr := range pdfCtx.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	for _, objNr := range objNrs {
+		entry := pdfCtx.Table[objNr]
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		switch o := entry.Object.(type) {
+		case types.Dict:
+			if t := o.Type(); t != nil && *t == "Page" {
+				if err := l.stripPage(o); err != nil {
+					return fmt.Errorf("failed to remove hidden layers: %w", err)
+				}
+			}
+		case types.StreamDict:
+			if st := o.Subtype(); st != nil && *st == "Form" {
+				if err := l.stripForm(&o); err != nil {
+					return fmt.Errorf("failed to remove hidden layers: %w", err)
+				}
+				entry.Object = o
+			}
+		}
+	}
+	return nil
+}
+
+// layerStripper removes content belonging to hidden optional content
+// groups from pages and form XObjects
+type layerStripper struct {
+	pdfCtx *model.Context
+	hidden map[int]bool // Object numbers of hidden groups
+}
+
+// isHidden reports whether o, an OCG or an optional content membership
+// dict, hides what it is attached to. Membership dicts default to AnyOn,
+// so they hide only when every group they list is hidden.
+func (l *layerStripper) isHidden(o types.Object) bool {
+	ref, ok := o.(types.IndirectRef)
+	if ok && l.hidden[ref.ObjectNumber.Value()] {
+		return true
+	}
+	d, err := l.pdfCtx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return false
+	}
+	if t := d.Type(); t == nil || *t != "OCMD" {
+		return false
+	}
+	var groups types.Array
+	switch g := d["OCGs"].(type) {
+	case types.IndirectRef:
+		groups = types.Array{g}
+	case types.Array:
+		groups = g
+	}
+	if len(groups) == 0 {
+		return false
+	}
+	for _, g := range groups {
+		if ref, ok := g.(types.IndirectRef); !ok || !l.hidden[ref.ObjectNumber.Value()] {
+			return false
+		}
+	}
+	return true
+}
+
+// hiddenNames returns the names, as written in content streams ("/MC0"),
+// of hidden properties and XObjects in a resource dict
+func (l *layerStripper) hiddenNames(resources types.Dict) (props, xobjects map[string]bool) {
+	props, xobjects = map[string]bool{}, map[string]bool{}
+	if d, err := l.pdfCtx.DereferenceDict(resources["Properties"]); err == nil {
+		for name, o := range d {
+			if l.isHidden(o) {
+				props["/"+name] = true
+			}
+		}
+	}
+	if d, err := l.pdfCtx.DereferenceDict(resources["XObject"]); err == nil {
+		for name, o := range d {
+			if sd, _, err := l.pdfCtx.DereferenceStreamDict(o); err == nil && sd != nil && sd.Dict["OC"] != nil && l.isHidden(sd.Dict["OC"]) {
+				xobjects["/"+name] = true
+			}
+		}
+	}
+	return props, xobjects
+}
+
+func (l *layerStripper) stripPage(page types.Dict) error {
+	// Hidden annotations go with their layer
+	if annots, err := l.pdfCtx.DereferenceArray(page["Annots"]); err == nil && annots != nil {
+		kept := types.Array{}
+		for _, o := range annots {
+			annot, err := l.pdfCtx.DereferenceDict(o)
+			if err == nil && annot != nil && annot["OC"] != nil && l.isHidden(annot["OC"]) {
+				continue
+			}
+			kept = append(kept, o)
+		}
+		if len(kept) != len(annots) {
+			page.Update("Annots", kept)
+		}
+	}
+
+	resources := inheritedResources(l.pdfCtx, page)
+	props, xobjects := l.hiddenNames(resources)
+	if len(props) == 0 && len(xobjects) == 0 {
+		return nil
+	}
+
+	content, err := pageContent(l.pdfCtx, page)
+	if err != nil || content == nil {
+		return err
+	}
+	stripped, changed := stripHiddenContent(content, props, xobjects)
+	if !changed {
+		return nil
+	}
+	return setPageContent(l.pdfCtx, page, stripped)
+}
+
+func (l *layerStripper) stripForm(sd *types.StreamDict) error {
+	if sd.Dict["OC"] != nil && l.isHidden(sd.Dict["OC"]) {
+		return replaceStreamContent(sd, nil)
+	}
+
+	resources, _ := l.pdfCtx.DereferenceDict(sd.Dict["Resources"])
+	props, xobjects := l.hiddenNames(resources)
+	if len(props) == 0 && len(xobjects) == 0 {
+		return nil
+	}
+	if err := sd.Decode(); err != nil {
+		return err
+	}
+	stripped, changed := stripHiddenContent(sd.Content, props, xobjects)
+	if !changed {
+		return nil
+	}
+	return replaceStreamContent(sd, stripped)
+}
+
+// inheritedResources returns a page's resources, which may be set on an
+// ancestor in the page tree
+func inheritedResources(pdfCtx *model.Context, page types.Dict) types.Dict {
+	for d, depth := page, 0; d != nil && depth < 64; depth++ {
+		if res, err := pdfCtx.DereferenceDict(d["Resources"]); err == nil && res != nil {
+			return res
+		}
+		d, _ = pdfCtx.DereferenceDict(d["Parent"])
+	}
+	return nil
+}
+
+// pageContent concatenates a page's content streams. Streams split only
+// at token boundaries, so a newline between them is always safe.
+func pageContent(pdfCtx *model.Context, page types.Dict) ([]byte, error) {
+	o, err := pdfCtx.Dereference(page["Contents"])
+	if err != nil || o == nil {
+		return nil, err
+	}
+	streams := types.Array{o}
+	if a, ok := o.(types.Array); ok {
+		streams = a
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		sd, _, err := pdfCtx.DereferenceStreamDict(s)
+		if err != nil {
+			return nil, err
+		}
+		if sd == nil {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return nil, err
+		}
+		buf.Write(sd.Content)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// setPageContent replaces a page's content streams with one new stream
+func setPageContent(pdfCtx *model.Context, page types.Dict, content []byte) error {
+	sd, err := pdfCtx.NewStreamDictForBuf(content)
+	if err != nil {
+		return err
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	ref, err := pdfCtx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+	page.Update("Contents", *ref)
+	return nil
+}
+
+// replaceStreamContent swaps a stream's content, re-encoded with Flate
+func replaceStreamContent(sd *types.StreamDict, content []byte) error {
+	if content == nil {
+		content = []byte{}
+	}
+	sd.Content = content
+	sd.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+	sd.Dict.Update("Filter", types.Name(filter.Flate))
+	sd.Dict.Delete("DecodeParms")
+	return sd.Encode()
+}
+
+// stripHiddenContent drops marked-content sections tagged with hidden
+// properties ("/OC /name BDC ... EMC") and paints of hidden XObjects
+// ("/name Do") from a content stream
+func stripHiddenContent(content []byte, props, xobjects map[string]bool) ([]byte, bool) {
+	var out bytes.Buffer
+	changed := false
+	// One entry per open marked-content section: whether it is hidden
+	var sections []bool
+	inHidden := func() bool { return len(sections) > 0 && sections[len(sections)-1] }
+
+	for _, op := range contentOperations(content) {
+		drop := inHidden()
+		switch op.operator {
+		case "BDC":
+			hidden := drop
+			if len(op.operands) == 2 && op.operands[0] == "/OC" && props[op.operands[1]] {
+				hidden = true
+			}
+			sections = append(sections, hidden)
+			drop = hidden
+		case "BMC":
+			sections = append(sections, drop)
+		case "EMC":
+			if len(sections) > 0 {
+				sections = sections[:len(sections)-1]
+			}
+		case "Do":
+			if len(op.operands) == 1 && xobjects[op.operands[0]] {
+				drop = true
+			}
+		}
+		if drop {
+			changed = true
+			continue
+		}
+		out.Write(content[op.start:op.end])
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), changed
+}
+
+// contentOp is one operator with its raw operands; start and end delimit
+// its bytes in the stream
+type contentOp struct {
+	start, end int
+	operator   string
+	operands   []string
+}
+
+// contentOperations splits a content stream into operations. Inline
+// images (BI ... ID <data> EI) are kept as one operation.
+func contentOperations(data []byte) []contentOp {
+	var ops []contentOp
+	var operands []string
+	start := -1
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+			continue
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+		tokStart := i
+		switch c {
+		case '(':
+			i = skipLiteralString(data, i)
+		case '<':
+			if i+1 < len(data) && data[i+1] == '<' {
+				i = skipDict(data, i)
+			} else {
+				for i < len(data) && data[i] != '>' {
+					i++
+				}
+				i++
+			}
+		case '[', ']', '{', '}', '>', ')':
+			i++
+		case '/':
+			i++
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+		default:
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+		}
+		if i > len(data) {
+			i = len(data)
+		}
+		token := string(data[tokStart:i])
+
+		if isPDFDelimiter(c) || isOperand(token) {
+			operands = append(operands, token)
+			continue
+		}
+
+		if token == "ID" {
+			// Skip the inline image's binary data up to a delimited EI
+			i++
+			for i < len(data) {
+				if data[i] == 'E' && i+1 < len(data) && data[i+1] == 'I' && isPDFWhitespace(data[i-1]) &&
+					(i+2 == len(data) || isPDFWhitespace(data[i+2])) {
+					i += 2
+					break
+				}
+				i++
+			}
+			if i > len(data) {
+				i = len(data)
+			}
+			token = "EI"
+		}
+		if token == "BI" {
+			// Keep the image dictionary with the image data
+			continue
+		}
+		ops = append(ops, contentOp{start: start, end: i, operator: token, operands: operands})
+		operands = nil
+		start = -1
+	}
+	return ops
+}
+
+func skipLiteralString(data []byte, i int) int {
+	depth := 0
+	for ; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func skipDict(data []byte, i int) int {
+	depth := 0
+	for i < len(data) {
+		switch {
+		case data[i] == '(':
+			i = skipLiteralString(data, i)
+			continue
+		case data[i] == '<' && i+1 < len(data) && data[i+1] == '<':
+			depth++
+			i += 2
+			continue
+		case data[i] == '>' && i+1 < len(data) && data[i+1] == '>':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+			continue
+		}
+		i++
+	}
+	return i
+}
+
+func isOperand(token string) bool {
+	switch token {
+	case "true", "false", "null":
+		return true
+	}
+	c := token[0]
+	return c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9')
+}
+
+func isPDFWhitespace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// executableExtensions are attachment names treated as programs or scripts
+var executableExtensions = map[string]bool{
+	".exe": true, ".com": true, ".scr": true, ".pif": true, ".dll": true,
+	".msi": true, ".bat": true, ".cmd": true, ".ps1": true, ".vbs": true,
+	".vbe": true, ".js": true, ".jse": true, ".wsf": true, ".hta": true,
+	".jar": true, ".sh": true, ".app": true, ".dmg": true, ".apk": true,
+	".lnk": true, ".reg": true, ".docm": true, ".xlsm": true, ".pptm": true,
+}
+
+// executableMagic are the leading bytes of Windows, Linux and macOS binaries
+var executableMagic = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+}
+
+// actionTriggers maps the keys holding actions to what fires them
+var actionTriggers = map[string]string{
+	"OpenAction": "open",
+	"AA":         "event",
+	"A":          "click",
+}
+
+var severityRank = map[string]int{
+	models.ScanRiskNone:   0,
+	models.ScanRiskLow:    1,
+	models.ScanRiskMedium: 2,
+	models.ScanRiskHigh:   3,
+}
+
+// maxScriptDetail bounds the script excerpt included in a finding
+const maxScriptDetail = 120
+
+// ScanSecurity inspects a PDF for content that can act on the reader's
+// machine: JavaScript, launch actions, external URIs, form submission and
+// embedded executables. Scripts and URIs that run automatically rate
+// higher than ones behind a click. The document is only read; pdfcpu's
+// validation is skipped so malformed, possibly hostile files still scan.
+func (s *PDFService) ScanSecurity(ctx context.Context, in PDFReader) (*models.ScanReport, error) {
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+
+	sc := &scanner{
+		pdfCtx: pdfCtx,
+		seen:   map[int]bool{},
+		uris:   map[string]bool{},
+		report: &models.ScanReport{URIs: []string{}, EmbeddedExecutables: []string{}, Findings: []models.ScanFinding{}},
+	}
+
+	if root, err := pdfCtx.Catalog(); err == nil {
+		if names, err := pdfCtx.DereferenceDict(root["Names"]); err == nil && names != nil {
+			sc.walkNameTree(names["JavaScript"], 0, func(name string, o types.Object) {
+				sc.action(o, "document")
+			})
+		}
+		if form, err := pdfCtx.DereferenceDict(root["AcroForm"]); err == nil && form != nil && form["XFA"] != nil {
+			sc.add("xfa", models.ScanRiskLow, "", "XML form data, which may carry scripts")
+		}
+	}
+
+	for _, entry := range pdfCtx.Table {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		sc.object(entry.Object)
+	}
+
+	report := sc.report
+	for uri := range sc.uris {
+		report.URIs = append(report.URIs, uri)
+	}
+	sort.Strings(report.URIs)
+	sort.Strings(report.EmbeddedExecutables)
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] > severityRank[b.Severity]
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Detail < b.Detail
+	})
+
+	report.Risk = models.ScanRiskNone
+	if len(report.Findings) > 0 {
+		report.Risk = report.Findings[0].Severity
+	}
+	report.Safe = report.Risk != models.ScanRiskHigh
+	return report, nil
+}
+
+// scanner collects findings while walking the objects of a document
+type scanner struct {
+	pdfCtx *model.Context
+	seen   map[int]bool // Indirect actions and file specs already reported
+	uris   map[string]bool
+	report *models.ScanReport
+}
+
+func (sc *scanner) add(kind, severity, trigger, detail string) {
+	sc.report.Findings = append(sc.report.Findings, models.ScanFinding{
+		Type:     kind,
+		Severity: severity,
+		Trigger:  trigger,
+		Detail:   detail,
+	})
+}
+
+// once reports whether o is direct or an indirect object not seen before
+func (sc *scanner) once(o types.Object) bool {
+	ref, ok := o.(types.IndirectRef)
+	if !ok {
+		return true
+	}
+	if sc.seen[ref.ObjectNumber.Value()] {
+		return false
+	}
+	sc.seen[ref.ObjectNumber.Value()] = true
+	return true
+}
+
+// object inspects o and the direct objects within it
+func (sc *scanner) object(o types.Object) {
+	switch o := o.(type) {
+	case types.Dict:
+		sc.dict(o)
+		for _, v := range o {
+			sc.object(v)
+		}
+	case types.StreamDict:
+		sc.dict(o.Dict)
+	case types.Array:
+		for _, v := range o {
+			sc.object(v)
+		}
+	}
+}
+
+func (sc *scanner) dict(d types.Dict) {
+	for key, trigger := range actionTriggers {
+		if d[key] == nil {
+			continue
+		}
+		if key == "AA" {
+			// Additional actions map events (page open, field focus, ...)
+			// to actions
+			if aa, err := sc.pdfCtx.DereferenceDict(d["AA"]); err == nil && aa != nil {
+				for _, a := range aa {
+					sc.action(a, trigger)
+				}
+			}
+			continue
+		}
+		sc.action(d[key], trigger)
+	}
+	if d["EF"] != nil {
+		sc.embeddedFile(d)
+	}
+}
+
+// action reports the action o and the actions chained after it with Next
+func (sc *scanner) action(o types.Object, trigger string) {
+	if !sc.once(o) {
+		return
+	}
+	a, err := sc.pdfCtx.DereferenceDict(o)
+	if err != nil || a == nil {
+		return
+	}
+	auto := trigger != "click"
+
+	switch kind := a.NameEntry("S"); {
+	case kind == nil:
+	case *kind == "JavaScript":
+		sc.report.JavaScript++
+		severity := models.ScanRiskMedium
+		if auto {
+			severity = models.ScanRiskHigh
+		}
+		sc.add("javascript", severity, trigger, sc.script(a["JS"]))
+	case *kind == "Launch":
+		sc.report.LaunchActions++
+		target := sc.fileSpecName(a["F"])
+		if win, err := sc.pdfCtx.DereferenceDict(a["Win"]); err == nil && win != nil && target == "" {
+			target, _ = sc.pdfCtx.DereferenceText(win["F"])
+		}
+		sc.add("launch", models.ScanRiskHigh, trigger, target)
+	case *kind == "URI":
+		uri, _ := sc.pdfCtx.DereferenceText(a["URI"])
+		if uri != "" {
+			sc.uris[uri] = true
+		}
+		severity := models.ScanRiskLow
+		if auto {
+			severity = models.ScanRiskMedium
+		}
+		sc.add("uri", severity, trigger, uri)
+	case *kind == "SubmitForm":
+		sc.add("submit", models.ScanRiskMedium, trigger, sc.fileSpecName(a["F"]))
+	case *kind == "ImportData":
+		sc.add("import", models.ScanRiskMedium, trigger, sc.fileSpecName(a["F"]))
+	}
+
+	switch next := a["Next"].(type) {
+	case types.Array:
+		for _, n := range next {
+			sc.action(n, trigger)
+		}
+	case nil:
+	default:
+		if arr, err := sc.pdfCtx.DereferenceArray(next); err == nil && arr != nil {
+			for _, n := range arr {
+				sc.action(n, trigger)
+			}
+			return
+		}
+		sc.action(next, trigger)
+	}
+}
+
+// embeddedFile reports an attachment, as an executable when its name or
+// leading bytes say it is one
+func (sc *scanner) embeddedFile(spec types.Dict) {
+	ef, err := sc.pdfCtx.DereferenceDict(spec["EF"])
+	if err != nil || ef == nil {
+		return
+	}
+	name := sc.fileSpecName(spec)
+
+	executable := executableExtensions[strings.ToLower(filepath.Ext(name))]
+	for _, key := range []string{"F", "UF"} {
+		if executable || ef[key] == nil || !sc.once(ef[key]) {
+			continue
+		}
+		sd, _, err := sc.pdfCtx.DereferenceStreamDict(ef[key])
+		if err != nil || sd == nil || sd.Decode() != nil {
+			continue
+		}
+		for _, magic := range executableMagic {
+			if bytes.HasPrefix(sd.Content, magic) {
+				executable = true
+				break
+			}
+		}
+	}
+
+	if executable {
+		sc.report.EmbeddedExecutables = append(sc.report.EmbeddedExecutables, name)
+		sc.add("embedded-executable", models.ScanRiskHigh, "", name)
+		return
+	}
+	sc.add("embedded-file", models.ScanRiskLow, "", name)
+}
+
+// fileSpecName returns the name in a file specification, which is either
+// a string or a dict with UF or F
+func (sc *scanner) fileSpecName(o types.Object) string {
+	if o == nil {
+		return ""
+	}
+	if d, err := sc.pdfCtx.DereferenceDict(o); err == nil && d != nil {
+		for _, key := range []string{"UF", "F"} {
+			if name, err := sc.pdfCtx.DereferenceText(d[key]); err == nil && name != "" {
+				return name
+			}
+		}
+		return ""
+	}
+	name, _ := sc.pdfCtx.DereferenceText(o)
+	return name
+}
+
+// script returns the start of a script given as a string or a stream
+func (sc *scanner) script(o types.Object) string {
+	var js string
+	if sd, _, err := sc.pdfCtx.DereferenceStreamDict(o); err == nil && sd != nil {
+		if sd.Decode() == nil {
+			js = string(sd.Content)
+		}
+	} else {
+		js, _ = sc.pdfCtx.DereferenceText(o)
+	}
+	js = strings.Join(strings.Fields(js), " ")
+	if r := []rune(js); len(r) > maxScriptDetail {
+		js = string(r[:maxScriptDetail]) + "…"
+	}
+	return js
+}
+
+// walkNameTree calls fn for each entry of the name tree node o
+func (sc *scanner) walkNameTree(o types.Object, depth int, fn func(name string, value types.Object)) {
+	node, err := sc.pdfCtx.DereferenceDict(o)
+	if err != nil || node == nil || depth > 32 {
+		return
+	}
+	if names, err := sc.pdfCtx.DereferenceArray(node["Names"]); err == nil {
+		for i := 0; i+1 < len(names); i += 2 {
+			name, _ := sc.pdfCtx.DereferenceText(names[i])
+			fn(name, names[i+1])
+		}
+	}
+	if kids, err := sc.pdfCtx.DereferenceArray(node["Kids"]); err == nil {
+		for _, kid := range kids {
+			sc.walkNameTree(kid, depth+1, fn)
+		}
+	}
+}
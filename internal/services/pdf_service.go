@@ -3,26 +3,30 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
+	"brainy-pdf/internal/assets"
+	"brainy-pdf/internal/models"
 	"github.com/ledongthuc/pdf"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
-	"github.com/signintech/gopdf"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
 // PDFService handles all PDF operations using pdfcpu
 type PDFService struct {
-	tempDir string
-}
-
-func (s *PDFService) ensureTempDir() error {
-    return os.MkdirAll(s.tempDir, 0755)
+	scratch *ScratchSpace // a private temp dir per operation
 }
 
 // Result types
@@ -49,16 +53,109 @@ type CompressResult struct {
 
 // Option types
 type WatermarkOptions struct {
+	Type     string // WatermarkTypeText or WatermarkTypeImage
 	Text     string
 	Position string
 	Opacity  float64
 	FontSize float64
+	Color    string  // Hex color like #808080
+	Font     string  // Key of StandardFonts
+	Mode     string  // WatermarkModeDiagonal or WatermarkModeHorizontal
+	Rotation float64 // Degrees, overrides Mode when non-zero
+	Tile     bool    // Repeat the text across the whole page
+	Image    []byte  // PNG or JPEG logo for WatermarkTypeImage
+	Scale    float64 // Image width as a fraction of the page width
 }
 
+// Watermark types
+const (
+	WatermarkTypeText  = "text"
+	WatermarkTypeImage = "image"
+)
+
+// Watermark layout modes
+const (
+	WatermarkModeDiagonal   = "diagonal"
+	WatermarkModeHorizontal = "horizontal"
+)
+
+// MaxWatermarkImageSize bounds the logo uploaded for an image watermark
+const MaxWatermarkImageSize = 5 * 1024 * 1024 // bytes
+
+// watermarkAnchors maps image watermark positions to pdfcpu anchors and
+// the offset that keeps the image off the page edge
+var watermarkAnchors = map[string]struct {
+	anchor string
+	dx, dy float64
+}{
+	"center":        {"c", 0, 0},
+	"top-left":      {"tl", 20, -20},
+	"top-center":    {"tc", 0, -20},
+	"top-right":     {"tr", -20, -20},
+	"left":          {"l", 20, 0},
+	"right":         {"r", -20, 0},
+	"bottom-left":   {"bl", 20, 20},
+	"bottom-center": {"bc", 0, 20},
+	"bottom-right":  {"br", -20, 20},
+}
+
+// StandardFonts maps the font families offered to clients to the PDF
+// standard fonts bundled with pdfcpu and to the Go fonts embedded in the
+// binary, so no font files need to be installed. Unlike the standard fonts,
+// the Go fonts cover text beyond Latin-1, e.g. Greek and Cyrillic.
+var StandardFonts = map[string]string{
+	"helvetica":        "Helvetica",
+	"helvetica-bold":   "Helvetica-Bold",
+	"helvetica-italic": "Helvetica-Oblique",
+	"times":            "Times-Roman",
+	"times-bold":       "Times-Bold",
+	"times-italic":     "Times-Italic",
+	"courier":          "Courier",
+	"courier-bold":     "Courier-Bold",
+	"courier-italic":   "Courier-Oblique",
+	"go":               "GoRegular",
+	"go-bold":          "Go-Bold",
+	"go-italic":        "Go-Italic",
+}
+
+// ErrInvalidWatermark wraps watermark option validation failures
+var ErrInvalidWatermark = errors.New("invalid watermark")
+
+// maxWatermarkTiles bounds the number of stamps per page when tiling
+const maxWatermarkTiles = 200
+
 type PageNumberOptions struct {
-	Position  string
-	Format    string
-	StartFrom int
+	Position    string
+	Format      string // {n} is the page number, {total} the last number
+	StartFrom   int
+	Style       string // PageNumberStyleArabic, PageNumberStyleRoman or PageNumberStyleRomanUpper
+	FrontMatter int    // Leading numbered pages labelled i, ii, iii before the body starts at StartFrom
+	Skip        string // Page selection left unnumbered, e.g. "1" for the cover
+	Mirror      bool   // Swap left and right on even pages for duplex printing
+}
+
+// Page number styles
+const (
+	PageNumberStyleArabic     = "arabic"
+	PageNumberStyleRoman      = "roman"
+	PageNumberStyleRomanUpper = "roman-upper"
+)
+
+// ErrInvalidPageNumbers wraps page number option validation failures
+var ErrInvalidPageNumbers = errors.New("invalid page numbers")
+
+// pageNumberAnchors maps positions to pdfcpu anchors and the offset that
+// keeps the label off the page edge
+var pageNumberAnchors = map[string]struct {
+	anchor string
+	dx, dy float64
+}{
+	"bottom-center": {"bc", 0, 20},
+	"bottom-left":   {"bl", 30, 20},
+	"bottom-right":  {"br", -30, 20},
+	"top-center":    {"tc", 0, -20},
+	"top-left":      {"tl", 30, -20},
+	"top-right":     {"tr", -30, -20},
 }
 
 type CropOptions struct {
@@ -68,30 +165,84 @@ type CropOptions struct {
 	Left   float64
 }
 
+// DrawTextOptions places one block of text. X/Y is the bottom-left corner
+// of the block in points from the page's bottom-left; with center or right
+// alignment and no Width, X is the block's center or right edge instead.
+// Lines break on "\n" and, when Width is set, wrap to fit it.
 type DrawTextOptions struct {
-	Text      string
-	X         float64
-	Y         float64
-	FontSize  float64
-	Color     string // Hex color like #FF0000
-	FontFamily string
+	Text       string
+	X          float64
+	Y          float64
+	FontSize   float64
+	Color      string  // Hex color like #FF0000
+	FontFamily string  // Key of StandardFonts
+	Pages      string  // pdfcpu page selection, "" for all pages
+	Width      float64 // Wrap width in points, 0 to break on newlines only
+	Align      string  // TextAlignLeft, TextAlignCenter or TextAlignRight
+	Rotation   float64 // Degrees counter-clockwise
+	Unit       string  // UnitPoints, or UnitPercent for X, Y and Width relative to each page
+}
+
+// Placement coordinate units. Percentages are resolved against every
+// target page, so a placement lands in the same spot on mixed page sizes.
+const (
+	UnitPoints  = "pt"
+	UnitPercent = "percent"
+)
+
+// ErrInvalidPlacement wraps coordinate validation failures
+var ErrInvalidPlacement = errors.New("invalid placement")
+
+// ErrUnknownBadge is returned for badge types with no embedded image
+var ErrUnknownBadge = errors.New("unknown badge type")
+
+// badgeSize is the width of a badge at scale 1, in points
+const badgeSize = 48
+
+// Text alignments for DrawTextOptions
+const (
+	TextAlignLeft   = "left"
+	TextAlignCenter = "center"
+	TextAlignRight  = "right"
+)
+
+// ErrInvalidDrawText wraps text placement validation failures
+var ErrInvalidDrawText = errors.New("invalid text placement")
+
+// MaxTextPlacements bounds the placements drawn by one DrawTextBatch call
+const MaxTextPlacements = 100
+
+// ImageStampOptions places an image (e.g. a stored signature). X/Y is the
+// bottom-left corner in points from the page's bottom-left; Width is the
+// rendered width in points, height follows the aspect ratio.
+type ImageStampOptions struct {
+	X        float64
+	Y        float64
+	Width    float64
+	Pages    string // pdfcpu page selection, "" for all pages
+	Opacity  float64
+	Rotation float64
 }
 
 type BadgeOptions struct {
-	Type     string // "gold", "silver", "verified"
+	Type     string // An embedded badge: "gold", "silver" or "verified"
 	X        float64
 	Y        float64
 	Scale    float64
+	Unit     string // UnitPoints or UnitPercent
 }
 
 // NewPDFService creates a new PDF service
 func NewPDFService() (*PDFService, error) {
-	tempDir := filepath.Join(os.TempDir(), "brainy-pdf-ops")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
+	if err := installEmbeddedFonts(); err != nil {
+		return nil, err
+	}
+	scratch, err := NewScratchSpace(filepath.Join(os.TempDir(), "brainy-pdf-ops"), 0)
+	if err != nil {
 		return nil, err
 	}
 	return &PDFService{
-		tempDir: tempDir,
+		scratch: scratch,
 	}, nil
 }
 
@@ -102,29 +253,29 @@ func (s *PDFService) getConfig() *model.Configuration {
 }
 
 // ValidatePDF validates a PDF file
-func (s *PDFService) ValidatePDF(data []byte) error {
-	_, err := api.ReadContext(bytes.NewReader(data), nil)
+func (s *PDFService) ValidatePDF(in PDFReader) error {
+	_, err := api.ReadContext(readSeeker(in), nil)
 	return err
 }
 
 // GetPageCount returns the number of pages in a PDF
-func (s *PDFService) GetPageCount(data []byte) (int, error) {
+func (s *PDFService) GetPageCount(in PDFReader) (int, error) {
 	// Try pdfcpu first
-	ctx, err := api.ReadContext(bytes.NewReader(data), nil)
+	ctx, err := api.ReadContext(readSeeker(in), nil)
 	if err == nil && ctx.PageCount > 0 {
 		return ctx.PageCount, nil
 	}
 
 	// Fallback to ledongthuc/pdf
-	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	r, err := pdf.NewReader(in, in.Size())
 	if err == nil {
 		return r.NumPage(), nil
 	}
 
     // Fallback 3: Heuristic count of /Type /Page
     // This is not 100% accurate but better than 0 for display
-    count1 := bytes.Count(data, []byte("/Type /Page"))
-    count2 := bytes.Count(data, []byte("/Type/Page"))
+    count1 := countInReader(in, []byte("/Type /Page"))
+    count2 := countInReader(in, []byte("/Type/Page"))
     estimated := count1 + count2
     if estimated > 0 {
         return estimated, nil
@@ -134,8 +285,8 @@ func (s *PDFService) GetPageCount(data []byte) (int, error) {
 }
 
 // GetInfo returns PDF metadata
-func (s *PDFService) GetInfo(data []byte) (map[string]string, error) {
-	ctx, err := api.ReadContext(bytes.NewReader(data), nil)
+func (s *PDFService) GetInfo(in PDFReader) (map[string]string, error) {
+	ctx, err := api.ReadContext(readSeeker(in), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -158,29 +309,29 @@ func (s *PDFService) GetInfo(data []byte) (map[string]string, error) {
 }
 
 // Merge combines multiple PDFs into one
-func (s *PDFService) Merge(ctx context.Context, pdfData [][]byte) (*MergeResult, error) {
-	if len(pdfData) < 2 {
+func (s *PDFService) Merge(ctx context.Context, inputs []PDFReader) (*MergeResult, error) {
+	if len(inputs) < 2 {
 		return nil, fmt.Errorf("at least 2 files required for merge")
 	}
 
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+	scratch, err := s.scratch.New("merge")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
 	// Create temp files for each PDF
-	tempFiles := make([]string, len(pdfData))
-	for i, data := range pdfData {
-		tempFile := filepath.Join(s.tempDir, fmt.Sprintf("merge_input_%d_%d.pdf", time.Now().UnixNano(), i))
-		if err := os.WriteFile(tempFile, data, 0644); err != nil {
+	tempFiles := make([]string, len(inputs))
+	for i, in := range inputs {
+		tempFile := scratch.Path("input.pdf")
+		if err := writeInput(scratch, tempFile, in); err != nil {
 			return nil, err
 		}
 		tempFiles[i] = tempFile
-		defer os.Remove(tempFile)
 	}
 
 	// Output file
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("merged_%d.pdf", time.Now().UnixNano()))
-	defer os.Remove(outputFile)
+	outputFile := scratch.Path("merged.pdf")
 
 	// Merge using pdfcpu
 	if err := api.MergeCreateFile(tempFiles, outputFile, false, s.getConfig()); err != nil {
@@ -193,7 +344,7 @@ func (s *PDFService) Merge(ctx context.Context, pdfData [][]byte) (*MergeResult,
 		return nil, err
 	}
 
-	pageCount, _ := s.GetPageCount(result)
+	pageCount, _ := s.GetPageCount(bytes.NewReader(result))
 
 	return &MergeResult{
 		Data:      result,
@@ -201,71 +352,76 @@ func (s *PDFService) Merge(ctx context.Context, pdfData [][]byte) (*MergeResult,
 	}, nil
 }
 
-// Split splits a PDF based on page specification
-func (s *PDFService) Split(ctx context.Context, data []byte, pages string) (*SplitResult, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
-
-	// Create temp input file
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("split_input_%d.pdf", time.Now().UnixNano()))
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+// Split splits a PDF based on page specification, reporting each range
+// done to progress (stage "process")
+func (s *PDFService) Split(ctx context.Context, in PDFReader, pages string, progress ProgressFunc) (*SplitResult, error) {
+	scratch, err := s.scratch.New("split")
+	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
+	defer scratch.Close()
 
-	// Create temp output directory
-	outputDir := filepath.Join(s.tempDir, fmt.Sprintf("split_output_%d", time.Now().UnixNano()))
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	// Create temp input file
+	inputFile := scratch.Path("input.pdf")
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.RemoveAll(outputDir)
 
-	// Split using pdfcpu
-	if err := api.SplitFile(inputFile, outputDir, 1, s.getConfig()); err != nil {
-		return nil, fmt.Errorf("split failed: %w", err)
+	// Produce one output file per comma-separated range ("1-3, 4-7" -> 2 files)
+	var parts []string
+	for _, part := range strings.Split(pages, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
 	}
+	var files [][]byte
+	for i, part := range parts {
+		outputFile := scratch.Path("output.pdf")
+		if err := api.CollectFile(inputFile, outputFile, []string{part}, s.getConfig()); err != nil {
+			os.Remove(outputFile)
+			return nil, fmt.Errorf("split failed for range %s: %w", part, err)
+		}
 
-	// Read all split files
-	entries, err := os.ReadDir(outputDir)
-	if err != nil {
-		return nil, err
+		data, err := os.ReadFile(outputFile)
+		os.Remove(outputFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, data)
+		report(progress, "process", int64(i+1), int64(len(parts)))
 	}
 
-	var files [][]byte
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".pdf") {
-			data, err := os.ReadFile(filepath.Join(outputDir, entry.Name()))
-			if err != nil {
-				continue
-			}
-			files = append(files, data)
-		}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("split failed: no page ranges given")
 	}
 
 	return &SplitResult{Files: files}, nil
 }
 
 // Rotate rotates pages in a PDF
-func (s *PDFService) Rotate(ctx context.Context, data []byte, pages string, angle int) (*RotateResult, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+func (s *PDFService) Rotate(ctx context.Context, in PDFReader, pages string, angle int) (*RotateResult, error) {
+	scratch, err := s.scratch.New("rotate")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
 	// Create temp files
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("rotate_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("rotate_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
-	// Parse pages (nil means all pages)
+	// Parse pages (nil means all pages); accepts lists like "1,3,5-7"
 	var pageSelection []string
 	if pages != "" && pages != "1-" {
-		pageSelection = []string{pages}
+		sel, err := api.ParsePageSelection(pages)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page selection: %w", err)
+		}
+		pageSelection = sel
 	}
 
 	// Rotate using pdfcpu
@@ -279,7 +435,7 @@ func (s *PDFService) Rotate(ctx context.Context, data []byte, pages string, angl
 		return nil, err
 	}
 
-	pageCount, _ := s.GetPageCount(result)
+	pageCount, _ := s.GetPageCount(bytes.NewReader(result))
 
 	return &RotateResult{
 		Data:      result,
@@ -287,23 +443,85 @@ func (s *PDFService) Rotate(ctx context.Context, data []byte, pages string, angl
 	}, nil
 }
 
+// ErrInvalidRotation wraps per-page rotation validation failures
+var ErrInvalidRotation = errors.New("invalid rotation")
+
+// RotatePages rotates each page in rotations, keyed by page number, by its
+// angle (90, 180 or 270 degrees) in a single pass. Other pages are left as
+// they are.
+func (s *PDFService) RotatePages(ctx context.Context, in PDFReader, rotations map[int]int) (*RotateResult, error) {
+	pdfCtx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+	if err := rotatePages(ctx, pdfCtx, rotations); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &out); err != nil {
+		return nil, fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return &RotateResult{
+		Data:      out.Bytes(),
+		PageCount: pdfCtx.PageCount,
+	}, nil
+}
+
+// rotatePages checks rotations against pdfCtx and applies them, one
+// pdfcpu rotation per distinct angle
+func rotatePages(ctx context.Context, pdfCtx *model.Context, rotations map[int]int) error {
+	if len(rotations) == 0 {
+		return fmt.Errorf("%w: no pages to rotate", ErrInvalidRotation)
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return fmt.Errorf("failed to validate pdf: %w", err)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return fmt.Errorf("failed to count pages: %w", err)
+	}
+
+	byAngle := map[int]types.IntSet{}
+	for page, angle := range rotations {
+		if page < 1 || page > pdfCtx.PageCount {
+			return fmt.Errorf("%w: page %d is not in the document (1-%d)", ErrInvalidRotation, page, pdfCtx.PageCount)
+		}
+		if angle != 90 && angle != 180 && angle != 270 {
+			return fmt.Errorf("%w: angle for page %d must be 90, 180 or 270", ErrInvalidRotation, page)
+		}
+		if byAngle[angle] == nil {
+			byAngle[angle] = types.IntSet{}
+		}
+		byAngle[angle][page] = true
+	}
+	for angle, pages := range byAngle {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := pdfcpu.RotatePages(pdfCtx, pages, angle); err != nil {
+			return fmt.Errorf("rotate failed: %w", err)
+		}
+	}
+	return nil
+}
+
 // Compress optimizes a PDF
-func (s *PDFService) Compress(ctx context.Context, data []byte, quality string) (*CompressResult, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+func (s *PDFService) Compress(ctx context.Context, in PDFReader, quality string) (*CompressResult, error) {
+	scratch, err := s.scratch.New("compress")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	sizeBefore := int64(len(data))
+	sizeBefore := in.Size()
 
 	// Create temp files
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("compress_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("compress_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
 	// Optimize using pdfcpu
 	if err := api.OptimizeFile(inputFile, outputFile, s.getConfig()); err != nil {
@@ -328,22 +546,27 @@ func (s *PDFService) Compress(ctx context.Context, data []byte, quality string)
 }
 
 // ExtractPages extracts specific pages from a PDF
-func (s *PDFService) ExtractPages(ctx context.Context, data []byte, pages string) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+func (s *PDFService) ExtractPages(ctx context.Context, in PDFReader, pages string) ([]byte, error) {
+	scratch, err := s.scratch.New("extract")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("extract_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("extract_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
-	// Extract using pdfcpu
-	if err := api.ExtractPagesFile(inputFile, outputFile, []string{pages}, s.getConfig()); err != nil {
+	selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page selection: %w", err)
+	}
+
+	// Collect the selected pages, in order, into one PDF
+	if err := api.CollectFile(inputFile, outputFile, selection, s.getConfig()); err != nil {
 		return nil, fmt.Errorf("extract failed: %w", err)
 	}
 
@@ -351,22 +574,27 @@ func (s *PDFService) ExtractPages(ctx context.Context, data []byte, pages string
 }
 
 // RemovePages removes specific pages from a PDF
-func (s *PDFService) RemovePages(ctx context.Context, data []byte, pages string) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+func (s *PDFService) RemovePages(ctx context.Context, in PDFReader, pages string) ([]byte, error) {
+	scratch, err := s.scratch.New("remove")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("remove_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("remove_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
+
+	selection, err := api.ParsePageSelection(pages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page selection: %w", err)
+	}
 
 	// Remove using pdfcpu
-	if err := api.RemovePagesFile(inputFile, outputFile, []string{pages}, s.getConfig()); err != nil {
+	if err := api.RemovePagesFile(inputFile, outputFile, selection, s.getConfig()); err != nil {
 		return nil, fmt.Errorf("remove failed: %w", err)
 	}
 
@@ -374,19 +602,19 @@ func (s *PDFService) RemovePages(ctx context.Context, data []byte, pages string)
 }
 
 // OrganizePages reorders pages in a PDF
-func (s *PDFService) OrganizePages(ctx context.Context, data []byte, order []int) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+func (s *PDFService) OrganizePages(ctx context.Context, in PDFReader, order []int) ([]byte, error) {
+	scratch, err := s.scratch.New("organize")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("organize_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("organize_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
 	// Convert order to string format for pdfcpu
 	var orderStr []string
@@ -402,253 +630,915 @@ func (s *PDFService) OrganizePages(ctx context.Context, data []byte, order []int
 	return os.ReadFile(outputFile)
 }
 
-// AddWatermark adds a text watermark to a PDF
-func (s *PDFService) AddWatermark(ctx context.Context, data []byte, opts WatermarkOptions) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+// ValidHexColor reports whether s is a #RRGGBB color
+func ValidHexColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	_, err := strconv.ParseUint(s[1:], 16, 32)
+	return err == nil
+}
+
+// NormalizeWatermarkOptions validates opts and fills in the defaults:
+// Helvetica, #808080, 45 degree diagonal for text; centered at half the
+// page width for images
+func NormalizeWatermarkOptions(opts WatermarkOptions) (WatermarkOptions, error) {
+	switch opts.Type = strings.ToLower(strings.TrimSpace(opts.Type)); opts.Type {
+	case "":
+		opts.Type = WatermarkTypeText
+	case WatermarkTypeText:
+	case WatermarkTypeImage:
+		return normalizeImageWatermark(opts)
+	default:
+		return opts, fmt.Errorf("%w: type must be %q or %q", ErrInvalidWatermark, WatermarkTypeText, WatermarkTypeImage)
+	}
+
+	if strings.TrimSpace(opts.Text) == "" {
+		return opts, fmt.Errorf("%w: text is required", ErrInvalidWatermark)
+	}
+	if opts.FontSize == 0 {
+		opts.FontSize = 48
+	}
+	if opts.FontSize < 6 || opts.FontSize > 200 {
+		return opts, fmt.Errorf("%w: fontSize must be between 6 and 200", ErrInvalidWatermark)
+	}
+	if opts.Opacity == 0 {
+		opts.Opacity = 0.3
+	}
+	if opts.Opacity < 0 || opts.Opacity > 1 {
+		return opts, fmt.Errorf("%w: opacity must be between 0 and 1", ErrInvalidWatermark)
+	}
+
+	if opts.Color == "" {
+		opts.Color = "#808080"
+	}
+	if !ValidHexColor(opts.Color) {
+		return opts, fmt.Errorf("%w: color must be a hex value like #808080", ErrInvalidWatermark)
+	}
+
+	opts.Font = strings.ToLower(strings.TrimSpace(opts.Font))
+	if opts.Font == "" {
+		opts.Font = "helvetica"
+	}
+	if _, ok := StandardFonts[opts.Font]; !ok {
+		return opts, fmt.Errorf("%w: unsupported font %q", ErrInvalidWatermark, opts.Font)
+	}
+
+	if opts.Rotation < -180 || opts.Rotation > 180 {
+		return opts, fmt.Errorf("%w: rotation must be between -180 and 180", ErrInvalidWatermark)
+	}
+	switch opts.Mode = strings.ToLower(strings.TrimSpace(opts.Mode)); opts.Mode {
+	case "":
+		opts.Mode = WatermarkModeDiagonal
+		if opts.Rotation == 0 {
+			opts.Rotation = 45
+		}
+	case WatermarkModeDiagonal:
+		if opts.Rotation == 0 {
+			opts.Rotation = 45
+		}
+	case WatermarkModeHorizontal:
+		if opts.Rotation != 0 {
+			return opts, fmt.Errorf("%w: rotation cannot be combined with horizontal mode", ErrInvalidWatermark)
+		}
+	default:
+		return opts, fmt.Errorf("%w: mode must be %q or %q", ErrInvalidWatermark, WatermarkModeDiagonal, WatermarkModeHorizontal)
+	}
+	return opts, nil
+}
+
+// normalizeImageWatermark validates the options of an image watermark.
+// The text-only fields are cleared so the applied settings echo cleanly.
+func normalizeImageWatermark(opts WatermarkOptions) (WatermarkOptions, error) {
+	if len(opts.Image) == 0 {
+		return opts, fmt.Errorf("%w: image is required", ErrInvalidWatermark)
+	}
+	if len(opts.Image) > MaxWatermarkImageSize {
+		return opts, fmt.Errorf("%w: image exceeds %d MB", ErrInvalidWatermark, MaxWatermarkImageSize>>20)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(opts.Image)); err != nil || (format != "png" && format != "jpeg") {
+		return opts, fmt.Errorf("%w: image must be a PNG or JPEG", ErrInvalidWatermark)
+	}
+
+	if opts.Position == "" {
+		opts.Position = "center"
+	}
+	if _, ok := watermarkAnchors[opts.Position]; !ok {
+		return opts, fmt.Errorf("%w: unsupported position %q", ErrInvalidWatermark, opts.Position)
+	}
+	if opts.Scale == 0 {
+		opts.Scale = 0.5
+	}
+	if opts.Scale < 0.05 || opts.Scale > 1 {
+		return opts, fmt.Errorf("%w: scale must be between 0.05 and 1", ErrInvalidWatermark)
+	}
+	if opts.Opacity == 0 {
+		opts.Opacity = 0.3
+	}
+	if opts.Opacity < 0 || opts.Opacity > 1 {
+		return opts, fmt.Errorf("%w: opacity must be between 0 and 1", ErrInvalidWatermark)
+	}
+	if opts.Rotation < -180 || opts.Rotation > 180 {
+		return opts, fmt.Errorf("%w: rotation must be between -180 and 180", ErrInvalidWatermark)
+	}
+	if opts.Tile {
+		return opts, fmt.Errorf("%w: tile is only supported for text watermarks", ErrInvalidWatermark)
+	}
+
+	opts.Text, opts.FontSize, opts.Color, opts.Font, opts.Mode = "", 0, "", "", ""
+	return opts, nil
+}
+
+// AddWatermark adds a text or image watermark to a PDF
+func (s *PDFService) AddWatermark(ctx context.Context, in PDFReader, opts WatermarkOptions) ([]byte, error) {
+	opts, err := NormalizeWatermarkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Type == WatermarkTypeImage {
+		return s.addImageWatermark(in, opts)
+	}
+
+	scratch, err := s.scratch.New("watermark")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("watermark_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("watermark_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
+
+	if opts.Tile {
+		if err := s.addTiledWatermark(inputFile, outputFile, in, opts); err != nil {
+			return nil, fmt.Errorf("watermark failed: %w", err)
+		}
+		return os.ReadFile(outputFile)
+	}
 
 	// Build watermark description
 	// Format: "font:Helvetica, points:48, color:#808080, opacity:0.3, rotation:45"
-	fontSize := opts.FontSize
-	if fontSize == 0 {
-		fontSize = 48
-	}
-	opacity := opts.Opacity
-	if opacity == 0 {
-		opacity = 0.3
-	}
-	
-	desc := fmt.Sprintf("font:Helvetica, points:%d, color:#808080, opacity:%.2f, rotation:45, scale:1.0 rel",
-		int(fontSize), opacity)
+	desc := fmt.Sprintf("font:%s, points:%d, color:%s, opacity:%.2f, rotation:%.1f, scale:1.0 rel",
+		StandardFonts[opts.Font], int(opts.FontSize), opts.Color, opts.Opacity, opts.Rotation)
 
 	// AddTextWatermarksFile(inFile, outFile, selectedPages, onTop, text, desc, conf)
 	if err := api.AddTextWatermarksFile(inputFile, outputFile, nil, true, opts.Text, desc, s.getConfig()); err != nil {
 		// If fails, return original
-		return data, nil
+		return readInput(in)
 	}
 
 	result, err := os.ReadFile(outputFile)
 	if err != nil {
-		return data, nil
+		return readInput(in)
 	}
 	return result, nil
 }
 
-// AddPageNumbers adds page numbers to a PDF
-func (s *PDFService) AddPageNumbers(ctx context.Context, data []byte, opts PageNumberOptions) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+// addImageWatermark stamps opts.Image on every page, scaled relative to
+// each page's width
+func (s *PDFService) addImageWatermark(in PDFReader, opts WatermarkOptions) ([]byte, error) {
+	scratch, err := s.scratch.New("watermark")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("pagenums_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("pagenums_output_%d.pdf", time.Now().UnixNano()))
-	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	// pdfcpu tells the image format from the file extension
+	_, format, _ := image.DecodeConfig(bytes.NewReader(opts.Image))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
+	if err := writeInput(scratch, inputFile, in); err != nil {
+		return nil, err
+	}
+	imageFile, err := scratch.WriteFile("logo."+strings.Replace(format, "jpeg", "jpg", 1), opts.Image)
+	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
-	// Determine position
-	pos := "bc" // bottom center as default
-	switch opts.Position {
-	case "bottom-left":
-		pos = "bl"
-	case "bottom-right":
-		pos = "br"
-	case "top-center":
-		pos = "tc"
-	case "top-left":
-		pos = "tl"
-	case "top-right":
-		pos = "tr"
+	a := watermarkAnchors[opts.Position]
+	desc := fmt.Sprintf("position:%s, offset:%.0f %.0f, scalefactor:%.2f rel, rotation:%.1f, opacity:%.2f",
+		a.anchor, a.dx, a.dy, opts.Scale, opts.Rotation, opts.Opacity)
+
+	// AddImageWatermarksFile(inFile, outFile, selectedPages, onTop, imageFile, desc, conf)
+	if err := api.AddImageWatermarksFile(inputFile, outputFile, nil, true, imageFile, desc, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("watermark failed: %w", err)
 	}
+	return os.ReadFile(outputFile)
+}
 
-	// Build description for page number stamp
-	// Using %p as placeholder for page number
-	desc := fmt.Sprintf("font:Helvetica, points:12, pos:%s, color:#333333", pos)
+// addTiledWatermark repeats the text in a grid over every page. Tiles use
+// the absolute font size; the grid pitch is estimated from the text length.
+func (s *PDFService) addTiledWatermark(inputFile, outputFile string, in PDFReader, opts WatermarkOptions) error {
+	dims, err := api.PageDims(readSeeker(in), s.getConfig())
+	if err != nil {
+		return err
+	}
 
-	// Text with page number placeholder
-	text := "Page %p"
-	if opts.Format != "" {
-		text = strings.ReplaceAll(opts.Format, "{n}", "%p")
+	stepX := opts.FontSize*0.6*float64(len([]rune(opts.Text))) + opts.FontSize*2
+	stepY := opts.FontSize * 4
+	desc := func(dx, dy float64) string {
+		return fmt.Sprintf("font:%s, points:%d, color:%s, opacity:%.2f, rotation:%.1f, scale:1 abs, position:bl, offset:%.2f %.2f",
+			StandardFonts[opts.Font], int(opts.FontSize), opts.Color, opts.Opacity, opts.Rotation, dx, dy)
 	}
 
-	// AddTextWatermarksFile(inFile, outFile, selectedPages, onTop, text, desc, conf)
-	if err := api.AddTextWatermarksFile(inputFile, outputFile, nil, true, text, desc, s.getConfig()); err != nil {
-		return data, nil
+	m := make(map[int][]*model.Watermark, len(dims))
+	for i, dim := range dims {
+		var wms []*model.Watermark
+		for row, y := 0, stepY/2; y < dim.Height && len(wms) < maxWatermarkTiles; row, y = row+1, y+stepY {
+			// Offset alternate rows so the pattern doesn't form columns
+			x := 0.0
+			if row%2 == 1 {
+				x = -stepX / 2
+			}
+			for ; x < dim.Width && len(wms) < maxWatermarkTiles; x += stepX {
+				wm, err := api.TextWatermark(opts.Text, desc(x, y), true, false, types.POINTS)
+				if err != nil {
+					return err
+				}
+				wms = append(wms, wm)
+			}
+		}
+		m[i+1] = wms
 	}
 
-	result, err := os.ReadFile(outputFile)
+	return api.AddWatermarksSliceMapFile(inputFile, outputFile, m, s.getConfig())
+}
+
+// NormalizePageNumberOptions validates opts and fills in the defaults
+func NormalizePageNumberOptions(opts PageNumberOptions) (PageNumberOptions, error) {
+	if opts.Position == "" {
+		opts.Position = "bottom-center"
+	}
+	if _, ok := pageNumberAnchors[opts.Position]; !ok {
+		return opts, fmt.Errorf("%w: unsupported position %q", ErrInvalidPageNumbers, opts.Position)
+	}
+	if opts.Format == "" {
+		opts.Format = "{n}"
+	}
+	if !strings.Contains(opts.Format, "{n}") {
+		return opts, fmt.Errorf("%w: format must contain {n}", ErrInvalidPageNumbers)
+	}
+	if opts.StartFrom == 0 {
+		opts.StartFrom = 1
+	}
+	if opts.StartFrom < 1 || opts.StartFrom > 9999 {
+		return opts, fmt.Errorf("%w: startFrom must be between 1 and 9999", ErrInvalidPageNumbers)
+	}
+	if opts.FrontMatter < 0 {
+		return opts, fmt.Errorf("%w: frontMatter cannot be negative", ErrInvalidPageNumbers)
+	}
+	switch opts.Style = strings.ToLower(strings.TrimSpace(opts.Style)); opts.Style {
+	case "":
+		opts.Style = PageNumberStyleArabic
+	case PageNumberStyleArabic, PageNumberStyleRoman, PageNumberStyleRomanUpper:
+	default:
+		return opts, fmt.Errorf("%w: style must be %q, %q or %q", ErrInvalidPageNumbers,
+			PageNumberStyleArabic, PageNumberStyleRoman, PageNumberStyleRomanUpper)
+	}
+	if opts.Style != PageNumberStyleArabic && opts.StartFrom+opts.FrontMatter > 3999 {
+		return opts, fmt.Errorf("%w: roman numerals stop at 3999", ErrInvalidPageNumbers)
+	}
+	if opts.Skip != "" {
+		if _, err := api.ParsePageSelection(opts.Skip); err != nil {
+			return opts, fmt.Errorf("%w: invalid skip selection: %v", ErrInvalidPageNumbers, err)
+		}
+	}
+	return opts, nil
+}
+
+// PageNumberLabels returns the label stamped on each page, keyed by page
+// number. Skipped pages get no label and don't consume a number, so
+// skipping the cover makes the next page StartFrom. {total} is the last
+// body number, or the front matter count on front matter pages.
+func PageNumberLabels(pageCount int, opts PageNumberOptions) (map[int]string, error) {
+	skipped := map[int]bool{}
+	if opts.Skip != "" {
+		selection, err := api.ParsePageSelection(opts.Skip)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid skip selection: %v", ErrInvalidPageNumbers, err)
+		}
+		set, err := api.PagesForPageSelection(pageCount, selection, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid skip selection: %v", ErrInvalidPageNumbers, err)
+		}
+		for page, ok := range set {
+			skipped[page] = ok
+		}
+	}
+
+	numbered := 0
+	for page := 1; page <= pageCount; page++ {
+		if !skipped[page] {
+			numbered++
+		}
+	}
+	frontMatter := opts.FrontMatter
+	if frontMatter > numbered {
+		frontMatter = numbered
+	}
+	bodyTotal := opts.StartFrom + numbered - frontMatter - 1
+
+	labels := make(map[int]string, numbered)
+	seq := 0
+	for page := 1; page <= pageCount; page++ {
+		if skipped[page] {
+			continue
+		}
+		var n, total string
+		if seq < frontMatter {
+			n, total = toRoman(seq+1), toRoman(frontMatter)
+		} else {
+			n, total = formatPageNumber(opts.StartFrom+seq-frontMatter, opts.Style), formatPageNumber(bodyTotal, opts.Style)
+		}
+		labels[page] = strings.NewReplacer("{n}", n, "{total}", total).Replace(opts.Format)
+		seq++
+	}
+	return labels, nil
+}
+
+func formatPageNumber(n int, style string) string {
+	switch style {
+	case PageNumberStyleRoman:
+		return toRoman(n)
+	case PageNumberStyleRomanUpper:
+		return strings.ToUpper(toRoman(n))
+	default:
+		return strconv.Itoa(n)
+	}
+}
+
+// toRoman formats 1..3999 as lowercase roman numerals
+func toRoman(n int) string {
+	if n < 1 || n > 3999 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// mirrorPosition swaps left and right, for even pages when printing duplex
+func mirrorPosition(position string) string {
+	switch {
+	case strings.HasSuffix(position, "-left"):
+		return strings.TrimSuffix(position, "-left") + "-right"
+	case strings.HasSuffix(position, "-right"):
+		return strings.TrimSuffix(position, "-right") + "-left"
+	}
+	return position
+}
+
+// AddPageNumbers adds page numbers to a PDF. Every label is computed up
+// front and stamped as its own watermark, since pdfcpu's %p placeholder
+// knows neither offsets nor numeral styles.
+func (s *PDFService) AddPageNumbers(ctx context.Context, in PDFReader, opts PageNumberOptions) ([]byte, error) {
+	opts, err := NormalizePageNumberOptions(opts)
 	if err != nil {
-		return data, nil
+		return nil, err
 	}
-	return result, nil
+
+	scratch, err := s.scratch.New("page-numbers")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+
+	pageCount, err := s.GetPageCount(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+	labels, err := PageNumberLabels(pageCount, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return readInput(in)
+	}
+
+	m := make(map[int]*model.Watermark, len(labels))
+	for page, label := range labels {
+		position := opts.Position
+		if opts.Mirror && page%2 == 0 {
+			position = mirrorPosition(position)
+		}
+		a := pageNumberAnchors[position]
+		desc := fmt.Sprintf("font:Helvetica, points:12, color:#333333, rotation:0, scale:1 abs, pos:%s, offset:%.0f %.0f",
+			a.anchor, a.dx, a.dy)
+		wm, err := api.TextWatermark(label, desc, true, false, types.POINTS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page label %q: %w", label, err)
+		}
+		m[page] = wm
+	}
+
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
+	
+	if err := writeInput(scratch, inputFile, in); err != nil {
+		return nil, err
+	}
+
+	if err := api.AddWatermarksMapFile(inputFile, outputFile, m, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("page numbering failed: %w", err)
+	}
+	return os.ReadFile(outputFile)
 }
 
 // Crop crops margins from a PDF
-func (s *PDFService) Crop(ctx context.Context, data []byte, opts CropOptions) ([]byte, error) {
+func (s *PDFService) Crop(ctx context.Context, in PDFReader, opts CropOptions) ([]byte, error) {
 	// If no crop values, return original
 	if opts.Top == 0 && opts.Right == 0 && opts.Bottom == 0 && opts.Left == 0 {
-		return data, nil
+		return readInput(in)
 	}
 
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+	scratch, err := s.scratch.New("crop")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("crop_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("crop_output_%d.pdf", time.Now().UnixNano()))
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
 	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	if err := writeInput(scratch, inputFile, in); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
 
 	// Use Trim which removes whitespace margins
 	if err := api.TrimFile(inputFile, outputFile, nil, s.getConfig()); err != nil {
-		return data, nil
+		return readInput(in)
 	}
 
 	result, err := os.ReadFile(outputFile)
 	if err != nil {
-		return data, nil
+		return readInput(in)
 	}
 	return result, nil
 }
 
 // ExtractText extracts text from PDF using ledongthuc/pdf
-func (s *PDFService) ExtractText(ctx context.Context, data []byte) (string, error) {
-	reader := bytes.NewReader(data)
-	f, err := pdf.NewReader(reader, int64(len(data)))
+func (s *PDFService) ExtractText(ctx context.Context, in PDFReader) (string, error) {
+	pages, err := s.ExtractPageTexts(ctx, in)
 	if err != nil {
-		return "", fmt.Errorf("failed to open pdf: %w", err)
+		return "", err
 	}
 
 	var textBuilder strings.Builder
+	for _, text := range pages {
+		if text == "" {
+			continue
+		}
+		textBuilder.WriteString(text)
+		textBuilder.WriteString("\n")
+	}
+
+	return textBuilder.String(), nil
+}
+
+// ExtractPageTexts extracts the text of each page; pages without readable
+// text are empty so indexes match page numbers
+func (s *PDFService) ExtractPageTexts(ctx context.Context, in PDFReader) ([]string, error) {
+	f, err := pdf.NewReader(in, in.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+
 	totalPage := f.NumPage()
+	pages := make([]string, totalPage)
 
 	for pageIndex := 1; pageIndex <= totalPage; pageIndex++ {
 		p := f.Page(pageIndex)
 		if p.V.IsNull() {
 			continue
 		}
-		
+
 		text, err := p.GetPlainText(nil)
 		if err != nil {
 			continue
 		}
-		textBuilder.WriteString(text)
-		textBuilder.WriteString("\n")
+		pages[pageIndex-1] = text
 	}
 
-	return textBuilder.String(), nil
+	return pages, nil
 }
 
 // ExtractTextWithOCR extracts text with OCR (stub)
-func (s *PDFService) ExtractTextWithOCR(ctx context.Context, data []byte) (string, error) {
+func (s *PDFService) ExtractTextWithOCR(ctx context.Context, in PDFReader) (string, error) {
 	return "", fmt.Errorf("OCR extraction not available")
 }
 
 // Helper functions
 
-// DrawTextOnPDF adds custom text at specific coordinates using gopdf
-func (s *PDFService) DrawTextOnPDF(ctx context.Context, data []byte, opts DrawTextOptions) ([]byte, error) {
-	if err := s.ensureTempDir(); err != nil {
-		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+// NormalizeDrawTextOptions validates opts and fills in the defaults:
+// 24pt black Helvetica, left aligned, on every page
+func NormalizeDrawTextOptions(opts DrawTextOptions) (DrawTextOptions, error) {
+	if strings.TrimSpace(opts.Text) == "" {
+		return opts, fmt.Errorf("%w: text is required", ErrInvalidDrawText)
+	}
+	unit, err := normalizeUnit(opts.Unit, opts.X, opts.Y)
+	if err != nil {
+		return opts, fmt.Errorf("%w: %v", ErrInvalidDrawText, err)
+	}
+	opts.Unit = unit
+	if opts.Unit == UnitPercent && opts.Width > 100 {
+		return opts, fmt.Errorf("%w: width cannot exceed 100 percent", ErrInvalidDrawText)
+	}
+	if opts.FontSize == 0 {
+		opts.FontSize = 24
+	}
+	if opts.FontSize < 4 || opts.FontSize > 200 {
+		return opts, fmt.Errorf("%w: fontSize must be between 4 and 200", ErrInvalidDrawText)
+	}
+	if opts.Color == "" {
+		opts.Color = "#000000"
+	}
+	if !ValidHexColor(opts.Color) {
+		return opts, fmt.Errorf("%w: color must be a hex value like #000000", ErrInvalidDrawText)
 	}
 
-	pdfRes := gopdf.GoPdf{}
-	pdfRes.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4}) // Default fallback
+	opts.FontFamily = strings.ToLower(strings.TrimSpace(opts.FontFamily))
+	if opts.FontFamily == "" {
+		opts.FontFamily = "helvetica"
+	}
+	if _, ok := StandardFonts[opts.FontFamily]; !ok {
+		return opts, fmt.Errorf("%w: unsupported font %q", ErrInvalidDrawText, opts.FontFamily)
+	}
 
-	// In a real implementation, we'd import the existing PDF pages.
-	// For now, let's show the logic of using gopdf to create a layer or a new doc.
-	// To truly overlay on existing PDF, we use pdfcpu's Stamp or import into gopdf.
-	
-	// Create a temporary file to work with
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("draw_input_%d.pdf", time.Now().UnixNano()))
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+	switch opts.Align = strings.ToLower(strings.TrimSpace(opts.Align)); opts.Align {
+	case "":
+		opts.Align = TextAlignLeft
+	case TextAlignLeft, TextAlignCenter, TextAlignRight:
+	default:
+		return opts, fmt.Errorf("%w: align must be %q, %q or %q", ErrInvalidDrawText,
+			TextAlignLeft, TextAlignCenter, TextAlignRight)
+	}
+	if opts.Width < 0 {
+		return opts, fmt.Errorf("%w: width cannot be negative", ErrInvalidDrawText)
+	}
+	if opts.Rotation < -180 || opts.Rotation > 180 {
+		return opts, fmt.Errorf("%w: rotation must be between -180 and 180", ErrInvalidDrawText)
+	}
+	if opts.Pages = strings.TrimSpace(opts.Pages); opts.Pages != "" {
+		if _, err := api.ParsePageSelection(opts.Pages); err != nil {
+			return opts, fmt.Errorf("%w: invalid page selection: %v", ErrInvalidDrawText, err)
+		}
+	}
+	return opts, nil
+}
+
+// normalizeUnit validates a placement's unit and coordinates, defaulting
+// to points
+func normalizeUnit(unit string, x, y float64) (string, error) {
+	switch unit = strings.ToLower(strings.TrimSpace(unit)); unit {
+	case "", "pt", "points":
+		unit = UnitPoints
+	case UnitPercent, "%":
+		unit = UnitPercent
+	default:
+		return unit, fmt.Errorf("unit must be %q or %q", UnitPoints, UnitPercent)
+	}
+	if x < 0 || y < 0 {
+		return unit, errors.New("x and y cannot be negative")
+	}
+	if unit == UnitPercent && (x > 100 || y > 100) {
+		return unit, errors.New("x and y cannot exceed 100 percent")
+	}
+	return unit, nil
+}
+
+// pointOnPage converts x/y to points on a page of size dim and rejects
+// points that fall outside it
+func pointOnPage(x, y float64, unit string, page int, dim types.Dim) (float64, float64, error) {
+	if unit == UnitPercent {
+		x, y = x/100*dim.Width, y/100*dim.Height
+	}
+	if x > dim.Width || y > dim.Height {
+		return x, y, fmt.Errorf("(%.0f, %.0f) is outside page %d (%.0f x %.0f pt)",
+			x, y, page, dim.Width, dim.Height)
+	}
+	return x, y, nil
+}
+
+// PageGeometry returns the size and rotation of every page. Width and
+// Height are as displayed, i.e. swapped for pages rotated by 90 or 270
+// degrees, which is the space placements are given in.
+func (s *PDFService) PageGeometry(in PDFReader) ([]models.PageGeometry, error) {
+	ctx, err := api.ReadContext(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := api.ValidateContext(ctx); err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
 
-	// pdfcpu is better at adding text to existing PDFs without losing structure
-	// Let's use pdfcpu for the actual operation but keep the advanced options
-	
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("draw_output_%d.pdf", time.Now().UnixNano()))
-	defer os.Remove(outputFile)
+	boundaries, err := ctx.PageBoundaries(nil)
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]models.PageGeometry, len(boundaries))
+	for i, pb := range boundaries {
+		dim := pb.MediaBox().Dimensions()
+		rotation := ((pb.Rot % 360) + 360) % 360
+		if rotation%180 != 0 {
+			dim.Width, dim.Height = dim.Height, dim.Width
+		}
+		pages[i] = models.PageGeometry{
+			Page:     i + 1,
+			Width:    math.Round(dim.Width*100) / 100,
+			Height:   math.Round(dim.Height*100) / 100,
+			Rotation: rotation,
+		}
+	}
+	return pages, nil
+}
+
+// WrapText breaks text into lines on newlines and, when width is positive,
+// wherever the next word would overflow it. Words wider than width are
+// split between characters.
+func WrapText(text, fontName string, fontSize int, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if width <= 0 {
+			lines = append(lines, paragraph)
+			continue
+		}
+
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if font.TextWidth(candidate, fontName, fontSize) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Break words that cannot fit on a line of their own
+			line = ""
+			for _, r := range word {
+				if line != "" && font.TextWidth(line+string(r), fontName, fontSize) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// DrawTextOnPDF adds custom text at specific coordinates
+func (s *PDFService) DrawTextOnPDF(ctx context.Context, in PDFReader, opts DrawTextOptions) ([]byte, error) {
+	return s.DrawTextBatch(ctx, in, []DrawTextOptions{opts})
+}
 
-	// Build description
-	color := opts.Color
-	if color == "" {
-		color = "#000000"
+// DrawTextBatch draws every placement in a single pass over the document,
+// so editor annotations don't rewrite the file once per text box
+func (s *PDFService) DrawTextBatch(ctx context.Context, in PDFReader, placements []DrawTextOptions) ([]byte, error) {
+	if len(placements) == 0 {
+		return nil, fmt.Errorf("%w: at least one placement is required", ErrInvalidDrawText)
 	}
-	fontSize := opts.FontSize
-	if fontSize == 0 {
-		fontSize = 24
+	if len(placements) > MaxTextPlacements {
+		return nil, fmt.Errorf("%w: at most %d placements per request", ErrInvalidDrawText, MaxTextPlacements)
 	}
+	scratch, err := s.scratch.New("draw-text")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
 
-	// Format: "pos:abs, x:100, y:100, font:Helvetica, points:24, color:#000000"
-	desc := fmt.Sprintf("pos:abs, x:%f, y:%f, font:Helvetica, points:%d, color:%s", 
-		opts.X, opts.Y, int(fontSize), color)
+	dims, err := api.PageDims(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page sizes: %w", err)
+	}
+	pageCount := len(dims)
 
-	if err := api.AddTextWatermarksFile(inputFile, outputFile, nil, true, opts.Text, desc, s.getConfig()); err != nil {
-		return data, nil
+	m := map[int][]*model.Watermark{}
+	for i, opts := range placements {
+		opts, err := NormalizeDrawTextOptions(opts)
+		if err != nil {
+			return nil, fmt.Errorf("placement %d: %w", i+1, err)
+		}
+
+		pages := make([]int, 0, pageCount)
+		if opts.Pages == "" {
+			for page := 1; page <= pageCount; page++ {
+				pages = append(pages, page)
+			}
+		} else {
+			selection, _ := api.ParsePageSelection(opts.Pages)
+			set, err := api.PagesForPageSelection(pageCount, selection, false, false)
+			if err != nil {
+				return nil, fmt.Errorf("placement %d: %w: invalid page selection: %v", i+1, ErrInvalidDrawText, err)
+			}
+			for page, ok := range set {
+				if ok {
+					pages = append(pages, page)
+				}
+			}
+		}
+		if len(pages) == 0 {
+			return nil, fmt.Errorf("placement %d: %w: no pages selected", i+1, ErrInvalidDrawText)
+		}
+
+		for _, page := range pages {
+			placed, err := opts.onPage(page, dims[page-1])
+			if err != nil {
+				return nil, fmt.Errorf("placement %d: %w: %v", i+1, ErrInvalidDrawText, err)
+			}
+			// Watermarks carry per-page render state, so each page gets its own
+			text, desc := drawTextWatermark(placed)
+			wm, err := api.TextWatermark(text, desc, true, false, types.POINTS)
+			if err != nil {
+				return nil, fmt.Errorf("placement %d: %w", i+1, err)
+			}
+			m[page] = append(m[page], wm)
+		}
+	}
+
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
+	if err := writeInput(scratch, inputFile, in); err != nil {
+		return nil, err
 	}
 
+	if err := api.AddWatermarksSliceMapFile(inputFile, outputFile, m, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("draw text failed: %w", err)
+	}
 	return os.ReadFile(outputFile)
 }
 
-// AddBadgeOnPDF adds a graphic badge to the PDF
-func (s *PDFService) AddBadgeOnPDF(ctx context.Context, data []byte, opts BadgeOptions) ([]byte, error) {
-    if err := s.ensureTempDir(); err != nil {
-        return nil, fmt.Errorf("failed to create temp dir: %w", err)
-    }
+// onPage resolves a normalized placement to points on the given page
+func (opts DrawTextOptions) onPage(page int, dim types.Dim) (DrawTextOptions, error) {
+	x, y, err := pointOnPage(opts.X, opts.Y, opts.Unit, page, dim)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Unit == UnitPercent {
+		opts.Width = opts.Width / 100 * dim.Width
+	}
+	opts.X, opts.Y, opts.Unit = x, y, UnitPoints
+	return opts, nil
+}
 
-	inputFile := filepath.Join(s.tempDir, fmt.Sprintf("badge_input_%d.pdf", time.Now().UnixNano()))
-	outputFile := filepath.Join(s.tempDir, fmt.Sprintf("badge_output_%d.pdf", time.Now().UnixNano()))
-	
-	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+// drawTextWatermark returns the wrapped text and pdfcpu description for a
+// normalized placement. The block is anchored bottom-left and shifted so
+// that X lands where opts.Align expects it.
+func drawTextWatermark(opts DrawTextOptions) (string, string) {
+	fontName := StandardFonts[opts.FontFamily]
+	fontSize := int(opts.FontSize)
+	lines := WrapText(opts.Text, fontName, fontSize, opts.Width)
+
+	blockWidth := 0.0
+	for i, line := range lines {
+		// pdfcpu drops empty lines, so blank ones keep a space
+		if line == "" {
+			lines[i] = " "
+		}
+		if w := font.TextWidth(line, fontName, fontSize); w > blockWidth {
+			blockWidth = w
+		}
+	}
+
+	x := opts.X
+	switch {
+	case opts.Align == TextAlignCenter && opts.Width > 0:
+		x += (opts.Width - blockWidth) / 2
+	case opts.Align == TextAlignCenter:
+		x -= blockWidth / 2
+	case opts.Align == TextAlignRight && opts.Width > 0:
+		x += opts.Width - blockWidth
+	case opts.Align == TextAlignRight:
+		x -= blockWidth
+	}
+
+	desc := fmt.Sprintf("font:%s, points:%d, color:%s, rotation:%.1f, scale:1 abs, position:bl, offset:%.2f %.2f, aligntext:%s",
+		fontName, fontSize, opts.Color, opts.Rotation, x, opts.Y, opts.Align)
+	return strings.Join(lines, "\n"), desc
+}
+
+// AddBadgeOnPDF stamps one of the embedded badge images on every page,
+// badgeSize points wide at scale 1
+func (s *PDFService) AddBadgeOnPDF(ctx context.Context, in PDFReader, opts BadgeOptions) ([]byte, error) {
+	unit, err := normalizeUnit(opts.Unit, opts.X, opts.Y)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlacement, err)
+	}
+	if opts.Type == "" {
+		opts.Type = "gold"
+	}
+	badge, err := assets.Badge(opts.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q; use %s", ErrUnknownBadge, opts.Type, strings.Join(assets.Badges(), ", "))
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(badge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read badge image: %w", err)
+	}
+
+	scratch, err := s.scratch.New("badge")
+	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(inputFile)
-	defer os.Remove(outputFile)
+	defer scratch.Close()
 
-    // In a real high-end app, we'd use gopdf or imagick to overlay a PNG badge.
-    // For this prototype, we'll use a specialized stamp description.
-    
-    // We can use emoji or special characters as badges for now
-    badgeIcon := "🏆" // Gold
-    if opts.Type == "verified" {
-        badgeIcon = "✅"
-    } else if opts.Type == "silver" {
-        badgeIcon = "🥈"
-    }
-    
-    scale := opts.Scale
-    if scale == 0 {
-        scale = 1.0
-    }
+	dims, err := api.PageDims(readSeeker(in), s.getConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page sizes: %w", err)
+	}
 
-    desc := fmt.Sprintf("pos:abs, x:%f, y:%f, points:%d, scale:%.2f", 
-        opts.X, opts.Y, 48, scale)
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
+	if err := writeInput(scratch, inputFile, in); err != nil {
+		return nil, err
+	}
 
-    if err := api.AddTextWatermarksFile(inputFile, outputFile, nil, true, badgeIcon, desc, s.getConfig()); err != nil {
-        return data, nil
-    }
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1.0
+	}
+	// abs scale multiplies the image's size in pixels, taken as points
+	factor := badgeSize * scale / float64(cfg.Width)
 
-    return os.ReadFile(outputFile)
+	m := make(map[int]*model.Watermark, len(dims))
+	for i, dim := range dims {
+		x, y, err := pointOnPage(opts.X, opts.Y, unit, i+1, dim)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPlacement, err)
+		}
+		desc := fmt.Sprintf("scale:%.4f abs, rotation:0, position:bl, offset:%.2f %.2f", factor, x, y)
+		wm, err := api.ImageWatermarkForReader(bytes.NewReader(badge), desc, true, false, types.POINTS)
+		if err != nil {
+			return nil, err
+		}
+		m[i+1] = wm
+	}
+
+	if err := api.AddWatermarksMapFile(inputFile, outputFile, m, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("add badge failed: %w", err)
+	}
+	return os.ReadFile(outputFile)
+}
+
+// StampImage overlays a PNG or JPEG image on the selected pages
+func (s *PDFService) StampImage(ctx context.Context, in PDFReader, img []byte, opts ImageStampOptions) ([]byte, error) {
+	scratch, err := s.scratch.New("stamp")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stamp image: %w", err)
+	}
+	if opts.Width <= 0 {
+		opts.Width = 150
+	}
+	opacity := opts.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+
+	var pageSelection []string
+	if opts.Pages != "" && opts.Pages != "1-" {
+		if pageSelection, err = api.ParsePageSelection(opts.Pages); err != nil {
+			return nil, fmt.Errorf("invalid page selection: %w", err)
+		}
+	}
+
+	inputFile := scratch.Path("input.pdf")
+	outputFile := scratch.Path("output.pdf")
+
+	if err := writeInput(scratch, inputFile, in); err != nil {
+		return nil, err
+	}
+
+	// Anchored bottom-left so the offset is the absolute position; an absolute
+	// scale factor is relative to the image's own size
+	scale := opts.Width / float64(cfg.Width)
+	desc := fmt.Sprintf("position:bl, offset:%.2f %.2f, scalefactor:%.4f abs, rotation:%.1f, opacity:%.2f",
+		opts.X, opts.Y, scale, opts.Rotation, opacity)
+
+	if err := api.AddImageWatermarksForReaderFile(inputFile, outputFile, pageSelection, true, bytes.NewReader(img), desc, s.getConfig()); err != nil {
+		return nil, fmt.Errorf("stamp failed: %w", err)
+	}
+
+	return os.ReadFile(outputFile)
 }
 
 // IsTextReadable checks if extracted text is readable
@@ -0,0 +1,96 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"brainy-pdf/internal/pdfgen"
+	"brainy-pdf/internal/services"
+)
+
+// Benchmarks for merge, split and compress on representative documents.
+// `make bench` runs them and checks the results against bench/budgets.json,
+// which keys them as "<operation>/<fixture>".
+
+type benchFixture struct {
+	name  string
+	data  []byte
+	pages int
+}
+
+var (
+	benchFixturesOnce sync.Once
+	benchFixtures     []benchFixture
+)
+
+// fixtures returns the benchmark documents, built once: dense text, large
+// embedded images, and a long (500+ page) document
+func fixtures() []benchFixture {
+	benchFixturesOnce.Do(func() {
+		text := &pdfgen.Builder{}
+		for p := 1; p <= 40; p++ {
+			lines := make([]string, 50)
+			for i := range lines {
+				lines[i] = fmt.Sprintf("Page %d line %d: the quick brown fox jumps over the lazy dog 0123456789", p, i+1)
+			}
+			text.AddTextPage(lines...)
+		}
+
+		images := &pdfgen.Builder{}
+		for p := 1; p <= 12; p++ {
+			images.AddImagePage(900, 1200, int64(p))
+		}
+
+		benchFixtures = []benchFixture{
+			{name: "text-heavy", data: text.Bytes(), pages: 40},
+			{name: "image-heavy", data: images.Bytes(), pages: 12},
+			{name: "long-520p", data: pdfgen.Sample(520), pages: 520},
+		}
+	})
+	return benchFixtures
+}
+
+// benchEach runs op as a sub-benchmark per fixture
+func benchEach(b *testing.B, op func(ctx context.Context, s *services.PDFService, fx benchFixture) error) {
+	s, err := services.NewPDFService()
+	if err != nil {
+		b.Fatalf("failed to create PDF service: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, fx := range fixtures() {
+		b.Run(fx.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := op(ctx, s, fx); err != nil {
+					b.Fatalf("%s: %v", fx.name, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	benchEach(b, func(ctx context.Context, s *services.PDFService, fx benchFixture) error {
+		_, err := s.Merge(ctx, []services.PDFReader{bytes.NewReader(fx.data), bytes.NewReader(fx.data)})
+		return err
+	})
+}
+
+func BenchmarkSplit(b *testing.B) {
+	benchEach(b, func(ctx context.Context, s *services.PDFService, fx benchFixture) error {
+		half := fx.pages / 2
+		_, err := s.Split(ctx, bytes.NewReader(fx.data), fmt.Sprintf("1-%d, %d-%d", half, half+1, fx.pages), nil)
+		return err
+	})
+}
+
+func BenchmarkCompress(b *testing.B) {
+	benchEach(b, func(ctx context.Context, s *services.PDFService, fx benchFixture) error {
+		_, err := s.Compress(ctx, bytes.NewReader(fx.data), "medium")
+		return err
+	})
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"brainy-pdf/internal/models"
+	"github.com/ledongthuc/pdf"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 100
+	MaxSearchLimit     = 1000
+	MaxSearchQuery     = 200
+)
+
+// ErrInvalidSearch wraps search option validation failures
+var ErrInvalidSearch = errors.New("invalid search")
+
+// SearchOptions configures SearchText
+type SearchOptions struct {
+	Query         string
+	CaseSensitive bool
+	Limit         int // Matches returned, DefaultSearchLimit when 0
+}
+
+// TextLine is a run of glyphs sharing a baseline, in reading order. Owner
+// maps each rune of Text to the glyph it came from, -1 for inserted spaces.
+type TextLine struct {
+	Text   []rune
+	Owner  []int
+	Glyphs []pdf.Text
+}
+
+// PageTextLines extracts the positioned text of a page grouped into lines,
+// top to bottom. Glyphs further apart than a quarter of the font size are
+// separated by a space.
+func PageTextLines(p pdf.Page) (lines []TextLine, err error) {
+	// ledongthuc/pdf panics on some malformed content streams
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unreadable page content: %v", r)
+		}
+	}()
+
+	glyphs := append([]pdf.Text(nil), p.Content().Text...)
+	sort.SliceStable(glyphs, func(i, j int) bool {
+		if glyphs[i].Y != glyphs[j].Y {
+			return glyphs[i].Y > glyphs[j].Y
+		}
+		return glyphs[i].X < glyphs[j].X
+	})
+
+	var group []pdf.Text
+	flush := func() {
+		if len(group) > 0 {
+			lines = append(lines, newTextLine(group))
+			group = nil
+		}
+	}
+	for _, g := range glyphs {
+		if g.S == "" {
+			continue
+		}
+		if len(group) > 0 && math.Abs(group[0].Y-g.Y) > math.Max(1, g.FontSize*0.3) {
+			flush()
+		}
+		group = append(group, g)
+	}
+	flush()
+	return lines, nil
+}
+
+// PageLineTexts extracts the text of the first maxPages pages (all when 0)
+// one line per row, top to bottom, along with the document's page count.
+// Pages without readable text are empty so indexes match page numbers.
+func (s *PDFService) PageLineTexts(ctx context.Context, in PDFReader, maxPages int) ([]string, int, error) {
+	f, err := pdf.NewReader(in, in.Size())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open pdf: %w", err)
+	}
+	total := f.NumPage()
+	n := total
+	if maxPages > 0 && n > maxPages {
+		n = maxPages
+	}
+
+	texts := make([]string, n)
+	for i := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		p := f.Page(i + 1)
+		if p.V.IsNull() {
+			continue
+		}
+		lines, err := PageTextLines(p)
+		if err != nil {
+			continue
+		}
+		var text []string
+		for _, tl := range lines {
+			if line := strings.Join(strings.Fields(string(tl.Text)), " "); line != "" {
+				text = append(text, line)
+			}
+		}
+		texts[i] = strings.Join(text, "\n")
+	}
+	return texts, total, nil
+}
+
+func newTextLine(glyphs []pdf.Text) TextLine {
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].X < glyphs[j].X })
+
+	// Without a Widths array ledongthuc/pdf reports zero widths and stacks
+	// a run's glyphs at its start, advancing them only by character and
+	// word spacing and TJ adjustments, so lay those out with standard
+	// metrics, keeping the spacing
+	cursor, prevX := math.Inf(-1), 0.0
+	for i := range glyphs {
+		g := &glyphs[i]
+		rawX := g.X
+		if g.W == 0 {
+			g.W = glyphWidth(*g)
+			if g.X < cursor {
+				g.X = cursor + g.X - prevX
+			}
+		}
+		cursor, prevX = g.X+g.W, rawX
+	}
+
+	line := TextLine{Glyphs: glyphs}
+	for i, g := range glyphs {
+		if i > 0 {
+			prev := glyphs[i-1]
+			gap := g.X - (prev.X + prev.W)
+			if gap > g.FontSize*0.25 && !strings.HasSuffix(prev.S, " ") && !strings.HasPrefix(g.S, " ") {
+				line.Text = append(line.Text, ' ')
+				line.Owner = append(line.Owner, -1)
+			}
+		}
+		for _, r := range g.S {
+			line.Text = append(line.Text, r)
+			line.Owner = append(line.Owner, i)
+		}
+	}
+	return line
+}
+
+// glyphWidth estimates the advance of g from the core font metrics, or
+// half an em per rune for embedded fonts
+func glyphWidth(g pdf.Text) float64 {
+	name := g.Font
+	if i := strings.IndexByte(name, '+'); i >= 0 {
+		name = name[i+1:] // Subset prefix, e.g. ABCDEF+Helvetica
+	}
+	if font.IsCoreFont(name) {
+		return font.TextWidth(g.S, name, 1000) * g.FontSize / 1000
+	}
+	return float64(len([]rune(g.S))) * g.FontSize / 2
+}
+
+// rect returns the box covering the glyphs behind runes [start, end)
+func (l TextLine) rect(start, end int) models.TextRect {
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, owner := range l.Owner[start:end] {
+		if owner < 0 {
+			continue
+		}
+		g := l.Glyphs[owner]
+		minX = math.Min(minX, g.X)
+		maxX = math.Max(maxX, g.X+g.W)
+		// Cover descenders below the baseline and ascenders above it
+		minY = math.Min(minY, g.Y-g.FontSize*0.2)
+		maxY = math.Max(maxY, g.Y+g.FontSize*0.8)
+	}
+	round := func(f float64) float64 { return math.Round(f*100) / 100 }
+	return models.TextRect{X: round(minX), Y: round(minY), Width: round(maxX - minX), Height: round(maxY - minY)}
+}
+
+// NormalizeSearchOptions validates opts and fills in the defaults
+func NormalizeSearchOptions(opts SearchOptions) (SearchOptions, error) {
+	opts.Query = strings.Join(strings.Fields(opts.Query), " ")
+	if opts.Query == "" {
+		return opts, fmt.Errorf("%w: query is required", ErrInvalidSearch)
+	}
+	if len([]rune(opts.Query)) > MaxSearchQuery {
+		return opts, fmt.Errorf("%w: query cannot exceed %d characters", ErrInvalidSearch, MaxSearchQuery)
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultSearchLimit
+	}
+	if opts.Limit < 1 || opts.Limit > MaxSearchLimit {
+		return opts, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, MaxSearchLimit)
+	}
+	return opts, nil
+}
+
+// SearchText finds every occurrence of the query with the rectangle it
+// occupies, page by page. All pages are scanned so TotalMatches is exact;
+// only the first Limit matches are returned.
+func (s *PDFService) SearchText(ctx context.Context, in PDFReader, opts SearchOptions) (*models.SearchResult, error) {
+	opts, err := NormalizeSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := pdf.NewReader(in, in.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+
+	query := []rune(opts.Query)
+
+	res := &models.SearchResult{Query: opts.Query, PageCount: f.NumPage(), Matches: []models.SearchMatch{}}
+	for pageNum := 1; pageNum <= res.PageCount; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := f.Page(pageNum)
+		if p.V.IsNull() {
+			continue
+		}
+		lines, err := PageTextLines(p)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range lines {
+			for _, i := range line.indexAll(query, opts.CaseSensitive) {
+				res.TotalMatches++
+				if len(res.Matches) < opts.Limit {
+					res.Matches = append(res.Matches, models.SearchMatch{
+						Page:    pageNum,
+						Text:    string(line.Text[i : i+len(query)]),
+						Context: strings.TrimSpace(string(line.Text)),
+						Rects:   []models.TextRect{line.rect(i, i+len(query))},
+					})
+				}
+			}
+		}
+	}
+	res.Truncated = res.TotalMatches > len(res.Matches)
+	return res, nil
+}
+
+// indexAll returns the rune offsets of the non-overlapping occurrences of
+// query in the line
+func (l TextLine) indexAll(query []rune, caseSensitive bool) []int {
+	if len(query) == 0 {
+		return nil
+	}
+	fold := func(runes []rune) []rune {
+		if caseSensitive {
+			return runes
+		}
+		out := make([]rune, len(runes))
+		for i, r := range runes {
+			out[i] = unicode.ToLower(r)
+		}
+		return out
+	}
+	haystack, needle := fold(l.Text), fold(query)
+
+	var offsets []int
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if runesEqual(haystack[i:i+len(needle)], needle) {
+			offsets = append(offsets, i)
+			i += len(needle) - 1
+		}
+	}
+	return offsets
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidPreferences is returned for preferences that requests would
+// reject as parameters
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+const (
+	preferencesCollection = "preferences"
+	// MaxShareExpiryMinutes is the longest a share link lasts (7 days)
+	MaxShareExpiryMinutes  = 10080
+	maxWatermarkTextLen    = 200
+	maxPageNumberFormatLen = 50
+)
+
+// PreferencesService keeps each user's default operation settings, one
+// document per user keyed by their ID
+type PreferencesService struct {
+	mongoClient *mongodb.Client
+}
+
+// NewPreferencesService creates a preferences service
+func NewPreferencesService(mongoClient *mongodb.Client) *PreferencesService {
+	return &PreferencesService{mongoClient: mongoClient}
+}
+
+func (s *PreferencesService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(preferencesCollection)
+}
+
+// Get returns userID's preferences, empty if they have saved none
+func (s *PreferencesService) Get(ctx context.Context, userID string) (*models.Preferences, error) {
+	prefs := models.Preferences{UserID: userID}
+	err := s.collection().FindOne(ctx, bson.M{"_id": userID}).Decode(&prefs)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// Update replaces userID's preferences after validating them
+func (s *PreferencesService) Update(ctx context.Context, userID string, prefs models.Preferences) (*models.Preferences, error) {
+	if err := ValidatePreferences(&prefs); err != nil {
+		return nil, err
+	}
+	prefs.UserID = userID
+	prefs.UpdatedAt = time.Now()
+	_, err := s.collection().ReplaceOne(ctx, bson.M{"_id": userID}, prefs, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// Reset deletes userID's preferences, restoring the built-in defaults
+func (s *PreferencesService) Reset(ctx context.Context, userID string) error {
+	if _, err := s.collection().DeleteOne(ctx, bson.M{"_id": userID}); err != nil {
+		return fmt.Errorf("failed to reset preferences: %w", err)
+	}
+	return nil
+}
+
+// ValidatePreferences normalizes prefs and checks each setting the way the
+// matching request parameter is checked, so applying them can't make a
+// request fail
+func ValidatePreferences(prefs *models.Preferences) error {
+	c := &prefs.Compression
+	c.Quality = strings.ToLower(strings.TrimSpace(c.Quality))
+	if c.Quality != "" && c.Quality != "low" && c.Quality != "medium" && c.Quality != "high" {
+		return fmt.Errorf("%w: compression quality must be low, medium or high", ErrInvalidPreferences)
+	}
+	c.Mode = strings.ToLower(strings.TrimSpace(c.Mode))
+	if c.Mode != "" && c.Mode != CompressModeStandard && c.Mode != CompressModePhoto {
+		return fmt.Errorf("%w: compression mode must be %q or %q", ErrInvalidPreferences, CompressModeStandard, CompressModePhoto)
+	}
+
+	w := &prefs.Watermark
+	w.Text = strings.TrimSpace(w.Text)
+	if len(w.Text) > maxWatermarkTextLen {
+		return fmt.Errorf("%w: watermark text is longer than %d characters", ErrInvalidPreferences, maxWatermarkTextLen)
+	}
+	if w.Opacity != 0 && (w.Opacity < 0.1 || w.Opacity > 1) {
+		return fmt.Errorf("%w: watermark opacity must be between 0.1 and 1", ErrInvalidPreferences)
+	}
+	w.Font = strings.ToLower(strings.TrimSpace(w.Font))
+	w.Mode = strings.ToLower(strings.TrimSpace(w.Mode))
+	// The text is only a placeholder when none is saved; the rest must pass
+	// as sent with any text
+	if _, err := NormalizeWatermarkOptions(WatermarkOptions{
+		Type:     WatermarkTypeText,
+		Text:     "preferences",
+		Position: w.Position,
+		Opacity:  w.Opacity,
+		FontSize: float64(w.FontSize),
+		Color:    w.Color,
+		Font:     w.Font,
+		Mode:     w.Mode,
+	}); err != nil {
+		return fmt.Errorf("%w: watermark %s", ErrInvalidPreferences, strings.TrimPrefix(err.Error(), ErrInvalidWatermark.Error()+": "))
+	}
+
+	p := &prefs.PageNumbers
+	p.Style = strings.ToLower(strings.TrimSpace(p.Style))
+	if len(p.Format) > maxPageNumberFormatLen {
+		return fmt.Errorf("%w: page number format is longer than %d characters", ErrInvalidPreferences, maxPageNumberFormatLen)
+	}
+	if _, err := NormalizePageNumberOptions(PageNumberOptions{
+		Position:  p.Position,
+		Format:    p.Format,
+		Style:     p.Style,
+		StartFrom: p.StartFrom,
+	}); err != nil {
+		return fmt.Errorf("%w: page numbers %s", ErrInvalidPreferences, strings.TrimPrefix(err.Error(), ErrInvalidPageNumbers.Error()+": "))
+	}
+
+	if m := prefs.Share.ExpiresInMinutes; m < 0 || m > MaxShareExpiryMinutes {
+		return fmt.Errorf("%w: share expiresInMinutes must be between 1 and %d", ErrInvalidPreferences, MaxShareExpiryMinutes)
+	}
+	return nil
+}
+
+// PreferenceDefaults returns the form fields prefs sets for operation
+// ("compress", "watermark" or "page-numbers"), for handlers to fill in
+// where a request left them out
+func PreferenceDefaults(prefs *models.Preferences, operation string) map[string]string {
+	defaults := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			defaults[key] = value
+		}
+	}
+	setInt := func(key string, value int) {
+		if value != 0 {
+			defaults[key] = strconv.Itoa(value)
+		}
+	}
+
+	switch operation {
+	case "compress":
+		set("quality", prefs.Compression.Quality)
+		set("mode", prefs.Compression.Mode)
+	case "watermark":
+		w := prefs.Watermark
+		set("text", w.Text)
+		set("position", w.Position)
+		if w.Opacity != 0 {
+			defaults["opacity"] = strconv.FormatFloat(w.Opacity, 'f', -1, 64)
+		}
+		setInt("fontSize", w.FontSize)
+		set("color", w.Color)
+		set("font", w.Font)
+		set("mode", w.Mode)
+	case "page-numbers":
+		p := prefs.PageNumbers
+		set("position", p.Position)
+		set("format", p.Format)
+		set("style", p.Style)
+		setInt("startFrom", p.StartFrom)
+	}
+	return defaults
+}
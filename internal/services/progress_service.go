@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// progressCollection holds the progress of running operations
+	progressCollection = "operation_progress"
+	// progressTTL is how long finished progress records stay readable
+	progressTTL = time.Hour
+	// progressSaveInterval limits how often a tracker writes to Mongo
+	progressSaveInterval = 500 * time.Millisecond
+)
+
+// ErrProgressNotFound means no progress is recorded under an ID for the
+// caller
+var ErrProgressNotFound = errors.New("progress not found")
+
+// progressIDPattern is what client-chosen progress IDs may look like
+var progressIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// ValidProgressID reports whether id can name an operation's progress
+func ValidProgressID(id string) bool {
+	return progressIDPattern.MatchString(id)
+}
+
+// ProgressService records how far long-running PDF operations have got, so
+// a client can poll the progress of a request it is still waiting on. The
+// client picks the ID and sends it with the request. Records are kept in
+// Mongo, so any instance can answer, and expire an hour after the last
+// update.
+type ProgressService struct {
+	mongoClient *mongodb.Client
+}
+
+// NewProgressService creates a progress service
+func NewProgressService(mongoClient *mongodb.Client) *ProgressService {
+	s := &ProgressService{mongoClient: mongoClient}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		log.Printf("[Progress] Failed to create expiry index: %v", err)
+	}
+	return s
+}
+
+func (s *ProgressService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(progressCollection)
+}
+
+// Start begins tracking an operation for userID. It returns nil, which
+// tracks nothing, when the service is nil or id is empty or invalid.
+func (s *ProgressService) Start(ctx context.Context, id, userID, operation string) *ProgressTracker {
+	if s == nil || !ValidProgressID(id) {
+		return nil
+	}
+	t := &ProgressTracker{
+		service: s,
+		state: models.OperationProgress{
+			ID:        id,
+			UserID:    userID,
+			Operation: operation,
+			Status:    string(JobStatusProcessing),
+			Stage:     models.ProgressStageReading,
+		},
+	}
+	t.save(ctx, true)
+	return t
+}
+
+// Get returns the progress recorded under id for userID
+func (s *ProgressService) Get(ctx context.Context, id, userID string) (*models.OperationProgress, error) {
+	if s == nil || !ValidProgressID(id) {
+		return nil, ErrProgressNotFound
+	}
+	var p models.OperationProgress
+	err := s.collection().FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&p)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrProgressNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ProgressTracker reports one operation's progress. Its methods are safe to
+// call on a nil tracker, which does nothing.
+type ProgressTracker struct {
+	service *ProgressService
+
+	mu        sync.Mutex
+	state     models.OperationProgress
+	lastSaved time.Time
+}
+
+// SetTotalPages sets how many pages the operation processes
+func (t *ProgressTracker) SetTotalPages(ctx context.Context, total int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.state.TotalPages = total
+	t.mu.Unlock()
+	t.save(ctx, false)
+}
+
+// Pages records that done of the total pages have been through stage
+func (t *ProgressTracker) Pages(ctx context.Context, stage string, done int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	changed := t.state.Stage != stage
+	t.state.Stage = stage
+	t.state.PagesProcessed = done
+	t.mu.Unlock()
+	t.save(ctx, changed)
+}
+
+// Finish records that the operation completed, or failed with err
+func (t *ProgressTracker) Finish(ctx context.Context, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if err != nil {
+		t.state.Status = string(JobStatusFailed)
+		t.state.Error = err.Error()
+	} else {
+		t.state.Status = string(JobStatusCompleted)
+		t.state.PagesProcessed = t.state.TotalPages
+	}
+	t.mu.Unlock()
+	t.save(ctx, true)
+}
+
+// percent weighs processing as most of the work: reading is the first
+// 10%, processing up to 90% and uploading the rest
+func (t *ProgressTracker) percent() int {
+	p := t.state
+	if p.Status == string(JobStatusCompleted) {
+		return 100
+	}
+	done := 0
+	if p.TotalPages > 0 {
+		done = p.PagesProcessed * 100 / p.TotalPages
+	}
+	switch p.Stage {
+	case models.ProgressStageReading:
+		return done / 10
+	case models.ProgressStageProcessing:
+		return 10 + done*80/100
+	default:
+		return 90 + done*9/100
+	}
+}
+
+// save writes the progress, at most every progressSaveInterval unless
+// force is set. Failures are logged; progress is informational.
+func (t *ProgressTracker) save(ctx context.Context, force bool) {
+	t.mu.Lock()
+	now := time.Now()
+	if !force && now.Sub(t.lastSaved) < progressSaveInterval {
+		t.mu.Unlock()
+		return
+	}
+	t.lastSaved = now
+	t.state.Progress = t.percent()
+	t.state.UpdatedAt = now
+	t.state.ExpiresAt = now.Add(progressTTL)
+	state := t.state
+	t.mu.Unlock()
+
+	// The request may already be cancelled when a failure is recorded
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if _, err := t.service.collection().ReplaceOne(ctx, bson.M{"_id": state.ID, "userId": state.UserID}, state, options.Replace().SetUpsert(true)); err != nil {
+		log.Printf("[Progress] Failed to save progress %s: %v", state.ID, err)
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"brainy-pdf/internal/models"
+	"github.com/ledongthuc/pdf"
+)
+
+// maxRedactTerms bounds the terms given to Redact
+const maxRedactTerms = 50
+
+// RedactOptions configures Redact. With no types, terms or AI, every
+// pattern type is redacted.
+type RedactOptions struct {
+	Types []string // Pattern types, see RedactionTypes
+	Terms []string // Text to redact as well, matched ignoring case
+	AI    bool     // Have the AI find names, addresses and other personal information too
+	Color string   // Fill of the redaction boxes, #000000 by default
+}
+
+// RedactionTypes returns the sensitive data types Redact finds by pattern,
+// sorted
+func RedactionTypes() []string {
+	types := make([]string, 0, len(sensitivePatterns))
+	for t := range sensitivePatterns {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NormalizeRedactOptions validates opts and fills in the defaults
+func NormalizeRedactOptions(opts RedactOptions) (RedactOptions, error) {
+	seen := make(map[string]bool)
+	var types []string
+	for _, t := range opts.Types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if sensitivePatterns[t] == nil {
+			return opts, fmt.Errorf("%w: unknown type %q; use %s", ErrInvalidRedact, t, strings.Join(RedactionTypes(), ", "))
+		}
+		seen[t] = true
+		types = append(types, t)
+	}
+	opts.Types = types
+
+	seen = make(map[string]bool)
+	var terms []string
+	for _, term := range opts.Terms {
+		term = strings.Join(strings.Fields(term), " ")
+		if term == "" || seen[strings.ToLower(term)] {
+			continue
+		}
+		if utf8.RuneCountInString(term) > MaxSearchQuery {
+			return opts, fmt.Errorf("%w: terms cannot exceed %d characters", ErrInvalidRedact, MaxSearchQuery)
+		}
+		seen[strings.ToLower(term)] = true
+		terms = append(terms, term)
+	}
+	if len(terms) > maxRedactTerms {
+		return opts, fmt.Errorf("%w: at most %d terms are allowed", ErrInvalidRedact, maxRedactTerms)
+	}
+	opts.Terms = terms
+
+	if len(opts.Types) == 0 && len(opts.Terms) == 0 && !opts.AI {
+		opts.Types = RedactionTypes()
+	}
+	if opts.Color == "" {
+		opts.Color = "#000000"
+	}
+	if !ValidHexColor(opts.Color) {
+		return opts, fmt.Errorf("%w: color must be a hex color like #000000", ErrInvalidRedact)
+	}
+	return opts, nil
+}
+
+// RedactionService finds sensitive data in a PDF's text layer and burns it
+// out of the file, unlike DetectSensitiveData and MaskSensitiveData, which
+// only report on the extracted text
+type RedactionService struct {
+	pdfService *PDFService
+	aiService  *AIService
+}
+
+func NewRedactionService(pdfService *PDFService, aiService *AIService) *RedactionService {
+	return &RedactionService{pdfService: pdfService, aiService: aiService}
+}
+
+// Redact finds the data opts asks for, maps each occurrence to its place on
+// the page and removes it with RedactAreas. Occurrences are matched line by
+// line, so data broken over two lines is missed, and scanned pages have no
+// text to match until they are OCRed. The text extractor ignores word
+// spacing (Tw), so boxes can fall short of data in text justified that
+// way.
+func (s *RedactionService) Redact(ctx context.Context, in PDFReader, opts RedactOptions) ([]byte, *models.RedactionReport, error) {
+	opts, err := NormalizeRedactOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := pdf.NewReader(in, in.Size())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+	pages := make([][]TextLine, f.NumPage())
+	for i := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		p := f.Page(i + 1)
+		if p.V.IsNull() {
+			continue
+		}
+		if lines, err := PageTextLines(p); err == nil {
+			pages[i] = lines
+		}
+	}
+
+	report := &models.RedactionReport{Findings: []models.RedactionFinding{}, Types: make(map[string]int), Pages: []int{}}
+	var areas []RedactArea
+	add := func(page int, dataType, value string, rect models.TextRect) {
+		report.Findings = append(report.Findings, models.RedactionFinding{
+			Page:  page,
+			Type:  dataType,
+			Value: maskRedacted(value, dataType),
+			Rects: []models.TextRect{rect},
+		})
+		report.Types[dataType]++
+		if n := len(report.Pages); n == 0 || report.Pages[n-1] != page {
+			report.Pages = append(report.Pages, page)
+		}
+		areas = append(areas, RedactArea{Page: page, Rect: rect})
+	}
+
+	terms := make([]SensitiveText, 0, len(opts.Terms))
+	for _, term := range opts.Terms {
+		terms = append(terms, SensitiveText{Type: "term", Text: term})
+	}
+	if opts.AI {
+		texts := make([]string, len(pages))
+		for i, lines := range pages {
+			var text []string
+			for _, line := range lines {
+				text = append(text, strings.Join(strings.Fields(string(line.Text)), " "))
+			}
+			texts[i] = strings.Join(text, "\n")
+		}
+		found, reviewed, err := s.aiService.FindSensitiveText(ctx, texts)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.AIAssisted = true
+		report.AIReviewedPages = reviewed
+		terms = append(terms, found...)
+	}
+
+	for i, lines := range pages {
+		for _, line := range lines {
+			text := string(line.Text)
+			for _, t := range opts.Types {
+				for _, loc := range sensitivePatterns[t].FindAllStringIndex(text, -1) {
+					match := text[loc[0]:loc[1]]
+					if valid := sensitiveValidators[t]; valid != nil && !valid(match) {
+						continue
+					}
+					start := utf8.RuneCountInString(text[:loc[0]])
+					add(i+1, t, match, line.rect(start, start+utf8.RuneCountInString(match)))
+				}
+			}
+			for _, term := range terms {
+				query := []rune(term.Text)
+				for _, at := range line.indexAll(query, false) {
+					add(i+1, term.Type, term.Text, line.rect(at, at+len(query)))
+				}
+			}
+		}
+	}
+
+	out, stats, err := s.pdfService.RedactAreas(ctx, in, areas, opts.Color)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Total = len(report.Findings)
+	report.RemovedGlyphs = stats.Glyphs
+	report.RemovedAnnotations = stats.Annotations
+	return out, report, nil
+}
+
+// maskRedacted masks a redacted value for the report: pattern types like
+// DetectSensitiveData, anything else keeping its first and last character
+func maskRedacted(value, dataType string) string {
+	if sensitivePatterns[dataType] != nil {
+		return maskSensitiveValue(value, dataType)
+	}
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return "****"
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+)
+
+// errReplicationStopped ends a sync whose lease moved to another instance
+var errReplicationStopped = errors.New("replication stopped")
+
+const (
+	replicationLease    = "replication"
+	replicationInterval = 15 * time.Minute
+	replicationBatch    = 100
+	// replicationConcurrency bounds the uploads copied at once per instance
+	replicationConcurrency = 4
+)
+
+// ReplicationService copies the platform's user files to the read replicas
+// set on the platform client, so presigned URLs can point at the replica
+// nearest the user. Uploads are copied as soon as they are stored and
+// deletions follow right away; a periodic sync copies objects missed or
+// changed since and removes those deleted from the primary. Objects are
+// copied as stored, so encrypted files stay encrypted.
+type ReplicationService struct {
+	platform *minioPkg.Client
+	slots    chan struct{}
+}
+
+// NewReplicationService creates a replication service for the replicas set
+// on platform
+func NewReplicationService(platform *minioPkg.Client) *ReplicationService {
+	return &ReplicationService{
+		platform: platform,
+		slots:    make(chan struct{}, replicationConcurrency),
+	}
+}
+
+// replicated reports whether doc's file is in the replicated bucket,
+// returning its key
+func (s *ReplicationService) replicated(doc models.Document) (string, bool) {
+	if doc.StorageID != "" {
+		return "", false
+	}
+	bucket, key := doc.Location()
+	return key, bucket == s.platform.GetBucketUserFiles()
+}
+
+// AfterUpload implements UploadHook by copying the file to every replica
+func (s *ReplicationService) AfterUpload(doc models.Document) {
+	key, ok := s.replicated(doc)
+	if !ok {
+		return
+	}
+	go func() {
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		for region, replica := range s.platform.Replicas() {
+			if err := s.copy(ctx, replica, key); err != nil {
+				log.Printf("[Replication] Failed to copy %s to %s: %v", key, region, err)
+			}
+		}
+	}()
+}
+
+// AfterDelete implements DeleteHook by deleting the file from every replica
+func (s *ReplicationService) AfterDelete(doc models.Document) {
+	key, ok := s.replicated(doc)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for region, replica := range s.platform.Replicas() {
+		if err := replica.DeleteFile(ctx, replica.GetBucketUserFiles(), key); err != nil {
+			log.Printf("[Replication] Failed to delete %s from %s: %v", key, region, err)
+		}
+	}
+}
+
+// Run syncs the replicas from whichever instance holds the replication
+// lease. Blocks until ctx is cancelled.
+func (s *ReplicationService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, replicationLease, replicationInterval, func(ctx context.Context) {
+		for region, replica := range s.platform.Replicas() {
+			copied, removed, err := s.sync(ctx, leases, replica)
+			if errors.Is(err, errReplicationStopped) {
+				return
+			}
+			if err != nil {
+				log.Printf("[Replication] Sync of %s failed: %v", region, err)
+				continue
+			}
+			if copied > 0 || removed > 0 {
+				log.Printf("[Replication] Synced %s: %d copied, %d removed", region, copied, removed)
+			}
+		}
+	})
+}
+
+// sync copies objects missing from replica or older there, then removes
+// the replica's objects that are gone from the primary
+func (s *ReplicationService) sync(ctx context.Context, leases *LeaseService, replica *minioPkg.Client) (copied, removed int, err error) {
+	bucket, replicaBucket := s.platform.GetBucketUserFiles(), replica.GetBucketUserFiles()
+
+	for after := ""; ; {
+		objects, err := s.platform.ListObjectsAfter(ctx, bucket, after, replicationBatch)
+		if err != nil {
+			return copied, removed, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(objects) == 0 {
+			break
+		}
+		if err := keepReplicationLease(ctx, leases); err != nil {
+			return copied, removed, err
+		}
+		for _, obj := range objects {
+			if !migrationStale(ctx, replica, replicaBucket, obj) {
+				continue
+			}
+			if err := s.copy(ctx, replica, obj.Key); err != nil {
+				return copied, removed, err
+			}
+			copied++
+		}
+		after = objects[len(objects)-1].Key
+	}
+
+	for after := ""; ; {
+		objects, err := replica.ListObjectsAfter(ctx, replicaBucket, after, replicationBatch)
+		if err != nil {
+			return copied, removed, fmt.Errorf("failed to list replica objects: %w", err)
+		}
+		if len(objects) == 0 {
+			break
+		}
+		if err := keepReplicationLease(ctx, leases); err != nil {
+			return copied, removed, err
+		}
+		for _, obj := range objects {
+			_, err := s.platform.GetFileInfo(ctx, bucket, obj.Key)
+			if !minioPkg.IsNotFound(err) {
+				continue
+			}
+			if err := replica.DeleteFile(ctx, replicaBucket, obj.Key); err != nil {
+				return copied, removed, fmt.Errorf("failed to remove %s: %w", obj.Key, err)
+			}
+			removed++
+		}
+		after = objects[len(objects)-1].Key
+	}
+	return copied, removed, nil
+}
+
+// copy copies an object from the primary to replica as stored. Objects
+// deleted since they were listed are skipped.
+func (s *ReplicationService) copy(ctx context.Context, replica *minioPkg.Client, key string) error {
+	obj, err := s.platform.GetObject(ctx, s.platform.GetBucketUserFiles(), key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if minioPkg.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if _, err := replica.UploadFile(ctx, replica.GetBucketUserFiles(), key, obj, info.Size, info.ContentType); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", key, err)
+	}
+	return nil
+}
+
+// keepReplicationLease renews the replication lease between batches, as a
+// sync of a large bucket can outlast the lease
+func keepReplicationLease(ctx context.Context, leases *LeaseService) error {
+	if ctx.Err() != nil {
+		return errReplicationStopped
+	}
+	acquired, err := leases.TryAcquire(ctx, replicationLease, replicationInterval+replicationInterval/2)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errReplicationStopped
+	}
+	return nil
+}
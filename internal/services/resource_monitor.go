@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResourceSnapshot is the last sampled resource usage
+type ResourceSnapshot struct {
+	TempDiskBytes int64     `json:"tempDiskBytes"`
+	RSSBytes      int64     `json:"rssBytes"`
+	MaxDiskBytes  int64     `json:"maxDiskBytes"`
+	MaxRSSBytes   int64     `json:"maxRssBytes"`
+	Overloaded    bool      `json:"overloaded"`
+	Reason        string    `json:"reason,omitempty"`
+	SampledAt     time.Time `json:"sampledAt"`
+	// Scratch is the per-job temp usage of each scratch space
+	Scratch []ScratchStats `json:"scratch,omitempty"`
+}
+
+// CleanupFunc removes finished-job artifacts older than maxAge
+type CleanupFunc func(ctx context.Context, maxAge time.Duration)
+
+// ResourceMonitor samples temp-dir disk usage and process RSS, and reports
+// the process as overloaded while either exceeds its threshold so that new
+// heavy work can be refused instead of filling the disk or getting OOM-killed
+type ResourceMonitor struct {
+	dirs         []string
+	maxDiskBytes int64
+	maxRSSBytes  int64
+	interval     time.Duration
+
+	mu       sync.RWMutex
+	snapshot ResourceSnapshot
+	cleaners []CleanupFunc
+	scratch  []*ScratchSpace
+}
+
+// Cleanup ages: routine sweeps keep an hour of artifacts; once a threshold is
+// crossed anything older than a few minutes (i.e. not in use) is removed
+const (
+	routineCleanupAge    = time.Hour
+	aggressiveCleanupAge = 5 * time.Minute
+)
+
+// NewResourceMonitor creates a monitor over the given temp directories.
+// A threshold of 0 disables that check.
+func NewResourceMonitor(maxDiskMB, maxRSSMB int, dirs ...string) *ResourceMonitor {
+	return &ResourceMonitor{
+		dirs:         dirs,
+		maxDiskBytes: int64(maxDiskMB) * 1024 * 1024,
+		maxRSSBytes:  int64(maxRSSMB) * 1024 * 1024,
+		interval:     15 * time.Second,
+	}
+}
+
+// DefaultTempDirs lists the scratch directories used by the services
+func DefaultTempDirs() []string {
+	tmp := os.TempDir()
+	return []string{
+		filepath.Join(tmp, "brainy-pdf-ops"),
+		filepath.Join(tmp, "brainy-pdf-convert"),
+		filepath.Join(tmp, "binarypdf-ai"),
+	}
+}
+
+// RegisterCleaner adds a cleanup hook run on every sweep
+func (m *ResourceMonitor) RegisterCleaner(fn CleanupFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cleaners = append(m.cleaners, fn)
+}
+
+// AddScratch reports the spaces' usage in snapshots, sweeps their leftover
+// job directories and keeps sweeps away from directories of running jobs
+func (m *ResourceMonitor) AddScratch(spaces ...*ScratchSpace) {
+	m.mu.Lock()
+	m.scratch = append(m.scratch, spaces...)
+	m.mu.Unlock()
+	for _, space := range spaces {
+		m.RegisterCleaner(space.Cleanup)
+	}
+}
+
+// Snapshot returns the last sample
+func (m *ResourceMonitor) Snapshot() ResourceSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot
+}
+
+// Overloaded reports whether new heavy jobs should be refused, and why
+func (m *ResourceMonitor) Overloaded() (bool, string) {
+	snap := m.Snapshot()
+	return snap.Overloaded, snap.Reason
+}
+
+// RetryAfter is the hint sent to clients refused while overloaded
+func (m *ResourceMonitor) RetryAfter() time.Duration {
+	return 2 * m.interval
+}
+
+// Run samples usage every interval until ctx is cancelled
+func (m *ResourceMonitor) Run(ctx context.Context) {
+	m.check(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	sweeps := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweeps++
+			snap := m.check(ctx)
+			// Routine sweep roughly every 5 minutes
+			if !snap.Overloaded && sweeps%20 == 0 {
+				m.cleanup(ctx, routineCleanupAge)
+			}
+		}
+	}
+}
+
+// check samples usage and, when over a threshold, clears artifacts
+// aggressively and samples again
+func (m *ResourceMonitor) check(ctx context.Context) ResourceSnapshot {
+	snap := m.sample()
+	if snap.Overloaded {
+		log.Printf("[Resources] Over threshold (%s), clearing finished-job artifacts", snap.Reason)
+		m.cleanup(ctx, aggressiveCleanupAge)
+		snap = m.sample()
+		if snap.Overloaded {
+			log.Printf("[Resources] Still over threshold (%s), refusing new heavy jobs", snap.Reason)
+		}
+	}
+
+	m.mu.Lock()
+	m.snapshot = snap
+	m.mu.Unlock()
+	return snap
+}
+
+func (m *ResourceMonitor) sample() ResourceSnapshot {
+	snap := ResourceSnapshot{
+		MaxDiskBytes: m.maxDiskBytes,
+		MaxRSSBytes:  m.maxRSSBytes,
+		SampledAt:    time.Now(),
+	}
+	for _, dir := range m.dirs {
+		snap.TempDiskBytes += dirSize(dir)
+	}
+	snap.RSSBytes = processRSS()
+	m.mu.RLock()
+	spaces := append([]*ScratchSpace(nil), m.scratch...)
+	m.mu.RUnlock()
+	for _, space := range spaces {
+		snap.Scratch = append(snap.Scratch, space.Stats())
+	}
+
+	var reasons []string
+	if m.maxDiskBytes > 0 && snap.TempDiskBytes > m.maxDiskBytes {
+		reasons = append(reasons, fmt.Sprintf("temp disk %dMB > %dMB", snap.TempDiskBytes>>20, m.maxDiskBytes>>20))
+	}
+	if m.maxRSSBytes > 0 && snap.RSSBytes > m.maxRSSBytes {
+		reasons = append(reasons, fmt.Sprintf("memory %dMB > %dMB", snap.RSSBytes>>20, m.maxRSSBytes>>20))
+	}
+	snap.Overloaded = len(reasons) > 0
+	snap.Reason = strings.Join(reasons, ", ")
+	return snap
+}
+
+func (m *ResourceMonitor) cleanup(ctx context.Context, maxAge time.Duration) {
+	m.mu.RLock()
+	cleaners := append([]CleanupFunc(nil), m.cleaners...)
+	spaces := append([]*ScratchSpace(nil), m.scratch...)
+	m.mu.RUnlock()
+
+	for _, fn := range cleaners {
+		fn(ctx, maxAge)
+	}
+	running := func(dir string) bool {
+		for _, space := range spaces {
+			if space.Running(dir) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, dir := range m.dirs {
+		removeOlderThan(dir, maxAge, running)
+	}
+}
+
+// dirSize sums the sizes of regular files under dir
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// removeOlderThan deletes files under dir not modified within maxAge, then
+// prunes directories left empty (dir itself is kept). Directories for
+// which skip returns true are left alone.
+func removeOlderThan(dir string, maxAge time.Duration, skip func(string) bool) {
+	cutoff := time.Now().Add(-maxAge)
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if skip(path) {
+				return fs.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(path)
+		}
+		return nil
+	})
+	// Deepest first; os.Remove fails harmlessly on non-empty directories
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// processRSS returns the resident set size, falling back to memory obtained
+// from the OS by the Go runtime where /proc is unavailable
+func processRSS() int64 {
+	if data, err := os.ReadFile("/proc/self/statm"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) > 1 {
+			if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return pages * int64(os.Getpagesize())
+			}
+		}
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.Sys)
+}
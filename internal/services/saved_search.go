@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by SavedSearchService
+var (
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+	ErrInvalidSavedSearch  = errors.New("invalid saved search")
+)
+
+const (
+	savedSearchCollection = "saved_searches"
+	maxSavedSearches      = 50
+	maxSavedSearchNameLen = 100
+	maxSavedSearchTextLen = 200
+	// savedSearchConcurrency bounds the uploads checked at once per instance
+	savedSearchConcurrency = 4
+	// savedSearchMaxSize and savedSearchMaxPages bound the text read from a
+	// new document; larger files are matched on their first pages
+	savedSearchMaxSize  = 50 * 1024 * 1024
+	savedSearchMaxPages = 300
+)
+
+// SavedSearchService keeps users' saved library searches and, for those
+// with alerts on, checks every document stored in the owner's library
+// against them in the background, notifying the owner of matches. New
+// documents are matched on their own text as well as their name, since
+// they have no notes yet.
+type SavedSearchService struct {
+	mongoClient   *mongodb.Client
+	storage       *StorageService
+	pdfService    *PDFService
+	notifications *NotificationService
+	slots         chan struct{}
+}
+
+// NewSavedSearchService creates a saved search service
+func NewSavedSearchService(mongoClient *mongodb.Client, storage *StorageService, pdfService *PDFService, notifications *NotificationService) *SavedSearchService {
+	s := &SavedSearchService{
+		mongoClient:   mongoClient,
+		storage:       storage,
+		pdfService:    pdfService,
+		notifications: notifications,
+		slots:         make(chan struct{}, savedSearchConcurrency),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "ownerUid", Value: 1}, {Key: "alert", Value: 1}},
+	}); err != nil {
+		log.Printf("[SavedSearch] Failed to create index: %v", err)
+	}
+	return s
+}
+
+func (s *SavedSearchService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(savedSearchCollection)
+}
+
+// List returns userID's saved searches, oldest first
+func (s *SavedSearchService) List(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	return s.find(ctx, bson.M{"ownerUid": userID})
+}
+
+func (s *SavedSearchService) find(ctx context.Context, filter bson.M) ([]models.SavedSearch, error) {
+	cursor, err := s.collection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	searches := []models.SavedSearch{}
+	if err := cursor.All(ctx, &searches); err != nil {
+		return nil, fmt.Errorf("failed to decode saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// Get returns one of userID's saved searches
+func (s *SavedSearchService) Get(ctx context.Context, userID, id string) (*models.SavedSearch, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSavedSearchNotFound
+	}
+	var search models.SavedSearch
+	err = s.collection().FindOne(ctx, bson.M{"_id": objID, "ownerUid": userID}).Decode(&search)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find saved search: %w", err)
+	}
+	return &search, nil
+}
+
+// Create saves a search for userID
+func (s *SavedSearchService) Create(ctx context.Context, userID string, search models.SavedSearch) (*models.SavedSearch, error) {
+	if err := ValidateSavedSearch(&search); err != nil {
+		return nil, err
+	}
+	n, err := s.collection().CountDocuments(ctx, bson.M{"ownerUid": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count saved searches: %w", err)
+	}
+	if n >= maxSavedSearches {
+		return nil, fmt.Errorf("%w: at most %d saved searches", ErrInvalidSavedSearch, maxSavedSearches)
+	}
+
+	now := time.Now()
+	search.ID = primitive.NewObjectID()
+	search.OwnerUID = userID
+	search.Alerts, search.LastMatchedAt = 0, nil
+	search.CreatedAt, search.UpdatedAt = now, now
+	if _, err := s.collection().InsertOne(ctx, search); err != nil {
+		return nil, fmt.Errorf("failed to save search: %w", err)
+	}
+	return &search, nil
+}
+
+// Update replaces the name, query and alert setting of one of userID's
+// saved searches
+func (s *SavedSearchService) Update(ctx context.Context, userID, id string, search models.SavedSearch) (*models.SavedSearch, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err := ValidateSavedSearch(&search); err != nil {
+		return nil, err
+	}
+
+	var updated models.SavedSearch
+	err = s.collection().FindOneAndUpdate(ctx, bson.M{"_id": objID, "ownerUid": userID}, bson.M{"$set": bson.M{
+		"name":      search.Name,
+		"query":     search.Query,
+		"alert":     search.Alert,
+		"updatedAt": time.Now(),
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved search: %w", err)
+	}
+	return &updated, nil
+}
+
+// Delete removes one of userID's saved searches
+func (s *SavedSearchService) Delete(ctx context.Context, userID, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSavedSearchNotFound
+	}
+	res, err := s.collection().DeleteOne(ctx, bson.M{"_id": objID, "ownerUid": userID})
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// ValidateSavedSearch checks a saved search and trims it in place. Tags
+// are expected to be normalized already.
+func ValidateSavedSearch(search *models.SavedSearch) error {
+	search.Name = strings.TrimSpace(search.Name)
+	if search.Name == "" || len(search.Name) > maxSavedSearchNameLen {
+		return fmt.Errorf("%w: name is required and at most %d characters", ErrInvalidSavedSearch, maxSavedSearchNameLen)
+	}
+	q := &search.Query
+	q.Text, q.Entity = strings.TrimSpace(q.Text), strings.TrimSpace(q.Entity)
+	if q.Text == "" && q.Entity == "" && len(q.Tags) == 0 {
+		return fmt.Errorf("%w: give text, tags or an entity to search for", ErrInvalidSavedSearch)
+	}
+	if len(q.Text) > maxSavedSearchTextLen || len(q.Entity) > maxSavedSearchTextLen {
+		return fmt.Errorf("%w: text and entity must be at most %d characters", ErrInvalidSavedSearch, maxSavedSearchTextLen)
+	}
+	if q.EntityType != "" && (q.Entity == "" || !ValidEntityType(q.EntityType)) {
+		return fmt.Errorf("%w: entityType must be person, company, invoice_number or date, with an entity", ErrInvalidSavedSearch)
+	}
+	return nil
+}
+
+// AfterUpload implements UploadHook by checking new library files against
+// their owner's alerting searches in the background
+func (s *SavedSearchService) AfterUpload(doc models.Document) {
+	if doc.IsTemporary || doc.OwnerUID == "" {
+		return
+	}
+	go s.check(doc)
+}
+
+// check notifies doc's owner of each alerting search it matches
+func (s *SavedSearchService) check(doc models.Document) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	searches, err := s.find(ctx, bson.M{"ownerUid": doc.OwnerUID, "alert": true})
+	if err != nil {
+		log.Printf("[SavedSearch] Failed to load alerts for %s: %v", doc.OwnerUID, err)
+		return
+	}
+	if len(searches) == 0 {
+		return
+	}
+
+	var pages []string
+	if doc.MimeType == "application/pdf" && needsText(searches) {
+		if pages, err = s.readText(ctx, &doc); err != nil {
+			// Names and tags can still match
+			log.Printf("[SavedSearch] Failed to read %s: %v", doc.ID.Hex(), err)
+		}
+	}
+	// Tags may have been added by folder rules meanwhile
+	var current models.Document
+	if err := s.mongoClient.Documents().FindOne(ctx, bson.M{"_id": doc.ID}).Decode(&current); err != nil {
+		return // deleted meanwhile
+	}
+
+	match := newDocumentMatch(&current, pages)
+	for _, search := range searches {
+		if !match.matches(search.Query) {
+			continue
+		}
+		now := time.Now()
+		s.collection().UpdateOne(ctx, bson.M{"_id": search.ID}, bson.M{
+			"$set": bson.M{"lastMatchedAt": now},
+			"$inc": bson.M{"alerts": 1},
+		})
+		// The query may hold sensitive values such as a passport number, so
+		// only the search's name is sent
+		title := fmt.Sprintf("New match for \"%s\"", search.Name)
+		message := fmt.Sprintf("\"%s\" was added to your library and matches your saved search \"%s\".", current.OriginalName, search.Name)
+		if err := s.notifications.CreateNotification(ctx, FirebaseRecipient(doc.OwnerUID), title, message, models.NotificationTypeInfo); err != nil {
+			log.Printf("[SavedSearch] Failed to notify %s of %s: %v", doc.OwnerUID, search.ID.Hex(), err)
+		}
+	}
+}
+
+// needsText reports whether any search looks at document text
+func needsText(searches []models.SavedSearch) bool {
+	for _, search := range searches {
+		if search.Query.Text != "" || search.Query.Entity != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SavedSearchService) readText(ctx context.Context, doc *models.Document) ([]string, error) {
+	if doc.Size > savedSearchMaxSize {
+		return nil, fmt.Errorf("file is larger than %d MB", savedSearchMaxSize/(1024*1024))
+	}
+	data, err := s.storage.ReadDocument(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	pages, _, err := s.pdfService.PageLineTexts(ctx, bytes.NewReader(data), savedSearchMaxPages)
+	return pages, err
+}
+
+// documentMatch tests saved searches against one new document
+type documentMatch struct {
+	name     string
+	text     string // page text, lowercased with whitespace collapsed
+	tags     []string
+	entities map[string]bool // type and key of each entity mentioned
+}
+
+func newDocumentMatch(doc *models.Document, pages []string) *documentMatch {
+	m := &documentMatch{
+		name:     strings.ToLower(doc.OriginalName),
+		text:     strings.ToLower(strings.Join(strings.Fields(strings.Join(pages, "\n")), " ")),
+		tags:     doc.Metadata.Tags,
+		entities: map[string]bool{},
+	}
+	for _, e := range ExtractEntities(pages) {
+		m.entities[e.Type+"\x00"+e.Key] = true
+	}
+	return m
+}
+
+// matches reports whether the document meets every criterion of q. Text is
+// matched as a phrase, ignoring case and spacing, in the file name or
+// page text.
+func (m *documentMatch) matches(q models.LibrarySearch) bool {
+	if q.Text != "" {
+		phrase := strings.ToLower(strings.Join(strings.Fields(q.Text), " "))
+		if !strings.Contains(m.name, phrase) && !strings.Contains(m.text, phrase) {
+			return false
+		}
+	}
+	for _, tag := range q.Tags {
+		if !containsString(m.tags, tag) {
+			return false
+		}
+	}
+	if q.Entity != "" {
+		types := []string{q.EntityType}
+		if q.EntityType == "" {
+			types = entityTypes
+		}
+		found := false
+		for _, t := range types {
+			if key := EntityKey(t, q.Entity); key != "" && m.entities[t+"\x00"+key] {
+				found = true
+			}
+		}
+		// Names the patterns miss are still found as a phrase
+		if !found && (q.EntityType == models.EntityPerson || q.EntityType == models.EntityCompany || q.EntityType == "") {
+			found = strings.Contains(m.text, strings.ToLower(strings.Join(strings.Fields(q.Entity), " ")))
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
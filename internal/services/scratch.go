@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrScratchQuota is returned when a job's temp files outgrow its quota
+var ErrScratchQuota = errors.New("job exceeded its temp disk quota")
+
+// ScratchStats is a scratch space's temp usage, reported in /health
+type ScratchStats struct {
+	Dir             string `json:"dir"`
+	QuotaBytes      int64  `json:"quotaBytes"` // per job; 0 is unlimited
+	ActiveJobs      int    `json:"activeJobs"`
+	ActiveBytes     int64  `json:"activeBytes"`
+	Jobs            int64  `json:"jobs"`          // created since startup
+	QuotaExceeded   int64  `json:"quotaExceeded"` // jobs stopped at their quota
+	LargestJobBytes int64  `json:"largestJobBytes"`
+	Swept           int64  `json:"swept"` // leftover job dirs removed by the janitor
+}
+
+// ScratchSpace hands out a private directory under root to each job, so
+// concurrent jobs never share file names and one job's files can be
+// measured and removed as a whole
+type ScratchSpace struct {
+	root  string
+	quota int64
+
+	mu       sync.Mutex
+	active   map[string]*Scratch
+	jobs     int64
+	exceeded int64
+	largest  int64
+	swept    int64
+}
+
+// NewScratchSpace creates root if needed. quota bounds each job's files in
+// bytes; 0 leaves them unbounded.
+func NewScratchSpace(root string, quota int64) (*ScratchSpace, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	return &ScratchSpace{root: root, quota: quota, active: make(map[string]*Scratch)}, nil
+}
+
+// SetQuota changes the per-job quota for jobs started after the call
+func (s *ScratchSpace) SetQuota(quota int64) {
+	s.mu.Lock()
+	s.quota = quota
+	s.mu.Unlock()
+}
+
+// New creates a directory for one job; job prefixes its randomized name.
+// The caller must Close it.
+func (s *ScratchSpace) New(job string) (*Scratch, error) {
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(s.root, job+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc := &Scratch{dir: dir, quota: s.quota, space: s}
+	s.active[dir] = sc
+	s.jobs++
+	return sc, nil
+}
+
+// Cleanup is the janitor for job directories left behind by crashes: it
+// removes directories under root that no running job owns and that weren't
+// modified within maxAge. It is a CleanupFunc for the resource monitor.
+func (s *ScratchSpace) Cleanup(ctx context.Context, maxAge time.Duration) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() || ctx.Err() != nil {
+			continue
+		}
+		dir := filepath.Join(s.root, e.Name())
+		if s.Running(dir) {
+			continue
+		}
+		if info, err := e.Info(); err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if os.RemoveAll(dir) == nil {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.mu.Lock()
+		s.swept += int64(removed)
+		s.mu.Unlock()
+		log.Printf("[Scratch] Removed %d leftover job dirs from %s", removed, s.root)
+	}
+}
+
+// Running reports whether dir belongs to a job that hasn't been closed
+func (s *ScratchSpace) Running(dir string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.active[dir]
+	return ok
+}
+
+// Stats reports the space's current and cumulative usage
+func (s *ScratchSpace) Stats() ScratchStats {
+	s.mu.Lock()
+	stats := ScratchStats{
+		Dir:             s.root,
+		QuotaBytes:      s.quota,
+		ActiveJobs:      len(s.active),
+		Jobs:            s.jobs,
+		QuotaExceeded:   s.exceeded,
+		LargestJobBytes: s.largest,
+		Swept:           s.swept,
+	}
+	dirs := make([]string, 0, len(s.active))
+	for dir := range s.active {
+		dirs = append(dirs, dir)
+	}
+	s.mu.Unlock()
+
+	for _, dir := range dirs {
+		stats.ActiveBytes += dirSize(dir)
+	}
+	return stats
+}
+
+// finish records a closed job's size and forgets it
+func (s *ScratchSpace) finish(sc *Scratch, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, sc.dir)
+	if size > s.largest {
+		s.largest = size
+	}
+}
+
+func (s *ScratchSpace) quotaExceeded() {
+	s.mu.Lock()
+	s.exceeded++
+	s.mu.Unlock()
+}
+
+// Scratch is one job's temp directory. Its files count against the quota
+// it was created with; Check enforces it between steps and Writer while
+// copying.
+type Scratch struct {
+	dir   string
+	quota int64
+	space *ScratchSpace
+
+	mu       sync.Mutex
+	seq      int
+	peak     int64
+	exceeded bool
+	closed   bool
+}
+
+// Dir returns the job's directory
+func (sc *Scratch) Dir() string {
+	return sc.dir
+}
+
+// Path returns a new file name in the directory: name with a sequence
+// number before its extension, so repeated calls never collide
+func (sc *Scratch) Path(name string) string {
+	sc.mu.Lock()
+	sc.seq++
+	seq := sc.seq
+	sc.mu.Unlock()
+	ext := filepath.Ext(name)
+	return filepath.Join(sc.dir, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), seq, ext))
+}
+
+// WriteFile writes data to a new file named like Path
+func (sc *Scratch) WriteFile(name string, data []byte) (string, error) {
+	if err := sc.reserve(int64(len(data))); err != nil {
+		return "", err
+	}
+	path := sc.Path(name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Check returns ErrScratchQuota once the directory's files exceed the quota
+func (sc *Scratch) Check() error {
+	return sc.reserve(0)
+}
+
+// reserve checks that n more bytes fit within the quota
+func (sc *Scratch) reserve(n int64) error {
+	size := sc.size()
+	if sc.quota > 0 && size+n > sc.quota {
+		return sc.overQuota()
+	}
+	return nil
+}
+
+// overQuota counts the job as stopped at its quota, once, and returns the
+// error reporting it
+func (sc *Scratch) overQuota() error {
+	sc.mu.Lock()
+	first := !sc.exceeded
+	sc.exceeded = true
+	sc.mu.Unlock()
+	if first {
+		sc.space.quotaExceeded()
+	}
+	return fmt.Errorf("%w (%dMB)", ErrScratchQuota, sc.quota>>20)
+}
+
+// size measures the directory, tracking the largest size seen
+func (sc *Scratch) size() int64 {
+	size := dirSize(sc.dir)
+	sc.mu.Lock()
+	if size > sc.peak {
+		sc.peak = size
+	}
+	sc.mu.Unlock()
+	return size
+}
+
+// Writer wraps w so that writing past the job's remaining quota fails
+// with ErrScratchQuota
+func (sc *Scratch) Writer(w io.Writer) io.Writer {
+	if sc.quota <= 0 {
+		return w
+	}
+	return &quotaWriter{w: w, sc: sc, remaining: sc.quota - sc.size()}
+}
+
+type quotaWriter struct {
+	w         io.Writer
+	sc        *Scratch
+	remaining int64
+}
+
+func (q *quotaWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > q.remaining {
+		return 0, q.sc.overQuota()
+	}
+	n, err := q.w.Write(p)
+	q.remaining -= int64(n)
+	return n, err
+}
+
+// Close removes the directory and everything in it. Safe to call twice.
+func (sc *Scratch) Close() {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return
+	}
+	sc.closed = true
+	sc.mu.Unlock()
+
+	sc.size()
+	if err := os.RemoveAll(sc.dir); err != nil {
+		log.Printf("[Scratch] Failed to remove %s: %v", sc.dir, err)
+	}
+	sc.space.finish(sc, sc.peak)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// shareHitCollection remembers who recently hit which link, keyed by a
+// hash so no IP address or user agent is stored
+const shareHitCollection = "share_hits"
+
+// Kinds of share hits, counted separately in a link's stats
+const (
+	ShareHitView     = "view"     // The share page was opened
+	ShareHitPreview  = "preview"  // The PDF was shown in the page's viewer
+	ShareHitDownload = "download" // The file was downloaded
+)
+
+// botUserAgents matches crawlers, link unfurlers, headless browsers and
+// uptime monitors, whose fetches aren't people looking at a link
+var botUserAgents = regexp.MustCompile(`(?i)bot\b|bot/|crawl|spider|slurp|facebookexternalhit|facebookcatalog|embedly|iframely|link ?preview|whatsapp|skypeuripreview|bitlybot|vkshare|headlesschrome|phantomjs|lighthouse|pingdom|uptimerobot|statuscake|monitor|python-requests|go-http-client|okhttp|java/`)
+
+// IsBotUserAgent reports whether ua, a User-Agent header, is a bot's.
+// Requests without one are treated as bots.
+func IsBotUserAgent(ua string) bool {
+	return strings.TrimSpace(ua) == "" || botUserAgents.MatchString(ua)
+}
+
+// ShareCounter counts a viewer's repeat hits on a link within a window once
+type ShareCounter struct {
+	mongoClient *mongodb.Client
+	window      time.Duration
+}
+
+// NewShareCounter creates a counter that counts a viewer's repeat hits
+// within window once
+func NewShareCounter(mongoClient *mongodb.Client, window time.Duration) *ShareCounter {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mongoClient.Collection(shareHitCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		log.Printf("[Share] Failed to create share hit TTL index: %v", err)
+	}
+	return &ShareCounter{mongoClient: mongoClient, window: window}
+}
+
+// Count reports whether a hit of kind on the link code, from ip with user
+// agent ua, should be counted: the same viewer hasn't made one within the
+// window. Lookup failures count the hit.
+func (s *ShareCounter) Count(ctx context.Context, code, kind, ip, ua string) bool {
+	sum := sha256.Sum256([]byte(code + "|" + kind + "|" + ip + "|" + ua))
+	key := hex.EncodeToString(sum[:])
+
+	// Claim the viewer's slot, or take over one whose window has passed
+	// but which the TTL monitor hasn't removed yet. A slot still in its
+	// window makes the upsert collide on _id.
+	now := time.Now()
+	_, err := s.mongoClient.Collection(shareHitCollection).UpdateOne(ctx,
+		bson.M{"_id": key, "expiresAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"expiresAt": now.Add(s.window)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[Share] Failed to record hit on %s: %v", code, err)
+	}
+	return true
+}
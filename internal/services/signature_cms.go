@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// CMS (RFC 5652) object identifiers used by PDF signatures
+var (
+	oidCMSData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCMSSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttrContentType  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigst = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAPSS          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+var cmsDigests = map[string]crypto.Hash{
+	oidDigestSHA1.String():   crypto.SHA1,
+	oidDigestSHA256.String(): crypto.SHA256,
+	oidDigestSHA384.String(): crypto.SHA384,
+	oidDigestSHA512.String(): crypto.SHA512,
+}
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo asn1.RawValue
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+// derSet encodes a DER SET OF, its elements sorted as DER requires
+func derSet(elems ...[]byte) []byte {
+	sorted := append([][]byte(nil), elems...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	out, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(sorted, nil)})
+	return out
+}
+
+func derAttribute(oid asn1.ObjectIdentifier, value interface{}) ([]byte, error) {
+	v, err := asn1.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsAttribute{Type: oid, Values: asn1.RawValue{FullBytes: derSet(v)}})
+}
+
+// signCMS returns a detached CMS SignedData over a SHA-256 digest, with
+// the signing certificate and chain embedded, as adbe.pkcs7.detached PDF
+// signatures hold it
+func signCMS(digest []byte, key crypto.Signer, cert *x509.Certificate, chain []*x509.Certificate, signedAt time.Time) ([]byte, error) {
+	var sigAlg asn1.ObjectIdentifier
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = oidRSAEncryption
+	case *ecdsa.PublicKey:
+		sigAlg = oidECDSAWithSHA256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.Public())
+	}
+
+	var attrs [][]byte
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttrContentType, oidCMSData},
+		{oidAttrSigningTime, signedAt.UTC()},
+		{oidAttrMessageDigst, digest},
+	} {
+		attr, err := derAttribute(a.oid, a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	// Signed as a SET, stored as [0] IMPLICIT
+	signedAttrs := derSet(attrs...)
+	attrsDigest := crypto.SHA256.New()
+	attrsDigest.Write(signedAttrs)
+	signature, err := key.Sign(rand.Reader, attrsDigest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	signedAttrs[0] = 0xA0
+
+	sid, err := asn1.Marshal(cmsIssuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}
+	digestAlgs, err := asn1.Marshal(digestAlg)
+	if err != nil {
+		return nil, err
+	}
+	encap, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{oidCMSData})
+	if err != nil {
+		return nil, err
+	}
+	certs := [][]byte{cert.Raw}
+	for _, c := range chain {
+		certs = append(certs, c.Raw)
+	}
+
+	sd, err := asn1.Marshal(cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{FullBytes: derSet(digestAlgs)},
+		EncapContentInfo: asn1.RawValue{FullBytes: encap},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(certs, nil)},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        asn1.RawValue{FullBytes: signedAttrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigAlg},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oidCMSSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// cmsSignature is what verifyCMS learns from a signature
+type cmsSignature struct {
+	Signer       *x509.Certificate
+	Certificates []*x509.Certificate
+	SigningTime  *time.Time
+}
+
+// verifyCMS checks a detached CMS SignedData against the signed content read
+// from content:
+// the digest it claims and the signer's signature over it. The signer is
+// returned whenever it can be found, also when the check fails.
+func verifyCMS(der []byte, content io.Reader) (*cmsSignature, error) {
+	// Signature contents are zero padded after the DER
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+	if !ci.ContentType.Equal(oidCMSSignedData) {
+		return nil, errors.New("signature is not CMS signed data")
+	}
+	var sd cmsSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("malformed signed data: %v", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("signature has no signer")
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed certificates: %v", err)
+	}
+
+	si := sd.SignerInfos[0]
+	res := &cmsSignature{Certificates: certs, Signer: findSigner(si.SID, certs)}
+	if res.Signer == nil {
+		return res, errors.New("signing certificate is not embedded")
+	}
+	hash, ok := cmsDigests[si.DigestAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return res, fmt.Errorf("unsupported digest algorithm %s", si.DigestAlgorithm.Algorithm)
+	}
+	h := hash.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return res, fmt.Errorf("failed to read signed content: %w", err)
+	}
+	contentDigest := h.Sum(nil)
+
+	signed := contentDigest
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		var claimed []byte
+		for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+			var a cmsAttribute
+			if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+				return res, fmt.Errorf("malformed signed attributes: %v", err)
+			}
+			switch {
+			case a.Type.Equal(oidAttrMessageDigst):
+				asn1.Unmarshal(a.Values.Bytes, &claimed)
+			case a.Type.Equal(oidAttrSigningTime):
+				var t time.Time
+				if _, err := asn1.Unmarshal(a.Values.Bytes, &t); err == nil {
+					res.SigningTime = &t
+				}
+			}
+		}
+		if !bytes.Equal(claimed, contentDigest) {
+			return res, errors.New("document digest does not match the signature")
+		}
+		attrsDER := append([]byte(nil), si.SignedAttrs.FullBytes...)
+		attrsDER[0] = 0x31 // signed as a SET
+		h := hash.New()
+		h.Write(attrsDER)
+		signed = h.Sum(nil)
+	}
+
+	if err := checkSignature(res.Signer.PublicKey, si.SignatureAlgorithm.Algorithm, hash, signed, si.Signature); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific { // subjectKeyIdentifier
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+	var ias cmsIssuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// checkSignature verifies sig over digest directly, so that old SHA-1
+// signatures can still be reported on
+func checkSignature(pub interface{}, sigAlg asn1.ObjectIdentifier, hash crypto.Hash, digest, sig []byte) error {
+	var err error
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if sigAlg.Equal(oidRSAPSS) {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			err = errors.New("ecdsa verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+	if err != nil {
+		return errors.New("signature does not match the signing certificate")
+	}
+	return nil
+}
@@ -0,0 +1,807 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"brainy-pdf/internal/models"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// Digital signature errors surfaced to handlers
+var (
+	ErrInvalidCertificate = errors.New("invalid certificate")
+	ErrInvalidSignOptions = errors.New("invalid signing options")
+)
+
+// sigContentsReserve is the room left for the CMS signature on top of the
+// embedded certificates: signature, attributes and headers
+const sigContentsReserve = 8192
+
+// DigitalSignOptions configures SignPDF. Certificate is a PKCS#12 (.p12 or
+// .pfx) file holding the signing key and certificate and, optionally, its
+// chain. With Image the signature is visible: drawn Width points wide with
+// its bottom-left corner at X/Y on Page (0 for the last page); without it
+// the signature is only listed in the reader's signature panel.
+type DigitalSignOptions struct {
+	Certificate []byte
+	Password    string
+	Image       []byte
+	Page        int
+	X, Y, Width float64
+	Reason      string
+	Location    string
+	ContactInfo string
+}
+
+// signingIdentity is the key and certificates read from a PKCS#12 file
+type signingIdentity struct {
+	key   crypto.Signer
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+}
+
+// loadPKCS12 reads the signing key, its certificate and the rest of the
+// chain. Files encrypted with AES (OpenSSL 3's default) aren't supported;
+// export them with -legacy.
+func loadPKCS12(data []byte, password string) (*signingIdentity, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, fmt.Errorf("%w: incorrect password", ErrInvalidCertificate)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	id := &signingIdentity{}
+	var certs []*x509.Certificate
+	for _, b := range blocks {
+		switch b.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			id.key, err = parsePEMKey(b)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if id.key == nil {
+		return nil, fmt.Errorf("%w: no private key in the file", ErrInvalidCertificate)
+	}
+
+	for _, cert := range certs {
+		if publicKeysEqual(cert.PublicKey, id.key.Public()) && id.cert == nil {
+			id.cert = cert
+		} else {
+			id.chain = append(id.chain, cert)
+		}
+	}
+	if id.cert == nil {
+		return nil, fmt.Errorf("%w: no certificate for the private key", ErrInvalidCertificate)
+	}
+	now := time.Now()
+	if now.Before(id.cert.NotBefore) || now.After(id.cert.NotAfter) {
+		return nil, fmt.Errorf("%w: certificate is valid from %s to %s", ErrInvalidCertificate,
+			id.cert.NotBefore.Format("2006-01-02"), id.cert.NotAfter.Format("2006-01-02"))
+	}
+	return id, nil
+}
+
+// parsePEMKey reads a key as pkcs12.ToPEM encodes it: PKCS#1 for RSA,
+// SEC 1 for ECDSA
+func parsePEMKey(b *pem.Block) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(b.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(b.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: only RSA and ECDSA keys are supported", ErrInvalidCertificate)
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	switch a := a.(type) {
+	case *rsa.PublicKey:
+		return a.Equal(b)
+	case *ecdsa.PublicKey:
+		return a.Equal(b)
+	}
+	return false
+}
+
+// SignPDF adds a digital signature to a PDF as an incremental update, so
+// signatures already in it stay valid. The signature is a detached CMS
+// (adbe.pkcs7.detached) over every byte of the result but its own.
+func (s *SignatureService) SignPDF(ctx context.Context, in PDFReader, opts DigitalSignOptions) ([]byte, *models.DigitalSignature, error) {
+	id, err := loadPKCS12(opts.Certificate, opts.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	pdfCtx, err := api.ReadContext(readSeeker(in), conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if pdfCtx.Encrypt != nil {
+		return nil, nil, fmt.Errorf("%w: remove the password before signing", ErrAlreadyEncrypted)
+	}
+	if err := pdfCtx.EnsurePageCount(); err != nil {
+		return nil, nil, err
+	}
+	if opts.Page == 0 {
+		opts.Page = pdfCtx.PageCount
+	}
+	if opts.Page < 1 || opts.Page > pdfCtx.PageCount {
+		return nil, nil, fmt.Errorf("%w: page must be between 1 and %d", ErrInvalidSignOptions, pdfCtx.PageCount)
+	}
+	startXRef, err := lastStartXRef(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pageDict, pageRef, inherited, err := pdfCtx.PageDict(opts.Page, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	catalog, err := pdfCtx.Catalog()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u := newPDFUpdate(in, pdfCtx)
+	signedAt := time.Now().UTC().Truncate(time.Second)
+	sig := &models.DigitalSignature{
+		Signer:       id.cert.Subject.CommonName,
+		Subject:      id.cert.Subject.String(),
+		Issuer:       id.cert.Issuer.String(),
+		SerialNumber: id.cert.SerialNumber.Text(16),
+		NotBefore:    id.cert.NotBefore,
+		NotAfter:     id.cert.NotAfter,
+		SignedAt:     &signedAt,
+		Reason:       strings.TrimSpace(opts.Reason),
+		Location:     strings.TrimSpace(opts.Location),
+		ContactInfo:  strings.TrimSpace(opts.ContactInfo),
+		SubFilter:    "adbe.pkcs7.detached",
+	}
+	if len(id.cert.EmailAddresses) > 0 {
+		sig.Email = id.cert.EmailAddresses[0]
+	}
+	if sig.Signer == "" {
+		sig.Signer = sig.Subject
+	}
+
+	// Appearance: the image scaled into the widget, or nothing
+	rect := "[0 0 0 0]"
+	apDict := "<< /Type /XObject /Subtype /Form /BBox [0 0 0 0] /Length 0 >>\nstream\n\nendstream"
+	if len(opts.Image) > 0 {
+		img, _, err := image.Decode(bytes.NewReader(opts.Image))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: image must be a PNG", ErrInvalidSignOptions)
+		}
+		if opts.Width <= 0 {
+			opts.Width = 150
+		}
+		bounds := img.Bounds()
+		w, h := opts.Width, opts.Width*float64(bounds.Dy())/float64(bounds.Dx())
+		imgNr, err := u.addImage(img)
+		if err != nil {
+			return nil, nil, err
+		}
+		content := fmt.Sprintf("q %s 0 0 %s 0 0 cm /Img Do Q", formatNumber(w), formatNumber(h))
+		apDict = fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [0 0 %s %s] /Resources << /XObject << /Img %d 0 R >> >> /Length %d >>\nstream\n%s\nendstream",
+			formatNumber(w), formatNumber(h), imgNr, len(content), content)
+
+		x, y := opts.X, opts.Y
+		if mb := inherited.MediaBox; mb != nil {
+			x, y = x+mb.LL.X, y+mb.LL.Y
+		}
+		rect = fmt.Sprintf("[%s %s %s %s]", formatNumber(x), formatNumber(y), formatNumber(x+w), formatNumber(y+h))
+	}
+	appearance := u.add(apDict)
+
+	// The signature value, with room for the CMS and a ByteRange patched
+	// in once the offsets are known
+	reserve := sigContentsReserve + len(id.cert.Raw)
+	for _, c := range id.chain {
+		reserve += len(c.Raw)
+	}
+	sigDict := fmt.Sprintf("<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached /ByteRange %s /Contents <%s> /M %s /Name %s",
+		byteRangePlaceholder, strings.Repeat("0", reserve*2), pdfTextString(pdfDate(signedAt)), pdfTextString(sig.Signer))
+	for _, entry := range [][2]string{{"Reason", sig.Reason}, {"Location", sig.Location}, {"ContactInfo", sig.ContactInfo}} {
+		if entry[1] != "" {
+			sigDict += fmt.Sprintf(" /%s %s", entry[0], pdfTextString(entry[1]))
+		}
+	}
+	sigNr := u.add(sigDict + " >>")
+
+	acroForm, acroFormRef, err := u.acroForm(catalog)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig.Field = uniqueFieldName(pdfCtx, acroForm, "Signature")
+	widgetNr := u.add(fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Sig /T %s /V %d 0 R /F 132 /P %s /Rect %s /AP << /N %d 0 R >> >>",
+		pdfTextString(sig.Field), sigNr, pageRef.PDFString(), rect, appearance))
+	widget := *types.NewIndirectRef(widgetNr, 0)
+
+	// The widget joins the page's annotations and the form's fields
+	if changed, err := u.appendRef(pageDict, "Annots", widget); err != nil {
+		return nil, nil, err
+	} else if changed {
+		u.put(int(pageRef.ObjectNumber), int(pageRef.GenerationNumber), pageDict.PDFString())
+	}
+	if _, err := u.appendRef(acroForm, "Fields", widget); err != nil {
+		return nil, nil, err
+	}
+	acroForm["SigFlags"] = types.Integer(3) // signatures exist; append only
+	if acroFormRef != nil {
+		u.put(int(acroFormRef.ObjectNumber), int(acroFormRef.GenerationNumber), acroForm.PDFString())
+	} else {
+		catalog["AcroForm"] = acroForm
+		root := pdfCtx.Root
+		u.put(int(root.ObjectNumber), int(root.GenerationNumber), catalog.PDFString())
+	}
+
+	out, err := u.finish(startXRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := signByteRange(out, u.offset(sigNr), id, signedAt); err != nil {
+		return nil, nil, err
+	}
+	return out, sig, nil
+}
+
+// byteRangePlaceholder is overwritten in place, padded with spaces
+var byteRangePlaceholder = "[0 " + strings.Repeat("9", 10) + " " + strings.Repeat("9", 10) + " " + strings.Repeat("9", 10) + "]"
+
+// signByteRange fills in the ByteRange and Contents of the signature
+// dictionary at sigOffset: everything but the Contents value is hashed
+// and signed.
+func signByteRange(out []byte, sigOffset int64, id *signingIdentity, signedAt time.Time) error {
+	obj := out[sigOffset:]
+	brAt := bytes.Index(obj, []byte(byteRangePlaceholder))
+	contentsAt := bytes.Index(obj, []byte("/Contents <"))
+	if brAt < 0 || contentsAt < 0 {
+		return errors.New("signature placeholder not found")
+	}
+	start := int(sigOffset) + contentsAt + len("/Contents ")
+	end := start + bytes.IndexByte(out[start:], '>') + 1
+
+	byteRange := fmt.Sprintf("[0 %d %d %d]", start, end, len(out)-end)
+	byteRange += strings.Repeat(" ", len(byteRangePlaceholder)-len(byteRange))
+	copy(out[int(sigOffset)+brAt:], byteRange)
+
+	h := sha256.New()
+	h.Write(out[:start])
+	h.Write(out[end:])
+	cms, err := signCMS(h.Sum(nil), id.key, id.cert, id.chain, signedAt)
+	if err != nil {
+		return err
+	}
+	encoded := hex.EncodeToString(cms)
+	if len(encoded) > end-start-2 {
+		return errors.New("signature exceeds the space reserved for it")
+	}
+	copy(out[start+1:], encoded)
+	return nil
+}
+
+// VerifyPDFSignatures checks every signature field of a PDF, oldest first
+func (s *SignatureService) VerifyPDFSignatures(ctx context.Context, in PDFReader) ([]models.SignatureVerification, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	pdfCtx, err := api.ReadContext(readSeeker(in), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	catalog, err := pdfCtx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	acroForm, err := pdfCtx.DereferenceDict(catalog["AcroForm"])
+	if err != nil || acroForm == nil {
+		return []models.SignatureVerification{}, nil
+	}
+	fields, err := pdfCtx.DereferenceArray(acroForm["Fields"])
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		start int64
+		res   models.SignatureVerification
+	}
+	var sigs []found
+	var walk func(fields types.Array, prefix, inheritedFT string, depth int)
+	walk = func(fields types.Array, prefix, inheritedFT string, depth int) {
+		if depth > 32 {
+			return
+		}
+		for _, o := range fields {
+			field, err := pdfCtx.DereferenceDict(o)
+			if err != nil || field == nil {
+				continue
+			}
+			name := prefix
+			if t := pdfObjectText(pdfCtx, field["T"]); t != "" {
+				if name != "" {
+					name += "."
+				}
+				name += t
+			}
+			ft := inheritedFT
+			if n, ok := field["FT"].(types.Name); ok {
+				ft = string(n)
+			}
+			if kids, err := pdfCtx.DereferenceArray(field["Kids"]); err == nil && len(kids) > 0 {
+				walk(kids, name, ft, depth+1)
+				continue
+			}
+			if ft != "Sig" {
+				continue
+			}
+			v, err := pdfCtx.DereferenceDict(field["V"])
+			if err != nil || v == nil {
+				continue // unsigned signature field
+			}
+			start, res := verifySignatureDict(pdfCtx, in, v)
+			res.Field = name
+			sigs = append(sigs, found{start, res})
+		}
+	}
+	walk(fields, "", "", 0)
+
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].start < sigs[j].start })
+	out := make([]models.SignatureVerification, 0, len(sigs))
+	for _, f := range sigs {
+		out = append(out, f.res)
+	}
+	return out, nil
+}
+
+// verifySignatureDict checks one signature value against the bytes its
+// ByteRange covers, returning where its Contents start for ordering
+func verifySignatureDict(pdfCtx *model.Context, in PDFReader, v types.Dict) (int64, models.SignatureVerification) {
+	var res models.SignatureVerification
+	res.Reason = pdfObjectText(pdfCtx, v["Reason"])
+	res.Location = pdfObjectText(pdfCtx, v["Location"])
+	res.ContactInfo = pdfObjectText(pdfCtx, v["ContactInfo"])
+	if n, ok := v["SubFilter"].(types.Name); ok {
+		res.SubFilter = string(n)
+	}
+	if m := pdfObjectText(pdfCtx, v["M"]); m != "" {
+		if t, ok := types.DateTime(m, true); ok {
+			res.SignedAt = &t
+		}
+	}
+
+	br, err := pdfCtx.DereferenceArray(v["ByteRange"])
+	var r [4]int64
+	for i := 0; err == nil && i < 4 && len(br) == 4; i++ {
+		n, ok := br[i].(types.Integer)
+		if !ok || n < 0 {
+			err = errors.New("malformed ByteRange")
+		}
+		r[i] = int64(n)
+	}
+	size := in.Size()
+	if err != nil || len(br) != 4 || r[0] != 0 || r[1]+1 >= r[2] || r[2]+r[3] > size {
+		res.Error = "signature ByteRange doesn't match the file"
+		return r[1], res
+	}
+	// The gap between the ranges is the hex Contents string, delimiters
+	// included
+	gap := make([]byte, r[2]-r[1])
+	if _, err := in.ReadAt(gap, r[1]); err != nil || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		res.Error = "signature ByteRange doesn't match the file"
+		return r[1], res
+	}
+	res.CoversWholeDocument = onlySpace(io.NewSectionReader(in, r[2]+r[3], size-r[2]-r[3]))
+
+	switch res.SubFilter {
+	case "adbe.pkcs7.detached", "ETSI.CAdES.detached":
+	default:
+		res.Error = "unsupported signature format " + res.SubFilter
+		return r[1], res
+	}
+	// Zero padding after the DER is ignored by the parser
+	raw := strings.Join(strings.Fields(string(gap[1:len(gap)-1])), "")
+	if len(raw)%2 == 1 {
+		raw += "0"
+	}
+	contents, err := hex.DecodeString(raw)
+	if err != nil {
+		res.Error = "malformed signature contents"
+		return r[1], res
+	}
+
+	signed := io.MultiReader(io.NewSectionReader(in, 0, r[1]), io.NewSectionReader(in, r[2], r[3]))
+	cms, err := verifyCMS(contents, signed)
+	if cms != nil && cms.Signer != nil {
+		describeSigner(&res, cms)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return r[1], res
+	}
+	res.Valid = true
+	return r[1], res
+}
+
+// describeSigner fills in the signer and checks its certificate at the
+// time of signing
+func describeSigner(res *models.SignatureVerification, cms *cmsSignature) {
+	cert := cms.Signer
+	res.Signer = cert.Subject.CommonName
+	if res.Signer == "" {
+		res.Signer = cert.Subject.String()
+	}
+	if len(cert.EmailAddresses) > 0 {
+		res.Email = cert.EmailAddresses[0]
+	}
+	res.Subject, res.Issuer = cert.Subject.String(), cert.Issuer.String()
+	res.SerialNumber = cert.SerialNumber.Text(16)
+	res.NotBefore, res.NotAfter = cert.NotBefore, cert.NotAfter
+	if cms.SigningTime != nil {
+		res.SignedAt = cms.SigningTime
+	}
+
+	at := time.Now()
+	if res.SignedAt != nil {
+		at = *res.SignedAt
+	}
+	res.CertificateValid = !at.Before(cert.NotBefore) && !at.After(cert.NotAfter)
+	res.SelfSigned = bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cms.Certificates {
+		if c != cert {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	res.Trusted = err == nil
+}
+
+// pdfUpdate collects the objects of an incremental update, appended to the
+// original bytes untouched
+type pdfUpdate struct {
+	ctx     *model.Context
+	in      PDFReader
+	buf     bytes.Buffer
+	offsets map[int]int64
+	gens    map[int]int
+	next    int
+}
+
+func newPDFUpdate(in PDFReader, ctx *model.Context) *pdfUpdate {
+	u := &pdfUpdate{ctx: ctx, in: in, offsets: map[int]int64{}, gens: map[int]int{}}
+	if ctx.Size != nil {
+		u.next = *ctx.Size
+	}
+	for nr := range ctx.Table {
+		if nr >= u.next {
+			u.next = nr + 1
+		}
+	}
+	last := make([]byte, 1)
+	if size := in.Size(); size > 0 {
+		if _, err := in.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+			u.buf.WriteByte('\n')
+		}
+	}
+	return u
+}
+
+// add writes a new object and returns its number
+func (u *pdfUpdate) add(body string) int {
+	nr := u.next
+	u.next++
+	u.put(nr, 0, body)
+	return nr
+}
+
+// put writes (or rewrites) an object
+func (u *pdfUpdate) put(nr, gen int, body string) {
+	u.offsets[nr] = u.in.Size() + int64(u.buf.Len())
+	u.gens[nr] = gen
+	fmt.Fprintf(&u.buf, "%d %d obj\n%s\nendobj\n", nr, gen, body)
+}
+
+func (u *pdfUpdate) offset(nr int) int64 {
+	return u.offsets[nr]
+}
+
+// addImage writes img as an RGB image XObject, with a soft mask for its
+// transparency
+func (u *pdfUpdate) addImage(img image.Image) (int, error) {
+	b := img.Bounds()
+	rgb := make([]byte, 0, b.Dx()*b.Dy()*3)
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	transparent := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a > 0 { // un-premultiply
+				r, g, bl = r*0xffff/a, g*0xffff/a, bl*0xffff/a
+			}
+			rgb = append(rgb, byte(r>>8), byte(g>>8), byte(bl>>8))
+			alpha = append(alpha, byte(a>>8))
+			transparent = transparent || a != 0xffff
+		}
+	}
+
+	smask := ""
+	if transparent {
+		z, err := deflate(alpha)
+		if err != nil {
+			return 0, err
+		}
+		nr := u.add(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			b.Dx(), b.Dy(), len(z), z))
+		smask = fmt.Sprintf(" /SMask %d 0 R", nr)
+	}
+	z, err := deflate(rgb)
+	if err != nil {
+		return 0, err
+	}
+	return u.add(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode%s /Length %d >>\nstream\n%s\nendstream",
+		b.Dx(), b.Dy(), smask, len(z), z)), nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acroForm returns the document's interactive form, a new one if it has
+// none, and its reference when it is an object of its own
+func (u *pdfUpdate) acroForm(catalog types.Dict) (types.Dict, *types.IndirectRef, error) {
+	switch v := catalog["AcroForm"].(type) {
+	case types.IndirectRef:
+		d, err := u.ctx.DereferenceDict(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d != nil {
+			return d, &v, nil
+		}
+	case types.Dict:
+		return v, nil, nil
+	}
+	return types.Dict{"Fields": types.Array{}}, nil, nil
+}
+
+// appendRef adds ref to the array d[key], rewriting the array's own object
+// when it has one. It reports whether d itself changed.
+func (u *pdfUpdate) appendRef(d types.Dict, key string, ref types.IndirectRef) (bool, error) {
+	switch v := d[key].(type) {
+	case types.IndirectRef:
+		arr, err := u.ctx.DereferenceArray(v)
+		if err != nil {
+			return false, err
+		}
+		arr = append(arr, ref)
+		u.put(int(v.ObjectNumber), int(v.GenerationNumber), arr.PDFString())
+		return false, nil
+	case types.Array:
+		d[key] = append(v, ref)
+	default:
+		d[key] = types.Array{ref}
+	}
+	return true, nil
+}
+
+// finish appends the cross-reference section, in the form the document
+// already uses, and returns the whole file
+func (u *pdfUpdate) finish(prev int64) ([]byte, error) {
+	trailer := fmt.Sprintf("/Root %s /Prev %d", u.ctx.Root.PDFString(), prev)
+	if u.ctx.Info != nil {
+		trailer += " /Info " + u.ctx.Info.PDFString()
+	}
+	if len(u.ctx.ID) > 0 {
+		trailer += " /ID " + u.ctx.ID.PDFString()
+	}
+
+	if u.ctx.Read != nil && u.ctx.Read.UsingXRefStreams {
+		// The xref stream lists itself
+		nr := u.next
+		u.next++
+		u.offsets[nr] = u.in.Size() + int64(u.buf.Len())
+		u.gens[nr] = 0
+		index, rows := u.xrefSections()
+		var stream bytes.Buffer
+		for _, row := range rows {
+			stream.WriteByte(1)
+			stream.Write([]byte{byte(row.offset >> 24), byte(row.offset >> 16), byte(row.offset >> 8), byte(row.offset)})
+			stream.Write([]byte{byte(row.gen >> 8), byte(row.gen)})
+		}
+		fmt.Fprintf(&u.buf, "%d 0 obj\n<< /Type /XRef /Size %d /Index [%s] /W [1 4 2] %s /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+			nr, u.next, index, trailer, stream.Len(), stream.Bytes())
+		fmt.Fprintf(&u.buf, "startxref\n%d\n%%%%EOF\n", u.offsets[nr])
+	} else {
+		xrefAt := u.in.Size() + int64(u.buf.Len())
+		index, rows := u.xrefSections()
+		u.buf.WriteString("xref\n")
+		fields := strings.Fields(index)
+		i := 0
+		for s := 0; s+1 < len(fields); s += 2 {
+			count, _ := strconv.Atoi(fields[s+1])
+			fmt.Fprintf(&u.buf, "%s %d\n", fields[s], count)
+			for ; count > 0; count-- {
+				fmt.Fprintf(&u.buf, "%010d %05d n\r\n", rows[i].offset, rows[i].gen)
+				i++
+			}
+		}
+		fmt.Fprintf(&u.buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", u.next, trailer, xrefAt)
+	}
+
+	out := make([]byte, u.in.Size(), u.in.Size()+int64(u.buf.Len()))
+	if _, err := io.ReadFull(readSeeker(u.in), out); err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return append(out, u.buf.Bytes()...), nil
+}
+
+type xrefRow struct {
+	offset int64
+	gen    int
+}
+
+// xrefSections returns the written objects as "first count" subsections
+// and their rows in the same order
+func (u *pdfUpdate) xrefSections() (string, []xrefRow) {
+	nrs := make([]int, 0, len(u.offsets))
+	for nr := range u.offsets {
+		nrs = append(nrs, nr)
+	}
+	sort.Ints(nrs)
+
+	var index []string
+	var rows []xrefRow
+	for i := 0; i < len(nrs); {
+		j := i
+		for j+1 < len(nrs) && nrs[j+1] == nrs[j]+1 {
+			j++
+		}
+		index = append(index, fmt.Sprintf("%d %d", nrs[i], j-i+1))
+		for k := i; k <= j; k++ {
+			rows = append(rows, xrefRow{u.offsets[nrs[k]], u.gens[nrs[k]]})
+		}
+		i = j + 1
+	}
+	return strings.Join(index, " "), rows
+}
+
+// startXRefWindow is how much of the end of a file lastStartXRef searches
+const startXRefWindow = 64 << 10
+
+// onlySpace reports whether r holds nothing but whitespace
+func onlySpace(r io.Reader) bool {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		if len(bytes.TrimSpace(buf[:n])) > 0 {
+			return false
+		}
+		if err != nil {
+			return err == io.EOF
+		}
+	}
+}
+
+// lastStartXRef returns the offset of the document's last cross-reference
+// section, where an update's /Prev points. It is looked for in the tail of
+// the file, where readers expect it.
+func lastStartXRef(in PDFReader) (int64, error) {
+	data := make([]byte, min(in.Size(), startXRefWindow))
+	if _, err := in.ReadAt(data, in.Size()-int64(len(data))); err != nil {
+		return 0, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i < 0 {
+		return 0, errors.New("PDF has no cross-reference table")
+	}
+	fields := strings.Fields(string(data[i+len("startxref") : min(len(data), i+len("startxref")+32)]))
+	if len(fields) == 0 {
+		return 0, errors.New("PDF has no cross-reference table")
+	}
+	return strconv.ParseInt(fields[0], 10, 64)
+}
+
+// uniqueFieldName returns base1, base2, ... : the first not used by a
+// top-level field of the form
+func uniqueFieldName(pdfCtx *model.Context, acroForm types.Dict, base string) string {
+	taken := map[string]bool{}
+	if fields, err := pdfCtx.DereferenceArray(acroForm["Fields"]); err == nil {
+		for _, o := range fields {
+			if field, err := pdfCtx.DereferenceDict(o); err == nil && field != nil {
+				taken[pdfObjectText(pdfCtx, field["T"])] = true
+			}
+		}
+	}
+	for n := 1; ; n++ {
+		if name := fmt.Sprintf("%s%d", base, n); !taken[name] {
+			return name
+		}
+	}
+}
+
+// pdfObjectText decodes a PDF text string, literal or hex
+func pdfObjectText(pdfCtx *model.Context, o types.Object) string {
+	o, err := pdfCtx.Dereference(o)
+	if err != nil || o == nil {
+		return ""
+	}
+	s, err := types.StringOrHexLiteral(o)
+	if err != nil || s == nil {
+		return ""
+	}
+	return *s
+}
+
+// pdfTextString encodes s as a PDF text string: a literal for ASCII,
+// UTF-16BE hex otherwise
+func pdfTextString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, c := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", c)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfDate formats t as a PDF date
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405") + "+00'00'"
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Signature library limits
+const (
+	MaxSignatureSize      = 512 * 1024 // bytes
+	MaxSignatureDimension = 2000       // pixels, either side
+	MaxSignaturesPerUser  = 20
+)
+
+// Signature errors surfaced to handlers. ErrSignatureNotFound also covers
+// signatures owned by someone else.
+var (
+	ErrSignatureNotFound = errors.New("signature not found")
+	ErrInvalidSignature  = errors.New("invalid signature")
+)
+
+// SignatureService stores reusable signature and initials images per user
+type SignatureService struct {
+	mongoClient *mongodb.Client
+	minioClient *minioPkg.Client
+}
+
+// NewSignatureService creates a signature service
+func NewSignatureService(mongoClient *mongodb.Client, minioClient *minioPkg.Client) *SignatureService {
+	return &SignatureService{
+		mongoClient: mongoClient,
+		minioClient: minioClient,
+	}
+}
+
+func (s *SignatureService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("signatures")
+}
+
+// ValidateSignatureImage checks that data is a small PNG and returns its
+// dimensions and whether it has any transparent pixels
+func ValidateSignatureImage(data []byte) (width, height int, transparent bool, err error) {
+	if len(data) > MaxSignatureSize {
+		return 0, 0, false, fmt.Errorf("%w: image exceeds %d KB", ErrInvalidSignature, MaxSignatureSize/1024)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("%w: image must be a PNG", ErrInvalidSignature)
+	}
+	if cfg.Width > MaxSignatureDimension || cfg.Height > MaxSignatureDimension {
+		return 0, 0, false, fmt.Errorf("%w: image must be at most %dx%d pixels", ErrInvalidSignature, MaxSignatureDimension, MaxSignatureDimension)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return cfg.Width, cfg.Height, hasTransparency(img), nil
+}
+
+func hasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return true
+}
+
+// Create stores a new signature image
+func (s *SignatureService) Create(ctx context.Context, ownerUID, name, kind string, data []byte) (*models.Signature, error) {
+	kind, err := normalizeSignatureKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	width, height, transparent, err := ValidateSignatureImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.collection().CountDocuments(ctx, bson.M{"ownerUid": ownerUID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count signatures: %w", err)
+	}
+	if count >= MaxSignaturesPerUser {
+		return nil, fmt.Errorf("%w: library is limited to %d images; delete one first", ErrInvalidSignature, MaxSignaturesPerUser)
+	}
+
+	now := time.Now()
+	sig := &models.Signature{
+		ID:              primitive.NewObjectID(),
+		OwnerUID:        ownerUID,
+		Name:            strings.TrimSpace(name),
+		Kind:            kind,
+		Width:           width,
+		Height:          height,
+		Size:            int64(len(data)),
+		HasTransparency: transparent,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if sig.Name == "" {
+		sig.Name = "Signature"
+		if kind == models.SignatureKindInitials {
+			sig.Name = "Initials"
+		}
+	}
+	sig.ObjectKey = fmt.Sprintf("%s/signatures/%s.png", ownerUID, sig.ID.Hex())
+
+	if _, err := s.minioClient.UploadBytes(ctx, s.minioClient.GetBucketUserFiles(), sig.ObjectKey, data, "image/png"); err != nil {
+		return nil, fmt.Errorf("failed to store signature: %w", err)
+	}
+	if _, err := s.collection().InsertOne(ctx, sig); err != nil {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketUserFiles(), sig.ObjectKey)
+		return nil, fmt.Errorf("failed to save signature: %w", err)
+	}
+
+	s.attachURL(ctx, sig)
+	return sig, nil
+}
+
+// List returns the user's signatures, newest first
+func (s *SignatureService) List(ctx context.Context, ownerUID string) ([]models.Signature, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := s.collection().Find(ctx, bson.M{"ownerUid": ownerUID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signatures: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sigs := []models.Signature{}
+	if err := cursor.All(ctx, &sigs); err != nil {
+		return nil, fmt.Errorf("failed to decode signatures: %w", err)
+	}
+	for i := range sigs {
+		s.attachURL(ctx, &sigs[i])
+	}
+	return sigs, nil
+}
+
+// Get returns one of the user's signatures
+func (s *SignatureService) Get(ctx context.Context, ownerUID, id string) (*models.Signature, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSignatureNotFound
+	}
+
+	var sig models.Signature
+	err = s.collection().FindOne(ctx, bson.M{"_id": objID, "ownerUid": ownerUID}).Decode(&sig)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSignatureNotFound
+		}
+		return nil, err
+	}
+	s.attachURL(ctx, &sig)
+	return &sig, nil
+}
+
+// GetImage returns the signature and its PNG bytes, for stamping
+func (s *SignatureService) GetImage(ctx context.Context, ownerUID, id string) (*models.Signature, []byte, error) {
+	sig, err := s.Get(ctx, ownerUID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := s.minioClient.DownloadFile(ctx, s.minioClient.GetBucketUserFiles(), sig.ObjectKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load signature image: %w", err)
+	}
+	return sig, data, nil
+}
+
+// Update renames a signature or changes its kind; empty values are kept
+func (s *SignatureService) Update(ctx context.Context, ownerUID, id, name, kind string) (*models.Signature, error) {
+	sig, err := s.Get(ctx, ownerUID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if name = strings.TrimSpace(name); name != "" {
+		set["name"] = name
+	}
+	if kind != "" {
+		if kind, err = normalizeSignatureKind(kind); err != nil {
+			return nil, err
+		}
+		set["kind"] = kind
+	}
+
+	if _, err := s.collection().UpdateOne(ctx, bson.M{"_id": sig.ID}, bson.M{"$set": set}); err != nil {
+		return nil, fmt.Errorf("failed to update signature: %w", err)
+	}
+	return s.Get(ctx, ownerUID, id)
+}
+
+// Delete removes a signature and its image
+func (s *SignatureService) Delete(ctx context.Context, ownerUID, id string) error {
+	sig, err := s.Get(ctx, ownerUID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.collection().DeleteOne(ctx, bson.M{"_id": sig.ID}); err != nil {
+		return fmt.Errorf("failed to delete signature: %w", err)
+	}
+	s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketUserFiles(), sig.ObjectKey)
+	return nil
+}
+
+func (s *SignatureService) attachURL(ctx context.Context, sig *models.Signature) {
+	sig.URL, _ = s.minioClient.GetPresignedURL(ctx, s.minioClient.GetBucketUserFiles(), sig.ObjectKey, 1*time.Hour)
+}
+
+func normalizeSignatureKind(kind string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", models.SignatureKindSignature:
+		return models.SignatureKindSignature, nil
+	case models.SignatureKindInitials:
+		return models.SignatureKindInitials, nil
+	default:
+		return "", fmt.Errorf("%w: kind must be %q or %q", ErrInvalidSignature, models.SignatureKindSignature, models.SignatureKindInitials)
+	}
+}
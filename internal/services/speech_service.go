@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// speechChunkChars keeps each request under the 4096-character input
+	// limit of OpenAI's speech API
+	speechChunkChars = 4000
+	// speechConcurrency bounds the chunks synthesized at once
+	speechConcurrency = 4
+)
+
+// SpeechService reads text aloud with an OpenAI-compatible /audio/speech
+// API, producing MP3 audio
+type SpeechService struct {
+	apiURL     string
+	apiKey     string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+// NewSpeechService creates a text-to-speech service. apiKey may be empty,
+// in which case the capability is reported unavailable.
+func NewSpeechService(apiURL, apiKey, model, voice string) *SpeechService {
+	if apiKey != "" {
+		log.Printf("[Speech] Using %s (voice %s) at %s", model, voice, apiURL)
+	}
+	return &SpeechService{
+		apiURL:     apiURL,
+		apiKey:     apiKey,
+		model:      model,
+		voice:      voice,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Capability reports whether text can be read aloud. Safe to call on a nil
+// service.
+func (s *SpeechService) Capability() Capability {
+	c := Capability{Name: CapabilitySpeech}
+	switch {
+	case s == nil:
+		c.Reason = "Speech service failed to start"
+	case s.apiKey == "":
+		c.Reason = "Text-to-speech API key is not configured"
+	default:
+		c.Available = true
+	}
+	return c
+}
+
+// DefaultVoice is the voice used when a request does not pick one
+func (s *SpeechService) DefaultVoice() string {
+	return s.voice
+}
+
+// Synthesize reads text aloud and returns MP3 audio. Long text is split at
+// paragraph and sentence boundaries and synthesized in parallel; MP3 frames
+// are self-contained, so the parts play back as one file when concatenated.
+// An empty voice uses the configured default.
+func (s *SpeechService) Synthesize(ctx context.Context, text, voice string) ([]byte, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("text-to-speech API key not configured")
+	}
+	if voice == "" {
+		voice = s.voice
+	}
+
+	chunks := chunkText(text, speechChunkChars)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no text to read")
+	}
+
+	parts := make([][]byte, len(chunks))
+	err := runLimited(ctx, len(chunks), speechConcurrency, func(ctx context.Context, i int) error {
+		var err error
+		parts[i], err = s.synthesizeChunk(ctx, chunks[i], voice)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Join(parts, nil), nil
+}
+
+// synthesizeChunk makes one speech API call
+func (s *SpeechService) synthesizeChunk(ctx context.Context, text, voice string) ([]byte, error) {
+	payload, err := json.Marshal(map[string]string{
+		"model":           s.model,
+		"voice":           voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call text-to-speech API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("text-to-speech rate limit exceeded")
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Speech] API error response: %s", string(body))
+		return nil, fmt.Errorf("text-to-speech API error (status %d)", resp.StatusCode)
+	}
+	return body, nil
+}
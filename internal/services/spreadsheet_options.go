@@ -0,0 +1,485 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Spreadsheet page orientations
+const (
+	SpreadsheetPortrait  = "portrait"
+	SpreadsheetLandscape = "landscape"
+)
+
+// spreadsheetMaxSheets bounds the sheets a conversion can select
+const spreadsheetMaxSheets = 100
+
+// spreadsheetPartLimit bounds a workbook part read into memory to apply
+// the options
+const spreadsheetPartLimit = 100 * 1024 * 1024
+
+var ErrInvalidSpreadsheetOptions = errors.New("invalid spreadsheet options")
+
+// SpreadsheetOptions lay out xls/xlsx files converted to PDF. LibreOffice
+// takes orientation, scaling and gridlines from each sheet's page style
+// rather than from its PDF export filter, so they are written into the
+// workbook's print setup before it is exported; sheets that weren't
+// selected are hidden, which keeps them out of the PDF.
+type SpreadsheetOptions struct {
+	Orientation string   `json:"orientation,omitempty" bson:"orientation,omitempty"` // empty keeps each sheet's own
+	FitToWidth  bool     `json:"fitToWidth,omitempty" bson:"fitToWidth,omitempty"`   // all columns on one page width
+	Gridlines   bool     `json:"gridlines,omitempty" bson:"gridlines,omitempty"`
+	Sheets      []string `json:"sheets,omitempty" bson:"sheets,omitempty"` // names to export; empty exports all
+}
+
+// NewSpreadsheetOptions validates spreadsheet options, returning nil when
+// none are set
+func NewSpreadsheetOptions(orientation string, fitToWidth, gridlines bool, sheets []string) (*SpreadsheetOptions, error) {
+	opts := &SpreadsheetOptions{
+		Orientation: strings.ToLower(strings.TrimSpace(orientation)),
+		FitToWidth:  fitToWidth,
+		Gridlines:   gridlines,
+	}
+	if opts.Orientation != "" && opts.Orientation != SpreadsheetPortrait && opts.Orientation != SpreadsheetLandscape {
+		return nil, fmt.Errorf("%w: orientation must be portrait or landscape", ErrInvalidSpreadsheetOptions)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range sheets {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		opts.Sheets = append(opts.Sheets, name)
+	}
+	if len(opts.Sheets) > spreadsheetMaxSheets {
+		return nil, fmt.Errorf("%w: at most %d sheets can be selected", ErrInvalidSpreadsheetOptions, spreadsheetMaxSheets)
+	}
+
+	if opts.Orientation == "" && !opts.FitToWidth && !opts.Gridlines && len(opts.Sheets) == 0 {
+		return nil, nil
+	}
+	return opts, nil
+}
+
+// IsSpreadsheetFile reports whether name is an xls or xlsx workbook
+func IsSpreadsheetFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".xls" || ext == ".xlsx"
+}
+
+// prepareSpreadsheet returns a copy of the workbook at inputPath with opts
+// applied, ready for LibreOffice to export
+func (s *ConversionService) prepareSpreadsheet(scratch *Scratch, inputPath string, opts *SpreadsheetOptions) (string, error) {
+	if strings.ToLower(filepath.Ext(inputPath)) == ".xls" {
+		// Legacy workbooks are binary; LibreOffice rewrites them as xlsx first
+		xlsx, err := s.runSoffice(scratch, inputPath, "xlsx:Calc MS Excel 2007 XML", "xlsx")
+		if err != nil {
+			return "", err
+		}
+		inputPath = xlsx
+	}
+	return applySpreadsheetOptions(scratch, inputPath, opts)
+}
+
+// applySpreadsheetOptions writes the xlsx at inputPath into scratch with
+// the selected sheets visible and the page setup of every worksheet set
+func applySpreadsheetOptions(scratch *Scratch, inputPath string, opts *SpreadsheetOptions) (string, error) {
+	zr, err := zip.OpenReader(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read workbook: %v", err)
+	}
+	defer zr.Close()
+
+	workbook := workbookPart(&zr.Reader)
+	worksheets := path.Join(path.Dir(workbook), "worksheets")
+	printSetup := opts.Orientation != "" || opts.FitToWidth || opts.Gridlines
+
+	outputPath := scratch.Path("workbook.xlsx")
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	zw := zip.NewWriter(scratch.Writer(out))
+
+	foundWorkbook := false
+	for _, f := range zr.File {
+		var patch func([]byte, *SpreadsheetOptions) ([]byte, error)
+		switch {
+		case f.Name == workbook:
+			foundWorkbook = true
+			if len(opts.Sheets) > 0 {
+				patch = selectSheets
+			}
+		case printSetup && path.Dir(f.Name) == worksheets && path.Ext(f.Name) == ".xml":
+			patch = setPrintSetup
+		}
+		if patch == nil {
+			if err := zw.Copy(f); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		data, err := readWorkbookPart(f)
+		if err != nil {
+			return "", err
+		}
+		if data, err = patch(data, opts); err != nil {
+			return "", err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(data); err != nil {
+			return "", err
+		}
+	}
+	if !foundWorkbook {
+		return "", errors.New("failed to read workbook: not an xlsx file")
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// workbookPart finds the workbook in the package relationships
+func workbookPart(zr *zip.Reader) string {
+	for _, f := range zr.File {
+		if f.Name != "_rels/.rels" {
+			continue
+		}
+		data, err := readWorkbookPart(f)
+		if err != nil {
+			break
+		}
+		var rels struct {
+			Relationships []struct {
+				Type   string `xml:"Type,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if xml.Unmarshal(data, &rels) != nil {
+			break
+		}
+		for _, r := range rels.Relationships {
+			if strings.HasSuffix(r.Type, "/officeDocument") {
+				return strings.TrimPrefix(path.Clean("/"+r.Target), "/")
+			}
+		}
+	}
+	return "xl/workbook.xml"
+}
+
+func readWorkbookPart(f *zip.File) ([]byte, error) {
+	tooLarge := fmt.Errorf("workbook part %s is larger than %dMB", f.Name, spreadsheetPartLimit>>20)
+	if f.UncompressedSize64 > spreadsheetPartLimit {
+		return nil, tooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, spreadsheetPartLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > spreadsheetPartLimit {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// selectSheets hides every sheet of a workbook.xml that isn't in
+// opts.Sheets, matching names ignoring case, and makes the first selected
+// sheet the active one
+func selectSheets(doc []byte, opts *SpreadsheetOptions) ([]byte, error) {
+	elems, err := scanXML(doc, "sheets", "bookViews")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workbook: %v", err)
+	}
+	if len(elems) == 0 || elems[0].name.Local != "workbook" {
+		return nil, errors.New("failed to read workbook: no workbook element")
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range opts.Sheets {
+		wanted[strings.ToLower(name)] = true
+	}
+	var edits []xmlEdit
+	var names []string
+	active := -1
+	sheets := findChild(elems, 0, "sheets")
+	for i, e := range elems {
+		if sheets < 0 || e.parent != sheets || e.name.Local != "sheet" {
+			continue
+		}
+		name := xmlAttr(e, "name")
+		state := "hidden"
+		if wanted[strings.ToLower(name)] {
+			delete(wanted, strings.ToLower(name))
+			state = "visible"
+			if active < 0 {
+				active = len(names)
+			}
+		}
+		names = append(names, name)
+		edits = append(edits, setXMLAttrs(doc, elems[i], "state", state))
+	}
+	for _, name := range opts.Sheets {
+		if wanted[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%w: sheet %q not found; the workbook has %q", ErrInvalidSpreadsheetOptions, name, names)
+		}
+	}
+
+	if views := findChild(elems, 0, "bookViews"); views >= 0 {
+		if view := findChild(elems, views, "workbookView"); view >= 0 {
+			tab := fmt.Sprint(active)
+			if xmlAttr(elems[view], "firstSheet") != "" {
+				edits = append(edits, setXMLAttrs(doc, elems[view], "activeTab", tab, "firstSheet", tab))
+			} else {
+				edits = append(edits, setXMLAttrs(doc, elems[view], "activeTab", tab))
+			}
+		}
+	}
+	return applyXMLEdits(doc, edits), nil
+}
+
+// worksheetOrder is the order of a worksheet's children in the schema,
+// which Excel and LibreOffice both insist on
+var worksheetOrder = []string{
+	"sheetPr", "dimension", "sheetViews", "sheetFormatPr", "cols", "sheetData",
+	"sheetCalcPr", "sheetProtection", "protectedRanges", "scenarios", "autoFilter",
+	"sortState", "dataConsolidate", "customSheetViews", "mergeCells", "phoneticPr",
+	"conditionalFormatting", "dataValidations", "hyperlinks", "printOptions",
+	"pageMargins", "pageSetup", "headerFooter", "rowBreaks", "colBreaks",
+	"customProperties", "cellWatches", "ignoredErrors", "smartTags", "drawing",
+	"legacyDrawing", "legacyDrawingHF", "drawingHF", "picture", "oleObjects",
+	"controls", "webPublishItems", "tableParts", "extLst",
+}
+
+// setPrintSetup applies orientation, fit to width and gridlines to a
+// worksheet, adding the elements that hold them where they are missing
+func setPrintSetup(doc []byte, opts *SpreadsheetOptions) ([]byte, error) {
+	elems, err := scanXML(doc, "sheetPr")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worksheet: %v", err)
+	}
+	if len(elems) == 0 || elems[0].name.Local != "worksheet" {
+		return doc, nil // a chart or dialog sheet has nothing to lay out
+	}
+	p := ""
+	if elems[0].name.Space != "" {
+		p = elems[0].name.Space + ":"
+	}
+	var edits []xmlEdit
+
+	if opts.FitToWidth {
+		fitToPage := "<" + p + `pageSetUpPr fitToPage="1"/>`
+		switch sheetPr := findChild(elems, 0, "sheetPr"); {
+		case sheetPr < 0:
+			edits = append(edits, xmlEdit{pos: elems[0].tagEnd, end: elems[0].tagEnd, text: "<" + p + "sheetPr>" + fitToPage + "</" + p + "sheetPr>"})
+		case findChild(elems, sheetPr, "pageSetUpPr") >= 0:
+			edits = append(edits, setXMLAttrs(doc, elems[findChild(elems, sheetPr, "pageSetUpPr")], "fitToPage", "1"))
+		case elems[sheetPr].selfClosing(doc):
+			e := elems[sheetPr]
+			tag := strings.TrimRight(string(doc[e.start:e.tagEnd-2]), " \t\r\n")
+			edits = append(edits, xmlEdit{pos: e.start, end: e.tagEnd, text: tag + ">" + fitToPage + "</" + p + "sheetPr>"})
+		default:
+			// pageSetUpPr is the last child of sheetPr
+			end := elems[sheetPr].closeTag(doc)
+			edits = append(edits, xmlEdit{pos: end, end: end, text: fitToPage})
+		}
+	}
+
+	if opts.Gridlines {
+		edits = append(edits, setWorksheetChild(doc, elems, p, "printOptions", "gridLines", "1"))
+	}
+
+	var pageSetup []string
+	if opts.Orientation != "" {
+		pageSetup = append(pageSetup, "orientation", opts.Orientation)
+	}
+	if opts.FitToWidth {
+		// 0 pages high lets the sheet run over as many pages as it needs
+		pageSetup = append(pageSetup, "fitToWidth", "1", "fitToHeight", "0")
+	}
+	if len(pageSetup) > 0 {
+		edits = append(edits, setWorksheetChild(doc, elems, p, "pageSetup", pageSetup...))
+	}
+	return applyXMLEdits(doc, edits), nil
+}
+
+// setWorksheetChild sets attributes of a worksheet's name element, adding
+// the element in its place in the schema order when the sheet has none
+func setWorksheetChild(doc []byte, elems []xmlElem, prefix, name string, attrs ...string) xmlEdit {
+	if i := findChild(elems, 0, name); i >= 0 {
+		return setXMLAttrs(doc, elems[i], attrs...)
+	}
+
+	rank := func(local string) int {
+		for i, n := range worksheetOrder {
+			if n == local {
+				return i
+			}
+		}
+		return -1
+	}
+	pos := elems[0].closeTag(doc)
+	for _, e := range elems {
+		if e.parent == 0 && rank(e.name.Local) > rank(name) {
+			pos = e.start
+			break
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + prefix + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, ` %s="%s"`, attrs[i], attrs[i+1])
+	}
+	b.WriteString("/>")
+	return xmlEdit{pos: pos, end: pos, text: b.String()}
+}
+
+// xmlElem is an element found by scanXML, with byte offsets into the
+// document so it can be edited in place without reserializing the rest
+type xmlElem struct {
+	name   xml.Name // Space holds the prefix as written
+	attr   []xml.Attr
+	parent int // index of the parent element, -1 for the root
+	start  int // offset of the start tag
+	tagEnd int // offset just past the start tag
+	end    int // offset just past the end tag
+}
+
+func (e xmlElem) selfClosing(doc []byte) bool {
+	return e.tagEnd == e.end && e.tagEnd >= 2 && doc[e.tagEnd-2] == '/'
+}
+
+// closeTag returns the offset of the element's end tag
+func (e xmlElem) closeTag(doc []byte) int {
+	if e.selfClosing(doc) {
+		return e.end
+	}
+	return bytes.LastIndex(doc[:e.end], []byte("</"))
+}
+
+// scanXML lists the root of doc, its children and the children of those
+// named in expand, in document order
+func scanXML(doc []byte, expand ...string) ([]xmlElem, error) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	var elems []xmlElem
+	var open []int // index of each open element, -1 when too deep to list
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			idx := -1
+			if len(open) < 2 || len(open) == 2 && open[1] >= 0 && containsString(expand, elems[open[1]].name.Local) {
+				parent := -1
+				if len(open) > 0 {
+					parent = open[len(open)-1]
+				}
+				t = t.Copy()
+				elems = append(elems, xmlElem{name: t.Name, attr: t.Attr, parent: parent, start: start, tagEnd: int(d.InputOffset())})
+				idx = len(elems) - 1
+			}
+			open = append(open, idx)
+		case xml.EndElement:
+			if len(open) == 0 {
+				return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+			}
+			if idx := open[len(open)-1]; idx >= 0 {
+				elems[idx].end = int(d.InputOffset())
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return nil, errors.New("unexpected end of document")
+	}
+	return elems, nil
+}
+
+func findChild(elems []xmlElem, parent int, local string) int {
+	for i, e := range elems {
+		if e.parent == parent && e.name.Local == local {
+			return i
+		}
+	}
+	return -1
+}
+
+func xmlAttr(e xmlElem, local string) string {
+	for _, a := range e.attr {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// xmlEdit replaces doc[pos:end] with text
+type xmlEdit struct {
+	pos, end int
+	text     string
+}
+
+// setXMLAttrs rewrites the start tag of e with the attributes given as
+// name, value pairs set
+func setXMLAttrs(doc []byte, e xmlElem, attrs ...string) xmlEdit {
+	tag := string(doc[e.start:e.tagEnd])
+	for i := 0; i+1 < len(attrs); i += 2 {
+		attr := fmt.Sprintf(` %s="%s"`, attrs[i], attrs[i+1])
+		re := regexp.MustCompile(`\s` + regexp.QuoteMeta(attrs[i]) + `\s*=\s*("[^"]*"|'[^']*')`)
+		if loc := re.FindStringIndex(tag); loc != nil {
+			tag = tag[:loc[0]] + attr + tag[loc[1]:]
+			continue
+		}
+		at := len(tag) - 1
+		if strings.HasSuffix(tag, "/>") {
+			at--
+		}
+		tag = tag[:at] + attr + tag[at:]
+	}
+	return xmlEdit{pos: e.start, end: e.tagEnd, text: tag}
+}
+
+// applyXMLEdits applies non-overlapping edits; insertions at the same
+// offset keep the order they were given in
+func applyXMLEdits(doc []byte, edits []xmlEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].pos < edits[j].pos })
+	var out bytes.Buffer
+	out.Grow(len(doc))
+	last := 0
+	for _, e := range edits {
+		out.Write(doc[last:e.pos])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.Write(doc[last:])
+	return out.Bytes()
+}
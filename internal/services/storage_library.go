@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyLibraryCollection held library documents, keyed by Firebase UID,
+// before they moved into documents. Items are copied over with the same ID
+// and marked migratedAt; unmigrated items are still counted and are moved
+// on first access, so instances running older code during a rollout don't
+// lose uploads.
+const legacyLibraryCollection = "library"
+
+// notMigrated matches legacy library items not yet moved into documents
+var notMigrated = bson.M{"$exists": false}
+
+// legacyLibraryItem is a document in the legacy library collection
+type legacyLibraryItem struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	UserID      string             `bson:"userId"`
+	FileName    string             `bson:"fileName"`
+	FileKey     string             `bson:"fileKey"`
+	Size        int64              `bson:"size"`
+	PageCount   int                `bson:"pageCount"`
+	MimeType    string             `bson:"mimeType"`
+	Tags        []string           `bson:"tags,omitempty"`
+	ContentHash string             `bson:"contentHash,omitempty"`
+	LegalHold   *models.LegalHold  `bson:"legalHold,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt"`
+}
+
+// LibraryQuery selects and orders a user's library documents
+type LibraryQuery struct {
+	Search    string               // matches file names
+	AlsoIDs   []primitive.ObjectID // documents matching the search some other way
+	OnlyIDs   []primitive.ObjectID // when non-nil, only these documents
+	Tags      []string             // documents carrying all of these tags
+	SortField string               // createdAt, name, size, pages
+	Ascending bool
+}
+
+// LibraryFilter matches the documents kept in userID's library
+func LibraryFilter(userID string) bson.M {
+	return bson.M{"ownerUid": userID, "isTemporary": false}
+}
+
+// AddToLibrary stores a PDF in userID's library, in their organization's
+// bucket when it has one. The caller checks the storage limit.
+func (s *StorageService) AddToLibrary(ctx context.Context, userID, fileName string, in PDFReader, pageCount int, contentHash string) (*models.Document, error) {
+	client, storageID, err := s.router.ForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket := client.GetBucketUserFiles()
+	fileName = NormalizeFilename(fileName)
+	uniqueFilename := minioPkg.GenerateUniqueFilename(fileName)
+	objectKey := fmt.Sprintf("%s/library/%s", userID, uniqueFilename)
+
+	if _, err := client.UploadFile(ctx, bucket, objectKey, readSeeker(in), in.Size(), "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	now := time.Now()
+	doc := models.Document{
+		ID:           primitive.NewObjectID(),
+		OwnerUID:     userID,
+		Filename:     uniqueFilename,
+		OriginalName: fileName,
+		MimeType:     "application/pdf",
+		Size:         in.Size(),
+		StorageID:    storageID,
+		Source:       models.DocumentSourceLibrary,
+		Metadata:     models.DocumentMetadata{PageCount: pageCount},
+		ContentHash:  contentHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	doc.SetLocation(bucket, objectKey)
+
+	if _, err := s.mongoClient.Documents().InsertOne(ctx, doc); err != nil {
+		client.DeleteFile(context.Background(), bucket, objectKey)
+		return nil, fmt.Errorf("failed to save file metadata: %w", err)
+	}
+	s.afterUpload(doc)
+	if err := s.userService.UpdateStorageUsed(context.Background(), userID, doc.Size); err != nil {
+		fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
+	}
+	return &doc, nil
+}
+
+// LibraryDocument returns a document in userID's library, moving it over
+// from the legacy library collection if needed
+func (s *StorageService) LibraryDocument(ctx context.Context, id, userID string) (*models.Document, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+
+	filter := LibraryFilter(userID)
+	filter["_id"] = objID
+	var doc models.Document
+	err = s.mongoClient.Documents().FindOne(ctx, filter).Decode(&doc)
+	if err == nil {
+		return &doc, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+
+	var item legacyLibraryItem
+	err = s.mongoClient.Collection(legacyLibraryCollection).FindOne(ctx, bson.M{
+		"_id":        objID,
+		"userId":     userID,
+		"migratedAt": notMigrated,
+	}).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+	return s.migrateLibraryItem(ctx, item)
+}
+
+// ListLibrary returns the documents in userID's library
+func (s *StorageService) ListLibrary(ctx context.Context, userID string, q LibraryQuery) ([]models.Document, error) {
+	if _, err := s.migrateLegacyLibrary(ctx, bson.M{"userId": userID}); err != nil {
+		return nil, err
+	}
+
+	filter := LibraryFilter(userID)
+	if q.Search != "" {
+		filter["$or"] = bson.A{
+			bson.M{"originalName": bson.M{"$regex": regexp.QuoteMeta(q.Search), "$options": "i"}},
+			bson.M{"_id": bson.M{"$in": q.AlsoIDs}},
+		}
+	}
+	if q.OnlyIDs != nil {
+		filter["_id"] = bson.M{"$in": q.OnlyIDs}
+	}
+	if len(q.Tags) > 0 {
+		filter["metadata.tags"] = bson.M{"$all": q.Tags}
+	}
+	sortField := "createdAt"
+	switch q.SortField {
+	case "name":
+		sortField = "originalName"
+	case "size":
+		sortField = "size"
+	case "pages":
+		sortField = "metadata.pageCount"
+	}
+	direction := -1
+	if q.Ascending {
+		direction = 1
+	}
+
+	cursor, err := s.mongoClient.Documents().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: sortField, Value: direction}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list library: %w", err)
+	}
+	docs := []models.Document{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode library: %w", err)
+	}
+	return docs, nil
+}
+
+// SetLibraryTags replaces a library document's tags, recording its content
+// hash when given
+func (s *StorageService) SetLibraryTags(ctx context.Context, doc *models.Document, tags []string, contentHash string) error {
+	set := bson.M{"metadata.tags": tags, "updatedAt": time.Now()}
+	if contentHash != "" {
+		set["contentHash"] = contentHash
+	}
+	res, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{"_id": doc.ID, "ownerUid": doc.OwnerUID}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrFileNotFound
+	}
+	doc.Metadata.Tags = tags
+	if contentHash != "" {
+		doc.ContentHash = contentHash
+	}
+	return nil
+}
+
+// ReadDocument downloads a document's contents. It fails with
+// ErrFileArchived for archived documents.
+func (s *StorageService) ReadDocument(ctx context.Context, doc *models.Document) ([]byte, error) {
+	if doc.Archive != nil {
+		return nil, ErrFileArchived
+	}
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket, objectKey := doc.Location()
+	return client.DownloadFile(ctx, bucket, objectKey)
+}
+
+// DocumentURL returns a presigned download URL for a document. It fails
+// with minio.ErrEncryptedObject when files are encrypted at rest, and with
+// ErrFileArchived for archived documents.
+func (s *StorageService) DocumentURL(ctx context.Context, doc *models.Document, expires time.Duration) (string, error) {
+	if doc.Archive != nil {
+		return "", ErrFileArchived
+	}
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket, objectKey := doc.Location()
+	return client.GetPresignedURL(ctx, bucket, objectKey, expires)
+}
+
+// MigrateLibrary moves every legacy library item into documents and gives
+// documents stored before OwnerUID existed an owner. Safe to run on several
+// instances at once.
+func (s *StorageService) MigrateLibrary(ctx context.Context) (int, error) {
+	moved, err := s.migrateLegacyLibrary(ctx, bson.M{})
+	if err != nil {
+		return moved, err
+	}
+
+	cursor, err := s.mongoClient.Documents().Find(ctx,
+		bson.M{"ownerUid": bson.M{"$exists": false}, "isTemporary": false},
+		options.Find().SetProjection(bson.M{"bucket": 1, "objectKey": 1, "minioPath": 1, "isTemporary": 1}))
+	if err != nil {
+		return moved, fmt.Errorf("failed to find documents without owner: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc models.Document
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		if owner := DocumentOwner(&doc); owner != "" {
+			s.mongoClient.Documents().UpdateOne(ctx,
+				bson.M{"_id": doc.ID, "ownerUid": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"ownerUid": owner}})
+		}
+	}
+	return moved, cursor.Err()
+}
+
+// migrateLegacyLibrary moves the unmigrated legacy library items matching
+// filter into documents
+func (s *StorageService) migrateLegacyLibrary(ctx context.Context, filter bson.M) (int, error) {
+	filter["migratedAt"] = notMigrated
+	cursor, err := s.mongoClient.Collection(legacyLibraryCollection).Find(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find library items: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var moved int
+	for cursor.Next(ctx) {
+		var item legacyLibraryItem
+		if err := cursor.Decode(&item); err != nil {
+			continue
+		}
+		if _, err := s.migrateLibraryItem(ctx, item); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, cursor.Err()
+}
+
+// migrateLibraryItem copies a legacy library item into documents under the
+// same ID, so shares, notes and holds referring to it keep working. Legacy
+// items are stored in the platform's user files bucket.
+func (s *StorageService) migrateLibraryItem(ctx context.Context, item legacyLibraryItem) (*models.Document, error) {
+	mimeType := item.MimeType
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+	doc := models.Document{
+		ID:           item.ID,
+		OwnerUID:     item.UserID,
+		Filename:     path.Base(item.FileKey),
+		OriginalName: NormalizeFilename(item.FileName),
+		MimeType:     mimeType,
+		Size:         item.Size,
+		Source:       models.DocumentSourceLibrary,
+		Metadata:     models.DocumentMetadata{PageCount: item.PageCount, Tags: item.Tags},
+		ContentHash:  item.ContentHash,
+		LegalHold:    item.LegalHold,
+		CreatedAt:    item.CreatedAt,
+		UpdatedAt:    item.UpdatedAt,
+	}
+	doc.SetLocation(s.minioClient.GetBucketUserFiles(), item.FileKey)
+
+	_, err := s.mongoClient.Documents().InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another instance moved it first
+		err = s.mongoClient.Documents().FindOne(ctx, bson.M{"_id": item.ID}).Decode(&doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to move library item %s: %w", item.ID.Hex(), err)
+	}
+	if _, err := s.mongoClient.Collection(legacyLibraryCollection).UpdateOne(ctx,
+		bson.M{"_id": item.ID},
+		bson.M{"$set": bson.M{"migratedAt": time.Now()}},
+	); err != nil {
+		return nil, fmt.Errorf("failed to mark library item %s moved: %w", item.ID.Hex(), err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"github.com/minio/minio-go/v7"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by StorageMigrationService
+var (
+	ErrMigrationNotFound   = errors.New("storage migration not found")
+	ErrMigrationInvalid    = errors.New("invalid storage migration")
+	ErrMigrationInProgress = errors.New("another storage migration is in progress")
+	ErrMigrationState      = errors.New("storage migration can't do that in its current state")
+	ErrMaintenanceRequired = errors.New("maintenance mode must be on")
+)
+
+// errMigrationStopped ends a run without failing the migration: it was
+// paused, the lease moved to another instance, or maintenance was lifted
+var errMigrationStopped = errors.New("storage migration stopped")
+
+const (
+	storageMigrationLease    = "storage-migration"
+	storageMigrationInterval = 30 * time.Second
+	storageMigrationBatch    = 100
+)
+
+// StorageMigrationService moves one of the platform's buckets to another
+// bucket or endpoint without downtime for reads. Objects are copied as
+// stored and verified by SHA-256 while the old bucket keeps serving; at
+// cutover, under maintenance mode, objects changed since are copied again
+// and document paths are rewritten. Progress is saved after each object,
+// so migrations resume after pauses, errors and restarts.
+type StorageMigrationService struct {
+	mongoClient *mongodb.Client
+	platform    *minioPkg.Client
+	maintenance *MaintenanceService
+}
+
+// NewStorageMigrationService creates a storage migration service
+func NewStorageMigrationService(mongoClient *mongodb.Client, platform *minioPkg.Client, maintenance *MaintenanceService) *StorageMigrationService {
+	return &StorageMigrationService{
+		mongoClient: mongoClient,
+		platform:    platform,
+		maintenance: maintenance,
+	}
+}
+
+func (s *StorageMigrationService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("storage_migrations")
+}
+
+// Start records a migration of sourceBucket to target after checking the
+// target bucket can be used. Copying begins on the next run of the job.
+func (s *StorageMigrationService) Start(ctx context.Context, sourceBucket string, target models.StorageMigrationTarget, by string) (*models.StorageMigration, error) {
+	if sourceBucket != s.platform.GetBucketTemp() && sourceBucket != s.platform.GetBucketUserFiles() {
+		return nil, fmt.Errorf("%w: source must be the %s or %s bucket", ErrMigrationInvalid, s.platform.GetBucketUserFiles(), s.platform.GetBucketTemp())
+	}
+	if target.Endpoint == "" || target.Bucket == "" || target.AccessKey == "" || target.SecretKey == "" {
+		return nil, fmt.Errorf("%w: target endpoint, bucket, accessKey and secretKey are required", ErrMigrationInvalid)
+	}
+	if target.Endpoint == s.platform.Endpoint() && target.Bucket == sourceBucket {
+		return nil, fmt.Errorf("%w: target is the source bucket", ErrMigrationInvalid)
+	}
+	client, err := migrationTargetClient(target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMigrationInvalid, err)
+	}
+	if err := client.CheckAccess(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMigrationInvalid, err)
+	}
+
+	active, err := s.collection().CountDocuments(ctx, bson.M{"status": bson.M{"$in": bson.A{
+		models.StorageMigrationCopying, models.StorageMigrationCopied, models.StorageMigrationCutover,
+	}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if active > 0 {
+		return nil, ErrMigrationInProgress
+	}
+
+	now := time.Now()
+	m := &models.StorageMigration{
+		ID:           primitive.NewObjectID(),
+		SourceBucket: sourceBucket,
+		Target:       target,
+		Status:       models.StorageMigrationCopying,
+		StartedBy:    by,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if _, err := s.collection().InsertOne(ctx, m); err != nil {
+		return nil, fmt.Errorf("failed to save migration: %w", err)
+	}
+	return m, nil
+}
+
+// Get returns a migration by ID
+func (s *StorageMigrationService) Get(ctx context.Context, id string) (*models.StorageMigration, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrMigrationNotFound
+	}
+	var m models.StorageMigration
+	err = s.collection().FindOne(ctx, bson.M{"_id": objID}).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMigrationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migration: %w", err)
+	}
+	return &m, nil
+}
+
+// List returns all migrations, newest first
+func (s *StorageMigrationService) List(ctx context.Context) ([]models.StorageMigration, error) {
+	cursor, err := s.collection().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	migrations := []models.StorageMigration{}
+	if err := cursor.All(ctx, &migrations); err != nil {
+		return nil, fmt.Errorf("failed to decode migrations: %w", err)
+	}
+	return migrations, nil
+}
+
+// Pause stops a running migration after the object in progress
+func (s *StorageMigrationService) Pause(ctx context.Context, id string) (*models.StorageMigration, error) {
+	return s.transition(ctx, id,
+		bson.M{"status": bson.M{"$in": bson.A{models.StorageMigrationCopying, models.StorageMigrationCutover}}},
+		bson.M{"paused": true})
+}
+
+// Resume continues a paused migration from where it stopped
+func (s *StorageMigrationService) Resume(ctx context.Context, id string) (*models.StorageMigration, error) {
+	return s.transition(ctx, id,
+		bson.M{"status": bson.M{"$in": bson.A{models.StorageMigrationCopying, models.StorageMigrationCutover}}},
+		bson.M{"paused": false, "error": ""})
+}
+
+// Cutover switches a copied migration over to the target. It requires
+// maintenance mode, so nothing is written to the source meanwhile.
+func (s *StorageMigrationService) Cutover(ctx context.Context, id string) (*models.StorageMigration, error) {
+	if !s.maintenance.Active() {
+		return nil, ErrMaintenanceRequired
+	}
+	return s.transition(ctx, id,
+		bson.M{"status": models.StorageMigrationCopied},
+		bson.M{"status": models.StorageMigrationCutover, "paused": false, "error": ""})
+}
+
+// Cancel abandons a migration before cutover. Objects already copied are
+// left in the target bucket.
+func (s *StorageMigrationService) Cancel(ctx context.Context, id string) (*models.StorageMigration, error) {
+	return s.transition(ctx, id,
+		bson.M{"status": bson.M{"$in": bson.A{models.StorageMigrationCopying, models.StorageMigrationCopied}}},
+		bson.M{"status": models.StorageMigrationCancelled})
+}
+
+func (s *StorageMigrationService) transition(ctx context.Context, id string, filter, set bson.M) (*models.StorageMigration, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrMigrationNotFound
+	}
+	filter["_id"] = objID
+	set["updatedAt"] = time.Now()
+
+	var m models.StorageMigration
+	err = s.collection().FindOneAndUpdate(ctx, filter, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrMigrationState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update migration: %w", err)
+	}
+	return &m, nil
+}
+
+// Run works on the active migration from whichever instance holds the
+// migration lease. Blocks until ctx is cancelled.
+func (s *StorageMigrationService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, storageMigrationLease, storageMigrationInterval, func(ctx context.Context) {
+		s.step(ctx, leases)
+	})
+}
+
+func (s *StorageMigrationService) step(ctx context.Context, leases *LeaseService) {
+	var m models.StorageMigration
+	err := s.collection().FindOne(ctx, bson.M{
+		"status": bson.M{"$in": bson.A{models.StorageMigrationCopying, models.StorageMigrationCutover}},
+		"paused": false,
+	}).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return
+	}
+	if err != nil {
+		log.Printf("[StorageMigration] Failed to load migration: %v", err)
+		return
+	}
+	if m.Status == models.StorageMigrationCutover && !s.maintenance.Active() {
+		log.Printf("[StorageMigration] Cutover of %s waiting for maintenance mode", m.ID.Hex())
+		return
+	}
+
+	target, err := migrationTargetClient(m.Target)
+	if err == nil {
+		if m.Status == models.StorageMigrationCopying {
+			err = s.copyAll(ctx, leases, &m, target)
+		} else {
+			err = s.cutover(ctx, leases, &m, target)
+		}
+	}
+	if err == nil || errors.Is(err, errMigrationStopped) || ctx.Err() != nil {
+		return
+	}
+
+	log.Printf("[StorageMigration] Pausing %s: %v", m.ID.Hex(), err)
+	s.collection().UpdateOne(ctx, bson.M{"_id": m.ID}, bson.M{"$set": bson.M{
+		"paused":    true,
+		"error":     err.Error(),
+		"updatedAt": time.Now(),
+	}})
+}
+
+// copyAll copies every object after the last one copied
+func (s *StorageMigrationService) copyAll(ctx context.Context, leases *LeaseService, m *models.StorageMigration, target *minioPkg.Client) error {
+	log.Printf("[StorageMigration] Copying %s to %s/%s from %q", m.SourceBucket, m.Target.Endpoint, m.Target.Bucket, m.LastKey)
+	for {
+		objects, err := s.platform.ListObjectsAfter(ctx, m.SourceBucket, m.LastKey, storageMigrationBatch)
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(objects) == 0 {
+			break
+		}
+		for _, obj := range objects {
+			if err := keepMigrationLease(ctx, leases); err != nil {
+				return err
+			}
+			size, err := s.copyObject(ctx, m, target, obj.Key)
+			if err != nil {
+				return err
+			}
+			if err := s.progress(ctx, m, bson.M{"lastKey": obj.Key}, bson.M{"copied": 1, "copiedBytes": size}); err != nil {
+				return err
+			}
+			m.LastKey = obj.Key
+		}
+	}
+
+	if err := s.progress(ctx, m, bson.M{"status": models.StorageMigrationCopied}, nil); err != nil {
+		return err
+	}
+	log.Printf("[StorageMigration] %s copied, ready for cutover", m.ID.Hex())
+	return nil
+}
+
+// cutover copies objects changed since they were copied, then points
+// documents in the source bucket at the target
+func (s *StorageMigrationService) cutover(ctx context.Context, leases *LeaseService, m *models.StorageMigration, target *minioPkg.Client) error {
+	log.Printf("[StorageMigration] Cutting over %s from %q", m.ID.Hex(), m.SyncKey)
+	for {
+		objects, err := s.platform.ListObjectsAfter(ctx, m.SourceBucket, m.SyncKey, storageMigrationBatch)
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(objects) == 0 {
+			break
+		}
+		for _, obj := range objects {
+			if err := keepMigrationLease(ctx, leases); err != nil {
+				return err
+			}
+			if !s.maintenance.Active() {
+				return errMigrationStopped
+			}
+			var inc bson.M
+			if migrationStale(ctx, target, m.Target.Bucket, obj) {
+				size, err := s.copyObject(ctx, m, target, obj.Key)
+				if err != nil {
+					return err
+				}
+				inc = bson.M{"resynced": 1, "copiedBytes": size}
+			}
+			if err := s.progress(ctx, m, bson.M{"syncKey": obj.Key}, inc); err != nil {
+				return err
+			}
+			m.SyncKey = obj.Key
+		}
+	}
+
+	// Documents in organizations' buckets are not affected
+	if m.Target.Bucket != m.SourceBucket {
+		filter := bson.M{
+			"storageId": bson.M{"$in": bson.A{"", nil}},
+			"$or": bson.A{
+				bson.M{"bucket": m.SourceBucket},
+				bson.M{"bucket": bson.M{"$exists": false}, "minioPath": bson.M{"$regex": "^" + regexp.QuoteMeta(m.SourceBucket+"/")}},
+			},
+		}
+		opts := options.Find().SetLimit(storageMigrationBatch).SetProjection(bson.M{"bucket": 1, "objectKey": 1, "minioPath": 1})
+		for {
+			cursor, err := s.mongoClient.Documents().Find(ctx, filter, opts)
+			if err != nil {
+				return fmt.Errorf("failed to find documents: %w", err)
+			}
+			var docs []models.Document
+			if err := cursor.All(ctx, &docs); err != nil {
+				return fmt.Errorf("failed to decode documents: %w", err)
+			}
+			if len(docs) == 0 {
+				break
+			}
+			for _, doc := range docs {
+				if err := keepMigrationLease(ctx, leases); err != nil {
+					return err
+				}
+				oldPath := doc.MinIOPath
+				_, objectKey := doc.Location()
+				doc.SetLocation(m.Target.Bucket, objectKey)
+				_, err := s.mongoClient.Documents().UpdateOne(ctx,
+					bson.M{"_id": doc.ID, "minioPath": oldPath},
+					bson.M{"$set": bson.M{"bucket": doc.Bucket, "objectKey": doc.ObjectKey, "minioPath": doc.MinIOPath}})
+				if err != nil {
+					return fmt.Errorf("failed to rewrite document %s: %w", doc.ID.Hex(), err)
+				}
+				if err := s.progress(ctx, m, nil, bson.M{"rewritten": 1}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	if err := s.progress(ctx, m, bson.M{"status": models.StorageMigrationCompleted, "completedAt": now}, nil); err != nil {
+		return err
+	}
+	log.Printf("[StorageMigration] %s completed", m.ID.Hex())
+	return nil
+}
+
+// progress saves a step of m. It fails with errMigrationStopped once the
+// migration was paused or left its phase.
+func (s *StorageMigrationService) progress(ctx context.Context, m *models.StorageMigration, set, inc bson.M) error {
+	if set == nil {
+		set = bson.M{}
+	}
+	set["updatedAt"] = time.Now()
+	update := bson.M{"$set": set}
+	if inc != nil {
+		update["$inc"] = inc
+	}
+
+	res, err := s.collection().UpdateOne(ctx, bson.M{"_id": m.ID, "status": m.Status, "paused": false}, update)
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return errMigrationStopped
+	}
+	return nil
+}
+
+// copyObject copies an object as stored, so encrypted files stay encrypted
+// with the same keys, and checks the copy against the source's SHA-256.
+// Objects deleted since they were listed are skipped.
+func (s *StorageMigrationService) copyObject(ctx context.Context, m *models.StorageMigration, target *minioPkg.Client, key string) (int64, error) {
+	obj, err := s.platform.GetObject(ctx, m.SourceBucket, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	sum := sha256.Sum256(data)
+
+	if _, err := target.UploadBytes(ctx, m.Target.Bucket, key, data, info.ContentType); err != nil {
+		return 0, fmt.Errorf("failed to copy %s: %w", key, err)
+	}
+	copied, err := target.GetObject(ctx, m.Target.Bucket, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify %s: %w", key, err)
+	}
+	stored, err := io.ReadAll(copied)
+	copied.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify %s: %w", key, err)
+	}
+	if sha256.Sum256(stored) != sum {
+		return 0, fmt.Errorf("copy of %s does not match the source", key)
+	}
+	return int64(len(data)), nil
+}
+
+// migrationStale reports whether the target's copy of obj is missing or
+// older than the source
+func migrationStale(ctx context.Context, target *minioPkg.Client, bucket string, obj minio.ObjectInfo) bool {
+	info, err := target.GetFileInfo(ctx, bucket, obj.Key)
+	return err != nil || info.Size != obj.Size || obj.LastModified.After(info.LastModified)
+}
+
+// keepMigrationLease renews the migration lease between objects, as a
+// run lasts far longer than the lease
+func keepMigrationLease(ctx context.Context, leases *LeaseService) error {
+	if ctx.Err() != nil {
+		return errMigrationStopped
+	}
+	acquired, err := leases.TryAcquire(ctx, storageMigrationLease, storageMigrationInterval+storageMigrationInterval/2)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return errMigrationStopped
+	}
+	return nil
+}
+
+// migrationTargetClient returns a client for the target bucket. It has no
+// encrypter, so objects are written exactly as read from the source.
+func migrationTargetClient(target models.StorageMigrationTarget) (*minioPkg.Client, error) {
+	return minioPkg.NewBucketClient(target.Endpoint, target.Region, target.AccessKey, target.SecretKey, target.UseSSL, target.Bucket, nil)
+}
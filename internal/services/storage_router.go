@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"github.com/minio/minio-go/v7"
+)
+
+// StorageRouter decides where files are stored: in the bucket of the
+// owner's organization when it brought its own storage, otherwise in the
+// platform's. Documents record the organization as their storage ID, so
+// they are read from the same place after the owner changes organization.
+//
+// Files in an organization's bucket are encrypted at rest with the
+// platform's keys, exactly like files in the platform's bucket, when
+// encryption is enabled. This is deliberate: the bucket's credentials
+// alone don't give access to documents, per-user keys can still be rotated
+// and destroyed, and the same files behave the same wherever they are
+// stored. The flip side is that the organization can only read its files
+// through the API, not straight from the bucket.
+type StorageRouter struct {
+	platform     *minioPkg.Client
+	orgs         *OrgService
+	allowPrivate bool // allow endpoints on loopback and private networks
+
+	mu      sync.Mutex
+	clients map[string]orgClient // by organization ID
+}
+
+// orgClient is a client for an organization's bucket, with the storage
+// configuration it was made from
+type orgClient struct {
+	client    *minioPkg.Client
+	updatedAt time.Time
+}
+
+// NewStorageRouter creates a storage router. Unless allowPrivate is set,
+// organizations can only use endpoints on public addresses, so neither the
+// bucket check nor their storage can be used to reach the internal
+// network. The address is checked when the endpoint is tested and again
+// on every connection, so re-pointing its DNS later doesn't get around it.
+func NewStorageRouter(platform *minioPkg.Client, orgs *OrgService, allowPrivate bool) *StorageRouter {
+	return &StorageRouter{
+		platform:     platform,
+		orgs:         orgs,
+		allowPrivate: allowPrivate,
+		clients:      make(map[string]orgClient),
+	}
+}
+
+// ForUser returns the storage for userID's files and its storage ID ("" for
+// the platform's). It fails rather than fall back to the platform when the
+// organization's bucket can't be used, so files never land outside it.
+func (r *StorageRouter) ForUser(ctx context.Context, userID string) (*minioPkg.Client, string, error) {
+	if userID == "" || r.orgs == nil {
+		return r.platform, "", nil
+	}
+	org, err := r.orgs.ForUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if org == nil || org.Storage == nil {
+		return r.platform, "", nil
+	}
+	client, err := r.clientFor(org)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, org.ID.Hex(), nil
+}
+
+// Client returns the storage with the given ID, as recorded on a document
+func (r *StorageRouter) Client(ctx context.Context, storageID string) (*minioPkg.Client, error) {
+	if storageID == "" {
+		return r.platform, nil
+	}
+	org, err := r.orgs.Get(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	if org.Storage == nil {
+		return nil, fmt.Errorf("organization %s no longer has its own storage", org.Name)
+	}
+	return r.clientFor(org)
+}
+
+// ForDocument returns the storage holding doc
+func (r *StorageRouter) ForDocument(ctx context.Context, doc *models.Document) (*minioPkg.Client, error) {
+	return r.Client(ctx, doc.StorageID)
+}
+
+// Test connects to a bucket configuration and checks that files can be
+// written, read and deleted
+func (r *StorageRouter) Test(ctx context.Context, storage models.OrgStorage) error {
+	if !r.allowPrivate {
+		if err := checkPublicEndpoint(ctx, storage.Endpoint); err != nil {
+			return err
+		}
+	}
+	client, err := r.newOrgStorageClient(storage)
+	if err != nil {
+		return err
+	}
+	return client.CheckAccess(ctx)
+}
+
+// clientFor returns a client for the organization's bucket, reusing it
+// until the configuration changes
+func (r *StorageRouter) clientFor(org *models.Organization) (*minioPkg.Client, error) {
+	id := org.ID.Hex()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.clients[id]; ok && c.updatedAt.Equal(org.Storage.UpdatedAt) {
+		return c.client, nil
+	}
+	client, err := r.newOrgStorageClient(*org.Storage)
+	if err != nil {
+		return nil, err
+	}
+	// Files in customer buckets are encrypted with the platform's keys (see
+	// StorageRouter)
+	if e := r.platform.Encrypter(); e != nil {
+		client.SetEncrypter(e)
+	}
+	r.clients[id] = orgClient{client: client, updatedAt: org.Storage.UpdatedAt}
+	return client, nil
+}
+
+// newOrgStorageClient connects to an organization's bucket, through a
+// transport that refuses private addresses unless they are allowed
+func (r *StorageRouter) newOrgStorageClient(storage models.OrgStorage) (*minioPkg.Client, error) {
+	var transport http.RoundTripper
+	if !r.allowPrivate {
+		t, err := minio.DefaultTransport(storage.UseSSL)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = nil // a proxy would dial internal hosts for us
+		t.DialContext = publicDialer(30*time.Second, errPrivateEndpoint).DialContext
+		transport = t
+	}
+	return minioPkg.NewBucketClient(storage.Endpoint, storage.Region, storage.AccessKey, storage.SecretKey, storage.UseSSL, storage.Bucket, transport)
+}
+
+// errPrivateEndpoint is returned when an organization's endpoint resolves
+// to a private address at connection time
+var errPrivateEndpoint = errors.New("storage endpoint is on a private network")
+
+// publicDialer returns a dialer that refuses, with blocked, connections to
+// private addresses. The check is made on the address actually dialed,
+// after DNS resolution, so hostnames can't re-resolve around it.
+func publicDialer(timeout time.Duration, blocked error) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+				return blocked
+			}
+			return nil
+		},
+	}
+}
+
+// checkPublicEndpoint refuses endpoints resolving to loopback, private or
+// link-local addresses
+func checkPublicEndpoint(ctx context.Context, endpoint string) error {
+	host := endpoint
+	if h, _, err := net.SplitHostPort(endpoint); err == nil {
+		host = h
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if privateIP(a.IP) {
+			return fmt.Errorf("endpoint %s is on a private network", host)
+		}
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// privateIP reports loopback, private, link-local (which includes cloud
+// metadata endpoints), multicast, unspecified and carrier-grade NAT
+// addresses
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
@@ -1,12 +1,17 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"brainy-pdf/internal/config"
 	"brainy-pdf/internal/models"
 	minioPkg "brainy-pdf/pkg/minio"
 	"brainy-pdf/pkg/mongodb"
@@ -19,17 +24,53 @@ import (
 // StorageService handles file storage operations
 type StorageService struct {
 	minioClient *minioPkg.Client
+	router      *StorageRouter // picks the platform's or an organization's bucket
 	mongoClient *mongodb.Client
 	pdfService  *PDFService
 	userService *UserService
 	tempTTL     time.Duration
+	hooks       []UploadHook
+	deleteHooks []DeleteHook
+}
+
+// UploadHook is told about every file stored through the service. It is
+// called inline once the document is saved, so slow work such as a virus
+// scan must carry on in the background.
+type UploadHook interface {
+	AfterUpload(doc models.Document)
+}
+
+// AddUploadHook registers a hook for files stored from now on. Call it
+// during startup, before requests are served.
+func (s *StorageService) AddUploadHook(hook UploadHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// DeleteHook is told about every file deleted through DeleteFile, after
+// its record is gone. Like UploadHook it is called inline.
+type DeleteHook interface {
+	AfterDelete(doc models.Document)
+}
+
+// AddDeleteHook registers a hook for files deleted from now on. Call it
+// during startup, before requests are served.
+func (s *StorageService) AddDeleteHook(hook DeleteHook) {
+	s.deleteHooks = append(s.deleteHooks, hook)
+}
+
+// afterUpload runs the upload hooks for a newly stored document
+func (s *StorageService) afterUpload(doc models.Document) {
+	for _, hook := range s.hooks {
+		hook.AfterUpload(doc)
+	}
 }
 
 // NewStorageService creates a new storage service
 // NewStorageService creates a new storage service
-func NewStorageService(minioClient *minioPkg.Client, mongoClient *mongodb.Client, pdfService *PDFService, userService *UserService, tempTTLHours int) *StorageService {
+func NewStorageService(minioClient *minioPkg.Client, router *StorageRouter, mongoClient *mongodb.Client, pdfService *PDFService, userService *UserService, tempTTLHours int) *StorageService {
 	return &StorageService{
 		minioClient: minioClient,
+		router:      router,
 		mongoClient: mongoClient,
 		pdfService:  pdfService,
 		userService: userService,
@@ -47,16 +88,20 @@ type UploadResult struct {
 	Metadata    models.DocumentMetadata `json:"metadata"`
 	IsTemporary bool                    `json:"isTemporary"`
 	ExpiresAt   *time.Time              `json:"expiresAt,omitempty"`
+	Retention   *models.Retention       `json:"retention,omitempty"`
 }
 
 // UploadFile uploads a file and creates a document record
 func (s *StorageService) UploadFile(ctx context.Context, userID, originalName, contentType string, reader io.Reader, size int64, isTemporary bool) (*UploadResult, error) {
+	originalName = NormalizeFilename(originalName)
+
 	// Generate unique filename
 	uniqueFilename := minioPkg.GenerateUniqueFilename(originalName)
 	
 	// Determine bucket and path
-	var bucket, objectPath string
+	var bucket, objectPath, storageID string
 	var expiresAt *time.Time
+	client := s.minioClient
 	
     if isTemporary || userID == "" {
 		bucket = s.minioClient.GetBucketTemp()
@@ -74,22 +119,32 @@ func (s *StorageService) UploadFile(ctx context.Context, userID, originalName, c
             return nil, fmt.Errorf("storage limit exceeded. Please upgrade your plan")
         }
 
-		bucket = s.minioClient.GetBucketUserFiles()
+		client, storageID, err = s.router.ForUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve storage: %w", err)
+		}
+		bucket = client.GetBucketUserFiles()
 		objectPath = fmt.Sprintf("%s/library/%s", userID, uniqueFilename)
 	}
 
 	// Upload to MinIO
-	if _, err := s.minioClient.UploadFile(ctx, bucket, objectPath, reader, size, contentType); err != nil {
+	if _, err := client.UploadFile(ctx, bucket, objectPath, reader, size, contentType); err != nil {
 		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
 
 	// Get PDF metadata if it's a PDF
 	var metadata models.DocumentMetadata
 	if contentType == "application/pdf" {
-		// Download the file to get metadata
-		data, err := s.minioClient.DownloadFile(ctx, bucket, objectPath)
-		if err == nil {
-			if pageCount, err := s.pdfService.GetPageCount(data); err == nil {
+		// Read uploads in place (multipart files and byte readers can be);
+		// other readers are consumed, so download the file to get metadata
+		var in PDFReader
+		if r, ok := reader.(io.ReaderAt); ok {
+			in = io.NewSectionReader(r, 0, size)
+		} else if data, err := client.DownloadFile(ctx, bucket, objectPath); err == nil {
+			in = bytes.NewReader(data)
+		}
+		if in != nil {
+			if pageCount, err := s.pdfService.GetPageCount(in); err == nil {
 				metadata.PageCount = pageCount
 			}
 		}
@@ -102,16 +157,18 @@ func (s *StorageService) UploadFile(ctx context.Context, userID, originalName, c
 		OriginalName: originalName,
 		MimeType:     contentType,
 		Size:         size,
-		MinIOPath:    fmt.Sprintf("%s/%s", bucket, objectPath),
+		StorageID:    storageID,
 		Metadata:     metadata,
 		IsTemporary:  isTemporary || userID == "",
 		ExpiresAt:    expiresAt,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
+	doc.SetLocation(bucket, objectPath)
 
 	// Set user ID if authenticated
 	if userID != "" {
+		doc.OwnerUID = userID
 		userObjID, err := primitive.ObjectIDFromHex(userID)
 		if err == nil {
 			doc.UserID = userObjID
@@ -121,12 +178,13 @@ func (s *StorageService) UploadFile(ctx context.Context, userID, originalName, c
 	_, err := s.mongoClient.Documents().InsertOne(ctx, doc)
 	if err != nil {
 		// Try to clean up the uploaded file
-		s.minioClient.DeleteFile(ctx, bucket, objectPath)
+		client.DeleteFile(ctx, bucket, objectPath)
 		return nil, fmt.Errorf("failed to create document record: %w", err)
 	}
+	s.afterUpload(doc)
 
     // Generate download URL
-	url, _ := s.minioClient.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
+	url, _ := client.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
 
     if userID != "" && !doc.IsTemporary {
         // Update storage usage
@@ -144,67 +202,115 @@ func (s *StorageService) UploadFile(ctx context.Context, userID, originalName, c
 		Metadata:    metadata,
 		IsTemporary: doc.IsTemporary,
 		ExpiresAt:   expiresAt,
+		Retention:   s.RetentionFor(ctx, &doc),
 	}, nil
 }
 
 // UploadProcessedFile uploads a processed file (result of PDF operation)
 func (s *StorageService) UploadProcessedFile(ctx context.Context, userID, originalName string, data []byte, sourceDocID string) (*UploadResult, error) {
-	// Determine if user is authenticated
-	isTemporary := userID == ""
-	
+	// Get page count
+	pageCount, _ := s.pdfService.GetPageCount(bytes.NewReader(data))
+
+	return s.uploadProcessed(ctx, userID, originalName, "application/pdf", bytes.NewReader(data), int64(len(data)), pageCount, nil)
+}
+
+// UploadProcessedMedia uploads a non-PDF output, such as the MP3 of a
+// read-aloud, with the same retention rules as processed PDFs
+func (s *StorageService) UploadProcessedMedia(ctx context.Context, userID, originalName, contentType string, data []byte) (*UploadResult, error) {
+	return s.uploadProcessed(ctx, userID, originalName, contentType, bytes.NewReader(data), int64(len(data)), 0, nil)
+}
+
+// UploadProcessedFileFromPath streams a processed file from disk to storage
+// without loading it into memory (large-file mode)
+func (s *StorageService) UploadProcessedFileFromPath(ctx context.Context, userID, originalName, path string, progress ProgressFunc) (*UploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open processed file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat processed file: %w", err)
+	}
+
+	pageCount, _ := s.pdfService.GetPageCountFile(path)
+
+	return s.uploadProcessed(ctx, userID, originalName, "application/pdf", f, info.Size(), pageCount, progress)
+}
+
+// Outputs of anonymous requests live in the temp bucket for tempTTL. Signed-in
+// users' outputs are kept for their plan's RetentionDays, without counting
+// toward storage, until saved to the library; plans without a retention
+// period keep them permanently.
+func (s *StorageService) uploadProcessed(ctx context.Context, userID, originalName, contentType string, reader io.Reader, size int64, pageCount int, progress ProgressFunc) (*UploadResult, error) {
+	originalName = NormalizeFilename(originalName)
 	uniqueFilename := minioPkg.GenerateUniqueFilename(originalName)
 	
-	var bucket, objectPath string
+	var bucket, objectPath, storageID string
 	var expiresAt *time.Time
+	isTemporary := true
+	client := s.minioClient
+	if userID != "" {
+		var err error
+		if client, storageID, err = s.router.ForUser(ctx, userID); err != nil {
+			return nil, fmt.Errorf("failed to resolve storage: %w", err)
+		}
+	}
 	
-	if isTemporary {
+	if userID == "" {
 		bucket = s.minioClient.GetBucketTemp()
 		sessionID := uuid.New().String()
 		objectPath = fmt.Sprintf("%s/processed/%s", sessionID, uniqueFilename)
 		exp := time.Now().Add(s.tempTTL)
 		expiresAt = &exp
+	} else if days := config.GetPlanLimits(s.userPlan(ctx, userID)).RetentionDays; days > 0 {
+		bucket = client.GetBucketUserFiles()
+		objectPath = fmt.Sprintf("%s/processed/%s", userID, uniqueFilename)
+		exp := time.Now().AddDate(0, 0, days)
+		expiresAt = &exp
 	} else {
+		isTemporary = false
+
 		// Enforce storage limit
-		size := int64(len(data))
 		ok, err := s.userService.CheckStorageLimit(ctx, userID, size)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check storage limit: %w", err)
 		}
 		if !ok {
-			return nil, fmt.Errorf("storage limit exceeded")
+			return nil, ErrStorageLimitExceeded
 		}
 
-		bucket = s.minioClient.GetBucketUserFiles()
+		bucket = client.GetBucketUserFiles()
 		objectPath = fmt.Sprintf("%s/processed/%s", userID, uniqueFilename)
 	}
 
 	// Upload to MinIO
-	if _, err := s.minioClient.UploadBytes(ctx, bucket, objectPath, data, "application/pdf"); err != nil {
+	reader = NewProgressReader(reader, "upload", size, progress)
+	if _, err := client.UploadFile(ctx, bucket, objectPath, reader, size, contentType); err != nil {
 		return nil, fmt.Errorf("failed to upload processed file: %w", err)
 	}
 
-	// Get page count
-	var metadata models.DocumentMetadata
-	if pageCount, err := s.pdfService.GetPageCount(data); err == nil {
-		metadata.PageCount = pageCount
-	}
+	metadata := models.DocumentMetadata{PageCount: pageCount}
 
 	// Create document record
 	doc := models.Document{
 		ID:           primitive.NewObjectID(),
 		Filename:     uniqueFilename,
 		OriginalName: originalName,
-		MimeType:     "application/pdf",
-		Size:         int64(len(data)),
-		MinIOPath:    fmt.Sprintf("%s/%s", bucket, objectPath),
+		MimeType:     contentType,
+		Size:         size,
+		StorageID:    storageID,
 		Metadata:     metadata,
 		IsTemporary:  isTemporary,
 		ExpiresAt:    expiresAt,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
+	doc.SetLocation(bucket, objectPath)
 
 	if userID != "" {
+		doc.OwnerUID = userID
 		userObjID, err := primitive.ObjectIDFromHex(userID)
 		if err == nil {
 			doc.UserID = userObjID
@@ -213,14 +319,15 @@ func (s *StorageService) UploadProcessedFile(ctx context.Context, userID, origin
 
 	_, err := s.mongoClient.Documents().InsertOne(ctx, doc)
 	if err != nil {
-		s.minioClient.DeleteFile(ctx, bucket, objectPath)
+		client.DeleteFile(ctx, bucket, objectPath)
 		return nil, fmt.Errorf("failed to create document record: %w", err)
 	}
+	s.afterUpload(doc)
 
-	url, _ := s.minioClient.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
+	url, _ := client.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
 
     if !isTemporary {
-        if err := s.userService.UpdateStorageUsed(ctx, userID, int64(len(data))); err != nil {
+        if err := s.userService.UpdateStorageUsed(ctx, userID, size); err != nil {
               fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
         }
     }
@@ -228,12 +335,13 @@ func (s *StorageService) UploadProcessedFile(ctx context.Context, userID, origin
 	return &UploadResult{
 		FileID:      doc.ID.Hex(),
 		Filename:    uniqueFilename,
-		Size:        int64(len(data)),
-		ContentType: "application/pdf",
+		Size:        size,
+		ContentType: contentType,
 		URL:         url,
 		Metadata:    metadata,
 		IsTemporary: isTemporary,
 		ExpiresAt:   expiresAt,
+		Retention:   s.RetentionFor(ctx, &doc),
 	}, nil
 }
 
@@ -249,18 +357,260 @@ func (s *StorageService) GetFile(ctx context.Context, fileID string) (*models.Do
 	if err != nil {
 		return nil, nil, fmt.Errorf("file not found: %w", err)
 	}
+	if doc.Archive != nil {
+		return nil, nil, ErrFileArchived
+	}
 
 	// Parse MinIO path
-	bucket, objectPath := parseMinIOPath(doc.MinIOPath)
+	bucket, objectPath := doc.Location()
+	client, err := s.router.ForDocument(ctx, &doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve storage: %w", err)
+	}
 	
-	data, err := s.minioClient.DownloadFile(ctx, bucket, objectPath)
+	data, err := client.DownloadFile(ctx, bucket, objectPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to download file: %w", err)
 	}
+	s.MarkAccessed(ctx, &doc)
 
 	return &doc, data, nil
 }
 
+// Errors returned by GetFileForUser and SaveToLibrary
+var (
+	ErrFileNotFound         = errors.New("file not found")
+	ErrFileAccessDenied     = errors.New("file belongs to another user")
+	ErrStorageLimitExceeded = errors.New("storage limit exceeded")
+	ErrFileArchived         = errors.New("file is archived and must be restored first")
+)
+
+// userPlan returns the plan of userID, or "free" when it can't be found
+func (s *StorageService) userPlan(ctx context.Context, userID string) string {
+	if userID == "" || s.userService == nil {
+		return "free"
+	}
+	user, err := s.userService.GetUserByFirebaseUID(ctx, userID)
+	if err != nil || user.Plan == "" {
+		return "free"
+	}
+	return user.Plan
+}
+
+// RetentionFor labels doc with how long it is kept and how to keep it
+func (s *StorageService) RetentionFor(ctx context.Context, doc *models.Document) *models.Retention {
+	r := &models.Retention{Temporary: doc.IsTemporary, ExpiresAt: doc.ExpiresAt}
+	owner := DocumentOwner(doc)
+	if owner != "" {
+		r.Plan = s.userPlan(ctx, owner)
+		r.RetentionDays = config.GetPlanLimits(r.Plan).RetentionDays
+	}
+	if doc.IsTemporary {
+		r.SaveURL = "/api/v1/files/" + doc.ID.Hex() + "/save"
+		r.RequiresSignIn = owner == ""
+	}
+	return r
+}
+
+// SaveToLibrary keeps a temporary file for userID: it moves into their
+// library, loses its expiry and counts toward their storage. Anonymous
+// uploads are claimed by the user saving them, so a file processed before
+// signing in can still be kept. Saving a permanent file is a no-op.
+func (s *StorageService) SaveToLibrary(ctx context.Context, fileID, userID string) (*UploadResult, error) {
+	doc, err := s.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	if doc.ExpiresAt != nil && time.Now().After(*doc.ExpiresAt) {
+		return nil, ErrFileNotFound
+	}
+	if owner := DocumentOwner(doc); owner != "" && owner != userID {
+		return nil, ErrFileAccessDenied
+	}
+
+	if doc.IsTemporary {
+		ok, err := s.userService.CheckStorageLimit(ctx, userID, doc.Size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check storage limit: %w", err)
+		}
+		if !ok {
+			return nil, ErrStorageLimitExceeded
+		}
+
+		src, err := s.router.ForDocument(ctx, doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve storage: %w", err)
+		}
+		dst, storageID, err := s.router.ForUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve storage: %w", err)
+		}
+		bucket, objectPath := doc.Location()
+		if userBucket := dst.GetBucketUserFiles(); src != dst || bucket != userBucket {
+			dest := fmt.Sprintf("%s/library/%s", userID, doc.Filename)
+			if src == dst {
+				err = dst.MoveFile(ctx, bucket, objectPath, userBucket, dest)
+			} else {
+				err = moveBetween(ctx, src, bucket, objectPath, dst, dest)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to move file: %w", err)
+			}
+			doc.SetLocation(userBucket, dest)
+			doc.StorageID = storageID
+		}
+
+		doc.OwnerUID = userID
+		if userObjID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			doc.UserID = userObjID
+		}
+		doc.IsTemporary = false
+		doc.ExpiresAt = nil
+		doc.UpdatedAt = time.Now()
+
+		set := bson.M{
+			"ownerUid":    doc.OwnerUID,
+			"bucket":      doc.Bucket,
+			"objectKey":   doc.ObjectKey,
+			"minioPath":   doc.MinIOPath,
+			"storageId":   doc.StorageID,
+			"isTemporary": false,
+			"updatedAt":   doc.UpdatedAt,
+		}
+		if !doc.UserID.IsZero() {
+			set["userId"] = doc.UserID
+		}
+		// Matching isTemporary keeps concurrent saves from counting twice
+		res, err := s.mongoClient.Documents().UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "isTemporary": true},
+			bson.M{"$set": set, "$unset": bson.M{"expiresAt": ""}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update document: %w", err)
+		}
+		if res.ModifiedCount > 0 {
+			if err := s.userService.UpdateStorageUsed(ctx, userID, doc.Size); err != nil {
+				fmt.Printf("Failed to update storage usage for user %s: %v\n", userID, err)
+			}
+		}
+	}
+
+	var url string
+	if client, err := s.router.ForDocument(ctx, doc); err == nil {
+		bucket, objectPath := doc.Location()
+		url, _ = client.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
+	}
+
+	return &UploadResult{
+		FileID:      doc.ID.Hex(),
+		Filename:    doc.Filename,
+		Size:        doc.Size,
+		ContentType: doc.MimeType,
+		URL:         url,
+		Metadata:    doc.Metadata,
+		IsTemporary: doc.IsTemporary,
+		ExpiresAt:   doc.ExpiresAt,
+		Retention:   s.RetentionFor(ctx, doc),
+	}, nil
+}
+
+// DocumentOwner returns the Firebase UID owning doc, or "" for temporary
+// uploads. Documents stored before OwnerUID existed are attributed through
+// their object path, which starts with the owner's UID.
+func DocumentOwner(doc *models.Document) string {
+	if doc.OwnerUID != "" {
+		return doc.OwnerUID
+	}
+	if doc.IsTemporary {
+		return ""
+	}
+	_, objectPath := doc.Location()
+	if i := strings.Index(objectPath, "/"); i > 0 {
+		return objectPath[:i]
+	}
+	return ""
+}
+
+// GetFileForUser retrieves a stored file for an operation requested by
+// userID. Owned files are only returned to their owner; temporary uploads
+// are returned to anyone holding the ID, as their download URLs are.
+func (s *StorageService) GetFileForUser(ctx context.Context, fileID, userID string) (*models.Document, []byte, error) {
+	doc, err := s.fileForUser(ctx, fileID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket, objectPath := doc.Location()
+	data, err := client.DownloadFile(ctx, bucket, objectPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	s.MarkAccessed(ctx, doc)
+	return doc, data, nil
+}
+
+// OpenFileForUser is GetFileForUser streaming the file's contents instead
+// of loading them, for operations that spool large inputs to disk. Files
+// encrypted at rest are decrypted in memory. The caller must Close the
+// reader.
+func (s *StorageService) OpenFileForUser(ctx context.Context, fileID, userID string) (*models.Document, io.ReadCloser, int64, error) {
+	doc, err := s.fileForUser(ctx, fileID, userID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket, objectPath := doc.Location()
+	reader, size, err := client.OpenObject(ctx, bucket, objectPath)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	s.MarkAccessed(ctx, doc)
+	return doc, reader, size, nil
+}
+
+// fileForUser looks up a file userID may operate on, as GetFileForUser
+// describes
+func (s *StorageService) fileForUser(ctx context.Context, fileID, userID string) (*models.Document, error) {
+	doc, err := s.GetFileMetadata(ctx, fileID)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	if doc.ExpiresAt != nil && time.Now().After(*doc.ExpiresAt) {
+		return nil, ErrFileNotFound
+	}
+	if owner := DocumentOwner(doc); owner != "" && owner != userID {
+		return nil, ErrFileAccessDenied
+	}
+	if doc.Archive != nil {
+		return nil, ErrFileArchived
+	}
+	return doc, nil
+}
+
+// MarkAccessed records that the owner opened doc, at most once a day, so
+// documents in use are not archived
+func (s *StorageService) MarkAccessed(ctx context.Context, doc *models.Document) {
+	if doc.IsTemporary {
+		return
+	}
+	now := time.Now()
+	if doc.LastAccessedAt != nil && now.Sub(*doc.LastAccessedAt) < 24*time.Hour {
+		return
+	}
+	doc.LastAccessedAt = &now
+	if _, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"lastAccessedAt": now}}); err != nil {
+		fmt.Printf("Warning: failed to record access to %s: %v\n", doc.ID.Hex(), err)
+	}
+}
+
 // GetFileMetadata retrieves file metadata by ID
 func (s *StorageService) GetFileMetadata(ctx context.Context, fileID string) (*models.Document, error) {
 	objID, err := primitive.ObjectIDFromHex(fileID)
@@ -284,53 +634,56 @@ func (s *StorageService) DeleteFile(ctx context.Context, fileID, userID string)
 		return fmt.Errorf("invalid file ID: %w", err)
 	}
 
-	// Build filter
 	filter := bson.M{"_id": objID}
-	if userID != "" {
-		userObjID, err := primitive.ObjectIDFromHex(userID)
-		if err == nil {
-			filter["userId"] = userObjID
-		}
-	}
-
 	var doc models.Document
 	err = s.mongoClient.Documents().FindOne(ctx, filter).Decode(&doc)
 	if err != nil {
 		return fmt.Errorf("file not found or unauthorized: %w", err)
 	}
-
-	// Delete from MinIO
-	bucket, objectPath := parseMinIOPath(doc.MinIOPath)
-	if err := s.minioClient.DeleteFile(ctx, bucket, objectPath); err != nil {
-		// Log but continue
-		fmt.Printf("Warning: failed to delete from MinIO: %v\n", err)
+	if owner := DocumentOwner(&doc); owner != "" && owner != userID {
+		return ErrFileAccessDenied
+	}
+	if doc.LegalHold != nil {
+		return ErrLegalHold
 	}
 
-	// Delete from MongoDB
-	_, err = s.mongoClient.Documents().DeleteOne(ctx, filter)
+	// Delete from MongoDB first, so a hold placed meanwhile keeps the file
+	filter["legalHold"] = notHeld
+	res, err := s.mongoClient.Documents().DeleteOne(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("failed to delete document record: %w", err)
 	}
+	if res.DeletedCount == 0 {
+		return ErrLegalHold
+	}
 
-    // Update storage usage (decrement)
-    if userID != "" {
+	// Delete from MinIO
+	bucket, objectPath := doc.Location()
+	if client, err := s.router.ForDocument(ctx, &doc); err != nil {
+		fmt.Printf("Warning: failed to resolve storage of %s: %v\n", fileID, err)
+	} else if err := client.DeleteFile(ctx, bucket, objectPath); err != nil {
+		// Log but continue
+		fmt.Printf("Warning: failed to delete from MinIO: %v\n", err)
+	}
+
+    // Update storage usage (decrement); temporary files were never counted
+    if userID != "" && !doc.IsTemporary {
         s.userService.UpdateStorageUsed(ctx, userID, -doc.Size)
     }
+	for _, hook := range s.deleteHooks {
+		hook.AfterDelete(doc)
+	}
 
 	return nil
 }
 
 // ListUserFiles lists files in a user's library
 func (s *StorageService) ListUserFiles(ctx context.Context, userID string, folderID *string, page, limit int) ([]models.Document, int64, error) {
-	userObjID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return nil, 0, fmt.Errorf("invalid user ID: %w", err)
+	if _, err := s.migrateLegacyLibrary(ctx, bson.M{"userId": userID}); err != nil {
+		return nil, 0, err
 	}
 
-	filter := bson.M{
-		"userId":      userObjID,
-		"isTemporary": false,
-	}
+	filter := LibraryFilter(userID)
 
 	if folderID != nil && *folderID != "" {
 		folderObjID, err := primitive.ObjectIDFromHex(*folderID)
@@ -373,16 +726,25 @@ func (s *StorageService) GetDownloadURL(ctx context.Context, fileID string) (str
 	if err != nil {
 		return "", err
 	}
+	if doc.Archive != nil {
+		return "", ErrFileArchived
+	}
 
-	bucket, objectPath := parseMinIOPath(doc.MinIOPath)
-	return s.minioClient.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return "", err
+	}
+	bucket, objectPath := doc.Location()
+	return client.GetPresignedURL(ctx, bucket, objectPath, 1*time.Hour)
 }
 
-// CleanupExpiredFiles removes expired temporary files
+// CleanupExpiredFiles removes expired temporary files. Files under legal
+// hold are kept past their expiry until the hold is released.
 func (s *StorageService) CleanupExpiredFiles(ctx context.Context) (int, error) {
 	filter := bson.M{
 		"isTemporary": true,
 		"expiresAt":   bson.M{"$lt": time.Now()},
+		"legalHold":   notHeld,
 	}
 
 	cursor, err := s.mongoClient.Documents().Find(ctx, filter)
@@ -398,33 +760,76 @@ func (s *StorageService) CleanupExpiredFiles(ctx context.Context) (int, error) {
 			continue
 		}
 
-		// Delete from MinIO
-		bucket, objectPath := parseMinIOPath(doc.MinIOPath)
-		s.minioClient.DeleteFile(ctx, bucket, objectPath)
+		// Delete from MongoDB, unless held since it was found
+		res, err := s.mongoClient.Documents().DeleteOne(ctx, bson.M{"_id": doc.ID, "legalHold": notHeld})
+		if err != nil || res.DeletedCount == 0 {
+			continue
+		}
 
-		// Delete from MongoDB
-		s.mongoClient.Documents().DeleteOne(ctx, bson.M{"_id": doc.ID})
+		// Delete from MinIO
+		bucket, objectPath := doc.Location()
+		if client, err := s.router.ForDocument(ctx, &doc); err == nil {
+			client.DeleteFile(ctx, bucket, objectPath)
+		}
 		deleted++
 	}
 
 	return deleted, nil
 }
 
-// Helper functions
+// BackfillLocations fills Bucket and ObjectKey on documents stored before
+// those fields existed, parsing them from MinIOPath. Safe to run on several
+// instances at once.
+func (s *StorageService) BackfillLocations(ctx context.Context) (int, error) {
+	filter := bson.M{"bucket": bson.M{"$exists": false}}
+	cursor, err := s.mongoClient.Documents().Find(ctx, filter, options.Find().SetProjection(bson.M{"minioPath": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find documents: %w", err)
+	}
+	defer cursor.Close(ctx)
 
-func parseMinIOPath(path string) (bucket, objectPath string) {
-	// Format: "bucket/path/to/file"
-	idx := 0
-	for i, c := range path {
-		if c == '/' {
-			idx = i
-			break
+	var updated int
+	for cursor.Next(ctx) {
+		var doc models.Document
+		if err := cursor.Decode(&doc); err != nil {
+			continue
 		}
+		bucket, objectKey := doc.Location()
+		if bucket == "" || objectKey == "" {
+			fmt.Printf("Warning: document %s has invalid path %q\n", doc.ID.Hex(), doc.MinIOPath)
+			continue
+		}
+		res, err := s.mongoClient.Documents().UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "bucket": bson.M{"$exists": false}, "minioPath": doc.MinIOPath},
+			bson.M{"$set": bson.M{"bucket": bucket, "objectKey": objectKey}},
+		)
+		if err != nil {
+			return updated, fmt.Errorf("failed to update document %s: %w", doc.ID.Hex(), err)
+		}
+		updated += int(res.ModifiedCount)
 	}
-	if idx > 0 {
-		return path[:idx], path[idx+1:]
+	return updated, cursor.Err()
+}
+
+// Helper functions
+
+// moveBetween moves a file from one storage to another's user files bucket
+func moveBetween(ctx context.Context, src *minioPkg.Client, srcBucket, srcPath string, dst *minioPkg.Client, dstPath string) error {
+	info, err := src.GetFileInfo(ctx, srcBucket, srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
 	}
-	return path, ""
+	data, err := src.DownloadFile(ctx, srcBucket, srcPath)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.UploadBytes(ctx, dst.GetBucketUserFiles(), dstPath, data, info.ContentType); err != nil {
+		return err
+	}
+	if err := src.DeleteFile(ctx, srcBucket, srcPath); err != nil {
+		fmt.Printf("Warning: failed to delete moved file %s/%s: %v\n", srcBucket, srcPath, err)
+	}
+	return nil
 }
 
 // GetFileExtension returns the file extension from a filename
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"brainy-pdf/internal/config"
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// subscriptionCollection holds each user's subscription
+	subscriptionCollection = "subscriptions"
+	// billingPlanCollection maps plans and prices to gateway plan IDs, so
+	// each is created with the gateway once
+	billingPlanCollection = "billing_plans"
+
+	subscriptionLease    = "subscriptions"
+	subscriptionInterval = time.Hour
+	// subscriptionCycles is how many monthly charges a subscription runs
+	// for; the gateway needs a bound
+	subscriptionCycles = 120
+	// subscriptionGracePeriod is how long a failed renewal is retried
+	// before the plan lapses
+	subscriptionGracePeriod = 3 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidSubscriptionPlan      = errors.New("plan has no subscription")
+	ErrSubscriptionExists           = errors.New("already subscribed")
+	ErrSubscriptionNotFound         = errors.New("subscription not found")
+	ErrInvalidSubscriptionSignature = errors.New("invalid subscription signature")
+)
+
+// SubscriptionService bills paid plans monthly through the payment gateway
+// and moves users back to the free plan when their subscription lapses
+type SubscriptionService struct {
+	mongoClient   *mongodb.Client
+	gateway       SubscriptionGateway
+	users         *UserService
+	notifications *NotificationService
+}
+
+// NewSubscriptionService creates a subscription service
+func NewSubscriptionService(mongoClient *mongodb.Client, gateway SubscriptionGateway, users *UserService, notifications *NotificationService) *SubscriptionService {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mongoClient.Collection(subscriptionCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "currentPeriodEnd", Value: 1}}},
+	}); err != nil {
+		log.Printf("[Subscription] Failed to create indexes: %v", err)
+	}
+
+	return &SubscriptionService{
+		mongoClient:   mongoClient,
+		gateway:       gateway,
+		users:         users,
+		notifications: notifications,
+	}
+}
+
+func (s *SubscriptionService) collection() *mongo.Collection {
+	return s.mongoClient.Collection(subscriptionCollection)
+}
+
+// Get returns userID's subscription, nil if they never subscribed
+func (s *SubscriptionService) Get(ctx context.Context, userID string) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := s.collection().FindOne(ctx, bson.M{"userId": userID}).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// Create subscribes userID to plan. The subscription starts once the first
+// payment is authorized at its CheckoutURL and confirmed with Activate. A
+// subscription still waiting for that is replaced; live ones must be
+// cancelled and run out first.
+func (s *SubscriptionService) Create(ctx context.Context, userID, plan string) (*models.Subscription, error) {
+	amount, ok := config.PlanPrices[plan]
+	if !ok {
+		return nil, ErrInvalidSubscriptionPlan
+	}
+	existing, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Live() {
+		if existing.Status != models.SubscriptionCreated {
+			return nil, ErrSubscriptionExists
+		}
+		if _, err := s.gateway.CancelSubscription(existing.GatewaySubscriptionID, false); err != nil {
+			log.Printf("[Subscription] Failed to cancel abandoned subscription %s: %v", existing.GatewaySubscriptionID, err)
+		}
+	}
+
+	planID, err := s.gatewayPlan(ctx, plan, amount)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := s.gateway.CreateSubscription(planID, subscriptionCycles, map[string]string{
+		"userId": userID,
+		"plan":   plan,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	now := time.Now()
+	sub := models.Subscription{
+		UserID:                userID,
+		Plan:                  plan,
+		Status:                models.SubscriptionCreated,
+		Amount:                amount,
+		GatewaySubscriptionID: gs.ID,
+		GatewayPlanID:         planID,
+		CheckoutURL:           gs.ShortURL,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	opts := options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After)
+	if err := s.collection().FindOneAndReplace(ctx, bson.M{"userId": userID}, sub, opts).Decode(&sub); err != nil {
+		return nil, fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// gatewayPlan returns the gateway's monthly plan for plan at amount,
+// creating it the first time
+func (s *SubscriptionService) gatewayPlan(ctx context.Context, plan string, amount int64) (string, error) {
+	key := fmt.Sprintf("%s-%d-INR-monthly", plan, amount)
+	var saved struct {
+		GatewayPlanID string `bson:"gatewayPlanId"`
+	}
+	err := s.mongoClient.Collection(billingPlanCollection).FindOne(ctx, bson.M{"_id": key}).Decode(&saved)
+	if err == nil {
+		return saved.GatewayPlanID, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("failed to look up billing plan: %w", err)
+	}
+
+	id, err := s.gateway.CreatePlan("BinaryPDF "+plan, amount, "INR")
+	if err != nil {
+		return "", fmt.Errorf("failed to create billing plan: %w", err)
+	}
+	// Two requests may both create a plan; the first one saved is used
+	_, err = s.mongoClient.Collection(billingPlanCollection).UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$setOnInsert": bson.M{"gatewayPlanId": id, "createdAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to save billing plan: %w", err)
+	}
+	if err := s.mongoClient.Collection(billingPlanCollection).FindOne(ctx, bson.M{"_id": key}).Decode(&saved); err != nil {
+		return "", fmt.Errorf("failed to look up billing plan: %w", err)
+	}
+	return saved.GatewayPlanID, nil
+}
+
+// Activate confirms the first payment of userID's subscription with the
+// signature checkout returned, and moves them to its plan
+func (s *SubscriptionService) Activate(ctx context.Context, userID, subscriptionID, paymentID, signature string) (*models.Subscription, error) {
+	sub, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil || sub.GatewaySubscriptionID != subscriptionID || !sub.Live() {
+		return nil, ErrSubscriptionNotFound
+	}
+	if !s.gateway.VerifySubscription(subscriptionID, paymentID, signature) {
+		return nil, ErrInvalidSubscriptionSignature
+	}
+	if sub.Status != models.SubscriptionCreated {
+		return sub, nil
+	}
+
+	// The gateway sets the period end once the first charge goes through,
+	// which may lag the authorization slightly
+	periodEnd := time.Now().AddDate(0, 1, 0)
+	if gs, err := s.gateway.FetchSubscription(subscriptionID); err != nil {
+		log.Printf("[Subscription] Failed to fetch %s: %v", subscriptionID, err)
+	} else if gs.CurrentEnd != nil {
+		periodEnd = *gs.CurrentEnd
+	}
+
+	if err := s.setPlan(ctx, userID, sub.Plan); err != nil {
+		return nil, err
+	}
+	sub, err = s.update(ctx, sub, bson.M{
+		"$set":   bson.M{"status": models.SubscriptionActive, "currentPeriodEnd": periodEnd},
+		"$unset": bson.M{"checkoutUrl": ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify(ctx, userID, "Subscription Active",
+		fmt.Sprintf("You're subscribed to the %s plan. It renews on %s.", sub.Plan, periodEnd.Format("2 Jan 2006")),
+		models.NotificationTypeSuccess)
+	return sub, nil
+}
+
+// Cancel stops userID's subscription from renewing. Their plan lasts until
+// the end of the paid period; subscriptions never paid for end right away.
+func (s *SubscriptionService) Cancel(ctx context.Context, userID string) (*models.Subscription, error) {
+	sub, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil || !sub.Live() {
+		return nil, ErrSubscriptionNotFound
+	}
+	if sub.Status == models.SubscriptionCancelled {
+		return sub, nil
+	}
+
+	unpaid := sub.Status == models.SubscriptionCreated
+	if _, err := s.gateway.CancelSubscription(sub.GatewaySubscriptionID, !unpaid); err != nil {
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	status := models.SubscriptionCancelled
+	if unpaid {
+		status = models.SubscriptionExpired
+	}
+	return s.update(ctx, sub, bson.M{"$set": bson.M{"status": status, "cancelledAt": time.Now()}})
+}
+
+// Run renews, marks past due and expires subscriptions whose paid period
+// has ended, from whichever instance holds the subscription lease. Blocks
+// until ctx is cancelled.
+func (s *SubscriptionService) Run(ctx context.Context, leases *LeaseService) {
+	leases.RunPeriodic(ctx, subscriptionLease, subscriptionInterval, func(ctx context.Context) {
+		if n := s.Reconcile(ctx); n > 0 {
+			log.Printf("[Subscription] Expired %d lapsed subscriptions", n)
+		}
+	})
+}
+
+// Reconcile checks every subscription past its period end with the
+// gateway and returns how many lapsed
+func (s *SubscriptionService) Reconcile(ctx context.Context) int {
+	cursor, err := s.collection().Find(ctx, bson.M{
+		"status":           bson.M{"$in": bson.A{models.SubscriptionActive, models.SubscriptionPastDue, models.SubscriptionCancelled}},
+		"currentPeriodEnd": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		log.Printf("[Subscription] Failed to list due subscriptions: %v", err)
+		return 0
+	}
+	var due []models.Subscription
+	if err := cursor.All(ctx, &due); err != nil {
+		log.Printf("[Subscription] Failed to list due subscriptions: %v", err)
+		return 0
+	}
+
+	expired := 0
+	for i := range due {
+		lapsed, err := s.reconcile(ctx, &due[i])
+		if err != nil {
+			log.Printf("[Subscription] Failed to reconcile %s: %v", due[i].GatewaySubscriptionID, err)
+			continue
+		}
+		if lapsed {
+			expired++
+		}
+	}
+	return expired
+}
+
+// reconcile brings one due subscription up to date and reports whether it
+// lapsed
+func (s *SubscriptionService) reconcile(ctx context.Context, sub *models.Subscription) (bool, error) {
+	if sub.Status == models.SubscriptionCancelled {
+		return true, s.expire(ctx, sub)
+	}
+
+	gs, err := s.gateway.FetchSubscription(sub.GatewaySubscriptionID)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	switch gs.Status {
+	case "cancelled", "completed", "expired":
+		return true, s.expire(ctx, sub)
+	case "active":
+		if gs.CurrentEnd != nil && gs.CurrentEnd.After(now) {
+			_, err := s.update(ctx, sub, bson.M{"$set": bson.M{"status": models.SubscriptionActive, "currentPeriodEnd": *gs.CurrentEnd}})
+			return false, err
+		}
+	}
+
+	// The renewal hasn't gone through: retried by the gateway until the
+	// grace period ends
+	if now.After(sub.CurrentPeriodEnd.Add(subscriptionGracePeriod)) {
+		if _, err := s.gateway.CancelSubscription(sub.GatewaySubscriptionID, false); err != nil {
+			log.Printf("[Subscription] Failed to cancel lapsed %s: %v", sub.GatewaySubscriptionID, err)
+		}
+		return true, s.expire(ctx, sub)
+	}
+	if sub.Status != models.SubscriptionPastDue {
+		if _, err := s.update(ctx, sub, bson.M{"$set": bson.M{"status": models.SubscriptionPastDue}}); err != nil {
+			return false, err
+		}
+		s.notify(ctx, sub.UserID, "Payment Failed",
+			fmt.Sprintf("We couldn't renew your %s plan. Update your payment method by %s to keep it.",
+				sub.Plan, sub.CurrentPeriodEnd.Add(subscriptionGracePeriod).Format("2 Jan 2006")),
+			models.NotificationTypeWarning)
+	}
+	return false, nil
+}
+
+// expire ends a subscription and moves its user back to the free plan,
+// unless they have since moved to another plan (bought through a one-time
+// order, say), which the subscription no longer governs
+func (s *SubscriptionService) expire(ctx context.Context, sub *models.Subscription) error {
+	user, err := s.users.GetUserByFirebaseUID(ctx, sub.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	downgraded, err := s.users.ReplacePlan(ctx, user.ID.Hex(), sub.Plan, "free")
+	if err != nil {
+		return err
+	}
+	if _, err := s.update(ctx, sub, bson.M{"$set": bson.M{"status": models.SubscriptionExpired}}); err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Your %s subscription has ended.", sub.Plan)
+	if downgraded {
+		message = fmt.Sprintf("Your %s subscription has ended and your account is back on the Free plan.", sub.Plan)
+	}
+	s.notify(ctx, sub.UserID, "Subscription Ended", message, models.NotificationTypeWarning)
+	return nil
+}
+
+func (s *SubscriptionService) setPlan(ctx context.Context, userID, plan string) error {
+	user, err := s.users.GetUserByFirebaseUID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	return s.users.UpdatePlan(ctx, user.ID.Hex(), plan)
+}
+
+// update applies change to sub and returns it as saved
+func (s *SubscriptionService) update(ctx context.Context, sub *models.Subscription, change bson.M) (*models.Subscription, error) {
+	set, _ := change["$set"].(bson.M)
+	if set == nil {
+		set = bson.M{}
+		change["$set"] = set
+	}
+	set["updatedAt"] = time.Now()
+
+	var updated models.Subscription
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.collection().FindOneAndUpdate(ctx, bson.M{"_id": sub.ID}, change, opts).Decode(&updated); err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return &updated, nil
+}
+
+func (s *SubscriptionService) notify(ctx context.Context, userID, title, message string, notifType models.NotificationType) {
+	if s.notifications == nil {
+		return
+	}
+	if err := s.notifications.CreateNotification(ctx, FirebaseRecipient(userID), title, message, notifType); err != nil {
+		log.Printf("[Subscription] Failed to notify %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,489 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Stages of a summary job while it is processing
+const (
+	SummaryStageSummarizing = "summarizing" // chunks are being summarized (map)
+	SummaryStageCombining   = "combining"   // chunk notes are being merged (reduce)
+)
+
+// SummaryChunk is the state of one chunk of a summary job: a group of
+// consecutive pages, or part of a long page. Pages are 0 when the text had
+// no page breaks. Notes are kept so a redelivered job only redoes the
+// chunks that had not finished.
+type SummaryChunk struct {
+	Index     int       `json:"index" bson:"index"`
+	FirstPage int       `json:"firstPage,omitempty" bson:"firstPage,omitempty"`
+	LastPage  int       `json:"lastPage,omitempty" bson:"lastPage,omitempty"`
+	Chars     int       `json:"chars" bson:"chars"`
+	Status    JobStatus `json:"status" bson:"status"`
+	Error     string    `json:"error,omitempty" bson:"error,omitempty"`
+	Notes     string    `json:"-" bson:"notes,omitempty"`
+}
+
+// SummaryJob is a background AI summarization of one document
+type SummaryJob struct {
+	ID              string           `json:"id" bson:"_id"`
+	UserID          string           `json:"-" bson:"userId,omitempty"`
+	Status          JobStatus        `json:"status" bson:"status"`
+	Lane            string           `json:"lane,omitempty" bson:"lane,omitempty"`
+	Stage           string           `json:"stage,omitempty" bson:"stage,omitempty"`
+	OriginalName    string           `json:"originalName" bson:"originalName"`
+	Length          string           `json:"length" bson:"length"`
+	WordCount       int              `json:"wordCount" bson:"wordCount"`
+	InputPath       string           `json:"-" bson:"inputPath"`          // extracted text, pages joined with PageBreak
+	InputKey        string           `json:"-" bson:"inputKey,omitempty"` // staged copy in the temp bucket
+	Chunks          []SummaryChunk   `json:"chunks" bson:"chunks"`
+	TotalChunks     int              `json:"totalChunks" bson:"totalChunks"`
+	CompletedChunks int              `json:"completedChunks" bson:"completedChunks"`
+	Progress        int              `json:"progress" bson:"progress"`
+	Result          *SummarizeResult `json:"result,omitempty" bson:"result,omitempty"`
+	Error           string           `json:"error,omitempty" bson:"error,omitempty"`
+	Instance        string           `json:"-" bson:"instance,omitempty"`
+	Attempts        int              `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	CreatedAt       time.Time        `json:"createdAt" bson:"createdAt"`
+	CompletedAt     time.Time        `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+}
+
+// clone copies the job so a worker can update it while readers hold the
+// stored snapshot
+func (j *SummaryJob) clone() *SummaryJob {
+	c := *j
+	c.Chunks = append([]SummaryChunk(nil), j.Chunks...)
+	return &c
+}
+
+// summaryJobsCollection holds job state shared by all instances
+const summaryJobsCollection = "summary_jobs"
+
+// summaryMaxAttempts bounds redeliveries of a job whose worker keeps dying
+const summaryMaxAttempts = 3
+
+// SummaryService runs AI summaries as background jobs. Documents are split
+// into chunks of whole pages that are summarized in parallel and then
+// combined, so large documents neither hold an HTTP request open nor hit
+// the model's timeout.
+type SummaryService struct {
+	jobs       sync.Map
+	queue      JobQueue
+	aiService  *AIService
+	workerPool int
+	tempDir    string
+	workers    *queueWorkers
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// Shared state, as for ConversionService
+	mongoClient *mongodb.Client
+	minioClient *minioPkg.Client
+	instanceID  string
+}
+
+// NewSummaryService creates a summary service. mongoClient, minioClient and
+// queue follow the same rules as NewConversionService; workerCount may be
+// 0 for instances that only submit jobs.
+func NewSummaryService(workerCount int, aiService *AIService, mongoClient *mongodb.Client, minioClient *minioPkg.Client, queue JobQueue) (*SummaryService, error) {
+	tempDir := filepath.Join(os.TempDir(), "brainy-pdf-summary")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	if queue == nil {
+		queue = NewMemoryJobQueue(10 * time.Minute)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &SummaryService{
+		queue:      queue,
+		aiService:  aiService,
+		workerPool: workerCount,
+		tempDir:    tempDir,
+		workers:    newQueueWorkers("Summary", queue, QueueTopicSummary, workerCount),
+		ctx:        ctx,
+		cancel:     cancel,
+
+		mongoClient: mongoClient,
+		minioClient: minioClient,
+	}
+	host, _ := os.Hostname()
+	s.instanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+
+	s.workers.start(ctx, s.processJob)
+
+	fmt.Printf("[Summary] Started %d workers\n", workerCount)
+	return s, nil
+}
+
+// Close stops the workers. Unacked jobs are redelivered by the queue.
+func (s *SummaryService) Close() {
+	s.cancel()
+	s.workers.wait()
+}
+
+// SetPauseCheck makes workers hold off dequeuing while fn returns true
+func (s *SummaryService) SetPauseCheck(fn func() bool) {
+	s.workers.paused = fn
+}
+
+// SetReservedWorkers keeps n workers for jobs in paid lanes, as for
+// ConversionService
+func (s *SummaryService) SetReservedWorkers(n int) {
+	s.workers.setReserved(n)
+}
+
+// SubmitJob queues a summary of the extracted text of a document, with
+// pages joined by PageBreak, in lane and returns the job. userID may be
+// empty.
+func (s *SummaryService) SubmitJob(userID, lane, text, originalName, length string) (*SummaryJob, error) {
+	chunks := groupPages(SplitPages(text), summarySectionChars)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no text to summarize")
+	}
+
+	job := &SummaryJob{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Lane:         lane,
+		Status:       JobStatusQueued,
+		OriginalName: originalName,
+		Length:       length,
+		WordCount:    len(strings.Fields(text)),
+		TotalChunks:  len(chunks),
+		CreatedAt:    time.Now(),
+	}
+	for i, chunk := range chunks {
+		job.Chunks = append(job.Chunks, SummaryChunk{
+			Index:     i,
+			FirstPage: chunk.FirstPage,
+			LastPage:  chunk.LastPage,
+			Chars:     len(chunk.Text),
+			Status:    JobStatusQueued,
+		})
+	}
+
+	if err := s.stageInput(job, text); err != nil {
+		return nil, fmt.Errorf("failed to stage document text: %w", err)
+	}
+
+	s.saveJob(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.queue.Enqueue(ctx, QueueTopicSummary, job.Lane, job.ID); err != nil {
+		s.jobs.Delete(job.ID)
+		s.deleteInput(job)
+		return nil, err
+	}
+
+	fmt.Printf("[Summary] Job %s queued with %d chunks\n", job.ID, job.TotalChunks)
+	return job, nil
+}
+
+// stageInput writes the text to local disk and, when configured, to the
+// temp bucket so a worker on any instance can run the job
+func (s *SummaryService) stageInput(job *SummaryJob, text string) error {
+	job.InputPath = filepath.Join(s.tempDir, job.ID+".txt")
+	if err := os.WriteFile(job.InputPath, []byte(text), 0644); err != nil {
+		return err
+	}
+	if s.minioClient == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	key := fmt.Sprintf("summaries/%s/input.txt", job.ID)
+	if _, err := s.minioClient.UploadBytes(ctx, s.minioClient.GetBucketTemp(), key, []byte(text), "text/plain; charset=utf-8"); err != nil {
+		os.Remove(job.InputPath)
+		return err
+	}
+	job.InputKey = key
+	return nil
+}
+
+// loadInput reads the job's text, from the temp bucket when it was
+// submitted on another instance
+func (s *SummaryService) loadInput(job *SummaryJob) (string, error) {
+	if data, err := os.ReadFile(job.InputPath); err == nil {
+		return string(data), nil
+	}
+	if job.InputKey == "" || s.minioClient == nil {
+		return "", fmt.Errorf("document text is not available on this instance")
+	}
+	data, err := s.minioClient.DownloadFile(s.ctx, s.minioClient.GetBucketTemp(), job.InputKey)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// deleteInput removes the staged text
+func (s *SummaryService) deleteInput(job *SummaryJob) {
+	os.Remove(job.InputPath)
+	if s.minioClient == nil || job.InputKey == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketTemp(), job.InputKey)
+}
+
+// saveJob records a snapshot of the job locally and, when configured, in Mongo
+func (s *SummaryService) saveJob(job *SummaryJob) {
+	snapshot := job.clone()
+	s.jobs.Store(job.ID, snapshot)
+
+	if s.mongoClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.mongoClient.Collection(summaryJobsCollection).ReplaceOne(ctx,
+		bson.M{"_id": job.ID}, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		fmt.Printf("[Summary] Failed to persist job %s: %v\n", job.ID, err)
+	}
+}
+
+// GetJob returns the current state of a job. The result must not be modified.
+func (s *SummaryService) GetJob(jobID string) (*SummaryJob, error) {
+	if val, ok := s.jobs.Load(jobID); ok {
+		return val.(*SummaryJob), nil
+	}
+
+	// Job may have been submitted to another instance
+	if s.mongoClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var job SummaryJob
+		err := s.mongoClient.Collection(summaryJobsCollection).FindOne(ctx, bson.M{"_id": jobID}).Decode(&job)
+		if err == nil {
+			return &job, nil
+		}
+	}
+	return nil, fmt.Errorf("job not found")
+}
+
+// CleanupFinished forgets local copies of jobs that reached a final state
+// more than maxAge ago; they stay readable from Mongo
+func (s *SummaryService) CleanupFinished(ctx context.Context, maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	s.jobs.Range(func(key, val interface{}) bool {
+		job := val.(*SummaryJob)
+		if (job.Status == JobStatusCompleted || job.Status == JobStatusFailed) &&
+			!job.CompletedAt.IsZero() && job.CompletedAt.Before(cutoff) {
+			s.jobs.Delete(key)
+		}
+		return true
+	})
+}
+
+// QueueDepth returns the number of summary jobs waiting for a worker
+func (s *SummaryService) QueueDepth(ctx context.Context) (int64, error) {
+	return s.queue.Depth(ctx, QueueTopicSummary)
+}
+
+// QueuePosition returns the job's place in the queue, from 1, or 0 once a
+// worker has taken it
+func (s *SummaryService) QueuePosition(ctx context.Context, jobID string) (int64, error) {
+	return s.queue.Position(ctx, QueueTopicSummary, jobID)
+}
+
+// processJob summarizes every unfinished chunk, then combines the notes.
+// It returns false when the service is shutting down and the job should be
+// redelivered.
+func (s *SummaryService) processJob(jobID string, attempts int) bool {
+	stored, err := s.GetJob(jobID)
+	if err != nil {
+		fmt.Printf("[Summary] Job %s: %v, dropping message\n", jobID, err)
+		return true
+	}
+	if stored.Status == JobStatusCompleted || stored.Status == JobStatusFailed {
+		return true
+	}
+	job := stored.clone()
+
+	job.Attempts = attempts
+	if attempts > summaryMaxAttempts {
+		s.failJob(job, fmt.Sprintf("Gave up after %d attempts", summaryMaxAttempts))
+		return true
+	}
+
+	text, err := s.loadInput(job)
+	if err != nil {
+		s.failJob(job, fmt.Sprintf("Failed to fetch document text: %v", err))
+		return true
+	}
+	chunks := groupPages(SplitPages(text), summarySectionChars)
+	if len(chunks) != job.TotalChunks {
+		s.failJob(job, "Document text changed since the job was queued")
+		return true
+	}
+
+	job.Status = JobStatusProcessing
+	job.Stage = SummaryStageSummarizing
+	job.Instance = s.instanceID
+	s.saveJob(job)
+
+	fmt.Printf("[Summary] Processing job %s (%d chunks)\n", jobID, job.TotalChunks)
+
+	var result *SummarizeResult
+	if job.TotalChunks == 1 {
+		// Short documents go straight to a single call
+		result, err = s.aiService.SummarizePDF(s.ctx, chunks[0].Text, job.Length)
+	} else {
+		var notes []textSection
+		notes, err = s.summarizeChunks(job, chunks)
+		if err == nil {
+			job.Stage = SummaryStageCombining
+			s.saveJob(job)
+			result, err = s.aiService.CombineSummaries(s.ctx, notes, job.Length, job.WordCount)
+		}
+	}
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return false
+		}
+		s.failJob(job, SummaryErrorMessage(err))
+		return true
+	}
+
+	result.WordCount = job.WordCount
+	for i := range job.Chunks {
+		job.Chunks[i].Status = JobStatusCompleted
+		job.Chunks[i].Notes = ""
+	}
+	s.deleteInput(job)
+
+	job.Status = JobStatusCompleted
+	job.Stage = ""
+	job.Result = result
+	job.CompletedChunks = job.TotalChunks
+	job.Progress = 100
+	job.CompletedAt = time.Now()
+	s.saveJob(job)
+
+	fmt.Printf("[Summary] Job %s completed\n", jobID)
+	return true
+}
+
+// summarizeChunks is the map step: it summarizes the chunks without notes
+// in parallel, recording progress as each one finishes, and returns the
+// notes in document order. The first failure stops the remaining chunks.
+func (s *SummaryService) summarizeChunks(job *SummaryJob, chunks []textSection) ([]textSection, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, summaryConcurrency)
+
+	for i := range chunks {
+		if job.Chunks[i].Status == JobStatusCompleted && job.Chunks[i].Notes != "" {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		mu.Lock()
+		job.Chunks[i].Status = JobStatusProcessing
+		job.Chunks[i].Error = ""
+		s.saveJob(job)
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			notes, err := s.aiService.SummarizeSection(ctx, chunks[i], i, len(chunks))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					job.Chunks[i].Status = JobStatusFailed
+					job.Chunks[i].Error = SummaryErrorMessage(err)
+					cancel()
+				} else {
+					// Cancelled because another chunk failed
+					job.Chunks[i].Status = JobStatusQueued
+				}
+				s.saveJob(job)
+				return
+			}
+			job.Chunks[i].Status = JobStatusCompleted
+			job.Chunks[i].Notes = notes
+			job.CompletedChunks++
+			// The combine step accounts for the last tenth
+			job.Progress = job.CompletedChunks * 90 / job.TotalChunks
+			s.saveJob(job)
+			fmt.Printf("[Summary] Job %s: %d/%d chunks completed\n", job.ID, job.CompletedChunks, job.TotalChunks)
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	notes := make([]textSection, len(job.Chunks))
+	for i, c := range job.Chunks {
+		notes[i] = textSection{FirstPage: c.FirstPage, LastPage: c.LastPage, Text: c.Notes}
+	}
+	return notes, nil
+}
+
+// failJob marks a job as failed
+func (s *SummaryService) failJob(job *SummaryJob, errMsg string) {
+	s.deleteInput(job)
+	job.Status = JobStatusFailed
+	job.Stage = ""
+	job.Error = errMsg
+	job.CompletedAt = time.Now()
+	s.saveJob(job)
+	fmt.Printf("[Summary] Job %s failed: %s\n", job.ID, errMsg)
+}
+
+// SummaryErrorMessage turns an AI failure into a message for the user
+func SummaryErrorMessage(err error) string {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "quota") || strings.Contains(errMsg, "rate"):
+		return "AI API rate limit exceeded. Please try again in a few moments."
+	case strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline"):
+		return "AI processing timed out. Please try again."
+	case strings.Contains(errMsg, "unintelligible") || strings.Contains(errMsg, "encrypted"):
+		return "The document content could not be understood. Please ensure the PDF contains readable text, not just images."
+	}
+	return "Summarization failed: " + errMsg
+}
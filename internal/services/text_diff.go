@@ -0,0 +1,266 @@
+package services
+
+import (
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+const (
+	// diffMaxSentenceWords splits run-on text without sentence ends
+	diffMaxSentenceWords = 60
+	// diffMaxEdits bounds the diff search; beyond it the differing middle
+	// of the documents is reported as one change
+	diffMaxEdits = 4000
+)
+
+// diffUnit is a sentence of a document and the page it starts on
+type diffUnit struct {
+	Text string
+	Page int
+}
+
+// diffOp is one step of an edit script: '=' keeps a[A] (== b[B]), '-'
+// removes a[A], '+' inserts b[B]
+type diffOp struct {
+	Kind byte
+	A, B int
+}
+
+// DiffPageTexts compares two versions of a document, given the text of each
+// page, sentence by sentence. Words are compared with whitespace collapsed,
+// so text that merely reflowed or moved to another page is unchanged.
+func DiffPageTexts(before, after []string) []models.TextChange {
+	a := splitSentences(before)
+	b := splitSentences(after)
+
+	keysA := make([]string, len(a))
+	for i, u := range a {
+		keysA[i] = u.Text
+	}
+	keysB := make([]string, len(b))
+	for i, u := range b {
+		keysB[i] = u.Text
+	}
+
+	var changes []models.TextChange
+	var removed, added []diffUnit
+	posA, posB := 0, 0 // Where a run starts in each version, to place pure additions and removals
+
+	flush := func() {
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		c := models.TextChange{
+			PageBefore: unitPage(a, posA, before),
+			PageAfter:  unitPage(b, posB, after),
+		}
+		switch {
+		case len(removed) > 0 && len(added) > 0:
+			c.Type = models.TextChanged
+		case len(removed) > 0:
+			c.Type = models.TextRemoved
+		default:
+			c.Type = models.TextAdded
+		}
+		if len(removed) > 0 {
+			c.PageBefore = removed[0].Page
+			c.Before = joinUnits(removed)
+		}
+		if len(added) > 0 {
+			c.PageAfter = added[0].Page
+			c.After = joinUnits(added)
+		}
+		changes = append(changes, c)
+		removed, added = nil, nil
+	}
+
+	for _, op := range diffStrings(keysA, keysB) {
+		switch op.Kind {
+		case '=':
+			flush()
+		case '-':
+			if len(removed) == 0 && len(added) == 0 {
+				posB = op.B
+			}
+			removed = append(removed, a[op.A])
+		case '+':
+			if len(removed) == 0 && len(added) == 0 {
+				posA = op.A
+			}
+			added = append(added, b[op.B])
+		}
+	}
+	flush()
+	return changes
+}
+
+// unitPage is the page of units[i], or the last page when i is past the end
+func unitPage(units []diffUnit, i int, pages []string) int {
+	if i < len(units) {
+		return units[i].Page
+	}
+	if len(units) > 0 {
+		return units[len(units)-1].Page
+	}
+	if len(pages) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func joinUnits(units []diffUnit) string {
+	parts := make([]string, len(units))
+	for i, u := range units {
+		parts[i] = u.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitSentences breaks the pages into sentences that flow across page
+// breaks; each keeps the page it starts on
+func splitSentences(pages []string) []diffUnit {
+	var units []diffUnit
+	var words []string
+	page := 0
+	flush := func() {
+		if len(words) > 0 {
+			units = append(units, diffUnit{Text: strings.Join(words, " "), Page: page})
+			words = nil
+		}
+	}
+
+	for i, text := range pages {
+		for _, w := range strings.Fields(text) {
+			if len(words) == 0 {
+				page = i + 1
+			}
+			words = append(words, w)
+			if strings.ContainsAny(w[len(w)-1:], ".!?;") || len(words) >= diffMaxSentenceWords {
+				flush()
+			}
+		}
+	}
+	flush()
+	return units
+}
+
+// diffStrings returns an edit script turning a into b, using Myers'
+// algorithm on what remains after the common prefix and suffix
+func diffStrings(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{'=', i, i})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	mid, ok := myers(midA, midB, diffMaxEdits)
+	if !ok {
+		// Too different to align; report the whole middle as replaced
+		mid = nil
+		for i := range midA {
+			mid = append(mid, diffOp{'-', i, 0})
+		}
+		for j := range midB {
+			mid = append(mid, diffOp{'+', len(midA), j})
+		}
+	}
+	for _, op := range mid {
+		op.A += prefix
+		op.B += prefix
+		ops = append(ops, op)
+	}
+
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, diffOp{'=', len(a) - suffix + i, len(b) - suffix + i})
+	}
+	return ops
+}
+
+// myers finds a shortest edit script with at most maxEdits insertions and
+// removals. Every op carries both positions: for '-' B is where the next
+// kept element of b is, for '+' A likewise.
+func myers(a, b []string, maxEdits int) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	// trace[d] holds the furthest x on each diagonal k in -d..d after d edits
+	var trace [][]int
+	v := make([]int, 2*limit+3)
+	off := limit + 1
+	end := -1
+	for d := 0; d <= limit && end < 0; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				end = d
+				break
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	if end < 0 {
+		return nil, false
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := end; d > 0; d-- {
+		prev := trace[d-1] // diagonals -(d-1)..d-1
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{'=', x, y})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', x, y})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{'=', x, y})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
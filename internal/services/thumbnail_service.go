@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // DecodeConfig of rendered JPEGs
+	_ "image/png"  // DecodeConfig of rendered PNGs
+	"math"
+
+	"brainy-pdf/internal/renderer"
+)
+
+const (
+	// MaxThumbnailPages bounds the pages rendered by one request
+	MaxThumbnailPages = 50
+	// maxThumbnailPixels bounds the longer side of a rendered page; the DPI
+	// is lowered for pages that would exceed it, e.g. posters and drawings
+	maxThumbnailPixels = 4096
+)
+
+// ErrUnreadablePDF is returned when the input can't be read as a PDF, e.g.
+// a stored file of another type
+var ErrUnreadablePDF = errors.New("invalid PDF file")
+
+// Thumbnail is one rendered page
+type Thumbnail struct {
+	Page   int
+	Width  int // pixels
+	Height int
+	DPI    int // below the requested DPI for very large pages
+	Data   []byte
+}
+
+// ThumbnailService renders page previews for the library and the reorder
+// and editor views
+type ThumbnailService struct {
+	pdfService *PDFService
+	renderer   *renderer.Renderer
+}
+
+func NewThumbnailService(pdfService *PDFService, r *renderer.Renderer) *ThumbnailService {
+	return &ThumbnailService{pdfService: pdfService, renderer: r}
+}
+
+// Capability reports whether pages can be rendered. Safe to call on a nil
+// service.
+func (s *ThumbnailService) Capability() Capability {
+	c := Capability{Name: CapabilityThumbnails}
+	switch {
+	case s == nil:
+		c.Reason = "Thumbnail service failed to start"
+	case !s.renderer.Available():
+		c.Reason = "pdftoppm (poppler-utils) is not installed"
+	default:
+		c.Available = true
+	}
+	return c
+}
+
+// Render renders pages (1-based, in the order given) of a PDF with opts.
+// Each page runs in the PDF service's scratch space, so large documents
+// count against the per-job temp quota.
+func (s *ThumbnailService) Render(ctx context.Context, in PDFReader, pages []int, opts renderer.Options) ([]Thumbnail, error) {
+	opts, err := renderer.NormalizeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) > MaxThumbnailPages {
+		return nil, fmt.Errorf("%w: at most %d pages can be rendered at once", renderer.ErrInvalidOptions, MaxThumbnailPages)
+	}
+	if !s.renderer.Available() {
+		return nil, renderer.ErrUnavailable
+	}
+
+	geometry, err := s.pdfService.PageGeometry(in)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadablePDF, err)
+	}
+	for _, page := range pages {
+		if page < 1 || page > len(geometry) {
+			return nil, fmt.Errorf("%w: page %d is out of range (document has %d pages)", renderer.ErrInvalidOptions, page, len(geometry))
+		}
+	}
+
+	scratch, err := s.pdfService.scratch.New("thumbnails")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+	input := scratch.Path("input.pdf")
+	if err := writeInput(scratch, input, in); err != nil {
+		return nil, err
+	}
+
+	thumbs := make([]Thumbnail, 0, len(pages))
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := scratch.Check(); err != nil {
+			return nil, err
+		}
+
+		pageOpts := opts
+		g := geometry[page-1]
+		if longest := math.Max(g.Width, g.Height); longest > 0 {
+			if limit := int(maxThumbnailPixels * 72 / longest); pageOpts.DPI > limit {
+				pageOpts.DPI = max(limit, 1)
+			}
+		}
+
+		img, err := s.renderer.RenderPage(ctx, input, scratch.Dir(), page, pageOpts)
+		if err != nil {
+			return nil, err
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rendered page %d: %w", page, err)
+		}
+		thumbs = append(thumbs, Thumbnail{Page: page, Width: cfg.Width, Height: cfg.Height, DPI: pageOpts.DPI, Data: img})
+	}
+	return thumbs, nil
+}
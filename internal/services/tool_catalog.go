@@ -0,0 +1,495 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"brainy-pdf/internal/assets"
+	"brainy-pdf/internal/renderer"
+)
+
+// ToolParam describes one request parameter of a tool
+type ToolParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // file, files, string, integer, number, boolean, json
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// ToolSpec describes a tool exposed by the API. Requires names the
+// capabilities (see Capability) that must be available for the tool to work.
+type ToolSpec struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Category    string      `json:"category"` // pdf, organize, edit, convert, ai
+	Method      string      `json:"method"`
+	Endpoint    string      `json:"endpoint"`
+	ContentType string      `json:"contentType"`
+	Params      []ToolParam `json:"params"`
+	Premium     bool        `json:"premium"`
+	Requires    []string    `json:"requires,omitempty"`
+}
+
+// Capability reports whether an optional backend a tool depends on is usable
+type Capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Capability names reported by services
+const (
+	CapabilityConversion    = "conversion"
+	CapabilityAI            = "ai"
+	CapabilityOCR           = "ocr"
+	CapabilityTranscription = "transcription"
+	CapabilitySpeech        = "speech"
+	CapabilityThumbnails    = "thumbnails"
+)
+
+const (
+	multipartForm = "multipart/form-data"
+	jsonBody      = "application/json"
+)
+
+var (
+	pdfFileParam = ToolParam{Name: "file", Type: "file", Required: true, Description: "PDF file"}
+	pagesParam   = ToolParam{Name: "pages", Type: "string", Required: true, Description: "Page selection, e.g. 1-3,5"}
+	unitParam    = ToolParam{Name: "unit", Type: "string", Default: UnitPoints, Enum: []string{UnitPoints, UnitPercent}, Description: "percent places x/y relative to each page's size; sizes in points come from GET /api/pdf/{fileId}/pages"}
+)
+
+// ToolCatalog lists every tool the API offers
+func ToolCatalog() []ToolSpec {
+	return []ToolSpec{
+		{
+			ID: "merge", Name: "Merge PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/merge", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "files", Type: "files", Description: "Two or more PDF files, merged in order"},
+				{Name: "fileIds", Type: "string", Description: "Comma-separated fileIds of earlier uploads, merged in order; instead of files"},
+			},
+		},
+		{
+			ID: "split", Name: "Split PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/split", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam, {Name: "pages", Type: "string", Required: true, Description: "Comma-separated ranges, one output per range, e.g. 1-3, 4-7"}},
+		},
+		{
+			ID: "auto-split", Name: "Auto-Split Documents", Category: "organize",
+			Method: "POST", Endpoint: "/api/pdf/auto-split", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "ai", Type: "boolean", Description: "Have the AI model confirm boundaries and name documents; used when available unless false"},
+				{Name: "dryRun", Type: "boolean", Default: false, Description: "Only report the documents found"},
+			},
+		},
+		{
+			ID: "rotate", Name: "Rotate PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/rotate", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "angle", Type: "integer", Required: true, Enum: []string{"90", "180", "270"}},
+				{Name: "pages", Type: "string", Default: "1-", Description: "Pages to rotate"},
+			},
+		},
+		{
+			ID: "compress", Name: "Compress PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/compress", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "quality", Type: "string", Default: "medium", Enum: []string{"low", "medium", "high"}},
+				{Name: "mode", Type: "string", Default: CompressModeStandard, Enum: []string{CompressModeStandard, CompressModePhoto}, Description: "photo recompresses JPEGs; suggested by imageProfile for image-heavy files"},
+				{Name: "dpi", Type: "integer", Default: 150, Description: "Photo mode: target image resolution"},
+				{Name: "dryRun", Type: "boolean", Default: false, Description: "Estimate the savings without storing a file"},
+			},
+		},
+		{
+			ID: "crop", Name: "Crop PDF", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/crop", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "top", Type: "number", Default: 0},
+				{Name: "right", Type: "number", Default: 0},
+				{Name: "bottom", Type: "number", Default: 0},
+				{Name: "left", Type: "number", Default: 0},
+			},
+		},
+		{
+			ID: "watermark", Name: "Add Watermark", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/watermark", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "type", Type: "string", Default: WatermarkTypeText, Enum: []string{WatermarkTypeText, WatermarkTypeImage}},
+				{Name: "text", Type: "string", Description: "Required for text watermarks"},
+				{Name: "image", Type: "file", Description: "PNG or JPEG logo, required for image watermarks"},
+				{Name: "position", Type: "string", Default: "center", Description: "Image watermarks: center, left, right, top-left, top-center, top-right, bottom-left, bottom-center or bottom-right"},
+				{Name: "scale", Type: "number", Default: 0.5, Description: "Image width relative to the page, 0.05 to 1"},
+				{Name: "opacity", Type: "number", Default: 0.3, Description: "0.1 to 1.0"},
+				{Name: "fontSize", Type: "integer", Default: 48, Description: "6 to 200"},
+				{Name: "color", Type: "string", Default: "#808080", Description: "Hex color"},
+				{Name: "font", Type: "string", Default: "helvetica", Enum: standardFontNames()},
+				{Name: "mode", Type: "string", Default: WatermarkModeDiagonal, Enum: []string{WatermarkModeDiagonal, WatermarkModeHorizontal}},
+				{Name: "rotation", Type: "number", Description: "Degrees, -180 to 180; overrides the 45 degree diagonal"},
+				{Name: "tile", Type: "boolean", Default: false, Description: "Repeat the text across each page"},
+			},
+		},
+		{
+			ID: "page-numbers", Name: "Add Page Numbers", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/page-numbers", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "position", Type: "string", Default: "bottom-center", Enum: []string{"bottom-center", "bottom-right", "bottom-left", "top-center", "top-right", "top-left"}},
+				{Name: "format", Type: "string", Default: "{n}", Description: "{n} is the page number, {total} the last number"},
+				{Name: "startFrom", Type: "integer", Default: 1},
+				{Name: "style", Type: "string", Default: PageNumberStyleArabic, Enum: []string{PageNumberStyleArabic, PageNumberStyleRoman, PageNumberStyleRomanUpper}},
+				{Name: "frontMatter", Type: "integer", Default: 0, Description: "Leading pages numbered i, ii, iii before the body"},
+				{Name: "skip", Type: "string", Description: "Pages left unnumbered, e.g. 1 for the cover"},
+				{Name: "mirror", Type: "boolean", Default: false, Description: "Swap left and right on even pages for duplex printing"},
+			},
+		},
+		{
+			ID: "reorder", Name: "Reorder Pages", Category: "organize",
+			Method: "POST", Endpoint: "/api/pdf/reorder", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam, {Name: "order", Type: "string", Required: true, Description: "New page order, e.g. 3,1,2"}},
+		},
+		{
+			ID: "remove", Name: "Remove Pages", Category: "organize",
+			Method: "POST", Endpoint: "/api/pdf/remove", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam, pagesParam},
+		},
+		{
+			ID: "extract", Name: "Extract Pages", Category: "organize",
+			Method: "POST", Endpoint: "/api/pdf/extract", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam, pagesParam},
+		},
+		{
+			ID: "pipeline", Name: "Pipeline", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/pipeline", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "steps", Type: "json", Required: true, Description: "Up to 10 operations applied in order, e.g. [{\"operation\": \"rotate\", \"angle\": 90}, {\"operation\": \"compress\"}]; rotate, crop, watermark (text), page-numbers, compress, reorder, remove, extract or sanitize with their endpoints' parameters"},
+			},
+		},
+		{
+			ID: "info", Name: "PDF Info", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/info", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "search", Name: "Search PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/search", ContentType: jsonBody,
+			Params: []ToolParam{
+				{Name: "fileId", Type: "string", Required: true, Description: "Stored file to search; a multipart file upload works too"},
+				{Name: "query", Type: "string", Required: true},
+				{Name: "caseSensitive", Type: "boolean", Default: false},
+				{Name: "limit", Type: "integer", Default: DefaultSearchLimit, Description: "Matches returned; totalMatches counts all"},
+			},
+		},
+		{
+			ID: "detect-structure", Name: "Detect Outline", Category: "organize",
+			Method: "POST", Endpoint: "/api/pdf/detect-structure", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "writeBookmarks", Type: "boolean", Default: false, Description: "Replace the bookmarks with the detected outline"},
+				{Name: "ai", Type: "boolean", Default: false, Description: "Have the AI model confirm and level the headings"},
+				{Name: "maxHeadings", Type: "integer", Default: DefaultMaxHeadings},
+			},
+		},
+		{
+			ID: "sanitize", Name: "Sanitize PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/sanitize", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "scan", Name: "Security Scan", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/scan", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "protect", Name: "Protect PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/protect", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "password", Type: "string", Required: true, Description: "Needed to open the PDF"},
+				{Name: "ownerPassword", Type: "string", Description: "Lifts the restrictions; required with restrict"},
+				{Name: "restrict", Type: "string", Description: "Comma-separated: print, copy, modify, annotate"},
+			},
+		},
+		{
+			ID: "unlock", Name: "Unlock PDF", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/unlock", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "password", Type: "string", Required: true, Description: "User or owner password"},
+			},
+		},
+		{
+			ID: "form-fields", Name: "List Form Fields", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/form/fields", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "form-fill", Name: "Fill Form", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/form/fill", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "values", Type: "string", Required: true, Description: "JSON object of field name to value, e.g. {\"firstName\": \"Ada\", \"subscribe\": true}"},
+				{Name: "flatten", Type: "boolean", Default: false, Description: "Burn the values into the pages so they can't be edited"},
+			},
+		},
+		{
+			ID: "form-flatten", Name: "Flatten Form", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/form/flatten", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "preflight", Name: "Print Preflight", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/preflight", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "minDpi", Type: "integer", Default: 300, Description: "Images below this resolution are flagged"},
+				{Name: "bleed", Type: "number", Default: 3, Description: "Bleed required beyond the trim box, in mm"},
+			},
+		},
+		{
+			ID: "thumbnails", Name: "Page Thumbnails", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/thumbnails", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "pages", Type: "string", Description: "Page selection, e.g. 1-3,5 (default: all pages, at most 50)"},
+				{Name: "dpi", Type: "integer", Default: renderer.DefaultDPI, Description: "Resolution, 18 to 300"},
+				{Name: "format", Type: "string", Default: renderer.FormatPNG, Enum: []string{renderer.FormatPNG, renderer.FormatJPEG}},
+				{Name: "quality", Type: "integer", Default: renderer.DefaultQuality, Description: "JPEG quality, 1 to 100"},
+			},
+			Requires: []string{CapabilityThumbnails},
+		},
+		{
+			ID: "invert", Name: "Dark Mode PDF", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/invert", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "background", Type: "string", Default: "#1E1E1E", Description: "Hex page color"},
+				{Name: "foreground", Type: "string", Default: "#E6E6E6", Description: "Hex color for black text"},
+				{Name: "images", Type: "string", Default: InvertImagesKeep, Enum: []string{InvertImagesKeep, InvertImagesDim}, Description: "dim veils images in the background color"},
+			},
+		},
+		{
+			ID: "draw-text", Name: "Draw Text", Category: "edit", Premium: true,
+			Method: "POST", Endpoint: "/api/pdf/draw-text", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "text", Type: "string", Description: "Required unless placements is set; \\n starts a new line"},
+				{Name: "x", Type: "number", Default: 0},
+				{Name: "y", Type: "number", Default: 0},
+				{Name: "fontSize", Type: "number", Default: 24},
+				{Name: "color", Type: "string", Default: "#000000"},
+				{Name: "font", Type: "string", Default: "helvetica", Enum: standardFontNames()},
+				{Name: "pages", Type: "string", Description: "Page selection, all pages when empty"},
+				{Name: "width", Type: "number", Description: "Wrap width in points"},
+				{Name: "align", Type: "string", Default: TextAlignLeft, Enum: []string{TextAlignLeft, TextAlignCenter, TextAlignRight}},
+				{Name: "rotation", Type: "number", Default: 0},
+				unitParam,
+				{Name: "placements", Type: "json", Description: "Array of {text, x, y, fontSize, color, font, pages, width, align, rotation} drawn in one pass"},
+			},
+		},
+		{
+			ID: "add-badge", Name: "Add Badge", Category: "edit", Premium: true,
+			Method: "POST", Endpoint: "/api/pdf/add-badge", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "type", Type: "string", Default: "gold", Enum: assets.Badges()},
+				{Name: "x", Type: "number", Default: 0},
+				{Name: "y", Type: "number", Default: 0},
+				{Name: "scale", Type: "number", Default: 1.0},
+				unitParam,
+			},
+		},
+		{
+			ID: "stamp-signature", Name: "Sign with Saved Signature", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/stamp-signature", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "signatureId", Type: "string", Required: true, Description: "ID from /api/v1/signatures"},
+				{Name: "x", Type: "number", Default: 0},
+				{Name: "y", Type: "number", Default: 0},
+				{Name: "width", Type: "number", Default: 150, Description: "Rendered width in points"},
+				{Name: "pages", Type: "string", Default: "l", Description: "Pages to sign (l = last page)"},
+				{Name: "opacity", Type: "number", Default: 1.0},
+			},
+		},
+		{
+			ID: "sign", Name: "Digitally Sign PDF", Category: "edit",
+			Method: "POST", Endpoint: "/api/pdf/sign", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "certificate", Type: "file", Required: true, Description: "PKCS#12 (.p12 or .pfx) file with the signing key and certificate"},
+				{Name: "password", Type: "string", Description: "Password of the certificate file"},
+				{Name: "signatureId", Type: "string", Description: "Saved signature from /api/v1/signatures to show on the page"},
+				{Name: "image", Type: "file", Description: "PNG to show on the page instead of a saved signature"},
+				{Name: "page", Type: "integer", Description: "Page of a visible signature (default: last)"},
+				{Name: "x", Type: "number", Default: 0},
+				{Name: "y", Type: "number", Default: 0},
+				{Name: "width", Type: "number", Default: 150, Description: "Rendered width in points"},
+				{Name: "reason", Type: "string"},
+				{Name: "location", Type: "string"},
+				{Name: "contactInfo", Type: "string"},
+			},
+		},
+		{
+			ID: "verify-signature", Name: "Verify Signatures", Category: "pdf",
+			Method: "POST", Endpoint: "/api/pdf/verify-signature", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "convert", Name: "Convert Documents", Category: "convert",
+			Method: "POST", Endpoint: "/api/v1/convert", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "files", Type: "files", Required: true, Description: "Office documents (doc, docx, odt, ppt, pptx, xls, xlsx)"},
+				{Name: "outputFormat", Type: "string", Default: "pdf", Enum: []string{"pdf", "docx", "odt"}},
+				{Name: "orientation", Type: "string", Enum: []string{SpreadsheetPortrait, SpreadsheetLandscape}, Description: "Page orientation of spreadsheets converted to PDF (default: the workbook's)"},
+				{Name: "fitToWidth", Type: "boolean", Default: false, Description: "Scale each spreadsheet sheet to one page wide"},
+				{Name: "gridlines", Type: "boolean", Default: false, Description: "Print spreadsheet gridlines"},
+				{Name: "sheets", Type: "string", Description: "Comma-separated names of the spreadsheet sheets to export (default: all)"},
+			},
+			Requires: []string{CapabilityConversion},
+		},
+		{
+			ID: "convert-handout", Name: "Presentation Handout", Category: "convert",
+			Method: "POST", Endpoint: "/api/v1/convert/handout", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "file", Type: "file", Required: true, Description: "Presentation (ppt or pptx)"},
+				{Name: "slidesPerPage", Type: "integer", Default: 4, Enum: []string{"2", "4", "6"}, Description: "Slides per page, each with note lines beside it"},
+				{Name: "pageSize", Type: "string", Default: "A4", Enum: []string{"A4", "Letter"}},
+			},
+			Requires: []string{CapabilityConversion},
+		},
+		{
+			ID: "scan-document", Name: "Scan to PDF", Category: "convert",
+			Method: "POST", Endpoint: "/api/pdf/scan-document", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "images", Type: "files", Required: true, Description: "Phone photos of paper pages (JPEG or PNG), one per page in order"},
+				{Name: "mode", Type: "string", Default: ScanModeGrayscale, Enum: []string{ScanModeColor, ScanModeGrayscale, ScanModeBW}},
+				{Name: "pageSize", Type: "string", Default: "A4", Enum: []string{"A4", "Letter"}},
+				{Name: "corners", Type: "json", Description: "Per image, the page's four {x, y} pixel corners from the top-left, clockwise, or null to detect them"},
+			},
+		},
+		{
+			ID: "from-images", Name: "Images to PDF", Category: "convert",
+			Method: "POST", Endpoint: "/api/pdf/from-images", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "images", Type: "files", Required: true, Description: "JPEG or PNG images, one per page in order"},
+				{Name: "pageSize", Type: "string", Default: "A4", Enum: []string{"A4", "Letter", "Legal", ImagePageFit}, Description: "Fit sizes each page to its image"},
+				{Name: "orientation", Type: "string", Default: ImagePageAuto, Enum: []string{ImagePagePortrait, ImagePageLandscape, ImagePageAuto}},
+				{Name: "margin", Type: "number", Default: 0, Description: "Space round each image, in points (at most 144)"},
+			},
+		},
+		{
+			ID: "ocr", Name: "OCR", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/ocr", ContentType: multipartForm,
+			Params:   []ToolParam{pdfFileParam},
+			Requires: []string{CapabilityOCR},
+		},
+		{
+			ID: "summarize", Name: "Summarize", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/summarize", ContentType: multipartForm,
+			Params:   []ToolParam{pdfFileParam, {Name: "length", Type: "string", Default: "medium", Enum: []string{"short", "medium", "long"}}},
+			Requires: []string{CapabilityAI},
+		},
+		{
+			ID: "contract-review", Name: "Contract Review", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/contract-review", ContentType: multipartForm,
+			Params:   []ToolParam{pdfFileParam},
+			Requires: []string{CapabilityAI},
+		},
+		{
+			ID: "diff-summary", Name: "Compare Versions", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/diff-summary", ContentType: multipartForm,
+			Params: []ToolParam{
+				{Name: "original", Type: "file", Required: true, Description: "Original version (PDF)"},
+				{Name: "revised", Type: "file", Required: true, Description: "Revised version (PDF)"},
+			},
+			Requires: []string{CapabilityAI},
+		},
+		{
+			ID: "template-check", Name: "Template Check", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/template-check", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "requirements", Type: "string", Required: true, Description: "What the document must contain: a JSON array of names or {name, description} objects, or one per line"},
+			},
+			Requires: []string{CapabilityAI},
+		},
+		{
+			ID: "read-aloud", Name: "Read Aloud", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/read-aloud", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "mode", Type: "string", Default: "document", Enum: []string{"document", "pages"}, Description: "One MP3 for the whole selection, or one per page"},
+				{Name: "pages", Type: "string", Description: "Page selection, e.g. 1-3,5 (default: all pages)"},
+				{Name: "voice", Type: "string", Description: "Voice of the text-to-speech provider, e.g. alloy"},
+			},
+			Requires: []string{CapabilitySpeech},
+		},
+		{
+			ID: "detect-sensitive", Name: "Detect Sensitive Data", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/detect-sensitive", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam},
+		},
+		{
+			ID: "mask-sensitive", Name: "Mask Sensitive Data", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/mask-sensitive", ContentType: multipartForm,
+			Params: []ToolParam{pdfFileParam, {Name: "types", Type: "string", Default: "email,phone,ssn,credit_card"}},
+		},
+		{
+			ID: "redact", Name: "Redact PDF", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/redact", ContentType: multipartForm,
+			Params: []ToolParam{
+				pdfFileParam,
+				{Name: "types", Type: "string", Description: "Comma-separated types to redact (default: all unless terms or ai are given): " + strings.Join(RedactionTypes(), ", ")},
+				{Name: "terms", Type: "string", Description: "Comma-separated text to redact, matched ignoring case"},
+				{Name: "ai", Type: "boolean", Default: false, Description: "Have the AI find names, addresses and other personal information too"},
+				{Name: "color", Type: "string", Default: "#000000", Description: "Color of the redaction boxes"},
+			},
+		},
+		{
+			ID: "auto-fill", Name: "Form Auto-fill", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/auto-fill", ContentType: jsonBody,
+			Params: []ToolParam{
+				{Name: "formFields", Type: "json", Required: true, Description: "Array of field names"},
+				{Name: "userData", Type: "json", Description: "Known values keyed by name"},
+			},
+			Requires: []string{CapabilityAI},
+		},
+		{
+			ID: "search", Name: "Smart Search", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/search", ContentType: jsonBody,
+			Params: []ToolParam{
+				{Name: "query", Type: "string", Required: true},
+				{Name: "documents", Type: "json", Description: "Document texts to search"},
+				{Name: "fileIds", Type: "json", Description: "Stored files to search"},
+			},
+		},
+		{
+			ID: "chat", Name: "Chat with PDF", Category: "ai",
+			Method: "POST", Endpoint: "/api/v1/ai/chat", ContentType: jsonBody,
+			Params: []ToolParam{
+				{Name: "text", Type: "string", Required: true, Description: "Document text"},
+				{Name: "question", Type: "string", Required: true},
+				{Name: "history", Type: "json"},
+			},
+			Requires: []string{CapabilityAI},
+		},
+	}
+}
+
+// standardFontNames returns the StandardFonts keys, sorted
+func standardFontNames() []string {
+	names := make([]string, 0, len(StandardFonts))
+	for name := range StandardFonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxAudioSize is the largest audio file the Whisper API accepts
+const MaxAudioSize = 25 * 1024 * 1024
+
+// AudioContentTypes maps the audio extensions Whisper accepts to MIME types
+var AudioContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".mpga": "audio/mpeg",
+	".mpeg": "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".mp4":  "audio/mp4",
+	".wav":  "audio/wav",
+	".webm": "audio/webm",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// Transcript is the text of an audio recording
+type Transcript struct {
+	Text     string  `json:"text"`
+	Language string  `json:"language,omitempty"`
+	Duration float64 `json:"duration,omitempty"` // seconds
+}
+
+// TranscriptionService turns speech into text with a Whisper-compatible
+// /audio/transcriptions API (OpenAI, Groq, a self-hosted server, ...)
+type TranscriptionService struct {
+	apiURL     string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewTranscriptionService creates a transcription service. apiKey may be
+// empty, in which case the capability is reported unavailable.
+func NewTranscriptionService(apiURL, apiKey, model string) *TranscriptionService {
+	if apiKey != "" {
+		log.Printf("[Transcription] Using %s at %s", model, apiURL)
+	}
+	return &TranscriptionService{
+		apiURL:     apiURL,
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Capability reports whether audio can be transcribed. Safe to call on a
+// nil service.
+func (s *TranscriptionService) Capability() Capability {
+	c := Capability{Name: CapabilityTranscription}
+	switch {
+	case s == nil:
+		c.Reason = "Transcription service failed to start"
+	case s.apiKey == "":
+		c.Reason = "Transcription API key is not configured"
+	default:
+		c.Available = true
+	}
+	return c
+}
+
+// Transcribe sends an audio file to the transcription API. filename's
+// extension tells the API the audio format.
+func (s *TranscriptionService) Transcribe(ctx context.Context, audio []byte, filename string) (*Transcript, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("transcription API key not configured")
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	part.Write(audio)
+	form.WriteField("model", s.model)
+	form.WriteField("response_format", "verbose_json")
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call transcription API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("transcription rate limit exceeded")
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Transcription] API error response: %s", string(respBody))
+		return nil, fmt.Errorf("transcription API error (status %d)", resp.StatusCode)
+	}
+
+	var transcript Transcript
+	if err := json.Unmarshal(respBody, &transcript); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	transcript.Text = strings.TrimSpace(transcript.Text)
+	return &transcript, nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// urlImportTimeout bounds one import, from connecting to the last byte
+	urlImportTimeout = 60 * time.Second
+	// urlImportMaxRedirects bounds the redirects followed
+	urlImportMaxRedirects = 3
+)
+
+// Import errors, reported to the client as they are
+var (
+	ErrImportURLInvalid      = errors.New("only http and https URLs can be imported")
+	ErrImportURLBlocked      = errors.New("URLs on private or internal networks can't be imported")
+	ErrImportTooLarge        = errors.New("remote file exceeds the size limit")
+	ErrImportUnsupportedType = errors.New("remote file is not a PDF or Office document")
+)
+
+// ImportTypes are the file types that can be imported, by extension
+var ImportTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ImportedFile is a file fetched from a URL
+type ImportedFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// URLImporter fetches files from public URLs. Every connection, including
+// those made for redirects, is checked against the address it actually
+// dials, so hostnames resolving (or re-resolving) to internal addresses are
+// refused.
+type URLImporter struct {
+	client *http.Client
+}
+
+// NewURLImporter creates an importer
+func NewURLImporter() *URLImporter {
+	dialer := publicDialer(10*time.Second, ErrImportURLBlocked)
+	transport := &http.Transport{
+		Proxy:                 nil, // a proxy would dial internal hosts for us
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+	}
+	return &URLImporter{client: &http.Client{
+		Transport: transport,
+		Timeout:   urlImportTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= urlImportMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", urlImportMaxRedirects)
+			}
+			return checkImportURL(req.URL)
+		},
+	}}
+}
+
+// Fetch downloads the file at rawURL, refusing files over maxSize bytes
+// and anything but PDFs and Office documents
+func (i *URLImporter) Fetch(ctx context.Context, rawURL string, maxSize int64) (*ImportedFile, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, ErrImportURLInvalid
+	}
+	if err := checkImportURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrImportURLInvalid
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrImportURLBlocked) {
+			return nil, ErrImportURLBlocked
+		}
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote server responded %s", resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, ErrImportTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrImportTooLarge
+	}
+
+	filename := importFilename(resp)
+	ext := strings.ToLower(path.Ext(filename))
+	if _, ok := ImportTypes[ext]; !ok {
+		// Name the file after its content type when the URL doesn't say
+		ext = importExtension(resp.Header.Get("Content-Type"))
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + ext
+	}
+	contentType, ok := ImportTypes[ext]
+	if !ok || !matchesMagic(ext, data) {
+		return nil, ErrImportUnsupportedType
+	}
+
+	return &ImportedFile{Filename: NormalizeFilename(filename), ContentType: contentType, Data: data}, nil
+}
+
+// checkImportURL allows http(s) URLs whose host isn't a literal internal
+// address; names are checked when dialed
+func checkImportURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrImportURLInvalid
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && privateIP(ip) {
+		return ErrImportURLBlocked
+	}
+	if host := strings.ToLower(u.Hostname()); host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return ErrImportURLBlocked
+	}
+	return nil
+}
+
+// importFilename takes the name from Content-Disposition, else the last
+// path segment of the final URL
+func importFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	if name, err := url.PathUnescape(path.Base(resp.Request.URL.Path)); err == nil && name != "/" && name != "." {
+		return name
+	}
+	return "imported"
+}
+
+// importExtension maps a Content-Type header to an import extension
+func importExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for ext, t := range ImportTypes {
+		if t == mediaType {
+			return ext
+		}
+	}
+	return ""
+}
+
+// matchesMagic checks data starts like a file of type ext: PDFs with
+// %PDF, OOXML and OpenDocument with a ZIP header, older Office formats
+// with an OLE2 header
+func matchesMagic(ext string, data []byte) bool {
+	switch ext {
+	case ".pdf":
+		return bytes.HasPrefix(data, []byte("%PDF"))
+	case ".docx", ".xlsx", ".pptx", ".odt":
+		return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+	case ".doc", ".xls", ".ppt":
+		return bytes.HasPrefix(data, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"))
+	}
+	return false
+}
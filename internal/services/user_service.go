@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"time"
 
 	"brainy-pdf/internal/config"
@@ -10,16 +13,24 @@ import (
 	"brainy-pdf/pkg/mongodb"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // UserService handles user-related operations
 type UserService struct {
-	mongoClient *mongodb.Client
+	mongoClient   *mongodb.Client
+	notifications *NotificationService
+	activity      *ActivityService
 }
 
 // NewUserService creates a new user service
 func NewUserService(mongoClient *mongodb.Client) *UserService {
-	return &UserService{mongoClient: mongoClient}
+	return &UserService{
+		mongoClient:   mongoClient,
+		notifications: NewNotificationService(mongoClient),
+		activity:      NewActivityService(mongoClient),
+	}
 }
 
 // CreateOrUpdateUser creates a new user or updates existing one after OAuth
@@ -101,6 +112,19 @@ func (s *UserService) GetUserByFirebaseUID(ctx context.Context, firebaseUID stri
 	return &user, nil
 }
 
+// QueueLane returns the job queue lane of the user's plan; anonymous and
+// unknown users get the standard lane
+func (s *UserService) QueueLane(ctx context.Context, firebaseUID string) string {
+	if firebaseUID == "" {
+		return QueueLaneStandard
+	}
+	user, err := s.GetUserByFirebaseUID(ctx, firebaseUID)
+	if err != nil {
+		return QueueLaneStandard
+	}
+	return QueueLaneForPlan(user.Plan)
+}
+
 // GetUserByID retrieves a user by MongoDB ID
 func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
 	objID, err := primitive.ObjectIDFromHex(userID)
@@ -119,7 +143,19 @@ func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.U
 	return &user, nil
 }
 
-// UpdateStorageUsed updates the user's storage usage
+// GetUserByEmail retrieves a user by email address
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := s.mongoClient.Users().FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	return &user, nil
+}
+
+// UpdateStorageUsed updates the user's storage usage, notifying them when
+// it crosses a warning threshold
 func (s *UserService) UpdateStorageUsed(ctx context.Context, firebaseUID string, delta int64) error {
 	collection := s.mongoClient.Users()
 
@@ -128,22 +164,205 @@ func (s *UserService) UpdateStorageUsed(ctx context.Context, firebaseUID string,
 		"$set": bson.M{"updatedAt": time.Now()},
 	}
 
-	_, err := collection.UpdateOne(ctx, bson.M{"firebaseUid": firebaseUID}, update)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var user models.User
+	err := collection.FindOneAndUpdate(ctx, bson.M{"firebaseUid": firebaseUID}, update, opts).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update storage: %w", err)
 	}
 
+	if delta > 0 {
+		s.warnStorageUsage(ctx, &user, user.StorageUsed-delta)
+	}
 	return nil
 }
 
-// CheckStorageLimit checks if user has enough storage
+// storageWarnings are the usage levels, in percent of the storage limit,
+// at which users are notified
+var storageWarnings = []int64{80, 95, 100}
+
+// warnStorageUsage notifies the user of the highest warning level their
+// usage crossed on its way up from previous. Usage only crosses a level again
+// after dropping below it, so each warning is sent once per crossing.
+func (s *UserService) warnStorageUsage(ctx context.Context, user *models.User, previous int64) {
+	if user.StorageLimit <= 0 {
+		return
+	}
+	var crossed int64
+	for _, pct := range storageWarnings {
+		threshold := user.StorageLimit * pct / 100
+		if previous < threshold && user.StorageUsed >= threshold {
+			crossed = pct
+		}
+	}
+	if crossed == 0 {
+		return
+	}
+
+	title := fmt.Sprintf("Storage %d%% full", crossed)
+	message := fmt.Sprintf("You are using %s of your %s storage. Delete large files or upgrade your plan to keep uploading.",
+		formatBytes(user.StorageUsed), formatBytes(user.StorageLimit))
+	if crossed == 100 {
+		title = "Storage limit reached"
+		if grace := config.GetStorageGraceForPlan(user.Plan, user.StorageLimit); grace > 0 {
+			message = fmt.Sprintf("You are over your %s storage limit. Uploads continue for another %s; delete large files or upgrade your plan.",
+				formatBytes(user.StorageLimit), formatBytes(user.StorageLimit+grace-user.StorageUsed))
+		}
+	}
+	if err := s.notifications.CreateNotification(ctx, UserRecipient(user.ID.Hex()), title, message, models.NotificationTypeWarning); err != nil {
+		log.Printf("[Storage] Failed to warn %s about storage usage: %v", user.FirebaseUID, err)
+	}
+}
+
+// formatBytes renders a size for messages, e.g. "1.5 GB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// CheckStorageLimit checks if user has enough storage. On paid plans, the
+// upload that crosses the limit may go over it by the plan's grace
+// allowance; once usage is over the limit, nothing more fits.
 func (s *UserService) CheckStorageLimit(ctx context.Context, firebaseUID string, fileSize int64) (bool, error) {
 	user, err := s.GetUserByFirebaseUID(ctx, firebaseUID)
 	if err != nil {
 		return false, err
 	}
 
-	return user.StorageUsed+fileSize <= user.StorageLimit, nil
+	limit := user.StorageLimit
+	if user.StorageUsed < user.StorageLimit {
+		limit += config.GetStorageGraceForPlan(user.Plan, user.StorageLimit)
+	}
+	return user.StorageUsed+fileSize <= limit, nil
+}
+
+// StorageBreakdown splits the user's storage usage by kind of file, with
+// their ten largest files
+func (s *UserService) StorageBreakdown(ctx context.Context, firebaseUID string) (*models.StorageBreakdown, error) {
+	user, err := s.GetUserByFirebaseUID(ctx, firebaseUID)
+	if err != nil {
+		return nil, err
+	}
+	b := &models.StorageBreakdown{
+		Used:  user.StorageUsed,
+		Limit: user.StorageLimit,
+		Grace: config.GetStorageGraceForPlan(user.Plan, user.StorageLimit),
+	}
+
+	if b.Library, err = s.sumStorage(ctx, "documents", bson.M{"ownerUid": firebaseUID, "isTemporary": false, "source": models.DocumentSourceLibrary}, "$size"); err != nil {
+		return nil, err
+	}
+	// Library documents not yet moved out of the legacy collection
+	legacy, err := s.sumStorage(ctx, legacyLibraryCollection, bson.M{"userId": firebaseUID, "migratedAt": notMigrated}, "$size")
+	if err != nil {
+		return nil, err
+	}
+	b.Library.Bytes += legacy.Bytes
+	b.Library.Files += legacy.Files
+	if b.Outputs, err = s.sumStorage(ctx, "documents", bson.M{"ownerUid": firebaseUID, "isTemporary": false, "source": bson.M{"$ne": models.DocumentSourceLibrary}}, "$size"); err != nil {
+		return nil, err
+	}
+	if b.VoiceNotes, err = s.sumStorage(ctx, "library_notes", bson.M{"userId": firebaseUID, "audioSize": bson.M{"$gt": 0}}, "$audioSize"); err != nil {
+		return nil, err
+	}
+	if b.Largest, err = s.LargestFiles(ctx, firebaseUID, 10); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// sumStorage totals the size field of the matching files in a collection
+func (s *UserService) sumStorage(ctx context.Context, collection string, match bson.M, sizeField string) (models.StorageCategory, error) {
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":   nil,
+			"bytes": bson.M{"$sum": sizeField},
+			"files": bson.M{"$sum": 1},
+		}},
+	}
+	cursor, err := s.mongoClient.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.StorageCategory{}, fmt.Errorf("failed to aggregate %s storage: %w", collection, err)
+	}
+	var result []models.StorageCategory
+	if err := cursor.All(ctx, &result); err != nil {
+		return models.StorageCategory{}, fmt.Errorf("failed to decode %s storage: %w", collection, err)
+	}
+	if len(result) == 0 {
+		return models.StorageCategory{}, nil
+	}
+	return result[0], nil
+}
+
+// LargestFiles returns the user's largest files counting toward storage,
+// the ones to delete first to free space
+func (s *UserService) LargestFiles(ctx context.Context, firebaseUID string, limit int) ([]models.StoredFile, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "size", Value: -1}}).SetLimit(int64(limit))
+	files := []models.StoredFile{}
+
+	var library []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		FileName  string             `bson:"fileName"`
+		Size      int64              `bson:"size"`
+		CreatedAt time.Time          `bson:"createdAt"`
+	}
+	cursor, err := s.mongoClient.Collection(legacyLibraryCollection).Find(ctx, bson.M{"userId": firebaseUID, "migratedAt": notMigrated}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list library documents: %w", err)
+	}
+	if err := cursor.All(ctx, &library); err != nil {
+		return nil, fmt.Errorf("failed to decode library documents: %w", err)
+	}
+	for _, item := range library {
+		files = append(files, models.StoredFile{ID: item.ID.Hex(), Kind: models.StoredFileLibrary, FileName: item.FileName, Size: item.Size, CreatedAt: item.CreatedAt})
+	}
+
+	var docs []models.Document
+	cursor, err = s.mongoClient.Documents().Find(ctx, bson.M{"ownerUid": firebaseUID, "isTemporary": false}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode files: %w", err)
+	}
+	for _, doc := range docs {
+		kind := models.StoredFileSaved
+		if doc.Source == models.DocumentSourceLibrary {
+			kind = models.StoredFileLibrary
+		}
+		files = append(files, models.StoredFile{ID: doc.ID.Hex(), Kind: kind, FileName: doc.OriginalName, Size: doc.Size, CreatedAt: doc.CreatedAt})
+	}
+
+	noteOpts := options.Find().SetSort(bson.D{{Key: "audioSize", Value: -1}}).SetLimit(int64(limit))
+	var notes []models.LibraryNote
+	cursor, err = s.mongoClient.Collection("library_notes").Find(ctx, bson.M{"userId": firebaseUID, "audioSize": bson.M{"$gt": 0}}, noteOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voice notes: %w", err)
+	}
+	if err := cursor.All(ctx, &notes); err != nil {
+		return nil, fmt.Errorf("failed to decode voice notes: %w", err)
+	}
+	for _, note := range notes {
+		files = append(files, models.StoredFile{ID: note.ID, Kind: models.StoredFileVoiceNote, FileName: note.AudioName, Size: note.AudioSize, DocumentID: note.DocumentID, CreatedAt: note.CreatedAt})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
 }
 
 // UpdatePlan updates the user's subscription plan
@@ -152,10 +371,28 @@ func (s *UserService) UpdatePlan(ctx context.Context, userID, plan string) error
 	if err != nil {
 		return fmt.Errorf("invalid user ID: %w", err)
 	}
+	_, err = s.setPlan(ctx, bson.M{"_id": objID}, plan)
+	return err
+}
+
+// ReplacePlan moves a user from plan from to plan, leaving users on any
+// other plan alone, and reports whether it changed anything. The check and
+// the write are one update, so a plan bought in the meantime is never
+// overwritten.
+func (s *UserService) ReplacePlan(ctx context.Context, userID, from, plan string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID: %w", err)
+	}
+	return s.setPlan(ctx, bson.M{"_id": objID, "plan": from}, plan)
+}
+
+// setPlan sets the plan and its storage limit on the user matching filter
+func (s *UserService) setPlan(ctx context.Context, filter bson.M, plan string) (bool, error) {
 
 	// Set storage limit based on plan from config
 	storageLimit := config.GetStorageLimitForPlan(plan)
-	if _, ok := config.Plans[plan]; !ok {
+	if !config.IsPlan(plan) {
 		plan = "free"
 		storageLimit = config.GetStorageLimitForPlan("free")
 	}
@@ -170,41 +407,60 @@ func (s *UserService) UpdatePlan(ctx context.Context, userID, plan string) error
 		},
 	}
 
-	_, err = collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	var previous models.User
+	err := collection.FindOneAndUpdate(ctx, filter, update).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update plan: %w", err)
+		return false, fmt.Errorf("failed to update plan: %w", err)
 	}
 
-	return nil
-}
-
-// RecalculateUserStorage recalculates and updates storage usage for a specific user by Firebase UID
-func (s *UserService) RecalculateUserStorage(ctx context.Context, firebaseUID string) error {
-	// Aggregate file sizes from library (library items use Firebase UID as userId)
-	pipeline := []bson.M{
-		{"$match": bson.M{"userId": firebaseUID}},
-		{"$group": bson.M{
-			"_id": nil,
-			"totalSize": bson.M{"$sum": "$size"},
-		}},
+	if previous.Plan != plan {
+		s.activity.Record(ctx, models.ActivityEntry{
+			UserID:  previous.FirebaseUID,
+			Action:  models.ActivityPlanChanged,
+			Details: map[string]interface{}{"from": previous.Plan, "to": plan},
+		})
 	}
+	return true, nil
+}
 
-	cursor, err := s.mongoClient.Collection("library").Aggregate(ctx, pipeline)
-	if err != nil {
-		return fmt.Errorf("failed to aggregate storage: %w", err)
+// SetFilenameTemplate sets the user's default output filename template;
+// an empty template restores the built-in names
+func (s *UserService) SetFilenameTemplate(ctx context.Context, firebaseUID, tmpl string) error {
+	update := bson.M{"$set": bson.M{"filenameTemplate": tmpl, "updatedAt": time.Now()}}
+	if tmpl == "" {
+		update = bson.M{"$unset": bson.M{"filenameTemplate": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	} else if err := ValidateFilenameTemplate(tmpl); err != nil {
+		return err
 	}
-	defer cursor.Close(ctx)
 
-	var result []struct {
-		TotalSize int64 `bson:"totalSize"`
-	}
-	if err := cursor.All(ctx, &result); err != nil {
-		return fmt.Errorf("failed to decode aggregation result: %w", err)
+	if _, err := s.mongoClient.Users().UpdateOne(ctx, bson.M{"firebaseUid": firebaseUID}, update); err != nil {
+		return fmt.Errorf("failed to update filename template: %w", err)
 	}
+	return nil
+}
 
+// RecalculateUserStorage recalculates and updates storage usage for a specific user by Firebase UID
+func (s *UserService) RecalculateUserStorage(ctx context.Context, firebaseUID string) error {
+	// Kept files, library documents not yet moved out of the legacy
+	// collection, and voice notes
 	var totalSize int64
-	if len(result) > 0 {
-		totalSize = result[0].TotalSize
+	for _, part := range []struct {
+		collection string
+		match      bson.M
+		sizeField  string
+	}{
+		{"documents", bson.M{"ownerUid": firebaseUID, "isTemporary": false}, "$size"},
+		{legacyLibraryCollection, bson.M{"userId": firebaseUID, "migratedAt": notMigrated}, "$size"},
+		{"library_notes", bson.M{"userId": firebaseUID, "audioSize": bson.M{"$gt": 0}}, "$audioSize"},
+	} {
+		used, err := s.sumStorage(ctx, part.collection, part.match, part.sizeField)
+		if err != nil {
+			return err
+		}
+		totalSize += used.Bytes
 	}
 
 	// Update user by Firebase UID
@@ -215,7 +471,7 @@ func (s *UserService) RecalculateUserStorage(ctx context.Context, firebaseUID st
 		},
 	}
 
-	_, err = s.mongoClient.Users().UpdateOne(ctx, bson.M{"firebaseUid": firebaseUID}, update)
+	_, err := s.mongoClient.Users().UpdateOne(ctx, bson.M{"firebaseUid": firebaseUID}, update)
 	if err != nil {
 		return fmt.Errorf("failed to update user storage: %w", err)
 	}
@@ -232,10 +488,7 @@ func (s *UserService) CheckLimit(ctx context.Context, firebaseUID string, featur
 
 	// In real-world, we'd check if LastReset was > 30 days ago and reset counts here.
 
-	limits, ok := config.Plans[user.Plan]
-	if !ok {
-		limits = config.Plans["free"]
-	}
+	limits := config.GetPlanLimits(user.Plan)
 
 	switch feature {
 	case "ai_chat":
@@ -268,6 +521,79 @@ func (s *UserService) IncrementCounter(ctx context.Context, firebaseUID string,
 	return err
 }
 
+// QuotaUsage is one metered feature's limit and usage
+type QuotaUsage struct {
+	Limit     int64 `json:"limit"`
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+	Unlimited bool  `json:"unlimited"`
+}
+
+func newQuotaUsage(limit, used int64) QuotaUsage {
+	q := QuotaUsage{Limit: limit, Used: used, Unlimited: limit >= config.UnlimitedQuota}
+	if q.Unlimited {
+		q.Limit = -1
+		q.Remaining = -1
+		return q
+	}
+	q.Remaining = limit - used
+	if q.Remaining < 0 {
+		q.Remaining = 0
+	}
+	return q
+}
+
+// UsageLimits summarizes a requester's plan limits and what is left of them.
+// Unlimited quotas report -1 for limit and remaining.
+type UsageLimits struct {
+	Plan          string     `json:"plan"`
+	Authenticated bool       `json:"authenticated"`
+	MaxFileSize   int64      `json:"maxFileSize"`
+	Operations    QuotaUsage `json:"operations"`
+	AIChats       QuotaUsage `json:"aiChats"`
+	Storage       QuotaUsage `json:"storage"`
+	StorageGrace  int64      `json:"storageGrace"` // bytes that may be used past the storage limit
+	ShareLinks    QuotaUsage `json:"shareLinks"`
+	RetentionDays int        `json:"retentionDays"`
+}
+
+// GetUsageLimits returns limits and remaining quota for a user, or the free
+// plan's limits for anonymous requesters (firebaseUID == "")
+func (s *UserService) GetUsageLimits(ctx context.Context, firebaseUID string) (*UsageLimits, error) {
+	if firebaseUID == "" {
+		limits := config.GetPlanLimits("free")
+		return &UsageLimits{
+			Plan:          "free",
+			MaxFileSize:   limits.MaxFileSize,
+			Operations:    newQuotaUsage(int64(limits.ToolkitOpsLimit), 0),
+			AIChats:       newQuotaUsage(int64(limits.AIChatsLimit), 0),
+			Storage:       newQuotaUsage(0, 0), // anonymous uploads are temporary
+			ShareLinks:    newQuotaUsage(0, 0),
+			RetentionDays: limits.RetentionDays,
+		}, nil
+	}
+
+	user, err := s.GetUserByFirebaseUID(ctx, firebaseUID)
+	if err != nil {
+		return nil, err
+	}
+	limits := config.GetPlanLimits(user.Plan)
+
+	activeLinks, _ := s.mongoClient.Collection("shares").CountDocuments(ctx, bson.M{"creatorId": firebaseUID, "expiresAt": bson.M{"$gt": time.Now()}})
+
+	return &UsageLimits{
+		Plan:          user.Plan,
+		Authenticated: true,
+		MaxFileSize:   limits.MaxFileSize,
+		Operations:    newQuotaUsage(int64(limits.ToolkitOpsLimit), int64(user.ToolkitCount)),
+		AIChats:       newQuotaUsage(int64(limits.AIChatsLimit), int64(user.AIChatCount)),
+		Storage:       newQuotaUsage(limits.StorageLimit, user.StorageUsed),
+		StorageGrace:  config.GetStorageGraceForPlan(user.Plan, limits.StorageLimit),
+		ShareLinks:    newQuotaUsage(int64(limits.MaxActiveLinks), activeLinks),
+		RetentionDays: limits.RetentionDays,
+	}, nil
+}
+
 // GetUserStats returns statistics for a user
 func (s *UserService) GetUserStats(ctx context.Context, firebaseUID string) (map[string]int64, error) {
 	// Aggregate from shares collection
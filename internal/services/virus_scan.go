@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	"brainy-pdf/pkg/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// virusScanConcurrency bounds the scans running at once per instance
+	virusScanConcurrency = 4
+	// virusScanStale is how long a scan may stay pending before it is
+	// queued again, e.g. after the instance running it stopped
+	virusScanStale = 15 * time.Minute
+	// clamavChunkSize is the size of each INSTREAM chunk sent to clamd
+	clamavChunkSize = 64 * 1024
+)
+
+// ErrScanPending means a file hasn't been scanned clean yet
+var ErrScanPending = errors.New("virus scan pending")
+
+// ErrFileInfected means the virus scanner found malware in a file
+var ErrFileInfected = errors.New("file is infected")
+
+// VirusScanner checks file contents for malware
+type VirusScanner interface {
+	Name() string
+	// Scan reads the file and returns the name of the malware found, or
+	// "" when it is clean
+	Scan(ctx context.Context, r io.Reader) (string, error)
+}
+
+// NewVirusScanner builds the configured scanner: "clamav" (a clamd daemon
+// at address) or "" for none
+func NewVirusScanner(name, address string, timeout time.Duration) (VirusScanner, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "clamav":
+		if address == "" {
+			return nil, fmt.Errorf("clamav scanner requires an address")
+		}
+		return NewClamAVScanner(address, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown virus scanner %q (supported: clamav)", name)
+	}
+}
+
+// ClamAVScanner streams files to clamd over TCP with the INSTREAM command
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner for the clamd daemon at host:port
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	return &ClamAVScanner{address: address, timeout: timeout}
+}
+
+// Name implements VirusScanner
+func (s *ClamAVScanner) Name() string {
+	return "clamav"
+}
+
+// Scan implements VirusScanner. Files larger than clamd's StreamMaxLength
+// fail with an error rather than passing unscanned.
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (string, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("failed to start scan: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream
+	buf := make([]byte, clamavChunkSize)
+	var size [4]byte
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return "", fmt.Errorf("failed to send file to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return "", fmt.Errorf("failed to send file to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", fmt.Errorf("failed to read file: %w", readErr)
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return "", fmt.Errorf("failed to send file to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseClamAVReply(strings.TrimRight(reply, "\x00"))
+}
+
+// parseClamAVReply reads "stream: OK", "stream: <name> FOUND" or
+// "<message> ERROR"
+func parseClamAVReply(reply string) (string, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// VirusScanService scans stored files in the background after upload and
+// records the result on the document. Public share links are refused until
+// a file has scanned clean. A nil service, used when no scanner is
+// configured, scans nothing and allows every file.
+type VirusScanService struct {
+	mongoClient *mongodb.Client
+	router      *StorageRouter
+	scanner     VirusScanner
+	timeout     time.Duration
+	slots       chan struct{}
+}
+
+// NewVirusScanService creates a scan service. It returns nil when scanner
+// is nil.
+func NewVirusScanService(mongoClient *mongodb.Client, router *StorageRouter, scanner VirusScanner, timeout time.Duration) *VirusScanService {
+	if scanner == nil {
+		return nil
+	}
+	return &VirusScanService{
+		mongoClient: mongoClient,
+		router:      router,
+		scanner:     scanner,
+		timeout:     timeout,
+		slots:       make(chan struct{}, virusScanConcurrency),
+	}
+}
+
+// Enabled reports whether files are scanned
+func (s *VirusScanService) Enabled() bool {
+	return s != nil
+}
+
+// AfterUpload implements UploadHook by queueing a scan of the new file
+func (s *VirusScanService) AfterUpload(doc models.Document) {
+	s.queue(doc)
+}
+
+// Check reports whether doc may be shared publicly: nil once it scanned
+// clean, ErrFileInfected, or ErrScanPending while its scan is outstanding.
+// Files never scanned, or whose scan failed or stalled, are queued again.
+func (s *VirusScanService) Check(doc *models.Document) error {
+	if !s.Enabled() || doc == nil {
+		return nil
+	}
+	scan := doc.VirusScan
+	switch {
+	case scan == nil:
+	case scan.Status == models.VirusScanClean:
+		return nil
+	case scan.Status == models.VirusScanInfected:
+		return ErrFileInfected
+	case scan.Status == models.VirusScanPending && time.Since(scan.RequestedAt) < virusScanStale:
+		return ErrScanPending
+	}
+	s.queue(*doc)
+	return ErrScanPending
+}
+
+// queue marks doc pending and scans it in the background
+func (s *VirusScanService) queue(doc models.Document) {
+	if !s.Enabled() {
+		return
+	}
+	pending := models.VirusScan{
+		Status:      models.VirusScanPending,
+		Scanner:     s.scanner.Name(),
+		RequestedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.mongoClient.Documents().UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"virusScan": pending}}); err != nil {
+		log.Printf("[VirusScan] Failed to queue %s: %v", doc.ID.Hex(), err)
+		return
+	}
+	go s.scan(doc, pending)
+}
+
+// scan runs one queued scan and stores its result
+func (s *VirusScanService) scan(doc models.Document, result models.VirusScan) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	signature, err := s.scanDocument(ctx, &doc)
+	now := time.Now()
+	result.ScannedAt = &now
+	switch {
+	case err != nil:
+		result.Status = models.VirusScanError
+		result.Error = err.Error()
+		log.Printf("[VirusScan] Failed to scan %s: %v", doc.ID.Hex(), err)
+	case signature != "":
+		result.Status = models.VirusScanInfected
+		result.Signature = signature
+		log.Printf("[VirusScan] %s (owner %s) is infected: %s", doc.ID.Hex(), doc.OwnerUID, signature)
+	default:
+		result.Status = models.VirusScanClean
+	}
+
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	if _, err := s.mongoClient.Documents().UpdateOne(saveCtx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"virusScan": result}}); err != nil {
+		log.Printf("[VirusScan] Failed to save result for %s: %v", doc.ID.Hex(), err)
+	}
+}
+
+// scanDocument streams the stored file to the scanner
+func (s *VirusScanService) scanDocument(ctx context.Context, doc *models.Document) (string, error) {
+	client, err := s.router.ForDocument(ctx, doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve storage: %w", err)
+	}
+	bucket, objectKey := doc.Location()
+	object, _, err := client.OpenObject(ctx, bucket, objectKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer object.Close()
+
+	return s.scanner.Scan(ctx, object)
+}
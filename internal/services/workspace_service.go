@@ -0,0 +1,365 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"brainy-pdf/internal/models"
+	minioPkg "brainy-pdf/pkg/minio"
+	"brainy-pdf/pkg/mongodb"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Workspace errors surfaced to handlers
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceConflict = errors.New("workspace was modified concurrently")
+	ErrNothingToUndo     = errors.New("nothing to undo")
+	ErrNothingToRedo     = errors.New("nothing to redo")
+	ErrInvalidEdit       = errors.New("invalid edit")
+)
+
+// workspaceTTL is how long an idle workspace is kept
+const workspaceTTL = 24 * time.Hour
+
+// maxWorkspaceEdits bounds the edit list of one session
+const maxWorkspaceEdits = 500
+
+// WorkspaceService holds multi-step editing sessions. The uploaded PDF is
+// stored once; edits are recorded as a list and only rendered on commit.
+type WorkspaceService struct {
+	mongoClient *mongodb.Client
+	minioClient *minioPkg.Client
+	pdfService  *PDFService
+}
+
+// NewWorkspaceService creates a workspace service
+func NewWorkspaceService(mongoClient *mongodb.Client, minioClient *minioPkg.Client, pdfService *PDFService) *WorkspaceService {
+	return &WorkspaceService{
+		mongoClient: mongoClient,
+		minioClient: minioClient,
+		pdfService:  pdfService,
+	}
+}
+
+func (s *WorkspaceService) collection() *mongo.Collection {
+	return s.mongoClient.Collection("workspaces")
+}
+
+// Create stores the PDF and opens a session over it
+func (s *WorkspaceService) Create(ctx context.Context, userID, filename string, in PDFReader) (*models.Workspace, error) {
+	pageCount, err := s.pdfService.GetPageCount(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+
+	id := uuid.New().String()
+	key := fmt.Sprintf("workspaces/%s/source.pdf", id)
+	if _, err := s.minioClient.UploadFile(ctx, s.minioClient.GetBucketTemp(), key, readSeeker(in), in.Size(), "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to store workspace file: %w", err)
+	}
+
+	now := time.Now()
+	ws := &models.Workspace{
+		ID:          id,
+		UserID:      userID,
+		Filename:    NormalizeFilename(filename),
+		SourceKey:   key,
+		SourcePages: pageCount,
+		Size:        in.Size(),
+		Edits:       []models.WorkspaceEdit{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(workspaceTTL),
+	}
+	if _, err := s.collection().InsertOne(ctx, ws); err != nil {
+		s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketTemp(), key)
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return ws, nil
+}
+
+// Get returns the session if it belongs to userID. Sessions created
+// anonymously are addressable by anyone holding their ID.
+func (s *WorkspaceService) Get(ctx context.Context, id, userID string) (*models.Workspace, error) {
+	var ws models.Workspace
+	if err := s.collection().FindOne(ctx, bson.M{"_id": id}).Decode(&ws); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	if ws.UserID != "" && ws.UserID != userID {
+		return nil, ErrWorkspaceNotFound
+	}
+	if time.Now().After(ws.ExpiresAt) {
+		return nil, ErrWorkspaceNotFound
+	}
+	return &ws, nil
+}
+
+// State replays the applied edits into the resulting page list
+func (s *WorkspaceService) State(ws *models.Workspace) (*models.WorkspaceState, error) {
+	pages, err := ApplyWorkspaceEdits(ws.SourcePages, ws.Edits[:ws.Cursor])
+	if err != nil {
+		return nil, err
+	}
+	return &models.WorkspaceState{
+		Workspace: ws,
+		Pages:     pages,
+		CanUndo:   ws.Cursor > 0,
+		CanRedo:   ws.Cursor < len(ws.Edits),
+	}, nil
+}
+
+// AddEdit validates the edit against the current pages and appends it,
+// discarding anything that could have been redone
+func (s *WorkspaceService) AddEdit(ctx context.Context, id, userID string, edit models.WorkspaceEdit) (*models.WorkspaceState, error) {
+	return s.update(ctx, id, userID, func(ws *models.Workspace) error {
+		if ws.Cursor >= maxWorkspaceEdits {
+			return fmt.Errorf("%w: workspace is limited to %d edits; commit to continue", ErrInvalidEdit, maxWorkspaceEdits)
+		}
+		pages, err := ApplyWorkspaceEdits(ws.SourcePages, ws.Edits[:ws.Cursor])
+		if err != nil {
+			return err
+		}
+		if _, err := applyWorkspaceEdit(pages, edit); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEdit, err)
+		}
+		ws.Edits = append(ws.Edits[:ws.Cursor], edit)
+		ws.Cursor++
+		return nil
+	})
+}
+
+// Undo steps back one edit
+func (s *WorkspaceService) Undo(ctx context.Context, id, userID string) (*models.WorkspaceState, error) {
+	return s.update(ctx, id, userID, func(ws *models.Workspace) error {
+		if ws.Cursor == 0 {
+			return ErrNothingToUndo
+		}
+		ws.Cursor--
+		return nil
+	})
+}
+
+// Redo re-applies the next undone edit
+func (s *WorkspaceService) Redo(ctx context.Context, id, userID string) (*models.WorkspaceState, error) {
+	return s.update(ctx, id, userID, func(ws *models.Workspace) error {
+		if ws.Cursor >= len(ws.Edits) {
+			return ErrNothingToRedo
+		}
+		ws.Cursor++
+		return nil
+	})
+}
+
+// update applies fn and saves the session, failing with ErrWorkspaceConflict
+// if another request changed it in between
+func (s *WorkspaceService) update(ctx context.Context, id, userID string, fn func(ws *models.Workspace) error) (*models.WorkspaceState, error) {
+	ws, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(ws); err != nil {
+		return nil, err
+	}
+
+	version := ws.Version
+	now := time.Now()
+	ws.Version++
+	ws.UpdatedAt = now
+	ws.ExpiresAt = now.Add(workspaceTTL)
+
+	res, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": ws.ID, "version": version},
+		bson.M{"$set": bson.M{
+			"edits":     ws.Edits,
+			"cursor":    ws.Cursor,
+			"version":   ws.Version,
+			"updatedAt": ws.UpdatedAt,
+			"expiresAt": ws.ExpiresAt,
+		}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save workspace: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrWorkspaceConflict
+	}
+	return s.State(ws)
+}
+
+// Render produces the edited PDF in a single pass: pages are collected in
+// their final order, then each rotation group is applied once
+func (s *WorkspaceService) Render(ctx context.Context, ws *models.Workspace) ([]byte, error) {
+	pages, err := ApplyWorkspaceEdits(ws.SourcePages, ws.Edits[:ws.Cursor])
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.minioClient.DownloadFile(ctx, s.minioClient.GetBucketTemp(), ws.SourceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workspace file: %w", err)
+	}
+
+	order := make([]int, len(pages))
+	identity := len(pages) == ws.SourcePages
+	for i, p := range pages {
+		order[i] = p.Source
+		if p.Source != i+1 {
+			identity = false
+		}
+	}
+	if !identity {
+		if data, err = s.pdfService.OrganizePages(ctx, bytes.NewReader(data), order); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := map[int][]string{}
+	for i, p := range pages {
+		if p.Rotation != 0 {
+			groups[p.Rotation] = append(groups[p.Rotation], strconv.Itoa(i+1))
+		}
+	}
+	angles := make([]int, 0, len(groups))
+	for angle := range groups {
+		angles = append(angles, angle)
+	}
+	sort.Ints(angles)
+	for _, angle := range angles {
+		result, err := s.pdfService.Rotate(ctx, bytes.NewReader(data), strings.Join(groups[angle], ","), angle)
+		if err != nil {
+			return nil, err
+		}
+		data = result.Data
+	}
+
+	return data, nil
+}
+
+// Delete removes the session and its stored file
+func (s *WorkspaceService) Delete(ctx context.Context, id, userID string) error {
+	ws, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	s.remove(ctx, ws)
+	return nil
+}
+
+func (s *WorkspaceService) remove(ctx context.Context, ws *models.Workspace) {
+	if err := s.minioClient.DeleteFile(ctx, s.minioClient.GetBucketTemp(), ws.SourceKey); err != nil {
+		log.Printf("[Workspace] failed to delete %s: %v", ws.SourceKey, err)
+	}
+	s.collection().DeleteOne(ctx, bson.M{"_id": ws.ID})
+}
+
+// CleanupExpired removes sessions idle past their TTL
+func (s *WorkspaceService) CleanupExpired(ctx context.Context) (int, error) {
+	cursor, err := s.collection().Find(ctx, bson.M{"expiresAt": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	removed := 0
+	for cursor.Next(ctx) {
+		var ws models.Workspace
+		if err := cursor.Decode(&ws); err != nil {
+			continue
+		}
+		s.remove(ctx, &ws)
+		removed++
+	}
+	return removed, cursor.Err()
+}
+
+// ApplyWorkspaceEdits replays edits over a document of pageCount pages
+func ApplyWorkspaceEdits(pageCount int, edits []models.WorkspaceEdit) ([]models.WorkspacePage, error) {
+	pages := make([]models.WorkspacePage, pageCount)
+	for i := range pages {
+		pages[i] = models.WorkspacePage{Source: i + 1}
+	}
+	for i, edit := range edits {
+		var err error
+		if pages, err = applyWorkspaceEdit(pages, edit); err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i+1, err)
+		}
+	}
+	return pages, nil
+}
+
+func applyWorkspaceEdit(pages []models.WorkspacePage, edit models.WorkspaceEdit) ([]models.WorkspacePage, error) {
+	selected, err := workspaceSelection(edit.Pages, len(pages), edit.Type != models.WorkspaceEditReorder)
+	if err != nil {
+		return nil, err
+	}
+
+	switch edit.Type {
+	case models.WorkspaceEditRotate:
+		if edit.Angle != 90 && edit.Angle != 180 && edit.Angle != 270 {
+			return nil, fmt.Errorf("angle must be 90, 180, or 270 degrees")
+		}
+		out := append([]models.WorkspacePage(nil), pages...)
+		for p := range selected {
+			out[p-1].Rotation = (out[p-1].Rotation + edit.Angle) % 360
+		}
+		return out, nil
+
+	case models.WorkspaceEditDelete:
+		if len(selected) == len(pages) {
+			return nil, fmt.Errorf("cannot delete every page")
+		}
+		out := make([]models.WorkspacePage, 0, len(pages)-len(selected))
+		for i, p := range pages {
+			if !selected[i+1] {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+
+	case models.WorkspaceEditReorder:
+		if len(edit.Order) != len(pages) {
+			return nil, fmt.Errorf("order must list all %d pages", len(pages))
+		}
+		if _, err := workspaceSelection(edit.Order, len(pages), true); err != nil {
+			return nil, err
+		}
+		out := make([]models.WorkspacePage, len(pages))
+		for i, p := range edit.Order {
+			out[i] = pages[p-1]
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("unknown edit type %q (supported: rotate, delete, reorder)", edit.Type)
+	}
+}
+
+// workspaceSelection checks page positions against pageCount
+func workspaceSelection(pages []int, pageCount int, required bool) (map[int]bool, error) {
+	if required && len(pages) == 0 {
+		return nil, fmt.Errorf("pages required")
+	}
+	selected := make(map[int]bool, len(pages))
+	for _, p := range pages {
+		if p < 1 || p > pageCount {
+			return nil, fmt.Errorf("page %d out of range (document has %d pages)", p, pageCount)
+		}
+		if selected[p] {
+			return nil, fmt.Errorf("page %d listed more than once", p)
+		}
+		selected[p] = true
+	}
+	return selected, nil
+}
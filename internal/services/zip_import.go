@@ -0,0 +1,175 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// ZipMaxEntries bounds the files (and directories) in one archive
+	ZipMaxEntries = 500
+	// ZipMaxTotalSize bounds the bytes extracted from one archive
+	ZipMaxTotalSize = 500 * 1024 * 1024
+	// zipMaxRatio is the highest compression ratio taken at face value;
+	// documents rarely pass 20:1, bombs go well past 1000:1
+	zipMaxRatio = 100
+	// zipRatioFloor is the size below which the ratio isn't checked, so
+	// tiny highly-compressible files aren't refused
+	zipRatioFloor = 1024 * 1024
+)
+
+// Archive errors. ErrZipTooLarge stops the extraction; entries read before
+// it keep their results.
+var (
+	ErrInvalidZip       = errors.New("invalid ZIP archive")
+	ErrZipTooLarge      = fmt.Errorf("ZIP archive extracts to more than %dMB", ZipMaxTotalSize/(1024*1024))
+	ErrZipEntryTooLarge = errors.New("file exceeds the size limit")
+)
+
+// IsZipFilename reports whether an upload should be treated as an archive
+func IsZipFilename(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".zip")
+}
+
+// ZipEntry is a regular file inside an uploaded archive
+type ZipEntry struct {
+	Name string // Path inside the archive, slash separated
+	Size int64  // Declared uncompressed size
+	file *zip.File
+}
+
+// ZipArchive reads the files of an uploaded ZIP without ever writing
+// under their names, and with the bytes extracted bounded per file and in
+// total, however the archive describes itself.
+type ZipArchive struct {
+	Entries []ZipEntry
+	// Skipped lists entries not offered for processing, with the reason
+	Skipped []ZipSkip
+
+	maxEntrySize int64
+	extracted    int64
+}
+
+// ZipSkip is an archive entry left out by OpenZip
+type ZipSkip struct {
+	Name   string
+	Size   int64
+	Reason string
+}
+
+// OpenZip lists the files of an archive. Directories, macOS metadata and
+// hidden files are dropped silently; entries with unsafe paths (absolute,
+// or climbing out with ".."), symlinks, encrypted entries, nested archives
+// and entries whose compression ratio looks like a bomb are listed as
+// skipped. maxEntrySize bounds each extracted file.
+func OpenZip(r io.ReaderAt, size, maxEntrySize int64) (*ZipArchive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidZip, err)
+	}
+	if len(zr.File) > ZipMaxEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidZip, ZipMaxEntries)
+	}
+
+	a := &ZipArchive{maxEntrySize: maxEntrySize}
+	for _, f := range zr.File {
+		name := strings.ReplaceAll(f.Name, `\`, "/")
+		if f.FileInfo().IsDir() || strings.HasSuffix(name, "/") {
+			continue
+		}
+		base := path.Base(name)
+		if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+
+		size := int64(f.UncompressedSize64)
+		skip := func(reason string) {
+			a.Skipped = append(a.Skipped, ZipSkip{Name: name, Size: size, Reason: reason})
+		}
+		switch {
+		case !safeZipPath(name):
+			skip("unsafe path")
+		case !f.Mode().IsRegular():
+			skip("not a regular file")
+		case f.Flags&0x1 != 0:
+			skip("encrypted")
+		case IsZipFilename(name):
+			skip("nested archives are not extracted")
+		case size > maxEntrySize:
+			skip(ErrZipEntryTooLarge.Error())
+		case size > zipRatioFloor && f.CompressedSize64 > 0 && f.UncompressedSize64/f.CompressedSize64 > zipMaxRatio:
+			skip("suspicious compression ratio")
+		default:
+			a.Entries = append(a.Entries, ZipEntry{Name: path.Clean(name), Size: size, file: f})
+		}
+	}
+	return a, nil
+}
+
+// safeZipPath reports whether an entry name stays inside the archive root
+func safeZipPath(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsRune(name, 0) {
+		return false
+	}
+	if len(name) > 1 && name[1] == ':' { // C:/...
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Open returns the content of an entry. Reads fail with ErrZipEntryTooLarge
+// past the entry limit and ErrZipTooLarge once the archive's total passes
+// ZipMaxTotalSize; the declared sizes aren't trusted.
+func (a *ZipArchive) Open(e ZipEntry) (io.ReadCloser, error) {
+	if a.extracted >= ZipMaxTotalSize {
+		return nil, ErrZipTooLarge
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidZip, err)
+	}
+	return &zipEntryReader{rc: rc, archive: a, left: a.maxEntrySize}, nil
+}
+
+// ReadAll reads an entry into memory
+func (a *ZipArchive) ReadAll(e ZipEntry) ([]byte, error) {
+	rc, err := a.Open(e)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// zipEntryReader counts an entry's bytes against both limits
+type zipEntryReader struct {
+	rc      io.ReadCloser
+	archive *ZipArchive
+	left    int64
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.left -= int64(n)
+	r.archive.extracted += int64(n)
+	switch {
+	case r.archive.extracted > ZipMaxTotalSize:
+		return n, ErrZipTooLarge
+	case r.left < 0:
+		return n, ErrZipEntryTooLarge
+	}
+	return n, err
+}
+
+func (r *zipEntryReader) Close() error {
+	return r.rc.Close()
+}
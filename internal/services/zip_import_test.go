@@ -0,0 +1,179 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"testing"
+)
+
+func TestSafeZipPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"report.pdf", true},
+		{"docs/2024/report.pdf", true},
+		{"docs/..report.pdf", true},
+		{"", false},
+		{"../report.pdf", false},
+		{"docs/../../report.pdf", false},
+		{"docs/..", false},
+		{"/etc/passwd", false},
+		{"/report.pdf", false},
+		{"C:/Windows/report.pdf", false},
+		{"c:report.pdf", false},
+		{"docs/report\x00.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := safeZipPath(tt.name); got != tt.want {
+			t.Errorf("safeZipPath(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// zipFile is an entry for buildZip
+type zipFile struct {
+	name string
+	data []byte
+}
+
+func buildZip(t *testing.T, files []zipFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("create %s: %v", f.name, err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			t.Fatalf("write %s: %v", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenZipSkipsUnsafeEntries(t *testing.T) {
+	pdf := []byte("%PDF-1.4 test")
+	data := buildZip(t, []zipFile{
+		{"docs/report.pdf", pdf},
+		{"../escape.pdf", pdf},
+		{`..\escape.pdf`, pdf},
+		{"docs/../../escape.pdf", pdf},
+		{"/etc/escape.pdf", pdf},
+		{`C:\Windows\escape.pdf`, pdf},
+		{"bomb.pdf", make([]byte, 4*zipRatioFloor)},
+		{"huge.pdf", make([]byte, 2048)},
+	})
+
+	archive, err := OpenZip(bytes.NewReader(data), int64(len(data)), 1024*1024*1024)
+	if err != nil {
+		t.Fatalf("OpenZip: %v", err)
+	}
+	if len(archive.Entries) != 2 || archive.Entries[0].Name != "docs/report.pdf" || archive.Entries[1].Name != "huge.pdf" {
+		t.Fatalf("entries = %+v, want docs/report.pdf and huge.pdf", archive.Entries)
+	}
+
+	// Backslashes are read as separators
+	want := []ZipSkip{
+		{Name: "../escape.pdf", Reason: "unsafe path"},
+		{Name: "../escape.pdf", Reason: "unsafe path"},
+		{Name: "docs/../../escape.pdf", Reason: "unsafe path"},
+		{Name: "/etc/escape.pdf", Reason: "unsafe path"},
+		{Name: "C:/Windows/escape.pdf", Reason: "unsafe path"},
+		{Name: "bomb.pdf", Reason: "suspicious compression ratio"},
+	}
+	if len(archive.Skipped) != len(want) {
+		t.Fatalf("skipped = %+v, want %+v", archive.Skipped, want)
+	}
+	for i, w := range want {
+		if got := archive.Skipped[i]; got.Name != w.Name || got.Reason != w.Reason {
+			t.Errorf("skipped[%d] = %s (%s), want %s (%s)", i, got.Name, got.Reason, w.Name, w.Reason)
+		}
+	}
+
+	// Entries over maxEntrySize are skipped too
+	archive, err = OpenZip(bytes.NewReader(data), int64(len(data)), 1024)
+	if err != nil {
+		t.Fatalf("OpenZip: %v", err)
+	}
+	for _, e := range archive.Entries {
+		if e.Name == "huge.pdf" {
+			t.Errorf("huge.pdf is over maxEntrySize but was listed")
+		}
+	}
+}
+
+// TestZipTotalSizeLimit extracts an archive whose entries each pass the
+// per-entry and ratio checks but together exceed ZipMaxTotalSize
+func TestZipTotalSizeLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("extracts over 500MB")
+	}
+
+	// 2MB that compresses about 50:1, under zipMaxRatio
+	const entrySize = 2 * 1024 * 1024
+	content := make([]byte, entrySize)
+	if _, err := rand.Read(content[:entrySize/50]); err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestSpeed)
+	fw.Write(content)
+	fw.Close()
+	if ratio := entrySize / compressed.Len(); ratio > zipMaxRatio {
+		t.Fatalf("fixture compresses %d:1, over the ratio limit", ratio)
+	}
+
+	// Reuse the compressed bytes for every entry
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	entries := ZipMaxTotalSize/entrySize + 2
+	for i := 0; i < entries; i++ {
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               fmt.Sprintf("part%03d.pdf", i),
+			Method:             zip.Deflate,
+			CRC32:              crc32.ChecksumIEEE(content),
+			CompressedSize64:   uint64(compressed.Len()),
+			UncompressedSize64: entrySize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(compressed.Bytes())
+	}
+	zw.Close()
+
+	archive, err := OpenZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), entrySize)
+	if err != nil {
+		t.Fatalf("OpenZip: %v", err)
+	}
+	if len(archive.Entries) != entries {
+		t.Fatalf("listed %d entries, want %d (skipped: %+v)", len(archive.Entries), entries, archive.Skipped)
+	}
+
+	read := 0
+	for _, e := range archive.Entries {
+		if _, err = archive.ReadAll(e); err != nil {
+			break
+		}
+		read++
+	}
+	if !errors.Is(err, ErrZipTooLarge) {
+		t.Fatalf("extracting everything: got %v, want %v", err, ErrZipTooLarge)
+	}
+	if read != ZipMaxTotalSize/entrySize {
+		t.Errorf("read %d entries before the limit, want %d", read, ZipMaxTotalSize/entrySize)
+	}
+	if _, err := archive.ReadAll(archive.Entries[len(archive.Entries)-1]); !errors.Is(err, ErrZipTooLarge) {
+		t.Errorf("opening an entry past the limit: got %v, want %v", err, ErrZipTooLarge)
+	}
+}
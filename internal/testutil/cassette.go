@@ -0,0 +1,316 @@
+package testutil
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RecordEnv turns cassette recording on: with TEST_RECORD_CASSETTES=1 and
+// the live credentials a contract needs, requests go to the real API and
+// the cassette in internal/testutil/cassettes is rewritten
+const RecordEnv = "TEST_RECORD_CASSETTES"
+
+// redacted replaces credentials in recorded headers. A cassette header with
+// this value only has to be present when replayed.
+const redacted = "REDACTED"
+
+//go:embed cassettes/*.json
+var cassettes embed.FS
+
+// recordedHeaders are the request headers kept in cassettes; anything else
+// (user agents, content lengths) varies between runs
+var recordedHeaders = []string{"Authorization", "Content-Type", "HTTP-Referer", "X-Title"}
+
+// Cassette is a recorded exchange with an external API
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request and the response it got
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request a replay is matched on: method,
+// URL and the headers listed. The body is kept for reference; contracts
+// check the bodies they care about.
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// RecordedResponse is replayed as is
+type RecordedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// SentRequest is a request a client under test made, credentials included
+type SentRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the request body into v, failing t when it isn't JSON
+func (r SentRequest) JSON(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("%s %s: body is not JSON: %v (%s)", r.Method, r.URL, err, truncate(r.Body, 200))
+	}
+}
+
+// Tape plays a cassette to the client under test, or records a new one.
+// Give the client Tape.Client() in place of its HTTP client.
+type Tape struct {
+	t         testing.TB
+	name      string
+	recording bool
+
+	mu       sync.Mutex
+	cassette Cassette
+	next     int
+	sent     []SentRequest
+}
+
+// UseCassette loads cassettes/<name>.json for replay. When RecordEnv is set
+// and every variable in liveEnv is too, it records against the live API
+// instead and saves the cassette when t ends; contracts that can't be
+// reproduced live (rate limits, failures) pass no liveEnv and always
+// replay.
+func UseCassette(t testing.TB, name string, liveEnv ...string) *Tape {
+	t.Helper()
+
+	tape := &Tape{t: t, name: name}
+	if os.Getenv(RecordEnv) == "1" && len(liveEnv) > 0 {
+		tape.recording = true
+		for _, key := range liveEnv {
+			if os.Getenv(key) == "" {
+				t.Logf("testutil: %s not set, replaying cassette %s", key, name)
+				tape.recording = false
+				break
+			}
+		}
+	}
+
+	if tape.recording {
+		t.Cleanup(tape.save)
+		return tape
+	}
+
+	data, err := cassettes.ReadFile("cassettes/" + name + ".json")
+	if err != nil {
+		t.Fatalf("testutil: cassette %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, &tape.cassette); err != nil {
+		t.Fatalf("testutil: cassette %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		tape.mu.Lock()
+		defer tape.mu.Unlock()
+		if tape.next < len(tape.cassette.Interactions) {
+			t.Errorf("cassette %s: %d of %d interactions were never requested", name, len(tape.cassette.Interactions)-tape.next, len(tape.cassette.Interactions))
+		}
+	})
+	return tape
+}
+
+// ReadCassette returns cassettes/<name>.json as is, for fixtures kept with
+// the cassettes that aren't recorded exchanges, such as test vectors
+func ReadCassette(t testing.TB, name string) []byte {
+	t.Helper()
+
+	data, err := cassettes.ReadFile("cassettes/" + name + ".json")
+	if err != nil {
+		t.Fatalf("testutil: cassette %s: %v", name, err)
+	}
+	return data
+}
+
+// Recording reports whether the tape talks to the live API
+func (tp *Tape) Recording() bool {
+	return tp.recording
+}
+
+// Client returns an HTTP client that goes through the tape
+func (tp *Tape) Client() *http.Client {
+	return &http.Client{Transport: tp}
+}
+
+// Sent returns the requests made so far, in order
+func (tp *Tape) Sent() []SentRequest {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return append([]SentRequest(nil), tp.sent...)
+}
+
+// Response returns the response to the i-th request, recorded or replayed
+func (tp *Tape) Response(i int) RecordedResponse {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if i >= len(tp.cassette.Interactions) {
+		tp.t.Fatalf("cassette %s: no response %d", tp.name, i)
+	}
+	return tp.cassette.Interactions[i].Response
+}
+
+// RoundTrip implements http.RoundTripper
+func (tp *Tape) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sent := SentRequest{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.sent = append(tp.sent, sent)
+
+	if tp.recording {
+		return tp.record(req, sent)
+	}
+	return tp.replay(req, sent)
+}
+
+func (tp *Tape) replay(req *http.Request, sent SentRequest) (*http.Response, error) {
+	if tp.next >= len(tp.cassette.Interactions) {
+		tp.t.Errorf("cassette %s: unexpected request %d: %s %s", tp.name, tp.next+1, sent.Method, sent.URL)
+		return nil, fmt.Errorf("cassette %s has no interaction for %s %s", tp.name, sent.Method, sent.URL)
+	}
+	it := tp.cassette.Interactions[tp.next]
+	tp.next++
+
+	if it.Request.Method != sent.Method || it.Request.URL != sent.URL {
+		tp.t.Errorf("cassette %s: request %d is %s %s, recorded %s %s", tp.name, tp.next, sent.Method, sent.URL, it.Request.Method, it.Request.URL)
+	}
+	for name, want := range it.Request.Headers {
+		got := sent.Header.Get(name)
+		if got == "" || (want != redacted && got != want) {
+			tp.t.Errorf("cassette %s: request %d header %s is %q, recorded %q", tp.name, tp.next, name, got, want)
+		}
+	}
+
+	body := replayedBody(it.Response.Body)
+	header := make(http.Header)
+	for name, value := range it.Response.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+		StatusCode:    it.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (tp *Tape) record(req *http.Request, sent SentRequest) (*http.Response, error) {
+	live := req.Clone(req.Context())
+	live.Body = io.NopCloser(bytes.NewReader(sent.Body))
+	resp, err := http.DefaultTransport.RoundTrip(live)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	it := Interaction{
+		Request:  RecordedRequest{Method: sent.Method, URL: sent.URL, Headers: map[string]string{}, Body: recordedBody(sent.Body)},
+		Response: RecordedResponse{Status: resp.StatusCode, Body: recordedBody(respBody)},
+	}
+	for _, name := range recordedHeaders {
+		if v := sent.Header.Get(name); v != "" {
+			if name == "Authorization" {
+				v = redacted
+			}
+			it.Request.Headers[name] = v
+		}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		it.Response.Headers = map[string]string{"Content-Type": ct}
+	}
+	tp.cassette.Interactions = append(tp.cassette.Interactions, it)
+	return resp, nil
+}
+
+// save writes a recorded cassette next to this file, so the next replay
+// embeds it
+func (tp *Tape) save() {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		tp.t.Errorf("testutil: can't locate the cassettes directory")
+		return
+	}
+	data, err := json.MarshalIndent(tp.cassette, "", "  ")
+	if err != nil {
+		tp.t.Errorf("testutil: cassette %s: %v", tp.name, err)
+		return
+	}
+	path := filepath.Join(filepath.Dir(file), "cassettes", tp.name+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		tp.t.Errorf("testutil: cassette %s: %v", tp.name, err)
+		return
+	}
+	tp.t.Logf("testutil: recorded %d interactions to %s", len(tp.cassette.Interactions), path)
+}
+
+// recordedBody keeps JSON bodies as JSON and anything else, e.g. an HTML
+// error page from a proxy, as a JSON string
+func recordedBody(body []byte) json.RawMessage {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil
+	}
+	if json.Valid(trimmed) {
+		return trimmed
+	}
+	quoted, _ := json.Marshal(strings.ToValidUTF8(string(body), "\uFFFD"))
+	return quoted
+}
+
+// replayedBody undoes recordedBody
+func replayedBody(body json.RawMessage) []byte {
+	var text string
+	if len(body) > 0 && body[0] == '"' && json.Unmarshal(body, &text) == nil {
+		return []byte(text)
+	}
+	return body
+}
+
+func truncate(data []byte, n int) string {
+	if len(data) > n {
+		return fmt.Sprintf("%s...", data[:n])
+	}
+	return string(data)
+}
@@ -0,0 +1,73 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Container is a throwaway container started through testcontainers for an
+// integration run
+type Container struct {
+	container testcontainers.Container
+	Host      string
+	Port      string
+}
+
+// DockerAvailable reports whether testcontainers can reach a healthy
+// docker daemon
+func DockerAvailable() bool {
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return false
+	}
+	defer provider.Close()
+	return provider.Health(context.Background()) == nil
+}
+
+// StartContainer runs image and resolves the host port mapped to
+// containerPort (e.g. "27017"), returning once that port accepts
+// connections
+func StartContainer(ctx context.Context, image, containerPort string, env map[string]string, cmd ...string) (*Container, error) {
+	port := nat.Port(containerPort + "/tcp")
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{string(port)},
+			Env:          env,
+			Cmd:          cmd,
+			WaitingFor:   wait.ForListeningPort(port).WithStartupTimeout(startupTimeout),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", image, err)
+	}
+	c := &Container{container: ctr}
+
+	if c.Host, err = ctr.Host(ctx); err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("failed to resolve host for %s: %w", image, err)
+	}
+	mapped, err := ctr.MappedPort(ctx, port)
+	if err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("failed to resolve port for %s: %w", image, err)
+	}
+	c.Port = mapped.Port()
+
+	return c, nil
+}
+
+// Addr returns host:port of the mapped container port
+func (c *Container) Addr() string {
+	return c.Host + ":" + c.Port
+}
+
+// Terminate stops and removes the container
+func (c *Container) Terminate() error {
+	return c.container.Terminate(context.Background())
+}
@@ -0,0 +1,132 @@
+package testutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"brainy-pdf/internal/services"
+)
+
+// FakePaymentGateway is an in-memory services.PaymentGateway and
+// services.SubscriptionGateway. Orders, plans and subscriptions get
+// sequential IDs and payments verify with Sign, the way Razorpay Checkout
+// signs them.
+type FakePaymentGateway struct {
+	KeySecret string
+	Err       error // Returned by CreateOrder and CreateSubscription when set
+
+	mu            sync.Mutex
+	orders        []services.PaymentOrder
+	notes         []map[string]string
+	plans         int
+	subscriptions map[string]*services.GatewaySubscription
+}
+
+func NewFakePaymentGateway() *FakePaymentGateway {
+	return &FakePaymentGateway{KeySecret: "fake_key_secret"}
+}
+
+// CreateOrder implements services.PaymentGateway
+func (g *FakePaymentGateway) CreateOrder(amount int64, currency, receipt string, notes map[string]string) (*services.PaymentOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Err != nil {
+		return nil, g.Err
+	}
+	order := services.PaymentOrder{
+		ID:       fmt.Sprintf("order_fake%06d", len(g.orders)+1),
+		Amount:   amount,
+		Currency: currency,
+		Receipt:  receipt,
+		Status:   "created",
+	}
+	g.orders = append(g.orders, order)
+	g.notes = append(g.notes, notes)
+	return &order, nil
+}
+
+// VerifyPayment implements services.PaymentGateway
+func (g *FakePaymentGateway) VerifyPayment(orderID, paymentID, signature string) bool {
+	return signature != "" && signature == g.Sign(orderID, paymentID)
+}
+
+// Sign returns the signature checkout would return for a payment
+func (g *FakePaymentGateway) Sign(orderID, paymentID string) string {
+	return services.RazorpaySignature(orderID, paymentID, g.KeySecret)
+}
+
+// Orders returns the orders created so far and the notes sent with each
+func (g *FakePaymentGateway) Orders() ([]services.PaymentOrder, []map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]services.PaymentOrder(nil), g.orders...), append([]map[string]string(nil), g.notes...)
+}
+
+// CreatePlan implements services.SubscriptionGateway
+func (g *FakePaymentGateway) CreatePlan(name string, amount int64, currency string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.plans++
+	return fmt.Sprintf("plan_fake%06d", g.plans), nil
+}
+
+// CreateSubscription implements services.SubscriptionGateway. Subscriptions
+// start out created, with no billing period.
+func (g *FakePaymentGateway) CreateSubscription(planID string, totalCount int, notes map[string]string) (*services.GatewaySubscription, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Err != nil {
+		return nil, g.Err
+	}
+	if g.subscriptions == nil {
+		g.subscriptions = map[string]*services.GatewaySubscription{}
+	}
+	id := fmt.Sprintf("sub_fake%06d", len(g.subscriptions)+1)
+	sub := &services.GatewaySubscription{ID: id, PlanID: planID, Status: "created", ShortURL: "https://rzp.io/i/" + id}
+	g.subscriptions[id] = sub
+	copied := *sub
+	return &copied, nil
+}
+
+// FetchSubscription implements services.SubscriptionGateway
+func (g *FakePaymentGateway) FetchSubscription(id string) (*services.GatewaySubscription, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sub, ok := g.subscriptions[id]
+	if !ok {
+		return nil, fmt.Errorf("razorpay subscription not found")
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+// CancelSubscription implements services.SubscriptionGateway
+func (g *FakePaymentGateway) CancelSubscription(id string, atCycleEnd bool) (*services.GatewaySubscription, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sub, ok := g.subscriptions[id]
+	if !ok {
+		return nil, fmt.Errorf("razorpay subscription not found")
+	}
+	if !atCycleEnd {
+		sub.Status = "cancelled"
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+// VerifySubscription implements services.SubscriptionGateway
+func (g *FakePaymentGateway) VerifySubscription(subscriptionID, paymentID, signature string) bool {
+	return signature != "" && signature == g.Sign(paymentID, subscriptionID)
+}
+
+// SetSubscription sets a subscription's status and period end, as charges
+// and retries would
+func (g *FakePaymentGateway) SetSubscription(id, status string, currentEnd *time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if sub, ok := g.subscriptions[id]; ok {
+		sub.Status, sub.CurrentEnd = status, currentEnd
+	}
+}
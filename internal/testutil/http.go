@@ -0,0 +1,118 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+
+	"brainy-pdf/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FormFile is one file part of a multipart request
+type FormFile struct {
+	Field string
+	Name  string
+	Data  []byte
+}
+
+// NewMultipartRequest builds a multipart/form-data request
+func NewMultipartRequest(method, path string, files []FormFile, fields map[string]string) (*http.Request, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	for _, f := range files {
+		part, err := w.CreateFormFile(f.Field, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req, nil
+}
+
+// NewJSONRequest builds a request with a JSON body
+func NewJSONRequest(method, path string, payload interface{}) (*http.Request, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Do sends req through the router, authenticated as uid when non-empty
+func (e *Env) Do(req *http.Request, uid string) *httptest.ResponseRecorder {
+	if uid != "" {
+		req.Header.Set(UserHeader, uid)
+	}
+	rec := httptest.NewRecorder()
+	e.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// DecodeData unmarshals the "data" field of a utils.APIResponse envelope.
+// Handlers that already nest {"success","data"} inside the envelope are
+// unwrapped one more level so callers see the payload either way.
+func DecodeData(rec *httptest.ResponseRecorder, v interface{}) error {
+	var envelope struct {
+		utils.APIResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		return fmt.Errorf("decode response (status %d): %w", rec.Code, err)
+	}
+	if !envelope.Success {
+		msg := "unknown error"
+		if envelope.Error != nil {
+			msg = envelope.Error.Message
+		}
+		return fmt.Errorf("request failed (status %d): %s", rec.Code, msg)
+	}
+
+	data := envelope.Data
+	var nested struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(data, &nested) == nil && nested.Success && len(nested.Data) > 0 {
+		data = nested.Data
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ReadAll drains a response body, for download assertions
+func ReadAll(rec *httptest.ResponseRecorder) []byte {
+	data, _ := io.ReadAll(rec.Result().Body)
+	return data
+}
+
+func mongoIndex(keys bson.D, unique bool) mongo.IndexModel {
+	return mongo.IndexModel{Keys: keys, Options: options.Index().SetUnique(unique)}
+}
+
+func isNamespaceExists(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists"
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ContentDisposition builds a Content-Disposition header value
+// ("attachment" or "inline") that survives any filename. filename carries
+// an ASCII fallback for old clients and filename* the UTF-8 name, encoded
+// as in RFC 5987, so Hindi, emoji or accented names download intact.
+func ContentDisposition(disposition, filename string) string {
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, asciiFilename(filename), encodeRFC5987(filename))
+}
+
+// asciiFilename reduces a filename to printable ASCII for the plain
+// filename parameter: accents are dropped ("résumé" -> "resume"), other
+// non-ASCII characters, quotes and backslashes become "_"
+func asciiFilename(filename string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(filename) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining mark left by decomposing an accented letter
+		case r < 0x20 || r > 0x7e || r == '"' || r == '\\':
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "download"
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes every byte of s outside attr-char
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if isAttrChar(ch) {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether ch may appear unencoded in an RFC 5987 value
+func isAttrChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
@@ -8,6 +8,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// RequestIDHeader carries the request ID assigned by the request ID
+// middleware
+const RequestIDHeader = "X-Request-ID"
+
 // APIResponse is the standard response structure
 type APIResponse struct {
 	Success bool        `json:"success"`
@@ -34,7 +38,7 @@ func Success(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Data:    data,
-		Meta:    newMeta(),
+		Meta:    newMeta(c),
 	})
 }
 
@@ -43,7 +47,7 @@ func SuccessWithStatus(c *gin.Context, status int, data interface{}) {
 	c.JSON(status, APIResponse{
 		Success: true,
 		Data:    data,
-		Meta:    newMeta(),
+		Meta:    newMeta(c),
 	})
 }
 
@@ -55,7 +59,7 @@ func Error(c *gin.Context, status int, code, message string) {
 			Code:    code,
 			Message: message,
 		},
-		Meta: newMeta(),
+		Meta: newMeta(c),
 	})
 }
 
@@ -68,7 +72,7 @@ func ErrorWithDetails(c *gin.Context, status int, code, message, details string)
 			Message: message,
 			Details: details,
 		},
-		Meta: newMeta(),
+		Meta: newMeta(c),
 	})
 }
 
@@ -101,6 +105,12 @@ func ServiceUnavailable(c *gin.Context, message string) {
 	Error(c, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", message)
 }
 
+// ServiceDisabled reports that an optional service a request depends on is
+// not available on this deployment
+func ServiceDisabled(c *gin.Context, service, reason string) {
+	ErrorWithDetails(c, http.StatusServiceUnavailable, "SERVICE_DISABLED", service+" is not available on this server", reason)
+}
+
 func TooManyRequests(c *gin.Context, message string) {
 	c.Header("Retry-After", "30") // Tell client to retry after 30 seconds
 	Error(c, http.StatusTooManyRequests, "TOO_MANY_REQUESTS", message)
@@ -110,9 +120,13 @@ func GatewayTimeout(c *gin.Context, message string) {
 	Error(c, http.StatusGatewayTimeout, "GATEWAY_TIMEOUT", message)
 }
 
-func newMeta() *APIMeta {
+func newMeta(c *gin.Context) *APIMeta {
+	requestID := c.Writer.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
 	return &APIMeta{
-		RequestID: uuid.New().String(),
+		RequestID: requestID,
 		Timestamp: time.Now().UTC(),
 	}
 }
@@ -3,10 +3,13 @@ package minio
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,11 +17,26 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// ErrEncryptedObject is returned for presigned URLs to encrypted objects,
+// which would hand out ciphertext; such files must be downloaded through
+// the API
+var ErrEncryptedObject = errors.New("object is encrypted and must be downloaded through the API")
+
+// Encrypter encrypts objects before they are stored and decrypts them after
+// they are read. Decrypt decides what to do with data it did not encrypt,
+// such as objects stored before encryption was enabled.
+type Encrypter interface {
+	Encrypt(ctx context.Context, objectPath string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, data []byte) ([]byte, error)
+}
+
 // Client wraps the MinIO client
 type Client struct {
 	client          *minio.Client
 	bucketTemp      string
 	bucketUserFiles string
+	encrypter       Encrypter
+	replicas        *replicas // nil without read replicas
 }
 
 // NewClient creates a new MinIO client
@@ -50,6 +68,48 @@ func NewClient(endpoint, accessKey, secretKey string, useSSL bool, bucketTemp, b
 	return c, nil
 }
 
+// NewBucketClient creates a client for a single existing bucket owned by
+// someone else, such as a customer's own S3 bucket. It is used as the user
+// files bucket and is never created or configured by us. transport, when
+// set, makes every request; nil uses minio's default.
+func NewBucketClient(endpoint, region, accessKey, secretKey string, useSSL bool, bucket string, transport http.RoundTripper) (*Client, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:    useSSL,
+		Region:    region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &Client{client: client, bucketUserFiles: bucket}, nil
+}
+
+// CheckAccess verifies the user files bucket exists and that objects can be
+// written, read and deleted in it
+func (c *Client) CheckAccess(ctx context.Context) error {
+	exists, err := c.client.BucketExists(ctx, c.bucketUserFiles)
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", c.bucketUserFiles)
+	}
+
+	probe := ".brainy-pdf-access-check/" + uuid.New().String()
+	if _, err := c.client.PutObject(ctx, c.bucketUserFiles, probe, bytes.NewReader([]byte("ok")), 2, minio.PutObjectOptions{ContentType: "text/plain"}); err != nil {
+		return fmt.Errorf("failed to write to bucket: %w", err)
+	}
+	_, statErr := c.client.StatObject(ctx, c.bucketUserFiles, probe, minio.StatObjectOptions{})
+	if err := c.client.RemoveObject(ctx, c.bucketUserFiles, probe, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete from bucket: %w", err)
+	}
+	if statErr != nil {
+		return fmt.Errorf("failed to read from bucket: %w", statErr)
+	}
+	return nil
+}
+
 // ensureBucket creates a bucket if it doesn't exist
 func (c *Client) ensureBucket(ctx context.Context, bucket string) error {
 	exists, err := c.client.BucketExists(ctx, bucket)
@@ -66,8 +126,36 @@ func (c *Client) ensureBucket(ctx context.Context, bucket string) error {
 	return nil
 }
 
+// SetEncrypter enables encryption at rest for the user files bucket.
+// Temporary files are left in the clear; they expire within hours.
+func (c *Client) SetEncrypter(e Encrypter) {
+	c.encrypter = e
+}
+
+// Encrypter returns the encrypter set with SetEncrypter, or nil
+func (c *Client) Encrypter() Encrypter {
+	return c.encrypter
+}
+
+// encrypts reports whether objects in bucket are encrypted
+func (c *Client) encrypts(bucket string) bool {
+	return c.encrypter != nil && bucket == c.bucketUserFiles
+}
+
 // UploadFile uploads a file to MinIO
 func (c *Client) UploadFile(ctx context.Context, bucket, objectPath string, reader io.Reader, size int64, contentType string) (string, error) {
+	if c.encrypts(bucket) {
+		plaintext, err := io.ReadAll(reader)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		sealed, err := c.encrypter.Encrypt(ctx, objectPath, plaintext)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt file: %w", err)
+		}
+		reader, size = bytes.NewReader(sealed), int64(len(sealed))
+	}
+
 	_, err := c.client.PutObject(ctx, bucket, objectPath, reader, size, minio.PutObjectOptions{
 		ContentType: contentType,
 	})
@@ -107,21 +195,60 @@ func (c *Client) DownloadFile(ctx context.Context, bucket, objectPath string) ([
 	if err != nil {
 		return nil, fmt.Errorf("failed to read object: %w", err)
 	}
+	if c.encrypts(bucket) {
+		if data, err = c.encrypter.Decrypt(ctx, data); err != nil {
+			return nil, fmt.Errorf("failed to decrypt object: %w", err)
+		}
+	}
 	return data, nil
 }
 
-// GetObject returns a reader for the object
+// GetObject returns a reader for the stored object, without decryption
 func (c *Client) GetObject(ctx context.Context, bucket, objectPath string) (*minio.Object, error) {
 	return c.client.GetObject(ctx, bucket, objectPath, minio.GetObjectOptions{})
 }
 
+// OpenObject returns a reader for the object's contents and their size,
+// decrypting encrypted objects
+func (c *Client) OpenObject(ctx context.Context, bucket, objectPath string) (io.ReadCloser, int64, error) {
+	if c.encrypts(bucket) {
+		data, err := c.DownloadFile(ctx, bucket, objectPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+	}
+
+	obj, err := c.GetObject(ctx, bucket, objectPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, 0, err
+	}
+	return obj, info.Size, nil
+}
+
 // DeleteFile deletes a file from MinIO
 func (c *Client) DeleteFile(ctx context.Context, bucket, objectPath string) error {
+	if bucket == c.bucketUserFiles {
+		c.forgetReplicated(objectPath)
+	}
 	return c.client.RemoveObject(ctx, bucket, objectPath, minio.RemoveObjectOptions{})
 }
 
-// GetPresignedURL generates a presigned URL for downloading
+// GetPresignedURL generates a presigned URL for downloading, against the
+// replica for the region in ctx when the object has reached it. It fails
+// with ErrEncryptedObject in buckets that are encrypted at rest.
 func (c *Client) GetPresignedURL(ctx context.Context, bucket, objectPath string, expires time.Duration) (string, error) {
+	if c.encrypts(bucket) {
+		return "", ErrEncryptedObject
+	}
+	if replica := c.replicaFor(ctx, bucket, objectPath); replica != nil {
+		return replica.GetPresignedURL(ctx, replica.bucketUserFiles, objectPath, expires)
+	}
 	url, err := c.client.PresignedGetObject(ctx, bucket, objectPath, expires, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
@@ -136,6 +263,11 @@ func (c *Client) GetFileInfo(ctx context.Context, bucket, objectPath string) (mi
 
 // MoveFile moves a file from one location to another
 func (c *Client) MoveFile(ctx context.Context, srcBucket, srcPath, destBucket, destPath string) error {
+	if c.encrypts(destBucket) && !c.encrypts(srcBucket) {
+		// A server-side copy would store the file unencrypted
+		return c.moveEncrypting(ctx, srcBucket, srcPath, destBucket, destPath)
+	}
+
 	// Copy to destination
 	_, err := c.client.CopyObject(ctx,
 		minio.CopyDestOptions{Bucket: destBucket, Object: destPath},
@@ -153,6 +285,117 @@ func (c *Client) MoveFile(ctx context.Context, srcBucket, srcPath, destBucket, d
 	return nil
 }
 
+// moveEncrypting moves a file into an encrypted bucket by re-uploading it
+func (c *Client) moveEncrypting(ctx context.Context, srcBucket, srcPath, destBucket, destPath string) error {
+	info, err := c.GetFileInfo(ctx, srcBucket, srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+	data, err := c.DownloadFile(ctx, srcBucket, srcPath)
+	if err != nil {
+		return err
+	}
+	if _, err := c.UploadBytes(ctx, destBucket, destPath, data, info.ContentType); err != nil {
+		return err
+	}
+	if err := c.DeleteFile(ctx, srcBucket, srcPath); err != nil {
+		return fmt.Errorf("failed to delete source file: %w", err)
+	}
+	return nil
+}
+
+// CopyStored copies an object as stored, without decrypting or encrypting
+// it. A storageClass such as GLACIER or STANDARD moves the copy to that
+// class; copying out of an archival class fails until the object is
+// restored, with an error IsArchived reports.
+func (c *Client) CopyStored(ctx context.Context, srcBucket, srcPath, destBucket, destPath, storageClass string) error {
+	dest := minio.CopyDestOptions{Bucket: destBucket, Object: destPath}
+	if storageClass != "" {
+		// Changing the class replaces the metadata, so carry it over
+		info, err := c.GetFileInfo(ctx, srcBucket, srcPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat source file: %w", err)
+		}
+		meta := map[string]string{"X-Amz-Storage-Class": storageClass, "Content-Type": info.ContentType}
+		for k, v := range info.UserMetadata {
+			meta[k] = v
+		}
+		dest.ReplaceMetadata = true
+		dest.UserMetadata = meta
+	}
+	if _, err := c.client.CopyObject(ctx, dest, minio.CopySrcOptions{Bucket: srcBucket, Object: srcPath}); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+// RestoreArchived asks for a readable copy of an object in an archival
+// storage class, kept for days. Restores take minutes to hours; poll with
+// CopyStored. Asking again while a restore is running is not an error.
+func (c *Client) RestoreArchived(ctx context.Context, bucket, objectPath string, days int) error {
+	req := minio.RestoreRequest{}
+	req.SetDays(days)
+	err := c.client.RestoreObject(ctx, bucket, objectPath, "", req)
+	if minio.ToErrorResponse(err).Code == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
+
+// IsArchived reports whether err means the object is in an archival
+// storage class and must be restored before it can be read
+func IsArchived(err error) bool {
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && resp.Code == "InvalidObjectState"
+}
+
+// IsNotFound reports whether err is S3 saying the object doesn't exist
+func IsNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
+
+// EnsureBucket creates bucket if it doesn't exist
+func (c *Client) EnsureBucket(ctx context.Context, bucket string) error {
+	return c.ensureBucket(ctx, bucket)
+}
+
+// ListAllObjects lists every object under prefix
+func (c *Client) ListAllObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var objects []string
+	for object := range c.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, object.Key)
+	}
+	return objects, nil
+}
+
+// ListObjectsAfter lists up to limit objects whose keys sort after
+// startAfter, in key order, so long listings can be resumed
+func (c *Client) ListObjectsAfter(ctx context.Context, bucket, startAfter string, limit int) ([]minio.ObjectInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var objects []minio.ObjectInfo
+	opts := minio.ListObjectsOptions{Recursive: true, StartAfter: startAfter}
+	for object := range c.client.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, object)
+		if len(objects) == limit {
+			break
+		}
+	}
+	return objects, nil
+}
+
+// Endpoint returns the host[:port] the client talks to
+func (c *Client) Endpoint() string {
+	return c.client.EndpointURL().Host
+}
+
 // GetBucketTemp returns the temp bucket name
 func (c *Client) GetBucketTemp() string {
 	return c.bucketTemp
@@ -163,10 +406,34 @@ func (c *Client) GetBucketUserFiles() string {
 	return c.bucketUserFiles
 }
 
-// GenerateUniqueFilename generates a unique filename
+// GenerateUniqueFilename generates a unique object name, keeping the
+// original's extension only when it is short and alphanumeric so the
+// client's name never reaches the object key
 func GenerateUniqueFilename(originalName string) string {
-	ext := filepath.Ext(originalName)
-	return fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	return fmt.Sprintf("%s%s", uuid.New().String(), SafeExtension(originalName))
+}
+
+// SafeExtension returns the extension of a client's filename, lowercased,
+// when it is safe to use in an object key or path, and "" otherwise
+func SafeExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !safeExtension(ext) {
+		return ""
+	}
+	return ext
+}
+
+// safeExtension reports whether ext is a dot and 1-10 ASCII letters or digits
+func safeExtension(ext string) bool {
+	if len(ext) < 2 || len(ext) > 11 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
 }
 
 // ListObjects lists objects in a bucket with a prefix (for debugging)
@@ -0,0 +1,107 @@
+package minio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// replicaSeenTTL is how long an object found on a replica is presigned
+	// there without checking again
+	replicaSeenTTL = time.Hour
+	// replicaSeenMax bounds the objects remembered per client
+	replicaSeenMax = 10000
+	// replicaStatTimeout bounds the check that an object reached a replica
+	replicaStatTimeout = 2 * time.Second
+)
+
+type regionKey struct{}
+
+// WithRegion returns a context for a request from region, so presigned URLs
+// made with it point at that region's replica
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// RegionFrom returns the region set with WithRegion, or ""
+func RegionFrom(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+// replicas are read replicas of the user files bucket by region
+type replicas struct {
+	clients map[string]*Client
+
+	mu   sync.Mutex
+	seen map[string]time.Time // region/key of objects found on a replica
+}
+
+// SetReplicas sets read replicas of the user files bucket by region, each
+// a client for its replica bucket. Presigned URLs for requests from a
+// region point at its replica once the object has been copied there.
+// Copying is up to the caller. Call it during startup.
+func (c *Client) SetReplicas(clients map[string]*Client) {
+	if len(clients) == 0 {
+		c.replicas = nil
+		return
+	}
+	c.replicas = &replicas{clients: clients, seen: make(map[string]time.Time)}
+}
+
+// Replicas returns the replica clients set with SetReplicas, by region
+func (c *Client) Replicas() map[string]*Client {
+	if c.replicas == nil {
+		return nil
+	}
+	return c.replicas.clients
+}
+
+// replicaFor returns the replica to presign an object of bucket against
+// for the request in ctx, or nil to use this client
+func (c *Client) replicaFor(ctx context.Context, bucket, objectPath string) *Client {
+	if c.replicas == nil || bucket != c.bucketUserFiles {
+		return nil
+	}
+	region := RegionFrom(ctx)
+	replica := c.replicas.clients[region]
+	if replica == nil {
+		return nil
+	}
+
+	key := region + "/" + objectPath
+	c.replicas.mu.Lock()
+	seenAt, ok := c.replicas.seen[key]
+	c.replicas.mu.Unlock()
+	if ok && time.Since(seenAt) < replicaSeenTTL {
+		return replica
+	}
+
+	// Objects are copied asynchronously; fall back to the primary until
+	// this one arrives
+	statCtx, cancel := context.WithTimeout(ctx, replicaStatTimeout)
+	defer cancel()
+	if _, err := replica.GetFileInfo(statCtx, replica.bucketUserFiles, objectPath); err != nil {
+		return nil
+	}
+	c.replicas.mu.Lock()
+	if len(c.replicas.seen) >= replicaSeenMax {
+		c.replicas.seen = make(map[string]time.Time)
+	}
+	c.replicas.seen[key] = time.Now()
+	c.replicas.mu.Unlock()
+	return replica
+}
+
+// forgetReplicated drops an object from the replicas found to hold it
+func (c *Client) forgetReplicated(objectPath string) {
+	if c.replicas == nil {
+		return
+	}
+	c.replicas.mu.Lock()
+	for region := range c.replicas.clients {
+		delete(c.replicas.seen, region+"/"+objectPath)
+	}
+	c.replicas.mu.Unlock()
+}
@@ -0,0 +1,251 @@
+// Package sdk is a Go client for the Brainy PDF API. It handles
+// authentication, retries on transient failures and multipart uploads, and
+// returns the same typed results the server produces.
+//
+//	client := sdk.New("https://api.example.com", sdk.WithToken(idToken))
+//	res, err := client.Merge(ctx, sdk.FileFromPath("a.pdf"), sdk.FileFromPath("b.pdf"))
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to a Brainy PDF server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      func(ctx context.Context) (string, error)
+	maxRetries int
+	backoff    time.Duration
+	userAgent  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates every request with a fixed Firebase ID token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+// WithTokenSource fetches a token per request, e.g. to refresh expired
+// Firebase ID tokens
+func WithTokenSource(fn func(ctx context.Context) (string, error)) Option {
+	return func(c *Client) { c.token = fn }
+}
+
+// WithHTTPClient replaces the default HTTP client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries sets how many times transient failures (429, 502, 503, 504
+// and network errors) are retried, and the initial backoff
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New creates a client for the server at baseURL (scheme and host, without
+// the /api prefix)
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Minute},
+		maxRetries: 3,
+		backoff:    500 * time.Millisecond,
+		userAgent:  "brainy-pdf-go-sdk",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is an error response from the server
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("%d %s: %s (%s)", e.StatusCode, e.Code, e.Message, e.Details)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// envelope is the server's standard response wrapper
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details string `json:"details"`
+	} `json:"error"`
+}
+
+// request describes one API call. body is called once per attempt so that
+// retries can resend uploads.
+type request struct {
+	method      string
+	path        string
+	query       map[string]string
+	body        func() (io.ReadCloser, error)
+	contentType string
+}
+
+// do sends req, retrying transient failures, and decodes the response's
+// data into out (which may be nil)
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var lastErr error
+	backoff := c.backoff
+
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+			if apiErr, ok := lastErr.(*APIError); ok && apiErr.RetryAfter > 0 {
+				wait = apiErr.RetryAfter
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			backoff *= 2
+		}
+
+		resp, err := c.send(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil || err == errBodyConsumed {
+				if lastErr != nil {
+					return lastErr
+				}
+				return err
+			}
+			lastErr = err
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		if apiErr, ok := err.(*APIError); ok && apiErr.Temporary() {
+			lastErr = err
+			continue
+		}
+		return err
+	}
+	return lastErr
+}
+
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body io.ReadCloser
+	if req.body != nil {
+		b, err := req.body()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, body)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	if len(req.query) > 0 {
+		q := httpReq.URL.Query()
+		for k, v := range req.query {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		httpReq.URL.RawQuery = q.Encode()
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			if body != nil {
+				body.Close()
+			}
+			return nil, fmt.Errorf("failed to get auth token: %w", err)
+		}
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		if resp.StatusCode >= 400 {
+			return &APIError{StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode), Message: strings.TrimSpace(string(data))}
+		}
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 || !env.Success {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode)}
+		if env.Error != nil {
+			apiErr.Code = env.Error.Code
+			apiErr.Message = env.Error.Message
+			apiErr.Details = env.Error.Details
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return apiErr
+	}
+
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Data, out)
+}
@@ -0,0 +1,166 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// errBodyConsumed stops retries when an upload cannot be replayed
+var errBodyConsumed = errors.New("sdk: upload reader already consumed, cannot retry")
+
+// File is an upload source. Open is called for every attempt. A File with
+// an ID refers to a file already stored on the server and sends no bytes.
+// Size, when known, is reported as the total to upload progress callbacks.
+type File struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+	ID   string
+	Size int64
+}
+
+// FileFromID references a stored file by its fileId instead of uploading
+// it, e.g. the output of a previous operation. Supported by Merge and
+// every operation that takes one PDF; not by ScanDocument.
+func FileFromID(fileID string) File {
+	return File{ID: fileID}
+}
+
+// FileFromPath uploads a file from disk; it is reopened on retries
+func FileFromPath(path string) File {
+	f := File{
+		Name: filepath.Base(path),
+		Open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}
+	if info, err := os.Stat(path); err == nil {
+		f.Size = info.Size()
+	}
+	return f
+}
+
+// FileFromBytes uploads an in-memory file
+func FileFromBytes(name string, data []byte) File {
+	return File{
+		Name: name,
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+		Size: int64(len(data)),
+	}
+}
+
+// FileFromReader uploads from a stream. The stream can only be sent once,
+// so the request is not retried after the first attempt.
+func FileFromReader(name string, r io.Reader) File {
+	var once sync.Once
+	return File{
+		Name: name,
+		Open: func() (io.ReadCloser, error) {
+			var rc io.ReadCloser
+			once.Do(func() { rc = io.NopCloser(r) })
+			if rc == nil {
+				return nil, errBodyConsumed
+			}
+			return rc, nil
+		},
+	}
+}
+
+// formPart is one named file in a multipart body. progress, if set, is
+// called with the bytes sent so far as the file is streamed.
+type formPart struct {
+	field    string
+	file     File
+	progress func(sent, total int64)
+}
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
+
+// multipartBody streams fields and files as multipart/form-data without
+// buffering uploads in memory
+func multipartBody(fields map[string]string, parts []formPart) (func() (io.ReadCloser, error), string) {
+	boundary := multipart.NewWriter(nil).Boundary()
+
+	body := func() (io.ReadCloser, error) {
+		// Open every file up front so a consumed reader fails the attempt
+		// before anything is sent
+		readers := make([]io.ReadCloser, len(parts))
+		for i, p := range parts {
+			if p.file.ID != "" {
+				continue
+			}
+			rc, err := p.file.Open()
+			if err != nil {
+				for _, r := range readers[:i] {
+					if r != nil {
+						r.Close()
+					}
+				}
+				return nil, err
+			}
+			readers[i] = rc
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			mw := multipart.NewWriter(pw)
+			mw.SetBoundary(boundary)
+			err := func() error {
+				for k, v := range fields {
+					if v == "" {
+						continue
+					}
+					if err := mw.WriteField(k, v); err != nil {
+						return err
+					}
+				}
+				for i, p := range parts {
+					if p.file.ID != "" {
+						if err := mw.WriteField("fileId", p.file.ID); err != nil {
+							return err
+						}
+						continue
+					}
+					w, err := mw.CreateFormFile(p.field, p.file.Name)
+					if err != nil {
+						return err
+					}
+					var r io.Reader = readers[i]
+					if p.progress != nil {
+						r = &progressReader{r: r, total: p.file.Size, progress: p.progress}
+					}
+					if _, err := io.Copy(w, r); err != nil {
+						return err
+					}
+				}
+				return mw.Close()
+			}()
+			for _, r := range readers {
+				if r != nil {
+					r.Close()
+				}
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	return body, "multipart/form-data; boundary=" + boundary
+}
@@ -0,0 +1,642 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"brainy-pdf/internal/models"
+)
+
+// Result types are shared with the server so the SDK always matches its schema
+type (
+	OperationOutput    = models.OperationOutput
+	Retention          = models.Retention
+	OperationLog       = models.OperationLog
+	SingleFileResult   = models.SingleFileResult
+	MergeResult        = models.MergeResult
+	SplitResult        = models.SplitResult
+	FailedPart         = models.FailedPart
+	DetectedDocument   = models.DetectedDocument
+	AutoSplitResult    = models.AutoSplitResult
+	RotateResult       = models.RotateResult
+	CompressResult     = models.CompressResult
+	CompressEstimate   = models.CompressEstimate
+	ImageProfile       = models.ImageProfile
+	PhotoCompression   = models.PhotoCompression
+	CropResult         = models.CropResult
+	CropMargins        = models.CropMargins
+	WatermarkResult    = models.WatermarkResult
+	PageNumbersResult  = models.PageNumbersResult
+	ReorderResult      = models.ReorderResult
+	RemovePagesResult  = models.RemovePagesResult
+	ExtractResult      = models.ExtractResult
+	PageGeometry       = models.PageGeometry
+	PageLayoutResult   = models.PageLayoutResult
+	SearchResult       = models.SearchResult
+	SearchMatch        = models.SearchMatch
+	Heading            = models.Heading
+	StructureResult    = models.StructureResult
+	SanitizeReport     = models.SanitizeReport
+	SanitizeResult     = models.SanitizeResult
+	InvertSettings     = models.InvertSettings
+	InvertResult       = models.InvertResult
+	ProtectResult      = models.ProtectResult
+	UnlockResult       = models.UnlockResult
+	PageThumbnail      = models.PageThumbnail
+	ThumbnailsResult   = models.ThumbnailsResult
+	PreflightIssue     = models.PreflightIssue
+	PreflightImage     = models.PreflightImage
+	PreflightFont      = models.PreflightFont
+	PreflightReport    = models.PreflightReport
+	PreflightResult    = models.PreflightResult
+	ScanFinding        = models.ScanFinding
+	ScanReport         = models.ScanReport
+	ScanResult         = models.ScanResult
+	ScanPoint          = models.ScanPoint
+	ScannedPage        = models.ScannedPage
+	DocumentScanResult = models.DocumentScanResult
+	FromImagesResult   = models.FromImagesResult
+	PipelineStepResult = models.PipelineStepResult
+	PipelineResult     = models.PipelineResult
+)
+
+// pdfOp posts a multipart form to /api/pdf/<op>
+func (c *Client) pdfOp(ctx context.Context, op string, fields map[string]string, parts []formPart, out interface{}) error {
+	body, contentType := multipartBody(fields, parts)
+	return c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/pdf/" + op,
+		body:        body,
+		contentType: contentType,
+	}, out)
+}
+
+// pdfJSON posts a JSON body to /api/pdf/<op>
+func (c *Client) pdfJSON(ctx context.Context, op string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/pdf/" + op,
+		body:        func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+		contentType: "application/json",
+	}, out)
+}
+
+func single(file File) []formPart {
+	return []formPart{{field: "file", file: file}}
+}
+
+// Merge combines two or more PDFs in order. Files may mix uploads and
+// FileFromID references; mixed inputs are uploaded first as in MergeUploads.
+func (c *Client) Merge(ctx context.Context, files ...File) (*MergeResult, error) {
+	for _, f := range files {
+		if f.ID != "" {
+			return c.MergeUploads(ctx, nil, files...)
+		}
+	}
+
+	parts := make([]formPart, len(files))
+	for i, f := range files {
+		parts[i] = formPart{field: "files", file: f}
+	}
+	var res MergeResult
+	if err := c.pdfOp(ctx, "merge", nil, parts, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MergeUploads uploads the files in parallel, reporting per-file progress,
+// then merges them by fileId in one small request. A failed upload is
+// retried on its own instead of resending every file.
+func (c *Client) MergeUploads(ctx context.Context, progress UploadProgress, files ...File) (*MergeResult, error) {
+	refs, err := c.UploadAll(ctx, files, progress)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(refs))
+	for i, f := range refs {
+		ids[i] = f.ID
+	}
+
+	var res MergeResult
+	if err := c.pdfJSON(ctx, "merge", map[string]interface{}{"fileIds": ids}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Split produces one PDF per comma-separated range, e.g. "1-3, 4-7"
+func (c *Client) Split(ctx context.Context, file File, pages string) (*SplitResult, error) {
+	var res SplitResult
+	if err := c.pdfOp(ctx, "split", map[string]string{"pages": pages}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AutoSplitOptions configures AutoSplit; zero values use the AI when the
+// server has it and store one file per document
+type AutoSplitOptions struct {
+	LayoutOnly bool // Find boundaries from page layout alone, without the AI
+	DryRun     bool // Only report the documents found
+}
+
+// AutoSplit splits a PDF holding several documents, such as scanned
+// invoices, into one file per document named by vendor and date
+func (c *Client) AutoSplit(ctx context.Context, file File, opts AutoSplitOptions) (*AutoSplitResult, error) {
+	fields := map[string]string{}
+	if opts.LayoutOnly {
+		fields["ai"] = "false"
+	}
+	if opts.DryRun {
+		fields["dryRun"] = "true"
+	}
+	var res AutoSplitResult
+	if err := c.pdfOp(ctx, "auto-split", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Rotate rotates the selected pages (all when pages is empty) by 90, 180 or 270 degrees
+func (c *Client) Rotate(ctx context.Context, file File, angle int, pages string) (*RotateResult, error) {
+	fields := map[string]string{"angle": strconv.Itoa(angle), "pages": pages}
+	var res RotateResult
+	if err := c.pdfOp(ctx, "rotate", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RotatePages rotates each page in rotations, keyed by page number, by its
+// angle (90, 180 or 270 degrees) in one request
+func (c *Client) RotatePages(ctx context.Context, file File, rotations map[int]int) (*RotateResult, error) {
+	byPage := make(map[string]int, len(rotations))
+	for page, angle := range rotations {
+		byPage[strconv.Itoa(page)] = angle
+	}
+	encoded, err := json.Marshal(byPage)
+	if err != nil {
+		return nil, err
+	}
+	var res RotateResult
+	if err := c.pdfOp(ctx, "rotate", map[string]string{"rotations": string(encoded)}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Compress optimizes a PDF; quality is low, medium or high
+func (c *Client) Compress(ctx context.Context, file File, quality string) (*CompressResult, error) {
+	var res CompressResult
+	if err := c.pdfOp(ctx, "compress", map[string]string{"quality": quality}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CompressPhotos compresses a scanned or photo-heavy PDF in photo mode,
+// recompressing its JPEGs at quality (low, medium or high) and downsampling
+// them to dpi; 0 uses the server default
+func (c *Client) CompressPhotos(ctx context.Context, file File, quality string, dpi int) (*CompressResult, error) {
+	var res CompressResult
+	if err := c.pdfOp(ctx, "compress", photoFields(quality, dpi, false), single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// EstimateCompression reports how much Compress (mode "standard") or
+// CompressPhotos (mode "photo") would save, and the file's image profile,
+// without storing anything
+func (c *Client) EstimateCompression(ctx context.Context, file File, mode, quality string, dpi int) (*CompressEstimate, error) {
+	fields := photoFields(quality, dpi, true)
+	fields["mode"] = mode
+	var res CompressEstimate
+	if err := c.pdfOp(ctx, "compress", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// photoFields builds the form fields of a photo mode compress
+func photoFields(quality string, dpi int, dryRun bool) map[string]string {
+	fields := map[string]string{"quality": quality, "mode": "photo"}
+	if dpi > 0 {
+		fields["dpi"] = strconv.Itoa(dpi)
+	}
+	if dryRun {
+		fields["dryRun"] = "true"
+	}
+	return fields
+}
+
+// Crop trims the given margins (in points) from every page
+func (c *Client) Crop(ctx context.Context, file File, margins CropMargins) (*CropResult, error) {
+	fields := map[string]string{
+		"top":    formatFloat(margins.Top),
+		"right":  formatFloat(margins.Right),
+		"bottom": formatFloat(margins.Bottom),
+		"left":   formatFloat(margins.Left),
+	}
+	var res CropResult
+	if err := c.pdfOp(ctx, "crop", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// WatermarkOptions configures Watermark; zero values use server defaults.
+// Setting Image stamps it instead of Text.
+type WatermarkOptions struct {
+	Text     string
+	Image    *File   // PNG or JPEG logo
+	Scale    float64 // Image width relative to the page, 0.05 to 1
+	Position string
+	Opacity  float64
+	FontSize int
+	Color    string  // Hex, e.g. "#FF0000"
+	Font     string  // e.g. "helvetica", "times-bold", "courier"
+	Mode     string  // "diagonal" or "horizontal"
+	Rotation float64 // Degrees; overrides the diagonal angle
+	Tile     bool
+}
+
+// Watermark stamps text, or an image, on every page
+func (c *Client) Watermark(ctx context.Context, file File, opts WatermarkOptions) (*WatermarkResult, error) {
+	fields := map[string]string{"text": opts.Text, "position": opts.Position}
+	parts := single(file)
+	if opts.Image != nil {
+		fields["type"] = "image"
+		parts = append(parts, formPart{field: "image", file: *opts.Image})
+	}
+	if opts.Scale > 0 {
+		fields["scale"] = formatFloat(opts.Scale)
+	}
+	if opts.Opacity > 0 {
+		fields["opacity"] = formatFloat(opts.Opacity)
+	}
+	if opts.FontSize > 0 {
+		fields["fontSize"] = strconv.Itoa(opts.FontSize)
+	}
+	fields["color"] = opts.Color
+	fields["font"] = opts.Font
+	fields["mode"] = opts.Mode
+	if opts.Rotation != 0 {
+		fields["rotation"] = formatFloat(opts.Rotation)
+	}
+	if opts.Tile {
+		fields["tile"] = "true"
+	}
+	var res WatermarkResult
+	if err := c.pdfOp(ctx, "watermark", fields, parts, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PageNumberOptions configures PageNumbers; zero values use server defaults
+type PageNumberOptions struct {
+	Position    string
+	Format      string // {n} and {total}, e.g. "Page {n} of {total}"
+	StartFrom   int
+	Style       string // "arabic", "roman" or "roman-upper"
+	FrontMatter int    // Leading pages numbered i, ii, iii
+	Skip        string // Pages left unnumbered, e.g. "1"
+	Mirror      bool   // Swap left/right on even pages
+}
+
+// PageNumbers adds page numbers to every page
+func (c *Client) PageNumbers(ctx context.Context, file File, opts PageNumberOptions) (*PageNumbersResult, error) {
+	fields := map[string]string{"position": opts.Position, "format": opts.Format}
+	if opts.StartFrom > 0 {
+		fields["startFrom"] = strconv.Itoa(opts.StartFrom)
+	}
+	fields["style"] = opts.Style
+	fields["skip"] = opts.Skip
+	if opts.FrontMatter > 0 {
+		fields["frontMatter"] = strconv.Itoa(opts.FrontMatter)
+	}
+	if opts.Mirror {
+		fields["mirror"] = "true"
+	}
+	var res PageNumbersResult
+	if err := c.pdfOp(ctx, "page-numbers", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Reorder rearranges pages into the given 1-based order
+func (c *Client) Reorder(ctx context.Context, file File, order []int) (*ReorderResult, error) {
+	parts := make([]string, len(order))
+	for i, p := range order {
+		parts[i] = strconv.Itoa(p)
+	}
+	var res ReorderResult
+	if err := c.pdfOp(ctx, "reorder", map[string]string{"order": strings.Join(parts, ",")}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RemovePages deletes the selected pages, e.g. "2,5-7"
+func (c *Client) RemovePages(ctx context.Context, file File, pages string) (*RemovePagesResult, error) {
+	var res RemovePagesResult
+	if err := c.pdfOp(ctx, "remove", map[string]string{"pages": pages}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Extract creates a PDF with only the selected pages
+func (c *Client) Extract(ctx context.Context, file File, pages string) (*ExtractResult, error) {
+	var res ExtractResult
+	if err := c.pdfOp(ctx, "extract", map[string]string{"pages": pages}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PipelineStep is one operation of a Pipeline with its parameters, named as
+// in the operation's own endpoint, e.g.
+// PipelineStep{"operation": "rotate", "angle": 90}
+type PipelineStep map[string]interface{}
+
+// Pipeline applies steps to file in order in one request (rotate, crop,
+// text watermark, page-numbers, compress, reorder, remove, extract and
+// sanitize), storing only the final PDF
+func (c *Client) Pipeline(ctx context.Context, file File, steps ...PipelineStep) (*PipelineResult, error) {
+	raw, err := json.Marshal(steps)
+	if err != nil {
+		return nil, err
+	}
+	var res PipelineResult
+	if err := c.pdfOp(ctx, "pipeline", map[string]string{"steps": string(raw)}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// History lists the caller's recent operations, optionally filtered by
+// operation name
+func (c *Client) History(ctx context.Context, operation string, limit int) ([]OperationLog, error) {
+	query := map[string]string{"operation": operation}
+	if limit > 0 {
+		query["limit"] = strconv.Itoa(limit)
+	}
+	var res struct {
+		Operations []OperationLog `json:"operations"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/pdf/history", query: query}, &res); err != nil {
+		return nil, err
+	}
+	return res.Operations, nil
+}
+
+// PageLayout returns the displayed size and rotation of each page of a
+// stored file, in points
+func (c *Client) PageLayout(ctx context.Context, fileID string) (*PageLayoutResult, error) {
+	var res PageLayoutResult
+	path := "/api/pdf/" + url.PathEscape(fileID) + "/pages"
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SearchOptions configures Search; zero values use server defaults
+type SearchOptions struct {
+	CaseSensitive bool
+	Limit         int
+}
+
+// Search finds query in a PDF, returning each match's page and rectangle
+func (c *Client) Search(ctx context.Context, file File, query string, opts SearchOptions) (*SearchResult, error) {
+	fields := map[string]string{"query": query}
+	if opts.CaseSensitive {
+		fields["caseSensitive"] = "true"
+	}
+	if opts.Limit > 0 {
+		fields["limit"] = strconv.Itoa(opts.Limit)
+	}
+	var res SearchResult
+	if err := c.pdfOp(ctx, "search", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// StructureOptions configures DetectStructure
+type StructureOptions struct {
+	WriteBookmarks bool // Store a copy with the outline as bookmarks
+	AI             bool // Have the AI model confirm the headings
+	MaxHeadings    int
+}
+
+// DetectStructure infers the headings of a PDF
+func (c *Client) DetectStructure(ctx context.Context, file File, opts StructureOptions) (*StructureResult, error) {
+	fields := map[string]string{
+		"writeBookmarks": strconv.FormatBool(opts.WriteBookmarks),
+		"ai":             strconv.FormatBool(opts.AI),
+	}
+	if opts.MaxHeadings > 0 {
+		fields["maxHeadings"] = strconv.Itoa(opts.MaxHeadings)
+	}
+	var res StructureResult
+	if err := c.pdfOp(ctx, "detect-structure", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Sanitize removes metadata, scripts, attachments, hidden layers and
+// earlier revisions from a PDF before it is shared
+func (c *Client) Sanitize(ctx context.Context, file File) (*SanitizeResult, error) {
+	var res SanitizeResult
+	if err := c.pdfOp(ctx, "sanitize", nil, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Scan reports JavaScript, launch actions, external URIs and embedded
+// executables in a PDF without changing it
+func (c *Client) Scan(ctx context.Context, file File) (*ScanResult, error) {
+	var res ScanResult
+	if err := c.pdfOp(ctx, "scan", nil, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ScanDocumentOptions configures ScanDocument; zero values use server
+// defaults (grayscale on A4, pages found automatically)
+type ScanDocumentOptions struct {
+	Mode     string // "color", "grayscale" or "bw"
+	PageSize string // "A4" or "Letter"
+	// Corners optionally gives each photo's page corners, top-left first
+	// and clockwise; a nil entry finds that page automatically
+	Corners [][]ScanPoint
+}
+
+// ScanDocument turns phone photos of paper pages, in page order, into one
+// straightened, contrast-enhanced PDF
+func (c *Client) ScanDocument(ctx context.Context, photos []File, opts ScanDocumentOptions) (*DocumentScanResult, error) {
+	fields := map[string]string{"mode": opts.Mode, "pageSize": opts.PageSize}
+	if len(opts.Corners) > 0 {
+		corners, err := json.Marshal(opts.Corners)
+		if err != nil {
+			return nil, err
+		}
+		fields["corners"] = string(corners)
+	}
+	parts := make([]formPart, len(photos))
+	for i, f := range photos {
+		parts[i] = formPart{field: "images", file: f}
+	}
+	var res DocumentScanResult
+	if err := c.pdfOp(ctx, "scan-document", fields, parts, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FromImagesOptions configures FromImages; zero values use server defaults
+// (A4, each page oriented to its image, no margin)
+type FromImagesOptions struct {
+	PageSize    string  // "A4", "Letter", "Legal" or "Fit" to size pages to their images
+	Orientation string  // "portrait", "landscape" or "auto"
+	Margin      float64 // Points
+}
+
+// FromImages assembles JPEG or PNG images, in page order, into one PDF
+// without correcting them
+func (c *Client) FromImages(ctx context.Context, images []File, opts FromImagesOptions) (*FromImagesResult, error) {
+	fields := map[string]string{"pageSize": opts.PageSize, "orientation": opts.Orientation}
+	if opts.Margin > 0 {
+		fields["margin"] = strconv.FormatFloat(opts.Margin, 'f', -1, 64)
+	}
+	parts := make([]formPart, len(images))
+	for i, f := range images {
+		parts[i] = formPart{field: "images", file: f}
+	}
+	var res FromImagesResult
+	if err := c.pdfOp(ctx, "from-images", fields, parts, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// InvertOptions configures Invert; zero values use server defaults
+type InvertOptions struct {
+	Background string // Hex page color, e.g. "#1E1E1E"
+	Foreground string // Hex color for black text
+	DimImages  bool
+}
+
+// Invert produces a dark-mode version of a PDF for on-screen reading
+func (c *Client) Invert(ctx context.Context, file File, opts InvertOptions) (*InvertResult, error) {
+	fields := map[string]string{"background": opts.Background, "foreground": opts.Foreground}
+	if opts.DimImages {
+		fields["images"] = "dim"
+	}
+	var res InvertResult
+	if err := c.pdfOp(ctx, "invert", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ProtectOptions configures Protect; Password is required
+type ProtectOptions struct {
+	Password      string
+	OwnerPassword string   // Required with Restrict
+	Restrict      []string // "print", "copy", "modify", "annotate"
+}
+
+// Protect encrypts a PDF so it opens only with opts.Password, optionally
+// restricting what readers without the owner password may do
+func (c *Client) Protect(ctx context.Context, file File, opts ProtectOptions) (*ProtectResult, error) {
+	fields := map[string]string{
+		"password":      opts.Password,
+		"ownerPassword": opts.OwnerPassword,
+		"restrict":      strings.Join(opts.Restrict, ","),
+	}
+	var res ProtectResult
+	if err := c.pdfOp(ctx, "protect", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Unlock removes the password and restrictions from a PDF given its user
+// or owner password
+func (c *Client) Unlock(ctx context.Context, file File, password string) (*UnlockResult, error) {
+	var res UnlockResult
+	if err := c.pdfOp(ctx, "unlock", map[string]string{"password": password}, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PreflightOptions configures Preflight; zero values use server defaults
+type PreflightOptions struct {
+	MinDPI  int
+	BleedMM float64
+}
+
+// Preflight checks a PDF for print: image resolution, RGB color, trim and
+// bleed boxes, transparency and font embedding
+func (c *Client) Preflight(ctx context.Context, file File, opts PreflightOptions) (*PreflightResult, error) {
+	fields := map[string]string{}
+	if opts.MinDPI > 0 {
+		fields["minDpi"] = strconv.Itoa(opts.MinDPI)
+	}
+	if opts.BleedMM > 0 {
+		fields["bleed"] = formatFloat(opts.BleedMM)
+	}
+	var res PreflightResult
+	if err := c.pdfOp(ctx, "preflight", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ThumbnailOptions configures Thumbnails; zero values use server defaults
+type ThumbnailOptions struct {
+	DPI     int
+	Format  string // png or jpeg
+	Quality int    // JPEG quality, 1-100
+}
+
+// Thumbnails renders pages (e.g. "1-3,5"; all when empty) of a PDF to
+// images, one stored file per page
+func (c *Client) Thumbnails(ctx context.Context, file File, pages string, opts ThumbnailOptions) (*ThumbnailsResult, error) {
+	fields := map[string]string{"pages": pages, "format": opts.Format}
+	if opts.DPI > 0 {
+		fields["dpi"] = strconv.Itoa(opts.DPI)
+	}
+	if opts.Quality > 0 {
+		fields["quality"] = strconv.Itoa(opts.Quality)
+	}
+	var res ThumbnailsResult
+	if err := c.pdfOp(ctx, "thumbnails", fields, single(file), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxParallelUploads bounds the concurrent requests made by UploadAll
+const maxParallelUploads = 4
+
+// UploadedFile is a file stored by Upload
+type UploadedFile struct {
+	FileID      string     `json:"fileId"`
+	Filename    string     `json:"filename"`
+	Size        int64      `json:"size"`
+	IsTemporary bool       `json:"isTemporary"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Retention   *Retention `json:"retention,omitempty"`
+}
+
+// UploadProgress reports the bytes of the index'th file sent so far. total
+// is the File's Size, or 0 when unknown. Calls for different files may
+// arrive concurrently, and restart from 0 when an upload is retried.
+type UploadProgress func(index int, sent, total int64)
+
+// Upload stores file as a temporary upload that operations can then
+// reference with FileFromID. progress may be nil.
+func (c *Client) Upload(ctx context.Context, file File, progress func(sent, total int64)) (*UploadedFile, error) {
+	parts := []formPart{{field: "file", file: file, progress: progress}}
+	body, contentType := multipartBody(map[string]string{"temporary": "true"}, parts)
+	var res UploadedFile
+	if err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/v1/files/upload",
+		body:        body,
+		contentType: contentType,
+	}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SaveToLibrary keeps a temporary upload or operation output in the
+// caller's library so it no longer expires; see the Retention of outputs
+func (c *Client) SaveToLibrary(ctx context.Context, fileID string) (*UploadedFile, error) {
+	var res UploadedFile
+	path := "/api/v1/files/" + url.PathEscape(fileID) + "/save"
+	if err := c.do(ctx, request{method: http.MethodPost, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UploadAll uploads files in parallel and returns FileFromID references to
+// them in the same order. Files that already have an ID are passed through.
+// The first failure cancels the uploads still running.
+func (c *Client) UploadAll(ctx context.Context, files []File, progress UploadProgress) ([]File, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	refs := make([]File, len(files))
+	sem := make(chan struct{}, maxParallelUploads)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i, f := range files {
+		if f.ID != "" {
+			refs[i] = f
+			continue
+		}
+		wg.Add(1)
+		go func(i int, f File) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			var onProgress func(sent, total int64)
+			if progress != nil {
+				onProgress = func(sent, total int64) { progress(i, sent, total) }
+			}
+			res, err := c.Upload(ctx, f, onProgress)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			refs[i] = FileFromID(res.FileID)
+		}(i, f)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}